@@ -0,0 +1,373 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"os"
+	"os/signal"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"tip-server/internal/models"
+)
+
+// falsePositiveMetric counts Bloom filter hits that ClickHouse did not confirm
+const falsePositiveMetric = "tip_bloom_filter_false_positives_total"
+
+// runCheck sends batches of known and unknown IOCs to POST /check at a fixed
+// rate and reports latency percentiles, recall and the Bloom filter
+// false-positive rate
+func runCheck(args []string) error {
+	fs := flag.NewFlagSet("check", flag.ExitOnError)
+	baseURL := fs.String("url", "http://localhost:8080", "API server base URL")
+	apiKey := fs.String("api-key", os.Getenv("TIP_API_KEY"), "API key (default $TIP_API_KEY)")
+	metricsURL := fs.String("metrics-url", "http://localhost:9090/metrics", "Prometheus endpoint of the API server; empty skips the false-positive rate")
+	known := fs.String("known", "", "File of IOCs known to the server, e.g. the planted.txt of an ingested corpus")
+	qps := fs.Float64("qps", 50, "Target requests per second")
+	duration := fs.Duration("duration", 30*time.Second, "How long to send requests")
+	batch := fs.Int("batch", 20, "IOCs per request (at most 1000)")
+	hitRatio := fs.Float64("hit-ratio", 0.1, "Fraction of each batch drawn from -known")
+	concurrency := fs.Int("concurrency", 64, "Maximum requests in flight")
+	seed := fs.Uint64("seed", 2, "Random seed for unknown IOCs")
+	fs.Parse(args)
+
+	if *qps <= 0 || *duration <= 0 || *concurrency <= 0 {
+		return errors.New("-qps, -duration and -concurrency must be positive")
+	}
+	if *batch < 1 || *batch > 1000 {
+		return errors.New("-batch must be between 1 and 1000")
+	}
+
+	var knownValues []string
+	if *known != "" {
+		var err error
+		if knownValues, err = readLines(*known); err != nil {
+			return err
+		}
+	}
+	if len(knownValues) == 0 {
+		*hitRatio = 0
+	}
+
+	mix, _ := parseMix(defaultMix)
+	gen := newGenerator(*seed, mix)
+	client := &http.Client{Timeout: 30 * time.Second}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	fpBefore, fpErr := scrapeCounter(ctx, client, *metricsURL, falsePositiveMetric)
+	if fpErr != nil && *metricsURL != "" {
+		log.Warn().Err(fpErr).Msg("Failed to scrape metrics, the false-positive rate will not be reported")
+	}
+
+	r := &checkRun{
+		client: client,
+		url:    strings.TrimRight(*baseURL, "/") + "/check",
+		apiKey: *apiKey,
+	}
+
+	log.Info().
+		Str("url", r.url).
+		Float64("qps", *qps).
+		Dur("duration", *duration).
+		Int("batch", *batch).
+		Float64("hit_ratio", *hitRatio).
+		Int("known", len(knownValues)).
+		Msg("Starting load")
+
+	// Requests are dispatched open-loop: a slow server does not lower the
+	// offered rate, and ticks with no free slot are counted as dropped
+	sem := make(chan struct{}, *concurrency)
+	ticker := time.NewTicker(time.Duration(float64(time.Second) / *qps))
+	defer ticker.Stop()
+	deadline := time.After(*duration)
+	start := time.Now()
+	var wg sync.WaitGroup
+
+dispatch:
+	for {
+		select {
+		case <-ctx.Done():
+			break dispatch
+		case <-deadline:
+			break dispatch
+		case <-ticker.C:
+		}
+
+		select {
+		case sem <- struct{}{}:
+		default:
+			r.dropped.Add(1)
+			continue
+		}
+
+		iocs, expected := buildBatch(gen, knownValues, *batch, *hitRatio)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			r.send(ctx, iocs, expected)
+		}()
+	}
+	wg.Wait()
+	elapsed := time.Since(start)
+
+	report := r.report(elapsed)
+	if fpErr == nil {
+		if fpAfter, err := scrapeCounter(context.Background(), client, *metricsURL, falsePositiveMetric); err != nil {
+			log.Warn().Err(err).Msg("Failed to scrape metrics after the run")
+		} else {
+			report.falsePositives = int64(fpAfter - fpBefore)
+			report.fpMeasured = true
+		}
+	}
+	report.print(os.Stdout)
+
+	if report.requests == 0 {
+		return errors.New("no request succeeded")
+	}
+	return nil
+}
+
+// buildBatch returns n IOCs, each drawn from known with probability
+// hitRatio and generated otherwise, and which of them are known
+func buildBatch(gen *generator, known []string, n int, hitRatio float64) ([]string, map[string]bool) {
+	iocs := make([]string, 0, n)
+	expected := make(map[string]bool, n)
+	for range n {
+		var value string
+		isKnown := len(known) > 0 && rand.Float64() < hitRatio
+		if isKnown {
+			value = known[rand.IntN(len(known))]
+		} else {
+			value, _ = gen.value()
+		}
+		iocs = append(iocs, value)
+		expected[value] = expected[value] || isKnown
+	}
+	return iocs, expected
+}
+
+// checkRun collects the outcome of the requests of one run
+type checkRun struct {
+	client *http.Client
+	url    string
+	apiKey string
+
+	dropped atomic.Int64
+
+	mu        sync.Mutex
+	latencies []time.Duration
+	errors    int64
+	knownSent int64
+	knownHit  int64
+	unknown   int64
+	unexpect  int64 // Unknown values the server reported found
+}
+
+// send posts one batch and records its latency and verdicts
+func (r *checkRun) send(ctx context.Context, iocs []string, expected map[string]bool) {
+	body, _ := json.Marshal(models.CheckRequest{IOCs: iocs})
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.url, bytes.NewReader(body))
+	if err != nil {
+		r.fail(err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if r.apiKey != "" {
+		req.Header.Set("X-API-Key", r.apiKey)
+	}
+
+	start := time.Now()
+	resp, err := r.client.Do(req)
+	if err != nil {
+		if ctx.Err() == nil {
+			r.fail(err)
+		}
+		return
+	}
+	defer resp.Body.Close()
+
+	var result models.CheckResponse
+	if resp.StatusCode != http.StatusOK {
+		io.Copy(io.Discard, resp.Body)
+		r.fail(fmt.Errorf("unexpected status %d", resp.StatusCode))
+		return
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		r.fail(err)
+		return
+	}
+	latency := time.Since(start)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.latencies = append(r.latencies, latency)
+	for _, res := range result.Results {
+		value := res.Input
+		if value == "" {
+			value = res.IOC
+		}
+		if expected[value] {
+			r.knownSent++
+			if res.Found {
+				r.knownHit++
+			}
+		} else {
+			r.unknown++
+			if res.Found {
+				r.unexpect++
+			}
+		}
+	}
+}
+
+// fail records a failed request, logging the first few
+func (r *checkRun) fail(err error) {
+	r.mu.Lock()
+	r.errors++
+	n := r.errors
+	r.mu.Unlock()
+
+	if n <= 5 {
+		log.Warn().Err(err).Msg("Check request failed")
+	}
+}
+
+// checkReport summarises a run
+type checkReport struct {
+	elapsed        time.Duration
+	requests       int
+	errors         int64
+	dropped        int64
+	p50, p90, p99  time.Duration
+	max            time.Duration
+	knownSent      int64
+	knownHit       int64
+	unknown        int64
+	unexpect       int64
+	falsePositives int64
+	fpMeasured     bool
+}
+
+func (r *checkRun) report(elapsed time.Duration) *checkReport {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	slices.Sort(r.latencies)
+	rep := &checkReport{
+		elapsed:   elapsed,
+		requests:  len(r.latencies),
+		errors:    r.errors,
+		dropped:   r.dropped.Load(),
+		knownSent: r.knownSent,
+		knownHit:  r.knownHit,
+		unknown:   r.unknown,
+		unexpect:  r.unexpect,
+	}
+	if n := len(r.latencies); n > 0 {
+		rep.p50 = percentile(r.latencies, 50)
+		rep.p90 = percentile(r.latencies, 90)
+		rep.p99 = percentile(r.latencies, 99)
+		rep.max = r.latencies[n-1]
+	}
+	return rep
+}
+
+// percentile returns the p-th percentile of sorted latencies by the
+// nearest-rank method
+func percentile(sorted []time.Duration, p int) time.Duration {
+	rank := (p*len(sorted) + 99) / 100
+	return sorted[max(rank-1, 0)]
+}
+
+func (rep *checkReport) print(w io.Writer) {
+	ratio := func(n, d int64) string {
+		if d == 0 {
+			return "n/a"
+		}
+		return fmt.Sprintf("%.4f%% (%d/%d)", 100*float64(n)/float64(d), n, d)
+	}
+
+	fmt.Fprintf(w, "Duration:        %s\n", rep.elapsed.Round(time.Millisecond))
+	fmt.Fprintf(w, "Requests:        %d ok, %d failed, %d dropped\n", rep.requests, rep.errors, rep.dropped)
+	fmt.Fprintf(w, "Achieved rate:   %.1f req/s\n", float64(rep.requests)/rep.elapsed.Seconds())
+	fmt.Fprintf(w, "Latency p50:     %s\n", rep.p50.Round(time.Microsecond))
+	fmt.Fprintf(w, "Latency p90:     %s\n", rep.p90.Round(time.Microsecond))
+	fmt.Fprintf(w, "Latency p99:     %s\n", rep.p99.Round(time.Microsecond))
+	fmt.Fprintf(w, "Latency max:     %s\n", rep.max.Round(time.Microsecond))
+	fmt.Fprintf(w, "Known recall:    %s\n", ratio(rep.knownHit, rep.knownSent))
+	fmt.Fprintf(w, "Unknown found:   %s\n", ratio(rep.unexpect, rep.unknown))
+	if rep.fpMeasured {
+		fmt.Fprintf(w, "Bloom FP rate:   %s\n", ratio(rep.falsePositives, rep.unknown))
+	} else {
+		fmt.Fprintf(w, "Bloom FP rate:   not measured\n")
+	}
+}
+
+// scrapeCounter reads a counter from a Prometheus text endpoint, summing
+// all of its series
+func scrapeCounter(ctx context.Context, client *http.Client, url, name string) (float64, error) {
+	if url == "" {
+		return 0, errors.New("no metrics URL")
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	var total float64
+	found := false
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, name) {
+			continue
+		}
+		rest := line[len(name):]
+		if rest == "" || (rest[0] != ' ' && rest[0] != '{') {
+			continue
+		}
+		fields := strings.Fields(rest[strings.LastIndexByte(rest, '}')+1:])
+		if len(fields) == 0 {
+			continue
+		}
+		v, err := strconv.ParseFloat(fields[0], 64)
+		if err != nil {
+			continue
+		}
+		total += v
+		found = true
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, err
+	}
+	if !found {
+		return 0, fmt.Errorf("metric %s not exposed", name)
+	}
+	return total, nil
+}
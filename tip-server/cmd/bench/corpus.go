@@ -0,0 +1,266 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+
+	"tip-server/internal/models"
+)
+
+// PlantedFile lists the IOCs planted in a corpus, one value per line
+const PlantedFile = "planted.txt"
+
+// defaultMix is the share of each IOC type among planted values
+const defaultMix = "ipv4=30,domain=30,url=15,sha256=15,md5=5,email=5"
+
+// runCorpus writes a synthetic corpus: text files of filler words with IOCs
+// planted at a given density, and the list of planted values
+func runCorpus(args []string) error {
+	fs := flag.NewFlagSet("corpus", flag.ExitOnError)
+	out := fs.String("out", "", "Directory to write the corpus to (required)")
+	files := fs.Int("files", 100, "Number of files")
+	sizeKB := fs.Int("size", 64, "Size of each file in KiB")
+	density := fs.Float64("density", 2, "Planted IOCs per KiB of text")
+	mixSpec := fs.String("mix", defaultMix, "Share of each IOC type, e.g. ipv4=50,sha256=50")
+	defang := fs.Float64("defang", 0.2, "Fraction of planted network IOCs written defanged")
+	seed := fs.Uint64("seed", 1, "Random seed; the same seed gives the same corpus")
+	fs.Parse(args)
+
+	if *out == "" {
+		return errors.New("-out is required")
+	}
+	if *files <= 0 || *sizeKB <= 0 || *density < 0 {
+		return errors.New("-files and -size must be positive and -density not negative")
+	}
+	mix, err := parseMix(*mixSpec)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(*out, 0o755); err != nil {
+		return err
+	}
+
+	gen := newGenerator(*seed, mix)
+	planted := make(map[string]struct{})
+	perFile := int(*density * float64(*sizeKB))
+
+	for n := 1; n <= *files; n++ {
+		path := filepath.Join(*out, fmt.Sprintf("doc-%05d.txt", n))
+		values, err := gen.writeDocument(path, *sizeKB<<10, perFile, *defang)
+		if err != nil {
+			return err
+		}
+		for _, v := range values {
+			planted[v] = struct{}{}
+		}
+	}
+
+	values := make([]string, 0, len(planted))
+	for v := range planted {
+		values = append(values, v)
+	}
+	sort.Strings(values)
+	if err := writeLines(filepath.Join(*out, PlantedFile), values); err != nil {
+		return err
+	}
+
+	log.Info().
+		Str("dir", *out).
+		Int("files", *files).
+		Int("size_kib", *sizeKB).
+		Int("iocs_per_file", perFile).
+		Int("distinct_iocs", len(values)).
+		Msg("Corpus written")
+	return nil
+}
+
+// typeShare is the weight of one IOC type in a mix
+type typeShare struct {
+	iocType models.IOCType
+	weight  int
+}
+
+// parseMix parses "type=weight,..." into weighted IOC types
+func parseMix(spec string) ([]typeShare, error) {
+	var mix []typeShare
+	for _, part := range strings.Split(spec, ",") {
+		name, weight, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid mix entry %q, want type=weight", part)
+		}
+		w, err := strconv.Atoi(weight)
+		if err != nil || w < 0 {
+			return nil, fmt.Errorf("invalid weight in mix entry %q", part)
+		}
+		t := models.IOCType(name)
+		switch t {
+		case models.IOCTypeIPv4, models.IOCTypeDomain, models.IOCTypeURL,
+			models.IOCTypeSHA256, models.IOCTypeMD5, models.IOCTypeEmail:
+		default:
+			return nil, fmt.Errorf("unsupported IOC type %q in mix", name)
+		}
+		if w > 0 {
+			mix = append(mix, typeShare{t, w})
+		}
+	}
+	if len(mix) == 0 {
+		return nil, errors.New("the mix has no IOC type with a positive weight")
+	}
+	return mix, nil
+}
+
+// generator produces synthetic IOC values and filler text
+type generator struct {
+	rng   *rand.Rand
+	mix   []typeShare
+	total int
+}
+
+func newGenerator(seed uint64, mix []typeShare) *generator {
+	g := &generator{rng: rand.New(rand.NewPCG(seed, seed^0x9e3779b97f4a7c15)), mix: mix}
+	for _, s := range mix {
+		g.total += s.weight
+	}
+	return g
+}
+
+// fillerWords pad documents between planted IOCs
+var fillerWords = strings.Fields(`the actor campaign observed infrastructure
+	payload loader beacon traffic host analysis sample network activity report
+	operator malware persistence lateral movement credential phishing lure
+	delivered stage second first channel command control server victim
+	organisation sector exploit vulnerability patched indicator attributed`)
+
+// tlds are the top-level domains of generated domains
+var tlds = []string{"com", "net", "org", "info", "biz", "xyz", "top", "ru", "io"}
+
+// value returns a random IOC of a type drawn from the mix
+func (g *generator) value() (string, models.IOCType) {
+	n := g.rng.IntN(g.total)
+	t := g.mix[len(g.mix)-1].iocType
+	for _, s := range g.mix {
+		if n < s.weight {
+			t = s.iocType
+			break
+		}
+		n -= s.weight
+	}
+
+	switch t {
+	case models.IOCTypeIPv4:
+		// Public unicast, avoiding 0/8, 10/8, 127/8 and multicast
+		return fmt.Sprintf("%d.%d.%d.%d", 11+g.rng.IntN(112), g.rng.IntN(256), g.rng.IntN(256), 1+g.rng.IntN(254)), t
+	case models.IOCTypeDomain:
+		return g.domain(), t
+	case models.IOCTypeURL:
+		return fmt.Sprintf("http://%s/%s.php", g.domain(), g.label(4, 10)), t
+	case models.IOCTypeSHA256:
+		return g.hex(64), t
+	case models.IOCTypeMD5:
+		return g.hex(32), t
+	default:
+		return g.label(4, 10) + "@" + g.domain(), models.IOCTypeEmail
+	}
+}
+
+func (g *generator) domain() string {
+	return g.label(6, 14) + "-" + g.label(3, 8) + "." + tlds[g.rng.IntN(len(tlds))]
+}
+
+func (g *generator) label(minLen, maxLen int) string {
+	b := make([]byte, minLen+g.rng.IntN(maxLen-minLen+1))
+	for i := range b {
+		b[i] = 'a' + byte(g.rng.IntN(26))
+	}
+	return string(b)
+}
+
+func (g *generator) hex(n int) string {
+	const digits = "0123456789abcdef"
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = digits[g.rng.IntN(16)]
+	}
+	return string(b)
+}
+
+// defangReplacer writes network IOCs the way reports commonly do
+var defangReplacer = strings.NewReplacer(".", "[.]", "http://", "hxxp://", "@", "[@]")
+
+// writeDocument writes about size bytes of filler text with count IOCs
+// spread through it and returns the planted values
+func (g *generator) writeDocument(path string, size, count int, defang float64) ([]string, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	w := bufio.NewWriter(f)
+
+	// Planted IOCs are placed at random word offsets
+	words := max(size/8, count+1)
+	at := make(map[int]int)
+	for range count {
+		at[g.rng.IntN(words)]++
+	}
+
+	var planted []string
+	line := 0
+	for i := 0; i < words; i++ {
+		for range at[i] {
+			value, t := g.value()
+			planted = append(planted, value)
+			if t != models.IOCTypeSHA256 && t != models.IOCTypeMD5 && g.rng.Float64() < defang {
+				value = defangReplacer.Replace(value)
+			}
+			w.WriteString(value)
+			w.WriteByte(' ')
+			line += len(value) + 1
+		}
+		word := fillerWords[g.rng.IntN(len(fillerWords))]
+		w.WriteString(word)
+		line += len(word) + 1
+		if line > 72 {
+			w.WriteByte('\n')
+			line = 0
+		} else {
+			w.WriteByte(' ')
+		}
+	}
+	w.WriteByte('\n')
+
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return planted, f.Close()
+}
+
+// writeLines writes values to path, one per line
+func writeLines(path string, values []string) error {
+	return os.WriteFile(path, []byte(strings.Join(values, "\n")+"\n"), 0o644)
+}
+
+// readLines reads the non-empty lines of path
+func readLines(path string) ([]string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var lines []string
+	for _, line := range strings.Split(string(content), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines, nil
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+const usage = `Usage: bench <command> [flags]
+
+Commands:
+  corpus   Generate a synthetic corpus with planted IOCs for the ingestor
+  check    Drive POST /check at a target rate and report latency and Bloom
+           filter false positives
+
+Run "bench <command> -h" for the flags of a command.
+`
+
+func main() {
+	log.Logger = log.Output(zerolog.ConsoleWriter{Out: os.Stderr, TimeFormat: time.RFC3339})
+
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	var err error
+	switch os.Args[1] {
+	case "corpus":
+		err = runCorpus(os.Args[2:])
+	case "check":
+		err = runCheck(os.Args[2:])
+	case "-h", "-help", "--help", "help":
+		fmt.Fprint(os.Stdout, usage)
+		return
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s", os.Args[1], usage)
+		os.Exit(2)
+	}
+
+	if err != nil {
+		log.Error().Err(err).Msg("Benchmark failed")
+		os.Exit(1)
+	}
+}
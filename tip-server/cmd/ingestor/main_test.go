@@ -0,0 +1,47 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"tip-server/internal/config"
+)
+
+func TestRunSelfTest(t *testing.T) {
+	t.Setenv("CONFIG_FILE", filepath.Join(t.TempDir(), ".env"))
+	t.Setenv("API_KEY", "test-api-key")
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testdata := filepath.Join("..", "..", "internal", "ingestor", "testdata")
+	stale := filepath.Join(t.TempDir(), "expected.json")
+	if err := os.WriteFile(stale, []byte(`{"notes.txt": {}, "report.txt": {"domain": ["evil.example"]}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		expected string
+		status   int
+		output   string
+	}{
+		{"pass", filepath.Join(testdata, "expected.json"), 0, "Failed:  0"},
+		{"fail", stale, 1, "FAIL report.txt"},
+		{"missing expectations", filepath.Join(t.TempDir(), "none.json"), 1, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out strings.Builder
+			if got := runSelfTest(cfg, filepath.Join(testdata, "corpus"), tt.expected, &out); got != tt.status {
+				t.Errorf("status = %d, want %d\n%s", got, tt.status, out.String())
+			}
+			if !strings.Contains(out.String(), tt.output) {
+				t.Errorf("report lacks %q:\n%s", tt.output, out.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+
+	"tip-server/internal/api"
+	"tip-server/internal/config"
+	"tip-server/internal/db"
+	"tip-server/internal/ingestor"
+)
+
+// Roles a tip process can take on
+const (
+	roleAPI      = "api"      // Serve the REST API
+	roleIngestor = "ingestor" // Crawl DATA_PATH and extract IOCs
+	roleJobs     = "jobs"     // Run the maintenance jobs on the shared stores
+)
+
+func main() {
+	rolesFlag := flag.String("roles", "api,ingestor,jobs", "Comma-separated roles to run in this process: api, ingestor, jobs")
+	flag.Parse()
+
+	// Initialize logger
+	log.Logger = log.Output(zerolog.ConsoleWriter{Out: os.Stdout, TimeFormat: time.RFC3339})
+
+	roles, err := parseRoles(*rolesFlag)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Invalid --roles")
+	}
+
+	log.Info().Str("roles", *rolesFlag).Msg("Starting Threat Intelligence Platform")
+
+	// Load configuration
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to load configuration")
+	}
+
+	// Connect to storage once for all roles
+	clients, err := db.Connect(cfg)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to connect to storage")
+	}
+	defer clients.Close()
+
+	reloader := config.NewReloader(cfg)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// The API server also hosts the background jobs; without the api role
+	// it is created for them but never listens
+	var server *api.Server
+	if roles[roleAPI] || roles[roleJobs] {
+		server, err = api.NewServer(reloader, clients)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Failed to create server")
+		}
+		defer server.Close()
+
+		if cfg.Metrics.Enabled {
+			go server.StartMetricsServer()
+		}
+
+		var set api.JobSet
+		if roles[roleAPI] {
+			set |= api.ServingJobs
+		}
+		if roles[roleJobs] {
+			set |= api.MaintenanceJobs
+		}
+		server.StartJobs(ctx, set)
+	}
+
+	var ing *ingestor.Ingestor
+	if roles[roleIngestor] {
+		ing, err = ingestor.NewIngestor(reloader, clients)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Failed to create ingestor")
+		}
+		defer ing.Close()
+
+		if cfg.Metrics.Enabled {
+			metricsServer := ing.StartMetricsServer()
+			defer ingestor.StopMetricsServer(metricsServer)
+		}
+	}
+
+	// Reload selected settings on SIGHUP
+	reloader.WatchSignals(ctx)
+
+	// Handle graceful shutdown
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+
+	go func() {
+		<-sigChan
+		log.Info().Msg("Received shutdown signal, gracefully stopping...")
+		cancel()
+		if roles[roleAPI] {
+			if err := server.Shutdown(); err != nil {
+				log.Error().Err(err).Msg("Error during shutdown")
+			}
+		}
+
+		<-sigChan
+		log.Warn().Msg("Received second shutdown signal, exiting immediately")
+		os.Exit(1)
+	}()
+
+	// Run ingestion alongside the API. Without WATCH_INTERVAL each directory
+	// is crawled once, then only on-demand runs are served.
+	var ingestWg sync.WaitGroup
+	if ing != nil {
+		ingestWg.Add(1)
+		go func() {
+			defer ingestWg.Done()
+			if err := ing.Watch(ctx); err != nil {
+				log.Error().Err(err).Msg("Ingestion failed")
+			}
+			ing.PrintStats()
+		}()
+	}
+
+	switch {
+	case roles[roleAPI]:
+		addr := fmt.Sprintf("%s:%d", cfg.API.Host, cfg.API.Port)
+		log.Info().Str("addr", addr).Msg("Starting API server")
+
+		if err := server.Listen(addr); err != nil {
+			log.Error().Err(err).Msg("Server failed")
+			cancel()
+		}
+	case roles[roleJobs]:
+		<-ctx.Done()
+	}
+
+	// Let the ingestor drain its queue before the connections close
+	ingestWg.Wait()
+}
+
+// parseRoles parses a comma-separated role list
+func parseRoles(spec string) (map[string]bool, error) {
+	roles := make(map[string]bool)
+	for _, role := range strings.Split(spec, ",") {
+		role = strings.ToLower(strings.TrimSpace(role))
+		switch role {
+		case "":
+			continue
+		case roleAPI, roleIngestor, roleJobs:
+			roles[role] = true
+		default:
+			return nil, fmt.Errorf("unknown role %q", role)
+		}
+	}
+	if len(roles) == 0 {
+		return nil, fmt.Errorf("no roles given")
+	}
+	return roles, nil
+}
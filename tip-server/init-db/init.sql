@@ -1,5 +1,7 @@
 -- Threat Intelligence Platform - Database Schema
 -- This file is auto-executed by ClickHouse on container startup
+-- Later schema changes are applied at startup by the migration runner
+-- (internal/db/migrations.go)
 
 CREATE DATABASE IF NOT EXISTS threat_intel;
 
@@ -0,0 +1,291 @@
+package api
+
+import (
+	"context"
+	"net"
+	"net/url"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/rs/zerolog/log"
+
+	"tip-server/internal/db"
+	"tip-server/internal/middleware"
+	"tip-server/internal/models"
+)
+
+// deleteIOCHandler deprecates an IOC across all source files (admin only).
+// The value is taken from the rest of the path so URLs can be passed
+// percent-encoded: DELETE /ioc/http%3A%2F%2Fevil.example%2Fpayload
+func (s *Server) deleteIOCHandler(c *fiber.Ctx) error {
+	value, err := url.PathUnescape(c.Params("*"))
+	if err != nil || value == "" {
+		return middleware.Problem(c, fiber.StatusBadRequest, models.ErrCodeInvalidIOC,
+			"Missing or malformed IOC value", "")
+	}
+
+	if err := middleware.ValidateIndicator(value, s.cfg.API.MaxIOCLength); err != nil {
+		return middleware.Problem(c, fiber.StatusBadRequest, models.ErrCodeInvalidIOC,
+			"Invalid IOC", err.Error())
+	}
+	// Fiber reuses the request buffer the parameter points into
+	value = s.redactor.Value(strings.Clone(value))
+
+	ctx := context.Background()
+
+	affected, err := s.ch.DeprecateIOC(ctx, value)
+	if err != nil {
+		log.Error().Err(err).Str("ioc", value).Msg("Failed to deprecate IOC")
+		return middleware.Problem(c, fiber.StatusInternalServerError, models.ErrCodeStorageUnavailable,
+			"Failed to deprecate IOC", "")
+	}
+
+	if affected == 0 {
+		return middleware.Problem(c, fiber.StatusNotFound, models.ErrCodeNotFound,
+			"IOC not found", value)
+	}
+
+	// The Bloom filter cannot delete; queue the value for the next rebuild
+	if err := s.redis.ScheduleBloomRemoval(ctx, value); err != nil {
+		log.Warn().Err(err).Str("ioc", value).Msg("Failed to schedule Bloom filter maintenance")
+	}
+	if err := s.redis.InvalidateCachedIOCs(ctx, value); err != nil {
+		log.Warn().Err(err).Str("ioc", value).Msg("Failed to invalidate lookup cache")
+	}
+
+	actor, _ := c.Locals("api_key_hash").(string)
+	entry := models.AuditEntry{
+		Timestamp:    time.Now().UTC(),
+		Action:       models.AuditActionDeprecate,
+		IOCValue:     value,
+		Actor:        actor,
+		Reason:       strings.Clone(c.Query("reason")),
+		ClientIP:     strings.Clone(c.IP()),
+		RowsAffected: affected,
+	}
+	if err := s.ch.InsertAuditEntry(ctx, entry); err != nil {
+		log.Error().Err(err).Str("ioc", value).Msg("Failed to write audit entry")
+	}
+
+	log.Info().
+		Str("ioc", value).
+		Uint64("rows", affected).
+		Str("actor", actor).
+		Msg("IOC deprecated")
+
+	return c.JSON(fiber.Map{
+		"ioc":           value,
+		"deprecated":    true,
+		"rows_affected": affected,
+		"timestamp":     entry.Timestamp.Format(time.RFC3339),
+	})
+}
+
+// listBlockedIPsHandler returns the current IP blocklist (admin only)
+func (s *Server) listBlockedIPsHandler(c *fiber.Ctx) error {
+	entries, err := s.redis.ListBlockedIPs(context.Background())
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to list blocked IPs")
+		return middleware.Problem(c, fiber.StatusInternalServerError, models.ErrCodeStorageUnavailable,
+			"Failed to list blocked IPs", "")
+	}
+
+	if entries == nil {
+		entries = []models.IPBlock{}
+	}
+
+	return c.JSON(fiber.Map{
+		"blocked": entries,
+		"total":   len(entries),
+	})
+}
+
+// blockIPHandler adds an IP to the blocklist (admin only)
+func (s *Server) blockIPHandler(c *fiber.Ctx) error {
+	var req models.BlockIPRequest
+	if err := middleware.ParseJSONStrict(c, &req); err != nil {
+		return middleware.Problem(c, fiber.StatusBadRequest, models.ErrCodeInvalidRequest,
+			"Invalid request body", err.Error())
+	}
+
+	ip := net.ParseIP(req.IP)
+	if ip == nil {
+		return middleware.Problem(c, fiber.StatusBadRequest, models.ErrCodeInvalidParameter,
+			"Invalid IP address", "")
+	}
+
+	var ttl time.Duration
+	if req.Duration != "" {
+		d, err := time.ParseDuration(req.Duration)
+		if err != nil || d <= 0 {
+			return middleware.Problem(c, fiber.StatusBadRequest, models.ErrCodeInvalidParameter,
+				"Invalid duration", "Use a positive Go duration such as 30m or 24h")
+		}
+		ttl = d
+	}
+
+	now := time.Now().UTC()
+	entry := models.IPBlock{
+		IP:        ip.String(),
+		Reason:    req.Reason,
+		Source:    models.IPBlockSourceManual,
+		BlockedAt: now,
+	}
+	if ttl > 0 {
+		expires := now.Add(ttl)
+		entry.ExpiresAt = &expires
+	}
+
+	if err := s.redis.BlockIP(context.Background(), entry, ttl); err != nil {
+		log.Error().Err(err).Str("ip", entry.IP).Msg("Failed to block IP")
+		return middleware.Problem(c, fiber.StatusInternalServerError, models.ErrCodeStorageUnavailable,
+			"Failed to block IP", "")
+	}
+
+	actor, _ := c.Locals("api_key_hash").(string)
+	log.Info().
+		Str("ip", entry.IP).
+		Str("actor", actor).
+		Dur("duration", ttl).
+		Msg("IP blocked")
+
+	return c.Status(fiber.StatusCreated).JSON(entry)
+}
+
+// unblockIPHandler removes an IP from the blocklist (admin only)
+func (s *Server) unblockIPHandler(c *fiber.Ctx) error {
+	ip := net.ParseIP(c.Params("ip"))
+	if ip == nil {
+		return middleware.Problem(c, fiber.StatusBadRequest, models.ErrCodeInvalidParameter,
+			"Invalid IP address", "")
+	}
+
+	removed, err := s.redis.UnblockIP(context.Background(), ip.String())
+	if err != nil {
+		log.Error().Err(err).Str("ip", ip.String()).Msg("Failed to unblock IP")
+		return middleware.Problem(c, fiber.StatusInternalServerError, models.ErrCodeStorageUnavailable,
+			"Failed to unblock IP", "")
+	}
+
+	if !removed {
+		return middleware.Problem(c, fiber.StatusNotFound, models.ErrCodeNotFound,
+			"IP not blocked", ip.String())
+	}
+
+	actor, _ := c.Locals("api_key_hash").(string)
+	log.Info().Str("ip", ip.String()).Str("actor", actor).Msg("IP unblocked")
+
+	return c.JSON(fiber.Map{
+		"ip":        ip.String(),
+		"unblocked": true,
+	})
+}
+
+// exportHandler starts a Parquet snapshot of the IOC store in the
+// background (admin only). The snapshot lands under exports/ioc_store/ in
+// the MinIO bucket.
+func (s *Server) exportHandler(c *fiber.Ctx) error {
+	if s.export.Running() {
+		return middleware.Problem(c, fiber.StatusConflict, models.ErrCodeConflict,
+			"Export already running", "Wait for the current snapshot to finish")
+	}
+
+	actor, _ := c.Locals("api_key_hash").(string)
+	entry := models.AuditEntry{
+		Timestamp: time.Now().UTC(),
+		Action:    models.AuditActionExport,
+		Actor:     actor,
+		Reason:    strings.Clone(c.Query("reason")),
+		ClientIP:  strings.Clone(c.IP()),
+	}
+
+	go func() {
+		ctx := context.Background()
+		if err := s.ch.InsertAuditEntry(ctx, entry); err != nil {
+			log.Error().Err(err).Msg("Failed to write audit entry")
+		}
+
+		key, err := s.export.Run(ctx)
+		if err != nil {
+			log.Error().Err(err).Str("actor", actor).Msg("IOC export failed")
+			return
+		}
+		log.Info().Str("object", key).Str("actor", actor).Msg("IOC export requested by admin completed")
+	}()
+
+	return c.Status(fiber.StatusAccepted).JSON(fiber.Map{
+		"status":    "started",
+		"prefix":    db.ExportPrefix,
+		"timestamp": entry.Timestamp.Format(time.RFC3339),
+	})
+}
+
+// ingestRunHandler asks watch-mode ingestors, over Redis pub/sub, to crawl
+// a directory or every directory of a feed now (admin only), for example
+// after a manual intel drop. Paths are resolved by the ingestor against its
+// own DATA_PATH and policies.
+func (s *Server) ingestRunHandler(c *fiber.Ctx) error {
+	var req models.IngestRunRequest
+	if err := middleware.ParseJSONStrict(c, &req); err != nil {
+		return middleware.Problem(c, fiber.StatusBadRequest, models.ErrCodeInvalidRequest,
+			"Invalid request body", err.Error())
+	}
+
+	req.Path, req.Feed = strings.TrimSpace(req.Path), strings.TrimSpace(req.Feed)
+	if (req.Path == "") == (req.Feed == "") {
+		return middleware.Problem(c, fiber.StatusBadRequest, models.ErrCodeInvalidRequest,
+			"Invalid run", "Set exactly one of path and feed")
+	}
+	if slices.Contains(strings.Split(filepath.ToSlash(req.Path), "/"), "..") {
+		return middleware.Problem(c, fiber.StatusBadRequest, models.ErrCodeInvalidRequest,
+			"Invalid path", "path must not contain .. segments")
+	}
+
+	run := models.IngestRun{
+		ID:          newRunID(),
+		Path:        req.Path,
+		Feed:        req.Feed,
+		RequestedAt: time.Now().UTC(),
+	}
+
+	ctx := context.Background()
+	ingestors, err := s.redis.RequestIngestRun(ctx, run)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to publish ingest run")
+		return middleware.Problem(c, fiber.StatusInternalServerError, models.ErrCodeStorageUnavailable,
+			"Failed to request run", "")
+	}
+	if ingestors == 0 {
+		return middleware.Problem(c, fiber.StatusServiceUnavailable, models.ErrCodeFeatureUnavailable,
+			"No ingestor listening", "On-demand runs are served by an ingestor in watch mode (WATCH_INTERVAL or INGEST_POLICY_FILE set)")
+	}
+
+	actor, _ := c.Locals("api_key_hash").(string)
+	entry := models.AuditEntry{
+		Timestamp: run.RequestedAt,
+		Action:    models.AuditActionIngestRun,
+		IOCValue:  req.Path + req.Feed,
+		Actor:     actor,
+		Reason:    c.Query("reason"),
+		ClientIP:  c.IP(),
+	}
+	if err := s.ch.InsertAuditEntry(ctx, entry); err != nil {
+		log.Error().Err(err).Msg("Failed to write audit entry")
+	}
+
+	log.Info().
+		Str("run", run.ID).
+		Str("path", run.Path).
+		Str("feed", run.Feed).
+		Str("actor", actor).
+		Int64("ingestors", ingestors).
+		Msg("Ingestion run requested")
+
+	return c.Status(fiber.StatusAccepted).JSON(fiber.Map{
+		"run":       run,
+		"ingestors": ingestors,
+	})
+}
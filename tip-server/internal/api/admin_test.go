@@ -0,0 +1,59 @@
+package api
+
+import (
+	"context"
+	"net/url"
+	"testing"
+
+	"tip-server/internal/models"
+)
+
+func TestDeleteIOC(t *testing.T) {
+	s, clients := newTestServer(t)
+
+	// Cache the match the deletion must drop
+	check(t, s, "/check", "203.0.113.77")
+	waitCached(t, clients, "203.0.113.77")
+
+	tests := []struct {
+		name   string
+		apiKey string
+		value  string
+		status int
+		found  bool // Still matched by /check afterwards
+	}{
+		{"not admin", testAPIKey, "203.0.113.77", 403, true},
+		{"delete", testAdminKey, "203.0.113.77", 200, false},
+		{"already deleted", testAdminKey, "203.0.113.77", 404, false},
+		{"unknown", testAdminKey, "never-seen.example", 404, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := "/ioc/" + url.PathEscape(tt.value) + "?reason=false+positive"
+			if status := request(t, s, "DELETE", path, tt.apiKey, nil, nil); status != tt.status {
+				t.Fatalf("status = %d, want %d", status, tt.status)
+			}
+			if r := check(t, s, "/check", tt.value).Results[0]; r.Found != tt.found {
+				t.Errorf("found after %s = %v, want %v", tt.name, r.Found, tt.found)
+			}
+		})
+	}
+
+	// The Bloom filter still holds the value until the next rebuild
+	pending, err := clients.Redis.PendingBloomRemovals(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pending != 1 {
+		t.Errorf("pending Bloom removals = %d, want 1", pending)
+	}
+
+	// Only the deprecation itself is audited
+	entries := auditEntries(t, clients, models.AuditActionDeprecate)
+	if len(entries) != 1 {
+		t.Fatalf("%d deprecation audit entries, want 1", len(entries))
+	}
+	if e := entries[0]; e.IOCValue != "203.0.113.77" || e.Reason != "false positive" || e.RowsAffected != 1 || e.Actor == "" {
+		t.Errorf("audit entry = %+v", e)
+	}
+}
@@ -0,0 +1,163 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/rs/zerolog/log"
+
+	"tip-server/internal/middleware"
+	"tip-server/internal/models"
+)
+
+const (
+	// bulkMaxListLength bounds each tag and source list of a bulk update
+	bulkMaxListLength = 1000
+
+	// bulkMaxLabelLength bounds a tag, source file ID or malware family
+	bulkMaxLabelLength = 256
+
+	// bulkMaxInvalidations bounds the values dropped from the lookup cache
+	// one by one; larger updates flush the whole cache
+	bulkMaxInvalidations = 10000
+)
+
+// bulkUpdateHandler applies tag, confidence or malware family curation to
+// every active IOC a filter selects, as one ClickHouse mutation (admin only)
+func (s *Server) bulkUpdateHandler(c *fiber.Ctx) error {
+	var req models.BulkUpdateRequest
+	if err := middleware.ParseJSONStrict(c, &req); err != nil {
+		return middleware.Problem(c, fiber.StatusBadRequest, models.ErrCodeInvalidRequest,
+			"Invalid request body", err.Error())
+	}
+	if err := validateBulkUpdate(&req); err != nil {
+		return middleware.Problem(c, fiber.StatusBadRequest, models.ErrCodeInvalidRequest,
+			"Invalid bulk update", err.Error())
+	}
+
+	ctx := context.Background()
+	resp := models.BulkUpdateResponse{DryRun: req.DryRun, Timestamp: time.Now().UTC()}
+
+	var err error
+	if req.DryRun {
+		resp.Matched, err = s.ch.CountIOCs(ctx, req.Filter)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to count IOCs for bulk update")
+			return middleware.Problem(c, fiber.StatusInternalServerError, models.ErrCodeStorageUnavailable,
+				"Failed to apply bulk update", "")
+		}
+		return c.JSON(resp)
+	}
+
+	// Listed before the update, which may change what the filter selects
+	values, listErr := s.ch.ListIOCFilterValues(ctx, req.Filter, bulkMaxInvalidations+1)
+	resp.Matched, err = s.ch.BulkUpdateIOCs(ctx, req.Filter, req.IOCUpdate)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to apply bulk IOC update")
+		return middleware.Problem(c, fiber.StatusInternalServerError, models.ErrCodeStorageUnavailable,
+			"Failed to apply bulk update", "")
+	}
+	if resp.Matched > 0 {
+		s.invalidateBulkUpdated(ctx, values, listErr)
+	}
+
+	filter, _ := json.Marshal(req.Filter)
+	actor, _ := c.Locals("api_key_hash").(string)
+	entry := models.AuditEntry{
+		Timestamp:    resp.Timestamp,
+		Action:       models.AuditActionBulkUpdate,
+		IOCValue:     string(filter),
+		Actor:        actor,
+		Reason:       req.Reason,
+		ClientIP:     c.IP(),
+		RowsAffected: resp.Matched,
+	}
+	if err := s.ch.InsertAuditEntry(ctx, entry); err != nil {
+		log.Error().Err(err).Msg("Failed to write audit entry")
+	}
+
+	log.Info().
+		RawJSON("filter", filter).
+		Uint64("rows", resp.Matched).
+		Str("actor", actor).
+		Msg("IOCs bulk updated")
+
+	return c.JSON(resp)
+}
+
+// invalidateBulkUpdated drops the updated values from the lookup cache, so
+// /check does not serve their old tags, confidence and family until
+// LOOKUP_CACHE_TTL. The whole cache is flushed when the values could not be
+// listed or are too many to drop one by one.
+func (s *Server) invalidateBulkUpdated(ctx context.Context, values []string, listErr error) {
+	var err error
+	switch {
+	case listErr != nil:
+		log.Warn().Err(listErr).Msg("Failed to list bulk updated IOCs, flushing lookup cache")
+		err = s.redis.FlushCachedIOCs(ctx)
+	case len(values) > bulkMaxInvalidations:
+		err = s.redis.FlushCachedIOCs(ctx)
+	case len(values) > 0:
+		err = s.redis.InvalidateCachedIOCs(ctx, values...)
+	}
+	if err != nil {
+		log.Warn().Err(err).Msg("Failed to invalidate lookup cache for bulk updated IOCs")
+	}
+}
+
+// validateBulkUpdate checks a bulk update and trims its labels. An empty
+// filter is refused so a mistyped request cannot rewrite the whole corpus.
+func validateBulkUpdate(req *models.BulkUpdateRequest) error {
+	f, u := &req.Filter, &req.IOCUpdate
+
+	if f.IsEmpty() {
+		return fmt.Errorf("filter must select by tags, source_file_ids, type, since or until")
+	}
+	if !req.DryRun && u.IsEmpty() {
+		return fmt.Errorf("set at least one of add_tags, remove_tags, confidence and malware_family")
+	}
+	if f.Type != "" && !slices.Contains(models.AllIOCTypes(), f.Type) {
+		return fmt.Errorf("filter.type %q is not a supported IOC type", f.Type)
+	}
+	if f.Since != nil && f.Until != nil && !f.Since.Before(*f.Until) {
+		return fmt.Errorf("filter.since must be before filter.until")
+	}
+	if u.Confidence != nil && *u.Confidence > 100 {
+		return fmt.Errorf("confidence must be between 0 and 100")
+	}
+
+	lists := []struct {
+		name   string
+		values *[]string
+	}{
+		{"filter.tags", &f.Tags},
+		{"filter.source_file_ids", &f.SourceFileIDs},
+		{"add_tags", &u.AddTags},
+		{"remove_tags", &u.RemoveTags},
+	}
+	for _, list := range lists {
+		if len(*list.values) > bulkMaxListLength {
+			return fmt.Errorf("%s has more than %d entries", list.name, bulkMaxListLength)
+		}
+		for i, v := range *list.values {
+			v = strings.TrimSpace(v)
+			if err := middleware.ValidateIndicator(v, bulkMaxLabelLength); err != nil {
+				return fmt.Errorf("%s[%d]: %w", list.name, i, err)
+			}
+			(*list.values)[i] = v
+		}
+	}
+
+	if u.MalwareFamily != "" {
+		u.MalwareFamily = strings.TrimSpace(u.MalwareFamily)
+		if err := middleware.ValidateIndicator(u.MalwareFamily, bulkMaxLabelLength); err != nil {
+			return fmt.Errorf("malware_family: %w", err)
+		}
+	}
+	return nil
+}
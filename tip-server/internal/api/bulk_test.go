@@ -0,0 +1,126 @@
+package api
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"tip-server/internal/fixtures"
+	"tip-server/internal/models"
+)
+
+func TestBulkUpdate(t *testing.T) {
+	s, clients := newTestServer(t)
+
+	// Cache the match the update will change
+	check(t, s, "/check", "update-checker-cdn.net")
+	waitCached(t, clients, "update-checker-cdn.net")
+
+	confidence := uint8(40)
+	domains := models.IOCFilter{Tags: []string{"fixture"}, Type: models.IOCTypeDomain}
+	update := models.IOCUpdate{AddTags: []string{"curated"}, Confidence: &confidence}
+
+	tests := []struct {
+		name    string
+		apiKey  string
+		req     models.BulkUpdateRequest
+		status  int
+		matched uint64
+	}{
+		{"not admin", testAPIKey, models.BulkUpdateRequest{Filter: domains, IOCUpdate: update}, 403, 0},
+		{"empty filter", testAdminKey, models.BulkUpdateRequest{IOCUpdate: update}, 400, 0},
+		{"empty update", testAdminKey, models.BulkUpdateRequest{Filter: domains}, 400, 0},
+		{"unknown type", testAdminKey, models.BulkUpdateRequest{Filter: models.IOCFilter{Type: "hostname"}, IOCUpdate: update}, 400, 0},
+		{"dry run", testAdminKey, models.BulkUpdateRequest{Filter: domains, IOCUpdate: update, DryRun: true}, 200, 1},
+		{"update", testAdminKey, models.BulkUpdateRequest{Filter: domains, IOCUpdate: update, Reason: "triage"}, 200, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var resp models.BulkUpdateResponse
+			if status := request(t, s, "POST", "/iocs/bulk-update", tt.apiKey, tt.req, &resp); status != tt.status {
+				t.Fatalf("status = %d, want %d", status, tt.status)
+			}
+			if resp.Matched != tt.matched || resp.DryRun != tt.req.DryRun {
+				t.Errorf("response = %+v, want %d matched", resp, tt.matched)
+			}
+
+			// Only the update itself changes what /check serves
+			r := check(t, s, "/v1/check", "update-checker-cdn.net").Results[0]
+			updated := tt.name == "update"
+			if got := r.Confidence == confidence && slices.Contains(r.Tags, "curated"); got != updated {
+				t.Errorf("after %s: confidence = %d, tags = %v", tt.name, r.Confidence, r.Tags)
+			}
+		})
+	}
+
+	// Other types were left alone
+	if r := check(t, s, "/check", "203.0.113.77").Results[0]; r.Confidence != 70 {
+		t.Errorf("ipv4 confidence = %d, want 70", r.Confidence)
+	}
+
+	// Dry runs are not audited
+	entries := auditEntries(t, clients, models.AuditActionBulkUpdate)
+	if len(entries) != 1 {
+		t.Fatalf("%d bulk update audit entries, want 1", len(entries))
+	}
+	if e := entries[0]; e.Reason != "triage" || e.RowsAffected != 1 || !strings.Contains(e.IOCValue, `"fixture"`) {
+		t.Errorf("audit entry = %+v, want the filter, reason and row count", e)
+	}
+}
+
+func TestBulkUpdateFilters(t *testing.T) {
+	past := time.Now().Add(-24 * time.Hour)
+
+	tests := []struct {
+		name    string
+		req     models.BulkUpdateRequest
+		matched uint64
+	}{
+		{
+			name: "source file",
+			req: models.BulkUpdateRequest{
+				Filter:    models.IOCFilter{SourceFileIDs: []string{fixtures.SourceFileID}},
+				IOCUpdate: models.IOCUpdate{RemoveTags: []string{"fixture"}, AddTags: []string{"retagged"}, MalwareFamily: "Curated"},
+			},
+			matched: uint64(len(fixtures.IOCs(time.Now()))),
+		},
+		{
+			name: "time range",
+			req: models.BulkUpdateRequest{
+				Filter:    models.IOCFilter{Since: &past, Type: models.IOCTypeIPv4},
+				IOCUpdate: models.IOCUpdate{RemoveTags: []string{"fixture"}, AddTags: []string{"retagged"}, MalwareFamily: "Curated"},
+			},
+			matched: 1,
+		},
+		{
+			name: "nothing before",
+			req: models.BulkUpdateRequest{
+				Filter:    models.IOCFilter{Until: &past},
+				IOCUpdate: models.IOCUpdate{MalwareFamily: "Curated"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, _ := newTestServer(t)
+
+			var resp models.BulkUpdateResponse
+			if status := request(t, s, "POST", "/iocs/bulk-update", testAdminKey, tt.req, &resp); status != 200 {
+				t.Fatalf("status = %d, want 200", status)
+			}
+			if resp.Matched != tt.matched {
+				t.Errorf("matched = %d, want %d", resp.Matched, tt.matched)
+			}
+
+			r := check(t, s, "/v1/check", "203.0.113.77").Results[0]
+			updated := tt.matched > 0
+			if got := r.MalwareFamily == "Curated"; got != updated {
+				t.Errorf("family = %q, want updated = %v", r.MalwareFamily, updated)
+			}
+			if updated && !slices.Equal(r.Tags, []string{"retagged"}) {
+				t.Errorf("tags = %v, want [retagged]", r.Tags)
+			}
+		})
+	}
+}
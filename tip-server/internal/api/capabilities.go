@@ -0,0 +1,99 @@
+package api
+
+import (
+	"github.com/gofiber/fiber/v2"
+
+	"tip-server/internal/models"
+)
+
+// bloomTypeRedisBloom is the Bloom filter implementation in front of the IOC
+// store: a scalable RedisBloom filter (BF.RESERVE)
+const bloomTypeRedisBloom = "redisbloom"
+
+// capabilitiesHandler reports which optional subsystems this deployment has
+// enabled, so client SDKs and UIs can hide features instead of probing
+// endpoints for 503s
+func (s *Server) capabilitiesHandler(c *fiber.Ctx) error {
+	similarity := s.index != nil
+
+	providers := make([]string, 0, 3)
+	if s.cfg.Enrichment.VirusTotalAPIKey != "" {
+		providers = append(providers, "virustotal")
+	}
+	if s.cfg.Enrichment.AbuseIPDBAPIKey != "" {
+		providers = append(providers, "abuseipdb")
+	}
+	if s.domainAge != nil {
+		providers = append(providers, "whois")
+	}
+
+	return c.JSON(models.CapabilitiesResponse{
+		Features: map[string]bool{
+			"qdrant":              s.qdrant != nil,
+			"similarity_search":   similarity,
+			"clustering":          similarity && s.cfg.Cluster.Interval > 0,
+			"yara":                false, // No YARA scanning in this build
+			"clamav":              s.cfg.ClamAV.Address != "",
+			"enrichment":          s.enricher != nil,
+			"lookup_cache":        s.cfg.Redis.LookupCacheTTL > 0,
+			"negative_cache":      s.cfg.Redis.NegativeCacheTTL > 0,
+			"event_bus":           s.cfg.EventBus.Type != "",
+			"scheduled_export":    s.cfg.Export.Interval > 0,
+			"replica_sync":        s.cfg.Sync.PrimaryURL != "",
+			"self_test":           s.cfg.API.SelfTestInterval > 0,
+			"ip_range_matching":   s.cfg.API.RangeRefreshInterval > 0,
+			"popularity_ranking":  s.cfg.Extractor.PopularityListFile != "",
+			"admin_api":           s.cfg.API.AdminAPIKey != "",
+			"review_queue":        s.cfg.Worker.ReviewConfidence > 0,
+			"email_redaction":     s.redactor.Enabled(),
+			"feed_signing":        s.signer.Enabled(),
+			"subscriptions":       s.cfg.Subscriptions.MaxPerKey > 0,
+			"volume_alerts":       s.cfg.Volume.Interval > 0,
+			"extraction_sampling": s.cfg.Extractor.SampleRate > 0,
+		},
+		EnrichmentProviders: providers,
+		Bloom: models.BloomCapability{
+			Type:      bloomTypeRedisBloom,
+			ErrorRate: s.cfg.Redis.BloomFilterErrorRate,
+			Capacity:  s.cfg.Redis.BloomFilterCapacity,
+		},
+		Endpoints: map[string]bool{
+			"POST /v1/check":              true,
+			"GET /search":                 true,
+			"POST /search/regex":          true,
+			"POST /search/fuzzy":          similarity,
+			"GET /clusters":               similarity && s.cfg.Cluster.Interval > 0,
+			"POST /search/typosquat":      true,
+			"GET /stream/ingestion":       true,
+			"GET /stream/matches":         true,
+			"GET /sync/iocs":              s.cfg.API.AdminAPIKey != "",
+			"GET /signing/key":            s.signer.Enabled(),
+			"POST /subscriptions":         s.cfg.Subscriptions.MaxPerKey > 0,
+			"GET /subscriptions":          s.cfg.Subscriptions.MaxPerKey > 0,
+			"DELETE /subscriptions/:id":   s.cfg.Subscriptions.MaxPerKey > 0,
+			"POST /admin/export":          s.cfg.API.AdminAPIKey != "",
+			"POST /admin/import":          s.cfg.API.AdminAPIKey != "",
+			"POST /admin/ingest/run":      s.cfg.API.AdminAPIKey != "",
+			"POST /admin/files/reprocess": s.cfg.API.AdminAPIKey != "",
+			"GET /admin/files/duplicates": s.cfg.API.AdminAPIKey != "",
+			"GET /admin/consistency":      s.cfg.API.AdminAPIKey != "",
+			"GET /admin/volume":           s.cfg.API.AdminAPIKey != "",
+			"POST /admin/delete":          s.cfg.API.AdminAPIKey != "",
+			"POST /admin/reveal":          s.cfg.API.AdminAPIKey != "" && s.redactor.Enabled(),
+			"POST /iocs/bulk-update":      s.cfg.API.AdminAPIKey != "",
+			"GET /context/:file_id/iocs":  true,
+			"GET /files/:file_id/history": true,
+			"GET /review":                 true,
+			"POST /review/approve":        s.cfg.API.AdminAPIKey != "",
+			"POST /review/reject":         s.cfg.API.AdminAPIKey != "",
+			"GET /samples":                true,
+			"GET /samples/precision":      true,
+			"POST /samples/label":         s.cfg.API.AdminAPIKey != "",
+		},
+		Limits: models.RequestLimits{
+			MaxIOCsPerCheck: checkMaxIOCs,
+			MaxIOCLength:    s.cfg.API.MaxIOCLength,
+			MaxBodyBytes:    s.cfg.API.BodyLimit,
+		},
+	})
+}
@@ -0,0 +1,69 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/rs/zerolog/log"
+
+	"tip-server/internal/db"
+	"tip-server/internal/middleware"
+	"tip-server/internal/models"
+)
+
+// clustersHandler returns the latest clustering of indexed files. Query
+// parameters min_size and limit narrow the clusters returned.
+func (s *Server) clustersHandler(c *fiber.Ctx) error {
+	var params [2]int
+	for i, name := range []string{"min_size", "limit"} {
+		n, ok := queryNonNegativeInt(c, name)
+		if !ok {
+			return middleware.Problem(c, fiber.StatusBadRequest, models.ErrCodeInvalidParameter,
+				"Invalid query parameter", name+" must be a non-negative integer")
+		}
+		params[i] = n
+	}
+	minSize, limit := params[0], params[1]
+
+	var report models.ClusterReport
+	err := s.redis.GetJSON(context.Background(), db.ClusterReportKey, &report)
+	if errors.Is(err, redis.Nil) {
+		details := "No clustering run has completed yet"
+		if s.index == nil || s.cfg.Cluster.Interval <= 0 {
+			details = "Clustering is disabled; set QDRANT_ENABLED=true and CLUSTER_INTERVAL"
+		}
+		return middleware.Problem(c, fiber.StatusNotFound, models.ErrCodeNotFound,
+			"No clusters available", details)
+	}
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to load cluster report")
+		return middleware.Problem(c, fiber.StatusInternalServerError, models.ErrCodeStorageUnavailable,
+			"Failed to load clusters", "")
+	}
+
+	// Clusters are stored largest first
+	clusters := report.Clusters[:0]
+	for _, cl := range report.Clusters {
+		if cl.Size < minSize || (limit > 0 && len(clusters) == limit) {
+			break
+		}
+		clusters = append(clusters, cl)
+	}
+	report.Clusters = clusters
+
+	return c.JSON(report)
+}
+
+// queryNonNegativeInt parses an optional non-negative integer query
+// parameter, returning 0 when it is absent
+func queryNonNegativeInt(c *fiber.Ctx, name string) (int, bool) {
+	raw := c.Query(name)
+	if raw == "" {
+		return 0, true
+	}
+	n, err := strconv.Atoi(raw)
+	return n, err == nil && n >= 0
+}
@@ -0,0 +1,93 @@
+package api
+
+import (
+	"context"
+	"sync"
+
+	"tip-server/internal/models"
+)
+
+// lookupFlights coalesces concurrent ClickHouse lookups of the same value:
+// the first /check request to query a value owns its flight, and requests
+// that want the value meanwhile wait for that answer instead of issuing
+// their own query. The zero value is ready to use.
+type lookupFlights struct {
+	mu      sync.Mutex
+	flights map[string]*lookupFlight
+}
+
+// lookupFlight is one in-progress lookup of a value
+type lookupFlight struct {
+	done   chan struct{}
+	ioc    models.IOC
+	found  bool
+	failed bool // The owner's query failed
+}
+
+// claim splits values into those the caller now owns and must query, and
+// the flights of values another request is already querying
+func (f *lookupFlights) claim(values []string) ([]string, map[string]*lookupFlight) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.flights == nil {
+		f.flights = make(map[string]*lookupFlight)
+	}
+
+	owned := make([]string, 0, len(values))
+	var joined map[string]*lookupFlight
+	for _, v := range values {
+		if flight, ok := f.flights[v]; ok {
+			if joined == nil {
+				joined = make(map[string]*lookupFlight)
+			}
+			joined[v] = flight
+			continue
+		}
+		f.flights[v] = &lookupFlight{done: make(chan struct{})}
+		owned = append(owned, v)
+	}
+	return owned, joined
+}
+
+// land publishes the outcome of the owned values' query and releases
+// their flights. Values in failed are reported as failed to waiters.
+func (f *lookupFlights) land(owned []string, found map[string]models.IOC, failed []string) {
+	failedSet := make(map[string]bool, len(failed))
+	for _, v := range failed {
+		failedSet[v] = true
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, v := range owned {
+		flight := f.flights[v]
+		delete(f.flights, v)
+		flight.ioc, flight.found = found[v]
+		flight.failed = failedSet[v]
+		close(flight.done)
+	}
+}
+
+// wait collects the outcomes of joined flights. Values whose owner failed,
+// or that did not land before ctx ended, are returned as failed.
+func (f *lookupFlights) wait(ctx context.Context, joined map[string]*lookupFlight) (map[string]models.IOC, []string) {
+	found := make(map[string]models.IOC)
+	var failed []string
+	for v, flight := range joined {
+		select {
+		case <-flight.done:
+		case <-ctx.Done():
+			failed = append(failed, v)
+			continue
+		}
+		switch {
+		case flight.failed:
+			failed = append(failed, v)
+		case flight.found:
+			found[v] = flight.ioc
+		}
+	}
+	return found, failed
+}
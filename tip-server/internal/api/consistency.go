@@ -0,0 +1,36 @@
+package api
+
+import (
+	"context"
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/rs/zerolog/log"
+
+	"tip-server/internal/db"
+	"tip-server/internal/middleware"
+	"tip-server/internal/models"
+)
+
+// consistencyHandler returns the latest cross-store consistency check
+// (admin only)
+func (s *Server) consistencyHandler(c *fiber.Ctx) error {
+	var report models.ConsistencyReport
+	err := s.redis.GetJSON(context.Background(), db.ConsistencyReportKey, &report)
+	if errors.Is(err, redis.Nil) {
+		details := "No consistency check has completed yet"
+		if s.cfg.Consistency.Interval <= 0 {
+			details = "Consistency checks are disabled; set CONSISTENCY_CHECK_INTERVAL"
+		}
+		return middleware.Problem(c, fiber.StatusNotFound, models.ErrCodeNotFound,
+			"No consistency report available", details)
+	}
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to load consistency report")
+		return middleware.Problem(c, fiber.StatusInternalServerError, models.ErrCodeStorageUnavailable,
+			"Failed to load consistency report", "")
+	}
+
+	return c.JSON(report)
+}
@@ -0,0 +1,204 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/rs/zerolog/log"
+
+	"tip-server/internal/middleware"
+	"tip-server/internal/models"
+)
+
+// deleteMaxTargets bounds the file IDs and IOC values of one delete request
+const deleteMaxTargets = 1000
+
+// deleteHandler deletes files and IOCs across the stores at once (admin
+// only). Files are tombstoned and IOC rows deprecated, or both removed from
+// ClickHouse with purge; either way the affected values leave the lookup
+// cache and are queued for the next Bloom filter rebuild, and the stored
+// content of deleted files is kept or removed per the object policy. Content
+// another registry entry still references is always kept.
+func (s *Server) deleteHandler(c *fiber.Ctx) error {
+	var req models.DeleteRequest
+	if err := middleware.ParseJSONStrict(c, &req); err != nil {
+		return middleware.Problem(c, fiber.StatusBadRequest, models.ErrCodeInvalidRequest,
+			"Invalid request body", err.Error())
+	}
+	if err := s.validateDelete(&req); err != nil {
+		return middleware.Problem(c, fiber.StatusBadRequest, models.ErrCodeInvalidRequest,
+			"Invalid delete request", err.Error())
+	}
+
+	ctx := context.Background()
+	now := time.Now().UTC()
+	resp := models.DeleteResponse{Files: []string{}, Purged: req.Purge, Timestamp: now}
+
+	// Soft deletes skip files already tombstoned; purges remove those too
+	var files []models.FileMetadata
+	for _, id := range req.FileIDs {
+		meta, err := s.ch.GetFileMetadata(ctx, id)
+		if err != nil || (meta.ScanStatus == models.ScanStatusDeleted && !req.Purge) {
+			resp.NotFound = append(resp.NotFound, id)
+			continue
+		}
+		files = append(files, *meta)
+		resp.Files = append(resp.Files, id)
+	}
+
+	values, err := s.ch.DeleteIOCs(ctx, req.IOCs, resp.Files, req.Purge)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to delete IOCs")
+		return middleware.Problem(c, fiber.StatusInternalServerError, models.ErrCodeStorageUnavailable,
+			"Failed to delete IOCs", "")
+	}
+	for _, value := range req.IOCs {
+		if !slices.Contains(values, value) {
+			resp.NotFound = append(resp.NotFound, value)
+		}
+	}
+	if len(files) == 0 && len(values) == 0 {
+		return middleware.Problem(c, fiber.StatusNotFound, models.ErrCodeNotFound,
+			"Nothing to delete", "None of the file IDs or IOC values has anything left to delete")
+	}
+	resp.IOCValues = len(values)
+
+	deleteObjects := req.Objects == models.DeletePolicyDelete
+	if req.Purge {
+		err = s.ch.PurgeFiles(ctx, resp.Files)
+	} else {
+		for _, meta := range files {
+			tombstone := meta
+			tombstone.ScanStatus = models.ScanStatusDeleted
+			tombstone.DeletedAt = &now
+			if deleteObjects {
+				tombstone.MinIOKey = ""
+			}
+			if err = s.ch.UpsertFileMetadata(ctx, &tombstone); err != nil {
+				break
+			}
+		}
+	}
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to delete files from the registry")
+		return middleware.Problem(c, fiber.StatusInternalServerError, models.ErrCodeStorageUnavailable,
+			"Failed to delete files", "")
+	}
+
+	// The Bloom filter cannot delete; the rebuild drops values with no
+	// active rows left
+	if len(values) > 0 {
+		if err := s.redis.ScheduleBloomRemoval(ctx, values...); err != nil {
+			log.Warn().Err(err).Msg("Failed to schedule Bloom filter maintenance")
+		}
+		if err := s.redis.InvalidateCachedIOCs(ctx, values...); err != nil {
+			log.Warn().Err(err).Msg("Failed to invalidate lookup cache for deleted IOCs")
+		}
+	}
+
+	resp.ObjectsDeleted, resp.ObjectsRetained = s.deleteObjects(ctx, files, resp.Files, deleteObjects)
+
+	targets, _ := json.Marshal(map[string][]string{"file_ids": resp.Files, "iocs": req.IOCs})
+	actor, _ := c.Locals("api_key_hash").(string)
+	action := models.AuditActionDelete
+	if req.Purge {
+		action = models.AuditActionPurge
+	}
+	entry := models.AuditEntry{
+		Timestamp:    now,
+		Action:       action,
+		IOCValue:     string(targets),
+		Actor:        actor,
+		Reason:       req.Reason,
+		ClientIP:     c.IP(),
+		RowsAffected: uint64(len(files) + len(values)),
+	}
+	if err := s.ch.InsertAuditEntry(ctx, entry); err != nil {
+		log.Error().Err(err).Msg("Failed to write audit entry")
+	}
+
+	log.Info().
+		Int("files", len(files)).
+		Int("ioc_values", len(values)).
+		Bool("purge", req.Purge).
+		Int("objects_deleted", len(resp.ObjectsDeleted)).
+		Str("actor", actor).
+		Msg("Deleted files and IOCs")
+
+	return c.JSON(resp)
+}
+
+// deleteObjects removes the stored content of deleted files when asked to
+// and no other registry entry references it, and returns the keys removed
+// and kept
+func (s *Server) deleteObjects(ctx context.Context, files []models.FileMetadata, fileIDs []string, remove bool) (deleted, retained []string) {
+	var keys []string
+	for _, meta := range files {
+		if meta.MinIOKey != "" && !slices.Contains(keys, meta.MinIOKey) {
+			keys = append(keys, meta.MinIOKey)
+		}
+	}
+	slices.Sort(keys)
+
+	for _, key := range keys {
+		if !remove {
+			retained = append(retained, key)
+			continue
+		}
+		refs, err := s.ch.CountMinIOKeyReferences(ctx, key, fileIDs)
+		if err != nil || refs > 0 {
+			if err != nil {
+				log.Warn().Err(err).Str("object", key).Msg("Failed to count object references, keeping it")
+			}
+			retained = append(retained, key)
+			continue
+		}
+		if err := s.minio.DeleteObject(ctx, key); err != nil {
+			log.Warn().Err(err).Str("object", key).Msg("Failed to delete stored content")
+			retained = append(retained, key)
+			continue
+		}
+		deleted = append(deleted, key)
+	}
+	return deleted, retained
+}
+
+// validateDelete checks a delete request, trims its file IDs and values and
+// applies the default object policy
+func (s *Server) validateDelete(req *models.DeleteRequest) error {
+	if len(req.FileIDs) == 0 && len(req.IOCs) == 0 {
+		return fmt.Errorf("set file_ids, iocs or both")
+	}
+	if len(req.FileIDs)+len(req.IOCs) > deleteMaxTargets {
+		return fmt.Errorf("file_ids and iocs have more than %d entries together", deleteMaxTargets)
+	}
+
+	switch req.Objects {
+	case "":
+		req.Objects = s.cfg.MinIO.DeletePolicy
+	case models.DeletePolicyRetain, models.DeletePolicyDelete:
+	default:
+		return fmt.Errorf("objects must be retain or delete")
+	}
+
+	for i, id := range req.FileIDs {
+		id = strings.TrimSpace(id)
+		if err := middleware.ValidateIndicator(id, fileIDMaxLength); err != nil {
+			return fmt.Errorf("file_ids[%d]: %w", i, err)
+		}
+		req.FileIDs[i] = id
+	}
+	for i, value := range req.IOCs {
+		value = strings.TrimSpace(value)
+		if err := middleware.ValidateIndicator(value, s.cfg.API.MaxIOCLength); err != nil {
+			return fmt.Errorf("iocs[%d]: %w", i, err)
+		}
+		req.IOCs[i] = s.redactor.Value(value)
+	}
+	return nil
+}
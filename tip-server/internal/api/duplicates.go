@@ -0,0 +1,48 @@
+package api
+
+import (
+	"context"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/rs/zerolog/log"
+
+	"tip-server/internal/middleware"
+	"tip-server/internal/models"
+)
+
+const (
+	// duplicatesDefaultLimit and duplicatesMaxLimit bound a page of the
+	// duplicate file report
+	duplicatesDefaultLimit = 100
+	duplicatesMaxLimit     = 1000
+)
+
+// duplicatesHandler reports content registered under more than one active
+// file ID (admin only): copies under the path identity, or renamed files
+// whose old registration is still active. Query parameters: limit and
+// offset.
+func (s *Server) duplicatesHandler(c *fiber.Ctx) error {
+	limit, ok := queryNonNegativeInt(c, "limit")
+	if !ok || limit > duplicatesMaxLimit {
+		return middleware.Problem(c, fiber.StatusBadRequest, models.ErrCodeInvalidParameter,
+			"Invalid query parameter", "limit must be between 1 and 1000")
+	}
+	if limit == 0 {
+		limit = duplicatesDefaultLimit
+	}
+	offset, ok := queryNonNegativeInt(c, "offset")
+	if !ok {
+		return middleware.Problem(c, fiber.StatusBadRequest, models.ErrCodeInvalidParameter,
+			"Invalid query parameter", "offset must be a non-negative integer")
+	}
+
+	report, err := s.ch.ListDuplicateFiles(context.Background(), limit, offset)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to list duplicate files")
+		return middleware.Problem(c, fiber.StatusInternalServerError, models.ErrCodeStorageUnavailable,
+			"Failed to list duplicate files", "")
+	}
+	report.Limit, report.Offset = limit, offset
+
+	return c.JSON(report)
+}
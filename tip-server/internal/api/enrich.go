@@ -0,0 +1,102 @@
+package api
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"tip-server/internal/enrich"
+	"tip-server/internal/models"
+)
+
+// enrichResults attaches external reputation and domain age to matched
+// results, in request order, within the configured time budget
+func (s *Server) enrichResults(results []models.IOCResult, foundMap map[string]models.IOC) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.cfg.Enrichment.Timeout)
+	defer cancel()
+
+	if s.domainAge != nil {
+		s.attachDomainAge(ctx, results)
+	}
+	if s.enricher == nil {
+		return
+	}
+
+	matched := make([]models.IOC, 0, len(foundMap))
+	for _, r := range results {
+		if r.Found {
+			matched = append(matched, foundMap[r.IOC])
+		}
+	}
+
+	reputation := s.enricher.Enrich(ctx, matched)
+	for i := range results {
+		reps, ok := reputation[results[i].IOC]
+		if !ok {
+			continue
+		}
+		results[i].Reputation = reps
+		if s.cfg.Enrichment.AdjustConfidence {
+			results[i].Confidence = enrich.AdjustConfidence(results[i].Confidence, reps)
+		}
+	}
+}
+
+// attachDomainAge flags matched domains registered within NRD_DAYS and
+// raises their confidence by NRD_CONFIDENCE_BOOST
+func (s *Server) attachDomainAge(ctx context.Context, results []models.IOCResult) {
+	var wg sync.WaitGroup
+	lookups := 0
+
+	for i := range results {
+		if !results[i].Found || results[i].Type != models.IOCTypeDomain {
+			continue
+		}
+		if lookups++; lookups > s.cfg.Enrichment.MaxPerRequest {
+			break
+		}
+
+		wg.Add(1)
+		go func(r *models.IOCResult) {
+			defer wg.Done()
+
+			created := s.domainAge.Registered(ctx, r.IOC)
+			if created.IsZero() {
+				return
+			}
+			r.RegisteredAt = created.Format(time.RFC3339)
+			if s.domainAge.NewlyRegistered(created) {
+				r.NewlyRegistered = true
+				r.Confidence = uint8(min(int(r.Confidence)+s.cfg.Enrichment.NRDConfidenceBoost, 100))
+			}
+		}(&results[i])
+	}
+
+	wg.Wait()
+}
+
+// attachDNSStatus adds the latest resolution status to matched domains
+func (s *Server) attachDNSStatus(ctx context.Context, results []models.IOCResult) {
+	var domains []string
+	for _, r := range results {
+		if r.Found && r.Type == models.IOCTypeDomain {
+			domains = append(domains, r.IOC)
+		}
+	}
+	if len(domains) == 0 {
+		return
+	}
+
+	resolutions, err := s.ch.GetDomainResolutions(ctx, domains)
+	if err != nil {
+		log.Warn().Err(err).Msg("Failed to load domain resolutions")
+		return
+	}
+	for i := range results {
+		if res, ok := resolutions[results[i].IOC]; ok && results[i].Found {
+			results[i].DNSStatus = res.Status
+		}
+	}
+}
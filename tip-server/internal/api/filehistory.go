@@ -0,0 +1,70 @@
+package api
+
+import (
+	"context"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/rs/zerolog/log"
+
+	"tip-server/internal/middleware"
+	"tip-server/internal/models"
+)
+
+const (
+	// fileHistoryDefaultLimit and fileHistoryMaxLimit bound the versions
+	// returned for a file
+	fileHistoryDefaultLimit = 100
+	fileHistoryMaxLimit     = 1000
+)
+
+// fileHistoryHandler returns the current registry entry of a file and its
+// recorded versions, newest first, marking the versions that changed the
+// scan status (e.g. clean to infected). Query parameter: limit.
+func (s *Server) fileHistoryHandler(c *fiber.Ctx) error {
+	fileID := c.Params("file_id")
+	if fileID == "" {
+		return middleware.Problem(c, fiber.StatusBadRequest, models.ErrCodeInvalidParameter,
+			"Missing file_id", "")
+	}
+	limit, ok := queryNonNegativeInt(c, "limit")
+	if !ok || limit > fileHistoryMaxLimit {
+		return middleware.Problem(c, fiber.StatusBadRequest, models.ErrCodeInvalidParameter,
+			"Invalid query parameter", "limit must be between 1 and 1000")
+	}
+	if limit == 0 {
+		limit = fileHistoryDefaultLimit
+	}
+
+	ctx := context.Background()
+	meta, err := s.ch.GetFileMetadata(ctx, fileID)
+	if err != nil {
+		return middleware.Problem(c, fiber.StatusNotFound, models.ErrCodeNotFound,
+			"File not found", fileID)
+	}
+
+	// One version beyond the limit tells whether the oldest returned
+	// version changed the status
+	rows, err := s.ch.GetFileHistory(ctx, fileID, limit+1)
+	if err != nil {
+		log.Error().Err(err).Str("file_id", fileID).Msg("Failed to read file history")
+		return middleware.Problem(c, fiber.StatusInternalServerError, models.ErrCodeStorageUnavailable,
+			"Failed to read file history", "")
+	}
+
+	resp := models.FileHistory{
+		FileID:   fileID,
+		Current:  *meta,
+		Versions: make([]models.FileVersion, 0, min(len(rows), limit)),
+		More:     len(rows) > limit,
+		Limit:    limit,
+	}
+	for idx, row := range rows[:min(len(rows), limit)] {
+		version := models.FileVersion{FileMetadata: row}
+		if idx+1 < len(rows) && rows[idx+1].ScanStatus != row.ScanStatus {
+			version.PreviousStatus = rows[idx+1].ScanStatus
+		}
+		resp.Versions = append(resp.Versions, version)
+	}
+
+	return c.JSON(resp)
+}
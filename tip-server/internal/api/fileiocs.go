@@ -0,0 +1,130 @@
+package api
+
+import (
+	"context"
+	"io"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/rs/zerolog/log"
+
+	"tip-server/internal/db"
+	"tip-server/internal/extractor"
+	"tip-server/internal/middleware"
+	"tip-server/internal/models"
+)
+
+const (
+	// fileIOCsDefaultLimit and fileIOCsMaxLimit bound a page of a file's IOCs
+	fileIOCsDefaultLimit = 1000
+	fileIOCsMaxLimit     = 10000
+
+	// fileIOCsMaxContentBytes bounds the stored content searched for offsets;
+	// larger files are listed without them
+	fileIOCsMaxContentBytes = 32 << 20
+)
+
+// fileIOCsHandler lists every IOC extracted from one file, with the offset
+// and surrounding text of its first occurrence when the file's content is
+// stored. Query parameters: limit, offset and locate (default true).
+// Quarantined samples are never searched, so their content is not released.
+func (s *Server) fileIOCsHandler(c *fiber.Ctx) error {
+	fileID := c.Params("file_id")
+	if fileID == "" {
+		return middleware.Problem(c, fiber.StatusBadRequest, models.ErrCodeInvalidParameter,
+			"Missing file_id", "")
+	}
+
+	limit, ok := queryNonNegativeInt(c, "limit")
+	if !ok || limit > fileIOCsMaxLimit {
+		return middleware.Problem(c, fiber.StatusBadRequest, models.ErrCodeInvalidParameter,
+			"Invalid query parameter", "limit must be between 1 and 10000")
+	}
+	if limit == 0 {
+		limit = fileIOCsDefaultLimit
+	}
+	offset, ok := queryNonNegativeInt(c, "offset")
+	if !ok {
+		return middleware.Problem(c, fiber.StatusBadRequest, models.ErrCodeInvalidParameter,
+			"Invalid query parameter", "offset must be a non-negative integer")
+	}
+
+	ctx := context.Background()
+
+	meta, err := s.ch.GetFileMetadata(ctx, fileID)
+	if err != nil {
+		return middleware.Problem(c, fiber.StatusNotFound, models.ErrCodeNotFound,
+			"File not found", fileID)
+	}
+
+	iocs, total, err := s.ch.ListIOCsBySourceFile(ctx, fileID, limit, offset)
+	if err != nil {
+		log.Error().Err(err).Str("file_id", fileID).Msg("Failed to list file IOCs")
+		return middleware.Problem(c, fiber.StatusInternalServerError, models.ErrCodeStorageUnavailable,
+			"Failed to list file IOCs", "")
+	}
+
+	resp := models.FileIOCsResponse{
+		FileID:   fileID,
+		FilePath: meta.FilePath,
+		IOCs:     make([]models.FileIOC, len(iocs)),
+		Total:    total,
+		Limit:    limit,
+		Offset:   offset,
+	}
+	for i, ioc := range iocs {
+		resp.IOCs[i].IOC = ioc
+	}
+
+	if len(iocs) > 0 && c.QueryBool("locate", true) {
+		if content := s.locatableContent(ctx, meta); content != nil {
+			locateIOCs(content, resp.IOCs)
+			resp.Located = true
+		}
+	}
+
+	return c.JSON(resp)
+}
+
+// locatableContent returns a file's stored content for locating IOCs in, or
+// nil when it is quarantined, missing or too large
+func (s *Server) locatableContent(ctx context.Context, meta *models.FileMetadata) []byte {
+	key := meta.MinIOKey
+	if key == "" {
+		key = meta.FileID
+	}
+	if db.IsQuarantineKey(key) {
+		return nil
+	}
+
+	reader, _, size, err := s.minio.OpenObject(ctx, key)
+	if err != nil {
+		return nil
+	}
+	defer reader.Close()
+	if size > fileIOCsMaxContentBytes {
+		return nil
+	}
+
+	content, err := io.ReadAll(io.LimitReader(reader, fileIOCsMaxContentBytes+1))
+	if err != nil {
+		log.Warn().Err(err).Str("file_id", meta.FileID).Msg("Failed to read file content")
+		return nil
+	}
+	if len(content) > fileIOCsMaxContentBytes {
+		return nil
+	}
+	return content
+}
+
+// locateIOCs sets the offset and snippet of each IOC found in content, as
+// extractor.Locate finds them; values the file only holds defanged or in
+// another notation are left without one.
+func locateIOCs(content []byte, iocs []models.FileIOC) {
+	folded := extractor.FoldASCII(content)
+	for i := range iocs {
+		if at := extractor.Locate(folded, iocs[i].Value); at >= 0 {
+			iocs[i].Offset = &at
+			iocs[i].Snippet = extractor.Snippet(content, at, at+len(iocs[i].Value))
+		}
+	}
+}
@@ -0,0 +1,251 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/rs/zerolog/log"
+
+	"tip-server/internal/feeds"
+	"tip-server/internal/middleware"
+	"tip-server/internal/models"
+)
+
+const (
+	// importSourcePrefix marks IOCs merged in from another deployment's export
+	importSourcePrefix = "import:"
+
+	// defaultImportSource names imports that do not identify their origin
+	defaultImportSource = "tip"
+
+	// importMaxSourceLength bounds the source query parameter
+	importMaxSourceLength = 128
+)
+
+// errUnknownImportFormat is returned for bodies that are neither our own
+// export format nor a STIX 2 bundle
+var errUnknownImportFormat = errors.New("body is neither a GET /sync/iocs page nor a STIX 2 bundle")
+
+// importHandler merges IOCs exported from another deployment (admin only).
+// The body is a page of GET /sync/iocs or a STIX 2 bundle. Query parameters:
+// source, recorded as "import:<source>", policy, deciding what happens to
+// values the local corpus already knows, and type_mismatch, whether rows
+// whose value is another type than declared are rejected or corrected.
+func (s *Server) importHandler(c *fiber.Ctx) error {
+	policy := c.Query("policy", models.ImportPolicyKeepHigherConfidence)
+	switch policy {
+	case models.ImportPolicyKeepHigherConfidence, models.ImportPolicyTagUnion:
+	default:
+		return middleware.Problem(c, fiber.StatusBadRequest, models.ErrCodeInvalidParameter,
+			"Invalid merge policy", "policy must be keep_higher_confidence or tag_union")
+	}
+
+	mismatch := c.Query("type_mismatch")
+	if !validTypeMismatch(mismatch) {
+		return middleware.Problem(c, fiber.StatusBadRequest, models.ErrCodeInvalidParameter,
+			"Invalid type mismatch mode", "type_mismatch must be reject or correct")
+	}
+	correct := s.correctTypeMismatch(mismatch)
+
+	source := strings.TrimSpace(c.Query("source", defaultImportSource))
+	if source == "" || len(source) > importMaxSourceLength {
+		return middleware.Problem(c, fiber.StatusBadRequest, models.ErrCodeInvalidParameter,
+			"Invalid query parameter", "source must be 1 to 128 characters")
+	}
+	source = strings.Clone(source)
+
+	format, rows, err := parseImport(c.Body())
+	if err != nil {
+		return middleware.Problem(c, fiber.StatusBadRequest, models.ErrCodeInvalidRequest,
+			"Invalid import document", err.Error())
+	}
+
+	resp := models.ImportResponse{
+		Format:   format,
+		Policy:   policy,
+		Source:   source,
+		Received: len(rows),
+	}
+
+	// Normalise, then fold repeated values (one row per source file in our
+	// own format) into one imported row each
+	now := time.Now()
+	merged := make(map[string]models.IOC, len(rows))
+	order := make([]string, 0, len(rows))
+	var issues submissionIssues
+	for i, row := range rows {
+		ioc, issue := s.normalizeSubmittedIOC(models.SubmittedIOC{
+			Value:         row.Value,
+			Type:          row.Type,
+			MalwareFamily: row.MalwareFamily,
+			Confidence:    row.Confidence,
+			Tags:          row.Tags,
+		}, correct)
+		if issue != nil {
+			issue.Index = i
+			issues.add(*issue)
+			if !issue.Corrected {
+				continue
+			}
+		}
+
+		ioc.SourceFileID = importSourcePrefix + source
+		ioc.FirstSeen, ioc.LastSeen, ioc.ValidUntil = row.FirstSeen, row.LastSeen, row.ValidUntil
+		if ioc.FirstSeen.IsZero() {
+			ioc.FirstSeen = now
+		}
+		if ioc.LastSeen.IsZero() {
+			ioc.LastSeen = now
+		}
+
+		if prev, dup := merged[ioc.Value]; dup {
+			ioc = mergeImportedIOC(prev, ioc)
+		} else {
+			order = append(order, ioc.Value)
+		}
+		merged[ioc.Value] = ioc
+	}
+
+	resp.Rejected, resp.Corrected = issues.rejected, issues.corrected
+	resp.Issues, resp.IssuesOmitted = issues.list, issues.omitted
+
+	ctx := context.Background()
+
+	local, failed := s.queryIOCChunks(ctx, order)
+	if len(failed) > 0 {
+		return middleware.Problem(c, fiber.StatusServiceUnavailable, models.ErrCodeStorageUnavailable,
+			"Failed to query IOC store", "Existing values are needed to apply the merge policy")
+	}
+
+	iocs := make([]models.IOC, 0, len(order))
+	for _, value := range order {
+		ioc := merged[value]
+		if existing, known := local[value]; known {
+			switch policy {
+			case models.ImportPolicyKeepHigherConfidence:
+				if existing.Confidence >= ioc.Confidence {
+					resp.Skipped++
+					continue
+				}
+			case models.ImportPolicyTagUnion:
+				ioc = mergeImportedIOC(ioc, existing)
+			}
+		}
+		iocs = append(iocs, ioc)
+	}
+
+	if err := s.storeIOCs(ctx, iocs); err != nil {
+		log.Error().Err(err).Str("source", source).Msg("Failed to store imported IOCs")
+		return middleware.Problem(c, fiber.StatusInternalServerError, models.ErrCodeStorageUnavailable,
+			"Failed to store imported IOCs", "")
+	}
+	resp.Imported = len(iocs)
+
+	values := make([]string, len(iocs))
+	for i, ioc := range iocs {
+		values[i] = ioc.Value
+	}
+	if err := s.redis.InvalidateCachedIOCs(ctx, values...); err != nil {
+		log.Warn().Err(err).Msg("Failed to invalidate lookup cache for imported IOCs")
+	}
+
+	actor, _ := c.Locals("api_key_hash").(string)
+	entry := models.AuditEntry{
+		Timestamp:    time.Now().UTC(),
+		Action:       models.AuditActionImport,
+		IOCValue:     importSourcePrefix + source,
+		Actor:        actor,
+		Reason:       c.Query("reason"),
+		ClientIP:     c.IP(),
+		RowsAffected: uint64(resp.Imported),
+	}
+	if err := s.ch.InsertAuditEntry(ctx, entry); err != nil {
+		log.Error().Err(err).Str("source", source).Msg("Failed to write audit entry")
+	}
+
+	log.Info().
+		Str("source", source).
+		Str("format", format).
+		Str("policy", policy).
+		Int("imported", resp.Imported).
+		Int("skipped", resp.Skipped).
+		Int("rejected", resp.Rejected).
+		Int("corrected", resp.Corrected).
+		Str("actor", actor).
+		Msg("IOCs imported")
+
+	return c.JSON(resp)
+}
+
+// parseImport decodes an import body into IOC rows, keeping the timestamps
+// and validity window the source recorded
+func parseImport(body []byte) (string, []models.IOC, error) {
+	if feeds.Detect(body) == feeds.FormatSTIX2 {
+		indicators, _, err := feeds.Parse(body)
+		if err != nil {
+			return "", nil, err
+		}
+
+		rows := make([]models.IOC, len(indicators))
+		for i, ind := range indicators {
+			rows[i] = models.IOC{
+				Value:         ind.Value,
+				Type:          ind.Type,
+				MalwareFamily: ind.MalwareFamily,
+				Confidence:    ind.Confidence,
+				Tags:          ind.Tags,
+				FirstSeen:     ind.ValidFrom,
+				LastSeen:      ind.ValidFrom,
+			}
+			if !ind.ValidUntil.IsZero() {
+				rows[i].ValidUntil = &ind.ValidUntil
+			}
+		}
+		return models.ImportFormatSTIX2, rows, nil
+	}
+
+	var page models.SyncResponse
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&page); err != nil || page.IOCs == nil {
+		return "", nil, errUnknownImportFormat
+	}
+
+	rows := make([]models.IOC, len(page.IOCs))
+	for i, row := range page.IOCs {
+		rows[i] = row.IOC
+	}
+	return models.ImportFormatTIP, rows, nil
+}
+
+// mergeImportedIOC combines two records of the same value: the higher
+// confidence, the union of tags, the widest sighting window, and a's malware
+// family unless only b names one
+func mergeImportedIOC(a, b models.IOC) models.IOC {
+	merged := a
+	merged.Confidence = max(a.Confidence, b.Confidence)
+
+	merged.Tags = slices.Clone(a.Tags)
+	for _, tag := range b.Tags {
+		if !slices.Contains(merged.Tags, tag) {
+			merged.Tags = append(merged.Tags, tag)
+		}
+	}
+
+	if (a.MalwareFamily == "" || a.MalwareFamily == "Unknown") && b.MalwareFamily != "" {
+		merged.MalwareFamily = b.MalwareFamily
+	}
+	if !b.FirstSeen.IsZero() && b.FirstSeen.Before(merged.FirstSeen) {
+		merged.FirstSeen = b.FirstSeen
+	}
+	if b.LastSeen.After(merged.LastSeen) {
+		merged.LastSeen = b.LastSeen
+	}
+	return merged
+}
@@ -0,0 +1,110 @@
+package api
+
+import (
+	"context"
+
+	"tip-server/internal/extractor"
+	"tip-server/internal/models"
+)
+
+const (
+	// denylistSource is the source_file_id reported for denylist matches
+	denylistSource = "denylist"
+
+	// popularDomainWarning flags matches on domains in the popularity list
+	popularDomainWarning = "popular domain — verify context"
+)
+
+// applyLists overrides lookup results with the operator allow/deny lists:
+// allowlisted values are dropped from the matches and denylisted values are
+// added to them. It returns the adjusted matches, without modifying found,
+// and each listed value's verdict.
+func (s *Server) applyLists(values []string, found map[string]models.IOC) (map[string]models.IOC, map[string]string) {
+	listed := make(map[string]string)
+	denied := make(map[string]models.IOCType)
+
+	for _, v := range values {
+		if _, seen := listed[v]; seen {
+			continue
+		}
+
+		ioc, ok := found[v]
+		iocType := ioc.Type
+		if !ok {
+			t, _, detected := s.extractor.Load().DetectType(v)
+			if !detected {
+				continue
+			}
+			iocType = t
+		}
+
+		verdict := s.extractor.Load().CheckLists(iocType, v)
+		if verdict == "" {
+			continue
+		}
+		listed[v] = verdict
+		if verdict == extractor.ListDeny && !ok {
+			denied[v] = iocType
+		}
+	}
+
+	if len(listed) == 0 {
+		return found, listed
+	}
+
+	adjusted := make(map[string]models.IOC, len(found)+len(denied))
+	for v, ioc := range found {
+		if listed[v] != extractor.ListAllow {
+			adjusted[v] = ioc
+		}
+	}
+	for v, iocType := range denied {
+		adjusted[v] = models.IOC{
+			Value:        v,
+			Type:         iocType,
+			SourceFileID: denylistSource,
+			Confidence:   100,
+		}
+	}
+	return adjusted, listed
+}
+
+// flagPopular lowers the confidence of domain matches on the popularity
+// list, more so the more popular the domain, and flags them for review. A
+// hit on google.com is far more likely a report quoting it than a threat.
+// Denylisted values keep the verdict the operator gave them.
+func (s *Server) flagPopular(results []models.IOCResult) {
+	for i := range results {
+		r := &results[i]
+		if !r.Found || r.Type != models.IOCTypeDomain || r.List == extractor.ListDeny {
+			continue
+		}
+		rank := s.extractor.Load().PopularityRank(r.IOC)
+		if rank == 0 {
+			continue
+		}
+		r.PopularityRank = rank
+		r.Confidence = popularityConfidence(r.Confidence, rank)
+		r.Warning = popularDomainWarning
+	}
+}
+
+// popularityConfidence scales confidence by popularity rank: top-1k domains
+// keep a quarter, top-10k half, top-100k three quarters and the rest 90%
+func popularityConfidence(confidence uint8, rank int) uint8 {
+	pct := 90
+	switch {
+	case rank <= 1000:
+		pct = 25
+	case rank <= 10000:
+		pct = 50
+	case rank <= 100000:
+		pct = 75
+	}
+	return uint8(int(confidence) * pct / 100)
+}
+
+// refreshLists re-reads the extraction allow/deny and popularity list files
+func (s *Server) refreshLists(ctx context.Context) error {
+	return s.extractor.Load().ReloadLists()
+}
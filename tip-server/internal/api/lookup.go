@@ -0,0 +1,222 @@
+package api
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"tip-server/internal/models"
+)
+
+// lookupResult is the merged output of the /check lookup pipeline
+type lookupResult struct {
+	found   map[string]models.IOC
+	cached  int    // Matches served from the lookup cache
+	bloom   []bool // Filter answer per requested value; nil unless bloomOK
+	bloomOK bool
+	queryOK bool // Every ClickHouse chunk answered
+	stages  models.CheckStages
+
+	// Candidates whose ClickHouse chunk failed, so neither found nor absent
+	unconfirmed map[string]bool
+
+	cachedMisses int // Values answered from the negative cache
+}
+
+// lookupIOCs resolves values against the corpus. The Bloom filter, the
+// Redis lookup cache and the negative cache are read in one pipelined round
+// trip; values the filter passes that were neither cached nor recently
+// missed are then queried from ClickHouse in parallel chunks, and the
+// outcomes are written back to both caches.
+func (s *Server) lookupIOCs(ctx context.Context, values []string) lookupResult {
+	res := lookupResult{found: make(map[string]models.IOC), queryOK: true}
+	cacheTTL := s.cfg.Redis.LookupCacheTTL
+	missTTL := s.cfg.Redis.NegativeCacheTTL
+
+	start := time.Now()
+	probe := s.redis.ProbeLookups(ctx, values, cacheTTL > 0, missTTL > 0)
+	res.stages.Redis = time.Since(start).String()
+	res.stages.Bloom = res.stages.Redis
+
+	if probe.BloomErr != nil {
+		// Continue without the filter: every value is a candidate
+		log.Error().Err(probe.BloomErr).Msg("Bloom filter check failed")
+	} else {
+		res.bloom, res.bloomOK = probe.Bloom, true
+	}
+	if probe.CacheErr != nil {
+		log.Warn().Err(probe.CacheErr).Msg("Lookup cache read failed")
+	}
+	if probe.MissErr != nil {
+		log.Warn().Err(probe.MissErr).Msg("Negative cache read failed")
+	}
+
+	candidates := make([]string, 0, len(values))
+	queued := make(map[string]bool, len(values))
+	for i, v := range values {
+		if res.bloomOK {
+			s.metrics.RecordBloomFilterCheck(res.bloom[i])
+		}
+		if ioc, ok := probe.Cached[v]; ok {
+			res.found[v] = ioc
+			continue
+		}
+		if probe.Misses[v] {
+			res.cachedMisses++
+			continue
+		}
+		if (res.bloomOK && !res.bloom[i]) || queued[v] {
+			continue
+		}
+		queued[v] = true
+		candidates = append(candidates, v)
+	}
+	res.cached = len(res.found)
+
+	start = time.Now()
+	fresh, failed := s.queryCandidates(ctx, candidates)
+	res.stages.ClickHouse = time.Since(start).String()
+	res.queryOK = len(failed) == 0
+
+	if !res.queryOK {
+		res.unconfirmed = make(map[string]bool, len(failed))
+		for _, v := range failed {
+			res.unconfirmed[v] = true
+		}
+	}
+
+	for v, ioc := range fresh {
+		res.found[v] = ioc
+	}
+
+	s.storeLookups(values, fresh, probe.Misses, res)
+	return res
+}
+
+// withholdPending removes matches still pending analyst review from found,
+// unless include is set, and returns the values it removed
+func (r *lookupResult) withholdPending(include bool) map[string]bool {
+	if include {
+		return nil
+	}
+	var withheld map[string]bool
+	for v, ioc := range r.found {
+		if !ioc.Pending {
+			continue
+		}
+		if withheld == nil {
+			withheld = make(map[string]bool)
+		}
+		withheld[v] = true
+		delete(r.found, v)
+	}
+	return withheld
+}
+
+// queryCandidates queries ClickHouse for candidates, sharing the lookups of
+// values other requests are querying at the same moment (CHECK_COALESCE)
+func (s *Server) queryCandidates(ctx context.Context, candidates []string) (map[string]models.IOC, []string) {
+	if !s.cfg.API.CheckCoalesce {
+		return s.queryIOCChunks(ctx, candidates)
+	}
+
+	owned, joined := s.flights.claim(candidates)
+	found, failed := s.queryIOCChunks(ctx, owned)
+	s.flights.land(owned, found, failed)
+
+	if len(joined) == 0 {
+		return found, failed
+	}
+	s.metrics.CheckCoalesced.Add(float64(len(joined)))
+
+	shared, sharedFailed := s.flights.wait(ctx, joined)
+	for v, ioc := range shared {
+		found[v] = ioc
+	}
+	return found, append(failed, sharedFailed...)
+}
+
+// storeLookups writes values found absent to the negative cache before
+// returning, and fresh matches to the lookup cache in the background. A miss
+// written in the background could land after the ingestor stored the value
+// and dropped its miss key, hiding the value for NEGATIVE_CACHE_TTL.
+func (s *Server) storeLookups(values []string, fresh map[string]models.IOC, knownMisses map[string]bool, res lookupResult) {
+	cacheTTL := s.cfg.Redis.LookupCacheTTL
+	missTTL := s.cfg.Redis.NegativeCacheTTL
+	if cacheTTL <= 0 {
+		fresh = nil
+	}
+
+	var absent []string
+	if missTTL > 0 {
+		seen := make(map[string]bool, len(values))
+		for _, v := range values {
+			if _, ok := res.found[v]; ok || knownMisses[v] || res.unconfirmed[v] || seen[v] {
+				continue
+			}
+			seen[v] = true
+			absent = append(absent, v)
+		}
+	}
+
+	if len(absent) > 0 {
+		missCtx, cancel := context.WithTimeout(context.Background(), time.Second)
+		if err := s.redis.StoreLookups(missCtx, nil, 0, absent, missTTL); err != nil {
+			log.Debug().Err(err).Msg("Negative cache write failed")
+		}
+		cancel()
+	}
+	if len(fresh) == 0 {
+		return
+	}
+
+	go func() {
+		cacheCtx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		if err := s.redis.StoreLookups(cacheCtx, fresh, cacheTTL, nil, 0); err != nil {
+			log.Debug().Err(err).Msg("Lookup cache write failed")
+		}
+	}()
+}
+
+// queryIOCChunks queries ClickHouse for values in CHECK_QUERY_CHUNK sized
+// chunks, CHECK_QUERY_CONCURRENCY at a time. It also returns the values of
+// the chunks that failed; matches from the other chunks are still returned.
+func (s *Server) queryIOCChunks(ctx context.Context, values []string) (map[string]models.IOC, []string) {
+	var (
+		mu     sync.Mutex
+		wg     sync.WaitGroup
+		found  = make(map[string]models.IOC)
+		failed []string
+		sem    = make(chan struct{}, s.cfg.API.CheckQueryConcurrency)
+		chunk  = s.cfg.API.CheckQueryChunk
+	)
+
+	for start := 0; start < len(values); start += chunk {
+		part := values[start:min(start+chunk, len(values))]
+
+		sem <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer func() { <-sem; wg.Done() }()
+
+			iocs, err := s.ch.QueryIOCs(ctx, part)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				log.Error().Err(err).Int("values", len(part)).Msg("ClickHouse query failed")
+				failed = append(failed, part...)
+				return
+			}
+			for _, ioc := range iocs {
+				found[ioc.Value] = ioc
+			}
+		}()
+	}
+
+	wg.Wait()
+	return found, failed
+}
@@ -0,0 +1,81 @@
+package api
+
+import (
+	"context"
+	"net/netip"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"tip-server/internal/extractor"
+	"tip-server/internal/models"
+	"tip-server/internal/netutil"
+)
+
+// refreshRanges reloads CIDR indicators into the in-memory table /check
+// matches addresses against. Blocks are few enough to hold in memory, and
+// containment cannot be answered by the Bloom filter or an exact lookup.
+func (s *Server) refreshRanges(ctx context.Context) error {
+	ranges, err := s.ch.ListActiveRanges(ctx)
+	if err != nil {
+		return err
+	}
+
+	table := netutil.NewPrefixTable[models.IOC]()
+	for _, ioc := range ranges {
+		p, err := netip.ParsePrefix(ioc.Value)
+		if err != nil {
+			continue
+		}
+		table.Insert(p, ioc)
+	}
+	s.ranges.Store(table)
+
+	log.Debug().Int("ranges", table.Len()).Msg("Reloaded IP range indicators")
+	return nil
+}
+
+// matchRanges marks unmatched IP addresses that fall inside a stored CIDR
+// block as found, reporting the most specific block. Exact matches and
+// allowlisted addresses take precedence; extended adds the block's last_seen
+// and tags (v1). It returns the number of results matched.
+func (s *Server) matchRanges(results []models.IOCResult, window checkWindow, extended bool) int {
+	table := s.ranges.Load()
+	if table == nil {
+		return 0
+	}
+
+	matched := 0
+	for i := range results {
+		r := &results[i]
+		if r.Found || r.List == extractor.ListAllow {
+			continue
+		}
+		addr, err := netip.ParseAddr(r.IOC)
+		if err != nil {
+			continue
+		}
+		block, ioc, ok := table.Lookup(addr)
+		if !ok {
+			continue
+		}
+		if !window.contains(ioc) {
+			r.OutsideWindow = true
+			continue
+		}
+
+		r.Found = true
+		r.Type = models.IOCTypeCIDR
+		r.MatchedRange = block.String()
+		r.SourceFileID = ioc.SourceFileID
+		r.MalwareFamily = ioc.MalwareFamily
+		r.Confidence = ioc.Confidence
+		r.FirstSeen = ioc.FirstSeen.Format(time.RFC3339)
+		if extended {
+			r.LastSeen = ioc.LastSeen.Format(time.RFC3339)
+			r.Tags = ioc.Tags
+		}
+		matched++
+	}
+	return matched
+}
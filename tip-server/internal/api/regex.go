@@ -0,0 +1,197 @@
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/rs/zerolog/log"
+
+	"tip-server/internal/db"
+	"tip-server/internal/middleware"
+	"tip-server/internal/models"
+)
+
+// regexMaxPattern bounds the length of a submitted regular expression
+const regexMaxPattern = 512
+
+// regexJobKey is the Redis key holding a background regex search
+func regexJobKey(id string) string {
+	return "tip:regex:" + id
+}
+
+// regexSearchHandler runs a regular expression over stored indicators of one
+// type, for retro-hunts such as DGA-shaped domains. Searches that outlast
+// REGEX_SEARCH_TIMEOUT, or that ask for it, continue as a background job
+// polled at GET /search/regex/:id.
+func (s *Server) regexSearchHandler(c *fiber.Ctx) error {
+	startTime := time.Now()
+
+	var req models.RegexSearchRequest
+	if err := middleware.ParseJSONStrict(c, &req); err != nil {
+		return middleware.Problem(c, fiber.StatusBadRequest, models.ErrCodeInvalidRequest,
+			"Invalid request body", err.Error())
+	}
+
+	if !slices.Contains(models.AllIOCTypes(), req.Type) {
+		return middleware.Problem(c, fiber.StatusBadRequest, models.ErrCodeInvalidRequest,
+			"Invalid IOC type", "type is required, since a pattern is matched against one type at a time")
+	}
+	if req.Pattern == "" || len(req.Pattern) > regexMaxPattern {
+		return middleware.Problem(c, fiber.StatusBadRequest, models.ErrCodeInvalidRequest,
+			"Invalid pattern", fmt.Sprintf("pattern must be between 1 and %d bytes", regexMaxPattern))
+	}
+	// ClickHouse evaluates match() with RE2, the same syntax as Go's regexp
+	if _, err := regexp.Compile(req.Pattern); err != nil {
+		return middleware.Problem(c, fiber.StatusBadRequest, models.ErrCodeInvalidRequest,
+			"Invalid pattern", err.Error())
+	}
+	if req.MinDGAScore > 100 {
+		return middleware.Problem(c, fiber.StatusBadRequest, models.ErrCodeInvalidRequest,
+			"Invalid DGA score", "min_dga_score must be between 0 and 100")
+	}
+	if req.Limit < 0 || req.Limit > searchMaxLimit {
+		return middleware.Problem(c, fiber.StatusBadRequest, models.ErrCodeInvalidRequest,
+			"Invalid limit", fmt.Sprintf("limit must be between 1 and %d", searchMaxLimit))
+	}
+	if req.Limit == 0 {
+		req.Limit = searchDefaultLimit
+	}
+
+	job := models.RegexSearchJob{
+		Status:    models.RegexJobRunning,
+		Pattern:   req.Pattern,
+		Type:      req.Type,
+		Limit:     req.Limit,
+		CreatedAt: startTime.UTC(),
+
+		MinDGAScore: req.MinDGAScore,
+	}
+
+	if !req.Async {
+		ctx, cancel := context.WithTimeout(context.Background(), s.cfg.API.RegexSearchTimeout)
+		iocs, err := s.ch.MatchIOCs(ctx, req.Pattern, req.Type, req.MinDGAScore, req.Limit+1, s.cfg.API.RegexSearchMaxRows)
+		cancel()
+
+		if err == nil {
+			resp := models.SearchResponse{
+				Query:       req.Pattern,
+				Mode:        models.SearchModeRegex,
+				Type:        req.Type,
+				MinDGAScore: req.MinDGAScore,
+			}
+			resp.Results, resp.Truncated = truncateResults(iocs, req.Limit)
+			resp.QueryTime = time.Since(startTime).String()
+			return c.JSON(resp)
+		}
+		if !errors.Is(err, context.DeadlineExceeded) {
+			return regexSearchProblem(c, err)
+		}
+		log.Info().Str("pattern", req.Pattern).Msg("Regex search exceeded its synchronous budget, continuing in the background")
+	}
+
+	return s.startRegexJob(c, job)
+}
+
+// startRegexJob records a background regex search and starts it, answering
+// 202 with the job to poll
+func (s *Server) startRegexJob(c *fiber.Ctx, job models.RegexSearchJob) error {
+	select {
+	case s.regexJobs <- struct{}{}:
+	default:
+		return middleware.Problem(c, fiber.StatusTooManyRequests, models.ErrCodeRateLimitExceeded,
+			"Too many regex searches running",
+			fmt.Sprintf("At most %d background searches run at once; retry later", cap(s.regexJobs)))
+	}
+
+	buf := make([]byte, 16)
+	rand.Read(buf)
+	job.ID = hex.EncodeToString(buf)
+
+	if err := s.redis.SetJSON(context.Background(), regexJobKey(job.ID), job, s.cfg.API.RegexSearchResultTTL); err != nil {
+		<-s.regexJobs
+		log.Error().Err(err).Msg("Failed to record regex search job")
+		return middleware.Problem(c, fiber.StatusInternalServerError, models.ErrCodeStorageUnavailable,
+			"Failed to start search", "")
+	}
+
+	go s.runRegexJob(job)
+
+	c.Location("/search/regex/" + job.ID)
+	return c.Status(fiber.StatusAccepted).JSON(job)
+}
+
+// runRegexJob runs a background regex search and stores its outcome
+func (s *Server) runRegexJob(job models.RegexSearchJob) {
+	defer func() { <-s.regexJobs }()
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.cfg.API.RegexSearchJobTimeout)
+	iocs, err := s.ch.MatchIOCs(ctx, job.Pattern, job.Type, job.MinDGAScore, job.Limit+1, s.cfg.API.RegexSearchMaxRows)
+	cancel()
+
+	completed := time.Now().UTC()
+	job.CompletedAt = &completed
+	switch {
+	case errors.Is(err, db.ErrScanLimit):
+		job.Status, job.Error = models.RegexJobFailed, "pattern scans too many rows; anchor it or make it more specific"
+	case errors.Is(err, context.DeadlineExceeded):
+		job.Status, job.Error = models.RegexJobFailed, "search did not finish within "+s.cfg.API.RegexSearchJobTimeout.String()
+	case err != nil:
+		log.Error().Err(err).Str("job", job.ID).Msg("Regex search failed")
+		job.Status, job.Error = models.RegexJobFailed, "search failed"
+	default:
+		job.Status = models.RegexJobDone
+		job.Results, job.Truncated = truncateResults(iocs, job.Limit)
+	}
+
+	if err := s.redis.SetJSON(context.Background(), regexJobKey(job.ID), job, s.cfg.API.RegexSearchResultTTL); err != nil {
+		log.Error().Err(err).Str("job", job.ID).Msg("Failed to store regex search results")
+	}
+}
+
+// regexJobHandler reports the status and, once done, the results of a
+// background regex search
+func (s *Server) regexJobHandler(c *fiber.Ctx) error {
+	var job models.RegexSearchJob
+	err := s.redis.GetJSON(context.Background(), regexJobKey(c.Params("id")), &job)
+	if errors.Is(err, redis.Nil) {
+		return middleware.Problem(c, fiber.StatusNotFound, models.ErrCodeNotFound,
+			"Search not found", "Unknown job id, or its results have expired")
+	}
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to load regex search job")
+		return middleware.Problem(c, fiber.StatusInternalServerError, models.ErrCodeStorageUnavailable,
+			"Failed to load search", "")
+	}
+	return c.JSON(job)
+}
+
+// regexSearchProblem answers a failed synchronous regex search
+func regexSearchProblem(c *fiber.Ctx, err error) error {
+	if errors.Is(err, db.ErrScanLimit) {
+		return middleware.Problem(c, fiber.StatusUnprocessableEntity, models.ErrCodeScanLimitExceeded,
+			"Search too broad", "The pattern scans too many rows; anchor it or make it more specific")
+	}
+	log.Error().Err(err).Msg("Regex search failed")
+	return middleware.Problem(c, fiber.StatusInternalServerError, models.ErrCodeStorageUnavailable,
+		"Search failed", "")
+}
+
+// truncateResults cuts results fetched with one extra row down to limit,
+// reporting whether anything was cut
+func truncateResults(iocs []models.IOC, limit int) ([]models.IOC, bool) {
+	if len(iocs) > limit {
+		return iocs[:limit], true
+	}
+	if iocs == nil {
+		iocs = []models.IOC{}
+	}
+	return iocs, false
+}
@@ -0,0 +1,168 @@
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/rs/zerolog/log"
+
+	"tip-server/internal/middleware"
+	"tip-server/internal/models"
+)
+
+const (
+	// reprocessMaxFiles bounds the files reset by one request
+	reprocessMaxFiles = 1000
+
+	// fileIDMaxLength bounds a requested file ID
+	fileIDMaxLength = 256
+)
+
+// reprocessStatuses are the scan statuses files can be selected by for
+// reprocessing
+var reprocessStatuses = []models.ScanStatus{
+	models.ScanStatusFailed,
+	models.ScanStatusTimeout,
+	models.ScanStatusOversized,
+}
+
+// reprocessHandler resets registered files to pending, clearing their error,
+// and asks watch-mode ingestors to process them again now (admin only).
+// Files are selected by ID or by scan status. Ingestors read pending files
+// whatever their size, mtime and content, so files no ingestor picks up at
+// once are processed by the next crawl of their directory.
+func (s *Server) reprocessHandler(c *fiber.Ctx) error {
+	var req models.ReprocessRequest
+	if err := middleware.ParseJSONStrict(c, &req); err != nil {
+		return middleware.Problem(c, fiber.StatusBadRequest, models.ErrCodeInvalidRequest,
+			"Invalid request body", err.Error())
+	}
+	if err := validateReprocess(&req); err != nil {
+		return middleware.Problem(c, fiber.StatusBadRequest, models.ErrCodeInvalidRequest,
+			"Invalid reprocess request", err.Error())
+	}
+
+	ctx := context.Background()
+	resp := models.ReprocessResponse{Files: []models.FileMetadata{}, Timestamp: time.Now().UTC()}
+
+	var files []models.FileMetadata
+	if req.Status != "" {
+		var err error
+		files, err = s.ch.ListFilesByStatus(ctx, req.Status, reprocessMaxFiles+1)
+		if err != nil {
+			log.Error().Err(err).Str("status", string(req.Status)).Msg("Failed to list files by status")
+			return middleware.Problem(c, fiber.StatusInternalServerError, models.ErrCodeStorageUnavailable,
+				"Failed to list files", "")
+		}
+		if len(files) > reprocessMaxFiles {
+			files, resp.More = files[:reprocessMaxFiles], true
+		}
+	} else {
+		for _, id := range req.FileIDs {
+			meta, err := s.ch.GetFileMetadata(ctx, id)
+			if err != nil || meta.ScanStatus == models.ScanStatusDeleted {
+				resp.NotFound = append(resp.NotFound, id)
+				continue
+			}
+			files = append(files, *meta)
+		}
+		if len(files) == 0 {
+			return middleware.Problem(c, fiber.StatusNotFound, models.ErrCodeNotFound,
+				"No files found", "None of the file IDs is registered")
+		}
+	}
+	if len(files) == 0 {
+		return c.JSON(resp)
+	}
+
+	ids := make([]string, len(files))
+	paths := make([]string, len(files))
+	for idx, meta := range files {
+		reset := meta
+		reset.ScanStatus = models.ScanStatusPending
+		reset.ErrorMessage = ""
+		if err := s.ch.UpsertFileMetadata(ctx, &reset); err != nil {
+			log.Error().Err(err).Str("file_id", meta.FileID).Msg("Failed to reset file for reprocessing")
+			return middleware.Problem(c, fiber.StatusInternalServerError, models.ErrCodeStorageUnavailable,
+				"Failed to reset files", "")
+		}
+		ids[idx], paths[idx] = meta.FileID, meta.FilePath
+	}
+	resp.Files = files
+
+	run := models.IngestRun{
+		ID:          newRunID(),
+		Paths:       paths,
+		RequestedAt: resp.Timestamp,
+	}
+	ingestors, err := s.redis.RequestIngestRun(ctx, run)
+	if err != nil {
+		log.Warn().Err(err).Msg("Failed to publish reprocess run, files wait for the next crawl")
+	} else {
+		resp.RunID, resp.Ingestors = run.ID, ingestors
+	}
+
+	list, _ := json.Marshal(ids)
+	actor, _ := c.Locals("api_key_hash").(string)
+	entry := models.AuditEntry{
+		Timestamp:    resp.Timestamp,
+		Action:       models.AuditActionReprocess,
+		IOCValue:     string(list),
+		Actor:        actor,
+		Reason:       req.Reason,
+		ClientIP:     c.IP(),
+		RowsAffected: uint64(len(files)),
+	}
+	if err := s.ch.InsertAuditEntry(ctx, entry); err != nil {
+		log.Error().Err(err).Msg("Failed to write audit entry")
+	}
+
+	log.Info().
+		Int("files", len(files)).
+		Str("status", string(req.Status)).
+		Str("run", resp.RunID).
+		Int64("ingestors", resp.Ingestors).
+		Str("actor", actor).
+		Msg("Files reset for reprocessing")
+
+	return c.Status(fiber.StatusAccepted).JSON(resp)
+}
+
+// validateReprocess checks a reprocess request and trims its file IDs
+func validateReprocess(req *models.ReprocessRequest) error {
+	if (len(req.FileIDs) == 0) == (req.Status == "") {
+		return fmt.Errorf("set exactly one of file_ids and status")
+	}
+	if req.Status != "" {
+		if !slices.Contains(reprocessStatuses, req.Status) {
+			return fmt.Errorf("status must be failed, timeout or oversized")
+		}
+		return nil
+	}
+
+	if len(req.FileIDs) > reprocessMaxFiles {
+		return fmt.Errorf("file_ids has more than %d entries", reprocessMaxFiles)
+	}
+	for i, id := range req.FileIDs {
+		id = strings.TrimSpace(id)
+		if err := middleware.ValidateIndicator(id, fileIDMaxLength); err != nil {
+			return fmt.Errorf("file_ids[%d]: %w", i, err)
+		}
+		req.FileIDs[i] = id
+	}
+	return nil
+}
+
+// newRunID returns a random ID for an on-demand ingestion run
+func newRunID() string {
+	buf := make([]byte, 8)
+	rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
@@ -0,0 +1,99 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/rs/zerolog/log"
+
+	"tip-server/internal/middleware"
+	"tip-server/internal/models"
+)
+
+// revealMaxValues bounds the values of one reveal request
+const revealMaxValues = 100
+
+// revealHandler returns the email addresses redacted values stand for
+// (admin only), decrypted from the originals sealed with them at ingestion.
+// Every reveal is audited, including those that found nothing.
+func (s *Server) revealHandler(c *fiber.Ctx) error {
+	if !s.redactor.Enabled() {
+		return middleware.Problem(c, fiber.StatusServiceUnavailable, models.ErrCodeFeatureUnavailable,
+			"Email redaction is off", "Addresses are stored verbatim unless EMAIL_REDACTION is hash or partial")
+	}
+
+	var req models.RevealRequest
+	if err := middleware.ParseJSONStrict(c, &req); err != nil {
+		return middleware.Problem(c, fiber.StatusBadRequest, models.ErrCodeInvalidRequest,
+			"Invalid request body", err.Error())
+	}
+	if len(req.Values) == 0 {
+		return middleware.Problem(c, fiber.StatusBadRequest, models.ErrCodeNoIOCs,
+			"No values provided", "")
+	}
+	if len(req.Values) > revealMaxValues {
+		return middleware.Problem(c, fiber.StatusBadRequest, models.ErrCodeIOCLimitExceeded,
+			"Too many values", fmt.Sprintf("Maximum %d values per reveal", revealMaxValues))
+	}
+	for i, value := range req.Values {
+		req.Values[i] = strings.TrimSpace(value)
+	}
+	slices.Sort(req.Values)
+	req.Values = slices.Compact(req.Values)
+	if err := middleware.ValidateIndicators(req.Values, s.cfg.API.MaxIOCLength); err != nil {
+		return middleware.Problem(c, fiber.StatusBadRequest, models.ErrCodeInvalidIOC,
+			"Invalid value", err.Error())
+	}
+
+	ctx := context.Background()
+	sealed, err := s.ch.GetSealedValues(ctx, req.Values)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to read sealed values")
+		return middleware.Problem(c, fiber.StatusInternalServerError, models.ErrCodeStorageUnavailable,
+			"Failed to read sealed values", "")
+	}
+
+	resp := models.RevealResponse{Revealed: make(map[string]string), Timestamp: time.Now().UTC()}
+	for _, value := range req.Values {
+		ciphertext, ok := sealed[value]
+		if !ok {
+			resp.NotFound = append(resp.NotFound, value)
+			continue
+		}
+		addr, err := s.redactor.Open(value, ciphertext)
+		if err != nil {
+			log.Warn().Err(err).Str("value", value).Msg("Failed to open sealed value")
+			resp.NotFound = append(resp.NotFound, value)
+			continue
+		}
+		resp.Revealed[value] = addr
+	}
+
+	values, _ := json.Marshal(req.Values)
+	actor, _ := c.Locals("api_key_hash").(string)
+	entry := models.AuditEntry{
+		Timestamp:    resp.Timestamp,
+		Action:       models.AuditActionReveal,
+		IOCValue:     string(values),
+		Actor:        actor,
+		Reason:       req.Reason,
+		ClientIP:     c.IP(),
+		RowsAffected: uint64(len(resp.Revealed)),
+	}
+	if err := s.ch.InsertAuditEntry(ctx, entry); err != nil {
+		log.Error().Err(err).Msg("Failed to write audit entry")
+	}
+
+	log.Info().
+		Int("revealed", len(resp.Revealed)).
+		Int("not_found", len(resp.NotFound)).
+		Str("actor", actor).
+		Msg("Revealed redacted email addresses")
+
+	return c.JSON(resp)
+}
@@ -0,0 +1,152 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/rs/zerolog/log"
+
+	"tip-server/internal/middleware"
+	"tip-server/internal/models"
+	"tip-server/internal/normalize"
+)
+
+const (
+	// reviewDefaultLimit and reviewMaxLimit bound a page of the review queue
+	reviewDefaultLimit = 100
+	reviewMaxLimit     = 1000
+
+	// reviewMaxValues bounds the values approved or rejected at once
+	reviewMaxValues = 1000
+)
+
+// reviewQueueHandler lists the IOCs awaiting analyst review, longest waiting
+// first. Query parameters: type, limit and offset.
+func (s *Server) reviewQueueHandler(c *fiber.Ctx) error {
+	iocType := models.IOCType(c.Query("type"))
+	if iocType != "" && !slices.Contains(models.AllIOCTypes(), iocType) {
+		return middleware.Problem(c, fiber.StatusBadRequest, models.ErrCodeInvalidParameter,
+			"Invalid query parameter", fmt.Sprintf("%q is not a supported IOC type", iocType))
+	}
+
+	limit, ok := queryNonNegativeInt(c, "limit")
+	if !ok || limit > reviewMaxLimit {
+		return middleware.Problem(c, fiber.StatusBadRequest, models.ErrCodeInvalidParameter,
+			"Invalid query parameter", "limit must be between 1 and 1000")
+	}
+	if limit == 0 {
+		limit = reviewDefaultLimit
+	}
+	offset, ok := queryNonNegativeInt(c, "offset")
+	if !ok {
+		return middleware.Problem(c, fiber.StatusBadRequest, models.ErrCodeInvalidParameter,
+			"Invalid query parameter", "offset must be a non-negative integer")
+	}
+
+	iocs, total, err := s.ch.ListPendingIOCs(context.Background(), iocType, limit, offset)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to list pending IOCs")
+		return middleware.Problem(c, fiber.StatusInternalServerError, models.ErrCodeStorageUnavailable,
+			"Failed to list review queue", "")
+	}
+
+	return c.JSON(models.ReviewQueueResponse{
+		IOCs:   iocs,
+		Total:  total,
+		Limit:  limit,
+		Offset: offset,
+	})
+}
+
+// reviewApproveHandler releases pending IOCs to /check (admin only)
+func (s *Server) reviewApproveHandler(c *fiber.Ctx) error {
+	return s.review(c, true)
+}
+
+// reviewRejectHandler deprecates pending IOCs (admin only)
+func (s *Server) reviewRejectHandler(c *fiber.Ctx) error {
+	return s.review(c, false)
+}
+
+// review applies an analyst's decision to the pending rows of the values in
+// the request body. Values with no pending rows are ignored.
+func (s *Server) review(c *fiber.Ctx, approve bool) error {
+	var req models.ReviewRequest
+	if err := middleware.ParseJSONStrict(c, &req); err != nil {
+		return middleware.Problem(c, fiber.StatusBadRequest, models.ErrCodeInvalidRequest,
+			"Invalid request body", err.Error())
+	}
+	if len(req.Values) == 0 {
+		return middleware.Problem(c, fiber.StatusBadRequest, models.ErrCodeNoIOCs,
+			"No IOCs provided", "")
+	}
+	if len(req.Values) > reviewMaxValues {
+		return middleware.Problem(c, fiber.StatusBadRequest, models.ErrCodeIOCLimitExceeded,
+			"Too many IOCs", fmt.Sprintf("Maximum %d values per review", reviewMaxValues))
+	}
+	if err := middleware.ValidateIndicators(req.Values, s.cfg.API.MaxIOCLength); err != nil {
+		return middleware.Problem(c, fiber.StatusBadRequest, models.ErrCodeInvalidIOC,
+			"Invalid IOC", err.Error())
+	}
+
+	values := make([]string, len(req.Values))
+	for i, v := range req.Values {
+		values[i] = s.redactor.Value(normalize.Value(v))
+	}
+
+	ctx := context.Background()
+	resp := models.ReviewResponse{Action: "reject", Values: values, Timestamp: time.Now().UTC()}
+	action := models.AuditActionReviewReject
+	if approve {
+		resp.Action, action = "approve", models.AuditActionReviewApprove
+	}
+
+	affected, err := s.ch.ReviewIOCs(ctx, values, approve)
+	if err != nil {
+		log.Error().Err(err).Str("action", resp.Action).Msg("Failed to review IOCs")
+		return middleware.Problem(c, fiber.StatusInternalServerError, models.ErrCodeStorageUnavailable,
+			"Failed to review IOCs", "")
+	}
+	resp.RowsAffected = affected
+	if affected == 0 {
+		return c.JSON(resp)
+	}
+
+	// Rejected values may have no active rows left; the rebuild decides
+	if !approve {
+		if err := s.redis.ScheduleBloomRemoval(ctx, values...); err != nil {
+			log.Warn().Err(err).Msg("Failed to schedule Bloom filter maintenance")
+		}
+	}
+	if err := s.redis.InvalidateCachedIOCs(ctx, values...); err != nil {
+		log.Warn().Err(err).Msg("Failed to invalidate lookup cache for reviewed IOCs")
+	}
+
+	list, _ := json.Marshal(values)
+	actor, _ := c.Locals("api_key_hash").(string)
+	entry := models.AuditEntry{
+		Timestamp:    resp.Timestamp,
+		Action:       action,
+		IOCValue:     string(list),
+		Actor:        actor,
+		Reason:       req.Reason,
+		ClientIP:     c.IP(),
+		RowsAffected: affected,
+	}
+	if err := s.ch.InsertAuditEntry(ctx, entry); err != nil {
+		log.Error().Err(err).Msg("Failed to write audit entry")
+	}
+
+	log.Info().
+		Str("action", resp.Action).
+		Int("values", len(values)).
+		Uint64("rows", affected).
+		Str("actor", actor).
+		Msg("IOCs reviewed")
+
+	return c.JSON(resp)
+}
@@ -0,0 +1,109 @@
+package api
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"tip-server/internal/fixtures"
+	"tip-server/internal/models"
+)
+
+func TestReview(t *testing.T) {
+	s, clients := newTestServer(t)
+
+	now := time.Now().UTC()
+	var held []models.IOC
+	for _, v := range []string{"approved-c2.example", "rejected-c2.example"} {
+		held = append(held, models.IOC{
+			Value:        v,
+			Type:         models.IOCTypeDomain,
+			SourceFileID: fixtures.SourceFileID,
+			Confidence:   20,
+			FirstSeen:    now,
+			LastSeen:     now,
+			Pending:      true,
+		})
+	}
+	if err := fixtures.Seed(context.Background(), clients, held); err != nil {
+		t.Fatal(err)
+	}
+
+	// Held values are only reported when asked for
+	for _, r := range check(t, s, "/check", "approved-c2.example", "rejected-c2.example").Results {
+		if r.Found {
+			t.Errorf("%s found before review", r.IOC)
+		}
+	}
+	for _, r := range check(t, s, "/check?include_pending=true", "approved-c2.example", "rejected-c2.example").Results {
+		if !r.Found || !r.Pending {
+			t.Errorf("%s = %+v with include_pending, want a pending match", r.IOC, r)
+		}
+	}
+
+	var queue models.ReviewQueueResponse
+	if status := request(t, s, "GET", "/review", testAPIKey, nil, &queue); status != 200 || queue.Total != 2 {
+		t.Fatalf("GET /review = %d with %d queued, want 200 with 2", status, queue.Total)
+	}
+
+	tests := []struct {
+		name   string
+		apiKey string
+		path   string
+		value  string
+		status int
+		rows   uint64
+		found  bool // Matched by /check afterwards, with include_pending
+	}{
+		{"not admin", testAPIKey, "/review/approve", "approved-c2.example", 403, 0, true},
+		{"no values", testAdminKey, "/review/approve", "", 400, 0, true},
+		{"approve", testAdminKey, "/review/approve", "APPROVED-C2[.]example", 200, 1, true},
+		{"approve again", testAdminKey, "/review/approve", "approved-c2.example", 200, 0, true},
+		{"reject", testAdminKey, "/review/reject", "rejected-c2.example", 200, 1, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := models.ReviewRequest{Reason: "triage"}
+			if tt.value != "" {
+				req.Values = []string{tt.value}
+			}
+			var resp models.ReviewResponse
+			if status := request(t, s, "POST", tt.path, tt.apiKey, req, &resp); status != tt.status {
+				t.Fatalf("status = %d, want %d", status, tt.status)
+			}
+			if resp.RowsAffected != tt.rows {
+				t.Errorf("rows affected = %d, want %d", resp.RowsAffected, tt.rows)
+			}
+			if tt.value == "" {
+				return
+			}
+			if r := check(t, s, "/check?include_pending=true", tt.value).Results[0]; r.Found != tt.found {
+				t.Errorf("found after %s = %v, want %v", tt.name, r.Found, tt.found)
+			}
+		})
+	}
+
+	// Approved values are released to /check
+	if r := check(t, s, "/check", "approved-c2.example").Results[0]; !r.Found || r.Pending {
+		t.Errorf("approved value = %+v, want a released match", r)
+	}
+	if status := request(t, s, "GET", "/review", testAPIKey, nil, &queue); status != 200 || queue.Total != 0 {
+		t.Errorf("GET /review = %d with %d queued, want an empty queue", status, queue.Total)
+	}
+
+	// Rejected values leave the Bloom filter with the next rebuild
+	if pending, _ := clients.Redis.PendingBloomRemovals(context.Background()); pending != 1 {
+		t.Errorf("pending Bloom removals = %d, want 1", pending)
+	}
+
+	// Decisions that changed rows are audited
+	for action, value := range map[string]string{
+		models.AuditActionReviewApprove: `["approved-c2.example"]`,
+		models.AuditActionReviewReject:  `["rejected-c2.example"]`,
+	} {
+		entries := auditEntries(t, clients, action)
+		if len(entries) != 1 || entries[0].IOCValue != value || entries[0].Reason != "triage" || entries[0].RowsAffected != 1 {
+			t.Errorf("%s audit entries = %+v, want one for %s", action, entries, value)
+		}
+	}
+}
@@ -0,0 +1,190 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/rs/zerolog/log"
+
+	"tip-server/internal/middleware"
+	"tip-server/internal/models"
+)
+
+const (
+	// samplesDefaultLimit and samplesMaxLimit bound a page of extraction
+	// samples
+	samplesDefaultLimit = 100
+	samplesMaxLimit     = 1000
+
+	// samplesMaxLabels bounds the samples labeled at once
+	samplesMaxLabels = 1000
+)
+
+// samplesHandler lists extraction samples, oldest first, for labeling.
+// Query parameters: type, status ("labeled" or "unlabeled"), limit and
+// offset.
+func (s *Server) samplesHandler(c *fiber.Ctx) error {
+	iocType := models.IOCType(c.Query("type"))
+	if iocType != "" && !slices.Contains(models.AllIOCTypes(), iocType) {
+		return middleware.Problem(c, fiber.StatusBadRequest, models.ErrCodeInvalidParameter,
+			"Invalid query parameter", fmt.Sprintf("%q is not a supported IOC type", iocType))
+	}
+	status := c.Query("status")
+	if status != "" && status != models.SampleStatusLabeled && status != models.SampleStatusUnlabeled {
+		return middleware.Problem(c, fiber.StatusBadRequest, models.ErrCodeInvalidParameter,
+			"Invalid query parameter", "status must be labeled or unlabeled")
+	}
+
+	limit, ok := queryNonNegativeInt(c, "limit")
+	if !ok || limit > samplesMaxLimit {
+		return middleware.Problem(c, fiber.StatusBadRequest, models.ErrCodeInvalidParameter,
+			"Invalid query parameter", "limit must be between 1 and 1000")
+	}
+	if limit == 0 {
+		limit = samplesDefaultLimit
+	}
+	offset, ok := queryNonNegativeInt(c, "offset")
+	if !ok {
+		return middleware.Problem(c, fiber.StatusBadRequest, models.ErrCodeInvalidParameter,
+			"Invalid query parameter", "offset must be a non-negative integer")
+	}
+
+	samples, total, err := s.ch.ListExtractionSamples(context.Background(), iocType, status, limit, offset)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to list extraction samples")
+		return middleware.Problem(c, fiber.StatusInternalServerError, models.ErrCodeStorageUnavailable,
+			"Failed to list extraction samples", "")
+	}
+
+	return c.JSON(models.SamplesResponse{
+		Samples: samples,
+		Total:   total,
+		Limit:   limit,
+		Offset:  offset,
+	})
+}
+
+// labelSamplesHandler records an analyst's verdict on extraction samples
+// (admin only). Relabeling a sample replaces its label; IDs with no sample
+// are reported back.
+func (s *Server) labelSamplesHandler(c *fiber.Ctx) error {
+	var req models.LabelRequest
+	if err := middleware.ParseJSONStrict(c, &req); err != nil {
+		return middleware.Problem(c, fiber.StatusBadRequest, models.ErrCodeInvalidRequest,
+			"Invalid request body", err.Error())
+	}
+	if len(req.Labels) == 0 {
+		return middleware.Problem(c, fiber.StatusBadRequest, models.ErrCodeInvalidRequest,
+			"Invalid request body", "labels is required")
+	}
+	if len(req.Labels) > samplesMaxLabels {
+		return middleware.Problem(c, fiber.StatusBadRequest, models.ErrCodeInvalidRequest,
+			"Invalid request body", fmt.Sprintf("Maximum %d labels per request", samplesMaxLabels))
+	}
+
+	// A sample labeled twice in one request keeps the later label
+	labels := make(map[string]string, len(req.Labels))
+	ids := make([]string, 0, len(req.Labels))
+	for _, l := range req.Labels {
+		if l.ID == "" {
+			return middleware.Problem(c, fiber.StatusBadRequest, models.ErrCodeInvalidRequest,
+				"Invalid request body", "every label needs a sample id")
+		}
+		if l.Label != models.LabelTruePositive && l.Label != models.LabelFalsePositive {
+			return middleware.Problem(c, fiber.StatusBadRequest, models.ErrCodeInvalidRequest,
+				"Invalid request body", fmt.Sprintf("label of %s must be true_positive or false_positive", l.ID))
+		}
+		if _, ok := labels[l.ID]; !ok {
+			ids = append(ids, l.ID)
+		}
+		labels[l.ID] = l.Label
+	}
+
+	ctx := context.Background()
+	existing, err := s.ch.GetExtractionSamples(ctx, ids)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to look up extraction samples")
+		return middleware.Problem(c, fiber.StatusInternalServerError, models.ErrCodeStorageUnavailable,
+			"Failed to label extraction samples", "")
+	}
+
+	actor, _ := c.Locals("api_key_hash").(string)
+	now := time.Now().UTC()
+	resp := models.LabelResponse{Unknown: []string{}, Timestamp: now}
+	samples := make([]models.ExtractionSample, 0, len(existing))
+	for _, id := range ids {
+		sample, ok := existing[id]
+		if !ok {
+			resp.Unknown = append(resp.Unknown, id)
+			continue
+		}
+		sample.Label = labels[id]
+		sample.LabeledBy = actor
+		sample.LabeledAt = &now
+		samples = append(samples, sample)
+	}
+	if len(samples) == 0 {
+		return c.JSON(resp)
+	}
+
+	if err := s.ch.InsertExtractionSamples(ctx, samples); err != nil {
+		log.Error().Err(err).Msg("Failed to label extraction samples")
+		return middleware.Problem(c, fiber.StatusInternalServerError, models.ErrCodeStorageUnavailable,
+			"Failed to label extraction samples", "")
+	}
+	resp.Labeled = len(samples)
+
+	list, _ := json.Marshal(req.Labels)
+	entry := models.AuditEntry{
+		Timestamp:    now,
+		Action:       models.AuditActionLabelSamples,
+		IOCValue:     string(list),
+		Actor:        actor,
+		ClientIP:     c.IP(),
+		RowsAffected: uint64(len(samples)),
+	}
+	if err := s.ch.InsertAuditEntry(ctx, entry); err != nil {
+		log.Error().Err(err).Msg("Failed to write audit entry")
+	}
+
+	return c.JSON(resp)
+}
+
+// samplePrecisionHandler estimates the precision of each IOC type from the
+// labeled samples. Query parameters: group_by ("day", "week" (default) or
+// "version"), type, and since to leave out older samples.
+func (s *Server) samplePrecisionHandler(c *fiber.Ctx) error {
+	groupBy := c.Query("group_by", models.PrecisionByWeek)
+	if !slices.Contains([]string{models.PrecisionByDay, models.PrecisionByWeek, models.PrecisionByVersion}, groupBy) {
+		return middleware.Problem(c, fiber.StatusBadRequest, models.ErrCodeInvalidParameter,
+			"Invalid query parameter", "group_by must be day, week or version")
+	}
+	iocType := models.IOCType(c.Query("type"))
+	if iocType != "" && !slices.Contains(models.AllIOCTypes(), iocType) {
+		return middleware.Problem(c, fiber.StatusBadRequest, models.ErrCodeInvalidParameter,
+			"Invalid query parameter", fmt.Sprintf("%q is not a supported IOC type", iocType))
+	}
+
+	since := time.Unix(0, 0).UTC()
+	if raw := c.Query("since"); raw != "" {
+		t, err := models.ParseTime(raw, time.Now())
+		if err != nil {
+			return middleware.Problem(c, fiber.StatusBadRequest, models.ErrCodeInvalidParameter,
+				"Invalid query parameter", "since: "+err.Error())
+		}
+		since = t.UTC()
+	}
+
+	estimates, err := s.ch.GetSamplePrecision(context.Background(), groupBy, iocType, since)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to estimate extraction precision")
+		return middleware.Problem(c, fiber.StatusInternalServerError, models.ErrCodeStorageUnavailable,
+			"Failed to estimate extraction precision", "")
+	}
+
+	return c.JSON(models.PrecisionResponse{GroupBy: groupBy, Estimates: estimates})
+}
@@ -0,0 +1,327 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/rs/zerolog/log"
+
+	"tip-server/internal/embed"
+	"tip-server/internal/middleware"
+	"tip-server/internal/models"
+	"tip-server/internal/netutil"
+	"tip-server/internal/normalize"
+	"tip-server/internal/typosquat"
+)
+
+const (
+	// typosquatMaxPermutations bounds corpus lookups per request
+	typosquatMaxPermutations = 10000
+
+	// typosquatMaxResolve bounds live DNS lookups per request
+	typosquatMaxResolve = 1000
+
+	// typosquatResolveConcurrency is the number of parallel DNS lookups
+	typosquatResolveConcurrency = 50
+
+	// typosquatQueryChunk is the number of values per ClickHouse query
+	typosquatQueryChunk = 1000
+
+	// fuzzyDefaultLimit and fuzzyMaxLimit bound similarity search results
+	fuzzyDefaultLimit = 10
+	fuzzyMaxLimit     = 100
+
+	// searchDefaultLimit and searchMaxLimit bound /search results
+	searchDefaultLimit = 100
+	searchMaxLimit     = 1000
+
+	// searchMinPartial is the shortest prefix or substring searched; shorter
+	// terms cannot use the trigram index and would scan the whole table
+	searchMinPartial = 3
+)
+
+// searchHandler finds stored indicators by a partial value, so analysts can
+// hunt for e.g. every domain containing a brand name. Query parameters: q,
+// mode (exact, prefix or substring; default prefix), type, min_dga_score and
+// limit. q may be omitted when min_dga_score is set, to list the likeliest
+// generated domains.
+func (s *Server) searchHandler(c *fiber.Ctx) error {
+	startTime := time.Now()
+
+	mode := c.Query("mode", models.SearchModePrefix)
+	switch mode {
+	case models.SearchModeExact, models.SearchModePrefix, models.SearchModeSubstring:
+	default:
+		return middleware.Problem(c, fiber.StatusBadRequest, models.ErrCodeInvalidParameter,
+			"Invalid search mode", "mode must be one of exact, prefix, substring")
+	}
+
+	iocType := models.IOCType(c.Query("type"))
+	if iocType != "" && !slices.Contains(models.AllIOCTypes(), iocType) {
+		return middleware.Problem(c, fiber.StatusBadRequest, models.ErrCodeInvalidParameter,
+			"Invalid IOC type", string(iocType))
+	}
+
+	minDGA, ok := queryNonNegativeInt(c, "min_dga_score")
+	if !ok || minDGA > 100 {
+		return middleware.Problem(c, fiber.StatusBadRequest, models.ErrCodeInvalidParameter,
+			"Invalid query parameter", "min_dga_score must be between 0 and 100")
+	}
+
+	q := normalize.Refang(normalize.Sanitize(c.Query("q")))
+	if mode == models.SearchModeExact {
+		q = s.redactor.Value(normalize.Value(q))
+	} else if storedLowercase(iocType) {
+		q = strings.ToLower(q)
+	}
+	// Without q, min_dga_score alone lists the likeliest generated domains
+	if q != "" || minDGA == 0 {
+		if err := middleware.ValidateIndicator(q, s.cfg.API.MaxIOCLength); err != nil {
+			return middleware.Problem(c, fiber.StatusBadRequest, models.ErrCodeInvalidParameter,
+				"Invalid query parameter", "q: "+err.Error())
+		}
+		if mode != models.SearchModeExact && utf8.RuneCountInString(q) < searchMinPartial {
+			return middleware.Problem(c, fiber.StatusBadRequest, models.ErrCodeInvalidParameter,
+				"Search term too short", fmt.Sprintf("prefix and substring searches need at least %d characters", searchMinPartial))
+		}
+	}
+	q = strings.Clone(q)
+
+	limit, ok := queryNonNegativeInt(c, "limit")
+	if !ok || limit > searchMaxLimit {
+		return middleware.Problem(c, fiber.StatusBadRequest, models.ErrCodeInvalidParameter,
+			"Invalid query parameter", fmt.Sprintf("limit must be between 1 and %d", searchMaxLimit))
+	}
+	if limit == 0 {
+		limit = searchDefaultLimit
+	}
+
+	// One extra row tells whether the results were cut off
+	iocs, err := s.ch.SearchIOCs(context.Background(), mode, q, iocType, uint8(minDGA), limit+1)
+	if err != nil {
+		log.Error().Err(err).Str("mode", mode).Msg("IOC search failed")
+		return middleware.Problem(c, fiber.StatusInternalServerError, models.ErrCodeStorageUnavailable,
+			"Search failed", "")
+	}
+
+	resp := models.SearchResponse{
+		Query:       q,
+		Mode:        mode,
+		Type:        iocType,
+		MinDGAScore: uint8(minDGA),
+	}
+	resp.Results, resp.Truncated = truncateResults(iocs, limit)
+	resp.QueryTime = time.Since(startTime).String()
+
+	return c.JSON(resp)
+}
+
+// storedLowercase reports whether values of t are stored lowercased, so
+// partial searches for them are case-insensitive
+func storedLowercase(t models.IOCType) bool {
+	switch t {
+	case models.IOCTypeDomain, models.IOCTypeEmail, models.IOCTypeMD5, models.IOCTypeSHA1,
+		models.IOCTypeSHA256, models.IOCTypeJA3, models.IOCTypeImphash, models.IOCTypeCertSHA1,
+		models.IOCTypeCertSHA256, models.IOCTypeCertSerial:
+		return true
+	}
+	return false
+}
+
+// fuzzySearchHandler returns indexed files whose content is most similar to
+// the submitted text
+func (s *Server) fuzzySearchHandler(c *fiber.Ctx) error {
+	startTime := time.Now()
+
+	if s.index == nil {
+		return middleware.Problem(c, fiber.StatusServiceUnavailable, models.ErrCodeFeatureUnavailable,
+			"Similarity search unavailable", "Set QDRANT_ENABLED=true and make sure Qdrant is reachable")
+	}
+
+	var req models.FuzzySearchRequest
+	if err := middleware.ParseJSONStrict(c, &req); err != nil {
+		return middleware.Problem(c, fiber.StatusBadRequest, models.ErrCodeInvalidRequest,
+			"Invalid request body", err.Error())
+	}
+
+	if req.Limit == 0 {
+		req.Limit = fuzzyDefaultLimit
+	}
+	if req.Limit < 0 || req.Limit > fuzzyMaxLimit || req.MinScore < 0 || req.MinScore > 1 {
+		return middleware.Problem(c, fiber.StatusBadRequest, models.ErrCodeInvalidParameter,
+			"Invalid search parameters", "limit must be between 1 and 100 and min_score between 0 and 1")
+	}
+
+	matches, err := s.index.Search(context.Background(), req.Text, req.Limit, req.MinScore)
+	if errors.Is(err, embed.ErrNoContent) {
+		return middleware.Problem(c, fiber.StatusBadRequest, models.ErrCodeInvalidRequest,
+			"Invalid search text", err.Error())
+	}
+	if err != nil {
+		log.Error().Err(err).Msg("Similarity search failed")
+		return middleware.Problem(c, fiber.StatusInternalServerError, models.ErrCodeStorageUnavailable,
+			"Failed to query similarity index", "")
+	}
+
+	return c.JSON(models.FuzzySearchResponse{
+		Matches:   matches,
+		QueryTime: time.Since(startTime).String(),
+	})
+}
+
+// typosquatHandler generates lookalike permutations of a brand domain and
+// reports which are known indicators and, optionally, which resolve
+func (s *Server) typosquatHandler(c *fiber.Ctx) error {
+	startTime := time.Now()
+
+	var req models.TyposquatRequest
+	if err := middleware.ParseJSONStrict(c, &req); err != nil {
+		return middleware.Problem(c, fiber.StatusBadRequest, models.ErrCodeInvalidRequest,
+			"Invalid request body", err.Error())
+	}
+
+	if err := middleware.ValidateIndicator(req.Domain, s.cfg.API.MaxIOCLength); err != nil {
+		return middleware.Problem(c, fiber.StatusBadRequest, models.ErrCodeInvalidIOC,
+			"Invalid domain", err.Error())
+	}
+
+	valid := toSet(typosquat.AllKinds())
+	for _, k := range req.Kinds {
+		if !valid[k] {
+			return middleware.Problem(c, fiber.StatusBadRequest, models.ErrCodeInvalidRequest,
+				"Invalid permutation kind", k)
+		}
+	}
+
+	perms, err := typosquat.Generate(req.Domain, req.Kinds, typosquatMaxPermutations)
+	if err != nil {
+		status, code := fiber.StatusInternalServerError, models.ErrCodeInternal
+		if errors.Is(err, typosquat.ErrInvalidDomain) {
+			status, code = fiber.StatusBadRequest, models.ErrCodeInvalidIOC
+		}
+		return middleware.Problem(c, status, code,
+			"Failed to generate permutations", err.Error())
+	}
+
+	ctx := context.Background()
+
+	found, err := s.lookupDomains(ctx, perms)
+	if err != nil {
+		log.Error().Err(err).Msg("Typosquat corpus lookup failed")
+		return middleware.Problem(c, fiber.StatusInternalServerError, models.ErrCodeStorageUnavailable,
+			"Failed to query IOC store", "")
+	}
+
+	var resolved map[string][]string
+	resolvedCount := 0
+	if req.ResolveDNS {
+		resolvedCount = min(len(perms), typosquatMaxResolve)
+		resolved = s.resolveDomains(ctx, perms[:resolvedCount])
+	}
+
+	matches := make([]models.TyposquatMatch, 0)
+	for _, p := range perms {
+		ioc, isIOC := found[p.Domain]
+		addrs, resolves := resolved[p.Domain]
+		if !isIOC && !resolves {
+			continue
+		}
+
+		m := models.TyposquatMatch{
+			Domain:    p.Domain,
+			Kind:      p.Kind,
+			Found:     isIOC,
+			Resolves:  resolves,
+			Addresses: addrs,
+		}
+		if isIOC {
+			m.MalwareFamily = ioc.MalwareFamily
+			m.Confidence = ioc.Confidence
+			m.SourceFileID = ioc.SourceFileID
+		}
+		matches = append(matches, m)
+	}
+
+	return c.JSON(models.TyposquatResponse{
+		Domain:    req.Domain,
+		Generated: len(perms),
+		Resolved:  resolvedCount,
+		Matches:   matches,
+		QueryTime: time.Since(startTime).String(),
+	})
+}
+
+// lookupDomains returns the permutations present in the IOC corpus,
+// prefiltered through the Bloom filter
+func (s *Server) lookupDomains(ctx context.Context, perms []typosquat.Permutation) (map[string]models.IOC, error) {
+	values := make([]string, len(perms))
+	for i, p := range perms {
+		values[i] = p.Domain
+	}
+
+	candidates := values
+	if exists, err := s.redis.BFMExists(ctx, values); err == nil {
+		candidates = candidates[:0:0]
+		for i, ok := range exists {
+			if ok {
+				candidates = append(candidates, values[i])
+			}
+		}
+	} else {
+		log.Warn().Err(err).Msg("Bloom filter check failed, querying ClickHouse directly")
+	}
+
+	found := make(map[string]models.IOC)
+	for start := 0; start < len(candidates); start += typosquatQueryChunk {
+		end := min(start+typosquatQueryChunk, len(candidates))
+		iocs, err := s.ch.QueryIOCs(ctx, candidates[start:end])
+		if err != nil {
+			return nil, err
+		}
+		for _, ioc := range iocs {
+			found[ioc.Value] = ioc
+		}
+	}
+	return found, nil
+}
+
+// resolveDomains returns the addresses of permutations that resolve
+func (s *Server) resolveDomains(ctx context.Context, perms []typosquat.Permutation) map[string][]string {
+	resolver := netutil.NewResolver(s.cfg.DNS.Server)
+
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		resolved = make(map[string][]string)
+		sem      = make(chan struct{}, typosquatResolveConcurrency)
+	)
+
+	for _, p := range perms {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(domain string) {
+			defer func() { <-sem; wg.Done() }()
+
+			lookupCtx, cancel := context.WithTimeout(ctx, s.cfg.DNS.Timeout)
+			defer cancel()
+
+			addrs, err := resolver.LookupHost(lookupCtx, domain)
+			if err != nil || len(addrs) == 0 {
+				return
+			}
+			mu.Lock()
+			resolved[domain] = addrs
+			mu.Unlock()
+		}(p.Domain)
+	}
+
+	wg.Wait()
+	return resolved
+}
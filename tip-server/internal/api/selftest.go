@@ -0,0 +1,191 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"tip-server/internal/db"
+	"tip-server/internal/models"
+)
+
+// Self-test steps, in the order they run
+const (
+	selfTestStepInsert     = "insert"
+	selfTestStepBloom      = "bloom"
+	selfTestStepClickHouse = "clickhouse"
+	selfTestStepCheck      = "check"
+	selfTestStepCleanup    = "cleanup"
+)
+
+// selfTestHeader carries the per-process token marking the self-test's own
+// /check request, which is kept out of the query log, metrics and match
+// streams
+const selfTestHeader = "X-TIP-Selftest"
+
+// selfTestCheckTimeout bounds the in-process /check request
+const selfTestCheckTimeout = 10 * time.Second
+
+// selfTestResult is the outcome of the latest self-test run
+type selfTestResult struct {
+	RanAt    time.Time
+	Duration time.Duration
+	Step     string // Failed step, "" when the run passed
+	Err      error
+}
+
+// newSelfTestToken returns a random token for selfTestHeader
+func newSelfTestToken() string {
+	buf := make([]byte, 16)
+	rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
+
+// isSelfTest reports whether a request was issued by the self-test
+func (s *Server) isSelfTest(c *fiber.Ctx) bool {
+	return c.Get(selfTestHeader) == s.selfTestToken
+}
+
+// runSelfTest inserts a synthetic SHA256 IOC, checks it is in the Bloom
+// filter, queryable in ClickHouse and reported by /check, then deletes it.
+// The outcome is exported as metrics and shown in /readyz.
+func (s *Server) runSelfTest(ctx context.Context) error {
+	start := time.Now()
+	step, err := s.selfTestRoundTrip(ctx)
+
+	// Clean up even after a failure or cancellation
+	cleanupCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if cleanupErr := s.ch.DeleteSelfTestIOCs(cleanupCtx); cleanupErr != nil && err == nil {
+		step, err = selfTestStepCleanup, cleanupErr
+	}
+
+	result := &selfTestResult{RanAt: time.Now(), Duration: time.Since(start)}
+	s.metrics.SelfTestLastRun.Set(float64(result.RanAt.Unix()))
+	if err != nil {
+		result.Step, result.Err = step, err
+		s.metrics.SelfTestPassed.Set(0)
+		s.metrics.SelfTestFailures.WithLabelValues(step).Inc()
+	} else {
+		s.metrics.SelfTestPassed.Set(1)
+	}
+	s.selfTest.Store(result)
+
+	if err != nil {
+		return fmt.Errorf("self-test %s step failed: %w", step, err)
+	}
+	return nil
+}
+
+// selfTestRoundTrip runs the self-test steps, returning the failed step
+func (s *Server) selfTestRoundTrip(ctx context.Context) (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return selfTestStepInsert, err
+	}
+	value := hex.EncodeToString(buf)
+
+	now := time.Now()
+	canary := models.IOC{
+		Value:         value,
+		Type:          models.IOCTypeSHA256,
+		SourceFileID:  db.SelfTestSourceID,
+		MalwareFamily: "SelfTest",
+		FirstSeen:     now,
+		LastSeen:      now,
+		Tags:          []string{db.SelfTestSourceID},
+	}
+	if err := s.ch.BatchInsertIOCs(ctx, []models.IOC{canary}); err != nil {
+		return selfTestStepInsert, err
+	}
+
+	if err := s.redis.BFMAdd(ctx, []string{value}); err != nil {
+		return selfTestStepBloom, err
+	}
+	exists, err := s.redis.BFMExists(ctx, []string{value})
+	if err != nil {
+		return selfTestStepBloom, err
+	}
+	if len(exists) != 1 || !exists[0] {
+		return selfTestStepBloom, errors.New("canary not in Bloom filter after insert")
+	}
+
+	found, err := s.ch.QueryIOCs(ctx, []string{value})
+	if err != nil {
+		return selfTestStepClickHouse, err
+	}
+	if len(found) == 0 {
+		return selfTestStepClickHouse, errors.New("canary not returned by ClickHouse")
+	}
+
+	if err := s.selfTestCheck(value); err != nil {
+		return selfTestStepCheck, err
+	}
+	return "", nil
+}
+
+// selfTestCheck looks the canary up through the full /check handler chain,
+// including authentication. Enrichment is skipped so no paid provider
+// lookups are spent on canaries.
+func (s *Server) selfTestCheck(value string) error {
+	body, err := json.Marshal(models.CheckRequest{IOCs: []string{value}})
+	if err != nil {
+		return err
+	}
+
+	apiKey := s.cfg.API.APIKey
+	if apiKey == "" {
+		apiKey = s.cfg.API.AdminAPIKey
+	}
+	if apiKey == "" {
+		apiKey = "selftest" // Any key is accepted when none is configured
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/check?enrich=false", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-API-Key", apiKey)
+	req.Header.Set(selfTestHeader, s.selfTestToken)
+
+	resp, err := s.app.Test(req, int(selfTestCheckTimeout.Milliseconds()))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("/check returned HTTP %d", resp.StatusCode)
+	}
+
+	var check models.CheckResponse
+	if err := json.NewDecoder(resp.Body).Decode(&check); err != nil {
+		return fmt.Errorf("invalid /check response: %w", err)
+	}
+	if check.Found != 1 || len(check.Results) != 1 || check.Results[0].SourceFileID != db.SelfTestSourceID {
+		return errors.New("/check did not report the canary")
+	}
+	return nil
+}
+
+// selfTestStatus describes the latest self-test run for /readyz
+func (s *Server) selfTestStatus() (string, int64, bool) {
+	result := s.selfTest.Load()
+	switch {
+	case s.cfg.API.SelfTestInterval <= 0:
+		return "not configured", 0, true
+	case result == nil:
+		return "pending", 0, true
+	case result.Err != nil:
+		return fmt.Sprintf("fail: %s: %v", result.Step, result.Err), result.Duration.Milliseconds(), false
+	default:
+		return "pass (" + time.Since(result.RanAt).Truncate(time.Second).String() + " ago)", result.Duration.Milliseconds(), true
+	}
+}
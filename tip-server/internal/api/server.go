@@ -0,0 +1,888 @@
+package api
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/compress"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/rs/zerolog/log"
+
+	"tip-server/internal/config"
+	"tip-server/internal/db"
+	"tip-server/internal/dga"
+	"tip-server/internal/embed"
+	"tip-server/internal/enrich"
+	"tip-server/internal/events"
+	"tip-server/internal/extractor"
+	"tip-server/internal/feeds"
+	"tip-server/internal/jobs"
+	"tip-server/internal/metrics"
+	"tip-server/internal/middleware"
+	"tip-server/internal/models"
+	"tip-server/internal/netutil"
+	"tip-server/internal/normalize"
+	"tip-server/internal/redact"
+	"tip-server/internal/signing"
+)
+
+// Server holds all dependencies for the API server
+type Server struct {
+	cfg     *config.Config
+	app     *fiber.App
+	ch      db.IOCStore
+	redis   db.Cache
+	minio   db.ObjectStore
+	qdrant  *db.QdrantClient
+	metrics *metrics.Metrics
+	jobs    *jobs.Scheduler
+
+	reloader  *config.Reloader
+	redirect  *http.Server // Plain HTTP redirect listener (TLS mode only)
+	bus       events.Publisher
+	redactor  *redact.Redactor  // nil unless EMAIL_REDACTION is set
+	signer    *signing.Signer   // nil unless FEED_SIGNING_KEY_FILE is set
+	enricher  *enrich.Enricher  // nil unless a reputation provider is configured
+	domainAge *enrich.DomainAge // nil unless WHOIS lookups are enabled
+	index     *embed.Index      // nil unless Qdrant is enabled and reachable
+	export    *jobs.ParquetExport
+	syncKey   ed25519.PublicKey // Key the primary's sync pages must be signed with (replica mode)
+
+	// Synthetic IOC round-trip (see selftest.go)
+	selfTest      atomic.Pointer[selfTestResult]
+	selfTestToken string
+	// Rebuilt from the extraction settings on each config reload
+	extractor atomic.Pointer[extractor.Extractor]
+	// CIDR indicators /check matches addresses against (see ranges.go)
+	ranges atomic.Pointer[netutil.PrefixTable[models.IOC]]
+	// ClickHouse lookups shared by concurrent /check requests (see coalesce.go)
+	flights lookupFlights
+	// Slots for background regex searches (see regex.go)
+	regexJobs chan struct{}
+}
+
+// NewServer creates an API server over shared storage connections. The
+// server reads its configuration from reloader.
+func NewServer(reloader *config.Reloader, clients *db.Clients) (*Server, error) {
+	cfg := reloader.Current()
+	extract, err := extractor.NewExtractorFromConfig(cfg.Extractor)
+	if err != nil {
+		return nil, err
+	}
+	ch, redis, minio, qdrant := clients.ClickHouse, clients.Redis, clients.MinIO, clients.Qdrant
+
+	// Similarity search needs Qdrant (optional)
+	index, err := embed.NewIndex(context.Background(), qdrant, cfg.Embedding)
+	if err != nil {
+		log.Warn().Err(err).Msg("Similarity index unavailable - /search/fuzzy is disabled")
+		index = nil
+	}
+
+	// Export feeds are signed when a key is configured
+	signer, err := signing.New(cfg.Signing)
+	if err != nil {
+		return nil, err
+	}
+	var syncKey ed25519.PublicKey
+	if cfg.Sync.VerifyKey != "" {
+		if syncKey, err = signing.ParsePublicKey(cfg.Sync.VerifyKey); err != nil {
+			return nil, fmt.Errorf("invalid SYNC_VERIFY_KEY: %w", err)
+		}
+	}
+
+	// Connect to the external event bus (no-op when not configured)
+	bus, err := events.NewPublisher(cfg.EventBus)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to event bus: %w", err)
+	}
+
+	// Create Fiber app
+	app := fiber.New(fiber.Config{
+		AppName:               "TIP API",
+		ReadTimeout:           30 * time.Second,
+		WriteTimeout:          30 * time.Second,
+		IdleTimeout:           120 * time.Second,
+		BodyLimit:             cfg.API.BodyLimit,
+		DisableStartupMessage: false,
+		ErrorHandler:          errorHandler,
+
+		// The proxy header is only read from trusted peers; anyone else
+		// is identified by their own address
+		ProxyHeader:             cfg.API.ProxyHeader,
+		EnableTrustedProxyCheck: true,
+		TrustedProxies:          cfg.API.TrustedProxies,
+	})
+
+	s := &Server{
+		cfg:     cfg,
+		app:     app,
+		ch:      ch,
+		redis:   redis,
+		minio:   minio,
+		qdrant:  qdrant,
+		metrics: metrics.GetMetrics(),
+		jobs:    jobs.NewScheduler(),
+
+		reloader:  reloader,
+		bus:       bus,
+		redactor:  redact.New(cfg.Redaction),
+		signer:    signer,
+		enricher:  enrich.New(cfg.Enrichment, redis),
+		domainAge: enrich.NewDomainAge(cfg.Enrichment, redis, ch),
+		index:     index,
+		export:    jobs.NewParquetExport(ch, minio, cfg.Export.Retention, signer),
+		syncKey:   syncKey,
+
+		selfTestToken: newSelfTestToken(),
+		regexJobs:     make(chan struct{}, cfg.API.RegexSearchMaxJobs),
+	}
+	s.extractor.Store(extract)
+	reloader.OnReload(s.reloadExtractor)
+	return s, nil
+}
+
+// reloadExtractor swaps in an extractor built from reloaded extraction
+// settings, re-reading the list files. On error the previous one is kept.
+func (s *Server) reloadExtractor(cfg *config.Config) {
+	extract, err := extractor.NewExtractorFromConfig(cfg.Extractor)
+	if err != nil {
+		log.Warn().Err(err).Msg("Failed to reload extraction settings, keeping previous ones")
+		return
+	}
+	s.extractor.Store(extract)
+}
+
+// Close waits for background jobs and closes the server's own connections.
+// The shared storage connections are left to their owner.
+func (s *Server) Close() {
+	s.jobs.Wait()
+	if s.redirect != nil {
+		s.redirect.Close()
+	}
+	if err := s.bus.Close(); err != nil {
+		log.Warn().Err(err).Msg("Failed to close event bus publisher")
+	}
+}
+
+// Shutdown stops serving requests, letting those in flight finish
+func (s *Server) Shutdown() error {
+	return s.app.Shutdown()
+}
+
+// SetupRoutes configures all API routes
+func (s *Server) SetupRoutes() {
+	// Global middleware
+	s.app.Use(middleware.MetricsMiddleware(s.metrics))
+	s.app.Use(middleware.RecoverMiddleware())
+	s.app.Use(middleware.CORSMiddleware())
+	s.app.Use(middleware.RequestLogger())
+	s.app.Use(middleware.NewIPFilterMiddleware(middleware.IPFilterConfig{
+		Redis:             s.redis,
+		RateLimitFunc:     func() int { return s.reloader.Current().API.IPRateLimit },
+		RateWindow:        time.Minute,
+		AuthFailureLimit:  s.cfg.API.AuthFailureLimit,
+		AuthFailureWindow: s.cfg.API.AuthFailureWindow,
+		BlockDuration:     s.cfg.API.AutoBlockDuration,
+	}))
+	if s.cfg.API.TLSEnabled() && s.cfg.API.HSTSMaxAge > 0 {
+		s.app.Use(middleware.HSTSMiddleware(s.cfg.API.HSTSMaxAge))
+	}
+	s.app.Use(compress.New(compress.Config{
+		// Event streams must be flushed incrementally, never buffered for compression
+		Next: func(c *fiber.Ctx) bool {
+			return strings.HasPrefix(c.Path(), "/stream/")
+		},
+	}))
+
+	// Authentication middleware (skip health and metrics)
+	authMiddleware := middleware.NewAuthMiddleware(middleware.AuthConfig{
+		APIKey:      s.cfg.API.APIKey,
+		AdminAPIKey: s.cfg.API.AdminAPIKey,
+		Redis:       s.redis,
+		RateWindow:  time.Minute,
+		SkipPaths:   []string{"/health", "/readyz", "/metrics"},
+
+		RateLimitFunc: func() int { return s.reloader.Current().API.RateLimit },
+	})
+
+	// Public endpoints
+	s.app.Get("/health", s.healthHandler)
+	s.app.Get("/readyz", s.readinessHandler)
+
+	// Protected endpoints
+	api := s.app.Group("/", authMiddleware, middleware.DecompressBody(s.cfg.API.MaxInflatedBody), middleware.RequireJSON(),
+		middleware.SignResponses(s.signer, "/sync/iocs"), middleware.TimeFormat(s.cfg.API.TimeFormat))
+	api.Post("/check", s.checkHandler)
+	api.Post("/v1/check", s.checkV1Handler)
+	api.Get("/capabilities", s.capabilitiesHandler)
+	api.Get("/context/:file_id", s.contextHandler)
+	api.Get("/context/:file_id/iocs", s.fileIOCsHandler)
+	api.Get("/files/:file_id/history", s.fileHistoryHandler)
+	api.Get("/stats", s.statsHandler)
+	api.Get("/stats/top", s.topHandler)
+	api.Get("/whois/related", s.whoisRelatedHandler)
+	api.Get("/stream/ingestion", s.ingestionStreamHandler)
+	api.Get("/stream/matches", s.matchStreamHandler)
+
+	// Admin endpoints
+	api.Delete("/ioc/*", middleware.RequireAdmin(), s.deleteIOCHandler)
+	api.Post("/iocs/bulk-update", middleware.RequireAdmin(), s.bulkUpdateHandler)
+
+	// Analyst review of low-confidence extractions
+	api.Get("/review", s.reviewQueueHandler)
+	api.Post("/review/approve", middleware.RequireAdmin(), s.reviewApproveHandler)
+	api.Post("/review/reject", middleware.RequireAdmin(), s.reviewRejectHandler)
+
+	// Labeling of sampled extractions and the precision they estimate
+	api.Get("/samples", s.samplesHandler)
+	api.Get("/samples/precision", s.samplePrecisionHandler)
+	api.Post("/samples/label", middleware.RequireAdmin(), s.labelSamplesHandler)
+	api.Get("/sync/iocs", middleware.RequireAdmin(), s.syncHandler)
+	api.Get("/signing/key", s.signingKeyHandler)
+
+	// Consumer subscriptions to newly ingested IOCs
+	api.Post("/subscriptions", s.createSubscriptionHandler)
+	api.Get("/subscriptions", s.listSubscriptionsHandler)
+	api.Get("/subscriptions/:id", s.getSubscriptionHandler)
+	api.Delete("/subscriptions/:id", s.deleteSubscriptionHandler)
+
+	admin := api.Group("/admin", middleware.RequireAdmin())
+	admin.Get("/blocklist", s.listBlockedIPsHandler)
+	admin.Post("/blocklist", s.blockIPHandler)
+	admin.Delete("/blocklist/:ip", s.unblockIPHandler)
+	admin.Post("/export", s.exportHandler)
+	admin.Post("/import", s.importHandler)
+	admin.Post("/ingest/run", s.ingestRunHandler)
+	admin.Post("/files/reprocess", s.reprocessHandler)
+	admin.Post("/delete", s.deleteHandler)
+	admin.Get("/files/duplicates", s.duplicatesHandler)
+	admin.Get("/consistency", s.consistencyHandler)
+	admin.Get("/volume", s.volumeHandler)
+	admin.Post("/reveal", s.revealHandler)
+
+	// Partial-value search over stored indicators
+	api.Get("/search", s.searchHandler)
+	api.Post("/search/regex", s.regexSearchHandler)
+	api.Get("/search/regex/:id", s.regexJobHandler)
+
+	// Similarity search and clustering over file content
+	api.Post("/search/fuzzy", s.fuzzySearchHandler)
+	api.Get("/clusters", s.clustersHandler)
+
+	api.Post("/search/typosquat", s.typosquatHandler)
+}
+
+// JobSet selects the background jobs a server runs
+type JobSet int
+
+const (
+	// ServingJobs keep this process's lookups current: the self-test,
+	// list refresh and IP range refresh. They run wherever the API serves.
+	ServingJobs JobSet = 1 << iota
+	// MaintenanceJobs work on the shared stores: cleanup, Bloom rebuild
+	// and snapshots, DNS resolution, clustering, export, consistency and
+	// ingestion volume checks, replica sync, subscription delivery and
+	// event bus submissions. One process of a deployment runs them.
+	MaintenanceJobs
+
+	AllJobs = ServingJobs | MaintenanceJobs
+)
+
+// StartJobs registers and starts periodic background jobs
+func (s *Server) StartJobs(ctx context.Context, set JobSet) {
+	if set&MaintenanceJobs != 0 {
+		s.jobs.Register("minio_orphan_cleanup", s.cfg.MinIO.OrphanCleanupInterval,
+			jobs.NewOrphanCleanup(s.ch, s.minio, s.cfg.MinIO.OrphanGracePeriod))
+		s.jobs.Register("bloom_rebuild", s.cfg.Redis.BloomRebuildInterval,
+			jobs.NewBloomRebuild(s.ch, s.redis))
+		if s.cfg.Redis.BloomSnapshotRestore {
+			s.jobs.Go(ctx, "bloom_snapshot_restore",
+				jobs.NewBloomSnapshotRestore(s.ch, s.redis, s.minio, s.cfg.Redis.BloomFilterName))
+		}
+		s.jobs.Register("bloom_snapshot", s.cfg.Redis.BloomSnapshotInterval,
+			jobs.NewBloomSnapshot(s.redis, s.minio, s.cfg.Redis.BloomFilterName))
+		s.jobs.Register("dns_resolution", s.cfg.DNS.ResolveInterval,
+			jobs.NewDNSResolution(s.ch, s.redis, s.cfg.DNS))
+		if s.index != nil {
+			s.jobs.Register("vector_clustering", s.cfg.Cluster.Interval,
+				jobs.NewVectorClustering(s.index, s.ch, s.redis, s.cfg.Cluster))
+		}
+		s.jobs.Register("parquet_export", s.cfg.Export.Interval, s.export.Job())
+		s.jobs.Register("consistency_check", s.cfg.Consistency.Interval,
+			jobs.NewConsistencyCheck(s.ch, s.redis, s.minio, s.cfg.Consistency))
+		s.jobs.Register("volume_check", s.cfg.Volume.Interval,
+			jobs.NewVolumeCheck(s.redis, s.cfg.Volume))
+		if s.cfg.Sync.PrimaryURL != "" {
+			s.jobs.Register("replica_sync", s.cfg.Sync.Interval,
+				jobs.NewReplicaSync(s.ch, s.redis, s.cfg.Sync, s.redactor, s.syncKey))
+		}
+		if s.cfg.Subscriptions.MaxPerKey > 0 {
+			s.jobs.Go(ctx, "subscription_delivery",
+				jobs.NewSubscriptionDelivery(s.redis, s.bus, s.cfg.Subscriptions, s.signer))
+		}
+		s.startSubmissionConsumer(ctx)
+	}
+
+	if set&ServingJobs != 0 {
+		s.jobs.Register("self_test", s.cfg.API.SelfTestInterval, s.runSelfTest)
+		s.jobs.Register("extract_list_refresh", s.cfg.Extractor.ListRefreshInterval, s.refreshLists)
+		if s.cfg.API.RangeRefreshInterval > 0 {
+			s.jobs.Go(ctx, "ip_range_initial_load", s.refreshRanges)
+			s.jobs.Register("ip_range_refresh", s.cfg.API.RangeRefreshInterval, s.refreshRanges)
+		}
+	}
+
+	s.jobs.Start(ctx)
+}
+
+// StartMetricsServer starts the Prometheus metrics server
+func (s *Server) StartMetricsServer() {
+	addr := fmt.Sprintf(":%d", s.cfg.Metrics.Port)
+	log.Info().Str("addr", addr).Msg("Starting metrics server")
+
+	http.Handle("/metrics", promhttp.Handler())
+	if err := http.ListenAndServe(addr, nil); err != nil {
+		log.Error().Err(err).Msg("Metrics server failed")
+	}
+}
+
+// ========== Handlers ==========
+
+// healthHandler returns service health status
+func (s *Server) healthHandler(c *fiber.Ctx) error {
+	return c.JSON(models.HealthResponse{
+		Status:    "healthy",
+		Timestamp: time.Now().UTC().Format(time.RFC3339),
+		Components: map[string]string{
+			"api": "up",
+		},
+	})
+}
+
+// readinessHandler checks if all dependencies are ready.
+// ClickHouse is required for lookups; losing Redis (Bloom filter) or MinIO
+// (context retrieval) only degrades the service, so it stays routable.
+func (s *Server) readinessHandler(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	components := make(map[string]string)
+	latency := make(map[string]int64)
+
+	probe := func(name string, ping func(context.Context) error) bool {
+		start := time.Now()
+		err := ping(ctx)
+		latency[name] = time.Since(start).Milliseconds()
+		if err != nil {
+			components[name] = "down: " + err.Error()
+			return false
+		}
+		components[name] = "up"
+		return true
+	}
+
+	clickhouseUp := probe("clickhouse", s.ch.Ping)
+	redisUp := probe("redis", s.redis.Ping)
+	minioUp := probe("minio", s.minio.Ping)
+
+	// Check Qdrant (optional)
+	if s.qdrant != nil && s.qdrant.IsInitialized() {
+		components["qdrant"] = "up"
+	} else {
+		components["qdrant"] = "not configured"
+	}
+
+	// Latest synthetic IOC round-trip
+	selfTest, selfTestMs, selfTestOK := s.selfTestStatus()
+	components["selftest"] = selfTest
+	if selfTestMs > 0 {
+		latency["selftest"] = selfTestMs
+	}
+
+	status := "ready"
+	statusCode := fiber.StatusOK
+	switch {
+	case !clickhouseUp:
+		status = "not ready"
+		statusCode = fiber.StatusServiceUnavailable
+	case !redisUp || !minioUp || !selfTestOK:
+		status = "degraded"
+	}
+
+	return c.Status(statusCode).JSON(models.HealthResponse{
+		Status:     status,
+		Timestamp:  time.Now().UTC().Format(time.RFC3339),
+		Components: components,
+		LatencyMs:  latency,
+	})
+}
+
+// checkMaxIOCs is the maximum number of IOCs in one /check request
+const checkMaxIOCs = 1000
+
+// checkHandler handles IOC lookup requests
+func (s *Server) checkHandler(c *fiber.Ctx) error {
+	return s.check(c, false)
+}
+
+// checkV1Handler handles IOC lookup requests whose matches carry the IOC's
+// aggregated attributes as well (last_seen, tags, hit_count, vector_id)
+func (s *Server) checkV1Handler(c *fiber.Ctx) error {
+	return s.check(c, true)
+}
+
+// check looks up the IOCs of a /check request, with the v1 result fields
+// when extended is set
+func (s *Server) check(c *fiber.Ctx, extended bool) error {
+	startTime := time.Now()
+
+	// Parse request
+	var req models.CheckRequest
+	if err := middleware.ParseJSONStrict(c, &req); err != nil {
+		return middleware.Problem(c, fiber.StatusBadRequest, models.ErrCodeInvalidRequest,
+			"Invalid request body", err.Error())
+	}
+
+	if len(req.IOCs) == 0 {
+		return middleware.Problem(c, fiber.StatusBadRequest, models.ErrCodeNoIOCs,
+			"No IOCs provided", "")
+	}
+
+	if len(req.IOCs) > checkMaxIOCs {
+		return middleware.Problem(c, fiber.StatusBadRequest, models.ErrCodeIOCLimitExceeded,
+			"Too many IOCs", fmt.Sprintf("Maximum %d IOCs per request", checkMaxIOCs))
+	}
+
+	if err := middleware.ValidateIndicators(req.IOCs, s.cfg.API.MaxIOCLength); err != nil {
+		return middleware.Problem(c, fiber.StatusBadRequest, models.ErrCodeInvalidIOC,
+			"Invalid IOC", err.Error())
+	}
+
+	window, err := parseCheckWindow(c)
+	if err != nil {
+		return middleware.Problem(c, fiber.StatusBadRequest, models.ErrCodeInvalidParameter,
+			"Invalid query parameter", err.Error())
+	}
+	maxSources, err := s.parseMaxSources(c)
+	if err != nil {
+		return middleware.Problem(c, fiber.StatusBadRequest, models.ErrCodeInvalidParameter,
+			"Invalid query parameter", err.Error())
+	}
+
+	ctx := context.Background()
+
+	// Look values up in the form the extractor stores them, so casing,
+	// defanging or IPv6 spelling cannot cause a miss, with email addresses
+	// redacted as they are at rest
+	values := make([]string, len(req.IOCs))
+	for i, v := range req.IOCs {
+		values[i] = s.redactor.Value(normalize.Value(v))
+	}
+
+	// Steps 1-2: Bloom filter, lookup cache and ClickHouse
+	lookup := s.lookupIOCs(ctx, values)
+	if !lookup.queryOK && !lookup.bloomOK {
+		return middleware.Problem(c, fiber.StatusServiceUnavailable, models.ErrCodeStorageUnavailable,
+			"IOC store unavailable", "Neither ClickHouse nor the Bloom filter answered")
+	}
+
+	// IOCs awaiting analyst review are left out unless asked for
+	withheld := lookup.withholdPending(c.QueryBool("include_pending", false))
+
+	// Known IOCs not seen within since/until do not count as matches
+	outside := lookup.withholdOutside(window)
+
+	// Operator allow/deny lists override the store
+	foundMap, listed := s.applyLists(values, lookup.found)
+
+	results := make([]models.IOCResult, len(req.IOCs))
+	foundCount := 0
+
+	for i, ioc := range values {
+		result := models.IOCResult{
+			IOC:           ioc,
+			Found:         false,
+			List:          listed[ioc],
+			OutsideWindow: outside[ioc],
+		}
+		if req.IOCs[i] != ioc {
+			result.Input = req.IOCs[i]
+		}
+
+		if found, ok := foundMap[ioc]; ok {
+			result.Found = true
+			result.Type = found.Type
+			result.SourceFileID = found.SourceFileID
+			result.MalwareFamily = found.MalwareFamily
+			result.Confidence = found.Confidence
+			result.Pending = found.Pending
+			result.DGAScore = found.DGAScore
+			if found.Type == models.IOCTypeDomain && found.DGAScore == 0 {
+				// Stored before scoring was introduced
+				result.DGAScore = dga.Score(ioc)
+			}
+			if !found.FirstSeen.IsZero() {
+				result.FirstSeen = found.FirstSeen.Format(time.RFC3339)
+			}
+			if extended {
+				if !found.LastSeen.IsZero() {
+					result.LastSeen = found.LastSeen.Format(time.RFC3339)
+				}
+				result.Tags = found.Tags
+				result.HitCount = found.HitCount
+				result.VectorID = found.VectorID
+			}
+			foundCount++
+		}
+
+		results[i] = result
+	}
+
+	// Down-weight matches on popular domains
+	s.flagPopular(results)
+
+	// Addresses inside stored CIDR blocks
+	foundCount += s.matchRanges(results, window, extended)
+
+	// Without ClickHouse the Bloom filter is the best answer there is:
+	// report its passes as probable rather than as misses
+	probableCount := 0
+	if !lookup.queryOK {
+		probableCount = s.markProbable(results, lookup.unconfirmed)
+		s.metrics.CheckDegraded.Inc()
+	}
+
+	// Outcomes are only meaningful when the store answered
+	selfTest := s.isSelfTest(c)
+	if lookup.queryOK && !selfTest {
+		s.recordCheckOutcomes(results, lookup.bloomOK, lookup.bloom, withheld)
+	}
+
+	// Every source of each match, not just the newest (?max_sources=0 skips)
+	if lookup.queryOK && maxSources > 0 && foundCount > 0 {
+		s.attachSources(ctx, results, lookup.found, maxSources)
+	}
+
+	// Flag matched domains that no longer resolve or point at a sinkhole
+	if s.cfg.DNS.ResolveInterval > 0 && foundCount > 0 {
+		s.attachDNSStatus(ctx, results)
+	}
+
+	// Step 3: External reputation and domain age for matches (opt out with ?enrich=false)
+	if (s.enricher != nil || s.domainAge != nil) && foundCount > 0 && c.QueryBool("enrich", true) {
+		enrichStart := time.Now()
+		s.enrichResults(results, foundMap)
+		lookup.stages.Enrich = time.Since(enrichStart).String()
+	}
+
+	if foundCount > 0 && !selfTest {
+		s.publishCheckHits(values, foundMap)
+	}
+
+	queryTime := time.Since(startTime)
+	if !selfTest {
+		s.logQuery(c, values, foundMap, queryTime)
+	}
+
+	status := fiber.StatusOK
+	if !lookup.queryOK {
+		status = fiber.StatusPartialContent
+	}
+
+	return c.Status(status).JSON(models.CheckResponse{
+		Results:      results,
+		Total:        len(req.IOCs),
+		Found:        foundCount,
+		NotFound:     len(req.IOCs) - foundCount - probableCount,
+		Cached:       lookup.cached,
+		CachedMisses: lookup.cachedMisses,
+		QueryTime:    queryTime.String(),
+		Stages:       lookup.stages,
+		Degraded:     !lookup.queryOK,
+		Probable:     probableCount,
+
+		OutsideWindow: len(outside),
+	})
+}
+
+// markProbable flags unmatched results whose ClickHouse lookup failed after
+// the Bloom filter passed them, and returns how many it flagged
+func (s *Server) markProbable(results []models.IOCResult, unconfirmed map[string]bool) int {
+	n := 0
+	for i := range results {
+		r := &results[i]
+		if r.Found || r.List != "" || !unconfirmed[r.IOC] {
+			continue
+		}
+		r.Probable = true
+		if t, _, ok := s.extractor.Load().DetectType(r.IOC); ok {
+			r.Type = t
+		}
+		n++
+	}
+	return n
+}
+
+// recordCheckOutcomes counts found/not-found lookups by type and Bloom filter
+// false positives (values the filter passed that ClickHouse did not hold)
+func (s *Server) recordCheckOutcomes(results []models.IOCResult, bloomOK bool, bloomResults []bool, withheld map[string]bool) {
+	for i, r := range results {
+		iocType := string(r.Type)
+		if !r.Found {
+			iocType = "unknown"
+			if t, _, ok := s.extractor.Load().DetectType(r.IOC); ok {
+				iocType = string(t)
+			}
+			if bloomOK && bloomResults[i] && !withheld[r.IOC] && !r.OutsideWindow {
+				s.metrics.BloomFalsePositives.Inc()
+			}
+		}
+		s.metrics.RecordCheckOutcome(iocType, r.Found)
+	}
+}
+
+// contextHandler streams file content from MinIO
+func (s *Server) contextHandler(c *fiber.Ctx) error {
+	fileID := c.Params("file_id")
+	if fileID == "" {
+		return middleware.Problem(c, fiber.StatusBadRequest, models.ErrCodeInvalidParameter,
+			"Missing file_id", "")
+	}
+
+	ctx := context.Background()
+
+	// Get file metadata from ClickHouse
+	meta, err := s.ch.GetFileMetadata(ctx, fileID)
+	if err != nil {
+		return middleware.Problem(c, fiber.StatusNotFound, models.ErrCodeNotFound,
+			"File not found", fileID)
+	}
+
+	// Check if file is in MinIO
+	minioKey := meta.MinIOKey
+	if minioKey == "" {
+		minioKey = fileID // Fallback to file_id as key
+	}
+
+	// Malware samples are only released to admins who explicitly ask for them
+	quarantined := db.IsQuarantineKey(minioKey)
+	if quarantined {
+		if problem, ok := s.authorizeQuarantineDownload(c, meta); !ok {
+			return middleware.SendProblem(c, problem)
+		}
+	}
+
+	// Get object from MinIO (decompressed transparently)
+	reader, info, size, err := s.minio.OpenObject(ctx, minioKey)
+	if err != nil {
+		return middleware.Problem(c, fiber.StatusNotFound, models.ErrCodeStorageMiss,
+			"File content not available", "File may not have been stored in object storage")
+	}
+	defer reader.Close()
+
+	// Set headers
+	c.Set("Content-Type", info.ContentType)
+	if quarantined {
+		c.Set("Content-Type", "application/octet-stream")
+		c.Set("X-Quarantined", "true")
+	}
+	c.Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", fileID))
+	c.Set("X-File-ID", fileID)
+	c.Set("X-Original-Path", meta.FilePath)
+	if meta.Language != "" {
+		c.Set("Content-Language", meta.Language)
+	}
+
+	// Read the whole object so the checksum is known before headers are
+	// sent; fasthttp buffers the response body either way
+	hasher := sha256.New()
+	body, err := io.ReadAll(io.TeeReader(reader, hasher))
+	if err != nil {
+		log.Error().Err(err).Str("file_id", fileID).Msg("Failed to read file content")
+		return middleware.Problem(c, fiber.StatusInternalServerError, models.ErrCodeStorageUnavailable,
+			"Failed to read file content", "")
+	}
+	if size >= 0 && int64(len(body)) != size {
+		log.Warn().Str("file_id", fileID).Int64("expected", size).Int("actual", len(body)).Msg("Stored file size mismatch")
+	}
+
+	// Objects uploaded before checksums were recorded fall back to the
+	// registry's content hash
+	expected := info.UserMetadata[db.MetaContentSHA256]
+	if expected == "" {
+		expected = meta.ContentHash
+	}
+	actual := hex.EncodeToString(hasher.Sum(nil))
+	c.Set("X-Content-SHA256", actual)
+	switch {
+	case expected == "":
+		c.Set("X-Integrity-Status", integrityUnverified)
+	case strings.EqualFold(expected, actual):
+		c.Set("X-Integrity-Status", integrityVerified)
+	default:
+		c.Set("X-Integrity-Status", integrityMismatch)
+		c.Set("X-Integrity-Error", "sha256 mismatch: expected "+expected)
+		log.Error().
+			Str("file_id", fileID).
+			Str("object", minioKey).
+			Str("expected", expected).
+			Str("actual", actual).
+			Msg("Stored file failed integrity check")
+	}
+
+	return c.Send(body)
+}
+
+// Values of the X-Integrity-Status header on /context responses
+const (
+	integrityVerified   = "verified"
+	integrityMismatch   = "mismatch"
+	integrityUnverified = "unverified" // No recorded checksum
+)
+
+// quarantineConfirmation is the confirm query value required to download a
+// quarantined sample
+const quarantineConfirmation = "quarantined"
+
+// authorizeQuarantineDownload checks that the caller is an admin who has
+// confirmed the download, and records it in the audit log. On refusal it
+// returns the error to send.
+func (s *Server) authorizeQuarantineDownload(c *fiber.Ctx, meta *models.FileMetadata) (models.Problem, bool) {
+	if role, _ := c.Locals("role").(string); role != middleware.RoleAdmin {
+		log.Warn().
+			Str("ip", c.IP()).
+			Str("file_id", meta.FileID).
+			Msg("Quarantined file access denied")
+		return models.NewProblem(fiber.StatusForbidden, models.ErrCodeAdminRequired,
+			"Admin privileges required", "File is a quarantined malware sample"), false
+	}
+
+	if c.Query("confirm") != quarantineConfirmation {
+		return models.NewProblem(fiber.StatusPreconditionRequired, models.ErrCodeConfirmationRequired,
+			"Confirmation required", fmt.Sprintf("File is a quarantined malware sample; repeat the request with ?confirm=%s to download it", quarantineConfirmation)), false
+	}
+
+	actor, _ := c.Locals("api_key_hash").(string)
+	entry := models.AuditEntry{
+		Timestamp: time.Now().UTC(),
+		Action:    models.AuditActionQuarantineDownload,
+		IOCValue:  meta.ContentHash,
+		Actor:     actor,
+		Reason:    meta.FilePath,
+		ClientIP:  c.IP(),
+	}
+	if err := s.ch.InsertAuditEntry(context.Background(), entry); err != nil {
+		log.Error().Err(err).Str("file_id", meta.FileID).Msg("Failed to write audit entry")
+	}
+
+	log.Info().
+		Str("file_id", meta.FileID).
+		Str("actor", actor).
+		Msg("Quarantined file downloaded")
+	return models.Problem{}, true
+}
+
+// statsFeedLimit bounds the feed documents listed in /stats freshness
+const statsFeedLimit = 100
+
+// statsHandler returns system statistics
+func (s *Server) statsHandler(c *fiber.Ctx) error {
+	ctx := context.Background()
+
+	// Get IOC stats
+	iocStats, err := s.ch.GetIOCStats(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to get IOC stats")
+	}
+
+	// Get file stats
+	fileStats, err := s.ch.GetFileStats(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to get file stats")
+	}
+
+	// Get corpus freshness
+	freshness, err := s.ch.GetFreshnessStats(ctx, feeds.Formats(), statsFeedLimit)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to get freshness stats")
+	}
+
+	// Get Bloom filter info
+	var bloomInfo map[string]interface{}
+	if info, err := s.redis.BFInfo(ctx); err == nil {
+		bloomInfo = map[string]interface{}{
+			"capacity":       info.Capacity,
+			"size":           info.Size,
+			"items_inserted": info.ItemsInserted,
+			"expansion_rate": info.ExpansionRate,
+		}
+
+		s.metrics.UpdateBloomFilterStats(info.Size, info.ItemsInserted)
+	}
+
+	return c.JSON(fiber.Map{
+		"ioc_stats":         iocStats,
+		"file_stats":        fileStats,
+		"freshness":         freshness,
+		"bloom_filter_info": bloomInfo,
+		"timestamp":         time.Now().UTC().Format(time.RFC3339),
+	})
+}
+
+// splitCSV splits a comma-separated query value, dropping empty entries
+func splitCSV(value string) []string {
+	var parts []string
+	for _, p := range strings.Split(value, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			parts = append(parts, p)
+		}
+	}
+	return parts
+}
+
+// errorHandler handles Fiber errors
+func errorHandler(c *fiber.Ctx, err error) error {
+	code := fiber.StatusInternalServerError
+	message := "Internal server error"
+
+	if e, ok := err.(*fiber.Error); ok {
+		code = e.Code
+		message = e.Message
+	}
+
+	log.Error().
+		Err(err).
+		Int("code", code).
+		Str("path", c.Path()).
+		Msg("Request error")
+
+	return middleware.Problem(c, code, errorCodeForStatus(code), message, "")
+}
+
+// errorCodeForStatus maps the status of an error raised by Fiber itself
+// (unknown route, oversized body, ...) to a problem code
+func errorCodeForStatus(status int) string {
+	switch {
+	case status == fiber.StatusNotFound:
+		return models.ErrCodeNotFound
+	case status == fiber.StatusMethodNotAllowed:
+		return models.ErrCodeMethodNotAllowed
+	case status == fiber.StatusRequestEntityTooLarge:
+		return models.ErrCodeBodyTooLarge
+	case status == fiber.StatusUnsupportedMediaType:
+		return models.ErrCodeUnsupportedMediaType
+	case status < fiber.StatusInternalServerError:
+		return models.ErrCodeInvalidRequest
+	default:
+		return models.ErrCodeInternal
+	}
+}
@@ -0,0 +1,237 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"tip-server/internal/config"
+	"tip-server/internal/db"
+	"tip-server/internal/fixtures"
+	"tip-server/internal/memstore"
+	"tip-server/internal/models"
+)
+
+const (
+	testAPIKey   = "test-api-key"
+	testAdminKey = "test-admin-key"
+)
+
+// newTestServer returns a server over in-memory backends seeded with the
+// fixture IOCs, with its routes set up
+func newTestServer(t *testing.T) (*Server, *db.Clients) {
+	t.Helper()
+	t.Setenv("CONFIG_FILE", filepath.Join(t.TempDir(), ".env"))
+	t.Setenv("API_KEY", testAPIKey)
+	t.Setenv("ADMIN_API_KEY", testAdminKey)
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clients := memstore.NewClients()
+	if err := fixtures.Seed(context.Background(), clients, fixtures.IOCs(time.Now().UTC())); err != nil {
+		t.Fatal(err)
+	}
+	s, err := NewServer(config.NewReloader(cfg), clients)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(s.Close)
+	s.SetupRoutes()
+	return s, clients
+}
+
+// request sends a JSON request with apiKey, decodes a JSON response into
+// out (if non-nil) and returns the status
+func request(t *testing.T, s *Server, method, path, apiKey string, body, out any) int {
+	t.Helper()
+	var reader io.Reader
+	if body != nil {
+		raw, err := json.Marshal(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reader = bytes.NewReader(raw)
+	}
+	req := httptest.NewRequest(method, path, reader)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-API-Key", apiKey)
+
+	resp, err := s.app.Test(req, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	raw, _ := io.ReadAll(resp.Body)
+	if out != nil && resp.StatusCode < 300 {
+		if err := json.Unmarshal(raw, out); err != nil {
+			t.Fatalf("%s %s: %v (%s)", method, path, err, raw)
+		}
+	}
+	return resp.StatusCode
+}
+
+// check posts values to path and returns the response
+func check(t *testing.T, s *Server, path string, values ...string) models.CheckResponse {
+	t.Helper()
+	var resp models.CheckResponse
+	if status := request(t, s, "POST", path, testAPIKey, models.CheckRequest{IOCs: values}, &resp); status != 200 {
+		t.Fatalf("POST %s = %d, want 200", path, status)
+	}
+	if len(resp.Results) != len(values) {
+		t.Fatalf("POST %s returned %d results, want %d", path, len(resp.Results), len(values))
+	}
+	return resp
+}
+
+// waitCached waits for /check to write value to the lookup cache, which it
+// does in the background
+func waitCached(t *testing.T, clients *db.Clients, value string) {
+	t.Helper()
+	for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+		if cached, _ := clients.Redis.GetCachedIOCs(context.Background(), []string{value}); len(cached) == 1 {
+			return
+		}
+	}
+	t.Fatalf("%s was not written to the lookup cache", value)
+}
+
+// auditEntries returns the recorded audit entries of action
+func auditEntries(t *testing.T, clients *db.Clients, action string) []models.AuditEntry {
+	t.Helper()
+	store, ok := clients.ClickHouse.(*memstore.IOCStore)
+	if !ok {
+		t.Fatalf("IOC store is %T, want the in-memory store", clients.ClickHouse)
+	}
+	var entries []models.AuditEntry
+	for _, entry := range store.AuditEntries() {
+		if entry.Action == action {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+func TestCheck(t *testing.T) {
+	s, clients := newTestServer(t)
+
+	resp := check(t, s, "/check", "UPDATE-CHECKER-CDN[.]NET", "203.0.113.77", "never-seen.example")
+	if resp.Total != 3 || resp.Found != 2 || resp.NotFound != 1 {
+		t.Errorf("total/found/not_found = %d/%d/%d, want 3/2/1", resp.Total, resp.Found, resp.NotFound)
+	}
+
+	domain := resp.Results[0]
+	if !domain.Found || domain.IOC != "update-checker-cdn.net" || domain.Input != "UPDATE-CHECKER-CDN[.]NET" {
+		t.Errorf("defanged input = %+v, want a match on the canonical value", domain)
+	}
+	if domain.Type != models.IOCTypeDomain || domain.Confidence != 85 || domain.MalwareFamily != "FixtureLoader" ||
+		domain.SourceFileID != fixtures.SourceFileID {
+		t.Errorf("match = %+v, want the stored attributes", domain)
+	}
+	if ip := resp.Results[1]; !ip.Found || ip.Type != models.IOCTypeIPv4 {
+		t.Errorf("ip = %+v, want a match", ip)
+	}
+	if miss := resp.Results[2]; miss.Found {
+		t.Errorf("never-seen value = %+v, want not found", miss)
+	}
+
+	// Misses are in the negative cache by the time the response is sent,
+	// and matches reach the lookup cache shortly after
+	probe := clients.Redis.ProbeLookups(context.Background(), []string{"never-seen.example"}, false, true)
+	if !probe.Misses["never-seen.example"] {
+		t.Error("miss was not written to the negative cache before responding")
+	}
+	waitCached(t, clients, "update-checker-cdn.net")
+	if again := check(t, s, "/check", "update-checker-cdn.net"); again.Cached != 1 || !again.Results[0].Found {
+		t.Errorf("repeated lookup = %+v, want a cached match", again)
+	}
+}
+
+func TestCheckRejects(t *testing.T) {
+	s, _ := newTestServer(t)
+
+	tests := []struct {
+		name   string
+		apiKey string
+		body   any
+		status int
+	}{
+		{"no API key", "", models.CheckRequest{IOCs: []string{"203.0.113.77"}}, 401},
+		{"wrong API key", "wrong", models.CheckRequest{IOCs: []string{"203.0.113.77"}}, 401},
+		{"no IOCs", testAPIKey, models.CheckRequest{}, 400},
+		{"too many IOCs", testAPIKey, models.CheckRequest{IOCs: make([]string, checkMaxIOCs+1)}, 400},
+		{"unknown field", testAPIKey, map[string]any{"iocs": []string{"203.0.113.77"}, "extra": true}, 400},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if status := request(t, s, "POST", "/check", tt.apiKey, tt.body, nil); status != tt.status {
+				t.Errorf("status = %d, want %d", status, tt.status)
+			}
+		})
+	}
+}
+
+func TestCheckV1Fields(t *testing.T) {
+	s, _ := newTestServer(t)
+
+	tests := []struct {
+		path     string
+		extended bool
+	}{
+		{"/check", false},
+		{"/v1/check", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			r := check(t, s, tt.path, "update-checker-cdn.net").Results[0]
+			if !r.Found || r.FirstSeen == "" {
+				t.Fatalf("result = %+v, want a match with first_seen", r)
+			}
+			if got := r.LastSeen != "" && slices.Contains(r.Tags, "fixture"); got != tt.extended {
+				t.Errorf("last_seen = %q, tags = %v, want them only on /v1/check", r.LastSeen, r.Tags)
+			}
+		})
+	}
+}
+
+func TestProxyHeader(t *testing.T) {
+	const client = "198.51.100.9"
+
+	tests := []struct {
+		name    string
+		trusted string // TRUSTED_PROXIES; test requests come from 0.0.0.0
+		blocked bool   // The forwarded address is the one filtered
+	}{
+		{"trusted proxy", "0.0.0.0", true},
+		{"trusted block", "0.0.0.0/8", true},
+		{"untrusted peer", "192.0.2.1", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("PROXY_HEADER", "X-Forwarded-For")
+			t.Setenv("TRUSTED_PROXIES", tt.trusted)
+			s, clients := newTestServer(t)
+			if err := clients.Redis.BlockIP(context.Background(), models.IPBlock{IP: client, Reason: "test"}, 0); err != nil {
+				t.Fatal(err)
+			}
+
+			req := httptest.NewRequest("GET", "/health", nil)
+			req.Header.Set("X-Forwarded-For", client)
+			resp, err := s.app.Test(req, -1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if blocked := resp.StatusCode == 403; blocked != tt.blocked {
+				t.Errorf("status = %d, want blocked = %v", resp.StatusCode, tt.blocked)
+			}
+		})
+	}
+}
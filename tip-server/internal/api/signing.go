@@ -0,0 +1,19 @@
+package api
+
+import (
+	"github.com/gofiber/fiber/v2"
+
+	"tip-server/internal/middleware"
+	"tip-server/internal/models"
+)
+
+// signingKeyHandler returns the public key export feeds are signed with, as
+// a raw Ed25519 key, a JWK and a minisign public key. Consumers should pin
+// it out of band rather than trust what an intermediary relays.
+func (s *Server) signingKeyHandler(c *fiber.Ctx) error {
+	if !s.signer.Enabled() {
+		return middleware.Problem(c, fiber.StatusServiceUnavailable, models.ErrCodeFeatureUnavailable,
+			"Feed signing is off", "Export feeds are signed once FEED_SIGNING_KEY_FILE is set")
+	}
+	return c.JSON(s.signer.PublicKey())
+}
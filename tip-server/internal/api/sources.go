@@ -0,0 +1,55 @@
+package api
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/rs/zerolog/log"
+
+	"tip-server/internal/models"
+)
+
+// checkMaxSourcesLimit bounds ?max_sources= (and CHECK_MAX_SOURCES)
+const checkMaxSourcesLimit = 100
+
+// parseMaxSources reads the max_sources query parameter of /check, falling
+// back to CHECK_MAX_SOURCES
+func (s *Server) parseMaxSources(c *fiber.Ctx) (int, error) {
+	if c.Query("max_sources") == "" {
+		return s.cfg.API.CheckMaxSources, nil
+	}
+	n, ok := queryNonNegativeInt(c, "max_sources")
+	if !ok || n > checkMaxSourcesLimit {
+		return 0, fmt.Errorf("max_sources must be between 0 and %d", checkMaxSourcesLimit)
+	}
+	return n, nil
+}
+
+// attachSources lists the sources of store matches on their results.
+// Denylist verdicts and CIDR hits have no stored rows of their own and are
+// left with source_file_id alone.
+func (s *Server) attachSources(ctx context.Context, results []models.IOCResult, found map[string]models.IOC, limit int) {
+	var values []string
+	for _, r := range results {
+		if _, ok := found[r.IOC]; ok && r.Found && r.List == "" {
+			values = append(values, r.IOC)
+		}
+	}
+	if len(values) == 0 {
+		return
+	}
+
+	sources, totals, err := s.ch.GetIOCSources(ctx, values, limit)
+	if err != nil {
+		log.Warn().Err(err).Msg("Failed to load IOC sources")
+		return
+	}
+	for i := range results {
+		r := &results[i]
+		if list, ok := sources[r.IOC]; ok && r.Found && r.List == "" {
+			r.Sources = list
+			r.SourceCount = totals[r.IOC]
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/rs/zerolog/log"
+
+	"tip-server/internal/db"
+	"tip-server/internal/events"
+	"tip-server/internal/models"
+)
+
+// sseHeartbeatInterval keeps idle connections (and intermediate proxies) alive
+const sseHeartbeatInterval = 15 * time.Second
+
+// ingestionStreamHandler streams per-file ingestion events as Server-Sent Events.
+// Optional filter: ?status=infected,failed
+//
+// Streams are closed when the server write timeout elapses; EventSource clients
+// reconnect automatically using the advertised retry interval.
+func (s *Server) ingestionStreamHandler(c *fiber.Ctx) error {
+	statuses := make(map[models.ScanStatus]bool)
+	for _, st := range splitCSV(c.Query("status")) {
+		statuses[models.ScanStatus(st)] = true
+	}
+
+	return s.streamChannel(c, db.IngestionEventsChannel, func(payload string) (string, bool) {
+		if len(statuses) > 0 {
+			var event models.IngestionEvent
+			if err := json.Unmarshal([]byte(payload), &event); err != nil {
+				return "", false
+			}
+			if !statuses[event.Status] {
+				return "", false
+			}
+		}
+		return "ingestion", true
+	})
+}
+
+// matchStreamHandler streams newly ingested IOCs and /check hits as
+// Server-Sent Events. The SSE event name is the match kind.
+// Optional filters: ?kind=check_hit&type=ip,domain&tag=apt28
+func (s *Server) matchStreamHandler(c *fiber.Ctx) error {
+	kinds := toSet(splitCSV(c.Query("kind")))
+	types := toSet(splitCSV(c.Query("type")))
+	tags := toSet(splitCSV(c.Query("tag")))
+
+	return s.streamChannel(c, db.MatchEventsChannel, func(payload string) (string, bool) {
+		var event models.MatchEvent
+		if err := json.Unmarshal([]byte(payload), &event); err != nil {
+			return "", false
+		}
+		if len(kinds) > 0 && !kinds[event.Kind] {
+			return "", false
+		}
+		if len(types) > 0 && !types[string(event.Type)] {
+			return "", false
+		}
+		if len(tags) > 0 && !hasAnyTag(event.Tags, tags) {
+			return "", false
+		}
+		return event.Kind, true
+	})
+}
+
+// publishCheckHits announces /check hits to match stream subscribers
+func (s *Server) publishCheckHits(requested []string, found map[string]models.IOC) {
+	seen := make(map[string]bool, len(found))
+	matches := make([]models.MatchEvent, 0, len(found))
+
+	for _, value := range requested {
+		ioc, ok := found[value]
+		if !ok || seen[value] {
+			continue
+		}
+		seen[value] = true
+		matches = append(matches, models.NewMatchEvent(models.MatchKindCheckHit, ioc))
+	}
+
+	s.publishMatches(context.Background(), matches)
+}
+
+// publishMatches fans match events out to stream subscribers and the event bus
+func (s *Server) publishMatches(ctx context.Context, matches []models.MatchEvent) {
+	if err := s.redis.PublishMatchEvents(ctx, matches); err != nil {
+		log.Debug().Err(err).Msg("Failed to publish match events")
+	}
+	if err := s.bus.Publish(ctx, events.MatchMessages(matches)...); err != nil {
+		log.Warn().Err(err).Msg("Failed to publish match events to event bus")
+	}
+}
+
+// streamChannel relays a pub/sub channel to the client as Server-Sent Events.
+// filter returns the SSE event name for a payload, or false to drop it.
+func (s *Server) streamChannel(c *fiber.Ctx, channel string, filter func(payload string) (string, bool)) error {
+	setSSEHeaders(c)
+
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		sub := s.redis.Subscribe(ctx, channel)
+		defer sub.Close()
+
+		fmt.Fprintf(w, "retry: 3000\n\n")
+		if err := w.Flush(); err != nil {
+			return
+		}
+
+		heartbeat := time.NewTicker(sseHeartbeatInterval)
+		defer heartbeat.Stop()
+
+		messages := sub.Payloads()
+		for {
+			select {
+			case payload, ok := <-messages:
+				if !ok {
+					return
+				}
+
+				event, ok := filter(payload)
+				if !ok {
+					continue
+				}
+
+				fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, payload)
+
+			case <-heartbeat.C:
+				fmt.Fprintf(w, ": keepalive\n\n")
+			}
+
+			if err := w.Flush(); err != nil {
+				log.Debug().Err(err).Str("channel", channel).Msg("Stream client disconnected")
+				return
+			}
+		}
+	})
+
+	return nil
+}
+
+// setSSEHeaders prepares the response for an event stream
+func setSSEHeaders(c *fiber.Ctx) {
+	c.Set("Content-Type", "text/event-stream")
+	c.Set("Cache-Control", "no-cache")
+	c.Set("Connection", "keep-alive")
+	c.Set("X-Accel-Buffering", "no")
+}
+
+// toSet converts a list of values into a lookup set
+func toSet(values []string) map[string]bool {
+	set := make(map[string]bool, len(values))
+	for _, v := range values {
+		set[v] = true
+	}
+	return set
+}
+
+// hasAnyTag reports whether any of tags is in the wanted set
+func hasAnyTag(tags []string, wanted map[string]bool) bool {
+	for _, t := range tags {
+		if wanted[t] {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,283 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"time"
+	"unicode/utf8"
+
+	"github.com/rs/zerolog/log"
+
+	"tip-server/internal/events"
+	"tip-server/internal/middleware"
+	"tip-server/internal/models"
+)
+
+// submissionSourcePrefix marks IOCs that were submitted directly rather than
+// extracted from a crawled file
+const submissionSourcePrefix = "submission:"
+
+// defaultSubmissionSource names producers that do not identify themselves
+const defaultSubmissionSource = "event-bus"
+
+// maxIssueValueLength bounds the value echoed back in a submission issue
+const maxIssueValueLength = 256
+
+// maxReportedIssues bounds the issues listed in one response
+const maxReportedIssues = 1000
+
+// startSubmissionConsumer consumes IOC submissions from the event bus, if configured
+func (s *Server) startSubmissionConsumer(ctx context.Context) {
+	sub, err := events.NewSubscriber(s.cfg.EventBus)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to create event bus subscriber")
+		return
+	}
+	if sub == nil {
+		return
+	}
+
+	s.jobs.Go(ctx, "event_bus_submissions", func(ctx context.Context) error {
+		defer sub.Close()
+		return sub.Consume(ctx, s.handleSubmissionMessage)
+	})
+}
+
+// handleSubmissionMessage decodes and stores one submission. Malformed
+// messages are logged and skipped so they cannot block the topic; storage
+// errors are returned so the message is redelivered.
+func (s *Server) handleSubmissionMessage(ctx context.Context, payload []byte) error {
+	var sub models.IOCSubmission
+	dec := json.NewDecoder(bytes.NewReader(payload))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&sub); err != nil {
+		log.Warn().Err(err).Msg("Discarding malformed IOC submission")
+		return nil
+	}
+	if !validTypeMismatch(sub.TypeMismatch) {
+		log.Warn().Str("type_mismatch", sub.TypeMismatch).Msg("Discarding malformed IOC submission")
+		return nil
+	}
+
+	accepted, issues, err := s.ingestSubmission(ctx, sub)
+	if err != nil {
+		return err
+	}
+
+	for _, issue := range issues.list {
+		log.Debug().
+			Str("source", sub.Source).
+			Int("index", issue.Index).
+			Str("code", issue.Code).
+			Bool("corrected", issue.Corrected).
+			Msg(issue.Message)
+	}
+	log.Info().
+		Str("source", sub.Source).
+		Int("accepted", accepted).
+		Int("rejected", issues.rejected).
+		Int("corrected", issues.corrected).
+		Msg("Processed IOC submission")
+	return nil
+}
+
+// ingestSubmission validates externally submitted IOCs and stores the valid
+// ones, returning how many were accepted and the issues found in the rest
+func (s *Server) ingestSubmission(ctx context.Context, sub models.IOCSubmission) (int, submissionIssues, error) {
+	source := sub.Source
+	if source == "" {
+		source = defaultSubmissionSource
+	}
+	correct := s.correctTypeMismatch(sub.TypeMismatch)
+
+	now := time.Now()
+	iocs := make([]models.IOC, 0, len(sub.IOCs))
+	var issues submissionIssues
+
+	for i, in := range sub.IOCs {
+		ioc, issue := s.normalizeSubmittedIOC(in, correct)
+		if issue != nil {
+			issue.Index = i
+			issues.add(*issue)
+			if !issue.Corrected {
+				continue
+			}
+		}
+
+		ioc.SourceFileID = submissionSourcePrefix + source
+		ioc.FirstSeen = now
+		ioc.LastSeen = now
+		iocs = append(iocs, ioc)
+	}
+
+	if err := s.storeIOCs(ctx, iocs); err != nil {
+		return 0, issues, err
+	}
+	return len(iocs), issues, nil
+}
+
+// storeIOCs inserts IOCs that did not come from a crawled file, adds them to
+// the Bloom filter and announces the values the corpus did not know before
+func (s *Server) storeIOCs(ctx context.Context, iocs []models.IOC) error {
+	if len(iocs) == 0 {
+		return nil
+	}
+	s.redactor.IOCs(iocs)
+
+	if err := s.ch.BatchInsertIOCs(ctx, iocs); err != nil {
+		return fmt.Errorf("failed to store submitted IOCs: %w", err)
+	}
+
+	values := make([]string, len(iocs))
+	for i, ioc := range iocs {
+		values[i] = ioc.Value
+	}
+
+	added, err := s.redis.BFMAddNew(ctx, values)
+	if err != nil {
+		log.Warn().Err(err).Msg("Failed to add submitted IOCs to Bloom filter")
+	}
+
+	var matches []models.MatchEvent
+	for i, isNew := range added {
+		if isNew {
+			matches = append(matches, models.NewMatchEvent(models.MatchKindIngested, iocs[i]))
+		}
+	}
+	s.publishMatches(ctx, matches)
+
+	if s.cfg.Volume.Interval > 0 {
+		counts := models.VolumeCounts("", iocs)
+		if err := s.redis.RecordIngestionVolume(ctx, time.Now(), counts, s.cfg.Volume.Retention()); err != nil {
+			log.Debug().Err(err).Msg("Failed to record ingestion volume")
+		}
+	}
+	return nil
+}
+
+// normalizeSubmittedIOC validates a submitted value against its declared
+// type and fills in its type and defaults. A non-nil issue explains why the
+// value was rejected, or that it was stored under another type when it was
+// Corrected.
+func (s *Server) normalizeSubmittedIOC(in models.SubmittedIOC, correct bool) (models.IOC, *models.SubmissionIssue) {
+	detected, value, issue := s.classifySubmittedIOC(in, correct)
+	if issue != nil && !issue.Corrected {
+		return models.IOC{}, issue
+	}
+
+	ioc := models.IOC{
+		Value:         value,
+		Type:          detected,
+		MalwareFamily: in.MalwareFamily,
+		Confidence:    in.Confidence,
+		Tags:          in.Tags,
+	}
+	if ioc.MalwareFamily == "" {
+		ioc.MalwareFamily = "Unknown"
+	}
+	if ioc.Confidence == 0 {
+		ioc.Confidence = 50
+	}
+	if ioc.Confidence > 100 {
+		ioc.Confidence = 100
+	}
+
+	return ioc, issue
+}
+
+// classifySubmittedIOC checks a value against its declared type with the
+// extractor's validators. A valid IOC of another type is a type mismatch,
+// returned corrected to the detected type when correct is set.
+func (s *Server) classifySubmittedIOC(in models.SubmittedIOC, correct bool) (models.IOCType, string, *models.SubmissionIssue) {
+	issue := &models.SubmissionIssue{Value: truncateIssueValue(in.Value), DeclaredType: in.Type}
+
+	if err := middleware.ValidateIndicator(in.Value, s.cfg.API.MaxIOCLength); err != nil {
+		issue.Code, issue.Message = models.SubmissionIssueInvalidValue, err.Error()
+		return "", "", issue
+	}
+	if in.Type != "" && !slices.Contains(models.AllIOCTypes(), in.Type) {
+		issue.Code = models.SubmissionIssueUnknownType
+		issue.Message = fmt.Sprintf("%q is not a supported IOC type", in.Type)
+		return "", "", issue
+	}
+
+	if detected, value, ok := s.extractor.Load().Classify(in.Value, in.Type, in.Tags); ok {
+		return detected, value, nil
+	}
+	if in.Type == "" {
+		issue.Code, issue.Message = models.SubmissionIssueUnrecognized, "value is not an IOC of any supported type"
+		return "", "", issue
+	}
+
+	detected, value, ok := s.extractor.Load().Classify(in.Value, "", in.Tags)
+	if !ok {
+		issue.Code = models.SubmissionIssueInvalidForType
+		issue.Message = fmt.Sprintf("value is not a valid %s, nor an IOC of another type", in.Type)
+		return "", "", issue
+	}
+
+	issue.Code, issue.DetectedType = models.SubmissionIssueTypeMismatch, detected
+	if !correct {
+		issue.Message = fmt.Sprintf("declared %s, but the value is a %s", in.Type, detected)
+		return "", "", issue
+	}
+	issue.Corrected = true
+	issue.Message = fmt.Sprintf("declared %s, but the value is a %s; stored as %s", in.Type, detected, detected)
+	return detected, value, issue
+}
+
+// correctTypeMismatch reports whether type mismatches are corrected rather
+// than rejected, given a submission's choice or the configured default
+func (s *Server) correctTypeMismatch(mode string) bool {
+	if mode == "" {
+		mode = s.cfg.API.SubmissionTypeMismatch
+	}
+	return mode == models.TypeMismatchCorrect
+}
+
+// validTypeMismatch reports whether mode is a type mismatch mode, or empty
+func validTypeMismatch(mode string) bool {
+	switch mode {
+	case "", models.TypeMismatchReject, models.TypeMismatchCorrect:
+		return true
+	}
+	return false
+}
+
+// truncateIssueValue shortens a value echoed back in an issue, cutting at
+// a rune boundary
+func truncateIssueValue(v string) string {
+	if len(v) <= maxIssueValueLength {
+		return v
+	}
+	cut := maxIssueValueLength
+	for cut > 0 && !utf8.RuneStart(v[cut]) {
+		cut--
+	}
+	return v[:cut] + "…"
+}
+
+// submissionIssues collects the issues of a submission, keeping the first
+// maxReportedIssues and counting the rest
+type submissionIssues struct {
+	list      []models.SubmissionIssue
+	omitted   int
+	rejected  int
+	corrected int
+}
+
+func (r *submissionIssues) add(issue models.SubmissionIssue) {
+	if issue.Corrected {
+		r.corrected++
+	} else {
+		r.rejected++
+	}
+	if len(r.list) >= maxReportedIssues {
+		r.omitted++
+		return
+	}
+	r.list = append(r.list, issue)
+}
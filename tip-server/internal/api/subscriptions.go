@@ -0,0 +1,290 @@
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/rs/zerolog/log"
+
+	"tip-server/internal/db"
+	"tip-server/internal/jobs"
+	"tip-server/internal/middleware"
+	"tip-server/internal/models"
+)
+
+const (
+	subscriptionMaxName = 128 // Characters
+	subscriptionMaxTags = 50
+)
+
+// createSubscriptionHandler registers a subscription for the calling API
+// key. Newly ingested IOCs passing its filter are delivered by the
+// subscription delivery job. A webhook's signing secret is returned here
+// and never again.
+func (s *Server) createSubscriptionHandler(c *fiber.Ctx) error {
+	if s.cfg.Subscriptions.MaxPerKey == 0 {
+		return subscriptionsDisabled(c)
+	}
+
+	var req models.SubscriptionRequest
+	if err := middleware.ParseJSONStrict(c, &req); err != nil {
+		return middleware.Problem(c, fiber.StatusBadRequest, models.ErrCodeInvalidRequest,
+			"Invalid request body", err.Error())
+	}
+	if err := s.validateSubscription(&req); err != nil {
+		return middleware.Problem(c, fiber.StatusBadRequest, models.ErrCodeInvalidRequest,
+			"Invalid subscription", err.Error())
+	}
+
+	ctx := context.Background()
+	owner, _ := c.Locals("api_key_hash").(string)
+	subs, err := s.redis.ListSubscriptions(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to list subscriptions")
+		return middleware.Problem(c, fiber.StatusInternalServerError, models.ErrCodeStorageUnavailable,
+			"Failed to register subscription", "")
+	}
+	owned := 0
+	for _, sub := range subs {
+		if sub.Owner == owner {
+			owned++
+		}
+	}
+	if owned >= s.cfg.Subscriptions.MaxPerKey {
+		return middleware.Problem(c, fiber.StatusConflict, models.ErrCodeConflict,
+			"Too many subscriptions",
+			fmt.Sprintf("An API key may register at most %d subscriptions; delete one first", s.cfg.Subscriptions.MaxPerKey))
+	}
+
+	sub := models.Subscription{
+		ID:        randomHex(16),
+		Name:      req.Name,
+		Owner:     owner,
+		Filter:    req.Filter,
+		Delivery:  req.Delivery,
+		CreatedAt: time.Now().UTC(),
+	}
+	if sub.Delivery.Method == models.DeliveryWebhook {
+		sub.Delivery.Secret = randomHex(32)
+	}
+	if err := s.redis.SaveSubscription(ctx, sub); err != nil {
+		log.Error().Err(err).Msg("Failed to save subscription")
+		return middleware.Problem(c, fiber.StatusInternalServerError, models.ErrCodeStorageUnavailable,
+			"Failed to register subscription", "")
+	}
+
+	entry := models.AuditEntry{
+		Timestamp: sub.CreatedAt,
+		Action:    models.AuditActionSubscribe,
+		IOCValue:  sub.ID,
+		Actor:     owner,
+		Reason:    sub.Delivery.Method,
+		ClientIP:  c.IP(),
+	}
+	if err := s.ch.InsertAuditEntry(ctx, entry); err != nil {
+		log.Error().Err(err).Msg("Failed to write audit entry")
+	}
+	log.Info().
+		Str("subscription", sub.ID).
+		Str("method", sub.Delivery.Method).
+		Str("actor", owner).
+		Msg("Subscription registered")
+
+	secret := sub.Delivery.Secret
+	redactSubscription(&sub)
+	sub.Delivery.Secret = secret
+	return c.Status(fiber.StatusCreated).JSON(sub)
+}
+
+// validateSubscription checks a subscription request, normalizing its
+// filter
+func (s *Server) validateSubscription(req *models.SubscriptionRequest) error {
+	if len([]rune(req.Name)) > subscriptionMaxName {
+		return fmt.Errorf("name is longer than %d characters", subscriptionMaxName)
+	}
+
+	known := models.AllIOCTypes()
+	for _, t := range req.Filter.Types {
+		if !slices.Contains(known, t) {
+			return fmt.Errorf("unknown IOC type %q", t)
+		}
+	}
+	if len(req.Filter.Tags) > subscriptionMaxTags {
+		return fmt.Errorf("at most %d tags may be given", subscriptionMaxTags)
+	}
+	for i, tag := range req.Filter.Tags {
+		req.Filter.Tags[i] = strings.TrimSpace(tag)
+		if req.Filter.Tags[i] == "" {
+			return fmt.Errorf("tags must not be empty")
+		}
+	}
+	if req.Filter.MinConfidence > 100 {
+		return fmt.Errorf("min_confidence must be between 0 and 100")
+	}
+
+	d := &req.Delivery
+	if d.Secret != "" {
+		return fmt.Errorf("delivery.secret is generated by the server")
+	}
+	switch d.Method {
+	case models.DeliveryWebhook, models.DeliveryTAXII:
+		if d.Topic != "" {
+			return fmt.Errorf("delivery.topic only applies to kafka delivery")
+		}
+		if err := jobs.CheckDeliveryURL(d.URL, s.cfg.Subscriptions.AllowPrivate); err != nil {
+			return fmt.Errorf("delivery.url: %w", err)
+		}
+	case models.DeliveryKafka:
+		if s.cfg.EventBus.Type != "kafka" {
+			return fmt.Errorf("kafka delivery requires EVENT_BUS_TYPE=kafka")
+		}
+		if d.URL != "" || d.Authorization != "" {
+			return fmt.Errorf("delivery.url and delivery.authorization do not apply to kafka delivery")
+		}
+		prefix := s.cfg.Subscriptions.TopicPrefix
+		if !strings.HasPrefix(d.Topic, prefix) || len(d.Topic) == len(prefix) {
+			return fmt.Errorf("delivery.topic must start with %q", prefix)
+		}
+	default:
+		return fmt.Errorf("delivery.method must be webhook, kafka or taxii")
+	}
+	return nil
+}
+
+// listSubscriptionsHandler lists the caller's subscriptions with their
+// delivery statistics. Admins see every key's subscriptions.
+func (s *Server) listSubscriptionsHandler(c *fiber.Ctx) error {
+	if s.cfg.Subscriptions.MaxPerKey == 0 {
+		return subscriptionsDisabled(c)
+	}
+
+	ctx := context.Background()
+	subs, err := s.redis.ListSubscriptions(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to list subscriptions")
+		return middleware.Problem(c, fiber.StatusInternalServerError, models.ErrCodeStorageUnavailable,
+			"Failed to list subscriptions", "")
+	}
+
+	visible := make([]models.Subscription, 0, len(subs))
+	for _, sub := range subs {
+		if !canSeeSubscription(c, sub) {
+			continue
+		}
+		s.attachSubscriptionStatus(ctx, &sub)
+		redactSubscription(&sub)
+		visible = append(visible, sub)
+	}
+	sort.Slice(visible, func(i, j int) bool {
+		return visible[i].CreatedAt.Before(visible[j].CreatedAt)
+	})
+
+	return c.JSON(fiber.Map{
+		"subscriptions": visible,
+		"count":         len(visible),
+	})
+}
+
+// getSubscriptionHandler returns one subscription with its delivery
+// statistics
+func (s *Server) getSubscriptionHandler(c *fiber.Ctx) error {
+	if s.cfg.Subscriptions.MaxPerKey == 0 {
+		return subscriptionsDisabled(c)
+	}
+
+	ctx := context.Background()
+	var sub models.Subscription
+	if err := s.redis.GetJSON(ctx, db.SubscriptionKey(c.Params("id")), &sub); err != nil || !canSeeSubscription(c, sub) {
+		// Other keys' subscriptions are reported missing rather than forbidden
+		return middleware.Problem(c, fiber.StatusNotFound, models.ErrCodeNotFound,
+			"Subscription not found", c.Params("id"))
+	}
+	s.attachSubscriptionStatus(ctx, &sub)
+	redactSubscription(&sub)
+	return c.JSON(sub)
+}
+
+// deleteSubscriptionHandler stops and removes a subscription. Batches
+// already pending for it are dropped.
+func (s *Server) deleteSubscriptionHandler(c *fiber.Ctx) error {
+	if s.cfg.Subscriptions.MaxPerKey == 0 {
+		return subscriptionsDisabled(c)
+	}
+
+	ctx := context.Background()
+	id := c.Params("id")
+	var sub models.Subscription
+	if err := s.redis.GetJSON(ctx, db.SubscriptionKey(id), &sub); err != nil || !canSeeSubscription(c, sub) {
+		return middleware.Problem(c, fiber.StatusNotFound, models.ErrCodeNotFound,
+			"Subscription not found", id)
+	}
+	if _, err := s.redis.DeleteSubscription(ctx, id); err != nil {
+		log.Error().Err(err).Str("subscription", id).Msg("Failed to delete subscription")
+		return middleware.Problem(c, fiber.StatusInternalServerError, models.ErrCodeStorageUnavailable,
+			"Failed to delete subscription", "")
+	}
+
+	actor, _ := c.Locals("api_key_hash").(string)
+	entry := models.AuditEntry{
+		Timestamp: time.Now().UTC(),
+		Action:    models.AuditActionUnsubscribe,
+		IOCValue:  id,
+		Actor:     actor,
+		Reason:    sub.Delivery.Method,
+		ClientIP:  c.IP(),
+	}
+	if err := s.ch.InsertAuditEntry(ctx, entry); err != nil {
+		log.Error().Err(err).Msg("Failed to write audit entry")
+	}
+	log.Info().Str("subscription", id).Str("actor", actor).Msg("Subscription deleted")
+
+	return c.JSON(fiber.Map{
+		"id":      id,
+		"deleted": true,
+	})
+}
+
+// attachSubscriptionStatus adds the delivery statistics the delivery job
+// recorded, if any
+func (s *Server) attachSubscriptionStatus(ctx context.Context, sub *models.Subscription) {
+	var status models.SubscriptionStatus
+	if err := s.redis.GetJSON(ctx, db.SubscriptionStatusKey(sub.ID), &status); err == nil {
+		sub.Status = &status
+	}
+}
+
+// canSeeSubscription reports whether the caller registered sub or is an
+// admin
+func canSeeSubscription(c *fiber.Ctx, sub models.Subscription) bool {
+	if role, _ := c.Locals("role").(string); role == middleware.RoleAdmin {
+		return true
+	}
+	owner, _ := c.Locals("api_key_hash").(string)
+	return sub.ID != "" && sub.Owner == owner
+}
+
+// redactSubscription clears the delivery credentials before a subscription
+// is returned
+func redactSubscription(sub *models.Subscription) {
+	sub.Delivery.Authorization = ""
+	sub.Delivery.Secret = ""
+}
+
+func subscriptionsDisabled(c *fiber.Ctx) error {
+	return middleware.Problem(c, fiber.StatusServiceUnavailable, models.ErrCodeFeatureUnavailable,
+		"Subscriptions are disabled", "Set SUBSCRIPTION_MAX_PER_KEY above 0 to enable them")
+}
+
+// randomHex returns n random bytes, hex encoded
+func randomHex(n int) string {
+	buf := make([]byte, n)
+	rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
@@ -0,0 +1,102 @@
+package api
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/rs/zerolog/log"
+
+	"tip-server/internal/middleware"
+	"tip-server/internal/models"
+)
+
+const (
+	// syncDefaultLimit and syncMaxLimit bound a /sync/iocs page
+	syncDefaultLimit = 1000
+	syncMaxLimit     = 10000
+)
+
+// syncHandler serves the IOCs ingested after a cursor, for replica
+// deployments pulling the corpus (admin only). Query parameters: cursor
+// from the previous page, or since to start from a point in time (see
+// models.ParseTime), and limit.
+func (s *Server) syncHandler(c *fiber.Ctx) error {
+	var cursor models.SyncCursor
+	if raw := c.Query("cursor"); raw != "" {
+		var err error
+		if cursor, err = decodeSyncCursor(raw); err != nil {
+			return middleware.Problem(c, fiber.StatusBadRequest, models.ErrCodeInvalidParameter,
+				"Invalid cursor", "Pass back the cursor of the previous page unchanged")
+		}
+	} else if raw := c.Query("since"); raw != "" {
+		since, err := models.ParseTime(raw, time.Now())
+		if err != nil {
+			return middleware.Problem(c, fiber.StatusBadRequest, models.ErrCodeInvalidParameter,
+				"Invalid query parameter", "since: "+err.Error())
+		}
+		cursor.IngestedAt = since
+	}
+
+	limit, ok := queryNonNegativeInt(c, "limit")
+	if !ok || limit > syncMaxLimit {
+		return middleware.Problem(c, fiber.StatusBadRequest, models.ErrCodeInvalidParameter,
+			"Invalid query parameter", "limit must be between 1 and 10000")
+	}
+	if limit == 0 {
+		limit = syncDefaultLimit
+	}
+
+	iocs, err := s.ch.GetIOCsAfter(context.Background(), cursor, limit)
+	if err != nil {
+		log.Error().Err(err).Msg("Sync query failed")
+		return middleware.Problem(c, fiber.StatusInternalServerError, models.ErrCodeStorageUnavailable,
+			"Failed to query IOC store", "")
+	}
+
+	if n := len(iocs); n > 0 {
+		last := iocs[n-1]
+		cursor = models.SyncCursor{
+			IngestedAt:   last.IngestedAt.UTC(),
+			Value:        last.Value,
+			SourceFileID: last.SourceFileID,
+		}
+	}
+
+	// Nothing synced yet: an empty cursor means "from the beginning"
+	next := ""
+	if !cursor.IngestedAt.IsZero() {
+		next = encodeSyncCursor(cursor)
+	}
+
+	return c.JSON(models.SyncResponse{
+		IOCs:   iocs,
+		Cursor: next,
+		More:   len(iocs) == limit,
+	})
+}
+
+// encodeSyncCursor returns the opaque form of a cursor handed to replicas
+func encodeSyncCursor(cursor models.SyncCursor) string {
+	raw, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+// decodeSyncCursor parses a cursor produced by encodeSyncCursor
+func decodeSyncCursor(s string) (models.SyncCursor, error) {
+	var cursor models.SyncCursor
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return cursor, err
+	}
+	if err := json.Unmarshal(raw, &cursor); err != nil {
+		return cursor, err
+	}
+	if cursor.IngestedAt.IsZero() {
+		return cursor, errors.New("cursor has no timestamp")
+	}
+	return cursor, nil
+}
@@ -0,0 +1,85 @@
+package api
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// Listen starts the Fiber listener, terminating TLS when configured
+func (s *Server) Listen(addr string) error {
+	api := s.cfg.API
+
+	switch {
+	case len(api.TLSAutocertDomains) > 0:
+		manager := &autocert.Manager{
+			Prompt:     autocert.AcceptTOS,
+			HostPolicy: autocert.HostWhitelist(api.TLSAutocertDomains...),
+			Cache:      autocert.DirCache(api.TLSAutocertCacheDir),
+			Email:      api.TLSAutocertEmail,
+		}
+
+		tlsConfig := manager.TLSConfig()
+		tlsConfig.MinVersion = tls.VersionTLS12
+
+		ln, err := tls.Listen("tcp", addr, tlsConfig)
+		if err != nil {
+			return fmt.Errorf("failed to listen on %s: %w", addr, err)
+		}
+
+		s.startRedirectServer(manager.HTTPHandler(nil))
+
+		log.Info().Strs("domains", api.TLSAutocertDomains).Msg("Serving HTTPS with autocert")
+		return s.app.Listener(ln)
+
+	case api.TLSCertFile != "":
+		s.startRedirectServer(http.HandlerFunc(redirectToHTTPS(api.Port)))
+
+		log.Info().Str("cert", api.TLSCertFile).Msg("Serving HTTPS")
+		return s.app.ListenTLS(addr, api.TLSCertFile, api.TLSKeyFile)
+
+	default:
+		return s.app.Listen(addr)
+	}
+}
+
+// startRedirectServer serves handler on the plain HTTP redirect port, if enabled
+func (s *Server) startRedirectServer(handler http.Handler) {
+	if s.cfg.API.TLSRedirectPort == 0 {
+		return
+	}
+
+	s.redirect = &http.Server{
+		Addr:              fmt.Sprintf("%s:%d", s.cfg.API.Host, s.cfg.API.TLSRedirectPort),
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		log.Info().Str("addr", s.redirect.Addr).Msg("Starting HTTP to HTTPS redirect server")
+		if err := s.redirect.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Error().Err(err).Msg("Redirect server failed")
+		}
+	}()
+}
+
+// redirectToHTTPS permanently redirects plain HTTP requests to the TLS port
+func redirectToHTTPS(tlsPort int) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		host, _, err := net.SplitHostPort(r.Host)
+		if err != nil {
+			host = r.Host
+		}
+		if tlsPort != 443 {
+			host = net.JoinHostPort(host, strconv.Itoa(tlsPort))
+		}
+
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusPermanentRedirect)
+	}
+}
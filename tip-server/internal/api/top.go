@@ -0,0 +1,110 @@
+package api
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/rs/zerolog/log"
+
+	"tip-server/internal/middleware"
+	"tip-server/internal/models"
+)
+
+const (
+	// topDefaultLimit and topMaxLimit bound /stats/top rankings
+	topDefaultLimit = 20
+	topMaxLimit     = 100
+
+	// topDefaultWindow and topMaxWindow bound how far back /stats/top looks
+	topDefaultWindow = 7 * 24 * time.Hour
+	topMaxWindow     = 90 * 24 * time.Hour
+)
+
+// topHandler ranks the most looked-up indicators, or the malware families
+// and source files behind the most matches, over a recent window. Query
+// parameters: dimension (required), limit, and window as a duration or
+// since as a time (see models.ParseTime), since taking precedence.
+func (s *Server) topHandler(c *fiber.Ctx) error {
+	startTime := time.Now()
+
+	dimension := c.Query("dimension")
+	switch dimension {
+	case models.TopDimensionQueried, models.TopDimensionMalwareFamily, models.TopDimensionSourceFile:
+	default:
+		return middleware.Problem(c, fiber.StatusBadRequest, models.ErrCodeInvalidParameter,
+			"Invalid dimension", "dimension must be one of queried, malware_family, source_file")
+	}
+
+	limit, ok := queryNonNegativeInt(c, "limit")
+	if !ok || limit > topMaxLimit {
+		return middleware.Problem(c, fiber.StatusBadRequest, models.ErrCodeInvalidParameter,
+			"Invalid query parameter", "limit must be between 1 and 100")
+	}
+	if limit == 0 {
+		limit = topDefaultLimit
+	}
+
+	window := topDefaultWindow
+	if raw := c.Query("window"); raw != "" {
+		d, err := time.ParseDuration(raw)
+		if err != nil || d <= 0 || d > topMaxWindow {
+			return middleware.Problem(c, fiber.StatusBadRequest, models.ErrCodeInvalidParameter,
+				"Invalid query parameter", "window must be a duration up to 2160h, e.g. 24h")
+		}
+		window = d
+	}
+
+	now := time.Now()
+	since := now.Add(-window)
+	if raw := c.Query("since"); raw != "" {
+		t, err := models.ParseTime(raw, now)
+		if err != nil || !t.Before(now) || now.Sub(t) > topMaxWindow {
+			return middleware.Problem(c, fiber.StatusBadRequest, models.ErrCodeInvalidParameter,
+				"Invalid query parameter", "since must be a past time within 2160h, e.g. -24h")
+		}
+		since = t
+	}
+	since = since.UTC().Truncate(time.Second)
+	entries, err := s.ch.GetTopLookups(context.Background(), dimension, since, limit)
+	if err != nil {
+		log.Error().Err(err).Str("dimension", dimension).Msg("Top-N query failed")
+		return middleware.Problem(c, fiber.StatusInternalServerError, models.ErrCodeStorageUnavailable,
+			"Failed to query lookup statistics", "")
+	}
+
+	return c.JSON(models.TopResponse{
+		Dimension: dimension,
+		Since:     since,
+		Entries:   entries,
+		QueryTime: time.Since(startTime).String(),
+	})
+}
+
+// logQuery records a lookup in the query log in the background; the log
+// feeds /stats/top and must not slow the lookup down
+func (s *Server) logQuery(c *fiber.Ctx, queried []string, found map[string]models.IOC, elapsed time.Duration) {
+	matched := make([]string, 0, len(found))
+	for value := range found {
+		matched = append(matched, value)
+	}
+
+	keyHash, _ := c.Locals("api_key_hash").(string)
+	entry := models.QueryLogEntry{
+		Timestamp:  time.Now().UTC(),
+		APIKeyHash: keyHash,
+		Endpoint:   strings.Clone(c.Path()), // Fiber reuses request buffers
+
+		IOCsQueried:    queried,
+		IOCsFound:      matched,
+		ResponseTimeMs: uint32(elapsed.Milliseconds()),
+		ClientIP:       strings.Clone(c.IP()),
+	}
+
+	go func() {
+		if err := s.ch.InsertQueryLog(context.Background(), entry); err != nil {
+			log.Debug().Err(err).Msg("Failed to write query log")
+		}
+	}()
+}
@@ -0,0 +1,36 @@
+package api
+
+import (
+	"context"
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/rs/zerolog/log"
+
+	"tip-server/internal/db"
+	"tip-server/internal/middleware"
+	"tip-server/internal/models"
+)
+
+// volumeHandler returns the latest ingestion volume check and the feeds
+// whose volume departs from their baseline (admin only)
+func (s *Server) volumeHandler(c *fiber.Ctx) error {
+	var report models.VolumeReport
+	err := s.redis.GetJSON(context.Background(), db.VolumeReportKey, &report)
+	if errors.Is(err, redis.Nil) {
+		details := "No ingestion volume check has completed yet"
+		if s.cfg.Volume.Interval <= 0 {
+			details = "Ingestion volume checks are disabled; set VOLUME_CHECK_INTERVAL"
+		}
+		return middleware.Problem(c, fiber.StatusNotFound, models.ErrCodeNotFound,
+			"No ingestion volume report available", details)
+	}
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to load ingestion volume report")
+		return middleware.Problem(c, fiber.StatusInternalServerError, models.ErrCodeStorageUnavailable,
+			"Failed to load ingestion volume report", "")
+	}
+
+	return c.JSON(report)
+}
@@ -0,0 +1,85 @@
+package api
+
+import (
+	"context"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/rs/zerolog/log"
+
+	"tip-server/internal/middleware"
+	"tip-server/internal/models"
+)
+
+const (
+	// whoisRelatedDefaultLimit and whoisRelatedMaxLimit bound /whois/related results
+	whoisRelatedDefaultLimit = 100
+	whoisRelatedMaxLimit     = 1000
+)
+
+// whoisRelatedHandler lists the domains whose registration data named the
+// given registrar, nameserver or registrant email, e.g. every domain
+// registered with a known bad address. Query parameters: relation, value
+// and limit. Only domains looked up through WHOIS enrichment are covered.
+func (s *Server) whoisRelatedHandler(c *fiber.Ctx) error {
+	relation := c.Query("relation")
+	switch relation {
+	case models.WhoisRelationRegistrar, models.WhoisRelationNameserver, models.WhoisRelationRegistrantEmail:
+	default:
+		return middleware.Problem(c, fiber.StatusBadRequest, models.ErrCodeInvalidParameter,
+			"Invalid relation", "relation must be one of registrar, nameserver, registrant_email")
+	}
+
+	// Nameservers and emails are stored lowercased; registrar names as published
+	value := strings.TrimSpace(c.Query("value"))
+	if relation != models.WhoisRelationRegistrar {
+		value = strings.TrimSuffix(strings.ToLower(value), ".")
+	}
+	if err := middleware.ValidateIndicator(value, s.cfg.API.MaxIOCLength); err != nil {
+		return middleware.Problem(c, fiber.StatusBadRequest, models.ErrCodeInvalidParameter,
+			"Invalid query parameter", "value: "+err.Error())
+	}
+	value = strings.Clone(value)
+
+	limit, ok := queryNonNegativeInt(c, "limit")
+	if !ok || limit > whoisRelatedMaxLimit {
+		return middleware.Problem(c, fiber.StatusBadRequest, models.ErrCodeInvalidParameter,
+			"Invalid query parameter", "limit must be between 1 and 1000")
+	}
+	if limit == 0 {
+		limit = whoisRelatedDefaultLimit
+	}
+
+	ctx := context.Background()
+
+	rels, err := s.ch.GetWhoisRelatedDomains(ctx, relation, value, limit)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to query WHOIS relationships")
+		return middleware.Problem(c, fiber.StatusInternalServerError, models.ErrCodeStorageUnavailable,
+			"Failed to query WHOIS relationships", "")
+	}
+
+	domains := make([]string, len(rels))
+	for i, r := range rels {
+		domains[i] = r.Domain
+	}
+	// Marking known IOCs is best effort; the relationships are the answer
+	known, _ := s.queryIOCChunks(ctx, domains)
+
+	resp := models.WhoisRelatedResponse{
+		Relation: relation,
+		Value:    value,
+		Domains:  make([]models.WhoisRelatedDomain, len(rels)),
+	}
+	for i, r := range rels {
+		d := models.WhoisRelatedDomain{Domain: r.Domain, ObservedAt: r.ObservedAt}
+		if ioc, found := known[r.Domain]; found {
+			d.Found = true
+			d.MalwareFamily = ioc.MalwareFamily
+			d.Confidence = ioc.Confidence
+		}
+		resp.Domains[i] = d
+	}
+
+	return c.JSON(resp)
+}
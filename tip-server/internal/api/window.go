@@ -0,0 +1,78 @@
+package api
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"tip-server/internal/models"
+)
+
+// checkWindow limits /check matches to IOCs seen within it, e.g. only
+// indicators active in the last 90 days. A zero bound is open.
+type checkWindow struct {
+	since, until time.Time
+}
+
+// parseCheckWindow reads the since and until query parameters of /check
+// (see models.ParseTime)
+func parseCheckWindow(c *fiber.Ctx) (checkWindow, error) {
+	var w checkWindow
+	now := time.Now()
+	if raw := c.Query("since"); raw != "" {
+		t, err := models.ParseTime(raw, now)
+		if err != nil {
+			return w, fmt.Errorf("since: %w", err)
+		}
+		w.since = t
+	}
+	if raw := c.Query("until"); raw != "" {
+		t, err := models.ParseTime(raw, now)
+		if err != nil {
+			return w, fmt.Errorf("until: %w", err)
+		}
+		w.until = t
+	}
+	if !w.since.IsZero() && !w.until.IsZero() && w.until.Before(w.since) {
+		return w, fmt.Errorf("until is before since")
+	}
+	return w, nil
+}
+
+// bounded reports whether the window excludes anything
+func (w checkWindow) bounded() bool {
+	return !w.since.IsZero() || !w.until.IsZero()
+}
+
+// contains reports whether an IOC was seen within the window: last seen at
+// or after since, and first seen at or before until
+func (w checkWindow) contains(ioc models.IOC) bool {
+	if !w.since.IsZero() && ioc.LastSeen.Before(w.since) {
+		return false
+	}
+	if !w.until.IsZero() && ioc.FirstSeen.After(w.until) {
+		return false
+	}
+	return true
+}
+
+// withholdOutside removes matches not seen within the window from found and
+// returns the values it removed
+func (r *lookupResult) withholdOutside(w checkWindow) map[string]bool {
+	if !w.bounded() {
+		return nil
+	}
+	var outside map[string]bool
+	for v, ioc := range r.found {
+		if w.contains(ioc) {
+			continue
+		}
+		if outside == nil {
+			outside = make(map[string]bool)
+		}
+		outside[v] = true
+		delete(r.found, v)
+	}
+	return outside
+}
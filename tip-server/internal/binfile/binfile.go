@@ -0,0 +1,123 @@
+// Package binfile describes executable samples: their hashes, format and
+// architecture, import hash, the printable strings embedded in them and,
+// for PE files, their resources. Nothing is executed or loaded; the file is
+// only read.
+package binfile
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"debug/elf"
+	"debug/pe"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// Formats recognised by Detect
+const (
+	FormatPE  = "pe"
+	FormatELF = "elf"
+)
+
+// Sample is the metadata and scannable content of an executable
+type Sample struct {
+	Format    string // FormatPE or FormatELF
+	Type      string // e.g. "pe32", "pe32+", "elf64"
+	Arch      string
+	MD5       string
+	SHA1      string
+	SHA256    string
+	Imphash   string // PE only; "" when there is no import table
+	Strings   []string
+	Resources []Resource // PE only
+}
+
+// Resource is a leaf of a PE resource tree
+type Resource struct {
+	Type       string // RT_* name or numeric ID
+	Name       string
+	Size       int
+	SHA256     string
+	Executable bool // The resource is itself a PE or ELF file
+}
+
+// Detect returns the executable format of content, or "" if it is not an
+// executable
+func Detect(content []byte) string {
+	switch {
+	case bytes.HasPrefix(content, []byte(elf.ELFMAG)):
+		return FormatELF
+	case len(content) >= 0x40 && content[0] == 'M' && content[1] == 'Z':
+		// e_lfanew points at the PE signature
+		off := int(uint32(content[0x3c]) | uint32(content[0x3d])<<8 | uint32(content[0x3e])<<16 | uint32(content[0x3f])<<24)
+		if off > 0 && off+4 <= len(content) && bytes.Equal(content[off:off+4], []byte("PE\x00\x00")) {
+			return FormatPE
+		}
+	}
+	return ""
+}
+
+// Analyze reads a sample in the given format. Strings shorter than
+// minString characters are not reported.
+func Analyze(format string, content []byte, minString int) (*Sample, error) {
+	s := &Sample{Format: format}
+	s.MD5, s.SHA1, s.SHA256 = hashes(content)
+
+	var err error
+	switch format {
+	case FormatPE:
+		err = s.readPE(content)
+	case FormatELF:
+		err = s.readELF(content)
+	default:
+		return nil, fmt.Errorf("unsupported executable format %q", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	s.Strings = Strings(content, minString)
+	return s, nil
+}
+
+// readELF records the class and machine of an ELF file
+func (s *Sample) readELF(content []byte) error {
+	f, err := elf.NewFile(bytes.NewReader(content))
+	if err != nil {
+		return fmt.Errorf("failed to parse ELF: %w", err)
+	}
+	defer f.Close()
+
+	s.Type = "elf32"
+	if f.Class == elf.ELFCLASS64 {
+		s.Type = "elf64"
+	}
+	s.Arch = machineName(f.Machine.String(), "EM_")
+	return nil
+}
+
+// hashes returns the hex MD5, SHA1 and SHA256 of content
+func hashes(content []byte) (string, string, string) {
+	m := md5.Sum(content)
+	s1 := sha1.Sum(content)
+	s256 := sha256.Sum256(content)
+	return hex.EncodeToString(m[:]), hex.EncodeToString(s1[:]), hex.EncodeToString(s256[:])
+}
+
+// machineName lowercases a debug/pe or debug/elf machine constant name
+func machineName(name, prefix string) string {
+	return strings.ToLower(strings.TrimPrefix(name, prefix))
+}
+
+// peMachines names the common PE machine types
+var peMachines = map[uint16]string{
+	pe.IMAGE_FILE_MACHINE_I386:  "i386",
+	pe.IMAGE_FILE_MACHINE_AMD64: "amd64",
+	pe.IMAGE_FILE_MACHINE_ARM:   "arm",
+	pe.IMAGE_FILE_MACHINE_ARMNT: "armnt",
+	pe.IMAGE_FILE_MACHINE_ARM64: "arm64",
+	pe.IMAGE_FILE_MACHINE_IA64:  "ia64",
+}
@@ -0,0 +1,152 @@
+package binfile
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func readFixture(t *testing.T, name string) []byte {
+	t.Helper()
+	content, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return content
+}
+
+func TestAnalyze(t *testing.T) {
+	imphash := md5.Sum([]byte("kernel32.createfilea,kernel32.ord5"))
+
+	tests := []struct {
+		file      string
+		format    string
+		typ       string
+		arch      string
+		imphash   string
+		strings   []string // Among the strings reported
+		resources []Resource
+	}{
+		{
+			file:    "sample.exe",
+			format:  FormatPE,
+			typ:     "pe32",
+			arch:    "i386",
+			imphash: hex.EncodeToString(imphash[:]),
+			strings: []string{"connect to http://update-checker-cdn.net/gate.php", "Global\\FixtureMutex", "KERNEL32.dll"},
+			resources: []Resource{
+				{Type: "RT_RCDATA", Name: "101", Size: 0x44, Executable: true},
+			},
+		},
+		{
+			file:    "sample.elf",
+			format:  FormatELF,
+			typ:     "elf64",
+			arch:    "x86_64",
+			strings: []string{"beacon to 203.0.113.77:8443"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			content := readFixture(t, tt.file)
+			if format := Detect(content); format != tt.format {
+				t.Fatalf("Detect() = %q, want %q", format, tt.format)
+			}
+			s, err := Analyze(tt.format, content, 6)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if s.Type != tt.typ || s.Arch != tt.arch {
+				t.Errorf("type, arch = %s, %s; want %s, %s", s.Type, s.Arch, tt.typ, tt.arch)
+			}
+			if s.Imphash != tt.imphash {
+				t.Errorf("imphash = %q, want %q", s.Imphash, tt.imphash)
+			}
+			if want, _, _ := hashes(content); s.MD5 != want || len(s.SHA256) != 64 {
+				t.Errorf("md5 = %s, sha256 = %s", s.MD5, s.SHA256)
+			}
+			for _, want := range tt.strings {
+				if !slices.Contains(s.Strings, want) {
+					t.Errorf("strings lack %q", want)
+				}
+			}
+			if len(s.Resources) != len(tt.resources) {
+				t.Fatalf("resources = %+v, want %+v", s.Resources, tt.resources)
+			}
+			for idx, r := range s.Resources {
+				w := tt.resources[idx]
+				if r.Type != w.Type || r.Name != w.Name || r.Size != w.Size || r.Executable != w.Executable || len(r.SHA256) != 64 {
+					t.Errorf("resource %d = %+v, want %+v", idx, r, w)
+				}
+			}
+		})
+	}
+}
+
+func TestAnalyzeMalformed(t *testing.T) {
+	exe := readFixture(t, "sample.exe")
+	elfFile := readFixture(t, "sample.elf")
+
+	tests := []struct {
+		name    string
+		format  string
+		content []byte
+		err     string
+	}{
+		{"pe headers cut", FormatPE, exe[:0x60], "failed to parse PE"},
+		{"pe bad signature offset", FormatPE, append(slices.Clone(exe[:0x3c]), 0xff, 0xff, 0, 0), "failed to parse PE"},
+		{"elf header cut", FormatELF, elfFile[:20], "failed to parse ELF"},
+		{"elf bad class", FormatELF, append(slices.Clone(elfFile[:4]), append([]byte{9}, elfFile[5:]...)...), "failed to parse ELF"},
+		{"unknown format", "macho", exe, "unsupported"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := Analyze(tt.format, tt.content, 6)
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("Analyze() = %+v, %v; want error %q", s, err, tt.err)
+			}
+		})
+	}
+}
+
+func TestStrings(t *testing.T) {
+	content := []byte("ab\x00evil.example\x00\x01" + "w\x00i\x00d\x00e\x00r\x00\x00\x00")
+	got := Strings(content, 4)
+	want := []string{"evil.example", "wider"}
+	if !slices.Equal(got, want) {
+		t.Errorf("Strings() = %q, want %q", got, want)
+	}
+}
+
+// TestAnalyzeDamaged analyzes every prefix of the samples and every
+// single-byte corruption of them; none may panic
+func TestAnalyzeDamaged(t *testing.T) {
+	for _, tt := range []struct {
+		file   string
+		format string
+	}{
+		{"sample.exe", FormatPE},
+		{"sample.elf", FormatELF},
+	} {
+		t.Run(tt.file, func(t *testing.T) {
+			content := readFixture(t, tt.file)
+			for n := range content {
+				Analyze(tt.format, content[:n], 6)
+			}
+			damaged := slices.Clone(content)
+			for idx := range damaged {
+				for _, b := range []byte{0x00, 0x7f, 0xff} {
+					orig := damaged[idx]
+					damaged[idx] = b
+					Analyze(tt.format, damaged, 6)
+					damaged[idx] = orig
+				}
+			}
+		})
+	}
+}
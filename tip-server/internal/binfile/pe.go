@@ -0,0 +1,273 @@
+package binfile
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha256"
+	"debug/pe"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// PE data directory indexes
+const (
+	dirImport   = 1
+	dirResource = 2
+)
+
+// Limits on malformed or hostile import and resource tables
+const (
+	maxImports       = 10000
+	maxResources     = 1000
+	maxResourceDepth = 3
+)
+
+// resourceTypes names the standard RT_* resource type IDs
+var resourceTypes = map[uint32]string{
+	1: "RT_CURSOR", 2: "RT_BITMAP", 3: "RT_ICON", 4: "RT_MENU", 5: "RT_DIALOG",
+	6: "RT_STRING", 7: "RT_FONTDIR", 8: "RT_FONT", 9: "RT_ACCELERATOR",
+	10: "RT_RCDATA", 11: "RT_MESSAGETABLE", 12: "RT_GROUP_CURSOR",
+	14: "RT_GROUP_ICON", 16: "RT_VERSION", 17: "RT_DLGINCLUDE",
+	19: "RT_PLUGPLAY", 20: "RT_VXD", 21: "RT_ANICURSOR", 22: "RT_ANIICON",
+	23: "RT_HTML", 24: "RT_MANIFEST",
+}
+
+// peImage maps RVAs of a PE file to file offsets
+type peImage struct {
+	content  []byte
+	sections []*pe.Section
+	is64     bool
+	dirs     []pe.DataDirectory
+}
+
+// readPE records the type, machine, import hash and resources of a PE file
+func (s *Sample) readPE(content []byte) error {
+	f, err := pe.NewFile(bytes.NewReader(content))
+	if err != nil {
+		return fmt.Errorf("failed to parse PE: %w", err)
+	}
+	defer f.Close()
+
+	img := &peImage{content: content, sections: f.Sections}
+	switch oh := f.OptionalHeader.(type) {
+	case *pe.OptionalHeader32:
+		s.Type = "pe32"
+		img.dirs = oh.DataDirectory[:min(int(oh.NumberOfRvaAndSizes), len(oh.DataDirectory))]
+	case *pe.OptionalHeader64:
+		s.Type = "pe32+"
+		img.is64 = true
+		img.dirs = oh.DataDirectory[:min(int(oh.NumberOfRvaAndSizes), len(oh.DataDirectory))]
+	default:
+		// Object files have no optional header
+		s.Type = "coff"
+	}
+	s.Arch = peMachines[f.Machine]
+	if s.Arch == "" {
+		s.Arch = "0x" + strconv.FormatUint(uint64(f.Machine), 16)
+	}
+
+	if imports := img.imports(); len(imports) > 0 {
+		sum := md5.Sum([]byte(strings.Join(imports, ",")))
+		s.Imphash = hex.EncodeToString(sum[:])
+	}
+	s.Resources = img.resources()
+	return nil
+}
+
+// offset returns the file offset of rva
+func (img *peImage) offset(rva uint32) (int64, bool) {
+	for _, sec := range img.sections {
+		size := max(sec.VirtualSize, sec.Size)
+		if rva >= sec.VirtualAddress && rva-sec.VirtualAddress < size {
+			return int64(sec.Offset) + int64(rva-sec.VirtualAddress), true
+		}
+	}
+	return 0, false
+}
+
+// slice returns n bytes at rva, or nil if they are not in the file
+func (img *peImage) slice(rva uint32, n int) []byte {
+	off, ok := img.offset(rva)
+	if !ok || n < 0 || off+int64(n) > int64(len(img.content)) {
+		return nil
+	}
+	return img.content[off : off+int64(n)]
+}
+
+// maxNameLength bounds import and library names
+const maxNameLength = 512
+
+// cstring returns the NUL-terminated string at rva
+func (img *peImage) cstring(rva uint32) string {
+	off, ok := img.offset(rva)
+	if !ok || off >= int64(len(img.content)) {
+		return ""
+	}
+	rest := img.content[off:]
+	if i := bytes.IndexByte(rest, 0); i >= 0 {
+		rest = rest[:i]
+	}
+	return string(rest[:min(len(rest), maxNameLength)])
+}
+
+// imports returns the import table as "library.function" entries in the
+// order and normalisation used by pefile's imphash: library names are
+// lowercased with .dll/.ocx/.sys removed and imports by ordinal are written
+// "ordN". pefile additionally resolves a few ws2_32/wsock32/oleaut32
+// ordinals to names, which is not reproduced here.
+func (img *peImage) imports() []string {
+	if len(img.dirs) <= dirImport || img.dirs[dirImport].VirtualAddress == 0 {
+		return nil
+	}
+
+	thunkSize := 4
+	ordinalFlag := uint64(1) << 31
+	if img.is64 {
+		thunkSize = 8
+		ordinalFlag = uint64(1) << 63
+	}
+
+	var out []string
+	for desc := img.dirs[dirImport].VirtualAddress; len(out) < maxImports; desc += 20 {
+		d := img.slice(desc, 20)
+		if d == nil {
+			break
+		}
+		lookup := binary.LittleEndian.Uint32(d[0:])
+		nameRVA := binary.LittleEndian.Uint32(d[12:])
+		iat := binary.LittleEndian.Uint32(d[16:])
+		if nameRVA == 0 && lookup == 0 && iat == 0 {
+			break
+		}
+		if lookup == 0 {
+			lookup = iat
+		}
+
+		lib := strings.ToLower(img.cstring(nameRVA))
+		if dot := strings.LastIndexByte(lib, '.'); dot >= 0 {
+			switch lib[dot+1:] {
+			case "dll", "ocx", "sys":
+				lib = lib[:dot]
+			}
+		}
+
+		for thunk := lookup; len(out) < maxImports; thunk += uint32(thunkSize) {
+			t := img.slice(thunk, thunkSize)
+			if t == nil {
+				break
+			}
+			var v uint64
+			if img.is64 {
+				v = binary.LittleEndian.Uint64(t)
+			} else {
+				v = uint64(binary.LittleEndian.Uint32(t))
+			}
+			if v == 0 {
+				break
+			}
+
+			var fn string
+			if v&ordinalFlag != 0 {
+				fn = "ord" + strconv.FormatUint(v&0xffff, 10)
+			} else {
+				// Hint (2 bytes) then the name
+				fn = strings.ToLower(img.cstring(uint32(v) + 2))
+			}
+			if fn != "" {
+				out = append(out, lib+"."+fn)
+			}
+		}
+	}
+	return out
+}
+
+// resources walks the resource tree: type, name, language
+func (img *peImage) resources() []Resource {
+	if len(img.dirs) <= dirResource || img.dirs[dirResource].VirtualAddress == 0 {
+		return nil
+	}
+	var out []Resource
+	img.walkResources(img.dirs[dirResource].VirtualAddress, 0, 0, nil, &out)
+	return out
+}
+
+// walkResources descends one resource directory; offsets in the tree are
+// relative to the root
+func (img *peImage) walkResources(root, off uint32, depth int, path []string, out *[]Resource) {
+	if depth >= maxResourceDepth {
+		return
+	}
+	hdr := img.slice(root+off, 16)
+	if hdr == nil {
+		return
+	}
+	count := int(binary.LittleEndian.Uint16(hdr[12:])) + int(binary.LittleEndian.Uint16(hdr[14:]))
+
+	for i := 0; i < count && len(*out) < maxResources; i++ {
+		e := img.slice(root+off+16+uint32(i)*8, 8)
+		if e == nil {
+			return
+		}
+		id := binary.LittleEndian.Uint32(e[0:])
+		target := binary.LittleEndian.Uint32(e[4:])
+
+		label := img.resourceLabel(root, id, depth)
+		if target&0x80000000 != 0 {
+			img.walkResources(root, target&0x7fffffff, depth+1, append(path, label), out)
+			continue
+		}
+
+		// Data entry: RVA, size, code page, reserved
+		entry := img.slice(root+target, 16)
+		if entry == nil {
+			continue
+		}
+		size := int(binary.LittleEndian.Uint32(entry[4:]))
+		data := img.slice(binary.LittleEndian.Uint32(entry[0:]), size)
+		if data == nil {
+			continue
+		}
+
+		r := Resource{Size: size}
+		full := append(path, label)
+		if len(full) > 0 {
+			r.Type = full[0]
+		}
+		if len(full) > 1 {
+			r.Name = full[1]
+		}
+		sum := sha256.Sum256(data)
+		r.SHA256 = hex.EncodeToString(sum[:])
+		r.Executable = Detect(data) != ""
+		*out = append(*out, r)
+	}
+}
+
+// resourceLabel names a directory entry: RT_* for well-known types at the
+// top level, the UTF-16 name for named entries, and the ID otherwise
+func (img *peImage) resourceLabel(root, id uint32, depth int) string {
+	if id&0x80000000 != 0 {
+		off := root + id&0x7fffffff
+		if hdr := img.slice(off, 2); hdr != nil {
+			n := int(binary.LittleEndian.Uint16(hdr))
+			if b := img.slice(off+2, n*2); b != nil {
+				u := make([]uint16, n)
+				for i := range u {
+					u[i] = binary.LittleEndian.Uint16(b[i*2:])
+				}
+				return string(utf16.Decode(u))
+			}
+		}
+		return ""
+	}
+	if depth == 0 {
+		if name, ok := resourceTypes[id]; ok {
+			return name
+		}
+	}
+	return strconv.FormatUint(uint64(id), 10)
+}
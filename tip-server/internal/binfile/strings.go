@@ -0,0 +1,59 @@
+package binfile
+
+import "unicode/utf16"
+
+// maxStrings bounds the strings reported for one sample
+const maxStrings = 200000
+
+// Strings returns runs of at least minLen printable ASCII characters, and
+// of UTF-16LE encoded printable ASCII, as found by strings(1) -e l
+func Strings(content []byte, minLen int) []string {
+	if minLen < 1 {
+		minLen = 1
+	}
+	var out []string
+
+	start := -1
+	for i := 0; i <= len(content); i++ {
+		if i < len(content) && printable(content[i]) {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		if start >= 0 && i-start >= minLen {
+			out = append(out, string(content[start:i]))
+			if len(out) >= maxStrings {
+				return out
+			}
+		}
+		start = -1
+	}
+
+	// UTF-16LE runs, at both byte alignments
+	for align := 0; align < 2; align++ {
+		var run []uint16
+		for i := align; ; i += 2 {
+			if i+1 < len(content) && content[i+1] == 0 && printable(content[i]) {
+				run = append(run, uint16(content[i]))
+				continue
+			}
+			if len(run) >= minLen {
+				out = append(out, string(utf16.Decode(run)))
+				if len(out) >= maxStrings {
+					return out
+				}
+			}
+			run = run[:0]
+			if i+1 >= len(content) {
+				break
+			}
+		}
+	}
+	return out
+}
+
+// printable reports whether b is printable ASCII or a tab
+func printable(b byte) bool {
+	return b == '\t' || (b >= 0x20 && b < 0x7f)
+}
@@ -0,0 +1,145 @@
+// Package clamav is a minimal clamd client: content is streamed over the
+// INSTREAM command on a Unix or TCP socket, so the daemon needs no access to
+// the ingestor's filesystem.
+package clamav
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// chunkSize is the INSTREAM chunk length
+const chunkSize = 64 * 1024
+
+// ErrSizeLimit is returned when clamd rejects a stream longer than its
+// StreamMaxLength
+var ErrSizeLimit = errors.New("clamd stream size limit exceeded")
+
+// Client talks to one clamd instance
+type Client struct {
+	network string
+	address string
+	timeout time.Duration
+}
+
+// Result is the verdict for one scanned stream
+type Result struct {
+	Infected  bool
+	Signature string // e.g. "Win.Trojan.Emotet-6913235-0"; "" when clean
+}
+
+// New returns a client for addr, either "unix:///path/to/clamd.ctl" or
+// "tcp://host:port"
+func New(addr string, timeout time.Duration) (*Client, error) {
+	network, address, ok := strings.Cut(addr, "://")
+	if !ok || address == "" {
+		return nil, fmt.Errorf("invalid clamd address %q", addr)
+	}
+	switch network {
+	case "unix", "tcp":
+	default:
+		return nil, fmt.Errorf("unsupported clamd network %q", network)
+	}
+	return &Client{network: network, address: address, timeout: timeout}, nil
+}
+
+// Ping checks that clamd is answering
+func (c *Client) Ping(ctx context.Context) error {
+	reply, err := c.command(ctx, "zPING\x00", nil)
+	if err != nil {
+		return err
+	}
+	if reply != "PONG" {
+		return fmt.Errorf("unexpected clamd reply %q", reply)
+	}
+	return nil
+}
+
+// Scan streams content to clamd and returns its verdict
+func (c *Client) Scan(ctx context.Context, content []byte) (Result, error) {
+	reply, err := c.command(ctx, "zINSTREAM\x00", content)
+	if err != nil {
+		return Result{}, err
+	}
+
+	// "stream: OK", "stream: <signature> FOUND" or "<reason> ERROR"
+	reply = strings.TrimPrefix(reply, "stream: ")
+	switch {
+	case reply == "OK":
+		return Result{}, nil
+	case strings.HasSuffix(reply, " FOUND"):
+		return Result{Infected: true, Signature: strings.TrimSuffix(reply, " FOUND")}, nil
+	case strings.Contains(reply, "size limit exceeded"):
+		return Result{}, ErrSizeLimit
+	default:
+		return Result{}, fmt.Errorf("clamd: %s", reply)
+	}
+}
+
+// command sends a null-terminated command, optionally followed by content
+// in INSTREAM chunks, and returns the reply without its terminator
+func (c *Client) command(ctx context.Context, cmd string, content []byte) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, c.network, c.address)
+	if err != nil {
+		return "", fmt.Errorf("failed to connect to clamd: %w", err)
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	w := bufio.NewWriterSize(conn, chunkSize+4)
+	w.WriteString(cmd)
+	if content != nil {
+		var size [4]byte
+		for len(content) > 0 {
+			n := min(len(content), chunkSize)
+			binary.BigEndian.PutUint32(size[:], uint32(n))
+			w.Write(size[:])
+			w.Write(content[:n])
+			content = content[n:]
+		}
+		// Zero-length chunk ends the stream
+		w.Write([]byte{0, 0, 0, 0})
+	}
+	if err := w.Flush(); err != nil {
+		return "", fmt.Errorf("failed to send to clamd: %w", err)
+	}
+
+	reply, err := bufio.NewReader(conn).ReadBytes(0)
+	if err != nil && len(reply) == 0 {
+		return "", fmt.Errorf("failed to read clamd reply: %w", err)
+	}
+	return string(bytes.TrimRight(reply, "\x00\n")), nil
+}
+
+// signatureSuffix matches the "-<sigid>-<revision>" suffix of a ClamAV
+// signature name
+var signatureSuffix = regexp.MustCompile(`(-\d+)+$`)
+
+// Family derives a malware family name from a ClamAV signature following
+// the Platform.Category.Name-SigID-Revision convention, e.g. "Emotet" from
+// "Doc.Dropper.Emotet-6913235-0". Signatures outside the convention are
+// returned without their numeric suffix.
+func Family(signature string) string {
+	name := signature
+	if parts := strings.Split(signature, "."); len(parts) >= 3 {
+		name = strings.Join(parts[2:], ".")
+	}
+	if trimmed := signatureSuffix.ReplaceAllString(name, ""); trimmed != "" {
+		name = trimmed
+	}
+	return name
+}
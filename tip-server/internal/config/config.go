@@ -2,6 +2,7 @@ package config
 
 import (
 	"os"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
@@ -13,8 +14,13 @@ import (
 
 // Config holds all application configuration
 type Config struct {
-	// Data Source
-	DataPath string
+	// Deployment environment ("development" or "production")
+	Environment string
+
+	// Data sources: the directories crawled, from DATA_PATH, a
+	// comma-separated list of paths each optionally named by the source its
+	// files are attributed to ("abusech=/data/abusech")
+	DataRoots []DataRoot
 
 	// ClickHouse
 	ClickHouse ClickHouseConfig
@@ -25,15 +31,60 @@ type Config struct {
 	// MinIO
 	MinIO MinIOConfig
 
-	// Qdrant (Phase 2)
+	// Qdrant vector store for similarity search
 	Qdrant QdrantConfig
 
+	// Content embeddings for similarity search
+	Embedding EmbeddingConfig
+
+	// Periodic clustering of file vectors (GET /clusters)
+	Cluster ClusterConfig
+
+	// Scheduled Parquet snapshots of the IOC store in MinIO
+	Export ExportConfig
+
+	// Signatures on export feeds (snapshots and GET /sync/iocs)
+	Signing SigningConfig
+
+	// Scheduled cross-store consistency checks (GET /admin/consistency)
+	Consistency ConsistencyConfig
+
+	// Anomaly detection on ingestion volume (GET /admin/volume)
+	Volume VolumeConfig
+
+	// Pulling IOCs from a primary deployment (replica mode)
+	Sync SyncConfig
+
+	// Consumer subscriptions to newly ingested IOCs
+	Subscriptions SubscriptionConfig
+
 	// API Server
 	API APIConfig
 
 	// Worker Settings
 	Worker WorkerConfig
 
+	// Extraction limits
+	Extractor ExtractorConfig
+
+	// Email address redaction at rest and in responses
+	Redaction RedactionConfig
+
+	// Syslog listener (ingestor --listen)
+	Syslog SyslogConfig
+
+	// External event bus
+	EventBus EventBusConfig
+
+	// External reputation enrichment (VirusTotal, AbuseIPDB)
+	Enrichment EnrichmentConfig
+
+	// Background DNS resolution of domain IOCs
+	DNS DNSConfig
+
+	// Antivirus scanning of ingested files (clamd)
+	ClamAV ClamAVConfig
+
 	// Logging
 	Log LogConfig
 
@@ -42,11 +93,14 @@ type Config struct {
 }
 
 type ClickHouseConfig struct {
-	Host     string
-	Port     int
-	Database string
-	User     string
-	Password string
+	Host          string
+	Port          int
+	Database      string
+	User          string
+	Password      string
+	HTTPPort      int      // HTTP interface, used for Parquet exports
+	MaxRowsPerIOC int      // Newest sightings aggregated per value in lookups
+	ReadReplicas  []string // host:port endpoints for analytical reads; empty = primary
 }
 
 type RedisConfig struct {
@@ -57,6 +111,25 @@ type RedisConfig struct {
 	BloomFilterName     string
 	BloomFilterErrorRate float64
 	BloomFilterCapacity int64
+
+	// BloomRebuildInterval controls how often the filter is rebuilt from
+	// ClickHouse to drop removed IOCs (0 = disabled)
+	BloomRebuildInterval time.Duration
+
+	// BloomSnapshotInterval controls how often the filter is dumped to MinIO
+	// (0 = disabled). With BloomSnapshotRestore, an empty filter is loaded
+	// from the latest snapshot at startup instead of waiting for a rebuild
+	BloomSnapshotInterval time.Duration
+	BloomSnapshotRestore  bool
+
+	// LookupCacheTTL is how long /check keeps matched IOCs in Redis in front
+	// of ClickHouse (0 = disabled)
+	LookupCacheTTL time.Duration
+
+	// NegativeCacheTTL is how long /check remembers values it did not find,
+	// answering repeats without the Bloom filter or ClickHouse. Entries are
+	// dropped when the value is added to the Bloom filter (0 = disabled)
+	NegativeCacheTTL time.Duration
 }
 
 type MinIOConfig struct {
@@ -65,25 +138,426 @@ type MinIOConfig struct {
 	SecretKey string
 	Bucket    string
 	UseSSL    bool
+
+	// QuarantineBucket holds malware samples apart from misc content so
+	// bucket access can be granted separately ("" = quarantine/ prefix of
+	// Bucket)
+	QuarantineBucket string
+
+	// Server-side encryption
+	SSEType           string // "", "sse-s3", "sse-kms" or "sse-c"
+	SSEKMSKeyID       string // KMS key ID (sse-kms)
+	SSECKey           string // Base64-encoded 32-byte customer key (sse-c)
+	RequireEncryption bool   // Refuse to start without an SSE mode configured
+
+	// Lifecycle management
+	ExpirationDays         int           // Expire objects after N days (0 = never)
+	TransitionDays         int           // Transition objects after N days (0 = never)
+	TransitionStorageClass string        // Target tier for transitions
+	OrphanCleanupInterval  time.Duration // How often to remove unreferenced objects (0 = disabled)
+	OrphanGracePeriod      time.Duration // Minimum object age before it can be removed as orphaned
+
+	// DeletePolicy is what POST /admin/delete does with the stored content of
+	// deleted files unless the request says otherwise: "retain" or "delete"
+	// (see models.DeletePolicyRetain)
+	DeletePolicy string
+
+	// Compression
+	Compression        string // "", "gzip" or "zstd"
+	CompressionMinSize int    // Objects smaller than this are stored uncompressed
 }
 
 type QdrantConfig struct {
+	Enabled    bool
 	Host       string
 	GRPCPort   int
 	RESTPort   int
 	Collection string
 }
 
+// EmbeddingConfig selects how misc file content is turned into vectors
+type EmbeddingConfig struct {
+	Provider   string // hash, openai, onnx
+	Dimensions int
+	MaxBytes   int // content beyond this many bytes is not embedded
+
+	// OpenAI-compatible HTTP endpoint (provider "openai")
+	URL     string
+	Model   string
+	APIKey  string
+	Timeout time.Duration
+
+	// Local ONNX sentence-transformer model (provider "onnx", requires the
+	// onnx build tag)
+	ONNXModel   string // path to model.onnx
+	ONNXVocab   string // path to the WordPiece vocab.txt
+	ONNXRuntime string // path to the onnxruntime shared library
+	MaxTokens   int
+}
+
+// ClusterConfig controls the periodic clustering of indexed file vectors
+type ClusterConfig struct {
+	Interval      time.Duration // 0 disables clustering
+	Threshold     float64       // minimum cosine similarity linking two files
+	Neighbors     int           // nearest neighbours considered per file
+	MaxPoints     int           // vectors read per run
+	MinSize       int           // smallest cluster reported
+	MaxMembers    int           // members listed per cluster
+	MaxIndicators int           // indicators listed per cluster
+}
+
+type ExportConfig struct {
+	Interval  time.Duration // 0 disables scheduled exports
+	Retention int           // snapshots kept in MinIO (0 = keep all)
+}
+
+// SigningConfig controls how export feeds are signed (see package signing)
+type SigningConfig struct {
+	KeyFile string // PEM (PKCS #8) Ed25519 private key ("" = unsigned)
+	Format  string // "jws" or "minisign"
+}
+
+// ConsistencyConfig controls the periodic cross-check of the IOC store, the
+// Bloom filter, MinIO and the file registry
+type ConsistencyConfig struct {
+	Interval    time.Duration // 0 disables the check
+	GracePeriod time.Duration // Entries younger than this may be mid-ingestion and are not reported
+	SampleSize  int           // Orphaned or missing entries listed per check
+}
+
+// VolumeConfig controls the baselining of ingestion volume per feed and
+// IOC type, and the alerts raised on sudden drops and spikes
+type VolumeConfig struct {
+	Interval        time.Duration // How often volume is checked (0 disables recording and checks)
+	Window          time.Duration // Span compared against the baseline, in whole hours
+	BaselineWindows int           // Preceding windows the baseline is the median of
+	SpikeFactor     float64       // Alert when a window exceeds the baseline this many times
+	DropFactor      float64       // Alert when a window falls below this fraction of the baseline
+	MinCount        int64         // Baselines below this are too small to judge
+	WebhookURL      string        // Alerts are POSTed here ("" = metrics and logs only)
+}
+
+// Retention is how long hourly volume counts are kept: the baseline, the
+// window under check and the hour in progress
+func (c VolumeConfig) Retention() time.Duration {
+	return time.Duration(c.BaselineWindows+1)*c.Window + time.Hour
+}
+
+// RedactionConfig controls how email addresses are stored (see package
+// redact)
+type RedactionConfig struct {
+	Mode string // "off", "hash" or "partial"
+	Key  string // Secret keying the stored tokens and sealing the originals
+}
+
+type SyncConfig struct {
+	PrimaryURL string        // Base URL of the primary's API ("" = not a replica)
+	APIKey     string        // Admin key on the primary
+	Interval   time.Duration // How often to pull new IOCs
+	BatchSize  int           // IOCs requested per page
+	Timeout    time.Duration // Per-request timeout
+	VerifyKey  string        // Public key the primary signs pages with ("" = not verified)
+}
+
+// SubscriptionConfig controls consumer subscriptions (POST /subscriptions)
+// and the fan-out of new IOCs to them
+type SubscriptionConfig struct {
+	MaxPerKey     int           // Subscriptions one API key may register (0 = disabled)
+	FlushInterval time.Duration // How long new IOCs are batched before delivery
+	BatchSize     int           // IOCs per delivery
+	Timeout       time.Duration // Per-delivery timeout
+	TopicPrefix   string        // Prefix every subscribed Kafka topic must carry
+	AllowPrivate  bool          // Allow webhook and TAXII URLs on private or loopback addresses
+}
+
 type APIConfig struct {
-	Host   string
-	Port   int
-	APIKey string
+	Host        string
+	Port        int
+	APIKey      string
+	AdminAPIKey string // Grants access to admin endpoints (empty = admin API disabled)
+	RateLimit   int    // Requests per minute per API key (0 = unlimited)
+
+	// Per-source-IP protection
+	IPRateLimit       int           // Requests per minute per client IP (0 = unlimited)
+	AuthFailureLimit  int           // Auth failures per window before an IP is blocked (0 = never)
+	AuthFailureWindow time.Duration // Window over which auth failures are counted
+	AutoBlockDuration time.Duration // How long automatically blocked IPs stay blocked
+	ProxyHeader       string        // Header carrying the client IP when behind a trusted proxy
+	TrustedProxies    []string      // Peer IPs or CIDRs whose ProxyHeader is honored
+
+	// Input limits
+	BodyLimit       int // Maximum request body size in bytes
+	MaxIOCLength    int // Maximum length of a single submitted IOC value
+	MaxInflatedBody int // Maximum gzip/zstd request body size after decompression
+
+	// Submitted IOCs (event bus, /admin/import) that are valid IOCs of a
+	// type other than the declared one are rejected ("reject") or stored
+	// under the detected type ("correct"); a submission may choose per batch
+	SubmissionTypeMismatch string
+
+	// /check ClickHouse lookups are split into chunks queried in parallel
+	CheckQueryChunk       int  // Values per ClickHouse query
+	CheckQueryConcurrency int  // Parallel queries per request
+	CheckCoalesce         bool // Share ClickHouse lookups of a value between concurrent requests
+
+	// Sources listed per /check match (?max_sources= overrides, 0 = none)
+	CheckMaxSources int
+
+	// POST /search/regex scans the IOC store, so it is bounded in time and
+	// rows read, and slow searches continue as background jobs
+	RegexSearchTimeout    time.Duration // Synchronous budget before falling back to a job
+	RegexSearchJobTimeout time.Duration // Budget of a background job
+	RegexSearchMaxRows    int           // Rows a single search may read (0 = unlimited)
+	RegexSearchMaxJobs    int           // Background jobs running at once
+	RegexSearchResultTTL  time.Duration // How long job results can be polled
+
+	// TLS termination: either a static certificate pair or autocert domains
+	TLSCertFile         string
+	TLSKeyFile          string
+	TLSAutocertDomains  []string // Obtain certificates from Let's Encrypt for these hosts
+	TLSAutocertEmail    string   // Contact address registered with the ACME account
+	TLSAutocertCacheDir string   // Where issued certificates are cached across restarts
+	TLSRedirectPort     int      // Plain HTTP port redirecting to HTTPS (0 = disabled)
+	HSTSMaxAge          time.Duration
+
+	// SelfTestInterval is how often a synthetic IOC is inserted and looked
+	// up end to end (0 = disabled)
+	SelfTestInterval time.Duration
+
+	// RangeRefreshInterval is how often CIDR indicators are reloaded for
+	// matching /check addresses against (0 = disabled)
+	RangeRefreshInterval time.Duration
+
+	// TimeFormat is how JSON responses render timestamps unless a request
+	// asks otherwise (?time_format=): "rfc3339" as stored, or "both" for
+	// RFC 3339 in UTC plus epoch milliseconds (see models.TimeFormatBoth)
+	TimeFormat string
+}
+
+// TLSEnabled reports whether the API server terminates TLS itself
+func (c APIConfig) TLSEnabled() bool {
+	return c.TLSCertFile != "" || c.TLSKeyFile != "" || len(c.TLSAutocertDomains) > 0
 }
 
 type WorkerConfig struct {
 	Count          int
 	BatchSize      int
 	FileExtensions []string
+
+	// ChangeDetection selects how unchanged files are detected:
+	// "mtime" skips on matching size+mtime and falls back to content hash;
+	// "hash" always reads and hashes content (safe against preserved mtimes)
+	ChangeDetection string
+
+	// FileIdentity selects what a file_id is derived from: "path",
+	// "content", "path_content" or "inode" (see models.FileIdentityPath)
+	FileIdentity string
+
+	// Deletion detection
+	DetectDeletions      bool // Tombstone registry entries whose files vanished
+	DeprecateDeletedIOCs bool // Also deprecate IOCs extracted from deleted files
+
+	// ShutdownTimeout bounds how long queued jobs are drained on shutdown
+	ShutdownTimeout time.Duration
+
+	// WatchInterval re-runs ingestion on this interval (0 = run once and exit)
+	WatchInterval time.Duration
+
+	// PolicyFile lists directories with their own rescan interval, priority
+	// and extraction profile, crawled instead of the DATA_PATH roots (see
+	// LoadIngestPolicies)
+	PolicyFile string
+
+	// Job queue: how many crawled files wait for a worker, and which are
+	// processed first among them: "size" (smallest), "recent" (newest
+	// mtime) or "fifo" (crawl order). Submitted files always go first.
+	QueueSize  int
+	QueueOrder string
+
+	// Per-file safeguards; the per-file IOC cap is EXTRACT_MAX_PER_FILE
+	MaxFileSize int64         // Files larger than this many bytes are not read (0 = unlimited)
+	FileTimeout time.Duration // Extraction of a single file is abandoned after this long (0 = unlimited)
+
+	// Crawl filters
+	FollowSymlinks bool     // Read symlinked files and directories, each target once, instead of skipping them
+	SkipHidden     bool     // Skip dot-files, dot-directories and OS system folders
+	ExcludeGlobs   []string // Skip paths matching any pattern, relative to the crawled directory ("**" spans directories)
+
+	// StructuredLogs extracts Suricata EVE / Zeek JSON logs from their typed
+	// fields instead of regex-scanning whole lines
+	StructuredLogs bool
+
+	// StructuredFeeds parses OpenIOC and STIX 1.x/2.x documents structurally,
+	// keeping the source's confidence, labels and malware family
+	StructuredFeeds bool
+
+	// DetectLanguage records each document's language in the file registry
+	DetectLanguage bool
+
+	// NormalizeText folds fullwidth characters, ideographic full stops and
+	// IDNs before regex extraction
+	NormalizeText bool
+
+	// ParseHTML scans only the visible text, links and script sources of
+	// HTML documents rather than the raw markup
+	ParseHTML bool
+
+	// ParseEmail reads .eml/.msg messages by header, body and attachment,
+	// tagging each IOC with where it was found
+	ParseEmail bool
+
+	// ParsePCAP reads .pcap/.pcapng captures into DNS, HTTP, TLS SNI and
+	// conversation IOCs tagged with their flows
+	ParsePCAP bool
+
+	// ParseExecutables records hashes, imphash and file type of PE/ELF
+	// samples and scans their embedded strings instead of the raw bytes
+	ParseExecutables bool
+	MinStringLength  int // Shortest embedded string scanned, in characters
+
+	// QuarantineSamples stores executables in MinIO quarantine;
+	// QuarantineInfected also stores every other file IOCs were found in
+	QuarantineSamples  bool
+	QuarantineInfected bool
+
+	// DisabledHandlers lists extraction handlers to skip; files they would
+	// have claimed fall through to the next matching handler
+	DisabledHandlers []string
+
+	// Bloom filter additions from the workers are batched across files and
+	// flushed by one goroutine once BloomBatchSize values are pending or
+	// BloomFlushInterval has passed; failed flushes are retried
+	BloomBatchSize     int
+	BloomFlushInterval time.Duration
+	BloomAddRetries    int
+
+	// ReviewConfidence holds extracted IOCs with a confidence below it for
+	// analyst review, out of /check until approved (0 = disabled)
+	ReviewConfidence int
+}
+
+// Extraction handler names, in the order the ingestor tries them
+const (
+	HandlerBinary = "binary"
+	HandlerFeeds  = "feeds"
+	HandlerEmail  = "eml"
+	HandlerPCAP   = "pcap"
+	HandlerLogs   = "logs"
+	HandlerPDF    = "pdf"
+	HandlerHTML   = "html"
+	HandlerText   = "text"
+)
+
+// ExtractionHandlers returns all extraction handler names
+func ExtractionHandlers() []string {
+	return []string{HandlerBinary, HandlerFeeds, HandlerEmail, HandlerPCAP, HandlerLogs, HandlerPDF, HandlerHTML, HandlerText}
+}
+
+// HandlerEnabled reports whether the named extraction handler is enabled,
+// by DISABLED_HANDLERS and the handler's own switch where it has one
+func (c WorkerConfig) HandlerEnabled(name string) bool {
+	if slices.Contains(c.DisabledHandlers, name) {
+		return false
+	}
+	switch name {
+	case HandlerBinary:
+		return c.ParseExecutables
+	case HandlerFeeds:
+		return c.StructuredFeeds
+	case HandlerEmail:
+		return c.ParseEmail
+	case HandlerPCAP:
+		return c.ParsePCAP
+	case HandlerLogs:
+		return c.StructuredLogs
+	case HandlerHTML:
+		return c.ParseHTML
+	}
+	return true
+}
+
+type ExtractorConfig struct {
+	MaxURLLength int // URLs longer than this are discarded
+	MaxPerType   int // Max unique IOCs kept per type per file (0 = unlimited)
+	MaxPerFile   int // Max unique IOCs kept per file across all types (0 = unlimited)
+
+	// Curated lists extending the built-in known-bad entries, one per line
+	MutexListFile     string // Mutex names; a trailing * matches any suffix
+	UserAgentListFile string // Case-insensitive user agent substrings
+
+	// Operator lists of known-benign and known-bad values (see extractor/lists.go)
+	AllowlistFiles      []string      // Never extracted, reported as benign by /check
+	DenylistFiles       []string      // Always reported as matches; carves exceptions out of the allowlist
+	ListRefreshInterval time.Duration // How often long-running processes re-read the lists (0 = startup only)
+
+	// Ranked popular domains (Tranco "rank,domain" CSV) tagged on extraction
+	// and down-weighted by /check
+	PopularityListFile string
+	PopularityTopN     int // Only domains ranked within this are considered popular (0 = all)
+
+	// Percentage of extracted IOCs kept with their context for labeling via
+	// /samples (0 = off)
+	SampleRate float64
+}
+
+type SyslogConfig struct {
+	TCPAddr        string        // TCP listen address (empty = disabled)
+	UDPAddr        string        // UDP listen address (empty = disabled)
+	BatchSize      int           // Messages matched per lookup round-trip
+	FlushInterval  time.Duration // Max time a message waits before its batch is matched
+	MaxMessageSize int           // Longer TCP frames close the connection; UDP datagrams are truncated
+	StoreIOCs      bool          // Also store extracted indicators (off: match only)
+}
+
+type EventBusConfig struct {
+	Type            string   // "", "kafka" or "nats"
+	Brokers         []string // Kafka brokers (host:port) or NATS server URLs
+	Topic           string   // Kafka topic, or NATS subject prefix, for published events
+	SubmissionTopic string   // Topic/subject consumed for IOC submissions (empty = disabled)
+	ConsumerGroup   string   // Kafka consumer group / NATS queue group
+}
+
+type EnrichmentConfig struct {
+	VirusTotalAPIKey    string
+	VirusTotalRateLimit int // Lookups per minute, shared by all API instances
+	AbuseIPDBAPIKey     string
+	AbuseIPDBRateLimit  int // Lookups per minute, shared by all API instances
+
+	CacheTTL         time.Duration // How long provider verdicts (including "unknown") are cached
+	Timeout          time.Duration // Budget for enriching one /check request
+	MaxPerRequest    int           // Max matched IOCs enriched per /check request
+	AdjustConfidence bool          // Blend provider scores into the reported confidence
+
+	// Domain age (newly registered domain detection) via RDAP/WHOIS
+	WhoisEnabled       bool
+	WhoisRDAPURL       string        // RDAP domain endpoint; the domain is appended
+	WhoisRateLimit     int           // Lookups per minute, shared by all API instances
+	WhoisCacheTTL      time.Duration // How long registration dates are cached
+	NRDDays            int           // Domains registered within this many days are flagged
+	NRDConfidenceBoost int           // Added to the confidence of newly registered domains
+}
+
+// Enabled reports whether any enrichment provider is configured
+func (c EnrichmentConfig) Enabled() bool {
+	return c.VirusTotalAPIKey != "" || c.AbuseIPDBAPIKey != ""
+}
+
+// ClamAVConfig enables scanning ingested files with clamd
+type ClamAVConfig struct {
+	Address         string        // unix:///path or tcp://host:port ("" = disabled)
+	Timeout         time.Duration // Per file, including transfer
+	MaxSize         int           // Larger files are not sent (keep <= clamd StreamMaxLength)
+	ConfidenceBoost int           // Added to the confidence of IOCs from detected files
+}
+
+type DNSConfig struct {
+	ResolveInterval time.Duration // How often the resolver job runs (0 = disabled)
+	MaxAge          time.Duration // Re-resolve domains whose last resolution is older than this
+	BatchSize       int           // Domains resolved per run
+	Concurrency     int           // Parallel lookups
+	Timeout         time.Duration // Per-lookup timeout
+	Server          string        // Resolver host:port (empty = system resolver)
+	Sinkholes       []string      // CIDRs/IPs that mark a domain as sinkholed
 }
 
 type LogConfig struct {
@@ -93,24 +567,49 @@ type LogConfig struct {
 }
 
 type MetricsConfig struct {
-	Enabled bool
-	Port    int
+	Enabled      bool
+	Port         int
+	IngestorPort int // Separate port so the API and ingestor can share a host
 }
 
 // Load reads configuration from environment variables
 func Load() (*Config, error) {
 	// Load .env file if it exists (ignore error if not found)
-	_ = godotenv.Load()
+	_ = godotenv.Load(configFile())
+
+	cfg := fromEnv()
+
+	// Initialize logger based on config
+	initLogger(cfg.Log)
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+// configFile returns the env file read at startup and on reload
+func configFile() string {
+	return getEnv("CONFIG_FILE", ".env")
+}
 
-	cfg := &Config{
-		DataPath: getEnv("DATA_PATH", "/data"),
+// fromEnv builds a Config from the current environment
+func fromEnv() *Config {
+	return &Config{
+		Environment: strings.ToLower(getEnv("APP_ENV", EnvDevelopment)),
+
+		DataRoots: parseDataRoots(getEnvSlice("DATA_PATH", []string{"/data"})),
 
 		ClickHouse: ClickHouseConfig{
-			Host:     getEnv("CLICKHOUSE_HOST", "localhost"),
-			Port:     getEnvInt("CLICKHOUSE_PORT", 9000),
-			Database: getEnv("CLICKHOUSE_DATABASE", "threat_intel"),
-			User:     getEnv("CLICKHOUSE_USER", "default"),
-			Password: getEnv("CLICKHOUSE_PASSWORD", ""),
+			Host:          getEnv("CLICKHOUSE_HOST", "localhost"),
+			Port:          getEnvInt("CLICKHOUSE_PORT", 9000),
+			Database:      getEnv("CLICKHOUSE_DATABASE", "threat_intel"),
+			User:          getEnv("CLICKHOUSE_USER", "default"),
+			Password:      getEnv("CLICKHOUSE_PASSWORD", ""),
+			HTTPPort:      getEnvInt("CLICKHOUSE_HTTP_PORT", 8123),
+			MaxRowsPerIOC: getEnvInt("CLICKHOUSE_MAX_ROWS_PER_IOC", 1000),
+			ReadReplicas:  getEnvSlice("CLICKHOUSE_READ_REPLICAS", nil),
 		},
 
 		Redis: RedisConfig{
@@ -121,6 +620,12 @@ func Load() (*Config, error) {
 			BloomFilterName:     getEnv("BLOOM_FILTER_NAME", "ioc_bloom"),
 			BloomFilterErrorRate: getEnvFloat("BLOOM_FILTER_ERROR_RATE", 0.001),
 			BloomFilterCapacity: getEnvInt64("BLOOM_FILTER_CAPACITY", 10000000),
+
+			BloomRebuildInterval:  getEnvDuration("BLOOM_REBUILD_INTERVAL", time.Hour),
+			BloomSnapshotInterval: getEnvDuration("BLOOM_SNAPSHOT_INTERVAL", time.Hour),
+			BloomSnapshotRestore:  getEnvBool("BLOOM_SNAPSHOT_RESTORE", true),
+			LookupCacheTTL:        getEnvDuration("LOOKUP_CACHE_TTL", 5*time.Minute),
+			NegativeCacheTTL:      getEnvDuration("NEGATIVE_CACHE_TTL", time.Minute),
 		},
 
 		MinIO: MinIOConfig{
@@ -129,25 +634,270 @@ func Load() (*Config, error) {
 			SecretKey: getEnv("MINIO_SECRET_KEY", "SuperSecretPassword123"),
 			Bucket:    getEnv("MINIO_BUCKET", "misc-data"),
 			UseSSL:    getEnvBool("MINIO_USE_SSL", false),
+
+			QuarantineBucket: getEnv("MINIO_QUARANTINE_BUCKET", ""),
+
+			SSEType:           strings.ToLower(getEnv("MINIO_SSE_TYPE", "")),
+			SSEKMSKeyID:       getEnv("MINIO_SSE_KMS_KEY_ID", ""),
+			SSECKey:           getEnv("MINIO_SSE_C_KEY", ""),
+			RequireEncryption: getEnvBool("MINIO_REQUIRE_ENCRYPTION", false),
+
+			ExpirationDays:         getEnvInt("MINIO_EXPIRATION_DAYS", 0),
+			TransitionDays:         getEnvInt("MINIO_TRANSITION_DAYS", 0),
+			TransitionStorageClass: getEnv("MINIO_TRANSITION_STORAGE_CLASS", ""),
+			OrphanCleanupInterval:  getEnvDuration("MINIO_ORPHAN_CLEANUP_INTERVAL", 0),
+			OrphanGracePeriod:      getEnvDuration("MINIO_ORPHAN_GRACE_PERIOD", 24*time.Hour),
+
+			DeletePolicy: strings.ToLower(getEnv("MINIO_DELETE_POLICY", "retain")),
+
+			Compression:        strings.ToLower(getEnv("MINIO_COMPRESSION", "")),
+			CompressionMinSize: getEnvInt("MINIO_COMPRESSION_MIN_SIZE", 1024),
 		},
 
 		Qdrant: QdrantConfig{
+			Enabled:    getEnvBool("QDRANT_ENABLED", false),
 			Host:       getEnv("QDRANT_HOST", "localhost"),
 			GRPCPort:   getEnvInt("QDRANT_GRPC_PORT", 6334),
 			RESTPort:   getEnvInt("QDRANT_REST_PORT", 6333),
 			Collection: getEnv("QDRANT_COLLECTION", "threat_vectors"),
 		},
 
+		Embedding: EmbeddingConfig{
+			Provider:   getEnv("EMBEDDING_PROVIDER", "hash"),
+			Dimensions: getEnvInt("EMBEDDING_DIMENSIONS", 384),
+			MaxBytes:   getEnvInt("EMBEDDING_MAX_BYTES", 65536),
+
+			URL:     getEnv("EMBEDDING_URL", "https://api.openai.com/v1"),
+			Model:   getEnv("EMBEDDING_MODEL", "text-embedding-3-small"),
+			APIKey:  getEnv("EMBEDDING_API_KEY", ""),
+			Timeout: getEnvDuration("EMBEDDING_TIMEOUT", 30*time.Second),
+
+			ONNXModel:   getEnv("EMBEDDING_ONNX_MODEL", ""),
+			ONNXVocab:   getEnv("EMBEDDING_ONNX_VOCAB", ""),
+			ONNXRuntime: getEnv("EMBEDDING_ONNX_RUNTIME", ""),
+			MaxTokens:   getEnvInt("EMBEDDING_MAX_TOKENS", 256),
+		},
+
+		Cluster: ClusterConfig{
+			Interval:      getEnvDuration("CLUSTER_INTERVAL", 0),
+			Threshold:     getEnvFloat("CLUSTER_THRESHOLD", 0.8),
+			Neighbors:     getEnvInt("CLUSTER_NEIGHBORS", 10),
+			MaxPoints:     getEnvInt("CLUSTER_MAX_POINTS", 10000),
+			MinSize:       getEnvInt("CLUSTER_MIN_SIZE", 2),
+			MaxMembers:    getEnvInt("CLUSTER_MAX_MEMBERS", 20),
+			MaxIndicators: getEnvInt("CLUSTER_MAX_INDICATORS", 50),
+		},
+
+		Export: ExportConfig{
+			Interval:  getEnvDuration("EXPORT_INTERVAL", 0),
+			Retention: getEnvInt("EXPORT_RETENTION", 7),
+		},
+
+		Signing: SigningConfig{
+			KeyFile: getEnv("FEED_SIGNING_KEY_FILE", ""),
+			Format:  strings.ToLower(getEnv("FEED_SIGNING_FORMAT", "jws")),
+		},
+
+		Consistency: ConsistencyConfig{
+			Interval:    getEnvDuration("CONSISTENCY_CHECK_INTERVAL", 24*time.Hour),
+			GracePeriod: getEnvDuration("CONSISTENCY_GRACE_PERIOD", time.Hour),
+			SampleSize:  getEnvInt("CONSISTENCY_SAMPLE_SIZE", 20),
+		},
+
+		Volume: VolumeConfig{
+			Interval:        getEnvDuration("VOLUME_CHECK_INTERVAL", time.Hour),
+			Window:          getEnvDuration("VOLUME_WINDOW", 24*time.Hour),
+			BaselineWindows: getEnvInt("VOLUME_BASELINE_WINDOWS", 7),
+			SpikeFactor:     getEnvFloat("VOLUME_SPIKE_FACTOR", 5),
+			DropFactor:      getEnvFloat("VOLUME_DROP_FACTOR", 0.2),
+			MinCount:        getEnvInt64("VOLUME_MIN_COUNT", 100),
+			WebhookURL:      getEnv("VOLUME_ALERT_WEBHOOK_URL", ""),
+		},
+
+		Redaction: RedactionConfig{
+			Mode: strings.ToLower(getEnv("EMAIL_REDACTION", "off")),
+			Key:  getEnv("EMAIL_REDACTION_KEY", ""),
+		},
+
+		Sync: SyncConfig{
+			PrimaryURL: strings.TrimSuffix(getEnv("SYNC_PRIMARY_URL", ""), "/"),
+			APIKey:     getEnv("SYNC_API_KEY", ""),
+			Interval:   getEnvDuration("SYNC_INTERVAL", 5*time.Minute),
+			BatchSize:  getEnvInt("SYNC_BATCH_SIZE", 5000),
+			Timeout:    getEnvDuration("SYNC_TIMEOUT", time.Minute),
+			VerifyKey:  getEnv("SYNC_VERIFY_KEY", ""),
+		},
+
+		Subscriptions: SubscriptionConfig{
+			MaxPerKey:     getEnvInt("SUBSCRIPTION_MAX_PER_KEY", 10),
+			FlushInterval: getEnvDuration("SUBSCRIPTION_FLUSH_INTERVAL", 5*time.Second),
+			BatchSize:     getEnvInt("SUBSCRIPTION_BATCH_SIZE", 500),
+			Timeout:       getEnvDuration("SUBSCRIPTION_TIMEOUT", 10*time.Second),
+			TopicPrefix:   getEnv("SUBSCRIPTION_TOPIC_PREFIX", "tip.subscriptions."),
+			AllowPrivate:  getEnvBool("SUBSCRIPTION_ALLOW_PRIVATE_URLS", false),
+		},
+
 		API: APIConfig{
-			Host:   getEnv("API_HOST", "0.0.0.0"),
-			Port:   getEnvInt("API_PORT", 8080),
-			APIKey: getEnv("API_KEY", ""),
+			Host:        getEnv("API_HOST", "0.0.0.0"),
+			Port:        getEnvInt("API_PORT", 8080),
+			APIKey:      getEnv("API_KEY", ""),
+			AdminAPIKey: getEnv("ADMIN_API_KEY", ""),
+			RateLimit:   getEnvInt("RATE_LIMIT", 1000),
+
+			IPRateLimit:       getEnvInt("IP_RATE_LIMIT", 0),
+			AuthFailureLimit:  getEnvInt("AUTH_FAILURE_LIMIT", 20),
+			AuthFailureWindow: getEnvDuration("AUTH_FAILURE_WINDOW", 10*time.Minute),
+			AutoBlockDuration: getEnvDuration("AUTO_BLOCK_DURATION", time.Hour),
+			ProxyHeader:       getEnv("PROXY_HEADER", ""),
+			TrustedProxies:    getEnvSlice("TRUSTED_PROXIES", nil),
+
+			BodyLimit:       getEnvInt("BODY_LIMIT", 1024*1024),
+			MaxIOCLength:    getEnvInt("MAX_IOC_LENGTH", 2048),
+			MaxInflatedBody: getEnvInt("MAX_INFLATED_BODY", 16*1024*1024),
+
+			SubmissionTypeMismatch: strings.ToLower(getEnv("SUBMISSION_TYPE_MISMATCH", "reject")),
+
+			CheckQueryChunk:       getEnvInt("CHECK_QUERY_CHUNK", 200),
+			CheckQueryConcurrency: getEnvInt("CHECK_QUERY_CONCURRENCY", 4),
+			CheckCoalesce:         getEnvBool("CHECK_COALESCE", true),
+
+			CheckMaxSources: getEnvInt("CHECK_MAX_SOURCES", 10),
+
+			RegexSearchTimeout:    getEnvDuration("REGEX_SEARCH_TIMEOUT", 10*time.Second),
+			RegexSearchJobTimeout: getEnvDuration("REGEX_SEARCH_JOB_TIMEOUT", 5*time.Minute),
+			RegexSearchMaxRows:    getEnvInt("REGEX_SEARCH_MAX_ROWS", 100000000),
+			RegexSearchMaxJobs:    getEnvInt("REGEX_SEARCH_MAX_JOBS", 2),
+			RegexSearchResultTTL:  getEnvDuration("REGEX_SEARCH_RESULT_TTL", time.Hour),
+
+			TLSCertFile:         getEnv("TLS_CERT_FILE", ""),
+			TLSKeyFile:          getEnv("TLS_KEY_FILE", ""),
+			TLSAutocertDomains:  getEnvSlice("TLS_AUTOCERT_DOMAINS", nil),
+			TLSAutocertEmail:    getEnv("TLS_AUTOCERT_EMAIL", ""),
+			TLSAutocertCacheDir: getEnv("TLS_AUTOCERT_CACHE_DIR", "./autocert-cache"),
+			TLSRedirectPort:     getEnvInt("TLS_REDIRECT_PORT", 0),
+			HSTSMaxAge:          getEnvDuration("HSTS_MAX_AGE", 0),
+
+			SelfTestInterval:     getEnvDuration("SELFTEST_INTERVAL", 5*time.Minute),
+			RangeRefreshInterval: getEnvDuration("RANGE_REFRESH_INTERVAL", time.Minute),
+
+			TimeFormat: strings.ToLower(getEnv("API_TIME_FORMAT", "rfc3339")),
 		},
 
 		Worker: WorkerConfig{
 			Count:          getEnvInt("WORKER_COUNT", 50),
 			BatchSize:      getEnvInt("BATCH_SIZE", 1000),
-			FileExtensions: getEnvSlice("FILE_EXTENSIONS", []string{".txt", ".log", ".json", ".csv", ".xml", ".html", ".md"}),
+			FileExtensions: getEnvSlice("FILE_EXTENSIONS", []string{".txt", ".log", ".json", ".csv", ".xml", ".html", ".htm", ".md", ".ioc", ".stix", ".eml", ".msg", ".pcap", ".pcapng", ".cap", ".exe", ".dll", ".sys", ".scr", ".elf", ".so", ".bin", ".pdf"}),
+
+			ChangeDetection: strings.ToLower(getEnv("CHANGE_DETECTION", "mtime")),
+			FileIdentity:    strings.ToLower(getEnv("FILE_IDENTITY", "path")),
+
+			DetectDeletions:      getEnvBool("DETECT_DELETIONS", true),
+			DeprecateDeletedIOCs: getEnvBool("DEPRECATE_DELETED_IOCS", false),
+
+			ShutdownTimeout: getEnvDuration("SHUTDOWN_TIMEOUT", 30*time.Second),
+			WatchInterval:   getEnvDuration("WATCH_INTERVAL", 0),
+			PolicyFile:      getEnv("INGEST_POLICY_FILE", ""),
+
+			QueueSize:  getEnvInt("QUEUE_SIZE", 10000),
+			QueueOrder: strings.ToLower(getEnv("QUEUE_ORDER", "size")),
+
+			MaxFileSize: int64(getEnvInt("MAX_FILE_SIZE_MB", 1024)) << 20,
+			FileTimeout: getEnvDuration("FILE_TIMEOUT", 5*time.Minute),
+
+			FollowSymlinks: getEnvBool("CRAWL_FOLLOW_SYMLINKS", false),
+			SkipHidden:     getEnvBool("CRAWL_SKIP_HIDDEN", true),
+			ExcludeGlobs:   getEnvSlice("CRAWL_EXCLUDE", nil),
+
+			StructuredLogs:  getEnvBool("STRUCTURED_LOGS", true),
+			StructuredFeeds: getEnvBool("STRUCTURED_FEEDS", true),
+			DetectLanguage:  getEnvBool("DETECT_LANGUAGE", true),
+			NormalizeText:   getEnvBool("NORMALIZE_TEXT", true),
+			ParseHTML:       getEnvBool("PARSE_HTML", true),
+			ParseEmail:      getEnvBool("PARSE_EMAIL", true),
+			ParsePCAP:       getEnvBool("PARSE_PCAP", true),
+
+			ParseExecutables:   getEnvBool("PARSE_EXECUTABLES", true),
+			MinStringLength:    getEnvInt("MIN_STRING_LENGTH", 6),
+			QuarantineSamples:  getEnvBool("QUARANTINE_SAMPLES", true),
+			QuarantineInfected: getEnvBool("QUARANTINE_INFECTED", false),
+
+			DisabledHandlers: getEnvSlice("DISABLED_HANDLERS", nil),
+
+			BloomBatchSize:     getEnvInt("BLOOM_BATCH_SIZE", 10000),
+			BloomFlushInterval: getEnvDuration("BLOOM_FLUSH_INTERVAL", 250*time.Millisecond),
+			BloomAddRetries:    getEnvInt("BLOOM_ADD_RETRIES", 3),
+
+			ReviewConfidence: getEnvInt("REVIEW_CONFIDENCE_THRESHOLD", 0),
+		},
+
+		Extractor: ExtractorConfig{
+			MaxURLLength: getEnvInt("EXTRACT_MAX_URL_LENGTH", 2048),
+			MaxPerType:   getEnvInt("EXTRACT_MAX_PER_TYPE", 50000),
+			MaxPerFile:   getEnvInt("EXTRACT_MAX_PER_FILE", 100000),
+
+			MutexListFile:     getEnv("EXTRACT_MUTEX_LIST", ""),
+			UserAgentListFile: getEnv("EXTRACT_USER_AGENT_LIST", ""),
+
+			AllowlistFiles:      getEnvSlice("EXTRACT_ALLOWLIST", nil),
+			DenylistFiles:       getEnvSlice("EXTRACT_DENYLIST", nil),
+			ListRefreshInterval: getEnvDuration("EXTRACT_LIST_REFRESH_INTERVAL", time.Hour),
+
+			PopularityListFile: getEnv("EXTRACT_POPULARITY_LIST", ""),
+			PopularityTopN:     getEnvInt("EXTRACT_POPULARITY_TOP_N", 100000),
+
+			SampleRate: getEnvFloat("EXTRACT_SAMPLE_RATE", 0),
+		},
+
+		Syslog: SyslogConfig{
+			TCPAddr:        getEnv("SYSLOG_TCP_ADDR", ":5514"),
+			UDPAddr:        getEnv("SYSLOG_UDP_ADDR", ":5514"),
+			BatchSize:      getEnvInt("SYSLOG_BATCH_SIZE", 500),
+			FlushInterval:  getEnvDuration("SYSLOG_FLUSH_INTERVAL", time.Second),
+			MaxMessageSize: getEnvInt("SYSLOG_MAX_MESSAGE_SIZE", 64*1024),
+			StoreIOCs:      getEnvBool("SYSLOG_STORE_IOCS", false),
+		},
+
+		EventBus: EventBusConfig{
+			Type:            strings.ToLower(getEnv("EVENT_BUS_TYPE", "")),
+			Brokers:         getEnvSlice("EVENT_BUS_BROKERS", nil),
+			Topic:           getEnv("EVENT_BUS_TOPIC", "tip.events"),
+			SubmissionTopic: getEnv("EVENT_BUS_SUBMISSION_TOPIC", ""),
+			ConsumerGroup:   getEnv("EVENT_BUS_CONSUMER_GROUP", "tip-server"),
+		},
+
+		Enrichment: EnrichmentConfig{
+			VirusTotalAPIKey:    getEnv("VT_API_KEY", ""),
+			VirusTotalRateLimit: getEnvInt("VT_RATE_LIMIT", 4),
+			AbuseIPDBAPIKey:     getEnv("ABUSEIPDB_API_KEY", ""),
+			AbuseIPDBRateLimit:  getEnvInt("ABUSEIPDB_RATE_LIMIT", 30),
+			CacheTTL:            getEnvDuration("ENRICH_CACHE_TTL", 24*time.Hour),
+			Timeout:             getEnvDuration("ENRICH_TIMEOUT", 3*time.Second),
+			MaxPerRequest:       getEnvInt("ENRICH_MAX_PER_REQUEST", 10),
+			AdjustConfidence:    getEnvBool("ENRICH_ADJUST_CONFIDENCE", false),
+
+			WhoisEnabled:       getEnvBool("WHOIS_ENABLED", false),
+			WhoisRDAPURL:       getEnv("WHOIS_RDAP_URL", "https://rdap.org/domain/"),
+			WhoisRateLimit:     getEnvInt("WHOIS_RATE_LIMIT", 30),
+			WhoisCacheTTL:      getEnvDuration("WHOIS_CACHE_TTL", 30*24*time.Hour),
+			NRDDays:            getEnvInt("NRD_DAYS", 30),
+			NRDConfidenceBoost: getEnvInt("NRD_CONFIDENCE_BOOST", 15),
+		},
+
+		DNS: DNSConfig{
+			ResolveInterval: getEnvDuration("DNS_RESOLVE_INTERVAL", 0),
+			MaxAge:          getEnvDuration("DNS_RESOLVE_MAX_AGE", 24*time.Hour),
+			BatchSize:       getEnvInt("DNS_RESOLVE_BATCH_SIZE", 1000),
+			Concurrency:     getEnvInt("DNS_RESOLVE_CONCURRENCY", 20),
+			Timeout:         getEnvDuration("DNS_RESOLVE_TIMEOUT", 5*time.Second),
+			Server:          getEnv("DNS_RESOLVER", ""),
+			Sinkholes:       getEnvSlice("DNS_SINKHOLES", []string{"0.0.0.0/8", "127.0.0.0/8", "::1/128"}),
+		},
+
+		ClamAV: ClamAVConfig{
+			Address:         getEnv("CLAMAV_ADDRESS", ""),
+			Timeout:         getEnvDuration("CLAMAV_TIMEOUT", 30*time.Second),
+			MaxSize:         getEnvInt("CLAMAV_MAX_SIZE", 25*1024*1024),
+			ConfidenceBoost: getEnvInt("CLAMAV_CONFIDENCE_BOOST", 30),
 		},
 
 		Log: LogConfig{
@@ -157,15 +907,11 @@ func Load() (*Config, error) {
 		},
 
 		Metrics: MetricsConfig{
-			Enabled: getEnvBool("METRICS_ENABLED", true),
-			Port:    getEnvInt("METRICS_PORT", 9090),
+			Enabled:      getEnvBool("METRICS_ENABLED", true),
+			Port:         getEnvInt("METRICS_PORT", 9090),
+			IngestorPort: getEnvInt("INGESTOR_METRICS_PORT", 9091),
 		},
 	}
-
-	// Initialize logger based on config
-	initLogger(cfg.Log)
-
-	return cfg, nil
 }
 
 // initLogger sets up zerolog based on configuration
@@ -230,6 +976,15 @@ func getEnvFloat(key string, defaultValue float64) float64 {
 	return defaultValue
 }
 
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	if value := os.Getenv(key); value != "" {
+		if durVal, err := time.ParseDuration(value); err == nil {
+			return durVal
+		}
+	}
+	return defaultValue
+}
+
 func getEnvBool(key string, defaultValue bool) bool {
 	if value := os.Getenv(key); value != "" {
 		if boolVal, err := strconv.ParseBool(value); err == nil {
@@ -0,0 +1,175 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// DataRoot is a crawled directory and the source its files are attributed to
+type DataRoot struct {
+	Source string // "" = not attributed
+	Path   string
+}
+
+// parseDataRoots reads DATA_PATH entries of the form [source=]path
+func parseDataRoots(entries []string) []DataRoot {
+	roots := make([]DataRoot, 0, len(entries))
+	for _, entry := range entries {
+		var root DataRoot
+		if source, path, ok := strings.Cut(entry, "="); ok {
+			root.Source, root.Path = strings.TrimSpace(source), strings.TrimSpace(path)
+		} else {
+			root.Path = entry
+		}
+		if root.Path != "" {
+			root.Path = filepath.Clean(root.Path)
+		}
+		roots = append(roots, root)
+	}
+	return roots
+}
+
+// IngestPolicy is how the watch-mode ingestor treats one directory
+type IngestPolicy struct {
+	Path           string        // Absolute, or relative to the first DATA_PATH root
+	RescanInterval time.Duration // How often the directory is crawled (0 = WATCH_INTERVAL)
+	Priority       int           // Higher is crawled first when several directories are due
+	Profile        string        // Extraction profile ("" = global settings)
+
+	// Attribution of the IOCs extracted from the directory's files
+	Source        string   // Recorded as a source:<name> tag
+	MalwareFamily string   // Family of IOCs their file does not attribute
+	Tags          []string // Added to every IOC
+}
+
+// ExtractionProfile overrides extraction settings for the directories that
+// name it. Unset fields keep the global value.
+type ExtractionProfile struct {
+	FileExtensions   []string `json:"file_extensions,omitempty"`   // Replaces FILE_EXTENSIONS
+	DisabledHandlers []string `json:"disabled_handlers,omitempty"` // Added to DISABLED_HANDLERS
+	MaxPerType       *int     `json:"max_per_type,omitempty"`      // Replaces EXTRACT_MAX_PER_TYPE
+	MaxPerFile       *int     `json:"max_per_file,omitempty"`      // Replaces EXTRACT_MAX_PER_FILE
+}
+
+// Apply returns cfg with the profile's overrides
+func (p ExtractionProfile) Apply(cfg Config) Config {
+	if p.FileExtensions != nil {
+		cfg.Worker.FileExtensions = p.FileExtensions
+	}
+	if len(p.DisabledHandlers) > 0 {
+		cfg.Worker.DisabledHandlers = append(slices.Clip(cfg.Worker.DisabledHandlers), p.DisabledHandlers...)
+	}
+	if p.MaxPerType != nil {
+		cfg.Extractor.MaxPerType = *p.MaxPerType
+	}
+	if p.MaxPerFile != nil {
+		cfg.Extractor.MaxPerFile = *p.MaxPerFile
+	}
+	return cfg
+}
+
+// IngestPolicies are the directories the ingestor crawls and the extraction
+// profiles they use
+type IngestPolicies struct {
+	Directories []IngestPolicy
+	Profiles    map[string]ExtractionProfile
+}
+
+// policyFile is the JSON layout of INGEST_POLICY_FILE:
+//
+//	{
+//	  "profiles": {"feeds": {"disabled_handlers": ["binary", "pcap"]}},
+//	  "directories": [
+//	    {"path": "feeds", "rescan_interval": "15m", "priority": 10, "profile": "feeds",
+//	     "source": "abusech", "malware_family": "Emotet", "tags": ["botnet"]},
+//	    {"path": "archive", "rescan_interval": "24h"}
+//	  ]
+//	}
+type policyFile struct {
+	Profiles    map[string]ExtractionProfile `json:"profiles"`
+	Directories []struct {
+		Path           string `json:"path"`
+		RescanInterval string `json:"rescan_interval"`
+		Priority       int    `json:"priority"`
+		Profile        string `json:"profile"`
+
+		Source        string   `json:"source"`
+		MalwareFamily string   `json:"malware_family"`
+		Tags          []string `json:"tags"`
+	} `json:"directories"`
+}
+
+// LoadIngestPolicies reads the directory policies for the configured data
+// roots. Without a policy file each DATA_PATH root is one directory
+// rescanned every WATCH_INTERVAL.
+func LoadIngestPolicies(cfg *Config) (*IngestPolicies, error) {
+	if cfg.Worker.PolicyFile == "" {
+		policies := &IngestPolicies{}
+		for _, root := range cfg.DataRoots {
+			policies.Directories = append(policies.Directories, IngestPolicy{Path: root.Path, Source: root.Source})
+		}
+		return policies, nil
+	}
+
+	raw, err := os.ReadFile(cfg.Worker.PolicyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read policy file: %w", err)
+	}
+	var file policyFile
+	if err := json.Unmarshal(raw, &file); err != nil {
+		return nil, fmt.Errorf("invalid policy file %s: %w", cfg.Worker.PolicyFile, err)
+	}
+
+	for name, profile := range file.Profiles {
+		for _, h := range profile.DisabledHandlers {
+			if !slices.Contains(ExtractionHandlers(), h) {
+				return nil, fmt.Errorf("profile %q: unknown handler %q", name, h)
+			}
+		}
+		if (profile.MaxPerType != nil && *profile.MaxPerType < 0) || (profile.MaxPerFile != nil && *profile.MaxPerFile < 0) {
+			return nil, fmt.Errorf("profile %q: max_per_type and max_per_file must be >= 0", name)
+		}
+	}
+
+	if len(file.Directories) == 0 {
+		return nil, fmt.Errorf("policy file %s lists no directories", cfg.Worker.PolicyFile)
+	}
+	policies := &IngestPolicies{Profiles: file.Profiles}
+	for _, d := range file.Directories {
+		if strings.TrimSpace(d.Path) == "" {
+			return nil, fmt.Errorf("policy file %s: directory without a path", cfg.Worker.PolicyFile)
+		}
+		p := IngestPolicy{
+			Path:     d.Path,
+			Priority: d.Priority,
+			Profile:  d.Profile,
+
+			Source:        d.Source,
+			MalwareFamily: d.MalwareFamily,
+			Tags:          d.Tags,
+		}
+		if !filepath.IsAbs(p.Path) && len(cfg.DataRoots) > 0 {
+			p.Path = filepath.Join(cfg.DataRoots[0].Path, p.Path)
+		}
+		p.Path = filepath.Clean(p.Path)
+
+		if d.RescanInterval != "" {
+			if p.RescanInterval, err = time.ParseDuration(d.RescanInterval); err != nil || p.RescanInterval < 0 {
+				return nil, fmt.Errorf("directory %s: invalid rescan_interval %q", p.Path, d.RescanInterval)
+			}
+		}
+		if _, ok := file.Profiles[p.Profile]; p.Profile != "" && !ok {
+			return nil, fmt.Errorf("directory %s: unknown profile %q", p.Path, p.Profile)
+		}
+		if slices.ContainsFunc(policies.Directories, func(o IngestPolicy) bool { return o.Path == p.Path }) {
+			return nil, fmt.Errorf("directory %s is listed twice", p.Path)
+		}
+		policies.Directories = append(policies.Directories, p)
+	}
+	return policies, nil
+}
@@ -0,0 +1,115 @@
+package config
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
+
+	"github.com/joho/godotenv"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+// Reloader holds the live configuration and re-reads the reloadable subset
+// on SIGHUP. Settings that require new connections (hosts, credentials,
+// ports) are never changed by a reload.
+type Reloader struct {
+	current atomic.Pointer[Config]
+
+	mu        sync.Mutex
+	listeners []func(*Config)
+}
+
+// NewReloader creates a reloader seeded with the startup configuration
+func NewReloader(cfg *Config) *Reloader {
+	r := &Reloader{}
+	r.current.Store(cfg)
+	return r
+}
+
+// Current returns the live configuration. Callers must not modify it.
+func (r *Reloader) Current() *Config {
+	return r.current.Load()
+}
+
+// OnReload registers fn to be called with the new configuration after each reload
+func (r *Reloader) OnReload(fn func(*Config)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.listeners = append(r.listeners, fn)
+}
+
+// Reload re-reads the config file and applies reloadable settings.
+// Values in the config file take precedence over the process environment.
+func (r *Reloader) Reload() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := godotenv.Overload(configFile()); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	fresh := fromEnv()
+	if err := fresh.Validate(); err != nil {
+		return err
+	}
+	next := *r.current.Load()
+
+	next.Log.Level = fresh.Log.Level
+	next.API.RateLimit = fresh.API.RateLimit
+	next.API.IPRateLimit = fresh.API.IPRateLimit
+	next.Worker.Count = fresh.Worker.Count
+	next.Worker.FileExtensions = fresh.Worker.FileExtensions
+	next.Worker.WatchInterval = fresh.Worker.WatchInterval
+	next.Worker.PolicyFile = fresh.Worker.PolicyFile
+	next.Worker.QueueSize = fresh.Worker.QueueSize
+	next.Worker.QueueOrder = fresh.Worker.QueueOrder
+	next.Worker.MaxFileSize = fresh.Worker.MaxFileSize
+	next.Worker.FileTimeout = fresh.Worker.FileTimeout
+	next.Worker.FollowSymlinks = fresh.Worker.FollowSymlinks
+	next.Worker.SkipHidden = fresh.Worker.SkipHidden
+	next.Worker.ExcludeGlobs = fresh.Worker.ExcludeGlobs
+	next.Extractor = fresh.Extractor
+
+	if level, err := zerolog.ParseLevel(next.Log.Level); err == nil {
+		zerolog.SetGlobalLevel(level)
+	}
+
+	r.current.Store(&next)
+
+	for _, fn := range r.listeners {
+		fn(&next)
+	}
+
+	log.Info().
+		Str("log_level", next.Log.Level).
+		Int("rate_limit", next.API.RateLimit).
+		Int("workers", next.Worker.Count).
+		Strs("file_extensions", next.Worker.FileExtensions).
+		Msg("Configuration reloaded")
+
+	return nil
+}
+
+// WatchSignals reloads configuration on every SIGHUP until ctx is cancelled
+func (r *Reloader) WatchSignals(ctx context.Context) {
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGHUP)
+
+	go func() {
+		defer signal.Stop(sigChan)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-sigChan:
+				if err := r.Reload(); err != nil {
+					log.Error().Err(err).Msg("Failed to reload configuration")
+				}
+			}
+		}
+	}()
+}
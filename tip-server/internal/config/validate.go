@@ -0,0 +1,517 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+// Environments recognised by APP_ENV
+const (
+	EnvDevelopment = "development"
+	EnvProduction  = "production"
+)
+
+// placeholderAPIKey is the example key shipped in .env.example
+const placeholderAPIKey = "change-this-to-a-secure-key"
+
+// defaultMinIOCredentials are well-known credential pairs that must not be
+// used outside development
+var defaultMinIOCredentials = map[string]string{
+	"admin":      "SuperSecretPassword123",
+	"minioadmin": "minioadmin",
+}
+
+// IsProduction reports whether the configuration targets a production deployment
+func (c *Config) IsProduction() bool {
+	return c.Environment == EnvProduction
+}
+
+// Validate checks the configuration for invalid values and insecure defaults.
+// Insecure defaults are errors in production and warnings elsewhere.
+func (c *Config) Validate() error {
+	var errs []error
+	invalid := func(format string, args ...any) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+	insecure := func(format string, args ...any) {
+		if c.IsProduction() {
+			invalid(format, args...)
+			return
+		}
+		log.Warn().Msgf("Insecure configuration: "+format, args...)
+	}
+
+	switch c.Environment {
+	case EnvDevelopment, EnvProduction:
+	default:
+		invalid("APP_ENV must be %q or %q, got %q", EnvDevelopment, EnvProduction, c.Environment)
+	}
+
+	// Authentication
+	switch c.API.APIKey {
+	case "":
+		insecure("API_KEY is empty; every API key will be accepted")
+	case placeholderAPIKey:
+		insecure("API_KEY is still set to the example value")
+	}
+	if c.API.AdminAPIKey != "" && c.API.AdminAPIKey == c.API.APIKey {
+		invalid("ADMIN_API_KEY must differ from API_KEY")
+	}
+	if c.API.RateLimit < 0 {
+		invalid("RATE_LIMIT must be >= 0, got %d", c.API.RateLimit)
+	}
+	validatePort(invalid, "API_PORT", c.API.Port)
+	if c.API.IPRateLimit < 0 || c.API.AuthFailureLimit < 0 {
+		invalid("IP_RATE_LIMIT and AUTH_FAILURE_LIMIT must be >= 0")
+	}
+	if c.API.AuthFailureLimit > 0 && c.API.AuthFailureWindow <= 0 {
+		invalid("AUTH_FAILURE_WINDOW must be > 0 when AUTH_FAILURE_LIMIT is set")
+	}
+	if c.API.ProxyHeader != "" && len(c.API.TrustedProxies) == 0 {
+		invalid("PROXY_HEADER requires TRUSTED_PROXIES; without it any client could set its own IP")
+	}
+	for _, proxy := range c.API.TrustedProxies {
+		if _, _, err := net.ParseCIDR(proxy); err != nil && net.ParseIP(proxy) == nil {
+			invalid("TRUSTED_PROXIES entry %q is not an IP address or CIDR block", proxy)
+		}
+	}
+	if c.API.BodyLimit <= 0 {
+		invalid("BODY_LIMIT must be > 0, got %d", c.API.BodyLimit)
+	}
+	if c.API.MaxIOCLength <= 0 {
+		invalid("MAX_IOC_LENGTH must be > 0, got %d", c.API.MaxIOCLength)
+	}
+	if c.API.MaxInflatedBody <= 0 {
+		invalid("MAX_INFLATED_BODY must be > 0, got %d", c.API.MaxInflatedBody)
+	}
+	switch c.API.SubmissionTypeMismatch {
+	case "reject", "correct":
+	default:
+		invalid("SUBMISSION_TYPE_MISMATCH must be reject or correct, got %q", c.API.SubmissionTypeMismatch)
+	}
+	switch c.API.TimeFormat {
+	case "rfc3339", "both":
+	default:
+		invalid("API_TIME_FORMAT must be rfc3339 or both, got %q", c.API.TimeFormat)
+	}
+	if c.API.CheckQueryChunk <= 0 || c.API.CheckQueryConcurrency <= 0 {
+		invalid("CHECK_QUERY_CHUNK and CHECK_QUERY_CONCURRENCY must be > 0")
+	}
+	if c.API.CheckMaxSources < 0 || c.API.CheckMaxSources > 100 {
+		invalid("CHECK_MAX_SOURCES must be between 0 and 100, got %d", c.API.CheckMaxSources)
+	}
+	if c.API.RegexSearchTimeout <= 0 || c.API.RegexSearchJobTimeout <= 0 || c.API.RegexSearchResultTTL <= 0 {
+		invalid("REGEX_SEARCH_TIMEOUT, REGEX_SEARCH_JOB_TIMEOUT and REGEX_SEARCH_RESULT_TTL must be > 0")
+	}
+	if c.API.RegexSearchMaxRows < 0 {
+		invalid("REGEX_SEARCH_MAX_ROWS must be >= 0, got %d", c.API.RegexSearchMaxRows)
+	}
+	if c.API.RegexSearchMaxJobs <= 0 {
+		invalid("REGEX_SEARCH_MAX_JOBS must be > 0, got %d", c.API.RegexSearchMaxJobs)
+	}
+
+	// TLS
+	if (c.API.TLSCertFile == "") != (c.API.TLSKeyFile == "") {
+		invalid("TLS_CERT_FILE and TLS_KEY_FILE must be set together")
+	}
+	if c.API.TLSCertFile != "" && len(c.API.TLSAutocertDomains) > 0 {
+		invalid("TLS_CERT_FILE and TLS_AUTOCERT_DOMAINS are mutually exclusive")
+	}
+	if !c.API.TLSEnabled() && (c.API.TLSRedirectPort != 0 || c.API.HSTSMaxAge > 0) {
+		invalid("TLS_REDIRECT_PORT and HSTS_MAX_AGE require TLS to be configured")
+	}
+	if c.API.TLSRedirectPort != 0 {
+		validatePort(invalid, "TLS_REDIRECT_PORT", c.API.TLSRedirectPort)
+	}
+	// Let's Encrypt sends HTTP-01 challenges to port 80 and TLS-ALPN-01 to 443
+	if len(c.API.TLSAutocertDomains) > 0 && c.API.TLSRedirectPort != 80 && c.API.Port != 443 {
+		invalid("autocert needs TLS_REDIRECT_PORT=80 or API_PORT=443 to answer ACME challenges")
+	}
+	if !c.API.TLSEnabled() {
+		insecure("TLS is not configured; API keys are sent in clear text unless a proxy terminates TLS")
+	}
+
+	// MinIO
+	if secret, ok := defaultMinIOCredentials[c.MinIO.AccessKey]; ok && secret == c.MinIO.SecretKey {
+		insecure("MINIO_ACCESS_KEY/MINIO_SECRET_KEY are set to default credentials")
+	}
+	if c.MinIO.Bucket == "" {
+		invalid("MINIO_BUCKET must not be empty")
+	}
+	if c.MinIO.QuarantineBucket == c.MinIO.Bucket {
+		invalid("MINIO_QUARANTINE_BUCKET must differ from MINIO_BUCKET")
+	}
+	switch c.MinIO.SSEType {
+	case "", "sse-s3", "sse-kms", "sse-c":
+	default:
+		invalid("MINIO_SSE_TYPE must be one of sse-s3, sse-kms, sse-c, got %q", c.MinIO.SSEType)
+	}
+	if c.MinIO.RequireEncryption && c.MinIO.SSEType == "" {
+		invalid("MINIO_REQUIRE_ENCRYPTION is set but MINIO_SSE_TYPE is empty")
+	}
+	switch c.MinIO.Compression {
+	case "", "gzip", "zstd":
+	default:
+		invalid("MINIO_COMPRESSION must be gzip or zstd, got %q", c.MinIO.Compression)
+	}
+	if c.MinIO.ExpirationDays < 0 || c.MinIO.TransitionDays < 0 {
+		invalid("MINIO_EXPIRATION_DAYS and MINIO_TRANSITION_DAYS must be >= 0")
+	}
+	if c.MinIO.TransitionDays > 0 && c.MinIO.TransitionStorageClass == "" {
+		invalid("MINIO_TRANSITION_STORAGE_CLASS is required when MINIO_TRANSITION_DAYS is set")
+	}
+	switch c.MinIO.DeletePolicy {
+	case "retain", "delete":
+	default:
+		invalid("MINIO_DELETE_POLICY must be retain or delete, got %q", c.MinIO.DeletePolicy)
+	}
+
+	// Redis / Bloom filter
+	validatePort(invalid, "REDIS_PORT", c.Redis.Port)
+	if c.Redis.BloomFilterName == "" {
+		invalid("BLOOM_FILTER_NAME must not be empty")
+	}
+	if c.Redis.BloomFilterErrorRate <= 0 || c.Redis.BloomFilterErrorRate >= 1 {
+		invalid("BLOOM_FILTER_ERROR_RATE must be between 0 and 1 (exclusive), got %g", c.Redis.BloomFilterErrorRate)
+	}
+	if c.Redis.BloomFilterCapacity <= 0 {
+		invalid("BLOOM_FILTER_CAPACITY must be > 0, got %d", c.Redis.BloomFilterCapacity)
+	}
+
+	// ClickHouse
+	validatePort(invalid, "CLICKHOUSE_PORT", c.ClickHouse.Port)
+	validatePort(invalid, "CLICKHOUSE_HTTP_PORT", c.ClickHouse.HTTPPort)
+	if c.ClickHouse.Database == "" {
+		invalid("CLICKHOUSE_DATABASE must not be empty")
+	}
+	if c.ClickHouse.MaxRowsPerIOC <= 0 {
+		invalid("CLICKHOUSE_MAX_ROWS_PER_IOC must be > 0, got %d", c.ClickHouse.MaxRowsPerIOC)
+	}
+	for _, addr := range c.ClickHouse.ReadReplicas {
+		if _, _, err := net.SplitHostPort(addr); err != nil {
+			invalid("CLICKHOUSE_READ_REPLICAS entry %q must be host:port", addr)
+		}
+	}
+
+	// Workers
+	if c.Worker.Count <= 0 {
+		invalid("WORKER_COUNT must be > 0, got %d", c.Worker.Count)
+	}
+	if len(c.DataRoots) == 0 {
+		invalid("DATA_PATH must list at least one directory")
+	}
+	for idx, root := range c.DataRoots {
+		if root.Path == "" {
+			invalid("DATA_PATH: source %q has no path", root.Source)
+		}
+		if slices.ContainsFunc(c.DataRoots[:idx], func(o DataRoot) bool { return o.Path == root.Path }) {
+			invalid("DATA_PATH: %s is listed twice", root.Path)
+		}
+	}
+	if c.Worker.BatchSize <= 0 {
+		invalid("BATCH_SIZE must be > 0, got %d", c.Worker.BatchSize)
+	}
+	switch c.Worker.ChangeDetection {
+	case "mtime", "hash":
+	default:
+		invalid("CHANGE_DETECTION must be mtime or hash, got %q", c.Worker.ChangeDetection)
+	}
+	switch c.Worker.FileIdentity {
+	case "path", "content", "path_content", "inode":
+	default:
+		invalid("FILE_IDENTITY must be path, content, path_content or inode, got %q", c.Worker.FileIdentity)
+	}
+	for _, name := range c.Worker.DisabledHandlers {
+		if !slices.Contains(ExtractionHandlers(), name) {
+			invalid("DISABLED_HANDLERS: unknown handler %q (known: %s)", name, strings.Join(ExtractionHandlers(), ", "))
+		}
+	}
+	if c.Worker.MinStringLength < 1 {
+		invalid("MIN_STRING_LENGTH must be > 0, got %d", c.Worker.MinStringLength)
+	}
+	for _, pattern := range c.Worker.ExcludeGlobs {
+		for _, segment := range strings.Split(pattern, "/") {
+			if _, err := path.Match(segment, ""); err != nil {
+				invalid("CRAWL_EXCLUDE: malformed pattern %q", pattern)
+				break
+			}
+		}
+	}
+	if c.Worker.QueueSize <= 0 {
+		invalid("QUEUE_SIZE must be > 0, got %d", c.Worker.QueueSize)
+	}
+	switch c.Worker.QueueOrder {
+	case "size", "recent", "fifo":
+	default:
+		invalid("QUEUE_ORDER must be size, recent or fifo, got %q", c.Worker.QueueOrder)
+	}
+	if c.Worker.MaxFileSize < 0 || c.Worker.FileTimeout < 0 {
+		invalid("MAX_FILE_SIZE_MB and FILE_TIMEOUT must not be negative")
+	}
+	if c.Worker.ShutdownTimeout < 0 || c.Worker.WatchInterval < 0 {
+		invalid("SHUTDOWN_TIMEOUT and WATCH_INTERVAL must not be negative")
+	}
+	if c.Worker.BloomBatchSize <= 0 || c.Worker.BloomFlushInterval <= 0 {
+		invalid("BLOOM_BATCH_SIZE and BLOOM_FLUSH_INTERVAL must be > 0")
+	}
+	if c.Worker.BloomAddRetries < 0 {
+		invalid("BLOOM_ADD_RETRIES must not be negative, got %d", c.Worker.BloomAddRetries)
+	}
+	if c.Worker.ReviewConfidence < 0 || c.Worker.ReviewConfidence > 100 {
+		invalid("REVIEW_CONFIDENCE_THRESHOLD must be between 0 and 100, got %d", c.Worker.ReviewConfidence)
+	}
+
+	// Extraction
+	if c.Extractor.MaxURLLength <= 0 {
+		invalid("EXTRACT_MAX_URL_LENGTH must be > 0, got %d", c.Extractor.MaxURLLength)
+	}
+	if c.Extractor.MaxPerType < 0 || c.Extractor.MaxPerFile < 0 {
+		invalid("EXTRACT_MAX_PER_TYPE and EXTRACT_MAX_PER_FILE must be >= 0")
+	}
+	if c.Extractor.PopularityTopN < 0 {
+		invalid("EXTRACT_POPULARITY_TOP_N must be >= 0, got %d", c.Extractor.PopularityTopN)
+	}
+	if c.Extractor.SampleRate < 0 || c.Extractor.SampleRate > 100 {
+		invalid("EXTRACT_SAMPLE_RATE must be between 0 and 100, got %g", c.Extractor.SampleRate)
+	}
+
+	// Syslog listener
+	if c.Syslog.BatchSize <= 0 || c.Syslog.FlushInterval <= 0 || c.Syslog.MaxMessageSize <= 0 {
+		invalid("SYSLOG_BATCH_SIZE, SYSLOG_FLUSH_INTERVAL and SYSLOG_MAX_MESSAGE_SIZE must be > 0")
+	}
+
+	// Event bus
+	switch c.EventBus.Type {
+	case "":
+	case "kafka", "nats":
+		if len(c.EventBus.Brokers) == 0 {
+			invalid("EVENT_BUS_BROKERS is required when EVENT_BUS_TYPE is set")
+		}
+		if c.EventBus.Topic == "" {
+			invalid("EVENT_BUS_TOPIC must not be empty")
+		}
+	default:
+		invalid("EVENT_BUS_TYPE must be kafka or nats, got %q", c.EventBus.Type)
+	}
+
+	// Enrichment
+	if c.Enrichment.Enabled() || c.Enrichment.WhoisEnabled {
+		if c.Enrichment.VirusTotalRateLimit <= 0 || c.Enrichment.AbuseIPDBRateLimit <= 0 {
+			invalid("VT_RATE_LIMIT and ABUSEIPDB_RATE_LIMIT must be > 0")
+		}
+		if c.Enrichment.CacheTTL <= 0 || c.Enrichment.Timeout <= 0 {
+			invalid("ENRICH_CACHE_TTL and ENRICH_TIMEOUT must be > 0")
+		}
+		if c.Enrichment.MaxPerRequest <= 0 {
+			invalid("ENRICH_MAX_PER_REQUEST must be > 0, got %d", c.Enrichment.MaxPerRequest)
+		}
+	}
+	if c.Enrichment.WhoisEnabled {
+		if u, err := url.Parse(c.Enrichment.WhoisRDAPURL); err != nil || u.Scheme == "" || u.Host == "" {
+			invalid("WHOIS_RDAP_URL must be an absolute URL, got %q", c.Enrichment.WhoisRDAPURL)
+		}
+		if c.Enrichment.WhoisRateLimit <= 0 || c.Enrichment.WhoisCacheTTL <= 0 {
+			invalid("WHOIS_RATE_LIMIT and WHOIS_CACHE_TTL must be > 0")
+		}
+		if c.Enrichment.NRDDays <= 0 {
+			invalid("NRD_DAYS must be > 0, got %d", c.Enrichment.NRDDays)
+		}
+		if c.Enrichment.NRDConfidenceBoost < 0 || c.Enrichment.NRDConfidenceBoost > 100 {
+			invalid("NRD_CONFIDENCE_BOOST must be between 0 and 100, got %d", c.Enrichment.NRDConfidenceBoost)
+		}
+	}
+
+	// Antivirus scanning
+	if c.ClamAV.Address != "" {
+		if network, _, _ := strings.Cut(c.ClamAV.Address, "://"); network != "unix" && network != "tcp" {
+			invalid("CLAMAV_ADDRESS must be unix:///path or tcp://host:port, got %q", c.ClamAV.Address)
+		}
+		if c.ClamAV.Timeout <= 0 || c.ClamAV.MaxSize <= 0 {
+			invalid("CLAMAV_TIMEOUT and CLAMAV_MAX_SIZE must be > 0")
+		}
+		if c.ClamAV.ConfidenceBoost < 0 || c.ClamAV.ConfidenceBoost > 100 {
+			invalid("CLAMAV_CONFIDENCE_BOOST must be between 0 and 100, got %d", c.ClamAV.ConfidenceBoost)
+		}
+	}
+
+	// DNS resolution (the timeout also bounds /search/typosquat lookups)
+	if c.DNS.Timeout <= 0 {
+		invalid("DNS_RESOLVE_TIMEOUT must be > 0")
+	}
+	if c.DNS.ResolveInterval > 0 {
+		if c.DNS.MaxAge <= 0 {
+			invalid("DNS_RESOLVE_MAX_AGE must be > 0")
+		}
+		if c.DNS.BatchSize <= 0 || c.DNS.Concurrency <= 0 {
+			invalid("DNS_RESOLVE_BATCH_SIZE and DNS_RESOLVE_CONCURRENCY must be > 0")
+		}
+		if c.DNS.Server != "" {
+			if _, _, err := net.SplitHostPort(c.DNS.Server); err != nil {
+				invalid("DNS_RESOLVER must be host:port, got %q", c.DNS.Server)
+			}
+		}
+		for _, s := range c.DNS.Sinkholes {
+			if _, _, err := net.ParseCIDR(s); err != nil && net.ParseIP(s) == nil {
+				invalid("DNS_SINKHOLES entry %q is not an IP or CIDR", s)
+			}
+		}
+	}
+
+	// Similarity search
+	if c.Qdrant.Enabled {
+		validatePort(invalid, "QDRANT_GRPC_PORT", c.Qdrant.GRPCPort)
+		if c.Qdrant.Collection == "" {
+			invalid("QDRANT_COLLECTION must not be empty")
+		}
+		switch c.Embedding.Provider {
+		case "hash":
+		case "openai":
+			if u, err := url.Parse(c.Embedding.URL); err != nil || u.Scheme == "" || u.Host == "" {
+				invalid("EMBEDDING_URL must be an absolute URL, got %q", c.Embedding.URL)
+			}
+			if c.Embedding.Model == "" {
+				invalid("EMBEDDING_MODEL is required for the openai provider")
+			}
+			if c.Embedding.Timeout <= 0 {
+				invalid("EMBEDDING_TIMEOUT must be > 0")
+			}
+		case "onnx":
+			if c.Embedding.ONNXModel == "" || c.Embedding.ONNXVocab == "" {
+				invalid("EMBEDDING_ONNX_MODEL and EMBEDDING_ONNX_VOCAB are required for the onnx provider")
+			}
+			if c.Embedding.MaxTokens < 3 {
+				invalid("EMBEDDING_MAX_TOKENS must be >= 3, got %d", c.Embedding.MaxTokens)
+			}
+		default:
+			invalid("EMBEDDING_PROVIDER must be hash, openai or onnx, got %q", c.Embedding.Provider)
+		}
+		if c.Embedding.Dimensions <= 0 || c.Embedding.MaxBytes <= 0 {
+			invalid("EMBEDDING_DIMENSIONS and EMBEDDING_MAX_BYTES must be > 0")
+		}
+	}
+
+	if c.Export.Retention < 0 {
+		invalid("EXPORT_RETENTION must be >= 0, got %d", c.Export.Retention)
+	}
+
+	if c.Consistency.Interval > 0 {
+		if c.Consistency.GracePeriod < 0 {
+			invalid("CONSISTENCY_GRACE_PERIOD must be >= 0, got %s", c.Consistency.GracePeriod)
+		}
+		if c.Consistency.SampleSize < 0 || c.Consistency.SampleSize > 1000 {
+			invalid("CONSISTENCY_SAMPLE_SIZE must be between 0 and 1000, got %d", c.Consistency.SampleSize)
+		}
+	}
+
+	if c.Volume.Interval > 0 {
+		if c.Volume.Window < time.Hour || c.Volume.Window%time.Hour != 0 {
+			invalid("VOLUME_WINDOW must be a whole number of hours, got %s", c.Volume.Window)
+		}
+		if c.Volume.BaselineWindows < 3 || c.Volume.BaselineWindows > 60 {
+			invalid("VOLUME_BASELINE_WINDOWS must be between 3 and 60, got %d", c.Volume.BaselineWindows)
+		}
+		if c.Volume.SpikeFactor <= 1 {
+			invalid("VOLUME_SPIKE_FACTOR must be > 1, got %g", c.Volume.SpikeFactor)
+		}
+		if c.Volume.DropFactor <= 0 || c.Volume.DropFactor >= 1 {
+			invalid("VOLUME_DROP_FACTOR must be between 0 and 1 exclusive, got %g", c.Volume.DropFactor)
+		}
+		if c.Volume.MinCount < 1 {
+			invalid("VOLUME_MIN_COUNT must be >= 1, got %d", c.Volume.MinCount)
+		}
+		if c.Volume.WebhookURL != "" {
+			if u, err := url.Parse(c.Volume.WebhookURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				invalid("VOLUME_ALERT_WEBHOOK_URL must be an http(s) URL, got %q", c.Volume.WebhookURL)
+			}
+		}
+	}
+
+	switch c.Redaction.Mode {
+	case "off":
+	case "hash", "partial":
+		// Changing the key orphans every address already stored
+		if len(c.Redaction.Key) < 32 {
+			invalid("EMAIL_REDACTION_KEY must be at least 32 characters with EMAIL_REDACTION=%s", c.Redaction.Mode)
+		}
+	default:
+		invalid("EMAIL_REDACTION must be off, hash or partial, got %q", c.Redaction.Mode)
+	}
+
+	switch c.Signing.Format {
+	case "jws", "minisign":
+	default:
+		invalid("FEED_SIGNING_FORMAT must be jws or minisign, got %q", c.Signing.Format)
+	}
+
+	if c.Sync.PrimaryURL != "" {
+		if u, err := url.Parse(c.Sync.PrimaryURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			invalid("SYNC_PRIMARY_URL must be an http(s) URL, got %q", c.Sync.PrimaryURL)
+		}
+		if c.Sync.APIKey == "" {
+			invalid("SYNC_API_KEY is required with SYNC_PRIMARY_URL")
+		}
+		if c.Sync.Interval <= 0 || c.Sync.Timeout <= 0 {
+			invalid("SYNC_INTERVAL and SYNC_TIMEOUT must be > 0")
+		}
+		if c.Sync.BatchSize < 1 || c.Sync.BatchSize > 10000 {
+			invalid("SYNC_BATCH_SIZE must be between 1 and 10000, got %d", c.Sync.BatchSize)
+		}
+	}
+
+	if c.Subscriptions.MaxPerKey < 0 {
+		invalid("SUBSCRIPTION_MAX_PER_KEY must be >= 0, got %d", c.Subscriptions.MaxPerKey)
+	}
+	if c.Subscriptions.MaxPerKey > 0 {
+		if c.Subscriptions.FlushInterval <= 0 || c.Subscriptions.Timeout <= 0 {
+			invalid("SUBSCRIPTION_FLUSH_INTERVAL and SUBSCRIPTION_TIMEOUT must be > 0")
+		}
+		if c.Subscriptions.BatchSize < 1 || c.Subscriptions.BatchSize > 10000 {
+			invalid("SUBSCRIPTION_BATCH_SIZE must be between 1 and 10000, got %d", c.Subscriptions.BatchSize)
+		}
+	}
+
+	if c.Cluster.Interval > 0 {
+		if !c.Qdrant.Enabled {
+			invalid("CLUSTER_INTERVAL requires QDRANT_ENABLED")
+		}
+		if c.Cluster.Threshold <= 0 || c.Cluster.Threshold > 1 {
+			invalid("CLUSTER_THRESHOLD must be in (0, 1], got %g", c.Cluster.Threshold)
+		}
+		if c.Cluster.Neighbors <= 0 || c.Cluster.MaxPoints <= 0 || c.Cluster.MaxMembers <= 0 || c.Cluster.MaxIndicators <= 0 {
+			invalid("CLUSTER_NEIGHBORS, CLUSTER_MAX_POINTS, CLUSTER_MAX_MEMBERS and CLUSTER_MAX_INDICATORS must be > 0")
+		}
+		if c.Cluster.MinSize < 2 {
+			invalid("CLUSTER_MIN_SIZE must be >= 2, got %d", c.Cluster.MinSize)
+		}
+	}
+
+	// Logging and metrics
+	if _, err := zerolog.ParseLevel(c.Log.Level); err != nil {
+		invalid("LOG_LEVEL %q is not a valid level", c.Log.Level)
+	}
+	if c.Metrics.Enabled {
+		validatePort(invalid, "METRICS_PORT", c.Metrics.Port)
+		validatePort(invalid, "INGESTOR_METRICS_PORT", c.Metrics.IngestorPort)
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid configuration: %w", errors.Join(errs...))
+	}
+	return nil
+}
+
+// validatePort reports ports outside the valid TCP range
+func validatePort(invalid func(string, ...any), name string, port int) {
+	if port < 1 || port > 65535 {
+		invalid("%s must be between 1 and 65535, got %d", name, port)
+	}
+}
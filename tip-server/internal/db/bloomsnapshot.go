@@ -0,0 +1,140 @@
+package db
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// BloomSnapshotPrefix is the MinIO key prefix of Bloom filter snapshots
+const BloomSnapshotPrefix = "snapshots/bloom/"
+
+// BloomSnapshotKey is the object holding the latest snapshot of a filter
+func BloomSnapshotKey(filterName string) string {
+	return BloomSnapshotPrefix + filterName
+}
+
+// bloomSnapshotMagic opens every snapshot object. It is followed by the
+// time the dump started and the filter's item count, then by one record
+// per BF.SCANDUMP chunk: iterator, length and data.
+const bloomSnapshotMagic = "TIPBLOOM1"
+
+// maxBloomChunk bounds a chunk read back from a snapshot; RedisBloom dumps
+// at most 16 MiB per chunk
+const maxBloomChunk = 64 << 20
+
+// ErrNoBloomSnapshot is returned by RestoreBloomSnapshot when none is stored
+var ErrNoBloomSnapshot = errors.New("no Bloom filter snapshot stored")
+
+// BloomSnapshot describes a stored snapshot
+type BloomSnapshot struct {
+	TakenAt time.Time // When the dump started; later additions are not in it
+	Items   int64     // Items the filter held
+	Chunks  int
+	Bytes   int64
+}
+
+// SaveBloomSnapshot dumps the Bloom filter and streams it to MinIO under
+// key, replacing the previous snapshot only once the dump completes
+func SaveBloomSnapshot(ctx context.Context, redis Cache, minio ObjectStore, key string) (BloomSnapshot, error) {
+	info, err := redis.BFInfo(ctx)
+	if err != nil {
+		return BloomSnapshot{}, err
+	}
+	snap := BloomSnapshot{TakenAt: time.Now(), Items: info.ItemsInserted}
+
+	pr, pw := io.Pipe()
+	go func() {
+		w := bufio.NewWriter(pw)
+		w.WriteString(bloomSnapshotMagic)
+		binary.Write(w, binary.BigEndian, snap.TakenAt.UnixNano())
+		binary.Write(w, binary.BigEndian, snap.Items)
+
+		err := redis.DumpBloomFilter(ctx, func(iter int64, data []byte) error {
+			binary.Write(w, binary.BigEndian, iter)
+			binary.Write(w, binary.BigEndian, uint32(len(data)))
+			_, err := w.Write(data)
+			snap.Chunks++
+			snap.Bytes += int64(len(data))
+			return err
+		})
+		if err == nil {
+			err = w.Flush()
+		}
+		pw.CloseWithError(err)
+	}()
+
+	if _, err := minio.UploadStream(ctx, key, pr, "application/octet-stream"); err != nil {
+		pr.CloseWithError(err)
+		return BloomSnapshot{}, err
+	}
+	return snap, nil
+}
+
+// RestoreBloomSnapshot replaces the live Bloom filter with the snapshot
+// stored under key
+func RestoreBloomSnapshot(ctx context.Context, redis Cache, minio ObjectStore, key string) (BloomSnapshot, error) {
+	exists, err := minio.ObjectExists(ctx, key)
+	if err != nil {
+		return BloomSnapshot{}, err
+	}
+	if !exists {
+		return BloomSnapshot{}, ErrNoBloomSnapshot
+	}
+
+	reader, _, _, err := minio.OpenObject(ctx, key)
+	if err != nil {
+		return BloomSnapshot{}, err
+	}
+	defer reader.Close()
+	r := bufio.NewReader(reader)
+
+	magic := make([]byte, len(bloomSnapshotMagic))
+	if _, err := io.ReadFull(r, magic); err != nil || string(magic) != bloomSnapshotMagic {
+		return BloomSnapshot{}, fmt.Errorf("%s is not a Bloom filter snapshot", key)
+	}
+	var takenAt int64
+	var snap BloomSnapshot
+	if err := binary.Read(r, binary.BigEndian, &takenAt); err != nil {
+		return BloomSnapshot{}, fmt.Errorf("truncated snapshot header: %w", err)
+	}
+	if err := binary.Read(r, binary.BigEndian, &snap.Items); err != nil {
+		return BloomSnapshot{}, fmt.Errorf("truncated snapshot header: %w", err)
+	}
+	snap.TakenAt = time.Unix(0, takenAt)
+
+	err = redis.RestoreBloomFilter(ctx, func(load func(iter int64, data []byte) error) error {
+		for {
+			var iter int64
+			var size uint32
+			if err := binary.Read(r, binary.BigEndian, &iter); err == io.EOF {
+				return nil
+			} else if err != nil {
+				return err
+			}
+			if err := binary.Read(r, binary.BigEndian, &size); err != nil {
+				return err
+			}
+			if size > maxBloomChunk {
+				return fmt.Errorf("snapshot chunk of %d bytes exceeds the limit", size)
+			}
+			data := make([]byte, size)
+			if _, err := io.ReadFull(r, data); err != nil {
+				return err
+			}
+			if err := load(iter, data); err != nil {
+				return err
+			}
+			snap.Chunks++
+			snap.Bytes += int64(size)
+		}
+	})
+	if err != nil {
+		return BloomSnapshot{}, err
+	}
+	return snap, nil
+}
@@ -4,7 +4,12 @@ import (
 	"context"
 	"crypto/sha256"
 	"encoding/hex"
+	"errors"
 	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/ClickHouse/clickhouse-go/v2"
@@ -12,6 +17,7 @@ import (
 	"github.com/rs/zerolog/log"
 
 	"tip-server/internal/config"
+	"tip-server/internal/dga"
 	"tip-server/internal/models"
 )
 
@@ -19,12 +25,61 @@ import (
 type ClickHouseClient struct {
 	conn driver.Conn
 	cfg  config.ClickHouseConfig
+
+	// analytics serves scans, reports and exports. It is a separate pool on
+	// the read replicas when configured, otherwise the primary connection.
+	// Replicas may lag, so reads that must see every insert (Bloom rebuilds,
+	// MinIO garbage collection) stay on conn.
+	analytics driver.Conn
+
+	// schemaVersion is the last migration applied, set by Migrate
+	schemaVersion atomic.Uint32
 }
 
 // NewClickHouseClient creates a new ClickHouse client
 func NewClickHouseClient(cfg config.ClickHouseConfig) (*ClickHouseClient, error) {
+	conn, err := openClickHouse(cfg, []string{fmt.Sprintf("%s:%d", cfg.Host, cfg.Port)})
+	if err != nil {
+		return nil, err
+	}
+
+	log.Info().
+		Str("host", cfg.Host).
+		Int("port", cfg.Port).
+		Str("database", cfg.Database).
+		Msg("Connected to ClickHouse")
+
+	client := &ClickHouseClient{conn: conn, cfg: cfg, analytics: conn}
+
+	migrateCtx, migrateCancel := context.WithTimeout(context.Background(), time.Minute)
+	defer migrateCancel()
+
+	if err := client.Migrate(migrateCtx); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	if len(cfg.ReadReplicas) > 0 {
+		analytics, err := openClickHouse(cfg, cfg.ReadReplicas)
+		if err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("read replicas: %w", err)
+		}
+		client.analytics = analytics
+
+		log.Info().
+			Strs("replicas", cfg.ReadReplicas).
+			Msg("Routing analytical ClickHouse reads to replicas")
+	}
+
+	return client, nil
+}
+
+// openClickHouse opens and pings a connection pool over addrs, spreading
+// new connections round-robin when there are several
+func openClickHouse(cfg config.ClickHouseConfig, addrs []string) (driver.Conn, error) {
 	conn, err := clickhouse.Open(&clickhouse.Options{
-		Addr: []string{fmt.Sprintf("%s:%d", cfg.Host, cfg.Port)},
+		Addr: addrs,
 		Auth: clickhouse.Auth{
 			Database: cfg.Database,
 			Username: cfg.User,
@@ -36,9 +91,10 @@ func NewClickHouseClient(cfg config.ClickHouseConfig) (*ClickHouseClient, error)
 		Compression: &clickhouse.Compression{
 			Method: clickhouse.CompressionLZ4,
 		},
-		MaxOpenConns:    10,
-		MaxIdleConns:    5,
-		ConnMaxLifetime: time.Hour,
+		ConnOpenStrategy: clickhouse.ConnOpenRoundRobin,
+		MaxOpenConns:     10,
+		MaxIdleConns:     5,
+		ConnMaxLifetime:  time.Hour,
 	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to ClickHouse: %w", err)
@@ -49,26 +105,32 @@ func NewClickHouseClient(cfg config.ClickHouseConfig) (*ClickHouseClient, error)
 	defer cancel()
 
 	if err := conn.Ping(ctx); err != nil {
+		conn.Close()
 		return nil, fmt.Errorf("failed to ping ClickHouse: %w", err)
 	}
 
-	log.Info().
-		Str("host", cfg.Host).
-		Int("port", cfg.Port).
-		Str("database", cfg.Database).
-		Msg("Connected to ClickHouse")
-
-	return &ClickHouseClient{conn: conn, cfg: cfg}, nil
+	return conn, nil
 }
 
-// Close closes the ClickHouse connection
+// Close closes the ClickHouse connections
 func (c *ClickHouseClient) Close() error {
+	if c.analytics != c.conn {
+		c.analytics.Close()
+	}
 	return c.conn.Close()
 }
 
-// Ping checks if the connection is alive
+// Ping checks if the connections are alive
 func (c *ClickHouseClient) Ping(ctx context.Context) error {
-	return c.conn.Ping(ctx)
+	if err := c.conn.Ping(ctx); err != nil {
+		return err
+	}
+	if c.analytics != c.conn {
+		if err := c.analytics.Ping(ctx); err != nil {
+			return fmt.Errorf("read replica: %w", err)
+		}
+	}
+	return nil
 }
 
 // GenerateFileID generates a deterministic file ID from the file path
@@ -77,87 +139,378 @@ func GenerateFileID(filePath string) string {
 	return hex.EncodeToString(hash[:])
 }
 
+// ContentFileID generates a file ID from a file's content hash, so copies of
+// a file share one identity
+func ContentFileID(contentHash string) string {
+	hash := sha256.Sum256([]byte("content\x00" + contentHash))
+	return hex.EncodeToString(hash[:])
+}
+
+// PathContentFileID generates a file ID from a file's path and content hash,
+// so each version of a file has its own identity
+func PathContentFileID(filePath, contentHash string) string {
+	hash := sha256.Sum256([]byte(filePath + "\x00" + contentHash))
+	return hex.EncodeToString(hash[:])
+}
+
+// InodeFileID generates a file ID from the device and inode a file is stored
+// at, so a file renamed within its filesystem keeps its identity
+func InodeFileID(device, inode uint64) string {
+	hash := sha256.Sum256([]byte(fmt.Sprintf("inode\x00%d:%d", device, inode)))
+	return hex.EncodeToString(hash[:])
+}
+
+// ContentHash returns the hex-encoded SHA-256 of file content
+func ContentHash(content []byte) string {
+	hash := sha256.Sum256(content)
+	return hex.EncodeToString(hash[:])
+}
+
+// ContentKey returns the content-addressable MinIO key for a content hash,
+// so identical files stored from different paths share one object
+func ContentKey(contentHash string) string {
+	return "sha256/" + contentHash
+}
+
+// QuarantineKey returns the MinIO key for a stored malware sample. Samples
+// live under their own prefix, in the quarantine bucket when one is
+// configured, so bucket policies can deny reads to them.
+func QuarantineKey(contentHash string) string {
+	return QuarantinePrefix + contentHash
+}
+
+// QuarantinePrefix is the MinIO key prefix of stored malware samples
+const QuarantinePrefix = "quarantine/sha256/"
+
+// IsQuarantineKey reports whether a MinIO key names a malware sample
+func IsQuarantineKey(key string) bool {
+	return strings.HasPrefix(key, QuarantinePrefix)
+}
+
 // ========== File Registry Operations ==========
 
 // GetFileMetadata retrieves file metadata by file ID
 func (c *ClickHouseClient) GetFileMetadata(ctx context.Context, fileID string) (*models.FileMetadata, error) {
 	query := `
-		SELECT file_id, file_path, file_size, last_modified, scan_status, 
-		       ioc_count, minio_key, error_message, processed_at, updated_at
+		SELECT ` + fileMetadataColumns + `
 		FROM threat_intel.file_registry
-		WHERE file_id = ?
+		WHERE file_id = @file_id
 		ORDER BY updated_at DESC
 		LIMIT 1
 	`
 
-	row := c.conn.QueryRow(ctx, query, fileID)
+	var meta models.FileMetadata
+	var scanStatus string
+	if err := c.queryRow(ctx, query, Params{"file_id": fileID}, fileMetadataDest(&meta, &scanStatus)...); err != nil {
+		return nil, err
+	}
+
+	meta.ScanStatus = models.ScanStatus(scanStatus)
+	return &meta, nil
+}
+
+// GetFileMetadataByPath retrieves the most recently updated registry entry
+// currently recorded under a path. Under content-derived file identities
+// the ID of a file is only known once it has been read.
+func (c *ClickHouseClient) GetFileMetadataByPath(ctx context.Context, filePath string) (*models.FileMetadata, error) {
+	query := `
+		SELECT ` + fileMetadataColumns + `
+		FROM threat_intel.file_registry FINAL
+		WHERE file_path = @file_path
+		ORDER BY updated_at DESC
+		LIMIT 1
+	`
 
 	var meta models.FileMetadata
 	var scanStatus string
+	if err := c.queryRow(ctx, query, Params{"file_path": filePath}, fileMetadataDest(&meta, &scanStatus)...); err != nil {
+		return nil, err
+	}
+
+	meta.ScanStatus = models.ScanStatus(scanStatus)
+	return &meta, nil
+}
+
+// fileMetadataColumns are the registry columns fileMetadataDest scans
+const fileMetadataColumns = `file_id, file_path, file_size, content_hash, language, file_type, last_modified,
+		       scan_status, ioc_count, minio_key, error_message, processed_at, updated_at, deleted_at`
 
-	err := row.Scan(
+// fileMetadataDest returns the scan destinations of fileMetadataColumns;
+// the scan status is read as a string
+func fileMetadataDest(meta *models.FileMetadata, scanStatus *string) []any {
+	return []any{
 		&meta.FileID,
 		&meta.FilePath,
 		&meta.FileSize,
+		&meta.ContentHash,
+		&meta.Language,
+		&meta.FileType,
 		&meta.LastModified,
-		&scanStatus,
+		scanStatus,
 		&meta.IOCCount,
 		&meta.MinIOKey,
 		&meta.ErrorMessage,
 		&meta.ProcessedAt,
 		&meta.UpdatedAt,
-	)
+		&meta.DeletedAt,
+	}
+}
+
+// UpsertFileMetadata inserts or updates file metadata
+func (c *ClickHouseClient) UpsertFileMetadata(ctx context.Context, meta *models.FileMetadata) error {
+	query := `
+		INSERT INTO threat_intel.file_registry 
+		(file_id, file_path, file_size, content_hash, language, file_type, last_modified, scan_status, ioc_count, minio_key, error_message, processed_at, updated_at, deleted_at)
+		VALUES (@file_id, @file_path, @file_size, @content_hash, @language, @file_type, @last_modified, @scan_status,
+		        @ioc_count, @minio_key, @error_message, @processed_at, @updated_at, @deleted_at)
+	`
+
+	return c.exec(ctx, query, Params{
+		"file_id":       meta.FileID,
+		"file_path":     meta.FilePath,
+		"file_size":     meta.FileSize,
+		"content_hash":  meta.ContentHash,
+		"language":      meta.Language,
+		"file_type":     meta.FileType,
+		"last_modified": meta.LastModified,
+		"scan_status":   string(meta.ScanStatus),
+		"ioc_count":     meta.IOCCount,
+		"minio_key":     meta.MinIOKey,
+		"error_message": meta.ErrorMessage,
+		"processed_at":  meta.ProcessedAt,
+		"updated_at":    time.Now(),
+		"deleted_at":    meta.DeletedAt,
+	})
+}
+
+// ListActiveFiles returns file_id -> file_path for all non-deleted registry
+// entries whose path starts with pathPrefix
+func (c *ClickHouseClient) ListActiveFiles(ctx context.Context, pathPrefix string) (map[string]string, error) {
+	query, params, err := Select("file_id", "file_path").
+		From("threat_intel.file_registry FINAL").
+		Where("scan_status != 'deleted'", nil).
+		WhereIf(pathPrefix != "", "startsWith(file_path, @prefix)", Params{"prefix": pathPrefix}).
+		Build()
 	if err != nil {
 		return nil, err
 	}
 
-	meta.ScanStatus = models.ScanStatus(scanStatus)
-	return &meta, nil
+	rows, err := c.query(ctx, query, params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list active files: %w", err)
+	}
+	defer rows.Close()
+
+	files := make(map[string]string)
+	for rows.Next() {
+		var fileID, filePath string
+		if err := rows.Scan(&fileID, &filePath); err != nil {
+			return nil, err
+		}
+		files[fileID] = filePath
+	}
+
+	return files, rows.Err()
 }
 
-// CheckFileChanged checks if a file has changed since last scan
-func (c *ClickHouseClient) CheckFileChanged(ctx context.Context, fileID string, lastModified time.Time) (bool, error) {
+// ListStoredMiscFiles returns active registry entries without IOCs whose
+// content was uploaded to MinIO
+func (c *ClickHouseClient) ListStoredMiscFiles(ctx context.Context) ([]models.FileMetadata, error) {
 	query := `
-		SELECT last_modified
-		FROM threat_intel.file_registry
-		WHERE file_id = ?
-		ORDER BY updated_at DESC
-		LIMIT 1
+		SELECT file_id, file_path, file_size, content_hash, minio_key
+		FROM threat_intel.file_registry FINAL
+		WHERE scan_status = 'misc' AND minio_key != ''
+		ORDER BY file_path
 	`
 
-	row := c.conn.QueryRow(ctx, query, fileID)
+	rows, err := c.query(ctx, query, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list stored misc files: %w", err)
+	}
+	defer rows.Close()
+
+	var files []models.FileMetadata
+	for rows.Next() {
+		var meta models.FileMetadata
+		if err := rows.Scan(&meta.FileID, &meta.FilePath, &meta.FileSize, &meta.ContentHash, &meta.MinIOKey); err != nil {
+			return nil, err
+		}
+		meta.ScanStatus = models.ScanStatusMisc
+		files = append(files, meta)
+	}
+
+	return files, rows.Err()
+}
+
+// ListFilesByStatus returns up to limit registry entries with the given scan
+// status, least recently processed first
+func (c *ClickHouseClient) ListFilesByStatus(ctx context.Context, status models.ScanStatus, limit int) ([]models.FileMetadata, error) {
+	query := `
+		SELECT file_id, file_path, file_size, content_hash, language, file_type, last_modified,
+		       ioc_count, minio_key, error_message, processed_at, updated_at, deleted_at
+		FROM threat_intel.file_registry FINAL
+		WHERE scan_status = @status
+		ORDER BY processed_at, file_path
+		LIMIT @limit
+	`
 
-	var dbLastModified time.Time
-	err := row.Scan(&dbLastModified)
+	rows, err := c.query(ctx, query, Params{"status": string(status), "limit": limit})
 	if err != nil {
-		// File not found, treat as changed (new file)
-		return true, nil
+		return nil, fmt.Errorf("failed to list files by status: %w", err)
+	}
+	defer rows.Close()
+
+	var files []models.FileMetadata
+	for rows.Next() {
+		meta := models.FileMetadata{ScanStatus: status}
+		if err := rows.Scan(
+			&meta.FileID,
+			&meta.FilePath,
+			&meta.FileSize,
+			&meta.ContentHash,
+			&meta.Language,
+			&meta.FileType,
+			&meta.LastModified,
+			&meta.IOCCount,
+			&meta.MinIOKey,
+			&meta.ErrorMessage,
+			&meta.ProcessedAt,
+			&meta.UpdatedAt,
+			&meta.DeletedAt,
+		); err != nil {
+			return nil, err
+		}
+		files = append(files, meta)
 	}
 
-	// File has changed if modification times differ
-	return !dbLastModified.Equal(lastModified), nil
+	return files, rows.Err()
 }
 
-// UpsertFileMetadata inserts or updates file metadata
-func (c *ClickHouseClient) UpsertFileMetadata(ctx context.Context, meta *models.FileMetadata) error {
+// GetFileHistory returns up to limit recorded versions of a registry entry,
+// newest first. Schemas without the history view read the registry rows
+// not yet merged away.
+func (c *ClickHouseClient) GetFileHistory(ctx context.Context, fileID string, limit int) ([]models.FileMetadata, error) {
+	table := "threat_intel.file_registry"
+	if c.schemaVersion.Load() >= fileHistoryVersion {
+		table = "threat_intel.file_registry_history"
+	}
 	query := `
-		INSERT INTO threat_intel.file_registry 
-		(file_id, file_path, file_size, last_modified, scan_status, ioc_count, minio_key, error_message, processed_at, updated_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		SELECT ` + fileMetadataColumns + `
+		FROM ` + table + `
+		WHERE file_id = @file_id
+		ORDER BY updated_at DESC
+		LIMIT @limit
 	`
 
-	return c.conn.Exec(ctx, query,
-		meta.FileID,
-		meta.FilePath,
-		meta.FileSize,
-		meta.LastModified,
-		string(meta.ScanStatus),
-		meta.IOCCount,
-		meta.MinIOKey,
-		meta.ErrorMessage,
-		meta.ProcessedAt,
-		time.Now(),
-	)
+	rows, err := c.query(ctx, query, Params{"file_id": fileID, "limit": limit})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file history: %w", err)
+	}
+	defer rows.Close()
+
+	var versions []models.FileMetadata
+	for rows.Next() {
+		var meta models.FileMetadata
+		var scanStatus string
+		if err := rows.Scan(fileMetadataDest(&meta, &scanStatus)...); err != nil {
+			return nil, err
+		}
+		meta.ScanStatus = models.ScanStatus(scanStatus)
+		versions = append(versions, meta)
+	}
+
+	return versions, rows.Err()
+}
+
+// ListDuplicateFiles reports content registered under more than one active
+// file ID, the content with the most registrations first
+func (c *ClickHouseClient) ListDuplicateFiles(ctx context.Context, limit, offset int) (*models.DuplicateReport, error) {
+	report := &models.DuplicateReport{Groups: []models.DuplicateFiles{}}
+
+	err := c.queryRow(ctx, `
+		SELECT count(), sum(n - 1)
+		FROM (
+			SELECT content_hash, count() AS n
+			FROM threat_intel.file_registry FINAL
+			WHERE scan_status != 'deleted' AND content_hash != ''
+			GROUP BY content_hash
+			HAVING n > 1
+		)
+	`, nil, &report.TotalGroups, &report.RedundantFiles)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count duplicate files: %w", err)
+	}
+
+	// The arrays of a group hold its registrations in the same order
+	rows, err := c.analyticsQuery(ctx, `
+		SELECT content_hash, groupArray(file_id), groupArray(file_path),
+		       groupArray(toString(scan_status)), groupArray(ioc_count), groupArray(processed_at),
+		       count() AS n
+		FROM threat_intel.file_registry FINAL
+		WHERE scan_status != 'deleted' AND content_hash != ''
+		GROUP BY content_hash
+		HAVING n > 1
+		ORDER BY n DESC, content_hash
+		LIMIT @limit OFFSET @offset
+	`, Params{"limit": limit, "offset": offset})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list duplicate files: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var group models.DuplicateFiles
+		var ids, paths, statuses []string
+		var counts []uint32
+		var processed []time.Time
+		var n uint64
+		if err := rows.Scan(&group.ContentHash, &ids, &paths, &statuses, &counts, &processed, &n); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		for idx := range ids {
+			group.Files = append(group.Files, models.DuplicateFile{
+				FileID:      ids[idx],
+				FilePath:    paths[idx],
+				ScanStatus:  models.ScanStatus(statuses[idx]),
+				IOCCount:    counts[idx],
+				ProcessedAt: processed[idx],
+			})
+		}
+		slices.SortFunc(group.Files, func(a, b models.DuplicateFile) int {
+			return b.ProcessedAt.Compare(a.ProcessedAt)
+		})
+		report.Groups = append(report.Groups, group)
+	}
+
+	return report, rows.Err()
+}
+
+// DeprecateIOCsBySource marks all IOC rows extracted from the given files as
+// deprecated and returns the distinct values that had active rows among them
+func (c *ClickHouseClient) DeprecateIOCsBySource(ctx context.Context, fileIDs []string) ([]string, error) {
+	return c.DeleteIOCs(ctx, nil, fileIDs, false)
+}
+
+// PurgeFiles removes registry entries, their recorded history and their
+// status from the file stats
+func (c *ClickHouseClient) PurgeFiles(ctx context.Context, fileIDs []string) error {
+	if len(fileIDs) == 0 {
+		return nil
+	}
+
+	tables := []string{"threat_intel.file_registry"}
+	if c.schemaVersion.Load() >= statsViewsVersion {
+		tables = append(tables, "threat_intel.file_status_stats")
+	}
+	if c.schemaVersion.Load() >= fileHistoryVersion {
+		tables = append(tables, "threat_intel.file_registry_history")
+	}
+	for _, table := range tables {
+		if err := c.exec(ctx, `ALTER TABLE `+table+` DELETE WHERE file_id IN (@file_ids)`, Params{"file_ids": fileIDs}); err != nil {
+			return fmt.Errorf("failed to purge files from %s: %w", table, err)
+		}
+	}
+	return nil
 }
 
 // ========== IOC Store Operations ==========
@@ -170,24 +523,35 @@ func (c *ClickHouseClient) BatchInsertIOCs(ctx context.Context, iocs []models.IO
 
 	batch, err := c.conn.PrepareBatch(ctx, `
 		INSERT INTO threat_intel.ioc_store 
-		(ioc_value, ioc_type, source_file_id, malware_family, confidence, first_seen, last_seen, hit_count, vector_id, tags)
+		(ioc_value, ioc_type, source_file_id, malware_family, confidence, dga_score, first_seen, last_seen, valid_until, hit_count, vector_id, tags, pending, sealed_value)
 	`)
 	if err != nil {
 		return fmt.Errorf("failed to prepare batch: %w", err)
 	}
 
 	for _, ioc := range iocs {
+		// Every insert path is scored here, so feeds, imports and replicas
+		// agree on the score without each computing it
+		dgaScore := ioc.DGAScore
+		if ioc.Type == models.IOCTypeDomain && dgaScore == 0 {
+			dgaScore = dga.Score(ioc.Value)
+		}
+
 		err := batch.Append(
 			ioc.Value,
 			string(ioc.Type),
 			ioc.SourceFileID,
 			ioc.MalwareFamily,
 			ioc.Confidence,
+			dgaScore,
 			ioc.FirstSeen,
 			ioc.LastSeen,
+			ioc.ValidUntil,
 			ioc.HitCount,
 			ioc.VectorID,
 			ioc.Tags,
+			ioc.Pending,
+			ioc.Sealed,
 		)
 		if err != nil {
 			return fmt.Errorf("failed to append to batch: %w", err)
@@ -202,28 +566,69 @@ func (c *ClickHouseClient) BatchInsertIOCs(ctx context.Context, iocs []models.IO
 	return nil
 }
 
-// QueryIOCs queries IOCs by their values
+// QueryIOCs returns one row per known value, aggregated across the source
+// files it appears in (see StreamIOCs)
 func (c *ClickHouseClient) QueryIOCs(ctx context.Context, iocValues []string) ([]models.IOC, error) {
+	results := make([]models.IOC, 0, len(iocValues))
+	err := c.StreamIOCs(ctx, iocValues, func(ioc models.IOC) error {
+		results = append(results, ioc)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// StreamIOCs calls fn with one aggregated row per known value, without
+// buffering the result set. Only the newest MaxRowsPerIOC sightings of each
+// value are considered, so a value seen in many files costs a bounded amount
+// of work. Attributes come from the newest sighting, first_seen/last_seen
+// span all of them, hit counts are summed and tags merged. Scanning stops as
+// soon as ctx is cancelled or fn returns an error.
+func (c *ClickHouseClient) StreamIOCs(ctx context.Context, iocValues []string, fn func(models.IOC) error) error {
 	if len(iocValues) == 0 {
-		return nil, nil
+		return nil
 	}
 
+	// valid_until is wrapped in a tuple so a newer NULL (no expiry) is not
+	// skipped in favour of an older row's expiry
 	query := `
-		SELECT ioc_value, ioc_type, source_file_id, malware_family, confidence, 
-		       first_seen, last_seen, hit_count, vector_id, tags
-		FROM threat_intel.ioc_store
-		WHERE ioc_value IN (?)
-		ORDER BY last_seen DESC
+		SELECT ioc_value,
+		       argMax(ioc_type, last_seen),
+		       argMax(source_file_id, last_seen),
+		       argMax(malware_family, last_seen),
+		       argMax(confidence, last_seen),
+		       max(dga_score),
+		       min(first_seen),
+		       max(last_seen),
+		       argMax(tuple(valid_until), last_seen).1,
+		       toUInt32(least(sum(hit_count), 4294967295)),
+		       argMax(vector_id, last_seen),
+		       groupUniqArrayArray(tags),
+		       min(pending)
+		FROM (
+			SELECT ioc_value, ioc_type, source_file_id, malware_family, confidence, dga_score,
+			       first_seen, last_seen, valid_until, hit_count, vector_id, tags, pending
+			FROM threat_intel.ioc_store
+			WHERE ioc_value IN (@values) AND deprecated = 0
+			ORDER BY ioc_value, last_seen DESC
+			LIMIT @per_value BY ioc_value
+		)
+		GROUP BY ioc_value
 	`
 
-	rows, err := c.conn.Query(ctx, query, iocValues)
+	rows, err := c.query(ctx, query, Params{"values": iocValues, "per_value": c.cfg.MaxRowsPerIOC})
 	if err != nil {
-		return nil, fmt.Errorf("failed to query IOCs: %w", err)
+		return fmt.Errorf("failed to query IOCs: %w", err)
 	}
 	defer rows.Close()
 
-	var results []models.IOC
 	for rows.Next() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
 		var ioc models.IOC
 		var iocType string
 
@@ -233,73 +638,1542 @@ func (c *ClickHouseClient) QueryIOCs(ctx context.Context, iocValues []string) ([
 			&ioc.SourceFileID,
 			&ioc.MalwareFamily,
 			&ioc.Confidence,
+			&ioc.DGAScore,
 			&ioc.FirstSeen,
 			&ioc.LastSeen,
+			&ioc.ValidUntil,
 			&ioc.HitCount,
 			&ioc.VectorID,
 			&ioc.Tags,
+			&ioc.Pending,
 		)
 		if err != nil {
-			return nil, fmt.Errorf("failed to scan row: %w", err)
+			return fmt.Errorf("failed to scan row: %w", err)
 		}
 
 		ioc.Type = models.IOCType(iocType)
-		results = append(results, ioc)
+		if err := fn(ioc); err != nil {
+			return err
+		}
 	}
 
-	return results, nil
+	return rows.Err()
 }
 
-// GetIOCStats returns statistics about IOCs by type
-func (c *ClickHouseClient) GetIOCStats(ctx context.Context) (map[models.IOCType]int64, error) {
-	query := `
-		SELECT ioc_type, count() as cnt
+// GetIOCSources returns the sources of each value, one per source file ID
+// with the most recently seen first and at most limit per value, and how
+// many sources each value has in all. The feed is read from the "source:"
+// tag the ingestor gives files crawled from a named DATA_PATH source.
+func (c *ClickHouseClient) GetIOCSources(ctx context.Context, values []string, limit int) (map[string][]models.IOCSource, map[string]int, error) {
+	sources := make(map[string][]models.IOCSource)
+	totals := make(map[string]int)
+	if len(values) == 0 || limit <= 0 {
+		return sources, totals, nil
+	}
+
+	rows, err := c.query(ctx, `
+		SELECT ioc_value,
+		       source_file_id,
+		       argMax(substring(arrayFirst(t -> startsWith(t, 'source:'), tags), 8), last_seen),
+		       argMax(malware_family, last_seen),
+		       argMax(confidence, last_seen),
+		       min(first_seen),
+		       max(last_seen) AS seen,
+		       count() OVER (PARTITION BY ioc_value)
 		FROM threat_intel.ioc_store
-		GROUP BY ioc_type
-	`
+		WHERE ioc_value IN (@values) AND deprecated = 0
+		GROUP BY ioc_value, source_file_id
+		ORDER BY ioc_value, seen DESC, source_file_id
+		LIMIT @limit BY ioc_value
+	`, Params{"values": values, "limit": limit})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to query IOC sources: %w", err)
+	}
+	defer rows.Close()
 
-	rows, err := c.conn.Query(ctx, query)
+	for rows.Next() {
+		var value string
+		var src models.IOCSource
+		var total uint64
+		err := rows.Scan(&value, &src.SourceFileID, &src.Feed, &src.MalwareFamily, &src.Confidence,
+			&src.FirstSeen, &src.LastSeen, &total)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		sources[value] = append(sources[value], src)
+		totals[value] = int(total)
+	}
+	return sources, totals, rows.Err()
+}
+
+// ListActiveRanges returns every active CIDR indicator, aggregated per block
+// like QueryIOCs, for matching addresses against in memory
+func (c *ClickHouseClient) ListActiveRanges(ctx context.Context) ([]models.IOC, error) {
+	rows, err := c.query(ctx, `
+		SELECT ioc_value,
+		       argMax(source_file_id, last_seen),
+		       argMax(malware_family, last_seen),
+		       argMax(confidence, last_seen),
+		       min(first_seen),
+		       max(last_seen),
+		       groupUniqArrayArray(tags)
+		FROM threat_intel.ioc_store
+		WHERE ioc_type = @type AND deprecated = 0 AND pending = 0
+		GROUP BY ioc_value
+	`, Params{"type": string(models.IOCTypeCIDR)})
 	if err != nil {
-		return nil, fmt.Errorf("failed to query IOC stats: %w", err)
+		return nil, fmt.Errorf("failed to list IP ranges: %w", err)
 	}
 	defer rows.Close()
 
-	stats := make(map[models.IOCType]int64)
+	var ranges []models.IOC
 	for rows.Next() {
-		var iocType string
-		var count int64
-		if err := rows.Scan(&iocType, &count); err != nil {
-			return nil, err
+		ioc := models.IOC{Type: models.IOCTypeCIDR}
+		err := rows.Scan(&ioc.Value, &ioc.SourceFileID, &ioc.MalwareFamily, &ioc.Confidence,
+			&ioc.FirstSeen, &ioc.LastSeen, &ioc.Tags)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
 		}
-		stats[models.IOCType(iocType)] = count
+		ranges = append(ranges, ioc)
 	}
+	return ranges, rows.Err()
+}
 
-	return stats, nil
+// SearchIOCs returns active indicators whose value matches q, exactly, as a
+// prefix or as a substring (see the models.SearchMode* constants), optionally
+// of one type and with a minimum DGA score, aggregated per value and type like
+// QueryIOCs. Matching is case-sensitive. An empty q matches every value and
+// lists the highest DGA scores first.
+func (c *ClickHouseClient) SearchIOCs(ctx context.Context, mode, q string, iocType models.IOCType, minDGAScore uint8, limit int) ([]models.IOC, error) {
+	b := searchSelect().
+		WhereIf(iocType != "", "ioc_type = @type", Params{"type": string(iocType)}).
+		WhereIf(minDGAScore > 0, "dga_score >= @min_dga_score", Params{"min_dga_score": minDGAScore}).
+		OrderBy("ioc_value", "ioc_type").
+		Limit(limit)
+	switch {
+	case q == "":
+		b.OrderBy("max(dga_score) DESC", "ioc_value", "ioc_type")
+	case mode == models.SearchModeExact:
+		b.Where("ioc_value = @q", Params{"q": q})
+	case mode == models.SearchModePrefix:
+		b.Where("startsWith(ioc_value, @q)", Params{"q": q})
+	case mode == models.SearchModeSubstring:
+		b.Where("ioc_value LIKE @q", Params{"q": "%" + likeEscaper.Replace(q) + "%"})
+	default:
+		return nil, fmt.Errorf("unknown search mode %q", mode)
+	}
+	return c.searchIOCs(ctx, b)
 }
 
-// GetFileStats returns statistics about processed files
-func (c *ClickHouseClient) GetFileStats(ctx context.Context) (map[models.ScanStatus]int64, error) {
+// ClickHouse error codes MatchIOCs translates
+const (
+	chTooManyRows     = 158
+	chTimeoutExceeded = 159
+)
+
+// ErrScanLimit is returned by MatchIOCs when matching would read more rows
+// than allowed
+var ErrScanLimit = errors.New("scan row limit exceeded")
+
+// MatchIOCs returns active indicators of one type whose value matches an RE2
+// regular expression, aggregated like SearchIOCs. Regular expressions cannot
+// use an index, so the scan is capped at maxRows rows read (0 = unlimited)
+// and at the context deadline, which is passed on to ClickHouse so abandoned
+// scans stop server-side too.
+func (c *ClickHouseClient) MatchIOCs(ctx context.Context, pattern string, iocType models.IOCType, minDGAScore uint8, limit, maxRows int) ([]models.IOC, error) {
+	settings := clickhouse.Settings{}
+	if maxRows > 0 {
+		settings["max_rows_to_read"] = maxRows
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		settings["max_execution_time"] = max(1, int(math.Ceil(time.Until(deadline).Seconds())))
+	}
+
+	b := searchSelect().
+		Where("match(ioc_value, @q)", Params{"q": pattern}).
+		Where("ioc_type = @type", Params{"type": string(iocType)}).
+		Where("dga_score >= @min_dga_score", Params{"min_dga_score": minDGAScore}).
+		OrderBy("ioc_value", "ioc_type").
+		Limit(limit)
+	iocs, err := c.searchIOCs(clickhouse.Context(ctx, clickhouse.WithSettings(settings)), b)
+
+	var ex *clickhouse.Exception
+	if errors.As(err, &ex) {
+		switch ex.Code {
+		case chTooManyRows:
+			return nil, ErrScanLimit
+		case chTimeoutExceeded:
+			return nil, fmt.Errorf("%w: %s", context.DeadlineExceeded, ex.Message)
+		}
+	}
+	return iocs, err
+}
+
+// searchSelect starts a search over active rows, aggregating matches per
+// value and type
+func searchSelect() *SelectBuilder {
+	return Select(
+		"ioc_value", "ioc_type",
+		"argMax(source_file_id, last_seen)",
+		"argMax(malware_family, last_seen)",
+		"argMax(confidence, last_seen)",
+		"max(dga_score)",
+		"min(first_seen)",
+		"max(last_seen)",
+		"groupUniqArrayArray(tags)",
+	).
+		From("threat_intel.ioc_store").
+		Where("deprecated = 0", nil).
+		GroupBy("ioc_value", "ioc_type")
+}
+
+// searchIOCs runs a search started with searchSelect
+func (c *ClickHouseClient) searchIOCs(ctx context.Context, b *SelectBuilder) ([]models.IOC, error) {
+	query, params, err := b.Build()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := c.analyticsQuery(ctx, query, params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search IOCs: %w", err)
+	}
+	defer rows.Close()
+
+	var iocs []models.IOC
+	for rows.Next() {
+		var ioc models.IOC
+		var t string
+		err := rows.Scan(&ioc.Value, &t, &ioc.SourceFileID, &ioc.MalwareFamily, &ioc.Confidence,
+			&ioc.DGAScore, &ioc.FirstSeen, &ioc.LastSeen, &ioc.Tags)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		ioc.Type = models.IOCType(t)
+		iocs = append(iocs, ioc)
+	}
+	return iocs, rows.Err()
+}
+
+// likeEscaper escapes LIKE wildcards so a search term matches literally
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// GetIndicatorsBySourceFiles returns the active IOCs extracted from the
+// given files, most widespread first, with the number of files containing each
+func (c *ClickHouseClient) GetIndicatorsBySourceFiles(ctx context.Context, fileIDs []string, limit int) ([]models.ClusterIndicator, error) {
+	if len(fileIDs) == 0 {
+		return nil, nil
+	}
+
 	query := `
-		SELECT scan_status, count() as cnt
-		FROM threat_intel.file_registry
-		GROUP BY scan_status
+		SELECT ioc_value, ioc_type, uniqExact(source_file_id) AS files,
+		       anyIf(malware_family, malware_family NOT IN ('', 'Unknown')) AS family
+		FROM threat_intel.ioc_store
+		WHERE source_file_id IN (@file_ids) AND deprecated = 0
+		GROUP BY ioc_value, ioc_type
+		ORDER BY files DESC, ioc_value
+		LIMIT @limit
 	`
 
-	rows, err := c.conn.Query(ctx, query)
+	rows, err := c.analyticsQuery(ctx, query, Params{"file_ids": fileIDs, "limit": limit})
 	if err != nil {
-		return nil, fmt.Errorf("failed to query file stats: %w", err)
+		return nil, fmt.Errorf("failed to query indicators by source: %w", err)
 	}
 	defer rows.Close()
 
-	stats := make(map[models.ScanStatus]int64)
+	var results []models.ClusterIndicator
 	for rows.Next() {
-		var status string
-		var count int64
-		if err := rows.Scan(&status, &count); err != nil {
-			return nil, err
+		var ind models.ClusterIndicator
+		var iocType string
+		var files uint64
+		if err := rows.Scan(&ind.Value, &iocType, &files, &ind.MalwareFamily); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
 		}
-		stats[models.ScanStatus(status)] = count
+		ind.Type = models.IOCType(iocType)
+		ind.Files = int(files)
+		results = append(results, ind)
 	}
 
-	return stats, nil
+	return results, rows.Err()
+}
+
+// ListIOCsBySourceFile returns the active IOCs extracted from one file,
+// aggregated per value and type and ordered by type then value, and how many
+// there are in all
+func (c *ClickHouseClient) ListIOCsBySourceFile(ctx context.Context, fileID string, limit, offset int) ([]models.IOC, uint64, error) {
+	params := Params{"file_id": fileID}
+
+	var total uint64
+	err := c.queryRow(ctx, `
+		SELECT uniqExact(ioc_value, ioc_type)
+		FROM threat_intel.ioc_store
+		WHERE source_file_id = @file_id AND deprecated = 0
+	`, params, &total)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to count file IOCs: %w", err)
+	}
+
+	params["limit"], params["offset"] = limit, offset
+	rows, err := c.query(ctx, `
+		SELECT ioc_value,
+		       toString(ioc_type) AS type,
+		       argMax(malware_family, last_seen),
+		       max(confidence),
+		       max(dga_score),
+		       min(first_seen),
+		       max(last_seen),
+		       sum(hit_count),
+		       groupUniqArrayArray(tags),
+		       min(pending)
+		FROM threat_intel.ioc_store
+		WHERE source_file_id = @file_id AND deprecated = 0
+		GROUP BY ioc_value, ioc_type
+		ORDER BY type, ioc_value
+		LIMIT @limit OFFSET @offset
+	`, params)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list file IOCs: %w", err)
+	}
+	defer rows.Close()
+
+	iocs := make([]models.IOC, 0, limit)
+	for rows.Next() {
+		ioc := models.IOC{SourceFileID: fileID}
+		var iocType string
+		var hits uint64
+		if err := rows.Scan(
+			&ioc.Value,
+			&iocType,
+			&ioc.MalwareFamily,
+			&ioc.Confidence,
+			&ioc.DGAScore,
+			&ioc.FirstSeen,
+			&ioc.LastSeen,
+			&hits,
+			&ioc.Tags,
+			&ioc.Pending,
+		); err != nil {
+			return nil, 0, fmt.Errorf("failed to scan row: %w", err)
+		}
+		ioc.Type = models.IOCType(iocType)
+		ioc.HitCount = uint32(min(hits, math.MaxUint32))
+		iocs = append(iocs, ioc)
+	}
+
+	return iocs, total, rows.Err()
+}
+
+// SelfTestSourceID is the source_file_id of synthetic IOCs inserted by the
+// API self-test; they are deleted after each run
+const SelfTestSourceID = "selftest"
+
+// DeleteSelfTestIOCs removes all synthetic self-test IOCs, including any
+// left behind by an interrupted run
+func (c *ClickHouseClient) DeleteSelfTestIOCs(ctx context.Context) error {
+	err := c.exec(ctx, `
+		ALTER TABLE threat_intel.ioc_store
+		DELETE WHERE source_file_id = @source_file_id
+	`, Params{"source_file_id": SelfTestSourceID})
+	if err != nil {
+		return fmt.Errorf("failed to delete self-test IOCs: %w", err)
+	}
+	return nil
+}
+
+// DeprecateIOC marks every active row for an IOC value as deprecated and
+// returns how many rows were affected (0 if the value is unknown)
+func (c *ClickHouseClient) DeprecateIOC(ctx context.Context, value string) (uint64, error) {
+	var active uint64
+	err := c.queryRow(ctx, `
+		SELECT count()
+		FROM threat_intel.ioc_store
+		WHERE ioc_value = @value AND deprecated = 0
+	`, Params{"value": value}, &active)
+	if err != nil {
+		return 0, fmt.Errorf("failed to look up IOC: %w", err)
+	}
+	if active == 0 {
+		return 0, nil
+	}
+
+	err = c.execSync(ctx, `
+		ALTER TABLE threat_intel.ioc_store
+		UPDATE deprecated = 1
+		WHERE ioc_value = @value
+	`, Params{"value": value})
+	if err != nil {
+		return 0, fmt.Errorf("failed to deprecate IOC: %w", err)
+	}
+
+	return active, nil
+}
+
+// DeleteIOCs marks the IOC rows holding the given values, or extracted from
+// the given files, deprecated, or removes them when purge is set. It returns
+// the distinct values that had rows: active rows when deprecating, any when
+// purging.
+func (c *ClickHouseClient) DeleteIOCs(ctx context.Context, values, fileIDs []string, purge bool) ([]string, error) {
+	var targets []string
+	params := Params{}
+	if len(values) > 0 {
+		targets = append(targets, "ioc_value IN (@values)")
+		params["values"] = values
+	}
+	if len(fileIDs) > 0 {
+		targets = append(targets, "source_file_id IN (@file_ids)")
+		params["file_ids"] = fileIDs
+	}
+	if len(targets) == 0 {
+		return nil, nil
+	}
+	where := "(" + strings.Join(targets, " OR ") + ")"
+	if !purge {
+		where += " AND deprecated = 0"
+	}
+
+	rows, err := c.query(ctx, `SELECT DISTINCT ioc_value FROM threat_intel.ioc_store WHERE `+where, params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up IOCs: %w", err)
+	}
+	defer rows.Close()
+
+	var affected []string
+	for rows.Next() {
+		var value string
+		if err := rows.Scan(&value); err != nil {
+			return nil, err
+		}
+		affected = append(affected, value)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(affected) == 0 {
+		return nil, nil
+	}
+
+	stmt := `ALTER TABLE threat_intel.ioc_store UPDATE deprecated = 1 WHERE ` + where
+	if purge {
+		stmt = `ALTER TABLE threat_intel.ioc_store DELETE WHERE ` + where
+	}
+	if err := c.execSync(ctx, stmt, params); err != nil {
+		return nil, fmt.Errorf("failed to delete IOCs: %w", err)
+	}
+	return affected, nil
+}
+
+// GetSealedValues returns the sealed original stored with each active
+// redacted value that has one (see package redact)
+func (c *ClickHouseClient) GetSealedValues(ctx context.Context, values []string) (map[string]string, error) {
+	result := make(map[string]string)
+	if len(values) == 0 {
+		return result, nil
+	}
+
+	rows, err := c.query(ctx, `
+		SELECT ioc_value, any(sealed_value)
+		FROM threat_intel.ioc_store
+		WHERE ioc_value IN (@values) AND sealed_value != '' AND deprecated = 0
+		GROUP BY ioc_value
+	`, Params{"values": values})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query sealed values: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var value, sealed string
+		if err := rows.Scan(&value, &sealed); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		result[value] = sealed
+	}
+	return result, rows.Err()
+}
+
+// ListPendingIOCs returns the values awaiting review, aggregated per value,
+// longest waiting first, and how many there are in all. iocType narrows the
+// queue when set.
+func (c *ClickHouseClient) ListPendingIOCs(ctx context.Context, iocType models.IOCType, limit, offset int) ([]models.IOC, uint64, error) {
+	params := Params{"type": string(iocType)}
+
+	var total uint64
+	err := c.queryRow(ctx, `
+		SELECT uniqExact(ioc_value)
+		FROM threat_intel.ioc_store
+		WHERE pending = 1 AND deprecated = 0 AND (@type = '' OR toString(ioc_type) = @type)
+	`, params, &total)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to count pending IOCs: %w", err)
+	}
+
+	params["limit"], params["offset"] = limit, offset
+	rows, err := c.query(ctx, `
+		SELECT ioc_value,
+		       toString(argMax(ioc_type, last_seen)),
+		       argMax(source_file_id, last_seen),
+		       argMax(malware_family, last_seen),
+		       max(confidence),
+		       max(dga_score),
+		       min(first_seen) AS first,
+		       max(last_seen),
+		       groupUniqArrayArray(tags)
+		FROM threat_intel.ioc_store
+		WHERE pending = 1 AND deprecated = 0 AND (@type = '' OR toString(ioc_type) = @type)
+		GROUP BY ioc_value
+		ORDER BY first, ioc_value
+		LIMIT @limit OFFSET @offset
+	`, params)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list pending IOCs: %w", err)
+	}
+	defer rows.Close()
+
+	iocs := make([]models.IOC, 0, limit)
+	for rows.Next() {
+		ioc := models.IOC{Pending: true}
+		var iocType string
+		if err := rows.Scan(
+			&ioc.Value,
+			&iocType,
+			&ioc.SourceFileID,
+			&ioc.MalwareFamily,
+			&ioc.Confidence,
+			&ioc.DGAScore,
+			&ioc.FirstSeen,
+			&ioc.LastSeen,
+			&ioc.Tags,
+		); err != nil {
+			return nil, 0, fmt.Errorf("failed to scan row: %w", err)
+		}
+		ioc.Type = models.IOCType(iocType)
+		iocs = append(iocs, ioc)
+	}
+
+	return iocs, total, rows.Err()
+}
+
+// ReviewIOCs approves or rejects the pending rows of values and returns how
+// many rows were affected. Approved rows are served by /check; rejected ones
+// are deprecated.
+func (c *ClickHouseClient) ReviewIOCs(ctx context.Context, values []string, approve bool) (uint64, error) {
+	if len(values) == 0 {
+		return 0, nil
+	}
+
+	var pending uint64
+	err := c.queryRow(ctx, `
+		SELECT count()
+		FROM threat_intel.ioc_store
+		WHERE ioc_value IN (@values) AND pending = 1 AND deprecated = 0
+	`, Params{"values": values}, &pending)
+	if err != nil {
+		return 0, fmt.Errorf("failed to look up pending IOCs: %w", err)
+	}
+	if pending == 0 {
+		return 0, nil
+	}
+
+	set := "deprecated = 1"
+	if approve {
+		set = "pending = 0"
+	}
+	query := "ALTER TABLE threat_intel.ioc_store UPDATE " + set +
+		" WHERE ioc_value IN (@values) AND pending = 1 AND deprecated = 0"
+	if err := c.execSync(ctx, query, Params{"values": values}); err != nil {
+		return 0, fmt.Errorf("failed to review IOCs: %w", err)
+	}
+	return pending, nil
+}
+
+// CountIOCs returns how many active rows filter selects
+func (c *ClickHouseClient) CountIOCs(ctx context.Context, filter models.IOCFilter) (uint64, error) {
+	query, params, err := iocFilterSelect(filter, "count()").Build()
+	if err != nil {
+		return 0, err
+	}
+
+	var n uint64
+	if err := c.queryRow(ctx, query, params, &n); err != nil {
+		return 0, fmt.Errorf("failed to count IOCs: %w", err)
+	}
+	return n, nil
+}
+
+// BulkUpdateIOCs applies update to every active row filter selects in one
+// mutation and returns how many rows were selected, once the mutation is
+// applied.
+func (c *ClickHouseClient) BulkUpdateIOCs(ctx context.Context, filter models.IOCFilter, update models.IOCUpdate) (uint64, error) {
+	n, err := c.CountIOCs(ctx, filter)
+	if err != nil || n == 0 {
+		return 0, err
+	}
+
+	where, params, err := iocFilterSelect(filter).WhereClause()
+	if err != nil {
+		return 0, err
+	}
+
+	var set []string
+	if len(update.AddTags) > 0 || len(update.RemoveTags) > 0 {
+		tags := "tags"
+		if len(update.RemoveTags) > 0 {
+			tags = "arrayFilter(t -> NOT has(@remove_tags, t), " + tags + ")"
+			params["remove_tags"] = update.RemoveTags
+		}
+		if len(update.AddTags) > 0 {
+			tags = "arrayDistinct(arrayConcat(" + tags + ", @add_tags))"
+			params["add_tags"] = update.AddTags
+		}
+		set = append(set, "tags = "+tags)
+	}
+	if update.Confidence != nil {
+		set = append(set, "confidence = @confidence")
+		params["confidence"] = *update.Confidence
+	}
+	if update.MalwareFamily != "" {
+		set = append(set, "malware_family = @malware_family")
+		params["malware_family"] = update.MalwareFamily
+	}
+	if len(set) == 0 {
+		return n, nil
+	}
+
+	query := "ALTER TABLE threat_intel.ioc_store UPDATE " + strings.Join(set, ", ") + " WHERE " + where
+	if err := c.execSync(ctx, query, params); err != nil {
+		return 0, fmt.Errorf("failed to update IOCs: %w", err)
+	}
+	return n, nil
+}
+
+// ListIOCFilterValues returns up to limit distinct values among the active
+// rows filter selects
+func (c *ClickHouseClient) ListIOCFilterValues(ctx context.Context, filter models.IOCFilter, limit int) ([]string, error) {
+	query, params, err := iocFilterSelect(filter, "DISTINCT ioc_value").Limit(limit).Build()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := c.query(ctx, query, params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list filtered IOCs: %w", err)
+	}
+	defer rows.Close()
+
+	var values []string
+	for rows.Next() {
+		var v string
+		if err := rows.Scan(&v); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		values = append(values, v)
+	}
+	return values, rows.Err()
+}
+
+// iocFilterSelect starts a query over the active rows a bulk filter
+// selects, never selecting self-test rows. The SQL depends only on which
+// filters are set.
+func iocFilterSelect(filter models.IOCFilter, columns ...string) *SelectBuilder {
+	b := Select(columns...).
+		From("threat_intel.ioc_store").
+		Where("deprecated = 0", nil).
+		Where("source_file_id != @selftest_source", Params{"selftest_source": SelfTestSourceID}).
+		WhereIf(len(filter.Tags) > 0, "hasAny(tags, @filter_tags)", Params{"filter_tags": filter.Tags}).
+		WhereIf(len(filter.SourceFileIDs) > 0, "source_file_id IN (@filter_source_file_ids)", Params{"filter_source_file_ids": filter.SourceFileIDs}).
+		WhereIf(filter.Type != "", "ioc_type = @filter_type", Params{"filter_type": string(filter.Type)})
+	if filter.Since != nil {
+		b.Where("last_seen >= @filter_since", Params{"filter_since": *filter.Since})
+	}
+	if filter.Until != nil {
+		b.Where("last_seen < @filter_until", Params{"filter_until": *filter.Until})
+	}
+	return b
+}
+
+// StreamActiveIOCValues calls fn with batches of non-deprecated IOC values
+// last seen at or after since. Values may repeat across source files.
+func (c *ClickHouseClient) StreamActiveIOCValues(ctx context.Context, since time.Time, batchSize int, fn func([]string) error) error {
+	rows, err := c.query(ctx, `
+		SELECT ioc_value
+		FROM threat_intel.ioc_store
+		WHERE deprecated = 0 AND last_seen >= @since
+	`, Params{"since": since})
+	if err != nil {
+		return fmt.Errorf("failed to stream IOC values: %w", err)
+	}
+	defer rows.Close()
+
+	batch := make([]string, 0, batchSize)
+	for rows.Next() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		var value string
+		if err := rows.Scan(&value); err != nil {
+			return err
+		}
+		batch = append(batch, value)
+
+		if len(batch) >= batchSize {
+			if err := fn(batch); err != nil {
+				return err
+			}
+			batch = batch[:0]
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	if len(batch) > 0 {
+		return fn(batch)
+	}
+	return nil
+}
+
+// syncSettleDelay holds back rows ingested in the last few seconds from
+// replicas: same-second inserts still in flight could otherwise land behind
+// a cursor that has already moved past them
+const syncSettleDelay = 10
+
+// GetIOCsAfter returns up to limit active IOC rows ingested after cursor, in
+// (ingested_at, ioc_value, source_file_id) order, for replicating to another
+// deployment. Self-test rows are never replicated. This reads the primary
+// connection, since a lagging replica could let the cursor skip rows.
+func (c *ClickHouseClient) GetIOCsAfter(ctx context.Context, cursor models.SyncCursor, limit int) ([]models.SyncIOC, error) {
+	// DateTime starts at the epoch; a zero cursor means "from the beginning"
+	after := cursor.IngestedAt
+	if after.Before(time.Unix(0, 0)) {
+		after = time.Unix(0, 0)
+	}
+
+	rows, err := c.query(ctx, `
+		SELECT ioc_value, toString(ioc_type), source_file_id, malware_family, confidence,
+		       first_seen, last_seen, valid_until, hit_count, tags, pending, ingested_at
+		FROM threat_intel.ioc_store
+		WHERE deprecated = 0 AND source_file_id != @selftest
+		  AND ingested_at <= now() - INTERVAL @settle SECOND
+		  AND (ingested_at, ioc_value, source_file_id) > (@after, @value, @source_file_id)
+		ORDER BY ingested_at, ioc_value, source_file_id
+		LIMIT @limit
+	`, Params{
+		"selftest":       SelfTestSourceID,
+		"settle":         syncSettleDelay,
+		"after":          after,
+		"value":          cursor.Value,
+		"source_file_id": cursor.SourceFileID,
+		"limit":          limit,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query IOCs for sync: %w", err)
+	}
+	defer rows.Close()
+
+	iocs := make([]models.SyncIOC, 0, limit)
+	for rows.Next() {
+		var ioc models.SyncIOC
+		var iocType string
+		if err := rows.Scan(
+			&ioc.Value,
+			&iocType,
+			&ioc.SourceFileID,
+			&ioc.MalwareFamily,
+			&ioc.Confidence,
+			&ioc.FirstSeen,
+			&ioc.LastSeen,
+			&ioc.ValidUntil,
+			&ioc.HitCount,
+			&ioc.Tags,
+			&ioc.Pending,
+			&ioc.IngestedAt,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		ioc.Type = models.IOCType(iocType)
+		iocs = append(iocs, ioc)
+	}
+
+	return iocs, rows.Err()
+}
+
+// ========== Domain Resolution ==========
+
+// ListDomainsDueForResolution returns active domain IOCs that have not been
+// resolved since olderThan, with their strongest confidence and a malware family
+func (c *ClickHouseClient) ListDomainsDueForResolution(ctx context.Context, olderThan time.Time, limit int) ([]models.IOC, error) {
+	rows, err := c.analyticsQuery(ctx, `
+		SELECT ioc_value, any(malware_family), max(confidence)
+		FROM threat_intel.ioc_store
+		WHERE ioc_type = 'domain' AND deprecated = 0
+		  AND ioc_value NOT IN (
+			SELECT domain FROM threat_intel.domain_resolution FINAL
+			WHERE resolved_at >= @older_than
+		  )
+		GROUP BY ioc_value
+		LIMIT @limit
+	`, Params{"older_than": olderThan, "limit": limit})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list domains for resolution: %w", err)
+	}
+	defer rows.Close()
+
+	var domains []models.IOC
+	for rows.Next() {
+		ioc := models.IOC{Type: models.IOCTypeDomain}
+		if err := rows.Scan(&ioc.Value, &ioc.MalwareFamily, &ioc.Confidence); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		domains = append(domains, ioc)
+	}
+	return domains, rows.Err()
+}
+
+// InsertDomainResolutions records resolution results
+func (c *ClickHouseClient) InsertDomainResolutions(ctx context.Context, resolutions []models.DomainResolution) error {
+	if len(resolutions) == 0 {
+		return nil
+	}
+
+	batch, err := c.conn.PrepareBatch(ctx, `
+		INSERT INTO threat_intel.domain_resolution (domain, status, addresses, resolved_at)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare batch: %w", err)
+	}
+
+	for _, r := range resolutions {
+		if err := batch.Append(r.Domain, r.Status, r.Addresses, r.ResolvedAt); err != nil {
+			return fmt.Errorf("failed to append to batch: %w", err)
+		}
+	}
+
+	return batch.Send()
+}
+
+// GetDomainResolutions returns the latest resolution of each known domain
+func (c *ClickHouseClient) GetDomainResolutions(ctx context.Context, domains []string) (map[string]models.DomainResolution, error) {
+	result := make(map[string]models.DomainResolution)
+	if len(domains) == 0 {
+		return result, nil
+	}
+
+	rows, err := c.query(ctx, `
+		SELECT domain, status, addresses, resolved_at
+		FROM threat_intel.domain_resolution FINAL
+		WHERE domain IN (@domains)
+	`, Params{"domains": domains})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query domain resolutions: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var r models.DomainResolution
+		if err := rows.Scan(&r.Domain, &r.Status, &r.Addresses, &r.ResolvedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		result[r.Domain] = r
+	}
+	return result, rows.Err()
+}
+
+// InsertWhoisRelationships records registration data of looked-up domains
+func (c *ClickHouseClient) InsertWhoisRelationships(ctx context.Context, rels []models.WhoisRelationship) error {
+	if len(rels) == 0 {
+		return nil
+	}
+
+	batch, err := c.conn.PrepareBatch(ctx, `
+		INSERT INTO threat_intel.whois_relationship (domain, relation, value, observed_at)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare batch: %w", err)
+	}
+
+	for _, r := range rels {
+		if err := batch.Append(r.Domain, r.Relation, r.Value, r.ObservedAt); err != nil {
+			return fmt.Errorf("failed to append to batch: %w", err)
+		}
+	}
+
+	return batch.Send()
+}
+
+// GetWhoisRelatedDomains returns the domains seen with the given registrar,
+// nameserver or registrant email, most recently observed first
+func (c *ClickHouseClient) GetWhoisRelatedDomains(ctx context.Context, relation, value string, limit int) ([]models.WhoisRelationship, error) {
+	rows, err := c.analyticsQuery(ctx, `
+		SELECT domain, max(observed_at) AS observed
+		FROM threat_intel.whois_relationship
+		WHERE relation = @relation AND value = @value
+		GROUP BY domain
+		ORDER BY observed DESC, domain
+		LIMIT @limit
+	`, Params{"relation": relation, "value": value, "limit": limit})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query WHOIS relationships: %w", err)
+	}
+	defer rows.Close()
+
+	var rels []models.WhoisRelationship
+	for rows.Next() {
+		r := models.WhoisRelationship{Relation: relation, Value: value}
+		if err := rows.Scan(&r.Domain, &r.ObservedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		rels = append(rels, r)
+	}
+	return rels, rows.Err()
+}
+
+// ========== Extraction Samples ==========
+
+// extractionSampleColumns are the columns scanExtractionSample reads
+const extractionSampleColumns = `sample_id, ioc_value, ioc_type, source_file_id, extractor_version,
+	match_offset, snippet, sampled_at, label, labeled_by, labeled_at`
+
+// InsertExtractionSamples stores sampled extractions, or new versions of
+// them when relabeled
+func (c *ClickHouseClient) InsertExtractionSamples(ctx context.Context, samples []models.ExtractionSample) error {
+	if len(samples) == 0 {
+		return nil
+	}
+
+	batch, err := c.conn.PrepareBatch(ctx, `INSERT INTO threat_intel.extraction_samples (`+extractionSampleColumns+`)`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare batch: %w", err)
+	}
+
+	for _, s := range samples {
+		labeledAt := time.Unix(0, 0)
+		if s.LabeledAt != nil {
+			labeledAt = *s.LabeledAt
+		}
+		if err := batch.Append(
+			s.ID, s.Value, string(s.Type), s.SourceFileID, s.ExtractorVersion,
+			s.Offset, s.Snippet, s.SampledAt, s.Label, s.LabeledBy, labeledAt,
+		); err != nil {
+			return fmt.Errorf("failed to append to batch: %w", err)
+		}
+	}
+
+	return batch.Send()
+}
+
+// ListExtractionSamples returns a page of samples, oldest first, and how
+// many there are in all. iocType and status ("labeled" or "unlabeled")
+// narrow the list when set.
+func (c *ClickHouseClient) ListExtractionSamples(ctx context.Context, iocType models.IOCType, status string, limit, offset int) ([]models.ExtractionSample, uint64, error) {
+	const where = `
+		WHERE (@type = '' OR ioc_type = @type)
+		  AND (@status = '' OR (label != '') = (@status = 'labeled'))`
+	params := Params{"type": string(iocType), "status": status}
+
+	var total uint64
+	err := c.queryRow(ctx, `SELECT count() FROM threat_intel.extraction_samples FINAL`+where, params, &total)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to count extraction samples: %w", err)
+	}
+
+	params["limit"], params["offset"] = limit, offset
+	rows, err := c.query(ctx, `
+		SELECT `+extractionSampleColumns+`
+		FROM threat_intel.extraction_samples FINAL`+where+`
+		ORDER BY sampled_at, sample_id
+		LIMIT @limit OFFSET @offset
+	`, params)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list extraction samples: %w", err)
+	}
+	defer rows.Close()
+
+	samples := make([]models.ExtractionSample, 0, limit)
+	for rows.Next() {
+		s, err := scanExtractionSample(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		samples = append(samples, s)
+	}
+	return samples, total, rows.Err()
+}
+
+// GetExtractionSamples returns the latest version of the samples with the
+// given IDs, by ID
+func (c *ClickHouseClient) GetExtractionSamples(ctx context.Context, ids []string) (map[string]models.ExtractionSample, error) {
+	result := make(map[string]models.ExtractionSample)
+	if len(ids) == 0 {
+		return result, nil
+	}
+
+	rows, err := c.query(ctx, `
+		SELECT `+extractionSampleColumns+`
+		FROM threat_intel.extraction_samples FINAL
+		WHERE sample_id IN (@ids)
+	`, Params{"ids": ids})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query extraction samples: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		s, err := scanExtractionSample(rows)
+		if err != nil {
+			return nil, err
+		}
+		result[s.ID] = s
+	}
+	return result, rows.Err()
+}
+
+// scanExtractionSample reads a row of extractionSampleColumns
+func scanExtractionSample(rows driver.Rows) (models.ExtractionSample, error) {
+	var s models.ExtractionSample
+	var iocType string
+	var labeledAt time.Time
+	if err := rows.Scan(
+		&s.ID, &s.Value, &iocType, &s.SourceFileID, &s.ExtractorVersion,
+		&s.Offset, &s.Snippet, &s.SampledAt, &s.Label, &s.LabeledBy, &labeledAt,
+	); err != nil {
+		return s, fmt.Errorf("failed to scan row: %w", err)
+	}
+	s.Type = models.IOCType(iocType)
+	if s.Label != "" {
+		s.LabeledAt = &labeledAt
+	}
+	return s, nil
+}
+
+// precisionGroups maps the groupings of GetSamplePrecision to the
+// expression grouping by it
+var precisionGroups = map[string]string{
+	models.PrecisionByDay:     "toString(toDate(sampled_at))",
+	models.PrecisionByWeek:    "toString(toMonday(sampled_at))",
+	models.PrecisionByVersion: "extractor_version",
+}
+
+// GetSamplePrecision estimates the precision of each IOC type from the
+// samples labeled so far, per day or week sampled or per extractor version
+// (groupBy), over samples taken since since. iocType narrows the estimates
+// when set.
+func (c *ClickHouseClient) GetSamplePrecision(ctx context.Context, groupBy string, iocType models.IOCType, since time.Time) ([]models.PrecisionEstimate, error) {
+	group, ok := precisionGroups[groupBy]
+	if !ok {
+		return nil, fmt.Errorf("unknown precision grouping %q", groupBy)
+	}
+
+	rows, err := c.analyticsQuery(ctx, `
+		SELECT ioc_type, `+group+` AS grp,
+		       countIf(label = @tp),
+		       countIf(label = @fp)
+		FROM threat_intel.extraction_samples FINAL
+		WHERE label != '' AND sampled_at >= @since AND (@type = '' OR ioc_type = @type)
+		GROUP BY ioc_type, grp
+		ORDER BY ioc_type, grp
+	`, Params{
+		"tp":    models.LabelTruePositive,
+		"fp":    models.LabelFalsePositive,
+		"since": since,
+		"type":  string(iocType),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query sample precision: %w", err)
+	}
+	defer rows.Close()
+
+	estimates := []models.PrecisionEstimate{}
+	for rows.Next() {
+		var t, g string
+		var tp, fp uint64
+		if err := rows.Scan(&t, &g, &tp, &fp); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		estimates = append(estimates, models.NewPrecisionEstimate(models.IOCType(t), g, tp, fp))
+	}
+	return estimates, rows.Err()
+}
+
+// ========== Audit Log ==========
+
+// InsertAuditEntry records an administrative action
+func (c *ClickHouseClient) InsertAuditEntry(ctx context.Context, entry models.AuditEntry) error {
+	query := `
+		INSERT INTO threat_intel.ioc_audit_log
+		(timestamp, action, ioc_value, actor, reason, client_ip, rows_affected)
+		VALUES (@timestamp, @action, @ioc_value, @actor, @reason, @client_ip, @rows_affected)
+	`
+
+	return c.exec(ctx, query, Params{
+		"timestamp":     entry.Timestamp,
+		"action":        entry.Action,
+		"ioc_value":     entry.IOCValue,
+		"actor":         entry.Actor,
+		"reason":        entry.Reason,
+		"client_ip":     entry.ClientIP,
+		"rows_affected": entry.RowsAffected,
+	})
+}
+
+// ========== Query Log ==========
+
+// InsertQueryLog records a lookup request. The insert is asynchronous on the
+// server so per-request rows do not each create a part.
+func (c *ClickHouseClient) InsertQueryLog(ctx context.Context, entry models.QueryLogEntry) error {
+	query := `
+		INSERT INTO threat_intel.query_log
+		(timestamp, api_key_hash, endpoint, iocs_queried, iocs_found, response_time_ms, client_ip)
+		VALUES (@timestamp, @api_key_hash, @endpoint, @iocs_queried, @iocs_found, @response_time_ms, @client_ip)
+	`
+
+	args, err := prepare(query).bind(Params{
+		"timestamp":        entry.Timestamp,
+		"api_key_hash":     entry.APIKeyHash,
+		"endpoint":         entry.Endpoint,
+		"iocs_queried":     entry.IOCsQueried,
+		"iocs_found":       entry.IOCsFound,
+		"response_time_ms": entry.ResponseTimeMs,
+		"client_ip":        entry.ClientIP,
+	})
+	if err != nil {
+		return err
+	}
+	return c.conn.AsyncInsert(ctx, query, false, args...)
+}
+
+// topQueries computes each GET /stats/top dimension from the query log.
+// Matches are credited to every active stored row of the matched value, so
+// a value found in three files counts once for each file.
+var topQueries = map[string]string{
+	models.TopDimensionQueried: `
+		SELECT ioc, count() AS lookups, countIf(has(iocs_found, ioc)), ''
+		FROM threat_intel.query_log
+		ARRAY JOIN iocs_queried AS ioc
+		WHERE timestamp >= @since
+		GROUP BY ioc
+		ORDER BY lookups DESC, ioc
+		LIMIT @limit
+	`,
+	models.TopDimensionMalwareFamily: `
+		SELECT i.malware_family AS family, count() AS matches, toUInt64(0), ''
+		FROM (
+			SELECT arrayJoin(iocs_found) AS ioc
+			FROM threat_intel.query_log
+			WHERE timestamp >= @since
+		) AS q
+		INNER JOIN (
+			SELECT ioc_value, argMax(malware_family, last_seen) AS malware_family
+			FROM threat_intel.ioc_store
+			WHERE deprecated = 0
+			GROUP BY ioc_value
+		) AS i ON i.ioc_value = q.ioc
+		GROUP BY family
+		ORDER BY matches DESC, family
+		LIMIT @limit
+	`,
+	models.TopDimensionSourceFile: `
+		SELECT m.source_file_id, m.matches, toUInt64(0), f.file_path
+		FROM (
+			SELECT i.source_file_id AS source_file_id, count() AS matches
+			FROM (
+				SELECT arrayJoin(iocs_found) AS ioc
+				FROM threat_intel.query_log
+				WHERE timestamp >= @since
+			) AS q
+			INNER JOIN (
+				SELECT DISTINCT ioc_value, source_file_id
+				FROM threat_intel.ioc_store
+				WHERE deprecated = 0
+			) AS i ON i.ioc_value = q.ioc
+			GROUP BY source_file_id
+			ORDER BY matches DESC, source_file_id
+			LIMIT @limit
+		) AS m
+		LEFT JOIN (
+			SELECT file_id, file_path
+			FROM threat_intel.file_registry FINAL
+		) AS f ON f.file_id = m.source_file_id
+		ORDER BY m.matches DESC, m.source_file_id
+	`,
+}
+
+// GetTopLookups ranks the given dimension over lookups made since since
+func (c *ClickHouseClient) GetTopLookups(ctx context.Context, dimension string, since time.Time, limit int) ([]models.TopEntry, error) {
+	query, ok := topQueries[dimension]
+	if !ok {
+		return nil, fmt.Errorf("unknown top dimension %q", dimension)
+	}
+
+	rows, err := c.analyticsQuery(ctx, query, Params{"since": since, "limit": limit})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query top %s: %w", dimension, err)
+	}
+	defer rows.Close()
+
+	entries := []models.TopEntry{}
+	for rows.Next() {
+		var e models.TopEntry
+		if err := rows.Scan(&e.Key, &e.Count, &e.Found, &e.FilePath); err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+
+	return entries, rows.Err()
+}
+
+// GetIOCStats returns statistics about IOCs by type. Counts come from the
+// ioc_type_stats view when the schema has it (approximate, excluding
+// self-test rows), otherwise from a full scan of ioc_store.
+func (c *ClickHouseClient) GetIOCStats(ctx context.Context) (map[models.IOCType]int64, error) {
+	counts, err := c.statsWithFallback(ctx, "IOC", `
+		SELECT ioc_type, uniqCombined64Merge(rows)
+		FROM threat_intel.ioc_type_stats
+		GROUP BY ioc_type
+	`, `
+		SELECT toString(ioc_type), count()
+		FROM threat_intel.ioc_store
+		GROUP BY ioc_type
+	`)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := make(map[models.IOCType]int64, len(counts))
+	for k, v := range counts {
+		stats[models.IOCType(k)] = v
+	}
+	return stats, nil
+}
+
+// GetFileStats returns statistics about processed files. Counts come from
+// the file_status_stats view when the schema has it, otherwise from a full
+// scan of file_registry.
+func (c *ClickHouseClient) GetFileStats(ctx context.Context) (map[models.ScanStatus]int64, error) {
+	counts, err := c.statsWithFallback(ctx, "file", `
+		SELECT status, count()
+		FROM (
+			SELECT argMaxMerge(status) AS status
+			FROM threat_intel.file_status_stats
+			GROUP BY file_id
+		)
+		GROUP BY status
+	`, `
+		SELECT toString(scan_status), count()
+		FROM threat_intel.file_registry
+		GROUP BY scan_status
+	`)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := make(map[models.ScanStatus]int64, len(counts))
+	for k, v := range counts {
+		stats[models.ScanStatus(k)] = v
+	}
+	return stats, nil
+}
+
+// statsWithFallback runs a (key, count) query against a stats view, falling
+// back to the equivalent table scan on schemas without the view or if the
+// view cannot be read
+func (c *ClickHouseClient) statsWithFallback(ctx context.Context, kind, viewQuery, scanQuery string) (map[string]int64, error) {
+	if c.schemaVersion.Load() >= statsViewsVersion {
+		counts, err := c.countBy(ctx, viewQuery)
+		if err == nil {
+			return counts, nil
+		}
+		log.Warn().Err(err).Str("stats", kind).Msg("Stats view unavailable, scanning table")
+	}
+
+	counts, err := c.countBy(ctx, scanQuery)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query %s stats: %w", kind, err)
+	}
+	return counts, nil
+}
+
+// countBy collects the rows of a (key, count) query
+func (c *ClickHouseClient) countBy(ctx context.Context, query string) (map[string]int64, error) {
+	rows, err := c.analyticsQuery(ctx, query, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make(map[string]int64)
+	for rows.Next() {
+		var key string
+		var count uint64
+		if err := rows.Scan(&key, &count); err != nil {
+			return nil, err
+		}
+		counts[key] = int64(count)
+	}
+	return counts, rows.Err()
+}
+
+// ageBuckets is the number of IOC age histogram buckets: under a day, then
+// [1,2), [2,4), ... [256,512) days, then 512 days and older
+const ageBuckets = 11
+
+// ageBucket is the SQL expression placing a timestamp column in its bucket
+func ageBucket(column string) string {
+	return fmt.Sprintf(
+		"toUInt32(if(dateDiff('day', %[1]s, now()) < 1, 0, least(floor(log2(dateDiff('day', %[1]s, now()))) + 1, %[2]d)))",
+		column, ageBuckets-1)
+}
+
+// AgeBucketLabels returns the labels of the IOC age histogram buckets
+func AgeBucketLabels() []string {
+	labels := []string{"<1d"}
+	for b := 1; b < ageBuckets-1; b++ {
+		labels = append(labels, fmt.Sprintf("%d-%dd", 1<<(b-1), 1<<b))
+	}
+	return append(labels, fmt.Sprintf(">=%dd", 1<<(ageBuckets-2)))
+}
+
+// GetFreshnessStats returns the age distribution of active IOCs by type, the
+// number past their validity window, and the staleness of up to feedLimit
+// feed documents of the given formats
+func (c *ClickHouseClient) GetFreshnessStats(ctx context.Context, feedFormats []string, feedLimit int) (*models.FreshnessStats, error) {
+	stats := &models.FreshnessStats{
+		Buckets:      AgeBucketLabels(),
+		FirstSeenAge: make(map[models.IOCType][]int64),
+		LastSeenAge:  make(map[models.IOCType][]int64),
+		Expired:      make(map[models.IOCType]int64),
+		Feeds:        []models.FeedFreshness{},
+	}
+
+	query := fmt.Sprintf(`
+		SELECT 'first_seen' AS field, toString(ioc_type), %s AS bucket, count()
+		FROM threat_intel.ioc_store
+		WHERE deprecated = 0
+		GROUP BY ioc_type, bucket
+		UNION ALL
+		SELECT 'last_seen' AS field, toString(ioc_type), %s AS bucket, count()
+		FROM threat_intel.ioc_store
+		WHERE deprecated = 0
+		GROUP BY ioc_type, bucket
+	`, ageBucket("first_seen"), ageBucket("last_seen"))
+
+	rows, err := c.analyticsQuery(ctx, query, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query IOC ages: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var field, iocType string
+		var bucket uint32
+		var count uint64
+		if err := rows.Scan(&field, &iocType, &bucket, &count); err != nil {
+			return nil, err
+		}
+		hist := stats.FirstSeenAge
+		if field == "last_seen" {
+			hist = stats.LastSeenAge
+		}
+		t := models.IOCType(iocType)
+		if hist[t] == nil {
+			hist[t] = make([]int64, ageBuckets)
+		}
+		hist[t][bucket] += int64(count)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = c.analyticsQuery(ctx, `
+		SELECT toString(ioc_type), count()
+		FROM threat_intel.ioc_store
+		WHERE deprecated = 0 AND valid_until < now()
+		GROUP BY ioc_type
+	`, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query expired IOCs: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var iocType string
+		var count uint64
+		if err := rows.Scan(&iocType, &count); err != nil {
+			return nil, err
+		}
+		stats.Expired[models.IOCType(iocType)] = int64(count)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(feedFormats) == 0 || feedLimit <= 0 {
+		return stats, nil
+	}
+
+	rows, err = c.analyticsQuery(ctx, `
+		SELECT f.file_id, f.file_path, f.file_type, f.last_modified,
+		       toInt64(dateDiff('day', f.last_modified, now())), f.ioc_count,
+		       i.newest, i.expired
+		FROM (
+			SELECT file_id, file_path, file_type, last_modified, ioc_count
+			FROM threat_intel.file_registry FINAL
+			WHERE file_type IN (@formats) AND scan_status != 'deleted'
+		) AS f
+		LEFT JOIN (
+			SELECT source_file_id,
+			       toNullable(max(first_seen)) AS newest,
+			       countIf(valid_until < now()) AS expired
+			FROM threat_intel.ioc_store
+			WHERE deprecated = 0
+			GROUP BY source_file_id
+		) AS i ON i.source_file_id = f.file_id
+		ORDER BY f.last_modified ASC
+		LIMIT @limit
+	`, Params{"formats": feedFormats, "limit": feedLimit})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query feed staleness: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var feed models.FeedFreshness
+		if err := rows.Scan(
+			&feed.FileID,
+			&feed.FilePath,
+			&feed.Format,
+			&feed.LastModified,
+			&feed.StaleDays,
+			&feed.IOCCount,
+			&feed.NewestIndicator,
+			&feed.Expired,
+		); err != nil {
+			return nil, err
+		}
+		stats.Feeds = append(stats.Feeds, feed)
+	}
+
+	return stats, rows.Err()
+}
+
+// CountMinIOKeyReferences returns how many current registry entries other
+// than excludeFileIDs point at a MinIO key. Content-addressed objects may
+// only be deleted once this reaches zero.
+func (c *ClickHouseClient) CountMinIOKeyReferences(ctx context.Context, minioKey string, excludeFileIDs []string) (uint64, error) {
+	query, params, err := Select("count()").
+		From("threat_intel.file_registry FINAL").
+		Where("minio_key = @minio_key", Params{"minio_key": minioKey}).
+		WhereIf(len(excludeFileIDs) > 0, "file_id NOT IN (@exclude)", Params{"exclude": excludeFileIDs}).
+		Build()
+	if err != nil {
+		return 0, err
+	}
+
+	var count uint64
+	if err := c.queryRow(ctx, query, params, &count); err != nil {
+		return 0, fmt.Errorf("failed to count MinIO key references: %w", err)
+	}
+	return count, nil
+}
+
+// GetReferencedMinIOKeys returns the set of MinIO keys still referenced by the file registry
+func (c *ClickHouseClient) GetReferencedMinIOKeys(ctx context.Context) (map[string]struct{}, error) {
+	query := `
+		SELECT DISTINCT minio_key
+		FROM threat_intel.file_registry FINAL
+		WHERE minio_key != ''
+	`
+
+	rows, err := c.query(ctx, query, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query MinIO keys: %w", err)
+	}
+	defer rows.Close()
+
+	keys := make(map[string]struct{})
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil, err
+		}
+		keys[key] = struct{}{}
+	}
+
+	return keys, rows.Err()
+}
+
+// ========== Consistency Checks ==========
+
+// orphanedIOCSources selects the sources of active IOCs ingested before
+// @before that have no active registry entry. Sources not shaped like a
+// file ID (imports, submissions, DNS resolution, the denylist, the
+// self-test) are not backed by a file and are left out.
+const orphanedIOCSources = `
+	SELECT DISTINCT source_file_id AS id
+	FROM threat_intel.ioc_store
+	WHERE deprecated = 0 AND ingested_at < @before
+	  AND length(source_file_id) = 64 AND NOT match(source_file_id, '[^0-9a-f]')
+	  AND source_file_id NOT IN (
+	      SELECT file_id FROM threat_intel.file_registry FINAL WHERE scan_status != 'deleted'
+	  )`
+
+// filesMissingIOCs selects infected registry entries processed before
+// @before that have no IOC rows at all
+const filesMissingIOCs = `
+	SELECT file_id AS id
+	FROM threat_intel.file_registry FINAL
+	WHERE scan_status = 'infected' AND ioc_count > 0 AND processed_at < @before
+	  AND file_id NOT IN (SELECT DISTINCT source_file_id FROM threat_intel.ioc_store)`
+
+// ListOrphanedIOCSources returns up to limit file IDs active IOCs were
+// extracted from that the registry no longer holds (or holds as deleted),
+// and how many there are
+func (c *ClickHouseClient) ListOrphanedIOCSources(ctx context.Context, before time.Time, limit int) ([]string, uint64, error) {
+	ids, total, err := c.listIDs(ctx, orphanedIOCSources, before, limit)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list orphaned IOC sources: %w", err)
+	}
+	return ids, total, nil
+}
+
+// ListFilesMissingIOCs returns up to limit IDs of files registered as
+// infected whose IOCs are not in the store, and how many there are
+func (c *ClickHouseClient) ListFilesMissingIOCs(ctx context.Context, before time.Time, limit int) ([]string, uint64, error) {
+	ids, total, err := c.listIDs(ctx, filesMissingIOCs, before, limit)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list files missing IOCs: %w", err)
+	}
+	return ids, total, nil
+}
+
+// listIDs counts the rows of a subquery selecting an id column and returns
+// the first limit of them in order
+func (c *ClickHouseClient) listIDs(ctx context.Context, subquery string, before time.Time, limit int) ([]string, uint64, error) {
+	params := Params{"before": before}
+
+	var total uint64
+	if err := c.queryRow(ctx, `SELECT count() FROM (`+subquery+`)`, params, &total); err != nil {
+		return nil, 0, err
+	}
+	if total == 0 || limit <= 0 {
+		return nil, total, nil
+	}
+
+	params["limit"] = limit
+	rows, err := c.query(ctx, `SELECT id FROM (`+subquery+`) ORDER BY id LIMIT @limit`, params)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, 0, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, total, rows.Err()
 }
@@ -0,0 +1,52 @@
+package db
+
+import (
+	"fmt"
+
+	"tip-server/internal/config"
+)
+
+// Clients are the storage connections shared by the API server, the
+// ingestor and background jobs of one process
+type Clients struct {
+	ClickHouse IOCStore
+	Redis      Cache
+	MinIO      ObjectStore
+	Qdrant     *QdrantClient // Uninitialized unless Qdrant is enabled and reachable
+}
+
+// Connect opens the storage connections, closing those already open when
+// one fails
+func Connect(cfg *config.Config) (*Clients, error) {
+	ch, err := NewClickHouseClient(cfg.ClickHouse)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to ClickHouse: %w", err)
+	}
+
+	redis, err := NewRedisClient(cfg.Redis)
+	if err != nil {
+		ch.Close()
+		return nil, fmt.Errorf("failed to connect to Redis: %w", err)
+	}
+
+	minio, err := NewMinIOClient(cfg.MinIO)
+	if err != nil {
+		ch.Close()
+		redis.Close()
+		return nil, fmt.Errorf("failed to connect to MinIO: %w", err)
+	}
+
+	// Qdrant is optional; similarity search is disabled without it
+	qdrant, _ := NewQdrantClient(cfg.Qdrant)
+
+	return &Clients{ClickHouse: ch, Redis: redis, MinIO: minio, Qdrant: qdrant}, nil
+}
+
+// Close closes the connections
+func (c *Clients) Close() {
+	c.ClickHouse.Close()
+	c.Redis.Close()
+	if c.Qdrant != nil {
+		c.Qdrant.Close()
+	}
+}
@@ -0,0 +1,78 @@
+package db
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Supported object content encodings
+const (
+	EncodingGzip = "gzip"
+	EncodingZstd = "zstd"
+)
+
+// MetaOriginalSize is the user metadata key holding the uncompressed object size
+const MetaOriginalSize = "Original-Size"
+
+// zstdEncoder is shared; EncodeAll is safe for concurrent use
+var zstdEncoder, _ = zstd.NewWriter(nil, zstd.WithEncoderLevel(zstd.SpeedDefault))
+
+// compressBytes compresses content with the given encoding
+func compressBytes(encoding string, content []byte) ([]byte, error) {
+	switch encoding {
+	case EncodingZstd:
+		return zstdEncoder.EncodeAll(content, make([]byte, 0, len(content)/4)), nil
+
+	case EncodingGzip:
+		var buf bytes.Buffer
+		gw := gzip.NewWriter(&buf)
+		if _, err := gw.Write(content); err != nil {
+			return nil, err
+		}
+		if err := gw.Close(); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+
+	default:
+		return nil, fmt.Errorf("unsupported compression %q", encoding)
+	}
+}
+
+// decompressReader wraps r so that reads return decoded content.
+// Unknown or empty encodings are passed through unchanged.
+func decompressReader(encoding string, r io.ReadCloser) (io.ReadCloser, error) {
+	switch encoding {
+	case EncodingZstd:
+		dec, err := zstd.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		return &decodingReader{ReadCloser: dec.IOReadCloser(), source: r}, nil
+
+	case EncodingGzip:
+		gr, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		return &decodingReader{ReadCloser: gr, source: r}, nil
+
+	default:
+		return r, nil
+	}
+}
+
+// decodingReader closes both the decoder and the underlying object stream
+type decodingReader struct {
+	io.ReadCloser
+	source io.Closer
+}
+
+func (d *decodingReader) Close() error {
+	d.ReadCloser.Close()
+	return d.source.Close()
+}
@@ -0,0 +1,74 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// ExportPrefix is the MinIO key prefix of Parquet snapshots of the IOC store
+const ExportPrefix = "exports/ioc_store/"
+
+// exportQuery selects the active corpus in a stable, tool-friendly shape:
+// enums as strings and no self-test rows
+const exportQuery = `
+	SELECT ioc_value, toString(ioc_type) AS ioc_type, source_file_id, malware_family,
+	       confidence, first_seen, last_seen, valid_until, hit_count, tags
+	FROM threat_intel.ioc_store
+	WHERE deprecated = 0 AND source_file_id != 'selftest'
+	ORDER BY ioc_type, ioc_value
+	FORMAT Parquet
+`
+
+// exportMaxExecutionSeconds bounds a snapshot query; exports scan the whole
+// table, so they get far longer than the interactive default
+const exportMaxExecutionSeconds = 3600
+
+// ExportIOCsParquet streams a Parquet snapshot of the active IOC store. The
+// native protocol cannot return raw output formats, so the query goes to the
+// HTTP interface of the first read replica, or of the primary when none is
+// configured. The caller must close the returned reader.
+func (c *ClickHouseClient) ExportIOCsParquet(ctx context.Context) (io.ReadCloser, error) {
+	host := c.cfg.Host
+	if len(c.cfg.ReadReplicas) > 0 {
+		host, _, _ = net.SplitHostPort(c.cfg.ReadReplicas[0])
+	}
+
+	params := url.Values{}
+	params.Set("database", c.cfg.Database)
+	params.Set("max_execution_time", strconv.Itoa(exportMaxExecutionSeconds))
+	params.Set("output_format_parquet_compression_method", "zstd")
+	// Buffer the result server-side so a failure mid-query surfaces as an
+	// HTTP error instead of a truncated file behind a 200
+	params.Set("wait_end_of_query", "1")
+
+	endpoint := url.URL{
+		Scheme:   "http",
+		Host:     net.JoinHostPort(host, strconv.Itoa(c.cfg.HTTPPort)),
+		RawQuery: params.Encode(),
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint.String(), strings.NewReader(exportQuery))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-ClickHouse-User", c.cfg.User)
+	req.Header.Set("X-ClickHouse-Key", c.cfg.Password)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start export: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("export query failed: %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+
+	return resp.Body, nil
+}
@@ -0,0 +1,351 @@
+package db
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/rs/zerolog/log"
+)
+
+// migration is a versioned schema change applied on top of init-db/init.sql.
+// Statements must be idempotent (IF NOT EXISTS) because the API server and
+// ingestor may race to apply the same version.
+type migration struct {
+	Version     uint32
+	Description string
+	Statements  []string
+}
+
+// migrations lists all schema changes in order. Never edit or reorder an
+// applied migration; append a new one instead.
+var migrations = []migration{
+	{
+		Version:     1,
+		Description: "content hash in file registry",
+		Statements: []string{
+			`ALTER TABLE threat_intel.file_registry ADD COLUMN IF NOT EXISTS content_hash String DEFAULT '' AFTER file_size`,
+		},
+	},
+	{
+		Version:     2,
+		Description: "file tombstones and IOC deprecation",
+		Statements: []string{
+			`ALTER TABLE threat_intel.file_registry MODIFY COLUMN scan_status Enum8('pending' = 0, 'clean' = 1, 'infected' = 2, 'misc' = 3, 'failed' = 4, 'deleted' = 5)`,
+			`ALTER TABLE threat_intel.file_registry ADD COLUMN IF NOT EXISTS deleted_at Nullable(DateTime)`,
+			`ALTER TABLE threat_intel.ioc_store ADD COLUMN IF NOT EXISTS deprecated UInt8 DEFAULT 0`,
+		},
+	},
+	{
+		Version:     3,
+		Description: "IOC audit log",
+		Statements: []string{
+			`CREATE TABLE IF NOT EXISTS threat_intel.ioc_audit_log (
+				timestamp DateTime DEFAULT now(),
+				action LowCardinality(String),
+				ioc_value String,
+				actor String,
+				reason String DEFAULT '',
+				client_ip String DEFAULT '',
+				rows_affected UInt64 DEFAULT 0
+			) ENGINE = MergeTree()
+			ORDER BY (timestamp, ioc_value)`,
+		},
+	},
+	{
+		Version:     4,
+		Description: "JA3 IOC type",
+		Statements: []string{
+			`ALTER TABLE threat_intel.ioc_store MODIFY COLUMN ioc_type Enum8(
+				'ipv4' = 1, 'ipv6' = 2, 'domain' = 3, 'url' = 4,
+				'md5' = 5, 'sha1' = 6, 'sha256' = 7, 'email' = 8, 'ja3' = 9
+			)`,
+			// The stats view pinned the old enum; store the type as a string so
+			// future additions don't require rebuilding it again.
+			`DROP VIEW IF EXISTS threat_intel.ioc_stats`,
+			`CREATE MATERIALIZED VIEW IF NOT EXISTS threat_intel.ioc_stats
+			ENGINE = SummingMergeTree()
+			ORDER BY (ioc_type, date)
+			POPULATE
+			AS SELECT
+				toString(ioc_type) AS ioc_type,
+				toDate(first_seen) AS date,
+				count() AS count
+			FROM threat_intel.ioc_store
+			GROUP BY ioc_type, date`,
+		},
+	},
+	{
+		Version:     5,
+		Description: "domain resolution state",
+		Statements: []string{
+			`CREATE TABLE IF NOT EXISTS threat_intel.domain_resolution (
+				domain String,
+				status LowCardinality(String),
+				addresses Array(String),
+				resolved_at DateTime DEFAULT now()
+			) ENGINE = ReplacingMergeTree(resolved_at)
+			ORDER BY domain`,
+		},
+	},
+	{
+		Version:     6,
+		Description: "document language",
+		Statements: []string{
+			`ALTER TABLE threat_intel.file_registry ADD COLUMN IF NOT EXISTS language LowCardinality(String) DEFAULT '' AFTER content_hash`,
+		},
+	},
+	{
+		Version:     7,
+		Description: "executable samples",
+		Statements: []string{
+			`ALTER TABLE threat_intel.ioc_store MODIFY COLUMN ioc_type Enum8(
+				'ipv4' = 1, 'ipv6' = 2, 'domain' = 3, 'url' = 4,
+				'md5' = 5, 'sha1' = 6, 'sha256' = 7, 'email' = 8, 'ja3' = 9,
+				'imphash' = 10
+			)`,
+			`ALTER TABLE threat_intel.file_registry ADD COLUMN IF NOT EXISTS file_type LowCardinality(String) DEFAULT '' AFTER language`,
+		},
+	},
+	{
+		Version:     8,
+		Description: "IOC validity window",
+		Statements: []string{
+			`ALTER TABLE threat_intel.ioc_store ADD COLUMN IF NOT EXISTS valid_until Nullable(DateTime) AFTER last_seen`,
+		},
+	},
+	{
+		Version:     statsViewsVersion,
+		Description: "stats materialized views",
+		Statements: []string{
+			// Distinct (value, source) pairs rather than inserted rows, so
+			// re-ingested files that ReplacingMergeTree later collapses are
+			// not counted twice. Self-test rows are deleted after each run,
+			// which a view cannot follow, so they are never counted.
+			`CREATE MATERIALIZED VIEW IF NOT EXISTS threat_intel.ioc_type_stats
+			ENGINE = AggregatingMergeTree()
+			ORDER BY ioc_type
+			POPULATE
+			AS SELECT
+				toString(ioc_type) AS ioc_type,
+				uniqCombined64State(ioc_value, source_file_id) AS rows
+			FROM threat_intel.ioc_store
+			WHERE source_file_id != 'selftest'
+			GROUP BY ioc_type`,
+			// Latest status of each file, whatever merges have happened
+			`CREATE MATERIALIZED VIEW IF NOT EXISTS threat_intel.file_status_stats
+			ENGINE = AggregatingMergeTree()
+			ORDER BY file_id
+			POPULATE
+			AS SELECT
+				file_id,
+				argMaxState(toString(scan_status), updated_at) AS status
+			FROM threat_intel.file_registry
+			GROUP BY file_id`,
+		},
+	},
+	{
+		Version:     10,
+		Description: "IOC ingestion time",
+		Statements: []string{
+			// Replicas sync by insertion order; first_seen and last_seen can
+			// come from feed metadata and are not monotonic. Existing rows
+			// get the migration time, so replicas pull them once.
+			`ALTER TABLE threat_intel.ioc_store ADD COLUMN IF NOT EXISTS ingested_at DateTime DEFAULT now()`,
+			`ALTER TABLE threat_intel.ioc_store MATERIALIZE COLUMN ingested_at`,
+		},
+	},
+	{
+		Version:     11,
+		Description: "WHOIS relationships",
+		Statements: []string{
+			// Ordered by (relation, value) for "all domains registered with
+			// this email" lookups; a domain keeps every value it was seen with
+			`CREATE TABLE IF NOT EXISTS threat_intel.whois_relationship (
+				domain String,
+				relation LowCardinality(String),
+				value String,
+				observed_at DateTime DEFAULT now()
+			) ENGINE = ReplacingMergeTree(observed_at)
+			ORDER BY (relation, value, domain)`,
+		},
+	},
+	{
+		Version:     12,
+		Description: "certificate IOC types",
+		Statements: []string{
+			`ALTER TABLE threat_intel.ioc_store MODIFY COLUMN ioc_type Enum8(
+				'ipv4' = 1, 'ipv6' = 2, 'domain' = 3, 'url' = 4,
+				'md5' = 5, 'sha1' = 6, 'sha256' = 7, 'email' = 8, 'ja3' = 9,
+				'imphash' = 10, 'cert_sha1' = 11, 'cert_sha256' = 12, 'cert_serial' = 13
+			)`,
+		},
+	},
+	{
+		Version:     13,
+		Description: "mutex and user agent IOC types",
+		Statements: []string{
+			`ALTER TABLE threat_intel.ioc_store MODIFY COLUMN ioc_type Enum8(
+				'ipv4' = 1, 'ipv6' = 2, 'domain' = 3, 'url' = 4,
+				'md5' = 5, 'sha1' = 6, 'sha256' = 7, 'email' = 8, 'ja3' = 9,
+				'imphash' = 10, 'cert_sha1' = 11, 'cert_sha256' = 12, 'cert_serial' = 13,
+				'mutex' = 14, 'user_agent' = 15
+			)`,
+		},
+	},
+	{
+		Version:     14,
+		Description: "ASN and CIDR IOC types",
+		Statements: []string{
+			`ALTER TABLE threat_intel.ioc_store MODIFY COLUMN ioc_type Enum8(
+				'ipv4' = 1, 'ipv6' = 2, 'domain' = 3, 'url' = 4,
+				'md5' = 5, 'sha1' = 6, 'sha256' = 7, 'email' = 8, 'ja3' = 9,
+				'imphash' = 10, 'cert_sha1' = 11, 'cert_sha256' = 12, 'cert_serial' = 13,
+				'mutex' = 14, 'user_agent' = 15, 'asn' = 16, 'cidr' = 17
+			)`,
+		},
+	},
+	{
+		Version:     15,
+		Description: "n-gram skip index for substring search",
+		Statements: []string{
+			// Prefix searches use the primary key; substrings of 3+ characters
+			// can skip granules through trigram Bloom filters
+			`ALTER TABLE threat_intel.ioc_store ADD INDEX IF NOT EXISTS idx_value_ngram ioc_value TYPE ngrambf_v1(3, 65536, 3, 0) GRANULARITY 4`,
+			`ALTER TABLE threat_intel.ioc_store MATERIALIZE INDEX idx_value_ngram`,
+		},
+	},
+	{
+		Version:     16,
+		Description: "DGA score",
+		Statements: []string{
+			// Scored in Go at insert (see dga.Score); rows stored earlier
+			// read 0 until their domain is ingested again
+			`ALTER TABLE threat_intel.ioc_store ADD COLUMN IF NOT EXISTS dga_score UInt8 DEFAULT 0 AFTER confidence`,
+		},
+	},
+	{
+		Version:     17,
+		Description: "file size and extraction time safeguards",
+		Statements: []string{
+			`ALTER TABLE threat_intel.file_registry MODIFY COLUMN scan_status Enum8('pending' = 0, 'clean' = 1, 'infected' = 2, 'misc' = 3, 'failed' = 4, 'deleted' = 5, 'oversized' = 6, 'timeout' = 7)`,
+		},
+	},
+	{
+		Version:     18,
+		Description: "IOC review queue",
+		Statements: []string{
+			// Low-confidence extractions wait for an analyst; /check leaves
+			// them out until approved
+			`ALTER TABLE threat_intel.ioc_store ADD COLUMN IF NOT EXISTS pending UInt8 DEFAULT 0`,
+		},
+	},
+	{
+		Version:     fileHistoryVersion,
+		Description: "file registry history",
+		Statements: []string{
+			// Every registry insert, kept after ReplacingMergeTree collapses
+			// the table to the latest version. Versions merged away before
+			// this migration are lost.
+			`CREATE MATERIALIZED VIEW IF NOT EXISTS threat_intel.file_registry_history
+			ENGINE = MergeTree()
+			ORDER BY (file_id, updated_at)
+			POPULATE
+			AS SELECT
+				file_id, file_path, file_size, content_hash, language, file_type, last_modified,
+				toString(scan_status) AS scan_status, ioc_count, minio_key, error_message,
+				processed_at, updated_at, deleted_at
+			FROM threat_intel.file_registry`,
+			// Latest version of each file, for ad-hoc queries
+			`CREATE VIEW IF NOT EXISTS threat_intel.file_registry_current AS
+			SELECT * FROM threat_intel.file_registry FINAL`,
+		},
+	},
+	{
+		Version:     20,
+		Description: "email redaction",
+		Statements: []string{
+			// Encrypted original of a redacted email address, for
+			// POST /admin/reveal; empty for every other row
+			`ALTER TABLE threat_intel.ioc_store ADD COLUMN IF NOT EXISTS sealed_value String DEFAULT ''`,
+		},
+	},
+	{
+		Version:     21,
+		Description: "extraction samples",
+		Statements: []string{
+			// Extracted IOCs kept with their context for labeling; a label
+			// is a newer version of the sample's row
+			`CREATE TABLE IF NOT EXISTS threat_intel.extraction_samples (
+				sample_id String,
+				ioc_value String,
+				ioc_type LowCardinality(String),
+				source_file_id String,
+				extractor_version LowCardinality(String),
+				match_offset Int64,
+				snippet String,
+				sampled_at DateTime,
+				label LowCardinality(String) DEFAULT '',
+				labeled_by String DEFAULT '',
+				labeled_at DateTime64(3) DEFAULT toDateTime64(0, 3)
+			) ENGINE = ReplacingMergeTree(labeled_at)
+			ORDER BY sample_id`,
+		},
+	},
+}
+
+// statsViewsVersion is the migration creating the views GetIOCStats and
+// GetFileStats read from; older schemas fall back to scanning the tables
+const statsViewsVersion = 9
+
+// fileHistoryVersion is the migration creating the view GetFileHistory reads
+// from; older schemas fall back to the unmerged registry rows
+const fileHistoryVersion = 19
+
+// Migrate applies all pending schema migrations
+func (c *ClickHouseClient) Migrate(ctx context.Context) error {
+	err := c.conn.Exec(ctx, `
+		CREATE TABLE IF NOT EXISTS threat_intel.schema_migrations (
+			version UInt32,
+			description String,
+			applied_at DateTime DEFAULT now()
+		) ENGINE = ReplacingMergeTree(applied_at)
+		ORDER BY version
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create schema_migrations table: %w", err)
+	}
+
+	var current uint32
+	row := c.conn.QueryRow(ctx, `SELECT max(version) FROM threat_intel.schema_migrations`)
+	if err := row.Scan(&current); err != nil {
+		return fmt.Errorf("failed to read schema version: %w", err)
+	}
+	defer func() { c.schemaVersion.Store(current) }()
+
+	for _, m := range migrations {
+		if m.Version <= current {
+			continue
+		}
+
+		for _, stmt := range m.Statements {
+			if err := c.conn.Exec(ctx, stmt); err != nil {
+				return fmt.Errorf("migration %d (%s) failed: %w", m.Version, m.Description, err)
+			}
+		}
+
+		if err := c.exec(ctx,
+			`INSERT INTO threat_intel.schema_migrations (version, description) VALUES (@version, @description)`,
+			Params{"version": m.Version, "description": m.Description},
+		); err != nil {
+			return fmt.Errorf("failed to record migration %d: %w", m.Version, err)
+		}
+		current = m.Version
+
+		log.Info().
+			Uint32("version", m.Version).
+			Str("description", m.Description).
+			Msg("Applied schema migration")
+	}
+
+	return nil
+}
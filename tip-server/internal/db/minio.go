@@ -3,12 +3,16 @@ package db
 import (
 	"bytes"
 	"context"
+	"encoding/base64"
 	"fmt"
 	"io"
+	"strconv"
 	"time"
 
 	"github.com/minio/minio-go/v7"
 	"github.com/minio/minio-go/v7/pkg/credentials"
+	"github.com/minio/minio-go/v7/pkg/encrypt"
+	"github.com/minio/minio-go/v7/pkg/lifecycle"
 	"github.com/rs/zerolog/log"
 
 	"tip-server/internal/config"
@@ -18,10 +22,22 @@ import (
 type MinIOClient struct {
 	client *minio.Client
 	cfg    config.MinIOConfig
+	sse    encrypt.ServerSide // nil when server-side encryption is disabled
 }
 
 // NewMinIOClient creates a new MinIO client
 func NewMinIOClient(cfg config.MinIOConfig) (*MinIOClient, error) {
+	sse, err := newServerSideEncryption(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	switch cfg.Compression {
+	case "", EncodingGzip, EncodingZstd:
+	default:
+		return nil, fmt.Errorf("unsupported MINIO_COMPRESSION %q", cfg.Compression)
+	}
+
 	client, err := minio.New(cfg.Endpoint, &minio.Options{
 		Creds:  credentials.NewStaticV4(cfg.AccessKey, cfg.SecretKey, ""),
 		Secure: cfg.UseSSL,
@@ -33,26 +49,153 @@ func NewMinIOClient(cfg config.MinIOConfig) (*MinIOClient, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	// Check if bucket exists, create if not
-	exists, err := client.BucketExists(ctx, cfg.Bucket)
-	if err != nil {
-		return nil, fmt.Errorf("failed to check bucket: %w", err)
+	// Check if buckets exist, create if not
+	if err := ensureBucket(ctx, client, cfg.Bucket); err != nil {
+		return nil, err
 	}
-
-	if !exists {
-		err = client.MakeBucket(ctx, cfg.Bucket, minio.MakeBucketOptions{})
-		if err != nil {
-			return nil, fmt.Errorf("failed to create bucket: %w", err)
+	if cfg.QuarantineBucket != "" {
+		// Created private; no lifecycle rules, so samples are kept until
+		// removed explicitly
+		if err := ensureBucket(ctx, client, cfg.QuarantineBucket); err != nil {
+			return nil, err
 		}
-		log.Info().Str("bucket", cfg.Bucket).Msg("Created MinIO bucket")
 	}
 
 	log.Info().
 		Str("endpoint", cfg.Endpoint).
 		Str("bucket", cfg.Bucket).
+		Str("quarantine_bucket", cfg.QuarantineBucket).
+		Str("sse", cfg.SSEType).
 		Msg("Connected to MinIO")
 
-	return &MinIOClient{client: client, cfg: cfg}, nil
+	m := &MinIOClient{client: client, cfg: cfg, sse: sse}
+
+	if err := m.applyLifecycle(ctx); err != nil {
+		log.Warn().Err(err).Str("bucket", cfg.Bucket).Msg("Failed to apply bucket lifecycle rules")
+	}
+
+	return m, nil
+}
+
+// ensureBucket creates a bucket if it does not exist
+func ensureBucket(ctx context.Context, client *minio.Client, bucket string) error {
+	exists, err := client.BucketExists(ctx, bucket)
+	if err != nil {
+		return fmt.Errorf("failed to check bucket: %w", err)
+	}
+	if exists {
+		return nil
+	}
+
+	if err := client.MakeBucket(ctx, bucket, minio.MakeBucketOptions{}); err != nil {
+		return fmt.Errorf("failed to create bucket: %w", err)
+	}
+	log.Info().Str("bucket", bucket).Msg("Created MinIO bucket")
+	return nil
+}
+
+// applyLifecycle installs the configured expiration/transition rules on the bucket
+func (m *MinIOClient) applyLifecycle(ctx context.Context) error {
+	if m.cfg.ExpirationDays <= 0 && m.cfg.TransitionDays <= 0 {
+		return nil
+	}
+
+	rule := lifecycle.Rule{
+		ID:     "tip-lifecycle",
+		Status: "Enabled",
+	}
+	if m.cfg.ExpirationDays > 0 {
+		rule.Expiration = lifecycle.Expiration{Days: lifecycle.ExpirationDays(m.cfg.ExpirationDays)}
+	}
+	if m.cfg.TransitionDays > 0 {
+		if m.cfg.TransitionStorageClass == "" {
+			return fmt.Errorf("MINIO_TRANSITION_STORAGE_CLASS is required when MINIO_TRANSITION_DAYS is set")
+		}
+		rule.Transition = lifecycle.Transition{
+			Days:         lifecycle.ExpirationDays(m.cfg.TransitionDays),
+			StorageClass: m.cfg.TransitionStorageClass,
+		}
+	}
+
+	lc := lifecycle.NewConfiguration()
+	lc.Rules = []lifecycle.Rule{rule}
+
+	if err := m.client.SetBucketLifecycle(ctx, m.cfg.Bucket, lc); err != nil {
+		return err
+	}
+
+	log.Info().
+		Str("bucket", m.cfg.Bucket).
+		Int("expiration_days", m.cfg.ExpirationDays).
+		Int("transition_days", m.cfg.TransitionDays).
+		Str("storage_class", m.cfg.TransitionStorageClass).
+		Msg("Applied bucket lifecycle rules")
+
+	return nil
+}
+
+// newServerSideEncryption builds the SSE configuration applied to every object operation
+func newServerSideEncryption(cfg config.MinIOConfig) (encrypt.ServerSide, error) {
+	switch cfg.SSEType {
+	case "":
+		if cfg.RequireEncryption {
+			return nil, fmt.Errorf("MinIO encryption is required but MINIO_SSE_TYPE is not set")
+		}
+		return nil, nil
+
+	case "sse-s3":
+		return encrypt.NewSSE(), nil
+
+	case "sse-kms":
+		if cfg.SSEKMSKeyID == "" {
+			return nil, fmt.Errorf("MINIO_SSE_KMS_KEY_ID is required for sse-kms")
+		}
+		sse, err := encrypt.NewSSEKMS(cfg.SSEKMSKeyID, nil)
+		if err != nil {
+			return nil, fmt.Errorf("invalid SSE-KMS configuration: %w", err)
+		}
+		return sse, nil
+
+	case "sse-c":
+		key, err := base64.StdEncoding.DecodeString(cfg.SSECKey)
+		if err != nil {
+			return nil, fmt.Errorf("MINIO_SSE_C_KEY must be base64-encoded: %w", err)
+		}
+		sse, err := encrypt.NewSSEC(key)
+		if err != nil {
+			return nil, fmt.Errorf("invalid SSE-C key: %w", err)
+		}
+		return sse, nil
+
+	default:
+		return nil, fmt.Errorf("unsupported MINIO_SSE_TYPE %q", cfg.SSEType)
+	}
+}
+
+// putOptions returns upload options with encryption applied
+func (m *MinIOClient) putOptions(contentType string) minio.PutObjectOptions {
+	return minio.PutObjectOptions{
+		ContentType:          contentType,
+		ServerSideEncryption: m.sse,
+	}
+}
+
+// getOptions returns download options with encryption applied.
+// Only SSE-C needs the key on reads; minio-go ignores other SSE types here.
+func (m *MinIOClient) getOptions() minio.GetObjectOptions {
+	return minio.GetObjectOptions{ServerSideEncryption: m.sse}
+}
+
+// Ping checks that MinIO is reachable and the configured bucket exists
+func (m *MinIOClient) Ping(ctx context.Context) error {
+	exists, err := m.client.BucketExists(ctx, m.cfg.Bucket)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("bucket %q does not exist", m.cfg.Bucket)
+	}
+	return nil
 }
 
 // Client returns the underlying MinIO client
@@ -65,13 +208,20 @@ func (m *MinIOClient) Bucket() string {
 	return m.cfg.Bucket
 }
 
+// bucketFor returns the bucket an object is stored in: the quarantine
+// bucket for samples when one is configured, the main bucket otherwise
+func (m *MinIOClient) bucketFor(objectName string) string {
+	if m.cfg.QuarantineBucket != "" && IsQuarantineKey(objectName) {
+		return m.cfg.QuarantineBucket
+	}
+	return m.cfg.Bucket
+}
+
 // ========== Object Operations ==========
 
 // UploadFile uploads a file to MinIO
 func (m *MinIOClient) UploadFile(ctx context.Context, objectName string, filePath string, contentType string) (*minio.UploadInfo, error) {
-	info, err := m.client.FPutObject(ctx, m.cfg.Bucket, objectName, filePath, minio.PutObjectOptions{
-		ContentType: contentType,
-	})
+	info, err := m.client.FPutObject(ctx, m.cfg.Bucket, objectName, filePath, m.putOptions(contentType))
 	if err != nil {
 		return nil, fmt.Errorf("failed to upload file: %w", err)
 	}
@@ -84,13 +234,29 @@ func (m *MinIOClient) UploadFile(ctx context.Context, objectName string, filePat
 	return &info, nil
 }
 
-// UploadBytes uploads byte content to MinIO
+// UploadBytes uploads byte content to MinIO, compressing it when configured.
+// Compressed objects carry a Content-Encoding marker and their original size;
+// every object carries the SHA-256 of its uncompressed content.
 func (m *MinIOClient) UploadBytes(ctx context.Context, objectName string, content []byte, contentType string) (*minio.UploadInfo, error) {
+	opts := m.putOptions(contentType)
+	opts.UserMetadata = map[string]string{MetaContentSHA256: ContentHash(content)}
+
+	if m.cfg.Compression != "" && len(content) >= m.cfg.CompressionMinSize {
+		compressed, err := compressBytes(m.cfg.Compression, content)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compress content: %w", err)
+		}
+		// Only keep the compressed form when it actually saves space
+		if len(compressed) < len(content) {
+			opts.ContentEncoding = m.cfg.Compression
+			opts.UserMetadata[MetaOriginalSize] = strconv.Itoa(len(content))
+			content = compressed
+		}
+	}
+
 	reader := bytes.NewReader(content)
 
-	info, err := m.client.PutObject(ctx, m.cfg.Bucket, objectName, reader, int64(len(content)), minio.PutObjectOptions{
-		ContentType: contentType,
-	})
+	info, err := m.client.PutObject(ctx, m.cfg.Bucket, objectName, reader, int64(len(content)), opts)
 	if err != nil {
 		return nil, fmt.Errorf("failed to upload bytes: %w", err)
 	}
@@ -103,11 +269,39 @@ func (m *MinIOClient) UploadBytes(ctx context.Context, objectName string, conten
 	return &info, nil
 }
 
+// MetaContentSHA256 is the user metadata key holding the hex SHA-256 of the
+// uncompressed content, checked when the object is served
+const MetaContentSHA256 = "Content-Sha256"
+
+// MetaQuarantine is the user metadata key marking an object as a malware sample
+const MetaQuarantine = "Quarantine"
+
+// UploadQuarantined stores a malware sample as opaque bytes: never
+// compressed, typed application/octet-stream and marked with MetaQuarantine
+// so it is not mistaken for scannable content
+func (m *MinIOClient) UploadQuarantined(ctx context.Context, objectName string, content []byte) (*minio.UploadInfo, error) {
+	opts := m.putOptions("application/octet-stream")
+	opts.UserMetadata = map[string]string{
+		MetaQuarantine:    "true",
+		MetaContentSHA256: ContentHash(content),
+	}
+
+	info, err := m.client.PutObject(ctx, m.bucketFor(objectName), objectName, bytes.NewReader(content), int64(len(content)), opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to upload sample: %w", err)
+	}
+
+	log.Debug().
+		Str("object", objectName).
+		Int64("size", info.Size).
+		Msg("Quarantined sample in MinIO")
+
+	return &info, nil
+}
+
 // UploadReader uploads from an io.Reader to MinIO
 func (m *MinIOClient) UploadReader(ctx context.Context, objectName string, reader io.Reader, size int64, contentType string) (*minio.UploadInfo, error) {
-	info, err := m.client.PutObject(ctx, m.cfg.Bucket, objectName, reader, size, minio.PutObjectOptions{
-		ContentType: contentType,
-	})
+	info, err := m.client.PutObject(ctx, m.cfg.Bucket, objectName, reader, size, m.putOptions(contentType))
 	if err != nil {
 		return nil, fmt.Errorf("failed to upload from reader: %w", err)
 	}
@@ -115,9 +309,28 @@ func (m *MinIOClient) UploadReader(ctx context.Context, objectName string, reade
 	return &info, nil
 }
 
+// streamPartSize is the multipart chunk used for uploads of unknown size;
+// the client default for those is sized for 5 TiB objects and buffers
+// hundreds of megabytes per part
+const streamPartSize = 16 << 20
+
+// UploadStream uploads a reader of unknown length, such as a query result,
+// as a multipart object. Content is stored as is, without compression.
+func (m *MinIOClient) UploadStream(ctx context.Context, objectName string, reader io.Reader, contentType string) (*minio.UploadInfo, error) {
+	opts := m.putOptions(contentType)
+	opts.PartSize = streamPartSize
+
+	info, err := m.client.PutObject(ctx, m.cfg.Bucket, objectName, reader, -1, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to upload stream: %w", err)
+	}
+
+	return &info, nil
+}
+
 // DownloadFile downloads a file from MinIO to local path
 func (m *MinIOClient) DownloadFile(ctx context.Context, objectName string, filePath string) error {
-	err := m.client.FGetObject(ctx, m.cfg.Bucket, objectName, filePath, minio.GetObjectOptions{})
+	err := m.client.FGetObject(ctx, m.bucketFor(objectName), objectName, filePath, m.getOptions())
 	if err != nil {
 		return fmt.Errorf("failed to download file: %w", err)
 	}
@@ -132,7 +345,7 @@ func (m *MinIOClient) DownloadFile(ctx context.Context, objectName string, fileP
 
 // GetObject retrieves an object as an io.ReadCloser
 func (m *MinIOClient) GetObject(ctx context.Context, objectName string) (*minio.Object, error) {
-	obj, err := m.client.GetObject(ctx, m.cfg.Bucket, objectName, minio.GetObjectOptions{})
+	obj, err := m.client.GetObject(ctx, m.bucketFor(objectName), objectName, m.getOptions())
 	if err != nil {
 		return nil, fmt.Errorf("failed to get object: %w", err)
 	}
@@ -140,9 +353,43 @@ func (m *MinIOClient) GetObject(ctx context.Context, objectName string) (*minio.
 	return obj, nil
 }
 
+// OpenObject opens an object for reading, transparently decompressing content
+// stored with a Content-Encoding marker. The returned size is the decoded size,
+// or -1 when it is unknown.
+func (m *MinIOClient) OpenObject(ctx context.Context, objectName string) (io.ReadCloser, minio.ObjectInfo, int64, error) {
+	obj, err := m.GetObject(ctx, objectName)
+	if err != nil {
+		return nil, minio.ObjectInfo{}, 0, err
+	}
+
+	info, err := obj.Stat()
+	if err != nil {
+		obj.Close()
+		return nil, minio.ObjectInfo{}, 0, fmt.Errorf("failed to stat object: %w", err)
+	}
+
+	encoding := info.Metadata.Get("Content-Encoding")
+	if encoding == "" {
+		return obj, info, info.Size, nil
+	}
+
+	reader, err := decompressReader(encoding, obj)
+	if err != nil {
+		obj.Close()
+		return nil, minio.ObjectInfo{}, 0, fmt.Errorf("failed to decode object: %w", err)
+	}
+
+	size := int64(-1)
+	if v, err := strconv.ParseInt(info.UserMetadata[MetaOriginalSize], 10, 64); err == nil {
+		size = v
+	}
+
+	return reader, info, size, nil
+}
+
 // GetObjectInfo retrieves object metadata without downloading content
 func (m *MinIOClient) GetObjectInfo(ctx context.Context, objectName string) (minio.ObjectInfo, error) {
-	info, err := m.client.StatObject(ctx, m.cfg.Bucket, objectName, minio.StatObjectOptions{})
+	info, err := m.client.StatObject(ctx, m.bucketFor(objectName), objectName, m.getOptions())
 	if err != nil {
 		return minio.ObjectInfo{}, fmt.Errorf("failed to get object info: %w", err)
 	}
@@ -152,7 +399,7 @@ func (m *MinIOClient) GetObjectInfo(ctx context.Context, objectName string) (min
 
 // DeleteObject deletes an object from MinIO
 func (m *MinIOClient) DeleteObject(ctx context.Context, objectName string) error {
-	err := m.client.RemoveObject(ctx, m.cfg.Bucket, objectName, minio.RemoveObjectOptions{})
+	err := m.client.RemoveObject(ctx, m.bucketFor(objectName), objectName, minio.RemoveObjectOptions{})
 	if err != nil {
 		return fmt.Errorf("failed to delete object: %w", err)
 	}
@@ -166,7 +413,7 @@ func (m *MinIOClient) DeleteObject(ctx context.Context, objectName string) error
 
 // ObjectExists checks if an object exists
 func (m *MinIOClient) ObjectExists(ctx context.Context, objectName string) (bool, error) {
-	_, err := m.client.StatObject(ctx, m.cfg.Bucket, objectName, minio.StatObjectOptions{})
+	_, err := m.client.StatObject(ctx, m.bucketFor(objectName), objectName, m.getOptions())
 	if err != nil {
 		errResp := minio.ToErrorResponse(err)
 		if errResp.Code == "NoSuchKey" {
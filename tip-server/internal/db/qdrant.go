@@ -3,6 +3,8 @@ package db
 import (
 	"context"
 	"fmt"
+	"hash/fnv"
+	"strconv"
 
 	pb "github.com/qdrant/go-client/qdrant"
 	"github.com/rs/zerolog/log"
@@ -12,18 +14,23 @@ import (
 	"tip-server/internal/config"
 )
 
-// QdrantClient wraps the Qdrant gRPC connection
-// This is a stub for Phase 2 implementation
+// QdrantClient wraps the Qdrant gRPC connection used for similarity search
 type QdrantClient struct {
-	conn           *grpc.ClientConn
-	pointsClient   pb.PointsClient
+	conn              *grpc.ClientConn
+	pointsClient      pb.PointsClient
 	collectionsClient pb.CollectionsClient
-	cfg            config.QdrantConfig
-	initialized    bool
+	cfg               config.QdrantConfig
+	initialized       bool
 }
 
-// NewQdrantClient creates a new Qdrant client (Phase 2 stub)
+// NewQdrantClient creates a new Qdrant client. When Qdrant is disabled or the
+// connection cannot be set up, an uninitialized client is returned so callers
+// can continue without vector search.
 func NewQdrantClient(cfg config.QdrantConfig) (*QdrantClient, error) {
+	if !cfg.Enabled {
+		return &QdrantClient{cfg: cfg}, nil
+	}
+
 	addr := fmt.Sprintf("%s:%d", cfg.Host, cfg.GRPCPort)
 
 	conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
@@ -31,7 +38,7 @@ func NewQdrantClient(cfg config.QdrantConfig) (*QdrantClient, error) {
 		log.Warn().
 			Err(err).
 			Str("addr", addr).
-			Msg("Failed to connect to Qdrant (Phase 2 feature) - continuing without vector search")
+			Msg("Failed to connect to Qdrant - continuing without vector search")
 		return &QdrantClient{cfg: cfg, initialized: false}, nil
 	}
 
@@ -46,7 +53,7 @@ func NewQdrantClient(cfg config.QdrantConfig) (*QdrantClient, error) {
 	log.Info().
 		Str("host", cfg.Host).
 		Int("port", cfg.GRPCPort).
-		Msg("Connected to Qdrant (Phase 2 ready)")
+		Msg("Connected to Qdrant")
 
 	return client, nil
 }
@@ -64,75 +71,230 @@ func (q *QdrantClient) IsInitialized() bool {
 	return q.initialized
 }
 
-// ========== Phase 2 Stub Methods ==========
-// These methods are placeholders for future vector search implementation
+// Collection returns the configured collection name
+func (q *QdrantClient) Collection() string {
+	return q.cfg.Collection
+}
+
+// EnsureCollection creates the collection if it does not exist, and fails if
+// an existing collection was created with a different vector size
+func (q *QdrantClient) EnsureCollection(ctx context.Context, name string, vectorSize uint64) error {
+	if !q.initialized {
+		return fmt.Errorf("qdrant client not initialized")
+	}
+
+	exists, err := q.collectionsClient.CollectionExists(ctx, &pb.CollectionExistsRequest{CollectionName: name})
+	if err != nil {
+		return fmt.Errorf("failed to check collection %s: %w", name, err)
+	}
+	if !exists.GetResult().GetExists() {
+		return q.CreateCollection(ctx, name, vectorSize)
+	}
+
+	info, err := q.collectionsClient.Get(ctx, &pb.GetCollectionInfoRequest{CollectionName: name})
+	if err != nil {
+		return fmt.Errorf("failed to get collection %s: %w", name, err)
+	}
+	size := info.GetResult().GetConfig().GetParams().GetVectorsConfig().GetParams().GetSize()
+	if size != vectorSize {
+		return fmt.Errorf("collection %s has vector size %d, embedder produces %d", name, size, vectorSize)
+	}
+
+	return nil
+}
 
-// CreateCollection creates a new vector collection (Phase 2)
+// CreateCollection creates a new vector collection using cosine distance
 func (q *QdrantClient) CreateCollection(ctx context.Context, name string, vectorSize uint64) error {
 	if !q.initialized {
 		return fmt.Errorf("qdrant client not initialized")
 	}
 
-	// TODO: Phase 2 implementation
-	// _, err := q.collectionsClient.Create(ctx, &pb.CreateCollection{
-	// 	CollectionName: name,
-	// 	VectorsConfig: &pb.VectorsConfig{
-	// 		Config: &pb.VectorsConfig_Params{
-	// 			Params: &pb.VectorParams{
-	// 				Size:     vectorSize,
-	// 				Distance: pb.Distance_Cosine,
-	// 			},
-	// 		},
-	// 	},
-	// })
-
-	log.Debug().Str("collection", name).Msg("CreateCollection called (Phase 2 stub)")
+	_, err := q.collectionsClient.Create(ctx, &pb.CreateCollection{
+		CollectionName: name,
+		VectorsConfig: pb.NewVectorsConfig(&pb.VectorParams{
+			Size:     vectorSize,
+			Distance: pb.Distance_Cosine,
+		}),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create collection %s: %w", name, err)
+	}
+
+	log.Info().Str("collection", name).Uint64("size", vectorSize).Msg("Created Qdrant collection")
 	return nil
 }
 
-// UpsertVectors upserts vectors into a collection (Phase 2)
+// UpsertVectors upserts vectors with their payloads into a collection
 func (q *QdrantClient) UpsertVectors(ctx context.Context, collection string, ids []uint64, vectors [][]float32, payloads []map[string]interface{}) error {
 	if !q.initialized {
 		return fmt.Errorf("qdrant client not initialized")
 	}
+	if len(ids) != len(vectors) || len(ids) != len(payloads) {
+		return fmt.Errorf("upsert: %d ids, %d vectors, %d payloads", len(ids), len(vectors), len(payloads))
+	}
 
-	// TODO: Phase 2 implementation
-	// Implement vector upsert logic using q.pointsClient.Upsert()
+	points := make([]*pb.PointStruct, len(ids))
+	for i, id := range ids {
+		payload, err := pb.TryValueMap(payloads[i])
+		if err != nil {
+			return fmt.Errorf("invalid payload for point %d: %w", id, err)
+		}
+		points[i] = &pb.PointStruct{
+			Id:      pb.NewIDNum(id),
+			Vectors: pb.NewVectorsDense(vectors[i]),
+			Payload: payload,
+		}
+	}
 
-	log.Debug().
-		Str("collection", collection).
-		Int("count", len(ids)).
-		Msg("UpsertVectors called (Phase 2 stub)")
+	wait := true
+	if _, err := q.pointsClient.Upsert(ctx, &pb.UpsertPoints{
+		CollectionName: collection,
+		Wait:           &wait,
+		Points:         points,
+	}); err != nil {
+		return fmt.Errorf("failed to upsert vectors: %w", err)
+	}
 
 	return nil
 }
 
-// SearchSimilar searches for similar vectors (Phase 2)
-func (q *QdrantClient) SearchSimilar(ctx context.Context, collection string, vector []float32, limit uint64) ([]VectorSearchResult, error) {
+// SearchSimilar returns up to limit points closest to vector, ignoring those
+// scoring below minScore
+func (q *QdrantClient) SearchSimilar(ctx context.Context, collection string, vector []float32, limit uint64, minScore float32) ([]VectorSearchResult, error) {
 	if !q.initialized {
 		return nil, fmt.Errorf("qdrant client not initialized")
 	}
 
-	// TODO: Phase 2 implementation
-	// Implement vector search using q.pointsClient.Search()
+	req := &pb.SearchPoints{
+		CollectionName: collection,
+		Vector:         vector,
+		Limit:          limit,
+		WithPayload:    pb.NewWithPayload(true),
+	}
+	if minScore > 0 {
+		req.ScoreThreshold = &minScore
+	}
+
+	resp, err := q.pointsClient.Search(ctx, req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search vectors: %w", err)
+	}
 
-	log.Debug().
-		Str("collection", collection).
-		Uint64("limit", limit).
-		Msg("SearchSimilar called (Phase 2 stub)")
+	results := make([]VectorSearchResult, 0, len(resp.GetResult()))
+	for _, p := range resp.GetResult() {
+		payload := make(map[string]interface{}, len(p.GetPayload()))
+		for k, v := range p.GetPayload() {
+			payload[k] = valueToInterface(v)
+		}
+		results = append(results, VectorSearchResult{
+			ID:      p.GetId().GetNum(),
+			Score:   p.GetScore(),
+			Payload: payload,
+		})
+	}
 
-	return nil, nil
+	return results, nil
 }
 
-// VectorSearchResult represents a search result from Qdrant (Phase 2)
+// VectorSearchResult represents a search result from Qdrant
 type VectorSearchResult struct {
 	ID      uint64                 `json:"id"`
 	Score   float32                `json:"score"`
 	Payload map[string]interface{} `json:"payload"`
 }
 
-// ========== Future Phase 2 Features ==========
-// - Domain embedding for fuzzy domain matching
-// - Text embedding for ransom note / threat report matching
-// - Similar IOC detection based on context
-// - Malware family clustering
+// VectorPoint is a stored point with its vector
+type VectorPoint struct {
+	ID      uint64
+	Vector  []float32
+	Payload map[string]interface{}
+}
+
+// scrollPageSize is the number of points fetched per scroll request
+const scrollPageSize = 256
+
+// ScrollVectors returns up to limit points of a collection with their
+// vectors and payloads, in point ID order
+func (q *QdrantClient) ScrollVectors(ctx context.Context, collection string, limit int) ([]VectorPoint, error) {
+	if !q.initialized {
+		return nil, fmt.Errorf("qdrant client not initialized")
+	}
+
+	var (
+		points []VectorPoint
+		offset *pb.PointId
+	)
+	for len(points) < limit {
+		page := uint32(min(scrollPageSize, limit-len(points)))
+		resp, err := q.pointsClient.Scroll(ctx, &pb.ScrollPoints{
+			CollectionName: collection,
+			Offset:         offset,
+			Limit:          &page,
+			WithPayload:    pb.NewWithPayload(true),
+			WithVectors:    pb.NewWithVectors(true),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to scroll vectors: %w", err)
+		}
+
+		for _, p := range resp.GetResult() {
+			payload := make(map[string]interface{}, len(p.GetPayload()))
+			for k, v := range p.GetPayload() {
+				payload[k] = valueToInterface(v)
+			}
+			points = append(points, VectorPoint{
+				ID:      p.GetId().GetNum(),
+				Vector:  p.GetVectors().GetVector().GetData(),
+				Payload: payload,
+			})
+		}
+
+		offset = resp.GetNextPageOffset()
+		if offset == nil {
+			break
+		}
+	}
+
+	return points, nil
+}
+
+// VectorPointID derives a stable numeric point ID from a file ID, so that
+// re-indexing a file replaces its previous vector
+func VectorPointID(fileID string) uint64 {
+	if len(fileID) >= 16 {
+		if id, err := strconv.ParseUint(fileID[:16], 16, 64); err == nil {
+			return id
+		}
+	}
+	h := fnv.New64a()
+	h.Write([]byte(fileID))
+	return h.Sum64()
+}
+
+// valueToInterface converts a Qdrant payload value to its Go equivalent
+func valueToInterface(v *pb.Value) interface{} {
+	switch kind := v.GetKind().(type) {
+	case *pb.Value_StringValue:
+		return kind.StringValue
+	case *pb.Value_IntegerValue:
+		return kind.IntegerValue
+	case *pb.Value_DoubleValue:
+		return kind.DoubleValue
+	case *pb.Value_BoolValue:
+		return kind.BoolValue
+	case *pb.Value_ListValue:
+		list := make([]interface{}, len(kind.ListValue.GetValues()))
+		for i, item := range kind.ListValue.GetValues() {
+			list[i] = valueToInterface(item)
+		}
+		return list
+	case *pb.Value_StructValue:
+		m := make(map[string]interface{}, len(kind.StructValue.GetFields()))
+		for k, item := range kind.StructValue.GetFields() {
+			m[k] = valueToInterface(item)
+		}
+		return m
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,251 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+)
+
+// Params binds values to the named parameters (@name) of a statement. Values
+// are always bound by the driver, never formatted into the SQL text.
+type Params map[string]any
+
+// paramPattern matches named parameters the same way the driver does
+var paramPattern = regexp.MustCompile(`@[a-zA-Z0-9_]+`)
+
+// placeholders are the named parameters a SQL text uses. ClickHouse has no
+// server-side prepared statements for queries; the text is sent with every
+// execution and only this scan of it is saved.
+type placeholders struct {
+	params []string // Parameter names, without the leading @
+}
+
+// placeholderCache holds the placeholders of each SQL text seen, by text
+var placeholderCache sync.Map // string -> *placeholders
+
+// prepare returns the placeholders of a SQL text, scanning it only the first
+// time it is seen
+func prepare(sql string) *placeholders {
+	if p, ok := placeholderCache.Load(sql); ok {
+		return p.(*placeholders)
+	}
+
+	p := &placeholders{}
+	for _, m := range paramPattern.FindAllString(sql, -1) {
+		if name := m[1:]; !slices.Contains(p.params, name) {
+			p.params = append(p.params, name)
+		}
+	}
+
+	actual, _ := placeholderCache.LoadOrStore(sql, p)
+	return actual.(*placeholders)
+}
+
+// bind checks that params covers exactly the text's placeholders and returns
+// them as driver arguments. Unused values are rejected too, since they
+// almost always mean a misspelt placeholder.
+func (s *placeholders) bind(params Params) ([]any, error) {
+	args := make([]any, 0, len(s.params))
+	for _, name := range s.params {
+		v, ok := params[name]
+		if !ok {
+			return nil, fmt.Errorf("missing value for query parameter @%s", name)
+		}
+		args = append(args, clickhouse.Named(name, v))
+	}
+	if len(params) != len(s.params) {
+		for name := range params {
+			if !slices.Contains(s.params, name) {
+				return nil, fmt.Errorf("query has no parameter @%s", name)
+			}
+		}
+	}
+	return args, nil
+}
+
+// query runs a SELECT with named parameters
+func (c *ClickHouseClient) query(ctx context.Context, sql string, params Params) (driver.Rows, error) {
+	args, err := prepare(sql).bind(params)
+	if err != nil {
+		return nil, err
+	}
+	return c.conn.Query(ctx, sql, args...)
+}
+
+// analyticsQuery runs a SELECT with named parameters on the analytics
+// connection, for scans and reports that tolerate replica lag
+func (c *ClickHouseClient) analyticsQuery(ctx context.Context, sql string, params Params) (driver.Rows, error) {
+	args, err := prepare(sql).bind(params)
+	if err != nil {
+		return nil, err
+	}
+	return c.analytics.Query(ctx, sql, args...)
+}
+
+// queryRow runs a single-row SELECT with named parameters and scans the
+// result into dest
+func (c *ClickHouseClient) queryRow(ctx context.Context, sql string, params Params, dest ...any) error {
+	args, err := prepare(sql).bind(params)
+	if err != nil {
+		return err
+	}
+	return c.conn.QueryRow(ctx, sql, args...).Scan(dest...)
+}
+
+// exec runs a statement with named parameters
+func (c *ClickHouseClient) exec(ctx context.Context, sql string, params Params) error {
+	args, err := prepare(sql).bind(params)
+	if err != nil {
+		return err
+	}
+	return c.conn.Exec(ctx, sql, args...)
+}
+
+// execSync runs a mutation (ALTER TABLE ... UPDATE/DELETE) and waits until
+// every replica has applied it. ClickHouse otherwise applies mutations in the
+// background, and a cache invalidated right after could be refilled with the
+// old rows.
+func (c *ClickHouseClient) execSync(ctx context.Context, sql string, params Params) error {
+	ctx = clickhouse.Context(ctx, clickhouse.WithSettings(clickhouse.Settings{"mutations_sync": 2}))
+	return c.exec(ctx, sql, params)
+}
+
+// SelectBuilder composes a SELECT from trusted SQL fragments and named
+// parameters, for endpoints whose filters vary per request. Fragments must
+// be constants; request values only ever enter through Params.
+type SelectBuilder struct {
+	columns []string
+	from    string
+	where   []string
+	groupBy []string
+	having  []string
+	orderBy []string
+	limit   bool
+	params  Params
+	err     error
+}
+
+// Select starts a query returning the given columns
+func Select(columns ...string) *SelectBuilder {
+	return &SelectBuilder{columns: columns, params: Params{}}
+}
+
+// From sets the table or subquery to select from
+func (b *SelectBuilder) From(from string) *SelectBuilder {
+	b.from = from
+	return b
+}
+
+// Where adds a condition, ANDed with the others, binding its parameters
+func (b *SelectBuilder) Where(cond string, params Params) *SelectBuilder {
+	b.where = append(b.where, cond)
+	b.bind(params)
+	return b
+}
+
+// WhereIf adds the condition only when ok is true, e.g. for optional filters
+func (b *SelectBuilder) WhereIf(ok bool, cond string, params Params) *SelectBuilder {
+	if ok {
+		b.Where(cond, params)
+	}
+	return b
+}
+
+// GroupBy sets the grouping expressions
+func (b *SelectBuilder) GroupBy(exprs ...string) *SelectBuilder {
+	b.groupBy = exprs
+	return b
+}
+
+// Having adds a post-aggregation condition, ANDed with the others
+func (b *SelectBuilder) Having(cond string, params Params) *SelectBuilder {
+	b.having = append(b.having, cond)
+	b.bind(params)
+	return b
+}
+
+// OrderBy sets the ordering expressions
+func (b *SelectBuilder) OrderBy(exprs ...string) *SelectBuilder {
+	b.orderBy = exprs
+	return b
+}
+
+// Limit caps the number of rows returned
+func (b *SelectBuilder) Limit(n int) *SelectBuilder {
+	b.limit = true
+	b.bind(Params{"limit": n})
+	return b
+}
+
+// bind merges params, rejecting a name bound twice to different clauses
+func (b *SelectBuilder) bind(params Params) {
+	for name, v := range params {
+		if _, dup := b.params[name]; dup && b.err == nil {
+			b.err = fmt.Errorf("query parameter @%s bound twice", name)
+		}
+		b.params[name] = v
+	}
+}
+
+// Build returns the SQL text and its parameters. Queries of the same shape
+// produce the same text, so their placeholders are scanned once.
+func (b *SelectBuilder) Build() (string, Params, error) {
+	if b.err != nil {
+		return "", nil, b.err
+	}
+	if len(b.columns) == 0 || b.from == "" {
+		return "", nil, fmt.Errorf("query needs columns and a FROM clause")
+	}
+
+	var sb strings.Builder
+	sb.WriteString("SELECT ")
+	sb.WriteString(strings.Join(b.columns, ", "))
+	sb.WriteString("\nFROM ")
+	sb.WriteString(b.from)
+	if len(b.where) > 0 {
+		sb.WriteString("\nWHERE ")
+		sb.WriteString(b.condition())
+	}
+	if len(b.groupBy) > 0 {
+		sb.WriteString("\nGROUP BY ")
+		sb.WriteString(strings.Join(b.groupBy, ", "))
+	}
+	if len(b.having) > 0 {
+		sb.WriteString("\nHAVING (")
+		sb.WriteString(strings.Join(b.having, ") AND ("))
+		sb.WriteString(")")
+	}
+	if len(b.orderBy) > 0 {
+		sb.WriteString("\nORDER BY ")
+		sb.WriteString(strings.Join(b.orderBy, ", "))
+	}
+	if b.limit {
+		sb.WriteString("\nLIMIT @limit")
+	}
+
+	return sb.String(), b.params, nil
+}
+
+// WhereClause returns only the ANDed conditions and their parameters, for
+// statements other than SELECT that share a filter, such as ALTER TABLE ...
+// UPDATE
+func (b *SelectBuilder) WhereClause() (string, Params, error) {
+	if b.err != nil {
+		return "", nil, b.err
+	}
+	if len(b.where) == 0 {
+		return "", nil, fmt.Errorf("query needs a condition")
+	}
+	return b.condition(), b.params, nil
+}
+
+// condition joins the WHERE conditions
+func (b *SelectBuilder) condition() string {
+	return "(" + strings.Join(b.where, ") AND (") + ")"
+}
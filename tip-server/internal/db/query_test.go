@@ -0,0 +1,121 @@
+package db
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"tip-server/internal/models"
+)
+
+func TestSelectBuilder(t *testing.T) {
+	since := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		build  func() (string, Params, error)
+		sql    string
+		params []string // Names bound
+		err    string
+	}{
+		{
+			name: "optional conditions",
+			build: Select("file_id").
+				From("threat_intel.file_registry FINAL").
+				Where("scan_status != 'deleted'", nil).
+				WhereIf(false, "startsWith(file_path, @prefix)", Params{"prefix": "/data"}).
+				Limit(10).
+				Build,
+			sql:    "SELECT file_id\nFROM threat_intel.file_registry FINAL\nWHERE (scan_status != 'deleted')\nLIMIT @limit",
+			params: []string{"limit"},
+		},
+		{
+			name: "bulk filter",
+			build: iocFilterSelect(models.IOCFilter{Type: models.IOCTypeDomain, Since: &since}, "count()").
+				Build,
+			sql: "SELECT count()\nFROM threat_intel.ioc_store\n" +
+				"WHERE (deprecated = 0) AND (source_file_id != @selftest_source) AND (ioc_type = @filter_type) AND (last_seen >= @filter_since)",
+			params: []string{"selftest_source", "filter_type", "filter_since"},
+		},
+		{
+			name:   "bulk filter condition",
+			build:  iocFilterSelect(models.IOCFilter{Tags: []string{"scanner"}}).WhereClause,
+			sql:    "(deprecated = 0) AND (source_file_id != @selftest_source) AND (hasAny(tags, @filter_tags))",
+			params: []string{"selftest_source", "filter_tags"},
+		},
+		{
+			name: "search",
+			build: searchSelect().
+				Where("ioc_value = @q", Params{"q": "evil.example"}).
+				OrderBy("ioc_value", "ioc_type").
+				Limit(5).
+				Build,
+			params: []string{"q", "limit"},
+		},
+		{
+			name:  "parameter bound twice",
+			build: Select("count()").From("t").Where("a = @v", Params{"v": 1}).Where("b = @v", Params{"v": 2}).Build,
+			err:   "bound twice",
+		},
+		{
+			name:  "no table",
+			build: Select("count()").Build,
+			err:   "FROM",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sql, params, err := tt.build()
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("err = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.sql != "" && sql != tt.sql {
+				t.Errorf("sql =\n%s\nwant\n%s", sql, tt.sql)
+			}
+			if len(params) != len(tt.params) {
+				t.Errorf("params = %v, want %v", params, tt.params)
+			}
+			// Every bound value has a placeholder and every placeholder a value
+			if _, err := prepare(sql).bind(params); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestBind(t *testing.T) {
+	const sql = "SELECT 1 FROM t WHERE a = @a AND (b = @b OR c = @b)"
+
+	tests := []struct {
+		name   string
+		params Params
+		err    string
+	}{
+		{"exact", Params{"a": 1, "b": 2}, ""},
+		{"missing", Params{"a": 1}, "missing value for query parameter @b"},
+		{"unused", Params{"a": 1, "b": 2, "c": 3}, "query has no parameter @c"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args, err := prepare(sql).bind(tt.params)
+			if tt.err == "" {
+				if err != nil || len(args) != 2 {
+					t.Errorf("args = %v, err = %v; want 2 args", args, err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tt.err {
+				t.Errorf("err = %v, want %q", err, tt.err)
+			}
+		})
+	}
+	if prepare(sql) != prepare(sql) {
+		t.Error("placeholders of the same text were scanned twice")
+	}
+}
@@ -2,13 +2,18 @@ package db
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/redis/go-redis/v9"
 	"github.com/rs/zerolog/log"
 
 	"tip-server/internal/config"
+	"tip-server/internal/models"
 )
 
 // RedisClient wraps the Redis connection with Bloom Filter support
@@ -105,22 +110,39 @@ func (r *RedisClient) initBloomFilter(ctx context.Context) error {
 
 // BFAdd adds a single item to the Bloom Filter
 func (r *RedisClient) BFAdd(ctx context.Context, item string) error {
-	return r.client.BFAdd(ctx, r.bloomFilterName, item).Err()
+	_, err := r.BFMAddNew(ctx, []string{item})
+	return err
 }
 
 // BFMAdd adds multiple items to the Bloom Filter
 func (r *RedisClient) BFMAdd(ctx context.Context, items []string) error {
+	_, err := r.BFMAddNew(ctx, items)
+	return err
+}
+
+// BFMAddNew adds multiple items to the Bloom Filter and reports, per item,
+// whether it was newly added (false means it was probably already present).
+// The items are also dropped from the negative lookup cache, so a value
+// /check recently missed is found as soon as it is stored.
+func (r *RedisClient) BFMAddNew(ctx context.Context, items []string) ([]bool, error) {
 	if len(items) == 0 {
-		return nil
+		return nil, nil
 	}
 
-	// Convert []string to []interface{} for BFMAdd
 	args := make([]interface{}, len(items))
+	missKeys := make([]string, len(items))
 	for i, item := range items {
 		args[i] = item
+		missKeys[i] = MissCacheKey(item)
 	}
 
-	return r.client.BFMAdd(ctx, r.bloomFilterName, args...).Err()
+	pipe := r.client.Pipeline()
+	added := pipe.BFMAdd(ctx, r.bloomFilterName, args...)
+	pipe.Unlink(ctx, missKeys...)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, err
+	}
+	return added.Result()
 }
 
 // BFExists checks if a single item exists in the Bloom Filter
@@ -149,6 +171,142 @@ func (r *RedisClient) BFInfo(ctx context.Context) (redis.BFInfo, error) {
 	return r.client.BFInfo(ctx, r.bloomFilterName).Result()
 }
 
+// BloomRemovalsKey is the Redis set of values removed from the corpus since the
+// last Bloom filter rebuild. Bloom filters cannot delete, so removals are
+// applied by rebuilding the filter. The ingestor also lists values it failed
+// to add, which the rebuild adds back from ClickHouse.
+const BloomRemovalsKey = "tip:bloom:pending_removals"
+
+// ScheduleBloomRemoval queues values for removal at the next filter rebuild
+func (r *RedisClient) ScheduleBloomRemoval(ctx context.Context, values ...string) error {
+	if len(values) == 0 {
+		return nil
+	}
+	args := make([]interface{}, len(values))
+	for i, v := range values {
+		args[i] = v
+	}
+	return r.client.SAdd(ctx, BloomRemovalsKey, args...).Err()
+}
+
+// PendingBloomRemovals returns how many values await a filter rebuild
+func (r *RedisClient) PendingBloomRemovals(ctx context.Context) (int64, error) {
+	return r.client.SCard(ctx, BloomRemovalsKey).Result()
+}
+
+// RebuildBloomFilter builds a fresh filter via fill and atomically swaps it in
+// place of the live one. fill receives an add function targeting the new filter.
+// Removals scheduled while the rebuild runs are kept for the next rebuild.
+func (r *RedisClient) RebuildBloomFilter(ctx context.Context, fill func(add func([]string) error) error) error {
+	tmpName := r.bloomFilterName + ":rebuild"
+	inProgressKey := BloomRemovalsKey + ":rebuilding"
+
+	if err := r.client.Del(ctx, tmpName).Err(); err != nil {
+		return err
+	}
+	if err := r.client.BFReserve(ctx, tmpName, r.cfg.BloomFilterErrorRate, r.cfg.BloomFilterCapacity).Err(); err != nil {
+		return fmt.Errorf("failed to reserve rebuild filter: %w", err)
+	}
+
+	// Snapshot the removals this rebuild will satisfy
+	if n, err := r.client.Exists(ctx, BloomRemovalsKey).Result(); err != nil {
+		return err
+	} else if n > 0 {
+		if err := r.client.Rename(ctx, BloomRemovalsKey, inProgressKey).Err(); err != nil {
+			return err
+		}
+	}
+
+	add := func(items []string) error {
+		args := make([]interface{}, len(items))
+		for i, item := range items {
+			args[i] = item
+		}
+		return r.client.BFMAdd(ctx, tmpName, args...).Err()
+	}
+
+	if err := fill(add); err != nil {
+		r.client.Del(ctx, tmpName)
+		// Return the snapshot to the pending set so it is retried
+		r.client.SUnionStore(ctx, BloomRemovalsKey, BloomRemovalsKey, inProgressKey)
+		r.client.Del(ctx, inProgressKey)
+		return fmt.Errorf("failed to fill rebuild filter: %w", err)
+	}
+
+	if err := r.client.Rename(ctx, tmpName, r.bloomFilterName).Err(); err != nil {
+		return fmt.Errorf("failed to swap rebuilt filter: %w", err)
+	}
+
+	return r.client.Del(ctx, inProgressKey).Err()
+}
+
+// DumpBloomFilter streams the filter to write chunk by chunk with
+// BF.SCANDUMP. The live filter is copied first, since a filter must not
+// change while it is dumped and the ingestor keeps adding to it.
+func (r *RedisClient) DumpBloomFilter(ctx context.Context, write func(iter int64, data []byte) error) error {
+	snapName := r.bloomFilterName + ":snapshot"
+	if err := r.client.Copy(ctx, r.bloomFilterName, snapName, r.cfg.DB, true).Err(); err != nil {
+		return fmt.Errorf("failed to copy filter for snapshot: %w", err)
+	}
+	defer r.client.Del(context.WithoutCancel(ctx), snapName)
+
+	var iter int64
+	for {
+		dump, err := r.client.BFScanDump(ctx, snapName, iter).Result()
+		if err != nil {
+			return err
+		}
+		if dump.Iter == 0 {
+			return nil
+		}
+		if err := write(dump.Iter, []byte(dump.Data)); err != nil {
+			return err
+		}
+		iter = dump.Iter
+	}
+}
+
+// RestoreBloomFilter replaces the live filter with one dumped by
+// DumpBloomFilter. read receives a load function to call for each chunk,
+// in dump order; the filter is swapped in only once every chunk loaded.
+func (r *RedisClient) RestoreBloomFilter(ctx context.Context, read func(load func(iter int64, data []byte) error) error) error {
+	tmpName := r.bloomFilterName + ":restore"
+	if err := r.client.Del(ctx, tmpName).Err(); err != nil {
+		return err
+	}
+
+	load := func(iter int64, data []byte) error {
+		return r.client.BFLoadChunk(ctx, tmpName, iter, data).Err()
+	}
+	if err := read(load); err != nil {
+		r.client.Del(ctx, tmpName)
+		return fmt.Errorf("failed to load filter snapshot: %w", err)
+	}
+
+	if err := r.client.Rename(ctx, tmpName, r.bloomFilterName).Err(); err != nil {
+		return fmt.Errorf("failed to swap restored filter: %w", err)
+	}
+	return nil
+}
+
+// SyncCursorKey holds the opaque cursor of a replica into its primary's
+// IOC store (see jobs.NewReplicaSync)
+const SyncCursorKey = "tip:sync:cursor"
+
+// GetSyncCursor returns the replica's sync cursor, or "" before the first sync
+func (r *RedisClient) GetSyncCursor(ctx context.Context) (string, error) {
+	cursor, err := r.client.Get(ctx, SyncCursorKey).Result()
+	if err == redis.Nil {
+		return "", nil
+	}
+	return cursor, err
+}
+
+// SetSyncCursor records how far the replica has synced
+func (r *RedisClient) SetSyncCursor(ctx context.Context, cursor string) error {
+	return r.client.Set(ctx, SyncCursorKey, cursor, 0).Err()
+}
+
 // ========== Cache Operations ==========
 
 // Set sets a key-value pair with expiration
@@ -161,11 +319,323 @@ func (r *RedisClient) Get(ctx context.Context, key string) (string, error) {
 	return r.client.Get(ctx, key).Result()
 }
 
+// SetJSON marshals v and stores it under key with expiration (0 = no expiry)
+func (r *RedisClient) SetJSON(ctx context.Context, key string, v interface{}, expiration time.Duration) error {
+	payload, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to marshal value: %w", err)
+	}
+	return r.client.Set(ctx, key, payload, expiration).Err()
+}
+
+// GetJSON loads key and unmarshals it into v. Returns redis.Nil if the key is missing.
+func (r *RedisClient) GetJSON(ctx context.Context, key string, v interface{}) error {
+	payload, err := r.client.Get(ctx, key).Bytes()
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(payload, v)
+}
+
 // Delete deletes a key
 func (r *RedisClient) Delete(ctx context.Context, keys ...string) error {
 	return r.client.Del(ctx, keys...).Err()
 }
 
+// ========== Lookup Cache ==========
+
+// LookupCacheKey generates the /check cache key for an IOC value
+func LookupCacheKey(value string) string {
+	return "tip:lookup:" + value
+}
+
+// GetCachedIOCs returns the cached IOCs among values
+func (r *RedisClient) GetCachedIOCs(ctx context.Context, values []string) (map[string]models.IOC, error) {
+	keys := make([]string, len(values))
+	for i, v := range values {
+		keys[i] = LookupCacheKey(v)
+	}
+
+	payloads, err := r.client.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+	return decodeCachedIOCs(values, payloads), nil
+}
+
+// decodeCachedIOCs decodes an MGET of lookup cache keys
+func decodeCachedIOCs(values []string, payloads []interface{}) map[string]models.IOC {
+	cached := make(map[string]models.IOC)
+	for i, p := range payloads {
+		payload, ok := p.(string)
+		if !ok {
+			continue // Missing
+		}
+		var ioc models.IOC
+		if err := json.Unmarshal([]byte(payload), &ioc); err != nil {
+			continue
+		}
+		cached[values[i]] = ioc
+	}
+	return cached
+}
+
+// CacheIOCs stores IOCs keyed by value for ttl
+func (r *RedisClient) CacheIOCs(ctx context.Context, iocs map[string]models.IOC, ttl time.Duration) error {
+	if len(iocs) == 0 {
+		return nil
+	}
+
+	pipe := r.client.Pipeline()
+	for value, ioc := range iocs {
+		payload, err := json.Marshal(ioc)
+		if err != nil {
+			return fmt.Errorf("failed to marshal IOC: %w", err)
+		}
+		pipe.Set(ctx, LookupCacheKey(value), payload, ttl)
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// InvalidateCachedIOCs drops values from the lookup cache
+func (r *RedisClient) InvalidateCachedIOCs(ctx context.Context, values ...string) error {
+	keys := make([]string, len(values))
+	for i, v := range values {
+		keys[i] = LookupCacheKey(v)
+	}
+	return r.client.Del(ctx, keys...).Err()
+}
+
+// FlushCachedIOCs empties the lookup cache, for changes touching more values
+// than are worth listing
+func (r *RedisClient) FlushCachedIOCs(ctx context.Context) error {
+	var keys []string
+	iter := r.client.Scan(ctx, 0, LookupCacheKey("*"), 1000).Iterator()
+	for iter.Next(ctx) {
+		keys = append(keys, iter.Val())
+		if len(keys) == 1000 {
+			if err := r.client.Unlink(ctx, keys...).Err(); err != nil {
+				return err
+			}
+			keys = keys[:0]
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return err
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+	return r.client.Unlink(ctx, keys...).Err()
+}
+
+// MissCacheKey generates the /check negative cache key for an IOC value
+func MissCacheKey(value string) string {
+	return "tip:miss:" + value
+}
+
+// LookupProbe is what Redis holds on the values of a /check request. Each
+// read reports its own error; the others are still answered.
+type LookupProbe struct {
+	Bloom    []bool // Filter answer per value
+	BloomErr error
+
+	Cached   map[string]models.IOC // Lookup cache hits
+	CacheErr error
+
+	Misses  map[string]bool // Values recently looked up and not found
+	MissErr error
+}
+
+// ProbeLookups reads the Bloom filter and, when asked, the lookup and
+// negative caches for values in one pipelined round trip
+func (r *RedisClient) ProbeLookups(ctx context.Context, values []string, cached, misses bool) LookupProbe {
+	var probe LookupProbe
+	if len(values) == 0 {
+		return probe
+	}
+
+	args := make([]interface{}, len(values))
+	for i, v := range values {
+		args[i] = v
+	}
+
+	pipe := r.client.Pipeline()
+	bloom := pipe.BFMExists(ctx, r.bloomFilterName, args...)
+	var cacheCmd, missCmd *redis.SliceCmd
+	if cached {
+		keys := make([]string, len(values))
+		for i, v := range values {
+			keys[i] = LookupCacheKey(v)
+		}
+		cacheCmd = pipe.MGet(ctx, keys...)
+	}
+	if misses {
+		keys := make([]string, len(values))
+		for i, v := range values {
+			keys[i] = MissCacheKey(v)
+		}
+		missCmd = pipe.MGet(ctx, keys...)
+	}
+	pipe.Exec(ctx) // Errors are read per command below
+
+	probe.Bloom, probe.BloomErr = bloom.Result()
+	if cacheCmd != nil {
+		var payloads []interface{}
+		if payloads, probe.CacheErr = cacheCmd.Result(); probe.CacheErr == nil {
+			probe.Cached = decodeCachedIOCs(values, payloads)
+		}
+	}
+	if missCmd != nil {
+		var payloads []interface{}
+		if payloads, probe.MissErr = missCmd.Result(); probe.MissErr == nil {
+			probe.Misses = make(map[string]bool)
+			for i, p := range payloads {
+				if p != nil {
+					probe.Misses[values[i]] = true
+				}
+			}
+		}
+	}
+	return probe
+}
+
+// StoreLookups writes /check outcomes back in one pipelined round trip:
+// matches to the lookup cache for hitTTL and values not found to the
+// negative cache for missTTL. A zero TTL skips that cache.
+func (r *RedisClient) StoreLookups(ctx context.Context, found map[string]models.IOC, hitTTL time.Duration, absent []string, missTTL time.Duration) error {
+	pipe := r.client.Pipeline()
+	if hitTTL > 0 {
+		for value, ioc := range found {
+			payload, err := json.Marshal(ioc)
+			if err != nil {
+				return fmt.Errorf("failed to marshal IOC: %w", err)
+			}
+			pipe.Set(ctx, LookupCacheKey(value), payload, hitTTL)
+		}
+	}
+	if missTTL > 0 {
+		for _, v := range absent {
+			pipe.Set(ctx, MissCacheKey(v), 1, missTTL)
+		}
+	}
+
+	if pipe.Len() == 0 {
+		return nil
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// ClusterReportKey holds the latest file vector clustering result
+const ClusterReportKey = "tip:clusters:latest"
+
+// ConsistencyReportKey holds the latest cross-store consistency check
+const ConsistencyReportKey = "tip:consistency:latest"
+
+// VolumeReportKey holds the latest ingestion volume check
+const VolumeReportKey = "tip:volume_report:latest"
+
+// ========== Pub/Sub ==========
+
+// IngestionEventsChannel is the pub/sub channel carrying per-file ingestion events
+const IngestionEventsChannel = "tip:events:ingestion"
+
+// MatchEventsChannel is the pub/sub channel carrying new-IOC and check-hit events
+const MatchEventsChannel = "tip:events:matches"
+
+// IngestRunsChannel is the pub/sub channel carrying on-demand crawl requests
+// from the API to watch-mode ingestors
+const IngestRunsChannel = "tip:ingest:runs"
+
+// RequestIngestRun publishes an on-demand crawl, returning how many
+// ingestors received it
+func (r *RedisClient) RequestIngestRun(ctx context.Context, run models.IngestRun) (int64, error) {
+	payload, err := json.Marshal(run)
+	if err != nil {
+		return 0, fmt.Errorf("failed to marshal ingest run: %w", err)
+	}
+	return r.client.Publish(ctx, IngestRunsChannel, payload).Result()
+}
+
+// PublishMatchEvents publishes match events in a single pipeline. Nothing is
+// sent when the channel has no subscribers, so bulk ingestion stays cheap.
+func (r *RedisClient) PublishMatchEvents(ctx context.Context, events []models.MatchEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	subs, err := r.client.PubSubNumSub(ctx, MatchEventsChannel).Result()
+	if err != nil {
+		return err
+	}
+	if subs[MatchEventsChannel] == 0 {
+		return nil
+	}
+
+	pipe := r.client.Pipeline()
+	for _, event := range events {
+		payload, err := json.Marshal(event)
+		if err != nil {
+			return fmt.Errorf("failed to marshal event: %w", err)
+		}
+		pipe.Publish(ctx, MatchEventsChannel, payload)
+	}
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+// PublishJSON marshals v and publishes it on a pub/sub channel
+func (r *RedisClient) PublishJSON(ctx context.Context, channel string, v interface{}) error {
+	payload, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %w", err)
+	}
+	return r.client.Publish(ctx, channel, payload).Err()
+}
+
+// Subscribe subscribes to one or more pub/sub channels
+func (r *RedisClient) Subscribe(ctx context.Context, channels ...string) Subscription {
+	sub := &redisSubscription{
+		pubsub:   r.client.Subscribe(ctx, channels...),
+		payloads: make(chan string),
+		done:     make(chan struct{}),
+	}
+	go sub.relay()
+	return sub
+}
+
+// redisSubscription relays the payloads of a Redis subscription
+type redisSubscription struct {
+	pubsub   *redis.PubSub
+	payloads chan string
+	done     chan struct{}
+	once     sync.Once
+}
+
+func (s *redisSubscription) relay() {
+	defer close(s.payloads)
+	for msg := range s.pubsub.Channel() {
+		select {
+		case s.payloads <- msg.Payload:
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// Payloads returns the channel delivering message payloads
+func (s *redisSubscription) Payloads() <-chan string {
+	return s.payloads
+}
+
+// Close unsubscribes, closing the payload channel
+func (s *redisSubscription) Close() error {
+	s.once.Do(func() { close(s.done) })
+	return s.pubsub.Close()
+}
+
 // ========== Rate Limiting ==========
 
 // RateLimitKey generates a rate limit key for an API key
@@ -176,8 +646,43 @@ func RateLimitKey(apiKeyHash string) string {
 // IncrementRateLimit increments and checks rate limit
 // Returns the current count and whether the limit was exceeded
 func (r *RedisClient) IncrementRateLimit(ctx context.Context, apiKeyHash string, limit int, window time.Duration) (int64, bool, error) {
-	key := RateLimitKey(apiKeyHash)
+	return r.incrementWindow(ctx, RateLimitKey(apiKeyHash), limit, window)
+}
 
+// IPRateLimitKey generates a rate limit key for a client IP
+func IPRateLimitKey(ip string) string {
+	return fmt.Sprintf("rate_limit:ip:%s", ip)
+}
+
+// IncrementIPRateLimit increments and checks the rate limit for a client IP
+func (r *RedisClient) IncrementIPRateLimit(ctx context.Context, ip string, limit int, window time.Duration) (int64, bool, error) {
+	return r.incrementWindow(ctx, IPRateLimitKey(ip), limit, window)
+}
+
+// AuthFailureKey generates the failed-authentication counter key for a client IP
+func AuthFailureKey(ip string) string {
+	return fmt.Sprintf("auth_failures:%s", ip)
+}
+
+// RecordAuthFailure counts a failed authentication from ip within window
+// and reports whether the count now exceeds limit
+func (r *RedisClient) RecordAuthFailure(ctx context.Context, ip string, limit int, window time.Duration) (int64, bool, error) {
+	return r.incrementWindow(ctx, AuthFailureKey(ip), limit, window)
+}
+
+// EnrichmentCacheKey generates the cache key for a provider verdict
+func EnrichmentCacheKey(provider, value string) string {
+	return fmt.Sprintf("tip:enrich:%s:%s", provider, value)
+}
+
+// IncrementEnrichmentRateLimit counts a lookup against a provider's
+// per-window quota and reports whether the quota is exhausted
+func (r *RedisClient) IncrementEnrichmentRateLimit(ctx context.Context, provider string, limit int, window time.Duration) (int64, bool, error) {
+	return r.incrementWindow(ctx, fmt.Sprintf("rate_limit:enrich:%s", provider), limit, window)
+}
+
+// incrementWindow increments a fixed-window counter and reports whether it exceeds limit
+func (r *RedisClient) incrementWindow(ctx context.Context, key string, limit int, window time.Duration) (int64, bool, error) {
 	// Use a Lua script for atomic increment + TTL check
 	script := redis.NewScript(`
 		local current = redis.call("INCR", KEYS[1])
@@ -212,3 +717,199 @@ func (r *RedisClient) GetRateLimitRemaining(ctx context.Context, apiKeyHash stri
 	}
 	return remaining, nil
 }
+
+// ========== IP Blocklist ==========
+
+// ipBlockPrefix prefixes per-IP blocklist entries; entries expire via key TTL
+const ipBlockPrefix = "tip:ip_block:"
+
+// IPBlockKey generates the blocklist key for a client IP
+func IPBlockKey(ip string) string {
+	return ipBlockPrefix + ip
+}
+
+// BlockIP adds an IP to the blocklist. A zero ttl blocks until removed.
+func (r *RedisClient) BlockIP(ctx context.Context, entry models.IPBlock, ttl time.Duration) error {
+	return r.SetJSON(ctx, IPBlockKey(entry.IP), entry, ttl)
+}
+
+// UnblockIP removes an IP from the blocklist and reports whether it was listed
+func (r *RedisClient) UnblockIP(ctx context.Context, ip string) (bool, error) {
+	n, err := r.client.Del(ctx, IPBlockKey(ip)).Result()
+	if err != nil {
+		return false, err
+	}
+
+	// Reset the failure counter so an unblocked IP is not re-blocked immediately
+	if err := r.client.Del(ctx, AuthFailureKey(ip)).Err(); err != nil {
+		return n > 0, err
+	}
+	return n > 0, nil
+}
+
+// IsIPBlocked checks whether an IP is currently blocklisted
+func (r *RedisClient) IsIPBlocked(ctx context.Context, ip string) (bool, error) {
+	n, err := r.client.Exists(ctx, IPBlockKey(ip)).Result()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+// ListBlockedIPs returns all current blocklist entries
+func (r *RedisClient) ListBlockedIPs(ctx context.Context) ([]models.IPBlock, error) {
+	var entries []models.IPBlock
+
+	iter := r.client.Scan(ctx, 0, ipBlockPrefix+"*", 100).Iterator()
+	for iter.Next(ctx) {
+		var entry models.IPBlock
+		if err := r.GetJSON(ctx, iter.Val(), &entry); err != nil {
+			if err == redis.Nil {
+				continue // Expired between SCAN and GET
+			}
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	if err := iter.Err(); err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+// ========== Ingestion Volume ==========
+
+const (
+	// volumePrefix prefixes the hourly IOC counts by feed and type, one hash
+	// per hour named by its Unix start time
+	volumePrefix = "tip:volume:"
+	// volumeSinceKey records when counting started, expiring with the
+	// counts so a gap in recording restarts the baseline
+	volumeSinceKey = "tip:volume_since"
+)
+
+// VolumeHourKey generates the key of an hour's IOC counts
+func VolumeHourKey(hour time.Time) string {
+	return volumePrefix + strconv.FormatInt(hour.Truncate(time.Hour).Unix(), 10)
+}
+
+// VolumeField names a series within an hour's counts
+func VolumeField(k models.VolumeKey) string {
+	return string(k.Type) + "|" + k.Feed
+}
+
+// ParseVolumeField reverses VolumeField
+func ParseVolumeField(field string) (models.VolumeKey, bool) {
+	t, feed, ok := strings.Cut(field, "|")
+	return models.VolumeKey{Feed: feed, Type: models.IOCType(t)}, ok
+}
+
+// RecordIngestionVolume adds IOC counts to the hour at, keeping the hour for
+// retention
+func (r *RedisClient) RecordIngestionVolume(ctx context.Context, at time.Time, counts map[models.VolumeKey]int64, retention time.Duration) error {
+	if len(counts) == 0 {
+		return nil
+	}
+	key := VolumeHourKey(at)
+
+	pipe := r.client.Pipeline()
+	for k, n := range counts {
+		pipe.HIncrBy(ctx, key, VolumeField(k), n)
+	}
+	pipe.Expire(ctx, key, retention)
+	pipe.SetNX(ctx, volumeSinceKey, at.Unix(), retention)
+	pipe.Expire(ctx, volumeSinceKey, retention)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// GetIngestionVolume returns the IOC counts of each hour, and since when
+// counts have been recorded without a gap longer than their retention (zero
+// if they are not)
+func (r *RedisClient) GetIngestionVolume(ctx context.Context, hours []time.Time) ([]map[models.VolumeKey]int64, time.Time, error) {
+	pipe := r.client.Pipeline()
+	cmds := make([]*redis.MapStringStringCmd, len(hours))
+	for i, hour := range hours {
+		cmds[i] = pipe.HGetAll(ctx, VolumeHourKey(hour))
+	}
+	since := pipe.Get(ctx, volumeSinceKey)
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return nil, time.Time{}, err
+	}
+
+	counts := make([]map[models.VolumeKey]int64, len(hours))
+	for i, cmd := range cmds {
+		counts[i] = make(map[models.VolumeKey]int64)
+		for field, value := range cmd.Val() {
+			k, ok := ParseVolumeField(field)
+			n, err := strconv.ParseInt(value, 10, 64)
+			if ok && err == nil {
+				counts[i][k] = n
+			}
+		}
+	}
+
+	var started time.Time
+	if unix, err := since.Int64(); err == nil {
+		started = time.Unix(unix, 0)
+	}
+	return counts, started, nil
+}
+
+// ========== Subscriptions ==========
+
+const (
+	// subscriptionPrefix prefixes consumer subscriptions, one key each
+	subscriptionPrefix = "tip:subscription:"
+	// subscriptionStatusPrefix prefixes their delivery statistics
+	subscriptionStatusPrefix = "tip:subscription_status:"
+)
+
+// SubscriptionKey generates the key of a subscription
+func SubscriptionKey(id string) string {
+	return subscriptionPrefix + id
+}
+
+// SubscriptionStatusKey generates the key of a subscription's delivery
+// statistics
+func SubscriptionStatusKey(id string) string {
+	return subscriptionStatusPrefix + id
+}
+
+// SaveSubscription stores a subscription
+func (r *RedisClient) SaveSubscription(ctx context.Context, sub models.Subscription) error {
+	return r.SetJSON(ctx, SubscriptionKey(sub.ID), sub, 0)
+}
+
+// DeleteSubscription removes a subscription and its statistics, and reports
+// whether it existed
+func (r *RedisClient) DeleteSubscription(ctx context.Context, id string) (bool, error) {
+	n, err := r.client.Del(ctx, SubscriptionKey(id), SubscriptionStatusKey(id)).Result()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+// ListSubscriptions returns all subscriptions
+func (r *RedisClient) ListSubscriptions(ctx context.Context) ([]models.Subscription, error) {
+	var subs []models.Subscription
+
+	iter := r.client.Scan(ctx, 0, subscriptionPrefix+"*", 100).Iterator()
+	for iter.Next(ctx) {
+		var sub models.Subscription
+		if err := r.GetJSON(ctx, iter.Val(), &sub); err != nil {
+			if err == redis.Nil {
+				continue // Deleted between SCAN and GET
+			}
+			return nil, err
+		}
+		subs = append(subs, sub)
+	}
+	if err := iter.Err(); err != nil {
+		return nil, err
+	}
+
+	return subs, nil
+}
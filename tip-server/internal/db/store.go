@@ -0,0 +1,167 @@
+package db
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/redis/go-redis/v9"
+
+	"tip-server/internal/models"
+)
+
+// The API server, the ingestor and background jobs reach storage through
+// these interfaces, so the ClickHouse, Redis and MinIO clients can be swapped
+// for the in-memory backends of package memstore.
+
+// IOCStore holds the IOC store, file registry, domain resolutions, WHOIS
+// relationships, extraction samples and the audit and query logs
+// (ClickHouseClient)
+type IOCStore interface {
+	Close() error
+	Ping(ctx context.Context) error
+
+	// File registry
+	GetFileMetadata(ctx context.Context, fileID string) (*models.FileMetadata, error)
+	GetFileMetadataByPath(ctx context.Context, filePath string) (*models.FileMetadata, error)
+	UpsertFileMetadata(ctx context.Context, meta *models.FileMetadata) error
+	ListActiveFiles(ctx context.Context, pathPrefix string) (map[string]string, error)
+	ListStoredMiscFiles(ctx context.Context) ([]models.FileMetadata, error)
+	ListFilesByStatus(ctx context.Context, status models.ScanStatus, limit int) ([]models.FileMetadata, error)
+	GetFileHistory(ctx context.Context, fileID string, limit int) ([]models.FileMetadata, error)
+	ListDuplicateFiles(ctx context.Context, limit, offset int) (*models.DuplicateReport, error)
+	ListFilesMissingIOCs(ctx context.Context, before time.Time, limit int) ([]string, uint64, error)
+	PurgeFiles(ctx context.Context, fileIDs []string) error
+	GetReferencedMinIOKeys(ctx context.Context) (map[string]struct{}, error)
+	CountMinIOKeyReferences(ctx context.Context, minioKey string, excludeFileIDs []string) (uint64, error)
+
+	// IOCs
+	BatchInsertIOCs(ctx context.Context, iocs []models.IOC) error
+	QueryIOCs(ctx context.Context, iocValues []string) ([]models.IOC, error)
+	StreamIOCs(ctx context.Context, iocValues []string, fn func(models.IOC) error) error
+	ListActiveRanges(ctx context.Context) ([]models.IOC, error)
+	SearchIOCs(ctx context.Context, mode, q string, iocType models.IOCType, minDGAScore uint8, limit int) ([]models.IOC, error)
+	MatchIOCs(ctx context.Context, pattern string, iocType models.IOCType, minDGAScore uint8, limit, maxRows int) ([]models.IOC, error)
+	ListOrphanedIOCSources(ctx context.Context, before time.Time, limit int) ([]string, uint64, error)
+	GetIndicatorsBySourceFiles(ctx context.Context, fileIDs []string, limit int) ([]models.ClusterIndicator, error)
+	ListIOCsBySourceFile(ctx context.Context, fileID string, limit, offset int) ([]models.IOC, uint64, error)
+	DeprecateIOC(ctx context.Context, value string) (uint64, error)
+	ListPendingIOCs(ctx context.Context, iocType models.IOCType, limit, offset int) ([]models.IOC, uint64, error)
+	ReviewIOCs(ctx context.Context, values []string, approve bool) (uint64, error)
+	CountIOCs(ctx context.Context, filter models.IOCFilter) (uint64, error)
+	BulkUpdateIOCs(ctx context.Context, filter models.IOCFilter, update models.IOCUpdate) (uint64, error)
+	ListIOCFilterValues(ctx context.Context, filter models.IOCFilter, limit int) ([]string, error)
+	DeprecateIOCsBySource(ctx context.Context, fileIDs []string) ([]string, error)
+	DeleteIOCs(ctx context.Context, values, fileIDs []string, purge bool) ([]string, error)
+	GetSealedValues(ctx context.Context, values []string) (map[string]string, error)
+	GetIOCSources(ctx context.Context, values []string, limit int) (map[string][]models.IOCSource, map[string]int, error)
+	DeleteSelfTestIOCs(ctx context.Context) error
+	StreamActiveIOCValues(ctx context.Context, since time.Time, batchSize int, fn func([]string) error) error
+	GetIOCsAfter(ctx context.Context, cursor models.SyncCursor, limit int) ([]models.SyncIOC, error)
+	ExportIOCsParquet(ctx context.Context) (io.ReadCloser, error)
+
+	// Domain resolution and WHOIS
+	ListDomainsDueForResolution(ctx context.Context, olderThan time.Time, limit int) ([]models.IOC, error)
+	InsertDomainResolutions(ctx context.Context, resolutions []models.DomainResolution) error
+	GetDomainResolutions(ctx context.Context, domains []string) (map[string]models.DomainResolution, error)
+	InsertWhoisRelationships(ctx context.Context, rels []models.WhoisRelationship) error
+	GetWhoisRelatedDomains(ctx context.Context, relation, value string, limit int) ([]models.WhoisRelationship, error)
+
+	// Extraction samples
+	InsertExtractionSamples(ctx context.Context, samples []models.ExtractionSample) error
+	ListExtractionSamples(ctx context.Context, iocType models.IOCType, status string, limit, offset int) ([]models.ExtractionSample, uint64, error)
+	GetExtractionSamples(ctx context.Context, ids []string) (map[string]models.ExtractionSample, error)
+	GetSamplePrecision(ctx context.Context, groupBy string, iocType models.IOCType, since time.Time) ([]models.PrecisionEstimate, error)
+
+	// Logs and statistics
+	InsertAuditEntry(ctx context.Context, entry models.AuditEntry) error
+	InsertQueryLog(ctx context.Context, entry models.QueryLogEntry) error
+	GetTopLookups(ctx context.Context, dimension string, since time.Time, limit int) ([]models.TopEntry, error)
+	GetIOCStats(ctx context.Context) (map[models.IOCType]int64, error)
+	GetFileStats(ctx context.Context) (map[models.ScanStatus]int64, error)
+	GetFreshnessStats(ctx context.Context, feedFormats []string, feedLimit int) (*models.FreshnessStats, error)
+}
+
+// Cache holds the Bloom filter, lookup and JSON caches, pub/sub channels,
+// rate limit counters and the IP blocklist (RedisClient). Missing keys are
+// reported as redis.Nil.
+type Cache interface {
+	Close() error
+	Ping(ctx context.Context) error
+
+	// Bloom filter
+	BFMAdd(ctx context.Context, items []string) error
+	BFMAddNew(ctx context.Context, items []string) ([]bool, error)
+	BFMExists(ctx context.Context, items []string) ([]bool, error)
+	BFInfo(ctx context.Context) (redis.BFInfo, error)
+	ScheduleBloomRemoval(ctx context.Context, values ...string) error
+	PendingBloomRemovals(ctx context.Context) (int64, error)
+	RebuildBloomFilter(ctx context.Context, fill func(add func([]string) error) error) error
+	DumpBloomFilter(ctx context.Context, write func(iter int64, data []byte) error) error
+	RestoreBloomFilter(ctx context.Context, read func(load func(iter int64, data []byte) error) error) error
+
+	// Key-value
+	GetSyncCursor(ctx context.Context) (string, error)
+	SetSyncCursor(ctx context.Context, cursor string) error
+	SetJSON(ctx context.Context, key string, v interface{}, expiration time.Duration) error
+	GetJSON(ctx context.Context, key string, v interface{}) error
+	GetCachedIOCs(ctx context.Context, values []string) (map[string]models.IOC, error)
+	CacheIOCs(ctx context.Context, iocs map[string]models.IOC, ttl time.Duration) error
+	InvalidateCachedIOCs(ctx context.Context, values ...string) error
+	FlushCachedIOCs(ctx context.Context) error
+	ProbeLookups(ctx context.Context, values []string, cached, misses bool) LookupProbe
+	StoreLookups(ctx context.Context, found map[string]models.IOC, hitTTL time.Duration, absent []string, missTTL time.Duration) error
+
+	// Pub/sub
+	RequestIngestRun(ctx context.Context, run models.IngestRun) (int64, error)
+	PublishMatchEvents(ctx context.Context, events []models.MatchEvent) error
+	PublishJSON(ctx context.Context, channel string, v interface{}) error
+	Subscribe(ctx context.Context, channels ...string) Subscription
+
+	// Rate limits and IP blocklist
+	IncrementRateLimit(ctx context.Context, apiKeyHash string, limit int, window time.Duration) (int64, bool, error)
+	IncrementIPRateLimit(ctx context.Context, ip string, limit int, window time.Duration) (int64, bool, error)
+	RecordAuthFailure(ctx context.Context, ip string, limit int, window time.Duration) (int64, bool, error)
+	IncrementEnrichmentRateLimit(ctx context.Context, provider string, limit int, window time.Duration) (int64, bool, error)
+	GetRateLimitRemaining(ctx context.Context, apiKeyHash string, limit int) (int, error)
+	BlockIP(ctx context.Context, entry models.IPBlock, ttl time.Duration) error
+	UnblockIP(ctx context.Context, ip string) (bool, error)
+	IsIPBlocked(ctx context.Context, ip string) (bool, error)
+	ListBlockedIPs(ctx context.Context) ([]models.IPBlock, error)
+
+	// Ingestion volume
+	RecordIngestionVolume(ctx context.Context, at time.Time, counts map[models.VolumeKey]int64, retention time.Duration) error
+	GetIngestionVolume(ctx context.Context, hours []time.Time) ([]map[models.VolumeKey]int64, time.Time, error)
+
+	// Consumer subscriptions
+	SaveSubscription(ctx context.Context, sub models.Subscription) error
+	DeleteSubscription(ctx context.Context, id string) (bool, error)
+	ListSubscriptions(ctx context.Context) ([]models.Subscription, error)
+}
+
+// Subscription receives the payloads published on the channels subscribed
+// to. The channel returned by Payloads is closed by Close.
+type Subscription interface {
+	Payloads() <-chan string
+	Close() error
+}
+
+// ObjectStore holds file contents, quarantined samples and exports
+// (MinIOClient)
+type ObjectStore interface {
+	Ping(ctx context.Context) error
+	UploadBytes(ctx context.Context, objectName string, content []byte, contentType string) (*minio.UploadInfo, error)
+	UploadQuarantined(ctx context.Context, objectName string, content []byte) (*minio.UploadInfo, error)
+	UploadStream(ctx context.Context, objectName string, reader io.Reader, contentType string) (*minio.UploadInfo, error)
+	OpenObject(ctx context.Context, objectName string) (io.ReadCloser, minio.ObjectInfo, int64, error)
+	ObjectExists(ctx context.Context, objectName string) (bool, error)
+	DeleteObject(ctx context.Context, objectName string) error
+	ListObjects(ctx context.Context, prefix string) <-chan minio.ObjectInfo
+}
+
+var (
+	_ IOCStore    = (*ClickHouseClient)(nil)
+	_ Cache       = (*RedisClient)(nil)
+	_ ObjectStore = (*MinIOClient)(nil)
+)
@@ -0,0 +1,43 @@
+package dga
+
+import "testing"
+
+func TestScore(t *testing.T) {
+	tests := []struct {
+		domain string
+		min    uint8
+		max    uint8
+	}{
+		// Generated names, as seen in C2 lookups
+		{"kqzxjvbwpt.com", 80, 100},
+		{"xjwqkzvr.net", 80, 100},
+		{"a3f9k2l8q7z.info", 80, 100},
+		{"mhkdtpzwqjfx.biz", 80, 100},
+		{"wbqjczsdnlrhk.net", 80, 100},
+		{"pgdwkgvbsyswyqt.com", 80, 100},
+		{"www.ydlcgjdkqqmbz.org", 80, 100},
+
+		// Words, brands and compounds of them
+		{"stackoverflow.com", 0, 20},
+		{"microsoftonline.com", 0, 20},
+		{"login.microsoftonline.com", 0, 20},
+		{"cloudflare.net", 0, 20},
+		{"jpmorganchase.com", 0, 20},
+		{"update-checker-cdn.net", 0, 20},
+		{"paymentsportal.co.uk", 0, 20},
+		{"wikipedia.org", 0, 20},
+
+		// Not scored
+		{"google.com", 0, 0},
+		{"xkqjz.com", 0, 0},
+		{"xn--80ak6aa92e.com", 0, 0},
+		{"q-z-x-j.com", 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.domain, func(t *testing.T) {
+			if got := Score(tt.domain); got < tt.min || got > tt.max {
+				t.Errorf("Score(%q) = %d, want %d-%d", tt.domain, got, tt.min, tt.max)
+			}
+		})
+	}
+}
@@ -0,0 +1,76 @@
+// Package embed turns file content into fixed-size vectors for similarity
+// search in Qdrant.
+package embed
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"unicode"
+	"unicode/utf8"
+
+	"tip-server/internal/config"
+)
+
+// Embedder converts text into a vector of fixed dimensionality
+type Embedder interface {
+	// Name identifies the provider in logs and point payloads
+	Name() string
+	// Dimensions is the length of every vector returned by Embed
+	Dimensions() int
+	// Embed returns the vector for text
+	Embed(ctx context.Context, text string) ([]float32, error)
+}
+
+// ErrNoContent is returned for text without any words to embed
+var ErrNoContent = errors.New("no embeddable content")
+
+// New creates the embedder selected by cfg.Provider
+func New(cfg config.EmbeddingConfig) (Embedder, error) {
+	switch cfg.Provider {
+	case "hash":
+		return NewHashEmbedder(cfg.Dimensions), nil
+	case "openai":
+		return NewOpenAI(cfg.URL, cfg.Model, cfg.APIKey, cfg.Dimensions, &http.Client{Timeout: cfg.Timeout}), nil
+	case "onnx":
+		return NewONNX(cfg)
+	default:
+		return nil, fmt.Errorf("unknown embedding provider %q", cfg.Provider)
+	}
+}
+
+// maxBinaryRatio is the share of control characters above which content is
+// treated as binary and not embedded
+const maxBinaryRatio = 0.1
+
+// Text returns the first maxBytes of content as a string, or false when the
+// content looks binary. A multi-byte rune cut off at the limit is dropped.
+func Text(content []byte, maxBytes int) (string, bool) {
+	if len(content) > maxBytes {
+		content = content[:maxBytes]
+		for i := 1; i < utf8.UTFMax && len(content) > 0; i++ {
+			if r, _ := utf8.DecodeLastRune(content); r != utf8.RuneError {
+				break
+			}
+			content = content[:len(content)-1]
+		}
+	}
+	if len(content) == 0 {
+		return "", false
+	}
+
+	control, runes := 0, 0
+	for rest := content; len(rest) > 0; runes++ {
+		r, size := utf8.DecodeRune(rest)
+		if (r == utf8.RuneError && size == 1) || (unicode.IsControl(r) && !unicode.IsSpace(r)) {
+			control++
+		}
+		rest = rest[size:]
+	}
+	if float64(control) > maxBinaryRatio*float64(runes) {
+		return "", false
+	}
+
+	return string(content), true
+}
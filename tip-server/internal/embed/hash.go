@@ -0,0 +1,76 @@
+package embed
+
+import (
+	"context"
+	"hash/fnv"
+	"math"
+	"strings"
+	"unicode"
+)
+
+// HashEmbedder embeds text with the hashing trick: word unigrams and bigrams
+// are hashed into a fixed number of buckets with a sign bit, weighted by
+// sub-linear term frequency and L2-normalised. It needs no model, so it
+// captures shared vocabulary (ransom note templates, reused phishing text)
+// rather than meaning.
+type HashEmbedder struct {
+	dims int
+}
+
+// NewHashEmbedder creates a feature-hashing embedder producing dims-sized vectors
+func NewHashEmbedder(dims int) *HashEmbedder {
+	return &HashEmbedder{dims: dims}
+}
+
+// Name implements Embedder
+func (h *HashEmbedder) Name() string { return "hash" }
+
+// Dimensions implements Embedder
+func (h *HashEmbedder) Dimensions() int { return h.dims }
+
+// Embed implements Embedder
+func (h *HashEmbedder) Embed(_ context.Context, text string) ([]float32, error) {
+	counts := make(map[string]int)
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for i, w := range words {
+		counts[w]++
+		if i > 0 {
+			counts[words[i-1]+" "+w]++
+		}
+	}
+
+	if len(counts) == 0 {
+		return nil, ErrNoContent
+	}
+
+	vec := make([]float64, h.dims)
+	for term, n := range counts {
+		hasher := fnv.New64a()
+		hasher.Write([]byte(term))
+		sum := hasher.Sum64()
+
+		weight := 1 + math.Log(float64(n))
+		if sum>>63 == 1 {
+			weight = -weight
+		}
+		vec[sum%uint64(h.dims)] += weight
+	}
+
+	var norm float64
+	for _, v := range vec {
+		norm += v * v
+	}
+	norm = math.Sqrt(norm)
+	if norm == 0 {
+		// Every term cancelled out; vanishingly rare but not searchable
+		return nil, ErrNoContent
+	}
+
+	out := make([]float32, h.dims)
+	for i, v := range vec {
+		out[i] = float32(v / norm)
+	}
+	return out, nil
+}
@@ -0,0 +1,167 @@
+package embed
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"tip-server/internal/config"
+	"tip-server/internal/db"
+	"tip-server/internal/models"
+)
+
+// snippetLength is the number of characters of content kept in each point
+// payload so search results can be previewed without fetching the file
+const snippetLength = 200
+
+// ErrNotText is returned when file content looks binary
+var ErrNotText = errors.New("content is not text")
+
+// Index stores file embeddings in a Qdrant collection and searches them
+type Index struct {
+	qdrant   *db.QdrantClient
+	embedder Embedder
+	maxBytes int
+}
+
+// NewIndex creates the similarity index and makes sure its collection
+// exists. It returns nil, nil when Qdrant is not enabled.
+func NewIndex(ctx context.Context, q *db.QdrantClient, cfg config.EmbeddingConfig) (*Index, error) {
+	if q == nil || !q.IsInitialized() {
+		return nil, nil
+	}
+
+	e, err := New(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if err := q.EnsureCollection(ctx, q.Collection(), uint64(e.Dimensions())); err != nil {
+		return nil, err
+	}
+
+	return &Index{qdrant: q, embedder: e, maxBytes: cfg.MaxBytes}, nil
+}
+
+// IndexFile embeds a stored file's content and upserts it under the file's
+// point ID, replacing any earlier vector for the same file
+func (x *Index) IndexFile(ctx context.Context, meta *models.FileMetadata, content []byte) error {
+	text, ok := Text(content, x.maxBytes)
+	if !ok {
+		return ErrNotText
+	}
+
+	vec, err := x.embedder.Embed(ctx, text)
+	if err != nil {
+		return err
+	}
+
+	payload := map[string]interface{}{
+		"file_id":      meta.FileID,
+		"file_path":    meta.FilePath,
+		"file_size":    int64(meta.FileSize),
+		"scan_status":  string(meta.ScanStatus),
+		"content_hash": meta.ContentHash,
+		"minio_key":    meta.MinIOKey,
+		"snippet":      snippet(text),
+		"embedder":     x.embedder.Name(),
+		"indexed_at":   time.Now().UTC().Format(time.RFC3339),
+	}
+
+	return x.qdrant.UpsertVectors(ctx, x.qdrant.Collection(),
+		[]uint64{db.VectorPointID(meta.FileID)}, [][]float32{vec}, []map[string]interface{}{payload})
+}
+
+// Search returns the stored files most similar to text
+func (x *Index) Search(ctx context.Context, text string, limit int, minScore float32) ([]models.SimilarFile, error) {
+	vec, err := x.embedder.Embed(ctx, text)
+	if err != nil {
+		return nil, err
+	}
+
+	results, err := x.qdrant.SearchSimilar(ctx, x.qdrant.Collection(), vec, uint64(limit), minScore)
+	if err != nil {
+		return nil, fmt.Errorf("similarity search failed: %w", err)
+	}
+
+	matches := make([]models.SimilarFile, 0, len(results))
+	for _, r := range results {
+		matches = append(matches, models.SimilarFile{
+			FileID:      payloadString(r.Payload, "file_id"),
+			FilePath:    payloadString(r.Payload, "file_path"),
+			Score:       r.Score,
+			ScanStatus:  models.ScanStatus(payloadString(r.Payload, "scan_status")),
+			ContentHash: payloadString(r.Payload, "content_hash"),
+			MinIOKey:    payloadString(r.Payload, "minio_key"),
+			Snippet:     payloadString(r.Payload, "snippet"),
+		})
+	}
+
+	return matches, nil
+}
+
+// IndexedFile is a file vector read back from the index
+type IndexedFile struct {
+	PointID uint64
+	Vector  []float32
+	File    models.ClusterMember
+}
+
+// Files returns up to limit indexed files with their vectors
+func (x *Index) Files(ctx context.Context, limit int) ([]IndexedFile, error) {
+	points, err := x.qdrant.ScrollVectors(ctx, x.qdrant.Collection(), limit)
+	if err != nil {
+		return nil, err
+	}
+
+	files := make([]IndexedFile, 0, len(points))
+	for _, p := range points {
+		if len(p.Vector) == 0 {
+			continue
+		}
+		files = append(files, IndexedFile{
+			PointID: p.ID,
+			Vector:  p.Vector,
+			File: models.ClusterMember{
+				FileID:     payloadString(p.Payload, "file_id"),
+				FilePath:   payloadString(p.Payload, "file_path"),
+				ScanStatus: models.ScanStatus(payloadString(p.Payload, "scan_status")),
+			},
+		})
+	}
+	return files, nil
+}
+
+// Neighbors returns the IDs of up to limit points scoring at least minScore
+// against vector
+func (x *Index) Neighbors(ctx context.Context, vector []float32, limit int, minScore float32) ([]uint64, error) {
+	results, err := x.qdrant.SearchSimilar(ctx, x.qdrant.Collection(), vector, uint64(limit), minScore)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]uint64, len(results))
+	for i, r := range results {
+		ids[i] = r.ID
+	}
+	return ids, nil
+}
+
+// snippet returns the start of text with whitespace collapsed
+func snippet(text string) string {
+	if len(text) > snippetLength*8 {
+		text = text[:snippetLength*8]
+	}
+	text = strings.Join(strings.Fields(text), " ")
+	if r := []rune(text); len(r) > snippetLength {
+		return string(r[:snippetLength])
+	}
+	return text
+}
+
+// payloadString reads a string payload field, returning "" when absent
+func payloadString(payload map[string]interface{}, key string) string {
+	s, _ := payload[key].(string)
+	return s
+}
@@ -0,0 +1,162 @@
+//go:build onnx
+
+// Building with -tags onnx requires cgo; the onnxruntime shared library is
+// loaded at run time from EMBEDDING_ONNX_RUNTIME. Default builds leave this
+// file and its cgo dependency out and stay pure Go.
+
+package embed
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"path/filepath"
+	"sync"
+
+	ort "github.com/yalue/onnxruntime_go"
+
+	"tip-server/internal/config"
+)
+
+var (
+	ortOnce sync.Once
+	ortErr  error
+)
+
+// ONNX embeds text with a local sentence-transformer model: the WordPiece
+// token sequence is run through the model and the last hidden state is
+// mean-pooled over the attention mask, then L2-normalised.
+type ONNX struct {
+	session   *ort.DynamicAdvancedSession
+	tokenizer *WordPiece
+	name      string
+	dims      int
+	maxTokens int
+	typeIDs   bool // model takes a token_type_ids input
+}
+
+// NewONNX loads the model and vocabulary named in cfg
+func NewONNX(cfg config.EmbeddingConfig) (Embedder, error) {
+	ortOnce.Do(func() {
+		if cfg.ONNXRuntime != "" {
+			ort.SetSharedLibraryPath(cfg.ONNXRuntime)
+		}
+		ortErr = ort.InitializeEnvironment()
+	})
+	if ortErr != nil {
+		return nil, fmt.Errorf("failed to initialize onnxruntime: %w", ortErr)
+	}
+
+	tokenizer, err := LoadWordPiece(cfg.ONNXVocab)
+	if err != nil {
+		return nil, err
+	}
+
+	inputs, outputs, err := ort.GetInputOutputInfo(cfg.ONNXModel)
+	if err != nil {
+		return nil, fmt.Errorf("failed to inspect model %s: %w", cfg.ONNXModel, err)
+	}
+	if len(outputs) == 0 {
+		return nil, fmt.Errorf("model %s has no outputs", cfg.ONNXModel)
+	}
+
+	inputNames := []string{"input_ids", "attention_mask"}
+	typeIDs := false
+	for _, in := range inputs {
+		if in.Name == "token_type_ids" {
+			inputNames = append(inputNames, in.Name)
+			typeIDs = true
+		}
+	}
+
+	session, err := ort.NewDynamicAdvancedSession(cfg.ONNXModel, inputNames, []string{outputs[0].Name}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load model %s: %w", cfg.ONNXModel, err)
+	}
+
+	return &ONNX{
+		session:   session,
+		tokenizer: tokenizer,
+		name:      "onnx:" + filepath.Base(cfg.ONNXModel),
+		dims:      cfg.Dimensions,
+		maxTokens: cfg.MaxTokens,
+		typeIDs:   typeIDs,
+	}, nil
+}
+
+// Name implements Embedder
+func (o *ONNX) Name() string { return o.name }
+
+// Dimensions implements Embedder
+func (o *ONNX) Dimensions() int { return o.dims }
+
+// Embed implements Embedder
+func (o *ONNX) Embed(_ context.Context, text string) ([]float32, error) {
+	ids := o.tokenizer.Encode(text, o.maxTokens)
+	if len(ids) <= 2 {
+		return nil, ErrNoContent
+	}
+
+	seq := int64(len(ids))
+	mask := make([]int64, seq)
+	for i := range mask {
+		mask[i] = 1
+	}
+	shape := ort.NewShape(1, seq)
+
+	idTensor, err := ort.NewTensor(shape, ids)
+	if err != nil {
+		return nil, err
+	}
+	defer idTensor.Destroy()
+	maskTensor, err := ort.NewTensor(shape, mask)
+	if err != nil {
+		return nil, err
+	}
+	defer maskTensor.Destroy()
+
+	inputs := []ort.Value{idTensor, maskTensor}
+	if o.typeIDs {
+		typeTensor, err := ort.NewTensor(shape, make([]int64, seq))
+		if err != nil {
+			return nil, err
+		}
+		defer typeTensor.Destroy()
+		inputs = append(inputs, typeTensor)
+	}
+
+	hidden, err := ort.NewEmptyTensor[float32](ort.NewShape(1, seq, int64(o.dims)))
+	if err != nil {
+		return nil, err
+	}
+	defer hidden.Destroy()
+
+	if err := o.session.Run(inputs, []ort.Value{hidden}); err != nil {
+		return nil, fmt.Errorf("onnx inference failed: %w", err)
+	}
+
+	// Mean pooling; every position is unmasked since inputs are not padded
+	data := hidden.GetData()
+	vec := make([]float64, o.dims)
+	for t := 0; t < int(seq); t++ {
+		for d := 0; d < o.dims; d++ {
+			vec[d] += float64(data[t*o.dims+d])
+		}
+	}
+
+	var normSq float64
+	for d := range vec {
+		vec[d] /= float64(seq)
+		normSq += vec[d] * vec[d]
+	}
+	n := math.Sqrt(normSq)
+	if n == 0 {
+		return nil, ErrNoContent
+	}
+
+	out := make([]float32, o.dims)
+	for d, v := range vec {
+		out[d] = float32(v / n)
+	}
+	return out, nil
+}
@@ -0,0 +1,15 @@
+//go:build !onnx
+
+package embed
+
+import (
+	"fmt"
+
+	"tip-server/internal/config"
+)
+
+// NewONNX is unavailable unless the binary is built with -tags onnx, which
+// links against the onnxruntime shared library
+func NewONNX(_ config.EmbeddingConfig) (Embedder, error) {
+	return nil, fmt.Errorf("onnx embedding provider requires a binary built with -tags onnx")
+}
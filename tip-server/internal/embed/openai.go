@@ -0,0 +1,92 @@
+package embed
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// OpenAI embeds text through an OpenAI-compatible /embeddings endpoint.
+// Ollama, vLLM, LocalAI and text-embeddings-inference expose the same API.
+type OpenAI struct {
+	endpoint string
+	model    string
+	apiKey   string
+	dims     int
+	client   *http.Client
+}
+
+// NewOpenAI creates an HTTP embedder for the API rooted at baseURL
+// (e.g. https://api.openai.com/v1)
+func NewOpenAI(baseURL, model, apiKey string, dims int, client *http.Client) *OpenAI {
+	return &OpenAI{
+		endpoint: strings.TrimSuffix(baseURL, "/") + "/embeddings",
+		model:    model,
+		apiKey:   apiKey,
+		dims:     dims,
+		client:   client,
+	}
+}
+
+// Name implements Embedder
+func (o *OpenAI) Name() string { return "openai:" + o.model }
+
+// Dimensions implements Embedder
+func (o *OpenAI) Dimensions() int { return o.dims }
+
+// Embed implements Embedder
+func (o *OpenAI) Embed(ctx context.Context, text string) ([]float32, error) {
+	if strings.TrimSpace(text) == "" {
+		return nil, ErrNoContent
+	}
+
+	body, err := json.Marshal(map[string]any{
+		"model": o.model,
+		"input": text,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, o.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if o.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+o.apiKey)
+	}
+
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("embedding request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("embedding endpoint returned status %d: %s", resp.StatusCode, bytes.TrimSpace(msg))
+	}
+
+	var out struct {
+		Data []struct {
+			Embedding []float32 `json:"embedding"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, fmt.Errorf("failed to decode embedding response: %w", err)
+	}
+	if len(out.Data) == 0 {
+		return nil, fmt.Errorf("embedding response contained no vectors")
+	}
+
+	vec := out.Data[0].Embedding
+	if len(vec) != o.dims {
+		return nil, fmt.Errorf("model %s returned %d dimensions, EMBEDDING_DIMENSIONS is %d", o.model, len(vec), o.dims)
+	}
+	return vec, nil
+}
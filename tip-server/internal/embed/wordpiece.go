@@ -0,0 +1,134 @@
+package embed
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Special tokens used by BERT-style vocabularies
+const (
+	tokenUnknown  = "[UNK]"
+	tokenClassify = "[CLS]"
+	tokenSeparate = "[SEP]"
+
+	// maxWordChars matches BERT: longer words become [UNK]
+	maxWordChars = 100
+)
+
+// WordPiece is an uncased BERT tokenizer, as used by most sentence-transformer
+// models exported to ONNX
+type WordPiece struct {
+	vocab map[string]int64
+	unk   int64
+	cls   int64
+	sep   int64
+}
+
+// LoadWordPiece reads a vocab.txt file with one token per line
+func LoadWordPiece(path string) (*WordPiece, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	vocab := make(map[string]int64)
+	scanner := bufio.NewScanner(f)
+	for id := int64(0); scanner.Scan(); id++ {
+		vocab[scanner.Text()] = id
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read vocabulary %s: %w", path, err)
+	}
+
+	wp := &WordPiece{vocab: vocab}
+	for token, id := range map[string]*int64{tokenUnknown: &wp.unk, tokenClassify: &wp.cls, tokenSeparate: &wp.sep} {
+		v, ok := vocab[token]
+		if !ok {
+			return nil, fmt.Errorf("vocabulary %s has no %s token", path, token)
+		}
+		*id = v
+	}
+
+	return wp, nil
+}
+
+// Encode returns token IDs wrapped in [CLS] ... [SEP], truncated to maxTokens
+func (w *WordPiece) Encode(text string, maxTokens int) []int64 {
+	ids := []int64{w.cls}
+	for _, word := range basicTokenize(text) {
+		for _, id := range w.wordPieces(word) {
+			if len(ids) >= maxTokens-1 {
+				return append(ids, w.sep)
+			}
+			ids = append(ids, id)
+		}
+	}
+	return append(ids, w.sep)
+}
+
+// wordPieces splits a word greedily into the longest vocabulary entries,
+// continuation pieces carrying a ## prefix
+func (w *WordPiece) wordPieces(word string) []int64 {
+	runes := []rune(word)
+	if len(runes) > maxWordChars {
+		return []int64{w.unk}
+	}
+
+	var ids []int64
+	for start := 0; start < len(runes); {
+		end := len(runes)
+		found := false
+		for ; end > start; end-- {
+			piece := string(runes[start:end])
+			if start > 0 {
+				piece = "##" + piece
+			}
+			if id, ok := w.vocab[piece]; ok {
+				ids = append(ids, id)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return []int64{w.unk}
+		}
+		start = end
+	}
+	return ids
+}
+
+// basicTokenize lowercases, strips accents and splits on whitespace,
+// punctuation and CJK characters
+func basicTokenize(text string) []string {
+	var words []string
+	var b strings.Builder
+	flush := func() {
+		if b.Len() > 0 {
+			words = append(words, b.String())
+			b.Reset()
+		}
+	}
+
+	for _, r := range norm.NFD.String(strings.ToLower(text)) {
+		switch {
+		case unicode.Is(unicode.Mn, r):
+			// Combining accent left behind by NFD
+		case unicode.IsSpace(r) || unicode.IsControl(r):
+			flush()
+		case unicode.IsPunct(r) || unicode.IsSymbol(r) || unicode.Is(unicode.Han, r):
+			flush()
+			words = append(words, string(r))
+		default:
+			b.WriteRune(r)
+		}
+	}
+	flush()
+
+	return words
+}
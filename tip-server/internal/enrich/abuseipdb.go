@@ -0,0 +1,82 @@
+package enrich
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"tip-server/internal/models"
+)
+
+const abuseIPDBCheckURL = "https://api.abuseipdb.com/api/v2/check"
+
+// AbuseIPDB looks up abuse confidence scores for IP addresses
+type AbuseIPDB struct {
+	apiKey string
+	client *http.Client
+}
+
+// NewAbuseIPDB creates an AbuseIPDB provider
+func NewAbuseIPDB(apiKey string, client *http.Client) *AbuseIPDB {
+	return &AbuseIPDB{apiKey: apiKey, client: client}
+}
+
+// Name implements Provider
+func (a *AbuseIPDB) Name() string { return "abuseipdb" }
+
+// Supports implements Provider
+func (a *AbuseIPDB) Supports(t models.IOCType) bool {
+	return t == models.IOCTypeIPv4 || t == models.IOCTypeIPv6
+}
+
+// Lookup implements Provider
+func (a *AbuseIPDB) Lookup(ctx context.Context, t models.IOCType, value string) (*models.Reputation, error) {
+	query := url.Values{}
+	query.Set("ipAddress", value)
+	query.Set("maxAgeInDays", "90")
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, abuseIPDBCheckURL+"?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Key", a.apiKey)
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("abuseipdb request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("abuseipdb returned status %d", resp.StatusCode)
+	}
+
+	var body struct {
+		Data struct {
+			AbuseConfidenceScore int `json:"abuseConfidenceScore"`
+			TotalReports         int `json:"totalReports"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("failed to decode abuseipdb response: %w", err)
+	}
+
+	if body.Data.TotalReports == 0 {
+		return nil, nil
+	}
+
+	score := body.Data.AbuseConfidenceScore
+	if score < 0 || score > 100 {
+		score = 0
+	}
+	return &models.Reputation{
+		Provider:  a.Name(),
+		Score:     uint8(score),
+		Reports:   body.Data.TotalReports,
+		CheckedAt: time.Now().UTC(),
+	}, nil
+}
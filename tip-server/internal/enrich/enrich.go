@@ -0,0 +1,172 @@
+// Package enrich looks matched IOCs up in external reputation services
+// (VirusTotal, AbuseIPDB). Verdicts are cached in Redis and lookups are
+// rate limited per provider across all API instances, since provider
+// quotas are small.
+package enrich
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/rs/zerolog/log"
+
+	"tip-server/internal/config"
+	"tip-server/internal/db"
+	"tip-server/internal/metrics"
+	"tip-server/internal/models"
+)
+
+// Provider is an external reputation service
+type Provider interface {
+	Name() string
+	Supports(t models.IOCType) bool
+
+	// Lookup returns the provider's verdict, or nil if it has no record of value
+	Lookup(ctx context.Context, t models.IOCType, value string) (*models.Reputation, error)
+}
+
+// limitedProvider pairs a provider with its per-minute quota
+type limitedProvider struct {
+	Provider
+	limit int
+}
+
+// cachedVerdict is the cache entry; Reputation is nil for "unknown"
+type cachedVerdict struct {
+	Reputation *models.Reputation `json:"reputation"`
+}
+
+// Enricher fans lookups out to the configured providers
+type Enricher struct {
+	cfg       config.EnrichmentConfig
+	redis     db.Cache
+	metrics   *metrics.Metrics
+	providers []limitedProvider
+}
+
+// New creates an enricher for the providers with API keys. Returns nil when
+// none are configured.
+func New(cfg config.EnrichmentConfig, redis db.Cache) *Enricher {
+	if !cfg.Enabled() {
+		return nil
+	}
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	e := &Enricher{
+		cfg:     cfg,
+		redis:   redis,
+		metrics: metrics.GetMetrics(),
+	}
+	if cfg.VirusTotalAPIKey != "" {
+		e.providers = append(e.providers, limitedProvider{NewVirusTotal(cfg.VirusTotalAPIKey, client), cfg.VirusTotalRateLimit})
+	}
+	if cfg.AbuseIPDBAPIKey != "" {
+		e.providers = append(e.providers, limitedProvider{NewAbuseIPDB(cfg.AbuseIPDBAPIKey, client), cfg.AbuseIPDBRateLimit})
+	}
+	return e
+}
+
+// Enrich looks up to MaxPerRequest IOCs in every supporting provider and
+// returns verdicts keyed by IOC value. Lookups still running when ctx ends
+// are abandoned; whatever completed is returned.
+func (e *Enricher) Enrich(ctx context.Context, iocs []models.IOC) map[string][]models.Reputation {
+	if len(iocs) > e.cfg.MaxPerRequest {
+		iocs = iocs[:e.cfg.MaxPerRequest]
+	}
+
+	var (
+		mu      sync.Mutex
+		wg      sync.WaitGroup
+		results = make(map[string][]models.Reputation)
+	)
+
+	for _, ioc := range iocs {
+		for _, p := range e.providers {
+			if !p.Supports(ioc.Type) {
+				continue
+			}
+
+			wg.Add(1)
+			go func(p limitedProvider, ioc models.IOC) {
+				defer wg.Done()
+
+				rep := e.lookup(ctx, p, ioc)
+				if rep == nil {
+					return
+				}
+				mu.Lock()
+				results[ioc.Value] = append(results[ioc.Value], *rep)
+				mu.Unlock()
+			}(p, ioc)
+		}
+	}
+
+	wg.Wait()
+	return results
+}
+
+// lookup returns a cached verdict or fetches one within the provider's quota
+func (e *Enricher) lookup(ctx context.Context, p limitedProvider, ioc models.IOC) *models.Reputation {
+	name := p.Name()
+	key := db.EnrichmentCacheKey(name, ioc.Value)
+
+	var cached cachedVerdict
+	err := e.redis.GetJSON(ctx, key, &cached)
+	if err == nil {
+		e.metrics.EnrichmentLookups.WithLabelValues(name, "cached").Inc()
+		return cached.Reputation
+	}
+	if !errors.Is(err, redis.Nil) {
+		log.Debug().Err(err).Str("provider", name).Msg("Enrichment cache read failed")
+	}
+
+	_, exceeded, err := e.redis.IncrementEnrichmentRateLimit(ctx, name, p.limit, time.Minute)
+	if err != nil || exceeded {
+		e.metrics.EnrichmentLookups.WithLabelValues(name, "throttled").Inc()
+		return nil
+	}
+
+	start := time.Now()
+	rep, err := p.Lookup(ctx, ioc.Type, ioc.Value)
+	e.metrics.EnrichmentLatency.WithLabelValues(name).Observe(time.Since(start).Seconds())
+	if err != nil {
+		e.metrics.EnrichmentLookups.WithLabelValues(name, "error").Inc()
+		log.Warn().Err(err).Str("provider", name).Str("ioc", ioc.Value).Msg("Enrichment lookup failed")
+		return nil
+	}
+
+	if rep == nil {
+		e.metrics.EnrichmentLookups.WithLabelValues(name, "unknown").Inc()
+	} else {
+		e.metrics.EnrichmentLookups.WithLabelValues(name, "fetched").Inc()
+	}
+
+	// Cache with a fresh context so a request timeout doesn't discard a paid-for lookup
+	cacheCtx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := e.redis.SetJSON(cacheCtx, key, cachedVerdict{Reputation: rep}, e.cfg.CacheTTL); err != nil {
+		log.Debug().Err(err).Str("provider", name).Msg("Enrichment cache write failed")
+	}
+
+	return rep
+}
+
+// AdjustConfidence blends the strongest provider score into a confidence
+// value: the result moves halfway from base towards that score
+func AdjustConfidence(base uint8, reps []models.Reputation) uint8 {
+	if len(reps) == 0 {
+		return base
+	}
+
+	var best uint8
+	for _, r := range reps {
+		if r.Score > best {
+			best = r.Score
+		}
+	}
+	return uint8((int(base) + int(best)) / 2)
+}
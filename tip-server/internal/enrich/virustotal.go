@@ -0,0 +1,103 @@
+package enrich
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"tip-server/internal/models"
+)
+
+const virusTotalBaseURL = "https://www.virustotal.com/api/v3"
+
+// VirusTotal looks up detection counts from the VirusTotal v3 API
+type VirusTotal struct {
+	apiKey string
+	client *http.Client
+}
+
+// NewVirusTotal creates a VirusTotal provider
+func NewVirusTotal(apiKey string, client *http.Client) *VirusTotal {
+	return &VirusTotal{apiKey: apiKey, client: client}
+}
+
+// Name implements Provider
+func (v *VirusTotal) Name() string { return "virustotal" }
+
+// Supports implements Provider
+func (v *VirusTotal) Supports(t models.IOCType) bool {
+	return v.endpoint(t, "") != ""
+}
+
+// endpoint returns the object URL for a value, or "" if the type is unsupported
+func (v *VirusTotal) endpoint(t models.IOCType, value string) string {
+	switch t {
+	case models.IOCTypeIPv4, models.IOCTypeIPv6:
+		return virusTotalBaseURL + "/ip_addresses/" + url.PathEscape(value)
+	case models.IOCTypeDomain:
+		return virusTotalBaseURL + "/domains/" + url.PathEscape(value)
+	case models.IOCTypeMD5, models.IOCTypeSHA1, models.IOCTypeSHA256:
+		return virusTotalBaseURL + "/files/" + url.PathEscape(value)
+	case models.IOCTypeURL:
+		// URL identifiers are the unpadded base64url encoding of the URL
+		return virusTotalBaseURL + "/urls/" + base64.RawURLEncoding.EncodeToString([]byte(value))
+	}
+	return ""
+}
+
+// Lookup implements Provider
+func (v *VirusTotal) Lookup(ctx context.Context, t models.IOCType, value string) (*models.Reputation, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, v.endpoint(t, value), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("x-apikey", v.apiKey)
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("virustotal request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, nil
+	case resp.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("virustotal returned status %d", resp.StatusCode)
+	}
+
+	var body struct {
+		Data struct {
+			Attributes struct {
+				LastAnalysisStats struct {
+					Malicious  int `json:"malicious"`
+					Suspicious int `json:"suspicious"`
+					Harmless   int `json:"harmless"`
+					Undetected int `json:"undetected"`
+				} `json:"last_analysis_stats"`
+			} `json:"attributes"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("failed to decode virustotal response: %w", err)
+	}
+
+	stats := body.Data.Attributes.LastAnalysisStats
+	engines := stats.Malicious + stats.Suspicious + stats.Harmless + stats.Undetected
+
+	rep := &models.Reputation{
+		Provider:  v.Name(),
+		Malicious: stats.Malicious,
+		Engines:   engines,
+		CheckedAt: time.Now().UTC(),
+	}
+	if engines > 0 {
+		rep.Score = uint8(stats.Malicious * 100 / engines)
+	}
+	return rep, nil
+}
@@ -0,0 +1,259 @@
+package enrich
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/rs/zerolog/log"
+	"golang.org/x/net/publicsuffix"
+
+	"tip-server/internal/config"
+	"tip-server/internal/db"
+	"tip-server/internal/metrics"
+	"tip-server/internal/models"
+)
+
+// whoisProvider names WHOIS/RDAP lookups in cache keys, rate limits and metrics
+const whoisProvider = "whois"
+
+// whoisUnknownTTL caches "no registration date" briefly; registries without
+// RDAP or transient gaps shouldn't hide a domain's age for the full TTL
+const whoisUnknownTTL = 24 * time.Hour
+
+// DomainAge looks up domain registration (creation) dates over RDAP, the
+// structured successor to port-43 WHOIS. The registrar, nameservers and
+// registrant email of each fetched record are kept in ClickHouse as WHOIS
+// relationships.
+type DomainAge struct {
+	cfg     config.EnrichmentConfig
+	redis   db.Cache
+	ch      db.IOCStore
+	metrics *metrics.Metrics
+	client  *http.Client
+}
+
+// registration is the cache entry; Created is zero when unknown
+type registration struct {
+	Created         time.Time `json:"created"`
+	Registrar       string    `json:"registrar,omitempty"`
+	Nameservers     []string  `json:"nameservers,omitempty"`
+	RegistrantEmail string    `json:"registrant_email,omitempty"`
+}
+
+// NewDomainAge creates a registration date lookup. Returns nil when WHOIS
+// lookups are disabled.
+func NewDomainAge(cfg config.EnrichmentConfig, redis db.Cache, ch db.IOCStore) *DomainAge {
+	if !cfg.WhoisEnabled {
+		return nil
+	}
+	return &DomainAge{
+		cfg:     cfg,
+		redis:   redis,
+		ch:      ch,
+		metrics: metrics.GetMetrics(),
+		client:  &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Registered returns when the registrable domain of host was created, or the
+// zero time if unknown (or the lookup was throttled)
+func (d *DomainAge) Registered(ctx context.Context, host string) time.Time {
+	domain, err := publicsuffix.EffectiveTLDPlusOne(strings.TrimSuffix(strings.ToLower(host), "."))
+	if err != nil {
+		return time.Time{}
+	}
+	key := db.EnrichmentCacheKey(whoisProvider, domain)
+
+	var cached registration
+	err = d.redis.GetJSON(ctx, key, &cached)
+	if err == nil {
+		d.metrics.EnrichmentLookups.WithLabelValues(whoisProvider, "cached").Inc()
+		return cached.Created
+	}
+	if !errors.Is(err, redis.Nil) {
+		log.Debug().Err(err).Msg("WHOIS cache read failed")
+	}
+
+	_, exceeded, err := d.redis.IncrementEnrichmentRateLimit(ctx, whoisProvider, d.cfg.WhoisRateLimit, time.Minute)
+	if err != nil || exceeded {
+		d.metrics.EnrichmentLookups.WithLabelValues(whoisProvider, "throttled").Inc()
+		return time.Time{}
+	}
+
+	start := time.Now()
+	reg, err := d.lookup(ctx, domain)
+	d.metrics.EnrichmentLatency.WithLabelValues(whoisProvider).Observe(time.Since(start).Seconds())
+	if err != nil {
+		d.metrics.EnrichmentLookups.WithLabelValues(whoisProvider, "error").Inc()
+		log.Warn().Err(err).Str("domain", domain).Msg("WHOIS lookup failed")
+		return time.Time{}
+	}
+
+	ttl := d.cfg.WhoisCacheTTL
+	if reg.Created.IsZero() {
+		d.metrics.EnrichmentLookups.WithLabelValues(whoisProvider, "unknown").Inc()
+		ttl = min(ttl, whoisUnknownTTL)
+	} else {
+		d.metrics.EnrichmentLookups.WithLabelValues(whoisProvider, "fetched").Inc()
+	}
+
+	cacheCtx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := d.redis.SetJSON(cacheCtx, key, reg, ttl); err != nil {
+		log.Debug().Err(err).Msg("WHOIS cache write failed")
+	}
+
+	storeCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := d.ch.InsertWhoisRelationships(storeCtx, reg.relationships(domain)); err != nil {
+		log.Warn().Err(err).Str("domain", domain).Msg("Failed to store WHOIS relationships")
+	}
+
+	return reg.Created
+}
+
+// relationships returns the registration's registrar, nameservers and
+// registrant email as relationships of domain
+func (r registration) relationships(domain string) []models.WhoisRelationship {
+	now := time.Now().UTC()
+	var rels []models.WhoisRelationship
+	add := func(relation, value string) {
+		if value != "" {
+			rels = append(rels, models.WhoisRelationship{
+				Domain:     domain,
+				Relation:   relation,
+				Value:      value,
+				ObservedAt: now,
+			})
+		}
+	}
+
+	add(models.WhoisRelationRegistrar, r.Registrar)
+	for _, ns := range r.Nameservers {
+		add(models.WhoisRelationNameserver, ns)
+	}
+	add(models.WhoisRelationRegistrantEmail, r.RegistrantEmail)
+	return rels
+}
+
+// NewlyRegistered reports whether created falls within the configured NRD window
+func (d *DomainAge) NewlyRegistered(created time.Time) bool {
+	return !created.IsZero() && time.Since(created) <= time.Duration(d.cfg.NRDDays)*24*time.Hour
+}
+
+// rdapEntity is a contact in an RDAP record; registrars nest their abuse
+// contacts as entities of their own
+type rdapEntity struct {
+	Roles    []string          `json:"roles"`
+	VCard    []json.RawMessage `json:"vcardArray"`
+	Entities []rdapEntity      `json:"entities"`
+}
+
+// lookup fetches the RDAP record and returns its registration event date,
+// registrar, nameservers and registrant email
+func (d *DomainAge) lookup(ctx context.Context, domain string) (registration, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, d.cfg.WhoisRDAPURL+url.PathEscape(domain), nil)
+	if err != nil {
+		return registration{}, err
+	}
+	req.Header.Set("Accept", "application/rdap+json")
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return registration{}, fmt.Errorf("rdap request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return registration{}, nil
+	case resp.StatusCode != http.StatusOK:
+		return registration{}, fmt.Errorf("rdap returned status %d", resp.StatusCode)
+	}
+
+	var body struct {
+		Events []struct {
+			Action string    `json:"eventAction"`
+			Date   time.Time `json:"eventDate"`
+		} `json:"events"`
+		Entities    []rdapEntity `json:"entities"`
+		Nameservers []struct {
+			Name string `json:"ldhName"`
+		} `json:"nameservers"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return registration{}, fmt.Errorf("failed to decode rdap response: %w", err)
+	}
+
+	var reg registration
+	for _, e := range body.Events {
+		if e.Action == "registration" {
+			reg.Created = e.Date.UTC()
+			break
+		}
+	}
+	for _, ns := range body.Nameservers {
+		if name := strings.TrimSuffix(strings.ToLower(ns.Name), "."); name != "" && !slices.Contains(reg.Nameservers, name) {
+			reg.Nameservers = append(reg.Nameservers, name)
+		}
+	}
+	for _, e := range body.Entities {
+		switch {
+		case slices.Contains(e.Roles, "registrar") && reg.Registrar == "":
+			reg.Registrar = strings.TrimSpace(vcardField(e.VCard, "fn"))
+		case slices.Contains(e.Roles, "registrant") && reg.RegistrantEmail == "":
+			reg.RegistrantEmail = registrantEmail(vcardField(e.VCard, "email"))
+		}
+	}
+	return reg, nil
+}
+
+// vcardField returns the text value of the first property called name in a
+// jCard (RFC 7095): ["vcard", [[name, params, type, value], ...]]
+func vcardField(vcard []json.RawMessage, name string) string {
+	if len(vcard) < 2 {
+		return ""
+	}
+	var props [][]json.RawMessage
+	if err := json.Unmarshal(vcard[1], &props); err != nil {
+		return ""
+	}
+
+	for _, prop := range props {
+		if len(prop) < 4 {
+			continue
+		}
+		var propName, value string
+		if json.Unmarshal(prop[0], &propName) != nil || propName != name {
+			continue
+		}
+		if json.Unmarshal(prop[3], &value) == nil {
+			return value
+		}
+	}
+	return ""
+}
+
+// registrantEmail normalises a registrant email, returning "" for redaction
+// placeholders and privacy-proxy addresses, which are shared by unrelated
+// domains and would link them all together
+func registrantEmail(s string) string {
+	addr, err := mail.ParseAddress(strings.TrimPrefix(strings.TrimSpace(s), "mailto:"))
+	if err != nil {
+		return ""
+	}
+	email := strings.ToLower(addr.Address)
+	if strings.Contains(email, "redacted") || strings.Contains(email, "privacy") {
+		return ""
+	}
+	return email
+}
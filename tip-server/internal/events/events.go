@@ -0,0 +1,118 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"tip-server/internal/config"
+	"tip-server/internal/models"
+)
+
+// Event types published on the bus
+const (
+	TypeFileProcessed = "file_processed"
+	TypeIOCIngested   = models.MatchKindIngested
+	TypeCheckHit      = models.MatchKindCheckHit
+	TypeLogHit        = models.MatchKindLogHit
+)
+
+// Message is a single event to publish
+type Message struct {
+	Type string // Event type (one of the Type* constants)
+	Key  string // Partitioning key (IOC value or file ID)
+	Data any    // Payload, marshalled as JSON
+}
+
+// Envelope is the JSON document written to the bus for every event
+type Envelope struct {
+	Event     string    `json:"event"`
+	Timestamp time.Time `json:"timestamp"`
+	Data      any       `json:"data"`
+}
+
+// Publisher sends events to an external bus
+type Publisher interface {
+	Publish(ctx context.Context, msgs ...Message) error
+	Close() error
+}
+
+// TopicPublisher sends events to a topic named per call instead of the
+// configured one (Kafka only)
+type TopicPublisher interface {
+	PublishTopic(ctx context.Context, topic string, msgs ...Message) error
+}
+
+// Handler processes one message received from the bus. Returning an error
+// leaves the message uncommitted where the bus supports redelivery.
+type Handler func(ctx context.Context, payload []byte) error
+
+// Subscriber consumes messages from an external bus
+type Subscriber interface {
+	// Consume delivers messages to handler until ctx is cancelled
+	Consume(ctx context.Context, handler Handler) error
+	Close() error
+}
+
+// NewPublisher creates a publisher for the configured bus. A no-op publisher
+// is returned when no bus is configured.
+func NewPublisher(cfg config.EventBusConfig) (Publisher, error) {
+	switch cfg.Type {
+	case "":
+		return noopPublisher{}, nil
+	case "kafka":
+		return newKafkaPublisher(cfg), nil
+	case "nats":
+		return newNATSPublisher(cfg)
+	default:
+		return nil, fmt.Errorf("unsupported EVENT_BUS_TYPE %q", cfg.Type)
+	}
+}
+
+// NewSubscriber creates a consumer for the configured submission topic.
+// Returns nil when no bus or submission topic is configured.
+func NewSubscriber(cfg config.EventBusConfig) (Subscriber, error) {
+	if cfg.SubmissionTopic == "" {
+		return nil, nil
+	}
+
+	switch cfg.Type {
+	case "":
+		return nil, nil
+	case "kafka":
+		return newKafkaSubscriber(cfg), nil
+	case "nats":
+		return newNATSSubscriber(cfg)
+	default:
+		return nil, fmt.Errorf("unsupported EVENT_BUS_TYPE %q", cfg.Type)
+	}
+}
+
+// MatchMessages converts match events into bus messages keyed by IOC value
+func MatchMessages(matches []models.MatchEvent) []Message {
+	msgs := make([]Message, len(matches))
+	for i, m := range matches {
+		msgs[i] = Message{Type: m.Kind, Key: m.Value, Data: m}
+	}
+	return msgs
+}
+
+// encode wraps a message in an envelope and marshals it
+func encode(msg Message) ([]byte, error) {
+	payload, err := json.Marshal(Envelope{
+		Event:     msg.Type,
+		Timestamp: time.Now().UTC(),
+		Data:      msg.Data,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal %s event: %w", msg.Type, err)
+	}
+	return payload, nil
+}
+
+// noopPublisher discards events when no bus is configured
+type noopPublisher struct{}
+
+func (noopPublisher) Publish(context.Context, ...Message) error { return nil }
+func (noopPublisher) Close() error                              { return nil }
@@ -0,0 +1,120 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+
+	"tip-server/internal/config"
+)
+
+// kafkaPublisher writes events to a single Kafka topic, or to topics named
+// per call (subscriptions). The event type is carried in the "event" header
+// and the envelope.
+type kafkaPublisher struct {
+	writer *kafka.Writer
+	topics *kafka.Writer // No default topic; each message names its own
+}
+
+func newKafkaPublisher(cfg config.EventBusConfig) *kafkaPublisher {
+	return &kafkaPublisher{
+		writer: newKafkaWriter(cfg, cfg.Topic),
+		topics: newKafkaWriter(cfg, ""),
+	}
+}
+
+func newKafkaWriter(cfg config.EventBusConfig, topic string) *kafka.Writer {
+	return &kafka.Writer{
+		Addr:                   kafka.TCP(cfg.Brokers...),
+		Topic:                  topic,
+		Balancer:               &kafka.Hash{},
+		RequiredAcks:           kafka.RequireOne,
+		BatchTimeout:           10 * time.Millisecond,
+		AllowAutoTopicCreation: true,
+	}
+}
+
+// Publish writes all messages in one batch
+func (p *kafkaPublisher) Publish(ctx context.Context, msgs ...Message) error {
+	return p.write(ctx, p.writer, "", msgs)
+}
+
+// PublishTopic writes all messages to topic in one batch
+func (p *kafkaPublisher) PublishTopic(ctx context.Context, topic string, msgs ...Message) error {
+	return p.write(ctx, p.topics, topic, msgs)
+}
+
+func (p *kafkaPublisher) write(ctx context.Context, w *kafka.Writer, topic string, msgs []Message) error {
+	if len(msgs) == 0 {
+		return nil
+	}
+
+	records := make([]kafka.Message, 0, len(msgs))
+	for _, msg := range msgs {
+		payload, err := encode(msg)
+		if err != nil {
+			return err
+		}
+		records = append(records, kafka.Message{
+			Topic:   topic,
+			Key:     []byte(msg.Key),
+			Value:   payload,
+			Headers: []kafka.Header{{Key: "event", Value: []byte(msg.Type)}},
+		})
+	}
+
+	if err := w.WriteMessages(ctx, records...); err != nil {
+		return fmt.Errorf("failed to write to Kafka: %w", err)
+	}
+	return nil
+}
+
+// Close flushes pending writes and closes connections
+func (p *kafkaPublisher) Close() error {
+	return errors.Join(p.writer.Close(), p.topics.Close())
+}
+
+// kafkaSubscriber consumes the submission topic as part of a consumer group
+type kafkaSubscriber struct {
+	reader *kafka.Reader
+}
+
+func newKafkaSubscriber(cfg config.EventBusConfig) *kafkaSubscriber {
+	return &kafkaSubscriber{
+		reader: kafka.NewReader(kafka.ReaderConfig{
+			Brokers: cfg.Brokers,
+			GroupID: cfg.ConsumerGroup,
+			Topic:   cfg.SubmissionTopic,
+		}),
+	}
+}
+
+// Consume commits each message once handler succeeds; failed messages are
+// redelivered after a restart or rebalance
+func (s *kafkaSubscriber) Consume(ctx context.Context, handler Handler) error {
+	for {
+		msg, err := s.reader.FetchMessage(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("failed to fetch from Kafka: %w", err)
+		}
+
+		if err := handler(ctx, msg.Value); err != nil {
+			return fmt.Errorf("failed to handle message at offset %d: %w", msg.Offset, err)
+		}
+
+		if err := s.reader.CommitMessages(ctx, msg); err != nil && ctx.Err() == nil {
+			return fmt.Errorf("failed to commit Kafka offset: %w", err)
+		}
+	}
+}
+
+// Close leaves the consumer group
+func (s *kafkaSubscriber) Close() error {
+	return s.reader.Close()
+}
@@ -0,0 +1,97 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/nats-io/nats.go"
+	"github.com/rs/zerolog/log"
+
+	"tip-server/internal/config"
+)
+
+// natsPublisher publishes each event on "<topic>.<event type>" so listeners
+// can subscribe to a single kind with a subject filter
+type natsPublisher struct {
+	conn  *nats.Conn
+	topic string
+}
+
+func newNATSPublisher(cfg config.EventBusConfig) (*natsPublisher, error) {
+	conn, err := connectNATS(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &natsPublisher{conn: conn, topic: cfg.Topic}, nil
+}
+
+// Publish sends all messages; NATS core publishing is fire-and-forget
+func (p *natsPublisher) Publish(ctx context.Context, msgs ...Message) error {
+	for _, msg := range msgs {
+		payload, err := encode(msg)
+		if err != nil {
+			return err
+		}
+		if err := p.conn.Publish(p.topic+"."+msg.Type, payload); err != nil {
+			return fmt.Errorf("failed to publish to NATS: %w", err)
+		}
+	}
+	return nil
+}
+
+// Close flushes buffered messages and closes the connection
+func (p *natsPublisher) Close() error {
+	return p.conn.Drain()
+}
+
+// natsSubscriber consumes the submission subject as a queue group member so
+// several API instances share the load
+type natsSubscriber struct {
+	conn    *nats.Conn
+	subject string
+	queue   string
+}
+
+func newNATSSubscriber(cfg config.EventBusConfig) (*natsSubscriber, error) {
+	conn, err := connectNATS(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &natsSubscriber{conn: conn, subject: cfg.SubmissionTopic, queue: cfg.ConsumerGroup}, nil
+}
+
+// Consume delivers messages until ctx is cancelled. NATS core has no
+// redelivery, so handler errors are logged and the message is dropped.
+func (s *natsSubscriber) Consume(ctx context.Context, handler Handler) error {
+	sub, err := s.conn.QueueSubscribe(s.subject, s.queue, func(msg *nats.Msg) {
+		if err := handler(ctx, msg.Data); err != nil {
+			log.Error().Err(err).Str("subject", msg.Subject).Msg("Failed to handle NATS message")
+		}
+	})
+	if err != nil {
+		return fmt.Errorf("failed to subscribe to %s: %w", s.subject, err)
+	}
+
+	<-ctx.Done()
+	return sub.Drain()
+}
+
+// Close closes the connection after pending messages are handled
+func (s *natsSubscriber) Close() error {
+	return s.conn.Drain()
+}
+
+// connectNATS dials the configured servers with automatic reconnects
+func connectNATS(cfg config.EventBusConfig) (*nats.Conn, error) {
+	conn, err := nats.Connect(
+		strings.Join(cfg.Brokers, ","),
+		nats.Name("tip-server"),
+		nats.MaxReconnects(-1),
+		nats.RetryOnFailedConnect(true),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to NATS: %w", err)
+	}
+	return conn, nil
+}
@@ -0,0 +1,261 @@
+package extractor
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"tip-server/internal/config"
+)
+
+// Length limits for behavioural indicators
+const (
+	maxMutexLength     = 260 // MAX_PATH, the longest kernel object name Win32 accepts
+	maxUserAgentLength = 512
+	minBehaviorLength  = 4 // Shorter names are too generic to act on
+)
+
+var (
+	// Mutex named after a "mutex:" / "mutexes =" label in a report
+	mutexLabelPattern = regexp.MustCompile(`(?i)\bmutex(?:es)?["']?[ \t]*[:=][ \t]*["']?([^\s"',;<>\[\]]{4,260})`)
+
+	// Mutex opened or created by an API call in a behaviour trace
+	mutexAPIPattern = regexp.MustCompile(`(?i)\b(?:Create|Open)Mutex(?:Ex)?[AW]?\s*\([^)"]*"([^"\r\n]{4,260})"`)
+
+	// Mutex lists in sandbox JSON reports, e.g. "mutexes": ["a", "b"]
+	mutexListPattern = regexp.MustCompile(`(?i)"mutex(?:es)?"\s*:\s*\[([^\]]*)\]`)
+
+	// User-Agent request header, as in HTTP dumps and reports
+	userAgentHeaderPattern = regexp.MustCompile(`(?im)^[ \t]*user-agent:[ \t]*([^\r\n]{4,512}?)[ \t]*\r?$`)
+
+	// User agent fields in JSON sandbox and sensor output
+	userAgentFieldPattern = regexp.MustCompile(`(?i)"(?:http_)?user[-_]?agent"\s*:\s*"((?:[^"\\\r\n]|\\.){4,512})"`)
+
+	// jsonStringPattern matches the string literals of a JSON array
+	jsonStringPattern = regexp.MustCompile(`"((?:[^"\\]|\\.)*)"`)
+
+	// browserUserAgentPattern matches mainstream browser user agents, which
+	// reports quote as context far more often than as indicators
+	browserUserAgentPattern = regexp.MustCompile(`^Mozilla/5\.0 \([^)]*\) .*(?:Chrome|Firefox|Safari|Edg|OPR)/[0-9.]+$`)
+)
+
+// knownMutexes are mutex names of well-known malware families. A trailing
+// "*" matches any suffix of word characters, for families that append a
+// per-build identifier.
+var knownMutexes = []string{
+	")!VoqA.I4",                             // Poison Ivy
+	"DC_MUTEX-*",                            // DarkComet
+	"DCPERSFWBP",                            // DarkComet persistence
+	"QSR_MUTEX_*",                           // Quasar RAT
+	"AsyncMutex_6SI8OkPnk",                  // AsyncRAT default build
+	"Remcos_Mutex_Inj",                      // Remcos
+	"Global\\MsWinZonesCacheCounterMutexA*", // WannaCry
+	"_AVIRA_2109",                           // Zeus
+	"_AVIRA_2108",                           // Zeus
+}
+
+// suspiciousUserAgents are lowercase substrings of user agents sent by
+// scanners, exploit tooling and malware with hardcoded headers
+var suspiciousUserAgents = []string{
+	"${jndi:", // Log4Shell probes
+	"() {",    // Shellshock probes
+	"sqlmap",
+	"nikto",
+	"masscan",
+	"zgrab",
+	"nmap scripting engine",
+	"nuclei",
+	"wpscan",
+	"havij",
+	"acunetix",
+	"netsparker",
+	"morfeus",
+	"zmeu",
+	"mozilla/4.0 (compatible; msie 6.0; windows nt 5.1)",
+	"mozilla/4.0 (compatible; msie 7.0; windows nt 5.1)",
+}
+
+// curatedLists are the known-bad mutexes and user agents an extractor
+// matches, built-in entries first
+type curatedLists struct {
+	mutexes    []string
+	userAgents []string
+}
+
+// NewExtractorFromConfig creates an extractor with the configured limits and
+// allow/deny and popularity lists, and the built-in curated lists extended by
+// EXTRACT_MUTEX_LIST and EXTRACT_USER_AGENT_LIST
+func NewExtractorFromConfig(cfg config.ExtractorConfig) (*Extractor, error) {
+	e := NewExtractorWithLimits(LimitsFromConfig(cfg))
+	e.listCfg = cfg
+	if err := e.ReloadLists(); err != nil {
+		return nil, err
+	}
+
+	mutexes, err := readList(cfg.MutexListFile)
+	if err != nil {
+		return nil, err
+	}
+	userAgents, err := readList(cfg.UserAgentListFile)
+	if err != nil {
+		return nil, err
+	}
+
+	e.curated.mutexes = append(e.curated.mutexes, mutexes...)
+	for _, ua := range userAgents {
+		e.curated.userAgents = append(e.curated.userAgents, strings.ToLower(ua))
+	}
+	return e, nil
+}
+
+// readList reads one entry per line, skipping blank lines and # comments.
+// An empty path is an empty list.
+func readList(path string) ([]string, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open list file: %w", err)
+	}
+	defer f.Close()
+
+	var entries []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" && !strings.HasPrefix(line, "#") {
+			entries = append(entries, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read list file %s: %w", path, err)
+	}
+	return entries, nil
+}
+
+// extractMutexes finds mutex names by report labels, API calls, sandbox
+// JSON lists and the curated list of known malware mutexes
+func (e *Extractor) extractMutexes(content string) []string {
+	var names []string
+	for _, pattern := range []*regexp.Regexp{mutexLabelPattern, mutexAPIPattern} {
+		for _, m := range pattern.FindAllStringSubmatch(content, -1) {
+			names = append(names, m[1])
+		}
+	}
+	for _, m := range mutexListPattern.FindAllStringSubmatch(content, -1) {
+		for _, s := range jsonStringPattern.FindAllStringSubmatch(m[1], -1) {
+			names = append(names, unescapeJSON(s[1]))
+		}
+	}
+	names = append(names, e.findKnownMutexes(content)...)
+
+	return deduplicate(validateBehavior(names, maxMutexLength))
+}
+
+// findKnownMutexes returns the curated mutex names present in content
+func (e *Extractor) findKnownMutexes(content string) []string {
+	var found []string
+	for _, entry := range e.curated.mutexes {
+		name, prefix := strings.CutSuffix(entry, "*")
+		for rest := content; ; {
+			i := strings.Index(rest, name)
+			if i < 0 {
+				break
+			}
+			end := i + len(name)
+			if prefix {
+				for end < len(rest) && isNameChar(rest[end]) {
+					end++
+				}
+			}
+			found = append(found, rest[i:end])
+			rest = rest[end:]
+		}
+	}
+	return found
+}
+
+// isNameChar reports whether b may continue a mutex name suffix
+func isNameChar(b byte) bool {
+	return b == '_' || b == '-' || b >= '0' && b <= '9' || b >= 'a' && b <= 'z' || b >= 'A' && b <= 'Z'
+}
+
+// extractUserAgents finds user agents in request headers and JSON fields,
+// dropping mainstream browser user agents unless they are curated
+func (e *Extractor) extractUserAgents(content string) []string {
+	var agents []string
+	for _, m := range userAgentHeaderPattern.FindAllStringSubmatch(content, -1) {
+		agents = append(agents, m[1])
+	}
+	for _, m := range userAgentFieldPattern.FindAllStringSubmatch(content, -1) {
+		agents = append(agents, unescapeJSON(m[1]))
+	}
+
+	kept := agents[:0]
+	for _, ua := range agents {
+		ua = strings.TrimSpace(ua)
+		if e.suspiciousUserAgent(ua) || !browserUserAgentPattern.MatchString(ua) {
+			kept = append(kept, ua)
+		}
+	}
+	return deduplicate(validateBehavior(kept, maxUserAgentLength))
+}
+
+// curatedUserAgents keeps only user agents on the curated list. Sensors log
+// every client's user agent, so structured fields are not trusted on their own.
+func (e *Extractor) curatedUserAgents(values []string) []string {
+	var agents []string
+	for _, ua := range values {
+		if ua = strings.TrimSpace(ua); e.suspiciousUserAgent(ua) {
+			agents = append(agents, ua)
+		}
+	}
+	return deduplicate(validateBehavior(agents, maxUserAgentLength))
+}
+
+// suspiciousUserAgent reports whether ua contains a curated substring
+func (e *Extractor) suspiciousUserAgent(ua string) bool {
+	lower := strings.ToLower(ua)
+	for _, s := range e.curated.userAgents {
+		if strings.Contains(lower, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeBehavior validates a mutex name or user agent stated by a
+// structured source or analyst
+func normalizeBehavior(value string, maxLen int) (string, bool) {
+	v := validateBehavior([]string{value}, maxLen)
+	if len(v) == 0 {
+		return "", false
+	}
+	return v[0], true
+}
+
+// validateBehavior trims values and drops ones that are too short, too
+// long or contain control characters
+func validateBehavior(values []string, maxLen int) []string {
+	valid := make([]string, 0, len(values))
+	for _, v := range values {
+		v = strings.TrimSpace(v)
+		if len(v) < minBehaviorLength || len(v) > maxLen || strings.IndexFunc(v, unicode.IsControl) >= 0 {
+			continue
+		}
+		valid = append(valid, v)
+	}
+	return valid
+}
+
+// unescapeJSON resolves the escapes common in JSON string literals
+func unescapeJSON(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	return strings.NewReplacer(`\\`, `\`, `\"`, `"`, `\/`, `/`).Replace(s)
+}
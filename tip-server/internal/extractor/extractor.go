@@ -3,26 +3,76 @@ package extractor
 import (
 	"net"
 	"regexp"
+	"slices"
 	"strings"
 	"sync"
+	"sync/atomic"
 
+	"tip-server/internal/config"
+	"tip-server/internal/language"
 	"tip-server/internal/models"
+	"tip-server/internal/netutil"
+	"tip-server/internal/normalize"
 )
 
 // Extractor holds pre-compiled regex patterns for IOC extraction
 type Extractor struct {
 	patterns map[models.IOCType]*regexp.Regexp
+	limits   Limits
+	curated  curatedLists
 	mu       sync.RWMutex
+
+	// Operator allow/deny lists and domain popularity ranks (see lists.go),
+	// swapped whole on reload
+	lists   atomic.Pointer[filterLists]
+	listCfg config.ExtractorConfig
+}
+
+// Limits bounds what a single scan may produce, so one pathological input
+// cannot flood the IOC store or the Bloom filter
+type Limits struct {
+	MaxURLLength int // URLs longer than this are discarded (0 = unlimited)
+	MaxPerType   int // Max unique values kept per type (0 = unlimited)
+	MaxPerFile   int // Max unique values kept across all types (0 = unlimited)
+}
+
+// DefaultLimits returns the limits used by NewExtractor
+func DefaultLimits() Limits {
+	return Limits{
+		MaxURLLength: 2048,
+		MaxPerType:   50000,
+		MaxPerFile:   100000,
+	}
+}
+
+// ScanReport describes values discarded by a scan
+type ScanReport struct {
+	Oversized   map[models.IOCType]int // Values rejected for exceeding length limits
+	Dropped     map[models.IOCType]int // Values dropped by per-type/per-file caps
+	Allowlisted map[models.IOCType]int // Known-benign values removed by the allowlist
+}
+
+// Truncated reports whether any valid values were dropped by the caps
+func (r ScanReport) Truncated() bool {
+	return len(r.Dropped) > 0
 }
 
+// Hard length limits defined by the relevant RFCs
+const (
+	maxDomainLength = 253
+	maxEmailLength  = 254
+)
+
 // Pre-compiled regex patterns for each IOC type
 var (
 	// IPv4 pattern - matches standard dotted decimal notation
 	ipv4Pattern = regexp.MustCompile(`\b(?:(?:25[0-5]|2[0-4][0-9]|[01]?[0-9][0-9]?)\.){3}(?:25[0-5]|2[0-4][0-9]|[01]?[0-9][0-9]?)\b`)
 
-	// IPv6 patterns - full form and compressed forms
+	// IPv6 patterns - full form and compressed forms. Go takes the first
+	// alternative that matches, so forms with more groups after "::" come
+	// first; otherwise "2001:db8::1" would match as "2001:db8::".
 	ipv6FullPattern = regexp.MustCompile(`\b(?:[0-9a-fA-F]{1,4}:){7}[0-9a-fA-F]{1,4}\b`)
-	ipv6CompressedPattern = regexp.MustCompile(`\b(?:[0-9a-fA-F]{1,4}:){1,7}:|(?:[0-9a-fA-F]{1,4}:){1,6}:[0-9a-fA-F]{1,4}|(?:[0-9a-fA-F]{1,4}:){1,5}(?::[0-9a-fA-F]{1,4}){1,2}|(?:[0-9a-fA-F]{1,4}:){1,4}(?::[0-9a-fA-F]{1,4}){1,3}|(?:[0-9a-fA-F]{1,4}:){1,3}(?::[0-9a-fA-F]{1,4}){1,4}|(?:[0-9a-fA-F]{1,4}:){1,2}(?::[0-9a-fA-F]{1,4}){1,5}|[0-9a-fA-F]{1,4}:(?::[0-9a-fA-F]{1,4}){1,6}|:(?::[0-9a-fA-F]{1,4}){1,7}|::(?:[fF]{4}:)?(?:(?:25[0-5]|2[0-4][0-9]|[01]?[0-9][0-9]?)\.){3}(?:25[0-5]|2[0-4][0-9]|[01]?[0-9][0-9]?)\b`)
+	ipv6CompressedPattern = regexp.MustCompile(`\b[0-9a-fA-F]{1,4}:(?::[0-9a-fA-F]{1,4}){1,6}|(?:[0-9a-fA-F]{1,4}:){1,2}(?::[0-9a-fA-F]{1,4}){1,5}|(?:[0-9a-fA-F]{1,4}:){1,3}(?::[0-9a-fA-F]{1,4}){1,4}|(?:[0-9a-fA-F]{1,4}:){1,4}(?::[0-9a-fA-F]{1,4}){1,3}|(?:[0-9a-fA-F]{1,4}:){1,5}(?::[0-9a-fA-F]{1,4}){1,2}|(?:[0-9a-fA-F]{1,4}:){1,6}:[0-9a-fA-F]{1,4}|(?:[0-9a-fA-F]{1,4}:){1,7}:|::(?:[fF]{4}:)?(?:(?:25[0-5]|2[0-4][0-9]|[01]?[0-9][0-9]?)\.){3}(?:25[0-5]|2[0-4][0-9]|[01]?[0-9][0-9]?)\b|:(?::[0-9a-fA-F]{1,4}){1,7}`)
 
 	// MD5 - 32 hex characters
 	md5Pattern = regexp.MustCompile(`\b[a-fA-F0-9]{32}\b`)
@@ -33,8 +83,12 @@ var (
 	// SHA256 - 64 hex characters
 	sha256Pattern = regexp.MustCompile(`\b[a-fA-F0-9]{64}\b`)
 
-	// Domain - matches domain names with TLDs
-	domainPattern = regexp.MustCompile(`(?i)\b(?:[a-z0-9](?:[a-z0-9-]{0,61}[a-z0-9])?\.)+(?:com|net|org|edu|gov|mil|int|info|biz|name|pro|aero|coop|museum|[a-z]{2})\b`)
+	// Domain - matches domain names with TLDs, including punycode TLDs
+	domainPattern = regexp.MustCompile(`(?i)\b(?:[a-z0-9](?:[a-z0-9-]{0,61}[a-z0-9])?\.)+(?:xn--[a-z0-9-]{2,59}|com|net|org|edu|gov|mil|int|info|biz|name|pro|aero|coop|museum|[a-z]{2})\b`)
+
+	// Hostname - a whole value known to be a DNS name (any alphabetic TLD).
+	// Used for structured fields, where the TLD heuristic above isn't needed.
+	hostnamePattern = regexp.MustCompile(`^(?:[a-z0-9_](?:[a-z0-9_-]{0,61}[a-z0-9])?\.)+[a-z](?:[a-z0-9-]{0,61}[a-z0-9])?$`)
 
 	// URL - HTTP/HTTPS URLs
 	urlPattern = regexp.MustCompile(`(?i)\bhttps?://[^\s<>"'\x60{}\[\]|\\^]+`)
@@ -55,16 +109,6 @@ var (
 		"0.",
 	}
 
-	// Common false positive domains
-	falsePositiveDomains = map[string]bool{
-		"example.com":     true,
-		"example.org":     true,
-		"example.net":     true,
-		"localhost.local": true,
-		"test.com":        true,
-		"domain.com":      true,
-	}
-
 	// File extension patterns that might be false positives for hashes
 	hashFalsePositivePatterns = []string{
 		"ffffffffffffffffffffffffffffffff", // All f's
@@ -72,9 +116,28 @@ var (
 	}
 )
 
+// LimitsFromConfig maps extraction settings onto extractor limits
+func LimitsFromConfig(cfg config.ExtractorConfig) Limits {
+	return Limits{
+		MaxURLLength: cfg.MaxURLLength,
+		MaxPerType:   cfg.MaxPerType,
+		MaxPerFile:   cfg.MaxPerFile,
+	}
+}
+
 // NewExtractor creates a new IOC extractor with pre-compiled patterns
 func NewExtractor() *Extractor {
-	return &Extractor{
+	return NewExtractorWithLimits(DefaultLimits())
+}
+
+// NewExtractorWithLimits creates a new IOC extractor with custom sanity limits
+func NewExtractorWithLimits(limits Limits) *Extractor {
+	e := &Extractor{
+		limits: limits,
+		curated: curatedLists{
+			mutexes:    slices.Clone(knownMutexes),
+			userAgents: slices.Clone(suspiciousUserAgents),
+		},
 		patterns: map[models.IOCType]*regexp.Regexp{
 			models.IOCTypeIPv4:   ipv4Pattern,
 			models.IOCTypeMD5:    md5Pattern,
@@ -85,16 +148,54 @@ func NewExtractor() *Extractor {
 			models.IOCTypeEmail:  emailPattern,
 		},
 	}
+	e.lists.Store(&filterLists{
+		allow: newValueList(builtinAllowlist),
+		deny:  newValueList(nil),
+		ranks: map[string]int{},
+	})
+	return e
 }
 
 // Scan extracts all IOCs from content
 // Returns a map where key is IOC type and value is a deduplicated list of matches
 func (e *Extractor) Scan(content []byte) (map[models.IOCType][]string, error) {
-	results := make(map[models.IOCType][]string)
-	contentStr := string(content)
+	results, _, err := e.ScanWithReport(content)
+	return results, err
+}
+
+// ScanWithReport extracts all IOCs from content, applying the allowlist and
+// the configured limits, and reports how many values were discarded
+func (e *Extractor) ScanWithReport(content []byte) (map[models.IOCType][]string, ScanReport, error) {
+	results := e.scan(content)
+	allowlisted := e.filterAllowlisted(results)
+	report := e.applyLimits(results)
+	report.Allowlisted = allowlisted
 
-	// Extract each IOC type
-	results[models.IOCTypeIPv4] = e.extractIPv4(contentStr)
+	// Remove empty results
+	for k, v := range results {
+		if len(v) == 0 {
+			delete(results, k)
+		}
+	}
+
+	return results, report, nil
+}
+
+// scan matches every IOC type in content, before lists and limits.
+// Invisible characters are stripped first, so padding cannot split an
+// indicator apart.
+func (e *Extractor) scan(content []byte) map[models.IOCType][]string {
+	results := make(map[models.IOCType][]string)
+	contentStr := language.StripInvisible(string(content))
+
+	// Extract each IOC type. Addresses of CIDR blocks are network
+	// boundaries, not hosts, so they are blanked before matching IPs.
+	results[models.IOCTypeCIDR] = e.extractCIDRs(contentStr)
+	ipContent := contentStr
+	if len(results[models.IOCTypeCIDR]) > 0 {
+		ipContent = cidrPattern.ReplaceAllLiteralString(contentStr, " ")
+	}
+	results[models.IOCTypeIPv4] = e.extractIPv4(ipContent)
 	results[models.IOCTypeIPv6] = e.extractIPv6(contentStr)
 	results[models.IOCTypeMD5] = e.extractMD5(contentStr)
 	results[models.IOCTypeSHA1] = e.extractSHA1(contentStr)
@@ -102,15 +203,125 @@ func (e *Extractor) Scan(content []byte) (map[models.IOCType][]string, error) {
 	results[models.IOCTypeDomain] = e.extractDomains(contentStr)
 	results[models.IOCTypeURL] = e.extractURLs(contentStr)
 	results[models.IOCTypeEmail] = e.extractEmails(contentStr)
+	results[models.IOCTypeMutex] = e.extractMutexes(contentStr)
+	results[models.IOCTypeUserAgent] = e.extractUserAgents(contentStr)
+	results[models.IOCTypeASN] = e.extractASNs(contentStr)
+
+	return results
+}
+
+// ScanFields validates values whose type is already known from a structured
+// source (e.g. a sensor log field). Each set only runs through its own type's
+// extractor, so values cannot be misclassified, and internal addresses are
+// dropped since sensors log both ends of every connection.
+func (e *Extractor) ScanFields(fields map[models.IOCType][]string) (map[models.IOCType][]string, ScanReport) {
+	results := make(map[models.IOCType][]string)
+
+	for iocType, values := range fields {
+		values = sanitizeAll(values)
+		content := strings.Join(values, "\n")
+
+		switch iocType {
+		case models.IOCTypeIPv4:
+			results[iocType] = filterInternalIPs(e.extractIPv4(content))
+		case models.IOCTypeIPv6:
+			results[iocType] = filterInternalIPs(e.extractIPv6(content))
+		case models.IOCTypeMD5, models.IOCTypeJA3, models.IOCTypeImphash:
+			results[iocType] = e.extractMD5(content)
+		case models.IOCTypeSHA1:
+			results[iocType] = e.extractSHA1(content)
+		case models.IOCTypeSHA256:
+			results[iocType] = e.extractSHA256(content)
+		case models.IOCTypeCertSHA1:
+			// Sensors log fingerprints as colon-separated byte pairs
+			results[iocType] = e.extractSHA1(strings.ReplaceAll(content, ":", ""))
+		case models.IOCTypeCertSHA256:
+			results[iocType] = e.extractSHA256(strings.ReplaceAll(content, ":", ""))
+		case models.IOCTypeCertSerial:
+			results[iocType] = extractCertSerials(values)
+		case models.IOCTypeDomain:
+			results[iocType] = validateHostnames(values)
+		case models.IOCTypeURL:
+			results[iocType] = e.extractURLs(content)
+		case models.IOCTypeEmail:
+			results[iocType] = e.extractEmails(content)
+		case models.IOCTypeMutex:
+			results[iocType] = deduplicate(validateBehavior(values, maxMutexLength))
+		case models.IOCTypeUserAgent:
+			results[iocType] = e.curatedUserAgents(values)
+		case models.IOCTypeCIDR:
+			results[iocType] = normalizeCIDRs(values)
+		case models.IOCTypeASN:
+			var asns []string
+			for _, v := range values {
+				if asn, ok := normalizeASN(v); ok {
+					asns = append(asns, asn)
+				}
+			}
+			results[iocType] = deduplicate(asns)
+		}
+	}
+
+	allowlisted := e.filterAllowlisted(results)
+	report := e.applyLimits(results)
+	report.Allowlisted = allowlisted
 
-	// Remove empty results
 	for k, v := range results {
 		if len(v) == 0 {
 			delete(results, k)
 		}
 	}
 
-	return results, nil
+	return results, report
+}
+
+// applyLimits enforces length and count limits in place
+func (e *Extractor) applyLimits(results map[models.IOCType][]string) ScanReport {
+	report := ScanReport{
+		Oversized: make(map[models.IOCType]int),
+		Dropped:   make(map[models.IOCType]int),
+	}
+
+	maxLength := map[models.IOCType]int{
+		models.IOCTypeURL:    e.limits.MaxURLLength,
+		models.IOCTypeDomain: maxDomainLength,
+		models.IOCTypeEmail:  maxEmailLength,
+	}
+
+	for iocType, max := range maxLength {
+		if max <= 0 || len(results[iocType]) == 0 {
+			continue
+		}
+		kept := results[iocType][:0]
+		for _, v := range results[iocType] {
+			if len(v) > max {
+				report.Oversized[iocType]++
+				continue
+			}
+			kept = append(kept, v)
+		}
+		results[iocType] = kept
+	}
+
+	// Walk types in a fixed order so the per-file cap is deterministic
+	remaining := e.limits.MaxPerFile
+	for _, iocType := range models.AllIOCTypes() {
+		values := results[iocType]
+		keep := len(values)
+		if e.limits.MaxPerType > 0 && keep > e.limits.MaxPerType {
+			keep = e.limits.MaxPerType
+		}
+		if e.limits.MaxPerFile > 0 && keep > remaining {
+			keep = remaining
+		}
+		if keep < len(values) {
+			report.Dropped[iocType] = len(values) - keep
+			results[iocType] = values[:keep]
+		}
+		remaining -= keep
+	}
+
+	return report
 }
 
 // ScanWithOptions extracts IOCs with filtering options
@@ -125,18 +336,13 @@ func (e *Extractor) ScanWithOptions(content []byte, opts ExtractOptions) (map[mo
 		results[models.IOCTypeIPv4] = filterPrivateIPs(results[models.IOCTypeIPv4])
 	}
 
-	if opts.ExcludeFalsePositiveDomains {
-		results[models.IOCTypeDomain] = filterFalsePositiveDomains(results[models.IOCTypeDomain])
-	}
-
 	return results, nil
 }
 
 // ExtractOptions allows customization of extraction behavior
 type ExtractOptions struct {
-	ExcludePrivateIPs           bool
-	ExcludeFalsePositiveDomains bool
-	Types                       []models.IOCType // If set, only extract these types
+	ExcludePrivateIPs bool
+	Types             []models.IOCType // If set, only extract these types
 }
 
 // ========== Individual Extractors ==========
@@ -157,7 +363,12 @@ func (e *Extractor) extractIPv6(content string) []string {
 	compressedMatches := ipv6CompressedPattern.FindAllString(content, -1)
 	matches = append(matches, compressedMatches...)
 
-	return deduplicate(validateIPv6s(matches))
+	// One address has many spellings; store the RFC 5952 form
+	valid := validateIPv6s(matches)
+	for i, ip := range valid {
+		valid[i], _ = normalize.IP(ip)
+	}
+	return deduplicate(valid)
 }
 
 func (e *Extractor) extractMD5(content string) []string {
@@ -183,7 +394,10 @@ func (e *Extractor) extractSHA256(content string) []string {
 
 func (e *Extractor) extractDomains(content string) []string {
 	matches := domainPattern.FindAllString(content, -1)
-	return deduplicate(toLower(matches))
+	for i, d := range matches {
+		matches[i] = normalize.Domain(d)
+	}
+	return deduplicate(matches)
 }
 
 func (e *Extractor) extractURLs(content string) []string {
@@ -192,14 +406,17 @@ func (e *Extractor) extractURLs(content string) []string {
 	cleaned := make([]string, 0, len(matches))
 	for _, u := range matches {
 		u = strings.TrimRight(u, ".,;:!?)")
-		cleaned = append(cleaned, u)
+		cleaned = append(cleaned, normalize.URL(u))
 	}
 	return deduplicate(cleaned)
 }
 
 func (e *Extractor) extractEmails(content string) []string {
 	matches := emailPattern.FindAllString(content, -1)
-	return deduplicate(toLower(matches))
+	for i, m := range matches {
+		matches[i] = normalize.Email(m)
+	}
+	return deduplicate(matches)
 }
 
 // ========== Helper Functions ==========
@@ -219,6 +436,16 @@ func deduplicate(items []string) []string {
 	return result
 }
 
+// sanitizeAll strips invisible characters and folds fullwidth forms in each
+// value
+func sanitizeAll(values []string) []string {
+	sanitized := make([]string, len(values))
+	for i, v := range values {
+		sanitized[i] = normalize.Sanitize(v)
+	}
+	return sanitized
+}
+
 // toLower converts all strings to lowercase
 func toLower(items []string) []string {
 	result := make([]string, len(items))
@@ -269,15 +496,29 @@ func filterPrivateIPs(ips []string) []string {
 	return public
 }
 
-// filterFalsePositiveDomains removes known false positive domains
-func filterFalsePositiveDomains(domains []string) []string {
-	filtered := make([]string, 0, len(domains))
-	for _, d := range domains {
-		if !falsePositiveDomains[strings.ToLower(d)] {
-			filtered = append(filtered, d)
+// validateHostnames normalizes and keeps whole values that are DNS names
+func validateHostnames(values []string) []string {
+	valid := make([]string, 0, len(values))
+	for _, v := range values {
+		v = normalize.Domain(strings.TrimSpace(v))
+		if len(v) <= 253 && hostnamePattern.MatchString(v) {
+			valid = append(valid, v)
 		}
 	}
-	return filtered
+	return deduplicate(valid)
+}
+
+// filterInternalIPs removes private, loopback, link-local and other
+// non-routable addresses
+func filterInternalIPs(ips []string) []string {
+	public := make([]string, 0, len(ips))
+	for _, s := range ips {
+		if ip := net.ParseIP(s); ip == nil || !netutil.IsPublic(ip) {
+			continue
+		}
+		public = append(public, s)
+	}
+	return public
 }
 
 // filterHashFalsePositives removes known false positive hash patterns
@@ -287,7 +528,8 @@ func filterHashFalsePositives(hashes []string) []string {
 		lower := strings.ToLower(h)
 		isFalsePositive := false
 		for _, fp := range hashFalsePositivePatterns {
-			if strings.HasPrefix(lower, fp[:len(lower)]) || lower == fp[:len(lower)] {
+			// Patterns are one repeated digit; match them at every hash length
+			if strings.Trim(lower, fp[:1]) == "" {
 				isFalsePositive = true
 				break
 			}
@@ -308,6 +550,112 @@ func CountIOCs(results map[models.IOCType][]string) int {
 	return count
 }
 
+// DetectType classifies a single indicator value. It returns the value as the
+// extractor would store it (e.g. lowercased domains) and false when the value
+// is not a recognised IOC on its own. Allowlisted values are still classified.
+func (e *Extractor) DetectType(value string) (models.IOCType, string, bool) {
+	value = normalize.Value(value)
+	results := e.scan([]byte(value))
+
+	for _, iocType := range models.AllIOCTypes() {
+		for _, v := range results[iocType] {
+			if strings.EqualFold(v, value) {
+				return iocType, v, true
+			}
+		}
+	}
+	return "", "", false
+}
+
+// Classify determines the type of a single value whose source may declare a
+// type or tag it. A declared hash-shaped type (JA3, imphash, certificate
+// fingerprint) overrides the file hash type the value's form detects as, and
+// SHA-1/SHA-256 values tagged as certificates become certificate
+// fingerprints. Serial numbers, mutex names and user agents are only
+// accepted when declared, since nearly any string looks like one.
+func (e *Extractor) Classify(value string, declared models.IOCType, tags []string) (models.IOCType, string, bool) {
+	switch declared {
+	case models.IOCTypeCertSerial:
+		serial, ok := normalizeCertSerial(value)
+		return declared, serial, ok
+	case models.IOCTypeMutex:
+		name, ok := normalizeBehavior(value, maxMutexLength)
+		return declared, name, ok
+	case models.IOCTypeUserAgent:
+		ua, ok := normalizeBehavior(value, maxUserAgentLength)
+		return declared, ua, ok
+	case models.IOCTypeCIDR:
+		block, ok := normalizeCIDR(value)
+		return declared, block, ok
+	case models.IOCTypeASN:
+		asn, ok := normalizeASN(value)
+		return declared, asn, ok
+	case models.IOCTypeCertSHA1, models.IOCTypeCertSHA256:
+		value = strings.ReplaceAll(value, ":", "")
+	}
+
+	detected, normalized, ok := e.DetectType(value)
+	if !ok && declared == "" {
+		// Forms text extraction does not produce: IPv6 or unmasked blocks, "ASN 123"
+		if block, ok := normalizeCIDR(value); ok {
+			return models.IOCTypeCIDR, block, true
+		}
+		if strings.HasPrefix(strings.ToUpper(strings.TrimSpace(value)), "AS") {
+			if asn, ok := normalizeASN(value); ok {
+				return models.IOCTypeASN, asn, true
+			}
+		}
+	}
+	if !ok {
+		return "", "", false
+	}
+
+	if declared == "" {
+		return models.CertificateHashType(detected, tags), normalized, true
+	}
+	if models.HashForm(declared) == detected {
+		detected = declared
+	}
+	if declared != detected {
+		return "", "", false
+	}
+	return detected, normalized, true
+}
+
+// maxCertSerialBytes is the longest serial number RFC 5280 allows
+const maxCertSerialBytes = 20
+
+// normalizeCertSerial returns a certificate serial number as lowercase hex
+// without separators or leading zeros, accepting the colon- and
+// space-separated forms tools print
+func normalizeCertSerial(s string) (string, bool) {
+	s = strings.ToLower(strings.NewReplacer(":", "", " ", "", "-", "").Replace(strings.TrimSpace(s)))
+	s = strings.TrimPrefix(s, "0x")
+	if s == "" || len(s) > 2*maxCertSerialBytes {
+		return "", false
+	}
+	for _, r := range s {
+		if (r < '0' || r > '9') && (r < 'a' || r > 'f') {
+			return "", false
+		}
+	}
+	if s = strings.TrimLeft(s, "0"); s == "" {
+		s = "0"
+	}
+	return s, true
+}
+
+// extractCertSerials normalises serial numbers from a structured field
+func extractCertSerials(values []string) []string {
+	var serials []string
+	for _, v := range values {
+		if serial, ok := normalizeCertSerial(v); ok {
+			serials = append(serials, serial)
+		}
+	}
+	return deduplicate(serials)
+}
+
 // FlattenIOCs converts scan results to a flat list of IOC structs
 func FlattenIOCs(results map[models.IOCType][]string, sourceFileID string) []models.IOC {
 	var iocs []models.IOC
@@ -0,0 +1,57 @@
+package extractor
+
+import (
+	"net/url"
+	"slices"
+	"strings"
+
+	"golang.org/x/net/idna"
+
+	"tip-server/internal/language"
+	"tip-server/internal/models"
+)
+
+// HomographTagPrefix starts the tag naming the Latin domain an IDN homograph
+// imitates, e.g. "homograph-of:apple.com" on xn--pple-43d.com
+const HomographTagPrefix = "homograph-of:"
+
+// HomographOf returns the Latin domain a punycode domain imitates by mixing
+// Cyrillic or Greek lookalikes into Latin labels, or "" when it imitates none
+func HomographOf(domain string) string {
+	if !strings.Contains(domain, "xn--") {
+		return ""
+	}
+	unicode, err := idna.Lookup.ToUnicode(domain)
+	if err != nil {
+		return ""
+	}
+	folded := language.FoldConfusables(unicode)
+	if folded == unicode || strings.IndexFunc(folded, func(r rune) bool { return r > 0x7f }) >= 0 {
+		return ""
+	}
+	return folded
+}
+
+// TagHomographs tags domain and URL indicators whose host is an IDN homograph
+// with the domain it imitates. The value is kept as extracted; the tag only
+// lets consumers find lookalikes of a domain they care about.
+func TagHomographs(iocs []models.IOC) {
+	for idx := range iocs {
+		ioc := &iocs[idx]
+		host := ioc.Value
+		switch ioc.Type {
+		case models.IOCTypeDomain:
+		case models.IOCTypeURL:
+			u, err := url.Parse(ioc.Value)
+			if err != nil {
+				continue
+			}
+			host = u.Hostname()
+		default:
+			continue
+		}
+		if target := HomographOf(host); target != "" {
+			ioc.Tags = append(slices.Clip(ioc.Tags), HomographTagPrefix+target)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package extractor
+
+import (
+	"slices"
+	"testing"
+
+	"tip-server/internal/models"
+	"tip-server/internal/normalize"
+)
+
+func TestHomographs(t *testing.T) {
+	tests := []struct {
+		name   string
+		iocs   []models.IOC
+		stored string // Canonical value, as extracted and looked up
+		tag    string // Homograph tag, if any
+	}{
+		{
+			name:   "cyrillic a in a latin domain",
+			iocs:   []models.IOC{{Value: "аpple.com", Type: models.IOCTypeDomain}},
+			stored: "xn--pple-43d.com",
+			tag:    HomographTagPrefix + "apple.com",
+		},
+		{
+			name:   "greek omicron in a url host",
+			iocs:   []models.IOC{{Value: "https://gοogle.com/login", Type: models.IOCTypeURL}},
+			stored: "https://xn--gogle-rce.com/login",
+			tag:    HomographTagPrefix + "google.com",
+		},
+		{
+			name:   "wholly cyrillic domain",
+			iocs:   []models.IOC{{Value: "пример.рф", Type: models.IOCTypeDomain}},
+			stored: "xn--e1afmkfd.xn--p1ai",
+		},
+		{
+			name:   "latin domain",
+			iocs:   []models.IOC{{Value: "apple.com", Type: models.IOCTypeDomain}},
+			stored: "apple.com",
+		},
+		{
+			name:   "hash",
+			iocs:   []models.IOC{{Value: "d41d8cd98f00b204e9800998ecf8427e", Type: models.IOCTypeMD5}},
+			stored: "d41d8cd98f00b204e9800998ecf8427e",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			iocs := tt.iocs
+			iocs[0].Value = normalize.Value(iocs[0].Value)
+			if iocs[0].Value != tt.stored {
+				t.Fatalf("canonical value = %q, want %q", iocs[0].Value, tt.stored)
+			}
+
+			TagHomographs(iocs)
+			if iocs[0].Value != tt.stored {
+				t.Errorf("tagging rewrote the value to %q", iocs[0].Value)
+			}
+			if tt.tag == "" {
+				if len(iocs[0].Tags) != 0 {
+					t.Errorf("tags = %v, want none", iocs[0].Tags)
+				}
+			} else if !slices.Equal(iocs[0].Tags, []string{tt.tag}) {
+				t.Errorf("tags = %v, want [%s]", iocs[0].Tags, tt.tag)
+			}
+		})
+	}
+}
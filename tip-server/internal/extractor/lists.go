@@ -0,0 +1,209 @@
+package extractor
+
+import (
+	"net/netip"
+	"strings"
+
+	"tip-server/internal/config"
+	"tip-server/internal/models"
+	"tip-server/internal/netutil"
+)
+
+// List verdicts reported by CheckLists
+const (
+	ListAllow = "allow"
+	ListDeny  = "deny"
+)
+
+// builtinAllowlist holds values that are benign everywhere: documentation
+// domains and the hashes of an empty file
+var builtinAllowlist = []string{
+	"example.com",
+	"example.org",
+	"example.net",
+	"localhost.local",
+	"test.com",
+	"domain.com",
+	"d41d8cd98f00b204e9800998ecf8427e", // MD5 of empty input
+	"da39a3ee5e6b4b0d3255bfef95601890afd80709",                         // SHA-1 of empty input
+	"e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855", // SHA-256 of empty input
+}
+
+// valueList matches indicator values against operator list entries. Domains
+// also match their subdomains, and addresses and blocks match the ranges
+// containing them.
+type valueList struct {
+	domains map[string]struct{}
+	values  map[string]struct{}
+	ranges  *netutil.PrefixTable[struct{}]
+}
+
+// filterLists are the allow and deny lists an extractor applies, and the
+// popularity ranks it tags domains with
+type filterLists struct {
+	allow *valueList
+	deny  *valueList
+	ranks map[string]int // Domain -> popularity rank (1 = most popular)
+}
+
+// newValueList classifies raw list entries. Lines of a CSV take their last
+// field, so ranked exports such as Tranco's "1,google.com" load as-is.
+func newValueList(entries []string) *valueList {
+	l := &valueList{
+		domains: make(map[string]struct{}),
+		values:  make(map[string]struct{}),
+		ranges:  netutil.NewPrefixTable[struct{}](),
+	}
+	for _, entry := range entries {
+		if i := strings.LastIndexByte(entry, ','); i >= 0 {
+			entry = entry[i+1:]
+		}
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		if p, err := netip.ParsePrefix(entry); err == nil {
+			l.ranges.Insert(p, struct{}{})
+			continue
+		}
+		if addr, err := netip.ParseAddr(entry); err == nil {
+			addr = addr.Unmap()
+			l.ranges.Insert(netip.PrefixFrom(addr, addr.BitLen()), struct{}{})
+			continue
+		}
+
+		lower := strings.TrimSuffix(strings.ToLower(entry), ".")
+		switch {
+		case isHexDigest(lower):
+			l.values[lower] = struct{}{}
+		case hostnamePattern.MatchString(lower):
+			l.domains[lower] = struct{}{}
+		default:
+			l.values[entry] = struct{}{}
+		}
+	}
+	return l
+}
+
+// contains reports whether a value of the given type is on the list
+func (l *valueList) contains(iocType models.IOCType, value string) bool {
+	switch iocType {
+	case models.IOCTypeIPv4, models.IOCTypeIPv6:
+		addr, err := netip.ParseAddr(value)
+		if err != nil {
+			return false
+		}
+		_, _, ok := l.ranges.Lookup(addr)
+		return ok
+	case models.IOCTypeCIDR:
+		p, err := netip.ParsePrefix(value)
+		if err != nil {
+			return false
+		}
+		// Only listed ranges at least as broad as the block contain it
+		listed, _, ok := l.ranges.Lookup(p.Addr())
+		return ok && listed.Bits() <= p.Bits()
+	case models.IOCTypeDomain:
+		for d := strings.ToLower(value); d != ""; {
+			if _, ok := l.domains[d]; ok {
+				return true
+			}
+			_, d, _ = strings.Cut(d, ".")
+		}
+		return false
+	}
+
+	if _, ok := l.values[value]; ok {
+		return true
+	}
+	_, ok := l.values[strings.ToLower(value)]
+	return ok
+}
+
+// isHexDigest reports whether s is shaped like an MD5, SHA-1 or SHA-256 digest
+func isHexDigest(s string) bool {
+	switch len(s) {
+	case 32, 40, 64:
+	default:
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if c := s[i]; (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// loadFilterLists reads the allow, deny and popularity list files, the
+// allowlist on top of the built-in entries
+func loadFilterLists(cfg config.ExtractorConfig) (*filterLists, error) {
+	allow := append([]string(nil), builtinAllowlist...)
+	for _, path := range cfg.AllowlistFiles {
+		entries, err := readList(path)
+		if err != nil {
+			return nil, err
+		}
+		allow = append(allow, entries...)
+	}
+
+	var deny []string
+	for _, path := range cfg.DenylistFiles {
+		entries, err := readList(path)
+		if err != nil {
+			return nil, err
+		}
+		deny = append(deny, entries...)
+	}
+
+	ranks, err := loadRanks(cfg.PopularityListFile, cfg.PopularityTopN)
+	if err != nil {
+		return nil, err
+	}
+
+	return &filterLists{allow: newValueList(allow), deny: newValueList(deny), ranks: ranks}, nil
+}
+
+// ReloadLists re-reads the configured allow, deny and popularity list
+// files. On error the previous lists stay in effect.
+func (e *Extractor) ReloadLists() error {
+	lists, err := loadFilterLists(e.listCfg)
+	if err != nil {
+		return err
+	}
+	e.lists.Store(lists)
+	return nil
+}
+
+// CheckLists returns ListDeny or ListAllow when an operator list decides a
+// value's verdict, or "" when neither does. The denylist wins, so single
+// hosts can be carved out of an allowlisted domain or range.
+func (e *Extractor) CheckLists(iocType models.IOCType, value string) string {
+	lists := e.lists.Load()
+	switch {
+	case lists.deny.contains(iocType, value):
+		return ListDeny
+	case lists.allow.contains(iocType, value):
+		return ListAllow
+	}
+	return ""
+}
+
+// filterAllowlisted removes allowlisted values from scan results in place,
+// counting them per type
+func (e *Extractor) filterAllowlisted(results map[models.IOCType][]string) map[models.IOCType]int {
+	removed := make(map[models.IOCType]int)
+	for iocType, values := range results {
+		kept := values[:0]
+		for _, v := range values {
+			if e.CheckLists(iocType, v) == ListAllow {
+				removed[iocType]++
+				continue
+			}
+			kept = append(kept, v)
+		}
+		results[iocType] = kept
+	}
+	return removed
+}
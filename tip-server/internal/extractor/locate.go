@@ -0,0 +1,87 @@
+package extractor
+
+import (
+	"bytes"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// SnippetContextBytes is how much text Snippet keeps on each side of a match
+const SnippetContextBytes = 80
+
+// Locate returns the offset of the first occurrence of value in content, or
+// -1. folded is the content folded with FoldASCII: matching ignores ASCII case,
+// since values are stored in canonical form, and skips occurrences inside a
+// longer word (1.2.3.4 in 11.2.3.45). Values the content only holds defanged
+// or in another notation are not found.
+func Locate(folded []byte, value string) int {
+	return indexWord(folded, FoldASCII([]byte(value)))
+}
+
+// indexWord returns the offset of the first occurrence of value in content
+// not adjoined by a letter or digit, or -1
+func indexWord(content, value []byte) int {
+	if len(value) == 0 {
+		return -1
+	}
+	for from := 0; from < len(content); {
+		i := bytes.Index(content[from:], value)
+		if i < 0 {
+			return -1
+		}
+		at := from + i
+		end := at + len(value)
+		if (at == 0 || !isWordByte(content[at-1])) && (end == len(content) || !isWordByte(content[end])) {
+			return at
+		}
+		from = at + 1
+	}
+	return -1
+}
+
+// isWordByte reports whether b is an ASCII letter or digit
+func isWordByte(b byte) bool {
+	return 'a' <= b && b <= 'z' || 'A' <= b && b <= 'Z' || '0' <= b && b <= '9'
+}
+
+// FoldASCII lowercases ASCII letters only, so byte offsets are preserved
+func FoldASCII(b []byte) []byte {
+	out := make([]byte, len(b))
+	for i, ch := range b {
+		if 'A' <= ch && ch <= 'Z' {
+			ch += 'a' - 'A'
+		}
+		out[i] = ch
+	}
+	return out
+}
+
+// Snippet returns the text of content[start:end] with up to
+// SnippetContextBytes on either side, cut at rune boundaries and with runs
+// of whitespace and control characters folded into single spaces
+func Snippet(content []byte, start, end int) string {
+	from := max(0, start-SnippetContextBytes)
+	for from < start && !utf8.RuneStart(content[from]) {
+		from++
+	}
+	to := min(len(content), end+SnippetContextBytes)
+	for to > end && to < len(content) && !utf8.RuneStart(content[to]) {
+		to--
+	}
+
+	var b strings.Builder
+	space := false
+	for _, r := range string(content[from:to]) {
+		if unicode.IsSpace(r) || unicode.IsControl(r) || r == utf8.RuneError {
+			space = true
+			continue
+		}
+		if space && b.Len() > 0 {
+			b.WriteByte(' ')
+		}
+		space = false
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -0,0 +1,90 @@
+package extractor
+
+import (
+	"net/netip"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Broader blocks are allocations rather than infrastructure and would match
+// far too much
+const (
+	minCIDRBitsIPv4 = 8
+	minCIDRBitsIPv6 = 16
+)
+
+var (
+	// IPv4 CIDR block, e.g. 185.100.87.0/24
+	cidrPattern = regexp.MustCompile(`\b(?:(?:25[0-5]|2[0-4][0-9]|[01]?[0-9][0-9]?)\.){3}(?:25[0-5]|2[0-4][0-9]|[01]?[0-9][0-9]?)/(?:3[0-2]|[12]?[0-9])\b`)
+
+	// Autonomous system number, e.g. AS12345 or ASN 12345. Case-sensitive:
+	// lowercase "as" is an English word.
+	asnPattern = regexp.MustCompile(`\bAS(?:N[ :]*)?([0-9]{1,10})\b`)
+)
+
+// extractCIDRs finds IPv4 CIDR blocks in text
+func (e *Extractor) extractCIDRs(content string) []string {
+	return normalizeCIDRs(cidrPattern.FindAllString(content, -1))
+}
+
+// extractASNs finds autonomous system numbers in text
+func (e *Extractor) extractASNs(content string) []string {
+	var asns []string
+	for _, m := range asnPattern.FindAllStringSubmatch(content, -1) {
+		if asn, ok := normalizeASN(m[1]); ok {
+			asns = append(asns, asn)
+		}
+	}
+	return deduplicate(asns)
+}
+
+// normalizeCIDRs masks blocks and drops invalid, internal and overly broad ones
+func normalizeCIDRs(values []string) []string {
+	var blocks []string
+	for _, v := range values {
+		if block, ok := normalizeCIDR(v); ok {
+			blocks = append(blocks, block)
+		}
+	}
+	return deduplicate(blocks)
+}
+
+// normalizeCIDR returns a public block in canonical masked form, e.g.
+// 10.1.2.3/8 becomes 10.0.0.0/8
+func normalizeCIDR(s string) (string, bool) {
+	p, err := netip.ParsePrefix(strings.TrimSpace(s))
+	if err != nil {
+		return "", false
+	}
+	p = p.Masked()
+
+	addr := p.Addr()
+	minBits := minCIDRBitsIPv6
+	if addr.Is4() {
+		minBits = minCIDRBitsIPv4
+	}
+	if p.Bits() < minBits || addr.IsPrivate() || addr.IsLoopback() || addr.IsLinkLocalUnicast() ||
+		addr.IsMulticast() || addr.IsUnspecified() {
+		return "", false
+	}
+	return p.String(), true
+}
+
+// normalizeASN returns an AS number as "AS<n>", accepting "AS", "ASN" or no
+// prefix. Reserved and private-use numbers are rejected.
+func normalizeASN(s string) (string, bool) {
+	s = strings.TrimSpace(strings.ToUpper(s))
+	s = strings.TrimLeft(strings.TrimPrefix(strings.TrimPrefix(s, "ASN"), "AS"), " :")
+
+	n, err := strconv.ParseUint(s, 10, 32)
+	if err != nil {
+		return "", false
+	}
+	switch {
+	case n == 0, n == 23456, n >= 64496 && n <= 131071, n >= 4200000000:
+		// Reserved, AS_TRANS, documentation/private/reserved ranges, private
+		return "", false
+	}
+	return "AS" + strconv.FormatUint(n, 10), true
+}
@@ -0,0 +1,73 @@
+package extractor
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+
+	"tip-server/internal/models"
+)
+
+// PopularityTagPrefix starts the tag recording a domain's popularity rank,
+// e.g. "tranco-rank:42"
+const PopularityTagPrefix = "tranco-rank:"
+
+// loadRanks reads a ranked domain list such as the Tranco top-1M CSV, one
+// "rank,domain" per line. Lines without a rank take their position. Only
+// domains ranked within topN are kept (0 = all).
+func loadRanks(path string, topN int) (map[string]int, error) {
+	lines, err := readList(path)
+	if err != nil {
+		return nil, err
+	}
+
+	ranks := make(map[string]int, len(lines))
+	for pos, line := range lines {
+		rank := pos + 1
+		domain := line
+		if r, d, ok := strings.Cut(line, ","); ok {
+			if n, err := strconv.Atoi(strings.TrimSpace(r)); err == nil && n > 0 {
+				rank = n
+			}
+			domain = d
+		}
+		if topN > 0 && rank > topN {
+			continue
+		}
+
+		domain = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(domain)), ".")
+		if prev, ok := ranks[domain]; domain == "" || ok && prev <= rank {
+			continue
+		}
+		ranks[domain] = rank
+	}
+	return ranks, nil
+}
+
+// PopularityRank returns the rank of the most specific listed domain that is
+// domain or one of its parents, or 0 when none is on the popularity list
+func (e *Extractor) PopularityRank(domain string) int {
+	ranks := e.lists.Load().ranks
+	for d := strings.ToLower(domain); d != ""; {
+		if rank, ok := ranks[d]; ok {
+			return rank
+		}
+		_, d, _ = strings.Cut(d, ".")
+	}
+	return 0
+}
+
+// TagPopularity tags domain indicators on the popularity list with their
+// rank. Popular domains are kept, since attackers host content on them,
+// but the tag lets consumers weigh a match accordingly.
+func (e *Extractor) TagPopularity(iocs []models.IOC) {
+	for idx := range iocs {
+		ioc := &iocs[idx]
+		if ioc.Type != models.IOCTypeDomain {
+			continue
+		}
+		if rank := e.PopularityRank(ioc.Value); rank > 0 {
+			ioc.Tags = append(slices.Clip(ioc.Tags), PopularityTagPrefix+strconv.Itoa(rank))
+		}
+	}
+}
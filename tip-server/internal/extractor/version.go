@@ -0,0 +1,37 @@
+package extractor
+
+import (
+	"runtime/debug"
+	"sync"
+)
+
+// Version identifies the build of the extractor, so extraction samples can
+// be compared across pattern changes: the VCS revision the binary was built
+// from (12 characters, "-dirty" when built with local changes), or "dev"
+// when the build recorded none
+var Version = sync.OnceValue(func() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "dev"
+	}
+
+	var revision, modified string
+	for _, s := range info.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			revision = s.Value
+		case "vcs.modified":
+			modified = s.Value
+		}
+	}
+	if revision == "" {
+		return "dev"
+	}
+	if len(revision) > 12 {
+		revision = revision[:12]
+	}
+	if modified == "true" {
+		revision += "-dirty"
+	}
+	return revision
+})
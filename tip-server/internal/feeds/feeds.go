@@ -0,0 +1,187 @@
+// Package feeds parses structured threat intelligence documents (OpenIOC,
+// STIX 1.x XML and STIX 2.x JSON bundles) into typed indicators, keeping the
+// confidence, labels, validity window and related malware from the source.
+package feeds
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"tip-server/internal/models"
+)
+
+// Document formats recognised by Parse
+const (
+	FormatOpenIOC = "openioc"
+	FormatSTIX1   = "stix1"
+	FormatSTIX2   = "stix2"
+)
+
+// Formats returns all document formats recognised by Parse
+func Formats() []string {
+	return []string{FormatOpenIOC, FormatSTIX1, FormatSTIX2}
+}
+
+// Indicator is a single indicator taken from a feed document
+type Indicator struct {
+	Type          models.IOCType
+	Value         string
+	Confidence    uint8 // 0 when the source does not state one
+	MalwareFamily string
+	Tags          []string
+	ValidFrom     time.Time
+	ValidUntil    time.Time
+}
+
+// Expired reports whether the indicator's validity window has ended
+func (ind Indicator) Expired(now time.Time) bool {
+	return !ind.ValidUntil.IsZero() && ind.ValidUntil.Before(now)
+}
+
+// Detect returns the document format of content, or "" if it is not a
+// recognised feed document
+func Detect(content []byte) string {
+	trimmed := bytes.TrimSpace(content)
+	if len(trimmed) == 0 {
+		return ""
+	}
+
+	switch trimmed[0] {
+	case '<':
+		return detectXML(trimmed)
+	case '{':
+		return detectJSON(trimmed)
+	}
+	return ""
+}
+
+// Parse extracts indicators from a feed document. format is "" when content
+// is not a feed document; err is set when it is one but cannot be parsed.
+// Expired indicators are dropped.
+func Parse(content []byte) (indicators []Indicator, format string, err error) {
+	format = Detect(content)
+
+	switch format {
+	case FormatOpenIOC:
+		indicators, err = parseOpenIOC(content)
+	case FormatSTIX1:
+		indicators, err = parseSTIX1(content)
+	case FormatSTIX2:
+		indicators, err = parseSTIX2(content)
+	default:
+		return nil, "", nil
+	}
+	if err != nil {
+		return nil, format, err
+	}
+
+	now := time.Now()
+	live := indicators[:0]
+	for _, ind := range indicators {
+		if ind.Value != "" && !ind.Expired(now) {
+			live = append(live, ind)
+		}
+	}
+	return live, format, nil
+}
+
+// detectXML identifies an XML document by its root element
+func detectXML(content []byte) string {
+	dec := xml.NewDecoder(bytes.NewReader(content))
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return ""
+		}
+		if start, ok := tok.(xml.StartElement); ok {
+			switch start.Name.Local {
+			case "ioc", "OpenIOC":
+				return FormatOpenIOC
+			case "STIX_Package":
+				return FormatSTIX1
+			}
+			return ""
+		}
+	}
+}
+
+// detectJSON identifies a STIX 2.x bundle or single indicator object
+func detectJSON(content []byte) string {
+	var head struct {
+		Type string `json:"type"`
+		ID   string `json:"id"`
+	}
+	if err := json.Unmarshal(content, &head); err != nil {
+		return ""
+	}
+	if (head.Type == "bundle" && strings.HasPrefix(head.ID, "bundle--")) ||
+		(head.Type == "indicator" && strings.HasPrefix(head.ID, "indicator--")) {
+		return FormatSTIX2
+	}
+	return ""
+}
+
+// ipType classifies an IP address or CIDR block value, or returns "" if it
+// is neither
+func ipType(value string) models.IOCType {
+	if strings.Contains(value, "/") {
+		return models.IOCTypeCIDR
+	}
+	ip := net.ParseIP(value)
+	switch {
+	case ip == nil:
+		return ""
+	case ip.To4() != nil:
+		return models.IOCTypeIPv4
+	default:
+		return models.IOCTypeIPv6
+	}
+}
+
+// hashType maps a hash algorithm name to its IOC type
+func hashType(name string) models.IOCType {
+	switch strings.ToUpper(strings.NewReplacer("-", "", "_", "", "'", "").Replace(strings.TrimSpace(name))) {
+	case "MD5":
+		return models.IOCTypeMD5
+	case "SHA1":
+		return models.IOCTypeSHA1
+	case "SHA256":
+		return models.IOCTypeSHA256
+	}
+	return ""
+}
+
+// parseTime parses the timestamp formats used by STIX and OpenIOC
+func parseTime(s string) time.Time {
+	s = strings.TrimSpace(s)
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02T15:04:05", "2006-01-02"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// parseConfidence converts a numeric (0-100) or STIX vocabulary
+// (High/Medium/Low) confidence to a score. Returns 0 when unknown.
+func parseConfidence(s string) uint8 {
+	s = strings.TrimSpace(s)
+	if n, err := strconv.Atoi(s); err == nil && n >= 0 && n <= 100 {
+		return uint8(n)
+	}
+
+	switch strings.ToLower(s) {
+	case "high":
+		return 85
+	case "medium":
+		return 50
+	case "low":
+		return 15
+	}
+	return 0
+}
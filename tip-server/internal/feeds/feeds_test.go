@@ -0,0 +1,258 @@
+package feeds
+
+import (
+	"encoding/json"
+	"slices"
+	"strings"
+	"testing"
+
+	"tip-server/internal/models"
+)
+
+// found is the type and value of an indicator
+type found struct {
+	Type  models.IOCType
+	Value string
+}
+
+func values(indicators []Indicator) []found {
+	out := make([]found, len(indicators))
+	for idx, ind := range indicators {
+		out[idx] = found{ind.Type, ind.Value}
+	}
+	return out
+}
+
+func TestSTIX2Patterns(t *testing.T) {
+	const sha256 = "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"
+
+	tests := []struct {
+		name        string
+		pattern     string
+		patternType string
+		want        []found
+	}{
+		{"domain", "[domain-name:value = 'update-checker-cdn.net']", "", []found{{models.IOCTypeDomain, "update-checker-cdn.net"}}},
+		{"url", "[url:value = 'http://update-checker-cdn.net/gate.php']", "stix", []found{{models.IOCTypeURL, "http://update-checker-cdn.net/gate.php"}}},
+		{"ipv4", "[ipv4-addr:value = '203.0.113.77']", "", []found{{models.IOCTypeIPv4, "203.0.113.77"}}},
+		{"ipv6", "[ipv6-addr:value = '2001:db8::1']", "", []found{{models.IOCTypeIPv6, "2001:db8::1"}}},
+		{"cidr", "[ipv4-addr:value = '198.51.100.0/24']", "", []found{{models.IOCTypeCIDR, "198.51.100.0/24"}}},
+		{"quoted hash name", "[file:hashes.'SHA-256' = '" + sha256 + "']", "", []found{{models.IOCTypeSHA256, sha256}}},
+		{"bare hash name", "[file:hashes.MD5 = '098f6bcd4621d373cade4e832627b4f6']", "", []found{{models.IOCTypeMD5, "098f6bcd4621d373cade4e832627b4f6"}}},
+		{"email sender", "[email-message:from_ref.value = 'invoices@payments-portal-secure.com']", "", []found{{models.IOCTypeEmail, "invoices@payments-portal-secure.com"}}},
+		{"traffic destination", "[network-traffic:dst_ref.value = '203.0.113.77']", "", []found{{models.IOCTypeIPv4, "203.0.113.77"}}},
+		{"certificate serial", "[x509-certificate:serial_number = '0a:1b:2c']", "", []found{{models.IOCTypeCertSerial, "0a:1b:2c"}}},
+		{"escaped quote", `[url:value = 'http://evil.example/it\'s']`, "", []found{{models.IOCTypeURL, "http://evil.example/it's"}}},
+		{
+			name:    "combined comparisons",
+			pattern: "[domain-name:value = 'a.example'] OR [ipv4-addr:value = '192.0.2.1' AND file:name = 'x.exe']",
+			want:    []found{{models.IOCTypeDomain, "a.example"}, {models.IOCTypeIPv4, "192.0.2.1"}},
+		},
+		{"not equality", "[domain-name:value MATCHES '^evil']", "", nil},
+		{"unknown object", "[mutex:name = 'Global\\\\Fixture']", "", nil},
+		{"other pattern language", "title: evil", "sigma", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			indicator := map[string]any{
+				"type": "indicator", "id": "indicator--1", "pattern": tt.pattern, "valid_from": "2024-06-01T00:00:00Z",
+			}
+			if tt.patternType != "" {
+				indicator["pattern_type"] = tt.patternType
+			}
+			doc, err := json.Marshal(map[string]any{"type": "bundle", "id": "bundle--1", "objects": []any{indicator}})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			indicators, format, err := Parse(doc)
+			if err != nil || format != FormatSTIX2 {
+				t.Fatalf("Parse() format = %q, err = %v", format, err)
+			}
+			if got := values(indicators); !slices.Equal(got, tt.want) {
+				t.Errorf("indicators = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+const stix2Bundle = `{
+  "type": "bundle",
+  "id": "bundle--fixture",
+  "objects": [
+    {"type": "indicator", "id": "indicator--c2", "pattern": "[domain-name:value = 'update-checker-cdn.net']",
+     "confidence": 85, "labels": ["malicious-activity"], "valid_from": "2024-06-01T00:00:00Z"},
+    {"type": "indicator", "id": "indicator--expired", "pattern": "[ipv4-addr:value = '192.0.2.1']",
+     "valid_from": "2020-01-01T00:00:00Z", "valid_until": "2020-02-01T00:00:00Z"},
+    {"type": "indicator", "id": "indicator--revoked", "pattern": "[ipv4-addr:value = '192.0.2.2']", "revoked": true},
+    {"type": "malware", "id": "malware--loader", "name": "FixtureLoader"},
+    {"type": "intrusion-set", "id": "intrusion-set--1", "name": "Fixture Panda"},
+    {"type": "relationship", "id": "relationship--1", "relationship_type": "indicates",
+     "source_ref": "indicator--c2", "target_ref": "malware--loader"},
+    {"type": "relationship", "id": "relationship--2", "relationship_type": "indicates",
+     "source_ref": "indicator--c2", "target_ref": "intrusion-set--1"}
+  ]
+}`
+
+const stix1Package = `<stix:STIX_Package xmlns:stix="http://stix.mitre.org/stix-1" xmlns:indicator="http://stix.mitre.org/Indicator-2"
+    xmlns:cybox="http://cybox.mitre.org/cybox-2" xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance"
+    xmlns:DomainNameObj="http://cybox.mitre.org/objects#DomainNameObject-1"
+    xmlns:AddressObj="http://cybox.mitre.org/objects#AddressObject-2"
+    xmlns:FileObj="http://cybox.mitre.org/objects#FileObject-2" xmlns:cyboxCommon="http://cybox.mitre.org/common-2">
+  <stix:Observables>
+    <cybox:Observable id="obs-1">
+      <cybox:Object>
+        <cybox:Properties xsi:type="AddressObj:AddressObjectType" category="ipv4-addr">
+          <AddressObj:Address_Value condition="Equals">203.0.113.77##comma##198.51.100.9</AddressObj:Address_Value>
+        </cybox:Properties>
+      </cybox:Object>
+    </cybox:Observable>
+  </stix:Observables>
+  <stix:Indicators>
+    <stix:Indicator id="ind-1" xsi:type="indicator:IndicatorType">
+      <indicator:Type>Domain Watchlist</indicator:Type>
+      <indicator:Observable>
+        <cybox:Object>
+          <cybox:Properties xsi:type="DomainNameObj:DomainNameObjectType">
+            <DomainNameObj:Value condition="Equals">update-checker-cdn.net</DomainNameObj:Value>
+          </cybox:Properties>
+        </cybox:Object>
+      </indicator:Observable>
+      <indicator:Observable>
+        <cybox:Object>
+          <cybox:Properties xsi:type="DomainNameObj:DomainNameObjectType">
+            <DomainNameObj:Value condition="Contains">partial</DomainNameObj:Value>
+          </cybox:Properties>
+        </cybox:Object>
+      </indicator:Observable>
+      <indicator:Indicated_TTP><stix:TTP idref="ttp-1"/></indicator:Indicated_TTP>
+      <indicator:Confidence><stixCommon:Value xmlns:stixCommon="http://stix.mitre.org/common-1">High</stixCommon:Value></indicator:Confidence>
+    </stix:Indicator>
+    <stix:Indicator id="ind-2" xsi:type="indicator:IndicatorType">
+      <indicator:Observable idref="obs-1"/>
+      <indicator:Observable>
+        <cybox:Object>
+          <cybox:Properties xsi:type="FileObj:FileObjectType">
+            <FileObj:Hashes><cyboxCommon:Hash>
+              <cyboxCommon:Type>MD5</cyboxCommon:Type>
+              <cyboxCommon:Simple_Hash_Value condition="Equals">098f6bcd4621d373cade4e832627b4f6</cyboxCommon:Simple_Hash_Value>
+            </cyboxCommon:Hash></FileObj:Hashes>
+          </cybox:Properties>
+        </cybox:Object>
+      </indicator:Observable>
+    </stix:Indicator>
+  </stix:Indicators>
+  <stix:TTPs>
+    <stix:TTP id="ttp-1"><ttp:Behavior xmlns:ttp="http://stix.mitre.org/TTP-1"><ttp:Malware>
+      <ttp:Malware_Instance><ttp:Name>FixtureLoader</ttp:Name></ttp:Malware_Instance>
+    </ttp:Malware></ttp:Behavior></stix:TTP>
+  </stix:TTPs>
+</stix:STIX_Package>`
+
+const openIOCDocument = `<ioc xmlns="http://openioc.org/schemas/OpenIOC_1.1" id="ioc-1" last-modified="2024-06-01T00:00:00">
+  <metadata><short_description>FixtureLoader</short_description></metadata>
+  <criteria>
+    <Indicator operator="OR" id="i-0">
+      <IndicatorItem id="i-1" condition="is">
+        <Context document="Network" search="Network/DNS" type="mir"/>
+        <Content type="string">update-checker-cdn.net</Content>
+      </IndicatorItem>
+      <IndicatorItem id="i-2" condition="is">
+        <Context document="PortItem" search="PortItem/remoteIP" type="mir"/>
+        <Content type="IP">2001:db8::1</Content>
+      </IndicatorItem>
+      <IndicatorItem id="i-3" condition="contains">
+        <Context document="Network" search="Network/DNS" type="mir"/>
+        <Content type="string">partial</Content>
+      </IndicatorItem>
+      <IndicatorItem id="i-4" condition="is" negate="true">
+        <Context document="Network" search="Network/DNS" type="mir"/>
+        <Content type="string">excluded.example</Content>
+      </IndicatorItem>
+    </Indicator>
+  </criteria>
+  <parameters>
+    <param ref-id="i-1" name="confidence"><value>high</value></param>
+  </parameters>
+</ioc>`
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name       string
+		doc        string
+		format     string
+		want       []found
+		family     string // Of the first indicator
+		confidence uint8  // Of the first indicator
+		tags       []string
+		err        string
+	}{
+		{
+			name:       "stix2 bundle",
+			doc:        stix2Bundle,
+			format:     FormatSTIX2,
+			want:       []found{{models.IOCTypeDomain, "update-checker-cdn.net"}},
+			family:     "FixtureLoader",
+			confidence: 85,
+			tags:       []string{"malicious-activity", "intrusion-set:Fixture Panda"},
+		},
+		{
+			name:   "stix1 package",
+			doc:    stix1Package,
+			format: FormatSTIX1,
+			want: []found{
+				{models.IOCTypeDomain, "update-checker-cdn.net"},
+				{models.IOCTypeIPv4, "203.0.113.77"},
+				{models.IOCTypeIPv4, "198.51.100.9"},
+				{models.IOCTypeMD5, "098f6bcd4621d373cade4e832627b4f6"},
+			},
+			family:     "FixtureLoader",
+			confidence: 85,
+			tags:       []string{"Domain Watchlist"},
+		},
+		{
+			name:   "openioc",
+			doc:    openIOCDocument,
+			format: FormatOpenIOC,
+			want: []found{
+				{models.IOCTypeDomain, "update-checker-cdn.net"},
+				{models.IOCTypeIPv6, "2001:db8::1"},
+			},
+			family:     "FixtureLoader",
+			confidence: 85,
+		},
+		{name: "stix2 bad objects", doc: `{"type": "bundle", "id": "bundle--1", "objects": "none"}`, format: FormatSTIX2, err: "invalid STIX 2.x"},
+		{name: "stix1 unclosed", doc: `<STIX_Package><Indicators><Indicator>`, format: FormatSTIX1, err: "invalid STIX 1.x"},
+		{name: "openioc unclosed", doc: `<ioc><criteria>`, format: FormatOpenIOC, err: "invalid OpenIOC"},
+		{name: "other json", doc: `{"type": "report", "id": "report--1"}`},
+		{name: "other xml", doc: `<rss><channel/></rss>`},
+		{name: "text", doc: "update-checker-cdn.net"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			indicators, format, err := Parse([]byte(tt.doc))
+			if format != tt.format {
+				t.Errorf("format = %q, want %q", format, tt.format)
+			}
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Errorf("err = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := values(indicators); !slices.Equal(got, tt.want) {
+				t.Fatalf("indicators = %v, want %v", got, tt.want)
+			}
+			if len(indicators) == 0 {
+				return
+			}
+			first := indicators[0]
+			if first.MalwareFamily != tt.family || first.Confidence != tt.confidence || !slices.Equal(first.Tags, tt.tags) {
+				t.Errorf("first indicator = %+v, want family %q, confidence %d, tags %v", first, tt.family, tt.confidence, tt.tags)
+			}
+		})
+	}
+}
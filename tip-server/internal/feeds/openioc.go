@@ -0,0 +1,108 @@
+package feeds
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"strings"
+
+	"tip-server/internal/models"
+)
+
+// openIOCSearches maps OpenIOC 1.0/1.1 search terms to IOC types. Terms that
+// name an IP resolve to ipv4 or ipv6 from the value.
+var openIOCSearches = map[string]models.IOCType{
+	"portitem/remoteip":                      models.IOCTypeIPv4,
+	"processitem/portlist/portitem/remoteip": models.IOCTypeIPv4,
+	"routeentryitem/destination":             models.IOCTypeIPv4,
+	"network/dns":                            models.IOCTypeDomain,
+	"dnsentryitem/host":                      models.IOCTypeDomain,
+	"dnsentryitem/recordname":                models.IOCTypeDomain,
+	"urlhistoryitem/hostname":                models.IOCTypeDomain,
+	"urlhistoryitem/url":                     models.IOCTypeURL,
+	"fileitem/md5sum":                        models.IOCTypeMD5,
+	"fileitem/sha1sum":                       models.IOCTypeSHA1,
+	"fileitem/sha256sum":                     models.IOCTypeSHA256,
+	"email/from":                             models.IOCTypeEmail,
+}
+
+// parseOpenIOC extracts exact-match ("is") indicator items. Partial matches
+// (contains, starts-with) and negated items are not indicators on their own.
+func parseOpenIOC(content []byte) ([]Indicator, error) {
+	var root node
+	if err := xml.NewDecoder(bytes.NewReader(content)).Decode(&root); err != nil {
+		return nil, fmt.Errorf("invalid OpenIOC document: %w", err)
+	}
+
+	// 1.0 keeps short_description on the root, 1.1 under metadata
+	family := root.childText("short_description")
+	if meta := root.child("metadata"); meta != nil && family == "" {
+		family = meta.childText("short_description")
+	}
+	validFrom := parseTime(root.attr("last-modified"))
+	confidence := openIOCConfidence(&root)
+
+	var indicators []Indicator
+	for _, item := range root.findAll("IndicatorItem") {
+		if cond := strings.ToLower(item.attr("condition")); cond != "is" && cond != "" {
+			continue
+		}
+		if strings.EqualFold(item.attr("negate"), "true") {
+			continue
+		}
+
+		ctx, c := item.child("Context"), item.child("Content")
+		if ctx == nil || c == nil {
+			continue
+		}
+
+		value := strings.TrimSpace(c.Text)
+		iocType := openIOCType(ctx.attr("search"), c.attr("type"), value)
+		if iocType == "" {
+			continue
+		}
+
+		indicators = append(indicators, Indicator{
+			Type:          iocType,
+			Value:         value,
+			Confidence:    confidence[item.attr("id")],
+			MalwareFamily: family,
+			ValidFrom:     validFrom,
+		})
+	}
+
+	return indicators, nil
+}
+
+// openIOCType resolves an item's type from its search term, falling back
+// to the content type attribute
+func openIOCType(search, contentType, value string) models.IOCType {
+	t, ok := openIOCSearches[strings.ToLower(search)]
+	if !ok {
+		switch strings.ToLower(contentType) {
+		case "ip":
+			t = models.IOCTypeIPv4
+		default:
+			t = hashType(contentType)
+		}
+	}
+
+	if t == models.IOCTypeIPv4 {
+		return ipType(value)
+	}
+	return t
+}
+
+// openIOCConfidence reads OpenIOC 1.1 "confidence" parameters by item id
+func openIOCConfidence(root *node) map[string]uint8 {
+	confidence := make(map[string]uint8)
+	for _, param := range root.findAll("param") {
+		if !strings.EqualFold(param.attr("name"), "confidence") {
+			continue
+		}
+		if c := parseConfidence(param.childText("value")); c > 0 {
+			confidence[param.attr("ref-id")] = c
+		}
+	}
+	return confidence
+}
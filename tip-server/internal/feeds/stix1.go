@@ -0,0 +1,179 @@
+package feeds
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"strings"
+
+	"tip-server/internal/models"
+)
+
+// stix1ListDelimiter separates multiple values in a single CybOX property
+const stix1ListDelimiter = "##comma##"
+
+// parseSTIX1 extracts CybOX observables from STIX 1.x indicators, resolving
+// idrefs to package-level observables and indicated TTPs. Packages with no
+// indicators are read as plain observable feeds.
+func parseSTIX1(content []byte) ([]Indicator, error) {
+	var root node
+	if err := xml.NewDecoder(bytes.NewReader(content)).Decode(&root); err != nil {
+		return nil, fmt.Errorf("invalid STIX 1.x document: %w", err)
+	}
+
+	ids := make(map[string]*node)
+	root.index(ids)
+
+	var indicators []Indicator
+	stixIndicators := root.path("Indicators")
+
+	if stixIndicators == nil {
+		for _, obs := range root.findAll("Observable") {
+			indicators = append(indicators, stix1Observable(obs, ids, Indicator{})...)
+		}
+		return indicators, nil
+	}
+
+	for idx := range stixIndicators.Nodes {
+		ind := &stixIndicators.Nodes[idx]
+		if ref := ind.attr("idref"); ref != "" {
+			if ind = ids[ref]; ind == nil {
+				continue
+			}
+		}
+
+		base := Indicator{
+			MalwareFamily: stix1Family(ind, ids),
+		}
+		if c := ind.child("Confidence"); c != nil {
+			base.Confidence = parseConfidence(c.childText("Value"))
+		}
+		for _, c := range ind.Nodes {
+			if label := strings.TrimSpace(c.Text); c.XMLName.Local == "Type" && label != "" {
+				base.Tags = append(base.Tags, label)
+			}
+		}
+		if vt := ind.child("Valid_Time_Position"); vt != nil {
+			base.ValidFrom = parseTime(vt.childText("Start_Time"))
+			base.ValidUntil = parseTime(vt.childText("End_Time"))
+		}
+
+		for _, obs := range ind.findAll("Observable") {
+			indicators = append(indicators, stix1Observable(obs, ids, base)...)
+		}
+	}
+
+	return indicators, nil
+}
+
+// stix1Observable extracts values from an observable (following idrefs)
+func stix1Observable(obs *node, ids map[string]*node, base Indicator) []Indicator {
+	if ref := obs.attr("idref"); ref != "" {
+		if obs = ids[ref]; obs == nil {
+			return nil
+		}
+	}
+
+	var out []Indicator
+	add := func(t models.IOCType, raw string) {
+		for _, v := range strings.Split(raw, stix1ListDelimiter) {
+			if v = strings.TrimSpace(v); v == "" {
+				continue
+			}
+			iocType := t
+			if iocType == "" {
+				if iocType = ipType(v); iocType == "" {
+					continue
+				}
+			}
+			ind := base
+			ind.Type, ind.Value = iocType, v
+			out = append(out, ind)
+		}
+	}
+
+	for _, props := range obs.findAll("Properties") {
+		// Only exact-match conditions identify a single indicator
+		if !exactValue(props) {
+			continue
+		}
+
+		xsiType := props.attr("type")
+		_, objType, _ := strings.Cut(xsiType, ":")
+		if objType == "" {
+			objType = xsiType
+		}
+
+		switch objType {
+		case "AddressObjectType":
+			v := props.child("Address_Value")
+			if v == nil || !exactValue(v) {
+				continue
+			}
+			switch props.attr("category") {
+			case "e-mail":
+				add(models.IOCTypeEmail, v.Text)
+			case "", "ipv4-addr", "ipv6-addr", "cidr":
+				add("", v.Text)
+			case "asn":
+				add(models.IOCTypeASN, v.Text)
+			}
+		case "DomainNameObjectType":
+			if v := props.child("Value"); v != nil && exactValue(v) {
+				add(models.IOCTypeDomain, v.Text)
+			}
+		case "URIObjectType":
+			if v := props.child("Value"); v != nil && exactValue(v) {
+				add(models.IOCTypeURL, v.Text)
+			}
+		case "FileObjectType":
+			for _, h := range props.findAll("Hash") {
+				t := hashType(h.childText("Type"))
+				v := h.child("Simple_Hash_Value")
+				if t != "" && v != nil && exactValue(v) {
+					add(t, v.Text)
+				}
+			}
+		case "EmailMessageObjectType":
+			if v := props.path("Header", "From", "Address_Value"); v != nil && exactValue(v) {
+				add(models.IOCTypeEmail, v.Text)
+			}
+		}
+	}
+
+	return out
+}
+
+// exactValue reports whether a CybOX property is an exact match (or carries
+// no condition at all, i.e. it is an observed value)
+func exactValue(n *node) bool {
+	switch n.attr("condition") {
+	case "", "Equals":
+		return true
+	}
+	return false
+}
+
+// stix1Family names the malware from an indicator's indicated TTPs
+func stix1Family(ind *node, ids map[string]*node) string {
+	for _, ref := range ind.findAll("TTP") {
+		ttp := ref
+		if id := ttp.attr("idref"); id != "" {
+			if ttp = ids[id]; ttp == nil {
+				continue
+			}
+		}
+		for _, mal := range ttp.findAll("Malware_Instance") {
+			if name := mal.childText("Name"); name != "" {
+				return name
+			}
+			if title := mal.childText("Title"); title != "" {
+				return title
+			}
+		}
+		if title := ttp.childText("Title"); title != "" {
+			return title
+		}
+	}
+	return ""
+}
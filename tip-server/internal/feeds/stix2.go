@@ -0,0 +1,161 @@
+package feeds
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"tip-server/internal/models"
+)
+
+// stix2Comparison matches an equality comparison in a STIX pattern, e.g.
+// [file:hashes.'SHA-256' = '...'] or [domain-name:value = 'evil.com']
+var stix2Comparison = regexp.MustCompile(`([a-z0-9-]+):([A-Za-z0-9_.'-]+)\s*=\s*'((?:[^'\\]|\\.)*)'`)
+
+// stix2Object holds the fields used from STIX 2.0/2.1 objects
+type stix2Object struct {
+	Type           string   `json:"type"`
+	ID             string   `json:"id"`
+	Name           string   `json:"name"`
+	Pattern        string   `json:"pattern"`
+	PatternType    string   `json:"pattern_type"` // 2.1 only
+	Labels         []string `json:"labels"`
+	IndicatorTypes []string `json:"indicator_types"` // 2.1 only
+	Confidence     *int     `json:"confidence"`
+	ValidFrom      string   `json:"valid_from"`
+	ValidUntil     string   `json:"valid_until"`
+	Revoked        bool     `json:"revoked"`
+
+	// Relationship objects
+	RelationshipType string `json:"relationship_type"`
+	SourceRef        string `json:"source_ref"`
+	TargetRef        string `json:"target_ref"`
+}
+
+// parseSTIX2 extracts indicators from a bundle (or a single indicator),
+// following "indicates" relationships to name the malware family and tag
+// related intrusion sets, threat actors and campaigns
+func parseSTIX2(content []byte) ([]Indicator, error) {
+	var doc struct {
+		stix2Object
+		Objects []stix2Object `json:"objects"`
+	}
+	if err := json.Unmarshal(content, &doc); err != nil {
+		return nil, fmt.Errorf("invalid STIX 2.x document: %w", err)
+	}
+
+	objects := doc.Objects
+	if doc.Type == "indicator" {
+		objects = []stix2Object{doc.stix2Object}
+	}
+
+	byID := make(map[string]*stix2Object, len(objects))
+	for idx := range objects {
+		byID[objects[idx].ID] = &objects[idx]
+	}
+
+	families := make(map[string]string)
+	related := make(map[string][]string)
+	for _, rel := range objects {
+		if rel.Type != "relationship" || rel.RelationshipType != "indicates" {
+			continue
+		}
+		target, ok := byID[rel.TargetRef]
+		if !ok || target.Name == "" {
+			continue
+		}
+		switch target.Type {
+		case "malware":
+			families[rel.SourceRef] = target.Name
+		case "intrusion-set", "threat-actor", "campaign":
+			related[rel.SourceRef] = append(related[rel.SourceRef], target.Type+":"+target.Name)
+		}
+	}
+
+	var indicators []Indicator
+	for _, obj := range objects {
+		if obj.Type != "indicator" || obj.Revoked {
+			continue
+		}
+		if obj.PatternType != "" && obj.PatternType != "stix" {
+			continue
+		}
+
+		base := Indicator{
+			MalwareFamily: families[obj.ID],
+			ValidFrom:     parseTime(obj.ValidFrom),
+			ValidUntil:    parseTime(obj.ValidUntil),
+		}
+		if obj.Confidence != nil && *obj.Confidence >= 0 && *obj.Confidence <= 100 {
+			base.Confidence = uint8(*obj.Confidence)
+		}
+		base.Tags = append(base.Tags, obj.Labels...)
+		base.Tags = append(base.Tags, obj.IndicatorTypes...)
+		base.Tags = append(base.Tags, related[obj.ID]...)
+
+		for _, m := range stix2Comparison.FindAllStringSubmatch(obj.Pattern, -1) {
+			value := unescapeSTIX2(m[3])
+			iocType := stix2Type(m[1], m[2], value)
+			if iocType == "" {
+				continue
+			}
+
+			ind := base
+			ind.Type, ind.Value = iocType, value
+			indicators = append(indicators, ind)
+		}
+	}
+
+	return indicators, nil
+}
+
+// stix2Type maps a pattern object path to an IOC type
+func stix2Type(object, path, value string) models.IOCType {
+	switch object {
+	case "ipv4-addr", "ipv6-addr":
+		if path == "value" {
+			return ipType(value)
+		}
+	case "domain-name":
+		if path == "value" {
+			return models.IOCTypeDomain
+		}
+	case "url":
+		if path == "value" {
+			return models.IOCTypeURL
+		}
+	case "email-addr":
+		if path == "value" {
+			return models.IOCTypeEmail
+		}
+	case "email-message":
+		if path == "from_ref.value" || path == "sender_ref.value" {
+			return models.IOCTypeEmail
+		}
+	case "network-traffic":
+		if path == "dst_ref.value" || path == "src_ref.value" {
+			return ipType(value)
+		}
+	case "file", "artifact":
+		if algo, ok := strings.CutPrefix(path, "hashes."); ok {
+			return hashType(algo)
+		}
+	case "x509-certificate":
+		if algo, ok := strings.CutPrefix(path, "hashes."); ok {
+			return models.CertificateHashType(hashType(algo), []string{"x509"})
+		}
+		if path == "serial_number" {
+			return models.IOCTypeCertSerial
+		}
+	}
+	return ""
+}
+
+// unescapeSTIX2 resolves \' and \\ escapes in a pattern string literal
+func unescapeSTIX2(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	return strings.NewReplacer(`\'`, `'`, `\\`, `\`).Replace(s)
+}
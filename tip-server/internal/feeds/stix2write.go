@@ -0,0 +1,118 @@
+package feeds
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"strings"
+	"time"
+
+	"tip-server/internal/models"
+)
+
+// stix2Timestamp is the STIX 2.1 timestamp form, UTC with milliseconds
+const stix2Timestamp = "2006-01-02T15:04:05.000Z"
+
+// STIX2Indicator is a STIX 2.1 indicator object as written to TAXII
+// collections
+type STIX2Indicator struct {
+	Type           string   `json:"type"`
+	SpecVersion    string   `json:"spec_version"`
+	ID             string   `json:"id"`
+	Created        string   `json:"created"`
+	Modified       string   `json:"modified"`
+	Name           string   `json:"name"`
+	Description    string   `json:"description,omitempty"`
+	IndicatorTypes []string `json:"indicator_types"`
+	Pattern        string   `json:"pattern"`
+	PatternType    string   `json:"pattern_type"`
+	ValidFrom      string   `json:"valid_from"`
+	Labels         []string `json:"labels,omitempty"`
+	Confidence     int      `json:"confidence"`
+}
+
+// NewSTIX2Indicator describes a match event as a STIX 2.1 indicator, or
+// returns false for IOC types no STIX pattern expresses (JA3). The ID is
+// derived from the type and value, so a value delivered twice updates the
+// same indicator.
+func NewSTIX2Indicator(event models.MatchEvent) (STIX2Indicator, bool) {
+	pattern, ok := stix2Pattern(event.Type, event.Value)
+	if !ok {
+		return STIX2Indicator{}, false
+	}
+
+	ts := event.Timestamp.UTC().Format(stix2Timestamp)
+	if event.Timestamp.IsZero() {
+		ts = time.Now().UTC().Format(stix2Timestamp)
+	}
+	ind := STIX2Indicator{
+		Type:           "indicator",
+		SpecVersion:    "2.1",
+		ID:             "indicator--" + stix2ID(event.Type, event.Value),
+		Created:        ts,
+		Modified:       ts,
+		Name:           event.Value,
+		IndicatorTypes: []string{"malicious-activity"},
+		Pattern:        pattern,
+		PatternType:    "stix",
+		ValidFrom:      ts,
+		Labels:         event.Tags,
+		Confidence:     int(event.Confidence),
+	}
+	if event.MalwareFamily != "" && event.MalwareFamily != "Unknown" {
+		ind.Description = "Malware family: " + event.MalwareFamily
+	}
+	return ind, true
+}
+
+// stix2Pattern returns the STIX pattern matching an IOC value
+func stix2Pattern(t models.IOCType, value string) (string, bool) {
+	literal := "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(value) + "'"
+	switch t {
+	case models.IOCTypeIPv4:
+		return "[ipv4-addr:value = " + literal + "]", true
+	case models.IOCTypeIPv6:
+		return "[ipv6-addr:value = " + literal + "]", true
+	case models.IOCTypeCIDR:
+		if strings.Contains(value, ":") {
+			return "[ipv6-addr:value = " + literal + "]", true
+		}
+		return "[ipv4-addr:value = " + literal + "]", true
+	case models.IOCTypeDomain:
+		return "[domain-name:value = " + literal + "]", true
+	case models.IOCTypeURL:
+		return "[url:value = " + literal + "]", true
+	case models.IOCTypeEmail:
+		return "[email-addr:value = " + literal + "]", true
+	case models.IOCTypeMD5:
+		return "[file:hashes.MD5 = " + literal + "]", true
+	case models.IOCTypeSHA1:
+		return "[file:hashes.'SHA-1' = " + literal + "]", true
+	case models.IOCTypeSHA256:
+		return "[file:hashes.'SHA-256' = " + literal + "]", true
+	case models.IOCTypeImphash:
+		return "[file:extensions.'windows-pebinary-ext'.imphash = " + literal + "]", true
+	case models.IOCTypeCertSHA1:
+		return "[x509-certificate:hashes.'SHA-1' = " + literal + "]", true
+	case models.IOCTypeCertSHA256:
+		return "[x509-certificate:hashes.'SHA-256' = " + literal + "]", true
+	case models.IOCTypeCertSerial:
+		return "[x509-certificate:serial_number = " + literal + "]", true
+	case models.IOCTypeMutex:
+		return "[mutex:name = " + literal + "]", true
+	case models.IOCTypeUserAgent:
+		return "[network-traffic:extensions.'http-request-ext'.request_header.'User-Agent' = " + literal + "]", true
+	case models.IOCTypeASN:
+		if n, ok := strings.CutPrefix(value, "AS"); ok {
+			return "[autonomous-system:number = " + n + "]", true
+		}
+	}
+	return "", false
+}
+
+// stix2ID returns a name-based (version 5 style) UUID for an IOC
+func stix2ID(t models.IOCType, value string) string {
+	sum := sha1.Sum([]byte("tip-server/" + string(t) + "/" + value))
+	sum[6] = sum[6]&0x0f | 0x50
+	sum[8] = sum[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", sum[0:4], sum[4:6], sum[6:8], sum[8:10], sum[10:16])
+}
@@ -0,0 +1,77 @@
+package feeds
+
+import (
+	"encoding/xml"
+	"strings"
+)
+
+// node is a generic XML element. STIX 1.x and OpenIOC spread indicators
+// across many namespaced schemas, so elements are matched by local name.
+type node struct {
+	XMLName xml.Name
+	Attrs   []xml.Attr `xml:",any,attr"`
+	Nodes   []node     `xml:",any"`
+	Text    string     `xml:",chardata"`
+}
+
+// attr returns the value of an attribute by local name
+func (n *node) attr(local string) string {
+	for _, a := range n.Attrs {
+		if a.Name.Local == local {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+// child returns the first direct child with the given local name
+func (n *node) child(local string) *node {
+	for idx := range n.Nodes {
+		if n.Nodes[idx].XMLName.Local == local {
+			return &n.Nodes[idx]
+		}
+	}
+	return nil
+}
+
+// childText returns the trimmed text of a direct child, or ""
+func (n *node) childText(local string) string {
+	if c := n.child(local); c != nil {
+		return strings.TrimSpace(c.Text)
+	}
+	return ""
+}
+
+// path follows a chain of child names
+func (n *node) path(locals ...string) *node {
+	cur := n
+	for _, local := range locals {
+		if cur = cur.child(local); cur == nil {
+			return nil
+		}
+	}
+	return cur
+}
+
+// findAll returns all descendants (depth-first) with the given local name
+func (n *node) findAll(local string) []*node {
+	var out []*node
+	for idx := range n.Nodes {
+		c := &n.Nodes[idx]
+		if c.XMLName.Local == local {
+			out = append(out, c)
+		}
+		out = append(out, c.findAll(local)...)
+	}
+	return out
+}
+
+// index maps every element's id attribute to the element
+func (n *node) index(ids map[string]*node) {
+	if id := n.attr("id"); id != "" {
+		ids[id] = n
+	}
+	for idx := range n.Nodes {
+		n.Nodes[idx].index(ids)
+	}
+}
@@ -0,0 +1,78 @@
+// Package fixtures embeds a small corpus of threat intelligence documents
+// and a known set of IOCs, for exercising the API server and the ingestor
+// over the in-memory backends of package memstore.
+package fixtures
+
+import (
+	"context"
+	"embed"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"tip-server/internal/db"
+	"tip-server/internal/models"
+)
+
+//go:embed corpus
+var corpus embed.FS
+
+// Corpus returns the fixture documents: a plain-text incident report, a
+// STIX 2.1 bundle and a curated IOC CSV
+func Corpus() fs.FS {
+	sub, err := fs.Sub(corpus, "corpus")
+	if err != nil {
+		panic(err) // The directory is embedded above
+	}
+	return sub
+}
+
+// WriteCorpus copies the fixture documents into dir, e.g. to crawl it as
+// DATA_PATH
+func WriteCorpus(dir string) error {
+	return os.CopyFS(dir, Corpus())
+}
+
+// SourceFileID is the source_file_id of the IOCs returned by IOCs
+var SourceFileID = db.GenerateFileID(filepath.Join("fixtures", "report.txt"))
+
+// IOCs returns the indicators named in the incident report, attributed to
+// SourceFileID and first and last seen at now
+func IOCs(now time.Time) []models.IOC {
+	ioc := func(value string, t models.IOCType, confidence uint8) models.IOC {
+		return models.IOC{
+			Value:         value,
+			Type:          t,
+			SourceFileID:  SourceFileID,
+			MalwareFamily: "FixtureLoader",
+			Confidence:    confidence,
+			FirstSeen:     now,
+			LastSeen:      now,
+			Tags:          []string{"fixture"},
+		}
+	}
+
+	return []models.IOC{
+		ioc("update-checker-cdn.net", models.IOCTypeDomain, 85),
+		ioc("http://update-checker-cdn.net/gate.php", models.IOCTypeURL, 80),
+		ioc("203.0.113.77", models.IOCTypeIPv4, 70),
+		ioc("9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08", models.IOCTypeSHA256, 90),
+		ioc("098f6bcd4621d373cade4e832627b4f6", models.IOCTypeMD5, 90),
+		ioc("invoices@payments-portal-secure.com", models.IOCTypeEmail, 60),
+	}
+}
+
+// Seed stores IOCs the way the ingestor does: rows in the IOC store and
+// values in the Bloom filter
+func Seed(ctx context.Context, clients *db.Clients, iocs []models.IOC) error {
+	if err := clients.ClickHouse.BatchInsertIOCs(ctx, iocs); err != nil {
+		return err
+	}
+
+	values := make([]string, len(iocs))
+	for i, ioc := range iocs {
+		values[i] = ioc.Value
+	}
+	return clients.Redis.BFMAdd(ctx, values)
+}
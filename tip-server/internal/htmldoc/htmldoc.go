@@ -0,0 +1,215 @@
+// Package htmldoc separates an HTML document into the parts worth scanning
+// for indicators: visible text and the URLs the page links to or loads.
+// Markup itself (namespaces, doctypes, schema.org microdata, stylesheets) is
+// dropped, since it is a large source of benign domains.
+package htmldoc
+
+import (
+	"bytes"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// Document is the scannable content of an HTML page
+type Document struct {
+	Text    string   // Visible text, one block per line
+	Links   []string // Absolute hrefs, form actions, frame and refresh targets
+	Scripts []string // Absolute script srcs
+}
+
+// sniffSize is the number of bytes inspected to recognise HTML content
+const sniffSize = 512
+
+// Detect reports whether a file is HTML, by extension or content
+func Detect(path string, content []byte) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".html", ".htm", ".xhtml":
+		return true
+	}
+	return strings.HasPrefix(http.DetectContentType(content[:min(len(content), sniffSize)]), "text/html")
+}
+
+// skipText lists elements whose content is not rendered as text
+var skipText = map[atom.Atom]bool{
+	atom.Script:   true,
+	atom.Style:    true,
+	atom.Template: true,
+	atom.Svg:      true,
+	atom.Math:     true,
+}
+
+// linkAttrs names the attribute holding a navigational URL per element
+var linkAttrs = map[atom.Atom]string{
+	atom.A:      "href",
+	atom.Area:   "href",
+	atom.Form:   "action",
+	atom.Iframe: "src",
+	atom.Frame:  "src",
+	atom.Embed:  "src",
+	atom.Object: "data",
+}
+
+// blockElements start a new line of text
+var blockElements = map[atom.Atom]bool{
+	atom.P: true, atom.Div: true, atom.Br: true, atom.Li: true, atom.Tr: true,
+	atom.Td: true, atom.Th: true, atom.H1: true, atom.H2: true, atom.H3: true,
+	atom.H4: true, atom.H5: true, atom.H6: true, atom.Pre: true, atom.Title: true,
+	atom.Section: true, atom.Article: true, atom.Blockquote: true, atom.Table: true,
+}
+
+// Parse tokenizes an HTML document. Malformed markup is tolerated the way
+// browsers tolerate it, so Parse never fails.
+func Parse(content []byte) Document {
+	var (
+		doc   Document
+		text  strings.Builder
+		base  *url.URL
+		skip  int // depth inside non-rendered elements
+		links = make(map[string]bool)
+	)
+	addLink := func(list *[]string, raw string) {
+		if u := resolve(base, raw); u != "" && !links[u] {
+			links[u] = true
+			*list = append(*list, u)
+		}
+	}
+
+	z := html.NewTokenizer(bytes.NewReader(content))
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			break
+		}
+		tok := z.Token()
+
+		switch tt {
+		case html.TextToken:
+			if skip == 0 {
+				text.WriteString(tok.Data)
+			}
+
+		case html.StartTagToken, html.SelfClosingTagToken:
+			if blockElements[tok.DataAtom] {
+				text.WriteByte('\n')
+			}
+			switch tok.DataAtom {
+			case atom.Base:
+				if u, err := url.Parse(attr(tok, "href")); err == nil && u.IsAbs() {
+					base = u
+				}
+			case atom.Script:
+				if src := attr(tok, "src"); src != "" {
+					addLink(&doc.Scripts, src)
+				}
+			case atom.Meta:
+				if strings.EqualFold(attr(tok, "http-equiv"), "refresh") {
+					addLink(&doc.Links, refreshURL(attr(tok, "content")))
+				}
+			default:
+				if name, ok := linkAttrs[tok.DataAtom]; ok {
+					addLink(&doc.Links, attr(tok, name))
+				}
+			}
+			if skipText[tok.DataAtom] && tt == html.StartTagToken {
+				skip++
+			}
+
+		case html.EndTagToken:
+			if skipText[tok.DataAtom] && skip > 0 {
+				skip--
+			}
+			if blockElements[tok.DataAtom] {
+				text.WriteByte('\n')
+			}
+		}
+	}
+
+	doc.Text = collapseBlankLines(text.String())
+	return doc
+}
+
+// Content renders the document as text for the regex extractor: visible
+// text followed by one linked or loaded URL per line
+func (d Document) Content() []byte {
+	var b strings.Builder
+	b.WriteString(d.Text)
+	for _, list := range [][]string{d.Links, d.Scripts} {
+		for _, u := range list {
+			b.WriteByte('\n')
+			b.WriteString(u)
+		}
+	}
+	return []byte(b.String())
+}
+
+// attr returns the value of a tag attribute, or ""
+func attr(tok html.Token, name string) string {
+	for _, a := range tok.Attr {
+		if strings.EqualFold(a.Key, name) {
+			return strings.TrimSpace(a.Val)
+		}
+	}
+	return ""
+}
+
+// resolve makes a link absolute against the document base. Relative links
+// without a base carry no host and are dropped, as are javascript:, data:
+// and fragment-only links.
+func resolve(base *url.URL, raw string) string {
+	if raw == "" || strings.HasPrefix(raw, "#") {
+		return ""
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return ""
+	}
+
+	switch {
+	case u.Scheme == "mailto":
+		return strings.TrimPrefix(raw, u.Scheme+":")
+	case u.IsAbs():
+	case base != nil:
+		u = base.ResolveReference(u)
+	case u.Host != "":
+		// Protocol-relative
+		u.Scheme = "https"
+	default:
+		return ""
+	}
+
+	switch strings.ToLower(u.Scheme) {
+	case "http", "https", "ftp":
+		return u.String()
+	}
+	return ""
+}
+
+// refreshURL extracts the target of a <meta http-equiv="refresh"> content
+// value such as "0; url=https://example.org/"
+func refreshURL(content string) string {
+	_, rest, ok := strings.Cut(content, ";")
+	if !ok {
+		return ""
+	}
+	rest = strings.TrimSpace(rest)
+	if len(rest) < 4 || !strings.EqualFold(rest[:4], "url=") {
+		return ""
+	}
+	return strings.Trim(strings.TrimSpace(rest[4:]), `'"`)
+}
+
+// collapseBlankLines trims each line and drops empty ones
+func collapseBlankLines(s string) string {
+	var lines []string
+	for _, line := range strings.Split(s, "\n") {
+		if line = strings.Join(strings.Fields(line), " "); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,189 @@
+package ingestor
+
+import (
+	"context"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"tip-server/internal/config"
+	"tip-server/internal/db"
+	"tip-server/internal/metrics"
+)
+
+// bloomRetryBackoff is the wait before the first retry of a failed flush;
+// it doubles with each further attempt
+const bloomRetryBackoff = 100 * time.Millisecond
+
+// bloomMaxBacklog bounds the values a writer keeps for another flush while
+// Redis is failing; beyond it they are left to a Bloom rebuild
+const bloomMaxBacklog = 100000
+
+// bloomWriter batches the workers' Bloom filter additions across files and
+// flushes them from one goroutine, so a worker never waits on Redis
+type bloomWriter struct {
+	ctx     context.Context
+	redis   db.Cache
+	metrics *metrics.Metrics
+
+	batchSize int
+	interval  time.Duration
+	retries   int
+
+	adds chan bloomAdd
+	done chan struct{}
+}
+
+// bloomAdd is one file's values. onAdded is called once they are in the
+// filter with, per value, whether the filter had not seen it before.
+type bloomAdd struct {
+	values  []string
+	onAdded func(added []bool)
+}
+
+// newBloomWriter starts a writer; close it to flush what is pending
+func newBloomWriter(ctx context.Context, redis db.Cache, m *metrics.Metrics, cfg config.WorkerConfig) *bloomWriter {
+	w := &bloomWriter{
+		ctx:       ctx,
+		redis:     redis,
+		metrics:   m,
+		batchSize: cfg.BloomBatchSize,
+		interval:  cfg.BloomFlushInterval,
+		retries:   cfg.BloomAddRetries,
+		adds:      make(chan bloomAdd, cfg.Count*2),
+		done:      make(chan struct{}),
+	}
+	go w.run()
+	return w
+}
+
+// add queues values for the filter. It only blocks while the writer is
+// behind by more than a few files per worker.
+func (w *bloomWriter) add(values []string, onAdded func(added []bool)) {
+	if len(values) == 0 {
+		return
+	}
+	w.adds <- bloomAdd{values: values, onAdded: onAdded}
+}
+
+// close flushes the queued values and stops the writer
+func (w *bloomWriter) close() {
+	close(w.adds)
+	<-w.done
+}
+
+// run collects additions until a batch is full or the flush interval
+// passes, then flushes them as one BF.MADD. A batch that fails is kept and
+// flushed again with the next one, on the next tick.
+func (w *bloomWriter) run() {
+	defer close(w.done)
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	var pending []bloomAdd
+	size := 0
+	failing := false
+	for {
+		select {
+		case add, ok := <-w.adds:
+			if !ok {
+				if !w.flush(pending) {
+					w.giveUp(pending)
+				}
+				return
+			}
+			pending = append(pending, add)
+			size += len(add.values)
+			if size < w.batchSize || failing {
+				continue
+			}
+		case <-ticker.C:
+			if len(pending) == 0 {
+				continue
+			}
+		}
+
+		if failing = !w.flush(pending); !failing {
+			pending, size = nil, 0
+		} else if size > bloomMaxBacklog {
+			w.giveUp(pending)
+			pending, size = nil, 0
+		}
+	}
+}
+
+// flush adds the pending values to the filter and hands each file its
+// share of the answer. It reports false if Redis failed after every retry,
+// leaving the values to be flushed again.
+func (w *bloomWriter) flush(pending []bloomAdd) bool {
+	if len(pending) == 0 {
+		return true
+	}
+
+	var values []string
+	for _, add := range pending {
+		values = append(values, add.values...)
+	}
+
+	start := time.Now()
+	added, err := w.addWithRetry(values)
+	w.metrics.RecordBloomAdd(len(values), time.Since(start).Seconds())
+	if err != nil {
+		log.Warn().Err(err).Int("values", len(values)).Msg("Failed to add IOCs to Bloom filter, keeping them for the next flush")
+		return false
+	}
+
+	for _, add := range pending {
+		if add.onAdded != nil {
+			add.onAdded(added[:len(add.values)])
+		}
+		added = added[len(add.values):]
+	}
+	return true
+}
+
+// giveUp drops values the writer could not add and marks the filter for a
+// rebuild, which adds them back from ClickHouse where they are already
+// stored. Uses its own context so it still runs after a shutdown signal.
+func (w *bloomWriter) giveUp(pending []bloomAdd) {
+	var values []string
+	for _, add := range pending {
+		values = append(values, add.values...)
+	}
+	if len(values) == 0 {
+		return
+	}
+	w.metrics.BloomAddFailures.Add(float64(len(values)))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := w.redis.ScheduleBloomRemoval(ctx, values...); err != nil {
+		log.Error().Err(err).Int("values", len(values)).Msg("Failed to add IOCs to Bloom filter or schedule a rebuild; they are missing until the next one")
+		return
+	}
+	log.Error().Int("values", len(values)).Msg("Failed to add IOCs to Bloom filter, scheduled a rebuild to add them")
+}
+
+// addWithRetry adds values to the filter, retrying with backoff up to
+// BLOOM_ADD_RETRIES times
+func (w *bloomWriter) addWithRetry(values []string) ([]bool, error) {
+	backoff := bloomRetryBackoff
+	for attempt := 1; ; attempt++ {
+		added, err := w.redis.BFMAddNew(w.ctx, values)
+		if err == nil {
+			return added, nil
+		}
+		if attempt > w.retries {
+			return nil, err
+		}
+
+		log.Warn().Err(err).Int("attempt", attempt).Msg("Bloom filter add failed, retrying")
+		select {
+		case <-time.After(backoff):
+		case <-w.ctx.Done():
+			return nil, err
+		}
+		backoff *= 2
+	}
+}
@@ -0,0 +1,85 @@
+package ingestor
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"tip-server/internal/config"
+	"tip-server/internal/memstore"
+	"tip-server/internal/metrics"
+)
+
+// failingCache fails Bloom filter additions while fail is set
+type failingCache struct {
+	*memstore.Cache
+	fail atomic.Bool
+}
+
+func (c *failingCache) BFMAddNew(ctx context.Context, items []string) ([]bool, error) {
+	if c.fail.Load() {
+		return nil, errors.New("connection refused")
+	}
+	return c.Cache.BFMAddNew(ctx, items)
+}
+
+func TestBloomWriterFailedFlush(t *testing.T) {
+	values := []string{"update-checker-cdn.net", "203.0.113.77"}
+	cfg := config.WorkerConfig{Count: 1, BloomBatchSize: 100, BloomFlushInterval: 10 * time.Millisecond}
+
+	tests := []struct {
+		name    string
+		recover bool // Redis comes back before the writer closes
+	}{
+		{"redis recovers", true},
+		{"redis stays down", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			cache := &failingCache{Cache: memstore.NewCache()}
+			cache.fail.Store(true)
+			w := newBloomWriter(ctx, cache, metrics.GetMetrics(), cfg)
+
+			var added atomic.Bool
+			w.add(values, func([]bool) { added.Store(true) })
+
+			// Several flushes fail meanwhile
+			time.Sleep(5 * cfg.BloomFlushInterval)
+			if added.Load() {
+				t.Fatal("values reported added while Redis was failing")
+			}
+			if tt.recover {
+				cache.fail.Store(false)
+				for deadline := time.Now().Add(2 * time.Second); !added.Load() && time.Now().Before(deadline); {
+					time.Sleep(cfg.BloomFlushInterval)
+				}
+			}
+			w.close()
+
+			if added.Load() != tt.recover {
+				t.Errorf("added = %v, want %v", added.Load(), tt.recover)
+			}
+			exists, err := cache.BFMExists(ctx, values)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for idx, v := range values {
+				if exists[idx] != tt.recover {
+					t.Errorf("%s in filter = %v, want %v", v, exists[idx], tt.recover)
+				}
+			}
+
+			// Values given up on are left to a rebuild
+			want := int64(0)
+			if !tt.recover {
+				want = int64(len(values))
+			}
+			if pending, _ := cache.PendingBloomRemovals(ctx); pending != want {
+				t.Errorf("values queued for rebuild = %d, want %d", pending, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package ingestor
+
+import (
+	"fmt"
+	"net/netip"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"tip-server/internal/models"
+	"tip-server/internal/pcap"
+)
+
+// Context tags recorded on IOCs extracted from packet captures
+const (
+	pcapTagPrefix       = "pcap:"
+	pcapTagConversation = "pcap:conversation"
+	flowTagPrefix       = "flow:"
+
+	// pcapMaxFlowTags bounds the flows listed on one indicator; a busy
+	// resolver or CDN address can appear in thousands
+	pcapMaxFlowTags = 5
+)
+
+// scanCapture extracts IOCs from a packet capture: DNS query names, HTTP
+// hosts and URLs, TLS SNI and the external endpoints of each conversation.
+// Each value is tagged with how it was seen and the flows it was seen on,
+// and its first-seen time is taken from the capture.
+func (i *Ingestor) scanCapture(p *profile, path, format string, content []byte) (*extraction, error) {
+	capture, err := pcap.Parse(format, content)
+	if err != nil {
+		return nil, err
+	}
+	log.Debug().
+		Str("file", path).
+		Str("format", format).
+		Int("packets", capture.Packets).
+		Int("undecoded", capture.Undecoded).
+		Int("flows", len(capture.Flows)).
+		Msg("Parsing packet capture")
+
+	set := newIndicatorSet()
+	flowTags := make(map[string]int)
+	add := func(t models.IOCType, value string, flow int, seen time.Time, tag string) {
+		tags := []string{tag}
+		key := strings.ToLower(value)
+		if flow >= 0 && flowTags[key] < pcapMaxFlowTags {
+			tags = append(tags, flowTag(capture.Flows[flow]))
+			flowTags[key]++
+		}
+		set.add(t, value, seen, tags...)
+	}
+
+	for _, o := range capture.Observations {
+		switch o.Kind {
+		case pcap.KindHTTPURL:
+			add(models.IOCTypeURL, o.Value, o.Flow, o.Time, pcapTagPrefix+o.Kind)
+		default:
+			if o.Kind == pcap.KindDNSQuery && isLocalName(o.Value) {
+				continue
+			}
+			add(hostType(o.Value), o.Value, o.Flow, o.Time, pcapTagPrefix+o.Kind)
+		}
+	}
+
+	// Internal endpoints are dropped by ScanFields
+	for idx, f := range capture.Flows {
+		for _, ep := range []netip.Addr{f.Src.Addr(), f.Dst.Addr()} {
+			ep = ep.Unmap()
+			add(hostType(ep.String()), ep.String(), idx, f.First, pcapTagConversation)
+		}
+	}
+
+	iocs, report := p.extractor.ScanFields(set.fields)
+	return &extraction{iocs: iocs, report: report, attrs: set.attrs, fileType: format}, nil
+}
+
+// flowTag describes a conversation, e.g.
+// "flow:tcp 10.0.0.5:51234 > 93.184.216.34:443 packets=12 bytes=3400"
+func flowTag(f pcap.Flow) string {
+	return fmt.Sprintf("%s%s packets=%d bytes=%d", flowTagPrefix, f, f.Packets, f.Bytes)
+}
+
+// hostType classifies a hostname or address literal
+func hostType(host string) models.IOCType {
+	addr, err := netip.ParseAddr(host)
+	switch {
+	case err != nil:
+		return models.IOCTypeDomain
+	case addr.Unmap().Is4():
+		return models.IOCTypeIPv4
+	default:
+		return models.IOCTypeIPv6
+	}
+}
+
+// isLocalName reports whether a DNS query is for reverse lookup or
+// link-local service discovery rather than an external name
+func isLocalName(name string) bool {
+	return strings.HasSuffix(name, ".arpa") || strings.HasSuffix(name, ".local")
+}
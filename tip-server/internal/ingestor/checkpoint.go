@@ -0,0 +1,65 @@
+package ingestor
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/rs/zerolog/log"
+
+	"tip-server/internal/models"
+)
+
+// checkpointKey is the Redis key holding the last run's checkpoint
+const checkpointKey = "tip:ingestor:checkpoint"
+
+// checkPreviousRun warns when the previous run did not complete
+func (i *Ingestor) checkPreviousRun() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var prev models.IngestCheckpoint
+	if err := i.redis.GetJSON(ctx, checkpointKey, &prev); err != nil {
+		if !errors.Is(err, redis.Nil) {
+			log.Debug().Err(err).Msg("Failed to load ingestion checkpoint")
+		}
+		return
+	}
+
+	if !prev.Completed {
+		log.Warn().
+			Time("started_at", prev.StartedAt).
+			Str("last_path", prev.LastPath).
+			Int64("abandoned", prev.FilesAbandoned).
+			Msg("Previous ingestion run was interrupted; unprocessed files will be picked up by change detection")
+	}
+}
+
+// saveCheckpoint persists the outcome of this pass, lastPath being the last
+// file it enqueued. Uses its own context so it is still written after a
+// shutdown signal.
+func (i *Ingestor) saveCheckpoint(completed bool, lastPath string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	cp := models.IngestCheckpoint{
+		StartedAt:      i.passStart,
+		FinishedAt:     time.Now(),
+		Completed:      completed,
+		LastPath:       lastPath,
+		FilesProcessed: atomic.LoadInt64(&i.stats.FilesProcessed),
+		FilesSkipped:   atomic.LoadInt64(&i.stats.FilesSkipped),
+		FilesFailed:    atomic.LoadInt64(&i.stats.FilesFailed),
+		FilesAbandoned: atomic.LoadInt64(&i.stats.FilesAbandoned),
+		IOCsExtracted:  atomic.LoadInt64(&i.stats.IOCsExtracted),
+	}
+
+	if err := i.redis.SetJSON(ctx, checkpointKey, cp, 0); err != nil {
+		log.Error().Err(err).Msg("Failed to persist ingestion checkpoint")
+		return
+	}
+
+	log.Info().Bool("completed", completed).Msg("Persisted ingestion checkpoint")
+}
@@ -0,0 +1,64 @@
+package ingestor
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"tip-server/internal/config"
+	"tip-server/internal/models"
+)
+
+// TestCheckpointWithOnDemandRun runs a pass while an on-demand run crawls
+// another directory; run with -race. The run outlasts the pass's crawl, and
+// must not leave its position in the pass's checkpoint.
+func TestCheckpointWithOnDemandRun(t *testing.T) {
+	const passFiles, runFiles = 50, 200
+
+	pass, onDemand := t.TempDir(), t.TempDir()
+	for dir, count := range map[string]int{pass: passFiles, onDemand: runFiles} {
+		for n := 0; n < count; n++ {
+			path := filepath.Join(dir, fmt.Sprintf("notes-%03d.txt", n))
+			if err := os.WriteFile(path, []byte(fmt.Sprintf("Weekly notes %d, nothing to report.\n", n)), 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	// One worker behind a one-job queue keeps the pass crawling while the
+	// run is served
+	i, clients := newRunIngestor(t, map[string]string{
+		"DATA_PATH":    pass + "," + onDemand,
+		"WORKER_COUNT": "1",
+		"QUEUE_SIZE":   "1",
+	})
+	policies, err := config.LoadIngestPolicies(i.reloader.Current())
+	if err != nil {
+		t.Fatal(err)
+	}
+	i.runs = make(chan models.IngestRun, runQueueSize)
+	i.runs <- models.IngestRun{ID: "on-demand", Path: onDemand}
+
+	ctx := context.Background()
+	if err := i.Run(ctx, policies.Directories[:1], policies); err != nil {
+		t.Fatal(err)
+	}
+
+	if processed := atomic.LoadInt64(&i.stats.FilesProcessed); processed != passFiles+runFiles {
+		t.Errorf("processed %d files, want %d from the pass and the run", processed, passFiles+runFiles)
+	}
+
+	var cp models.IngestCheckpoint
+	if err := clients.Redis.GetJSON(ctx, checkpointKey, &cp); err != nil {
+		t.Fatal(err)
+	}
+	if !cp.Completed {
+		t.Error("checkpoint not completed")
+	}
+	if want := filepath.Join(pass, fmt.Sprintf("notes-%03d.txt", passFiles-1)); cp.LastPath != want {
+		t.Errorf("checkpoint last path = %q, want %q from the pass", cp.LastPath, want)
+	}
+}
@@ -0,0 +1,96 @@
+package ingestor
+
+import (
+	"context"
+	"errors"
+	"slices"
+
+	"github.com/rs/zerolog/log"
+
+	"tip-server/internal/clamav"
+	"tip-server/internal/config"
+	"tip-server/internal/models"
+)
+
+// clamavTagPrefix is followed by the signature name on IOCs from detected files
+const clamavTagPrefix = "clamav:"
+
+// newClamAV connects to clamd, returning nil when scanning is disabled or
+// the daemon is unreachable
+func newClamAV(cfg config.ClamAVConfig) *clamav.Client {
+	if cfg.Address == "" {
+		return nil
+	}
+	client, err := clamav.New(cfg.Address, cfg.Timeout)
+	if err == nil {
+		err = client.Ping(context.Background())
+	}
+	if err != nil {
+		log.Warn().Err(err).Str("address", cfg.Address).Msg("ClamAV unavailable - files will not be scanned")
+		return nil
+	}
+	log.Info().Str("address", cfg.Address).Msg("ClamAV scanning enabled")
+	return client
+}
+
+// avScan submits a file to clamd and returns the detection name, or "" when
+// the file is clean, too large or could not be scanned. Scan failures never
+// fail ingestion.
+func (i *Ingestor) avScan(path string, content []byte) string {
+	if i.clamav == nil {
+		return ""
+	}
+	if len(content) > i.cfg.ClamAV.MaxSize {
+		i.metrics.ClamAVScans.WithLabelValues("skipped").Inc()
+		return ""
+	}
+
+	res, err := i.clamav.Scan(i.ctx, content)
+	if err != nil {
+		result := "error"
+		if errors.Is(err, clamav.ErrSizeLimit) {
+			result = "skipped"
+		}
+		i.metrics.ClamAVScans.WithLabelValues(result).Inc()
+		log.Warn().Err(err).Str("file", path).Msg("ClamAV scan failed")
+		return ""
+	}
+	if !res.Infected {
+		i.metrics.ClamAVScans.WithLabelValues("clean").Inc()
+		return ""
+	}
+
+	i.metrics.ClamAVScans.WithLabelValues("detected").Inc()
+	log.Info().Str("file", path).Str("signature", res.Signature).Msg("ClamAV detection")
+	return res.Signature
+}
+
+// addDetection records a detected file's own SHA256 as an indicator and
+// treats the file as a sample, so it is quarantined like an executable
+func addDetection(ext *extraction, contentHash string) {
+	if ext.iocs == nil {
+		ext.iocs = make(map[models.IOCType][]string)
+	}
+	if !slices.Contains(ext.iocs[models.IOCTypeSHA256], contentHash) {
+		ext.iocs[models.IOCTypeSHA256] = append(ext.iocs[models.IOCTypeSHA256], contentHash)
+	}
+	ext.quarantine = true
+}
+
+// applyDetection labels IOCs from a detected file with the signature: the
+// family is taken from the signature name unless a feed already gave one,
+// and confidence is raised by boost
+func applyDetection(iocList []models.IOC, signature string, boost int) {
+	family := clamav.Family(signature)
+	tag := clamavTagPrefix + signature
+	for idx := range iocList {
+		ioc := &iocList[idx]
+		if ioc.MalwareFamily == "" || ioc.MalwareFamily == "Unknown" {
+			ioc.MalwareFamily = family
+		}
+		ioc.Confidence = uint8(min(int(ioc.Confidence)+boost, 100))
+		if !slices.Contains(ioc.Tags, tag) {
+			ioc.Tags = append(slices.Clip(ioc.Tags), tag)
+		}
+	}
+}
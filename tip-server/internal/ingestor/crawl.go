@@ -0,0 +1,194 @@
+package ingestor
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+
+	"tip-server/internal/config"
+	"tip-server/internal/models"
+)
+
+// systemNames are folders and files operating systems and archivers leave
+// in shares, skipped with the hidden ones
+var systemNames = map[string]bool{
+	"$RECYCLE.BIN":              true,
+	"System Volume Information": true,
+	"lost+found":                true,
+	"__MACOSX":                  true,
+	"Thumbs.db":                 true,
+	"desktop.ini":               true,
+}
+
+// crawl walks a policy directory and enqueues its files at the given
+// priority for processing with the directory's extraction profile.
+// Subdirectories listed in policies of their own are left to their policy;
+// hidden, excluded and, unless followed, symlinked entries are skipped.
+// The path of each file enqueued is recorded in last, if non-nil, for the
+// caller's checkpoint.
+func (i *Ingestor) crawl(ctx context.Context, dir config.IngestPolicy, policies []config.IngestPolicy, priority int, last *string) error {
+	worker := i.profile(dir.Profile).worker
+	extensions := make(map[string]bool)
+	for _, ext := range worker.FileExtensions {
+		extensions[strings.ToLower(ext)] = true
+	}
+
+	// Real paths of the trees walked; a symlink into or above one of them
+	// would crawl files twice or loop
+	var walked []string
+
+	var walk func(root, resolved string) error
+	walk = func(root, resolved string) error {
+		walked = append(walked, resolved)
+
+		return filepath.WalkDir(resolved, func(realPath string, d fs.DirEntry, err error) error {
+			// Check for cancellation
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			default:
+			}
+
+			// Files under a followed symlink are recorded under the link
+			path := root + strings.TrimPrefix(realPath, resolved)
+
+			if err != nil {
+				log.Warn().Err(err).Str("path", path).Msg("Error accessing path")
+				return nil // Continue walking
+			}
+
+			if path != dir.Path {
+				rel, _ := filepath.Rel(dir.Path, path)
+				if crawlExcluded(worker, filepath.ToSlash(rel), d.Name()) {
+					if d.IsDir() {
+						return filepath.SkipDir
+					}
+					return nil
+				}
+			}
+
+			var info fs.FileInfo
+			switch {
+			case d.Type()&fs.ModeSymlink != 0:
+				if !worker.FollowSymlinks {
+					return nil
+				}
+				target, err := filepath.EvalSymlinks(realPath)
+				if err != nil {
+					log.Warn().Err(err).Str("path", path).Msg("Broken symlink")
+					return nil
+				}
+				if slices.ContainsFunc(walked, func(w string) bool { return within(target, w) || within(w, target) }) {
+					log.Debug().Str("path", path).Str("target", target).Msg("Symlink target already crawled, skipping")
+					return nil
+				}
+				if info, err = os.Stat(target); err != nil {
+					log.Warn().Err(err).Str("path", path).Msg("Failed to get file info")
+					return nil
+				}
+				if info.IsDir() {
+					return walk(path, target)
+				}
+
+			case d.IsDir():
+				// Skip subtrees with a policy of their own
+				if path != dir.Path && slices.ContainsFunc(policies, func(o config.IngestPolicy) bool { return o.Path == path }) {
+					return filepath.SkipDir
+				}
+				return nil
+
+			default:
+				if info, err = d.Info(); err != nil {
+					log.Warn().Err(err).Str("path", path).Msg("Failed to get file info")
+					return nil
+				}
+			}
+
+			// Check file extension
+			ext := strings.ToLower(filepath.Ext(path))
+			if len(extensions) > 0 && !extensions[ext] {
+				return nil
+			}
+
+			// Enqueue job
+			job := models.FileJob{
+				FilePath:     path,
+				FileSize:     info.Size(),
+				LastModified: info.ModTime(),
+				Directory:    dir.Path,
+				Priority:     priority,
+			}
+
+			if err := i.jobs.Push(ctx, job); err != nil {
+				return err
+			}
+			if last != nil {
+				*last = path
+			}
+			return nil
+		})
+	}
+
+	resolved, err := filepath.EvalSymlinks(dir.Path)
+	if err != nil {
+		return err
+	}
+	return walk(dir.Path, resolved)
+}
+
+// crawlExcluded reports whether the crawl filters skip an entry, given its
+// slash-separated path relative to the crawled directory
+func crawlExcluded(worker config.WorkerConfig, rel, name string) bool {
+	if worker.SkipHidden && (strings.HasPrefix(name, ".") || systemNames[name]) {
+		return true
+	}
+	for _, pattern := range worker.ExcludeGlobs {
+		if matchGlob(pattern, rel) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchGlob reports whether a slash-separated path matches pattern. "**"
+// matches any number of directories and other segments follow path.Match;
+// a pattern without a slash matches the last segment at any depth.
+func matchGlob(pattern, name string) bool {
+	if !strings.Contains(pattern, "/") {
+		ok, _ := path.Match(pattern, path.Base(name))
+		return ok
+	}
+	return matchSegments(strings.Split(strings.Trim(pattern, "/"), "/"), strings.Split(name, "/"))
+}
+
+func matchSegments(pattern, segments []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for skip := 0; skip <= len(segments); skip++ {
+				if matchSegments(pattern[1:], segments[skip:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(segments) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], segments[0]); !ok {
+			return false
+		}
+		pattern, segments = pattern[1:], segments[1:]
+	}
+	return len(segments) == 0
+}
+
+// within reports whether path is dir or inside it
+func within(path, dir string) bool {
+	return path == dir || strings.HasPrefix(path, dir+string(filepath.Separator))
+}
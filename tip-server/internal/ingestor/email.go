@@ -0,0 +1,80 @@
+package ingestor
+
+import (
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"tip-server/internal/mailparse"
+	"tip-server/internal/models"
+)
+
+// Context tags recorded on IOCs extracted from email
+const (
+	emailTagPrefix      = "email:"
+	emailTagReceived    = "email:received"
+	emailTagBody        = "email:body"
+	emailTagAttachment  = "email:attachment"
+	attachmentTagPrefix = "attachment:"
+)
+
+// scanEmail extracts IOCs from a parsed message: sender addresses and their
+// domains, relay IPs from the Received chain, indicators in the bodies and
+// attachment hashes. Each value is tagged with where in the message it was
+// found.
+func (i *Ingestor) scanEmail(p *profile, path, format string, content []byte) (*extraction, error) {
+	msg, err := mailparse.Parse(format, content)
+	if err != nil {
+		return nil, err
+	}
+	log.Debug().
+		Str("file", path).
+		Str("format", format).
+		Int("received", len(msg.Received)).
+		Int("attachments", len(msg.Attachments)).
+		Msg("Parsing email message")
+
+	set := newIndicatorSet()
+
+	for _, header := range mailparse.SenderHeaders {
+		tag := emailTagPrefix + strings.ToLower(header)
+		for _, addr := range msg.Addresses[header] {
+			set.add(models.IOCTypeEmail, addr, time.Time{}, tag)
+			if _, domain, ok := strings.Cut(addr, "@"); ok {
+				set.add(models.IOCTypeDomain, domain, time.Time{}, tag)
+			}
+		}
+	}
+
+	for _, hop := range msg.Received {
+		found, _ := p.extractor.Scan([]byte(hop))
+		for _, t := range []models.IOCType{models.IOCTypeIPv4, models.IOCTypeIPv6} {
+			for _, ip := range found[t] {
+				set.add(t, ip, time.Time{}, emailTagReceived)
+			}
+		}
+	}
+
+	for _, body := range msg.Bodies {
+		found, _ := p.extractor.Scan(i.normalize([]byte(body)))
+		for t, values := range found {
+			for _, v := range values {
+				set.add(t, v, time.Time{}, emailTagBody)
+			}
+		}
+	}
+
+	for _, a := range msg.Attachments {
+		tags := []string{emailTagAttachment}
+		if a.Name != "" {
+			tags = append(tags, attachmentTagPrefix+a.Name)
+		}
+		set.add(models.IOCTypeMD5, a.MD5, time.Time{}, tags...)
+		set.add(models.IOCTypeSHA1, a.SHA1, time.Time{}, tags...)
+		set.add(models.IOCTypeSHA256, a.SHA256, time.Time{}, tags...)
+	}
+
+	iocs, report := p.extractor.ScanFields(set.fields)
+	return &extraction{iocs: iocs, report: report, attrs: set.attrs, fileType: format}, nil
+}
@@ -0,0 +1,91 @@
+package ingestor
+
+import (
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"tip-server/internal/binfile"
+	"tip-server/internal/db"
+	"tip-server/internal/models"
+)
+
+// Context tags recorded on IOCs extracted from executables
+const (
+	sampleTagPrefix   = "sample:" // Followed by the file type, e.g. sample:pe32+
+	sampleTagStrings  = "sample:strings"
+	sampleTagResource = "sample:resource"
+	resourceTagPrefix = "resource:"
+)
+
+// scanExecutable hashes a PE/ELF sample and scans its embedded strings.
+// The sample's own hashes and imphash, and the SHA256 of any executable
+// embedded as a PE resource, are recorded regardless of the extraction caps.
+func (i *Ingestor) scanExecutable(p *profile, path, format string, content []byte) (*extraction, error) {
+	sample, err := binfile.Analyze(format, content, i.cfg.Worker.MinStringLength)
+	if err != nil {
+		return nil, err
+	}
+	log.Debug().
+		Str("file", path).
+		Str("type", sample.Type).
+		Str("arch", sample.Arch).
+		Str("imphash", sample.Imphash).
+		Int("strings", len(sample.Strings)).
+		Int("resources", len(sample.Resources)).
+		Msg("Analyzing executable")
+
+	found, report, err := p.extractor.ScanWithReport([]byte(strings.Join(sample.Strings, "\n")))
+	if err != nil {
+		return nil, err
+	}
+
+	set := newIndicatorSet()
+	for t, values := range found {
+		for _, v := range values {
+			set.add(t, v, time.Time{}, sampleTagStrings)
+		}
+	}
+
+	typeTag := sampleTagPrefix + sample.Type
+	set.add(models.IOCTypeMD5, sample.MD5, time.Time{}, typeTag)
+	set.add(models.IOCTypeSHA1, sample.SHA1, time.Time{}, typeTag)
+	set.add(models.IOCTypeSHA256, sample.SHA256, time.Time{}, typeTag)
+	if sample.Imphash != "" {
+		set.add(models.IOCTypeImphash, sample.Imphash, time.Time{}, typeTag)
+	}
+	for _, r := range sample.Resources {
+		if r.Executable {
+			set.add(models.IOCTypeSHA256, r.SHA256, time.Time{}, sampleTagResource, resourceTagPrefix+r.Type)
+		}
+	}
+
+	return &extraction{
+		iocs:       set.fields,
+		report:     report,
+		attrs:      set.attrs,
+		fileType:   sample.Type,
+		quarantine: true,
+	}, nil
+}
+
+// quarantine stores an executable sample in MinIO under the quarantine
+// prefix and returns its key, or "" if it could not be stored
+func (i *Ingestor) quarantine(path, contentHash string, content []byte) string {
+	key := db.QuarantineKey(contentHash)
+
+	exists, err := i.minio.ObjectExists(i.ctx, key)
+	if err != nil {
+		log.Debug().Err(err).Str("object", key).Msg("Failed to check for existing object")
+	}
+	if exists {
+		return key
+	}
+
+	if _, err := i.minio.UploadQuarantined(i.ctx, key, content); err != nil {
+		log.Warn().Err(err).Str("file", path).Msg("Failed to quarantine sample")
+		return ""
+	}
+	return key
+}
@@ -0,0 +1,99 @@
+package ingestor
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"tip-server/internal/db"
+	"tip-server/internal/models"
+)
+
+// fileIdentity returns the ID of a file under FILE_IDENTITY. Before the
+// file is read contentHash is "", and the content-derived strategies return
+// "". The inode strategy falls back to the path where inodes are unknown.
+func (i *Ingestor) fileIdentity(path, contentHash string) string {
+	switch i.cfg.Worker.FileIdentity {
+	case models.FileIdentityContent:
+		if contentHash == "" {
+			return ""
+		}
+		return db.ContentFileID(contentHash)
+	case models.FileIdentityPathContent:
+		if contentHash == "" {
+			return ""
+		}
+		return db.PathContentFileID(path, contentHash)
+	case models.FileIdentityInode:
+		if device, inode, ok := fileInode(path); ok {
+			return db.InodeFileID(device, inode)
+		}
+	}
+	return db.GenerateFileID(path)
+}
+
+// registration returns the registry entry of a file by ID, or by path while
+// its ID is not known yet. Tombstoned entries of another path are ignored,
+// so a new file is never mistaken for a deleted one.
+func (i *Ingestor) registration(fileID, path string) *models.FileMetadata {
+	var prev *models.FileMetadata
+	var err error
+	if fileID == "" {
+		prev, err = i.ch.GetFileMetadataByPath(i.ctx, path)
+	} else {
+		prev, err = i.ch.GetFileMetadata(i.ctx, fileID)
+	}
+	if err != nil {
+		log.Debug().Err(err).Str("file", path).Msg("Change detection query (new file)")
+		return nil
+	}
+	if prev.FilePath != path && prev.ScanStatus == models.ScanStatusDeleted {
+		return nil
+	}
+	return prev
+}
+
+// skipRegistered skips an unchanged file. When it is registered under
+// another path and that path no longer exists, the file was renamed and its
+// registration follows it; a copy or hard link, whose registered path still
+// exists, leaves the registration where it is.
+func (i *Ingestor) skipRegistered(prev *models.FileMetadata, job models.FileJob, result models.ProcessResult) models.ProcessResult {
+	if prev.FilePath == job.FilePath {
+		return i.skipUnchanged(result)
+	}
+
+	if _, err := os.Lstat(prev.FilePath); !errors.Is(err, fs.ErrNotExist) {
+		log.Debug().Str("file", job.FilePath).Str("registered", prev.FilePath).Msg("Copy of a registered file, skipping")
+		return i.skipUnchanged(result)
+	}
+
+	moved := *prev
+	moved.FilePath = job.FilePath
+	moved.FileSize = uint64(job.FileSize)
+	moved.LastModified = job.LastModified
+	if err := i.ch.UpsertFileMetadata(i.ctx, &moved); err != nil {
+		log.Warn().Err(err).Str("file", job.FilePath).Msg("Failed to update file registry")
+	} else {
+		log.Info().Str("file", job.FilePath).Str("from", prev.FilePath).Str("file_id", prev.FileID).Msg("Registered file renamed")
+	}
+	return i.skipUnchanged(result)
+}
+
+// supersede tombstones the registration of a path's previous content once
+// the path holds content with another identity (content-derived strategies)
+func (i *Ingestor) supersede(prev *models.FileMetadata, fileID string) {
+	if prev == nil || prev.FileID == fileID || prev.ScanStatus == models.ScanStatusDeleted {
+		return
+	}
+	now := time.Now()
+	old := *prev
+	old.ScanStatus = models.ScanStatusDeleted
+	old.DeletedAt = &now
+	old.ErrorMessage = "superseded by " + fileID
+	if err := i.ch.UpsertFileMetadata(i.ctx, &old); err != nil {
+		log.Warn().Err(err).Str("file", prev.FilePath).Msg("Failed to update file registry")
+	}
+}
@@ -0,0 +1,9 @@
+//go:build !unix
+
+package ingestor
+
+// fileInode is unavailable where file info carries no inode; FILE_IDENTITY
+// inode then falls back to the path
+func fileInode(path string) (device, inode uint64, ok bool) {
+	return 0, 0, false
+}
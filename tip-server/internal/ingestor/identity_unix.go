@@ -0,0 +1,21 @@
+//go:build unix
+
+package ingestor
+
+import (
+	"os"
+	"syscall"
+)
+
+// fileInode returns the device and inode a file is stored at
+func fileInode(path string) (device, inode uint64, ok bool) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0, 0, false
+	}
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, 0, false
+	}
+	return uint64(st.Dev), uint64(st.Ino), true
+}
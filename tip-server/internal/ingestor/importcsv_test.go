@@ -0,0 +1,149 @@
+package ingestor
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"tip-server/internal/models"
+)
+
+func TestParseCSVMapping(t *testing.T) {
+	tests := []struct {
+		spec string
+		want CSVMapping
+		err  string
+	}{
+		{spec: "value=1", want: CSVMapping{Value: 1}},
+		{spec: " Value = 2 , TYPE=1,family=3,confidence=4,tags=5,", want: CSVMapping{Value: 2, Type: 1, Family: 3, Confidence: 4, Tags: 5}},
+		{spec: "type=1", err: "value column is required"},
+		{spec: "", err: "value column is required"},
+		{spec: "value", err: "expected field=column"},
+		{spec: "value=0", err: "column must be a number"},
+		{spec: "value=first", err: "column must be a number"},
+		{spec: "value=1,severity=2", err: "unknown field"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			m, err := ParseCSVMapping(tt.spec)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Errorf("ParseCSVMapping() = %+v, %v; want error %q", m, err, tt.err)
+				}
+				return
+			}
+			if err != nil || m != tt.want {
+				t.Errorf("ParseCSVMapping() = %+v, %v; want %+v", m, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestImportCSV(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		mapping string
+		opts    CSVImportOptions
+		stats   CSVImportStats
+		want    []models.IOC // Type, value, family, confidence and tags
+	}{
+		{
+			name: "header and quoting",
+			content: "indicator,kind,family,score,labels\n" +
+				"update-checker-cdn.net,Domain,FixtureLoader,85,c2;loader\n" +
+				`"http://update-checker-cdn.net/gate.php?a=1,b=2", url ,"Fixture, Loader",,` + "\n" +
+				"  203.0.113.77\n",
+			mapping: "value=1,type=2,family=3,confidence=4,tags=5",
+			opts:    CSVImportOptions{Delimiter: ',', Header: true},
+			stats:   CSVImportStats{Rows: 3, Imported: 3, New: 3},
+			want: []models.IOC{
+				{Type: models.IOCTypeDomain, Value: "update-checker-cdn.net", MalwareFamily: "FixtureLoader", Confidence: 85, Tags: []string{"c2", "loader"}},
+				{Type: models.IOCTypeURL, Value: "http://update-checker-cdn.net/gate.php?a=1,b=2", MalwareFamily: "Fixture, Loader", Confidence: 50},
+				{Type: models.IOCTypeIPv4, Value: "203.0.113.77", MalwareFamily: "Unknown", Confidence: 50},
+			},
+		},
+		{
+			name:    "other delimiter and column order",
+			content: "c2 server;203.0.113.9;10\nbeacon;asn 13335;20\n",
+			mapping: "value=2,confidence=3",
+			opts:    CSVImportOptions{Delimiter: ';', Source: "partner-feed"},
+			stats:   CSVImportStats{Rows: 2, Imported: 2, New: 2},
+			want: []models.IOC{
+				{Type: models.IOCTypeIPv4, Value: "203.0.113.9", MalwareFamily: "Unknown", Confidence: 10},
+				{Type: models.IOCTypeASN, Value: "AS13335", MalwareFamily: "Unknown", Confidence: 20},
+			},
+		},
+		{
+			name: "bad rows",
+			content: "update-checker-cdn.net,domain,90\n" +
+				"update-checker-cdn.net,domain,90\n" + // Duplicate
+				",domain,90\n" + // Empty value
+				"only-one-column\n" +
+				"update-checker-cdn.net,ipv4,90\n" + // Not of the stated type
+				"c2.example.org,domain,high\n" + // Bad confidence
+				"c2.example.org,domain,101\n" +
+				"not an indicator,,\n" +
+				"\xff\xfe.example,,\n",
+			mapping: "value=1,type=2,confidence=3",
+			opts:    CSVImportOptions{Delimiter: ','},
+			stats:   CSVImportStats{Rows: 9, Imported: 1, Rejected: 7, New: 1},
+			want: []models.IOC{
+				{Type: models.IOCTypeDomain, Value: "update-checker-cdn.net", MalwareFamily: "Unknown", Confidence: 90},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			i, clients := newRunIngestor(t, nil)
+			path := filepath.Join(t.TempDir(), "iocs.csv")
+			if err := os.WriteFile(path, []byte(tt.content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			mapping, err := ParseCSVMapping(tt.mapping)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.opts.Mapping = mapping
+
+			stats, err := i.ImportCSV(context.Background(), path, tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if stats != tt.stats {
+				t.Errorf("stats = %+v, want %+v", stats, tt.stats)
+			}
+
+			source := csvSourcePrefix + tt.opts.Source
+			if tt.opts.Source == "" {
+				source = csvSourcePrefix + "iocs.csv"
+			}
+			stored := storedIOCs(t, clients, source)
+			if len(stored) != len(tt.want) {
+				t.Fatalf("stored %d IOCs, want %d: %+v", len(stored), len(tt.want), stored)
+			}
+			for _, w := range tt.want {
+				got, ok := stored[w.Value]
+				if !ok {
+					t.Errorf("%s not stored", w.Value)
+					continue
+				}
+				if got.Type != w.Type || got.MalwareFamily != w.MalwareFamily || got.Confidence != w.Confidence || !slices.Equal(got.Tags, w.Tags) {
+					t.Errorf("%s = %s %q %d %q, want %s %q %d %q", w.Value,
+						got.Type, got.MalwareFamily, got.Confidence, got.Tags, w.Type, w.MalwareFamily, w.Confidence, w.Tags)
+				}
+			}
+		})
+	}
+
+	t.Run("missing file", func(t *testing.T) {
+		i, _ := newRunIngestor(t, nil)
+		_, err := i.ImportCSV(context.Background(), filepath.Join(t.TempDir(), "none.csv"), CSVImportOptions{Delimiter: ','})
+		if err == nil || !strings.Contains(err.Error(), "failed to open CSV") {
+			t.Errorf("err = %v, want an open error", err)
+		}
+	})
+}
@@ -0,0 +1,856 @@
+package ingestor
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"tip-server/internal/clamav"
+	"tip-server/internal/config"
+	"tip-server/internal/db"
+	"tip-server/internal/embed"
+	"tip-server/internal/events"
+	"tip-server/internal/extractor"
+	"tip-server/internal/feeds"
+	"tip-server/internal/metrics"
+	"tip-server/internal/models"
+	"tip-server/internal/redact"
+)
+
+// Ingestor orchestrates the file crawling and IOC extraction
+type Ingestor struct {
+	cfg       *config.Config
+	ch        db.IOCStore
+	redis     db.Cache
+	minio     db.ObjectStore
+	qdrant    *db.QdrantClient
+	index     *embed.Index
+	clamav    *clamav.Client
+	extractor *extractor.Extractor
+	redactor  *redact.Redactor // nil unless EMAIL_REDACTION is set
+	metrics   *metrics.Metrics
+	bus       events.Publisher
+
+	// Worker pool
+	jobs    *jobQueue
+	results chan models.ProcessResult
+	wg      sync.WaitGroup
+	bloom   *bloomWriter // Batches the workers' Bloom filter additions during a pass
+
+	// Statistics
+	stats IngestorStats
+
+	// Control
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// Draining: cancelled when the shutdown drain timeout expires, after
+	// which queued jobs are abandoned instead of processed
+	drainCtx context.Context
+	abandon  context.CancelFunc
+
+	// Live configuration; worker count, file extensions and extraction
+	// limits are re-read at the start of every pass
+	reloader  *config.Reloader
+	passStart time.Time
+
+	// Directories crawled, by path, and the extraction profiles of the
+	// current pass
+	policies    *config.IngestPolicies
+	dirMu       sync.RWMutex
+	directories map[string]config.IngestPolicy
+	profiles    map[string]*profile
+
+	// On-demand runs requested through the API; nil outside watch mode
+	runs chan models.IngestRun
+}
+
+// IngestorStats tracks ingestion statistics
+type IngestorStats struct {
+	FilesProcessed int64
+	FilesSkipped   int64
+	FilesFailed    int64
+	FilesAbandoned int64
+	FilesStopped   int64 // Oversized or timed out
+	IOCsExtracted  int64
+	BytesProcessed int64
+	StartTime      time.Time
+}
+
+// NewIngestor creates an ingestor over shared storage connections. The
+// ingestor reads its configuration from reloader.
+func NewIngestor(reloader *config.Reloader, clients *db.Clients) (*Ingestor, error) {
+	cfg := reloader.Current()
+	extract, err := extractor.NewExtractorFromConfig(cfg.Extractor)
+	if err != nil {
+		return nil, err
+	}
+
+	policies, err := config.LoadIngestPolicies(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	ch, redis, minio, qdrant := clients.ClickHouse, clients.Redis, clients.MinIO, clients.Qdrant
+
+	// Connect to the external event bus (no-op when not configured)
+	bus, err := events.NewPublisher(cfg.EventBus)
+	if err != nil {
+		return nil, err
+	}
+
+	// Similarity index for misc files (optional)
+	index, err := embed.NewIndex(context.Background(), qdrant, cfg.Embedding)
+	if err != nil {
+		log.Warn().Err(err).Msg("Similarity index unavailable - misc files will not be embedded")
+		index = nil
+	}
+
+	// Antivirus scanning (optional)
+	av := newClamAV(cfg.ClamAV)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	drainCtx, abandon := context.WithCancel(context.Background())
+
+	return &Ingestor{
+		cfg:       cfg,
+		ch:        ch,
+		redis:     redis,
+		minio:     minio,
+		qdrant:    qdrant,
+		index:     index,
+		clamav:    av,
+		extractor: extract,
+		redactor:  redact.New(cfg.Redaction),
+		metrics:   metrics.GetMetrics(),
+		bus:       bus,
+		ctx:       ctx,
+		cancel:    cancel,
+		drainCtx:  drainCtx,
+		abandon:   abandon,
+		stats: IngestorStats{
+			StartTime: time.Now(),
+		},
+		reloader: reloader,
+		policies: policies,
+		profiles: map[string]*profile{"": {worker: cfg.Worker, extractor: extract}},
+	}, nil
+}
+
+// Close stops outstanding work and closes the ingestor's own connections.
+// The shared storage connections are left to their owner.
+func (i *Ingestor) Close() {
+	i.abandon()
+	i.cancel()
+	if err := i.bus.Close(); err != nil {
+		log.Warn().Err(err).Msg("Failed to close event bus publisher")
+	}
+}
+
+// Run performs a single ingestion pass over dirs, highest priority first
+func (i *Ingestor) Run(ctx context.Context, dirs []config.IngestPolicy, policies *config.IngestPolicies) error {
+	cfg := i.reloader.Current()
+	i.passStart = time.Now()
+	dirs = byPriority(dirs)
+
+	paths := make([]string, len(dirs))
+	for idx, dir := range dirs {
+		paths[idx] = dir.Path
+	}
+	log.Info().
+		Strs("directories", paths).
+		Int("workers", cfg.Worker.Count).
+		Int("batch_size", cfg.Worker.BatchSize).
+		Msg("Starting ingestion")
+
+	i.checkPreviousRun()
+
+	// Crawl directories and enqueue jobs. Directories with a policy of
+	// their own are skipped when nested in another.
+	var (
+		crawled  []string
+		lastPath string // Last file the pass enqueued, for the checkpoint
+	)
+	i.process(ctx, dirs, policies, func() {
+		// On-demand runs requested meanwhile are crawled alongside, their
+		// files ahead of the pass's
+		stopRuns := make(chan struct{})
+		var runsWg sync.WaitGroup
+		runsWg.Add(1)
+		go i.serveRuns(ctx, policies, stopRuns, &runsWg)
+
+		for _, dir := range dirs {
+			if err := i.crawl(ctx, dir, policies.Directories, models.JobPriorityCrawl, &lastPath); err != nil {
+				log.Error().Err(err).Str("directory", dir.Path).Msg("Crawl error")
+				if ctx.Err() != nil {
+					break
+				}
+				continue
+			}
+			crawled = append(crawled, dir.Path)
+		}
+		close(stopRuns)
+		runsWg.Wait()
+	})
+	crawlComplete := len(crawled) == len(dirs)
+
+	i.saveCheckpoint(crawlComplete && ctx.Err() == nil, lastPath)
+
+	// Only reconcile fully crawled directories; a partial walk says nothing
+	// about deletions
+	if i.cfg.Worker.DetectDeletions && ctx.Err() == nil {
+		for _, root := range crawled {
+			if err := i.reconcileDeletions(ctx, root); err != nil {
+				log.Error().Err(err).Str("directory", root).Msg("Deletion reconciliation failed")
+			}
+		}
+	}
+
+	log.Info().Msg("Ingestion complete")
+	return nil
+}
+
+// process starts the workers with the current settings, runs crawl to
+// enqueue jobs, then waits for every queued file to be processed and its
+// IOCs stored
+func (i *Ingestor) process(ctx context.Context, dirs []config.IngestPolicy, policies *config.IngestPolicies, crawl func()) {
+	cfg := i.reloader.Current()
+
+	// Apply reloadable settings for this pass
+	if profiles, err := buildProfiles(cfg, policies.Profiles); err == nil {
+		i.profiles = profiles
+		i.extractor = profiles[""].extractor
+	} else {
+		log.Warn().Err(err).Msg("Failed to reload extraction settings, keeping previous ones")
+	}
+	i.directories = make(map[string]config.IngestPolicy, len(dirs))
+	for _, dir := range dirs {
+		i.setDirectory(dir)
+	}
+	i.jobs = newJobQueue(cfg.Worker.QueueSize, cfg.Worker.QueueOrder)
+	i.results = make(chan models.ProcessResult, cfg.Worker.Count*2)
+
+	// Start result collector
+	var collectorWg sync.WaitGroup
+	collectorWg.Add(1)
+	go i.resultCollector(&collectorWg)
+
+	// Start the Bloom filter writer and the workers feeding it
+	i.bloom = newBloomWriter(i.ctx, i.redis, i.metrics, cfg.Worker)
+	for w := 0; w < cfg.Worker.Count; w++ {
+		i.wg.Add(1)
+		go i.worker(w)
+	}
+
+	// Start batch processor
+	batchChan := make(chan []models.IOC, 10)
+	var batchWg sync.WaitGroup
+	batchWg.Add(1)
+	go i.batchProcessor(batchChan, &batchWg)
+
+	crawl()
+
+	// Close the job queue and wait for workers. On shutdown, queued jobs are
+	// drained until the timeout, then abandoned; in-flight files always finish
+	// so no file is left with a partially inserted IOC set.
+	i.jobs.Close()
+
+	workersDone := make(chan struct{})
+	go func() {
+		i.wg.Wait()
+		close(workersDone)
+	}()
+
+	if ctx.Err() != nil {
+		log.Info().
+			Int("queued", i.jobs.Len()).
+			Dur("timeout", i.cfg.Worker.ShutdownTimeout).
+			Msg("Crawl stopped, draining queued jobs")
+
+		select {
+		case <-workersDone:
+		case <-time.After(i.cfg.Worker.ShutdownTimeout):
+			log.Warn().Msg("Drain timeout exceeded, abandoning queued jobs")
+			i.abandon()
+		}
+	}
+	<-workersDone
+
+	// Close results channel and wait for collector
+	close(i.results)
+	collectorWg.Wait()
+
+	// Close batch channel and flush pending batches
+	close(batchChan)
+	batchWg.Wait()
+
+	// Flush Bloom filter additions before the pass is checkpointed
+	i.bloom.close()
+}
+
+// worker processes files from the job queue, most urgent first
+func (i *Ingestor) worker(id int) {
+	defer i.wg.Done()
+
+	i.metrics.ActiveWorkers.Inc()
+	defer i.metrics.ActiveWorkers.Dec()
+
+	for {
+		job, ok := i.jobs.Pop()
+		if !ok {
+			return
+		}
+		if i.drainCtx.Err() != nil {
+			atomic.AddInt64(&i.stats.FilesAbandoned, 1)
+			continue
+		}
+
+		result := i.processFile(job)
+
+		select {
+		case i.results <- result:
+		case <-i.ctx.Done():
+			return
+		}
+	}
+}
+
+// applyFeedAttributes copies source confidence, family, tags and validity
+// start onto IOCs parsed from a feed document
+func applyFeedAttributes(iocList []models.IOC, attrs map[string]feeds.Indicator) {
+	for idx := range iocList {
+		a, ok := attrs[strings.ToLower(iocList[idx].Value)]
+		if !ok {
+			continue
+		}
+		if a.Confidence > 0 {
+			iocList[idx].Confidence = a.Confidence
+		}
+		if a.MalwareFamily != "" {
+			iocList[idx].MalwareFamily = a.MalwareFamily
+		}
+		if len(a.Tags) > 0 {
+			iocList[idx].Tags = a.Tags
+		}
+		if !a.ValidFrom.IsZero() && a.ValidFrom.Before(iocList[idx].FirstSeen) {
+			iocList[idx].FirstSeen = a.ValidFrom
+		}
+		if !a.ValidUntil.IsZero() {
+			validUntil := a.ValidUntil
+			iocList[idx].ValidUntil = &validUntil
+		}
+	}
+}
+
+// indicatorSet collects candidates read from structured content along with
+// the tags and earliest sighting of each, in the shape handlers return
+type indicatorSet struct {
+	fields map[models.IOCType][]string
+	attrs  map[string]feeds.Indicator
+}
+
+func newIndicatorSet() *indicatorSet {
+	return &indicatorSet{
+		fields: make(map[models.IOCType][]string),
+		attrs:  make(map[string]feeds.Indicator),
+	}
+}
+
+// add records a candidate, merging tags with earlier sightings of the same
+// value. A zero seen time leaves the first-seen time to the ingestor.
+func (s *indicatorSet) add(t models.IOCType, value string, seen time.Time, tags ...string) {
+	key := strings.ToLower(value)
+	ind, ok := s.attrs[key]
+	if !ok {
+		s.fields[t] = append(s.fields[t], value)
+		ind = feeds.Indicator{Type: t, Value: value}
+	}
+	for _, tag := range tags {
+		if !slices.Contains(ind.Tags, tag) {
+			ind.Tags = append(ind.Tags, tag)
+		}
+	}
+	if !seen.IsZero() && (ind.ValidFrom.IsZero() || seen.Before(ind.ValidFrom)) {
+		ind.ValidFrom = seen
+	}
+	s.attrs[key] = ind
+}
+
+// processFile processes a single file
+func (i *Ingestor) processFile(job models.FileJob) models.ProcessResult {
+	startTime := time.Now()
+
+	result := models.ProcessResult{
+		FilePath: job.FilePath,
+		FileID:   i.fileIdentity(job.FilePath, ""),
+	}
+
+	// Fast path: unchanged size and mtime. Content-derived identities are
+	// only known once the file is read, so until then its registration is
+	// found by path, and a file stopped before being read is recorded under
+	// it.
+	prev := i.registration(result.FileID, job.FilePath)
+	byContent := result.FileID == ""
+	if byContent {
+		result.FileID = db.GenerateFileID(job.FilePath)
+		if prev != nil {
+			result.FileID = prev.FileID
+		}
+	}
+
+	// Files once too large are retried when MAX_FILE_SIZE_MB has been raised
+	dir := i.directory(job.Directory)
+	p := i.profile(dir.Profile)
+	oversized := p.worker.MaxFileSize > 0 && job.FileSize > p.worker.MaxFileSize
+
+	// Files reset by POST /admin/files/reprocess, or whose last attempt
+	// failed, are read again whatever their size, mtime and content
+	retry := prev != nil && (prev.ScanStatus == models.ScanStatusPending || prev.ScanStatus == models.ScanStatusFailed)
+
+	if prev != nil && !retry && i.cfg.Worker.ChangeDetection != "hash" && metadataUnchanged(prev, job) &&
+		(prev.ScanStatus != models.ScanStatusOversized || oversized) {
+		return i.skipRegistered(prev, job, result)
+	}
+	if oversized {
+		return i.stopFile(result, job, models.ScanStatusOversized,
+			fmt.Errorf("file size %d bytes exceeds MAX_FILE_SIZE_MB (%d bytes)", job.FileSize, p.worker.MaxFileSize))
+	}
+
+	// Read file content
+	content, err := os.ReadFile(job.FilePath)
+	if err != nil {
+		log.Warn().Err(err).Str("file", job.FilePath).Msg("Failed to read file")
+		return i.failFile(result, job, err)
+	}
+
+	result.ContentHash = db.ContentHash(content)
+
+	// A path whose content changed holds a new file under content-derived
+	// identities, which may already be registered elsewhere
+	if byContent {
+		result.FileID = i.fileIdentity(job.FilePath, result.ContentHash)
+		if prev == nil || prev.FileID != result.FileID {
+			i.supersede(prev, result.FileID)
+			prev = i.registration(result.FileID, job.FilePath)
+		}
+	}
+
+	// Slow path: mtime/size changed (touch, rsync) but content is identical.
+	// Record the new mtime so the fast path hits next time.
+	if prev != nil && !retry && prev.ContentHash == result.ContentHash {
+		if prev.FilePath != job.FilePath {
+			return i.skipRegistered(prev, job, result)
+		}
+		if !metadataUnchanged(prev, job) {
+			refreshed := *prev
+			refreshed.LastModified = job.LastModified
+			refreshed.FileSize = uint64(job.FileSize)
+			if err := i.ch.UpsertFileMetadata(i.ctx, &refreshed); err != nil {
+				log.Warn().Err(err).Str("file", job.FilePath).Msg("Failed to refresh file registry")
+			}
+		}
+		return i.skipUnchanged(result)
+	}
+
+	atomic.AddInt64(&i.stats.BytesProcessed, int64(len(content)))
+	i.metrics.BytesProcessed.Add(float64(len(content)))
+
+	// Extract IOCs
+	ext, err := i.extractWithTimeout(p, job.FilePath, content)
+	if errors.Is(err, errFileTimeout) {
+		result.Duration = time.Since(startTime)
+		return i.stopFile(result, job, models.ScanStatusTimeout, err)
+	}
+	if err != nil {
+		return i.failFile(result, job, err)
+	}
+
+	result.FileType = ext.fileType
+	result.Language = ext.language
+	result.Signature = i.avScan(job.FilePath, content)
+	if result.Signature != "" {
+		addDetection(ext, result.ContentHash)
+	}
+	iocs, report := ext.iocs, ext.report
+
+	for iocType, n := range report.Oversized {
+		i.metrics.RecordIOCsDropped(string(iocType), "oversized", n)
+	}
+	for iocType, n := range report.Allowlisted {
+		i.metrics.RecordIOCsDropped(string(iocType), "allowlisted", n)
+	}
+	for iocType, n := range report.Dropped {
+		i.metrics.RecordIOCsDropped(string(iocType), "cap", n)
+		result.Dropped += n
+	}
+	if report.Truncated() {
+		log.Warn().
+			Str("file", job.FilePath).
+			Int("dropped", result.Dropped).
+			Msg("IOC cap exceeded, extraction truncated")
+	}
+
+	result.IOCs = iocs
+	result.IOCCount = extractor.CountIOCs(iocs)
+	result.Duration = time.Since(startTime)
+
+	// The copies kept in MinIO and the similarity index hold email
+	// addresses in their redacted form too
+	stored := i.redactor.Text(content)
+
+	if result.IOCCount > 0 {
+		result.Status = models.ScanStatusInfected
+		atomic.AddInt64(&i.stats.IOCsExtracted, int64(result.IOCCount))
+
+		// Record IOCs by type
+		for iocType, values := range iocs {
+			i.metrics.RecordIOCsExtracted(string(iocType), len(values))
+		}
+
+		// Batch insert IOCs to ClickHouse
+		iocList := extractor.FlattenIOCs(iocs, result.FileID)
+		now := time.Now()
+		for idx := range iocList {
+			iocList[idx].FirstSeen = now
+			iocList[idx].LastSeen = now
+			iocList[idx].Confidence = 50
+			iocList[idx].MalwareFamily = "Unknown"
+		}
+		applyFeedAttributes(iocList, ext.attrs)
+		i.extractor.TagPopularity(iocList)
+		extractor.TagHomographs(iocList)
+		if result.Signature != "" {
+			applyDetection(iocList, result.Signature, i.cfg.ClamAV.ConfidenceBoost)
+		}
+		applyDirectoryAttributes(iocList, dir)
+		i.holdForReview(iocList)
+		i.redactor.IOCs(iocList)
+
+		if err := i.ch.BatchInsertIOCs(i.ctx, iocList); err != nil {
+			log.Error().Err(err).Str("file", job.FilePath).Msg("Failed to insert IOCs")
+
+			// Recorded as failed so the next crawl tries again
+			result.Status = models.ScanStatusFailed
+			result.Error = fmt.Errorf("failed to insert IOCs: %w", err)
+			atomic.AddInt64(&i.stats.FilesFailed, 1)
+			i.metrics.FilesFailed.Inc()
+		} else {
+			i.metrics.RecordBatchInsert(len(iocList), time.Since(startTime).Seconds())
+			i.addToBloom(iocList)
+			i.recordVolume(i.ctx, cmp.Or(dir.Source, dir.Path), iocList)
+			i.sampleExtractions(iocList, stored)
+		}
+
+		if (ext.quarantine && i.cfg.Worker.QuarantineSamples) || i.cfg.Worker.QuarantineInfected {
+			result.MinIOKey = i.quarantine(job.FilePath, result.ContentHash, stored)
+		}
+
+	} else {
+		result.Status = models.ScanStatusMisc
+
+		// Upload to MinIO under a content-addressed key; identical files
+		// dropped in several directories are stored once
+		minioKey := db.ContentKey(result.ContentHash)
+		exists, err := i.minio.ObjectExists(i.ctx, minioKey)
+		if err != nil {
+			log.Debug().Err(err).Str("object", minioKey).Msg("Failed to check for existing object")
+		}
+
+		if exists {
+			log.Debug().Str("file", job.FilePath).Str("object", minioKey).Msg("Content already stored, reusing object")
+			result.MinIOKey = minioKey
+		} else {
+			contentType := db.GetContentType(job.FilePath)
+			if _, err := i.minio.UploadBytes(i.ctx, minioKey, stored, contentType); err != nil {
+				log.Warn().Err(err).Str("file", job.FilePath).Msg("Failed to upload to MinIO")
+			} else {
+				result.MinIOKey = minioKey
+			}
+		}
+	}
+
+	// Update file registry
+	meta := &models.FileMetadata{
+		FileID:       result.FileID,
+		FilePath:     job.FilePath,
+		FileSize:     uint64(job.FileSize),
+		ContentHash:  result.ContentHash,
+		Language:     result.Language,
+		FileType:     result.FileType,
+		LastModified: job.LastModified,
+		ScanStatus:   result.Status,
+		IOCCount:     uint32(result.IOCCount),
+		MinIOKey:     result.MinIOKey,
+		ProcessedAt:  time.Now(),
+	}
+
+	if result.Error != nil {
+		meta.ErrorMessage = result.Error.Error()
+	} else if result.Dropped > 0 {
+		meta.ErrorMessage = fmt.Sprintf("ioc cap exceeded: %d values dropped", result.Dropped)
+	}
+
+	if err := i.ch.UpsertFileMetadata(i.ctx, meta); err != nil {
+		log.Warn().Err(err).Str("file", job.FilePath).Msg("Failed to update file registry")
+	}
+
+	// Infected files are indexed too so clusters of similar files can be
+	// linked to the indicators extracted from them
+	if result.Status == models.ScanStatusInfected || result.MinIOKey != "" {
+		i.indexFile(meta, stored)
+	}
+
+	atomic.AddInt64(&i.stats.FilesProcessed, 1)
+	i.metrics.RecordFileProcessed(string(result.Status), result.Duration.Seconds())
+
+	return result
+}
+
+// metadataUnchanged reports whether a file's size and mtime match the registry.
+// ClickHouse stores mtime with second precision, so compare at that resolution.
+func metadataUnchanged(prev *models.FileMetadata, job models.FileJob) bool {
+	return prev.FileSize == uint64(job.FileSize) &&
+		prev.LastModified.Unix() == job.LastModified.Unix()
+}
+
+// stopFile records a file a per-file safeguard stopped: nothing is
+// extracted from it, and it is not retried until it changes
+func (i *Ingestor) stopFile(result models.ProcessResult, job models.FileJob, status models.ScanStatus, err error) models.ProcessResult {
+	result.Status = status
+	result.Error = err
+	log.Warn().Err(err).Str("file", job.FilePath).Str("status", string(status)).Msg("File stopped by safeguard")
+
+	meta := &models.FileMetadata{
+		FileID:       result.FileID,
+		FilePath:     job.FilePath,
+		FileSize:     uint64(job.FileSize),
+		ContentHash:  result.ContentHash,
+		LastModified: job.LastModified,
+		ScanStatus:   status,
+		ErrorMessage: err.Error(),
+		ProcessedAt:  time.Now(),
+	}
+	if err := i.ch.UpsertFileMetadata(i.ctx, meta); err != nil {
+		log.Warn().Err(err).Str("file", job.FilePath).Msg("Failed to update file registry")
+	}
+
+	atomic.AddInt64(&i.stats.FilesStopped, 1)
+	i.metrics.RecordFileProcessed(string(status), result.Duration.Seconds())
+	return result
+}
+
+// failFile records a file that could not be read or extracted. Failed
+// files are retried by every crawl until they succeed.
+func (i *Ingestor) failFile(result models.ProcessResult, job models.FileJob, err error) models.ProcessResult {
+	result.Status = models.ScanStatusFailed
+	result.Error = err
+	atomic.AddInt64(&i.stats.FilesFailed, 1)
+	i.metrics.FilesFailed.Inc()
+
+	meta := &models.FileMetadata{
+		FileID:       result.FileID,
+		FilePath:     job.FilePath,
+		FileSize:     uint64(job.FileSize),
+		ContentHash:  result.ContentHash,
+		LastModified: job.LastModified,
+		ScanStatus:   models.ScanStatusFailed,
+		ErrorMessage: err.Error(),
+		ProcessedAt:  time.Now(),
+	}
+	if err := i.ch.UpsertFileMetadata(i.ctx, meta); err != nil {
+		log.Warn().Err(err).Str("file", job.FilePath).Msg("Failed to update file registry")
+	}
+	return result
+}
+
+// skipUnchanged marks a result as skipped because its content has not changed
+func (i *Ingestor) skipUnchanged(result models.ProcessResult) models.ProcessResult {
+	result.Status = models.ScanStatusClean
+	result.Skipped = true
+	atomic.AddInt64(&i.stats.FilesSkipped, 1)
+	i.metrics.FilesSkipped.Inc()
+	return result
+}
+
+// resultCollector collects and logs results
+func (i *Ingestor) resultCollector(wg *sync.WaitGroup) {
+	defer wg.Done()
+
+	logTicker := time.NewTicker(10 * time.Second)
+	defer logTicker.Stop()
+
+	for {
+		select {
+		case result, ok := <-i.results:
+			if !ok {
+				return
+			}
+
+			if !result.Skipped {
+				i.publishEvent(result)
+			}
+
+			// Log significant results
+			if result.IOCCount > 0 {
+				log.Info().
+					Str("file", result.FilePath).
+					Str("status", string(result.Status)).
+					Int("ioc_count", result.IOCCount).
+					Dur("duration", result.Duration).
+					Msg("Processed file with IOCs")
+			}
+
+		case <-logTicker.C:
+			// Periodic status log
+			log.Info().
+				Int64("processed", atomic.LoadInt64(&i.stats.FilesProcessed)).
+				Int64("skipped", atomic.LoadInt64(&i.stats.FilesSkipped)).
+				Int64("failed", atomic.LoadInt64(&i.stats.FilesFailed)).
+				Int64("stopped", atomic.LoadInt64(&i.stats.FilesStopped)).
+				Int64("iocs", atomic.LoadInt64(&i.stats.IOCsExtracted)).
+				Int64("bytes", atomic.LoadInt64(&i.stats.BytesProcessed)).
+				Msg("Ingestion progress")
+		}
+	}
+}
+
+// publishEvent broadcasts a processed file to live-tail subscribers
+func (i *Ingestor) publishEvent(result models.ProcessResult) {
+	event := models.IngestionEvent{
+		FileID:     result.FileID,
+		FilePath:   result.FilePath,
+		Status:     result.Status,
+		IOCCount:   result.IOCCount,
+		Language:   result.Language,
+		FileType:   result.FileType,
+		Signature:  result.Signature,
+		DurationMs: result.Duration.Milliseconds(),
+		Timestamp:  time.Now().UTC(),
+	}
+
+	if len(result.IOCs) > 0 {
+		event.IOCsByType = make(map[models.IOCType]int, len(result.IOCs))
+		for iocType, values := range result.IOCs {
+			event.IOCsByType[iocType] = len(values)
+		}
+	}
+
+	if result.Error != nil {
+		event.Error = result.Error.Error()
+	}
+
+	if err := i.redis.PublishJSON(i.ctx, db.IngestionEventsChannel, event); err != nil {
+		log.Debug().Err(err).Msg("Failed to publish ingestion event")
+	}
+
+	msg := events.Message{Type: events.TypeFileProcessed, Key: event.FileID, Data: event}
+	if err := i.bus.Publish(i.ctx, msg); err != nil {
+		log.Warn().Err(err).Msg("Failed to publish ingestion event to event bus")
+	}
+}
+
+// addToBloom queues stored IOCs for the Bloom filter; values it had not
+// seen before are announced on the match stream once added
+func (i *Ingestor) addToBloom(iocList []models.IOC) {
+	values := make([]string, len(iocList))
+	for idx, ioc := range iocList {
+		values[idx] = ioc.Value
+	}
+
+	i.bloom.add(values, func(added []bool) {
+		newValues := make(map[string]bool)
+		for idx, isNew := range added {
+			if isNew {
+				newValues[values[idx]] = true
+			}
+		}
+		i.publishNewIOCs(iocList, newValues)
+	})
+}
+
+// recordVolume counts stored IOCs toward the ingestion volume of their feed,
+// or of their source when feed is "", for the volume check
+func (i *Ingestor) recordVolume(ctx context.Context, feed string, iocList []models.IOC) {
+	if i.cfg.Volume.Interval <= 0 {
+		return
+	}
+	counts := models.VolumeCounts(feed, iocList)
+	if err := i.redis.RecordIngestionVolume(ctx, time.Now(), counts, i.cfg.Volume.Retention()); err != nil {
+		log.Debug().Err(err).Msg("Failed to record ingestion volume")
+	}
+}
+
+// holdForReview marks IOCs below REVIEW_CONFIDENCE_THRESHOLD as pending
+// analyst review
+func (i *Ingestor) holdForReview(iocList []models.IOC) {
+	threshold := i.cfg.Worker.ReviewConfidence
+	for idx := range iocList {
+		if int(iocList[idx].Confidence) < threshold {
+			iocList[idx].Pending = true
+		}
+	}
+}
+
+// publishNewIOCs announces first-seen IOCs to match stream subscribers.
+// IOCs pending review are not announced.
+func (i *Ingestor) publishNewIOCs(iocList []models.IOC, newValues map[string]bool) {
+	if len(newValues) == 0 {
+		return
+	}
+
+	matches := make([]models.MatchEvent, 0, len(newValues))
+	for _, ioc := range iocList {
+		if newValues[ioc.Value] && !ioc.Pending {
+			matches = append(matches, models.NewMatchEvent(models.MatchKindIngested, ioc))
+		}
+	}
+
+	if err := i.redis.PublishMatchEvents(i.ctx, matches); err != nil {
+		log.Debug().Err(err).Msg("Failed to publish match events")
+	}
+	if err := i.bus.Publish(i.ctx, events.MatchMessages(matches)...); err != nil {
+		log.Warn().Err(err).Msg("Failed to publish match events to event bus")
+	}
+}
+
+// batchProcessor handles batch operations (currently unused, for future optimization)
+func (i *Ingestor) batchProcessor(batches <-chan []models.IOC, wg *sync.WaitGroup) {
+	defer wg.Done()
+
+	for batch := range batches {
+		if len(batch) == 0 {
+			continue
+		}
+
+		startTime := time.Now()
+		if err := i.ch.BatchInsertIOCs(i.ctx, batch); err != nil {
+			log.Error().Err(err).Int("count", len(batch)).Msg("Batch insert failed")
+		} else {
+			i.metrics.RecordBatchInsert(len(batch), time.Since(startTime).Seconds())
+		}
+	}
+}
+
+// PrintStats prints final ingestion statistics
+func (i *Ingestor) PrintStats() {
+	duration := time.Since(i.stats.StartTime)
+
+	log.Info().
+		Int64("files_processed", i.stats.FilesProcessed).
+		Int64("files_skipped", i.stats.FilesSkipped).
+		Int64("files_failed", i.stats.FilesFailed).
+		Int64("iocs_extracted", i.stats.IOCsExtracted).
+		Int64("bytes_processed", i.stats.BytesProcessed).
+		Dur("duration", duration).
+		Float64("files_per_sec", float64(i.stats.FilesProcessed)/duration.Seconds()).
+		Msg("Ingestion complete")
+}
@@ -0,0 +1,314 @@
+package ingestor
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"tip-server/internal/config"
+	"tip-server/internal/db"
+	"tip-server/internal/fixtures"
+	"tip-server/internal/memstore"
+	"tip-server/internal/models"
+)
+
+// newRunIngestor returns an ingestor over empty in-memory backends, with env
+// applied to the configuration
+func newRunIngestor(t *testing.T, env map[string]string) (*Ingestor, *db.Clients) {
+	t.Helper()
+	t.Setenv("CONFIG_FILE", filepath.Join(t.TempDir(), ".env"))
+	t.Setenv("API_KEY", "test-api-key")
+	for k, v := range env {
+		t.Setenv(k, v)
+	}
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clients := memstore.NewClients()
+	i, err := NewIngestor(config.NewReloader(cfg), clients)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(i.Close)
+	return i, clients
+}
+
+// newTestIngestor returns an ingestor like newRunIngestor, set up for
+// processFile the way a pass sets it up
+func newTestIngestor(t *testing.T, env map[string]string) (*Ingestor, *db.Clients) {
+	t.Helper()
+	i, clients := newRunIngestor(t, env)
+	i.directories = make(map[string]config.IngestPolicy)
+	i.bloom = newBloomWriter(i.ctx, i.redis, i.metrics, i.reloader.Current().Worker)
+	t.Cleanup(i.bloom.close)
+	return i, clients
+}
+
+// reportFile copies the fixture corpus into a temporary directory and
+// returns the path of its incident report
+func reportFile(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	if err := fixtures.WriteCorpus(dir); err != nil {
+		t.Fatal(err)
+	}
+	return filepath.Join(dir, "report.txt")
+}
+
+// crawlJob returns the job a crawl would queue for path
+func crawlJob(t *testing.T, path string) models.FileJob {
+	t.Helper()
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return models.FileJob{FilePath: path, FileSize: info.Size(), LastModified: info.ModTime(), Directory: filepath.Dir(path)}
+}
+
+// storedIOCs returns the IOCs stored from a file, by value
+func storedIOCs(t *testing.T, clients *db.Clients, fileID string) map[string]models.IOC {
+	t.Helper()
+	iocs, _, err := clients.ClickHouse.ListIOCsBySourceFile(context.Background(), fileID, 1000, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	byValue := make(map[string]models.IOC, len(iocs))
+	for _, ioc := range iocs {
+		byValue[ioc.Value] = ioc
+	}
+	return byValue
+}
+
+// rewrite replaces old with new (of the same length) in path, keeping its
+// size and mtime
+func rewrite(t *testing.T, path, old, new string) {
+	t.Helper()
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, bytes.ReplaceAll(content, []byte(old), []byte(new)), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, info.ModTime(), info.ModTime()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestProcessFileChangeDetection(t *testing.T) {
+	touched := time.Now().Add(-time.Hour).Truncate(time.Second)
+
+	tests := []struct {
+		name      string
+		detection string
+		change    func(t *testing.T, path string)
+		skipped   bool
+		value     string // Stored once the file was processed again
+	}{
+		{
+			name:      "unchanged",
+			detection: "mtime",
+			change:    func(*testing.T, string) {},
+			skipped:   true,
+		},
+		{
+			// Slow path: the content hash matches and only the mtime is refreshed
+			name:      "touched",
+			detection: "mtime",
+			change: func(t *testing.T, path string) {
+				if err := os.Chtimes(path, touched, touched); err != nil {
+					t.Fatal(err)
+				}
+			},
+			skipped: true,
+		},
+		{
+			// Fast path: same size and mtime are trusted
+			name:      "rewritten in place",
+			detection: "mtime",
+			change:    func(t *testing.T, path string) { rewrite(t, path, "203.0.113.77", "203.0.113.78") },
+			skipped:   true,
+		},
+		{
+			name:      "rewritten in place, hashed",
+			detection: "hash",
+			change:    func(t *testing.T, path string) { rewrite(t, path, "203.0.113.77", "203.0.113.78") },
+			value:     "203.0.113.78",
+		},
+		{
+			name:      "unchanged, hashed",
+			detection: "hash",
+			change:    func(*testing.T, string) {},
+			skipped:   true,
+		},
+		{
+			name:      "edited",
+			detection: "mtime",
+			change: func(t *testing.T, path string) {
+				f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer f.Close()
+				io.WriteString(f, "Second stage: 198.51.100.23\n")
+			},
+			value: "198.51.100.23",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			i, clients := newTestIngestor(t, map[string]string{"CHANGE_DETECTION": tt.detection})
+			path := reportFile(t)
+
+			first := i.processFile(crawlJob(t, path))
+			if first.Status != models.ScanStatusInfected || first.IOCCount == 0 {
+				t.Fatalf("first pass = %s (%v), want infected", first.Status, first.Error)
+			}
+
+			tt.change(t, path)
+			job := crawlJob(t, path)
+			second := i.processFile(job)
+			if second.Skipped != tt.skipped {
+				t.Fatalf("second pass skipped = %v (%s), want %v", second.Skipped, second.Status, tt.skipped)
+			}
+			if tt.value != "" {
+				if _, ok := storedIOCs(t, clients, second.FileID)[tt.value]; !ok {
+					t.Errorf("%s was not stored from the changed file", tt.value)
+				}
+			}
+
+			// Skipped files keep a registration matching the file on disk,
+			// so the fast path hits on the next pass
+			if tt.skipped {
+				meta, err := clients.ClickHouse.GetFileMetadata(context.Background(), first.FileID)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !metadataUnchanged(meta, job) {
+					t.Errorf("registered mtime %v, want %v", meta.LastModified, job.LastModified)
+				}
+			}
+		})
+	}
+}
+
+func TestProcessFileStorage(t *testing.T) {
+	const (
+		email    = "invoices@payments-portal-secure.com"
+		redactor = "0123456789abcdef0123456789abcdef"
+	)
+
+	tests := []struct {
+		name        string
+		env         map[string]string
+		pending     bool // Held for review
+		quarantined bool
+		redacted    bool
+	}{
+		{name: "stored"},
+		{name: "quarantine", env: map[string]string{"QUARANTINE_INFECTED": "true"}, quarantined: true},
+		{name: "review hold", env: map[string]string{"REVIEW_CONFIDENCE_THRESHOLD": "60"}, pending: true},
+		{name: "review threshold met", env: map[string]string{"REVIEW_CONFIDENCE_THRESHOLD": "1"}},
+		{
+			name:        "redaction",
+			env:         map[string]string{"EMAIL_REDACTION": "hash", "EMAIL_REDACTION_KEY": redactor, "QUARANTINE_INFECTED": "true"},
+			quarantined: true,
+			redacted:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			i, clients := newTestIngestor(t, tt.env)
+			ctx := context.Background()
+
+			result := i.processFile(crawlJob(t, reportFile(t)))
+			if result.Status != models.ScanStatusInfected || result.Error != nil {
+				t.Fatalf("status = %s (%v), want infected", result.Status, result.Error)
+			}
+
+			stored := storedIOCs(t, clients, result.FileID)
+			for _, want := range fixtures.IOCs(time.Now()) {
+				if want.Type == models.IOCTypeEmail {
+					continue
+				}
+				ioc, ok := stored[want.Value]
+				if !ok {
+					t.Errorf("%s was not stored", want.Value)
+					continue
+				}
+				if ioc.Type != want.Type || ioc.Pending != tt.pending {
+					t.Errorf("%s stored as %s, pending %v; want %s, pending %v", want.Value, ioc.Type, ioc.Pending, want.Type, tt.pending)
+				}
+			}
+			if _, ok := stored[email]; ok == tt.redacted {
+				t.Errorf("address stored as is = %v, want %v", ok, !tt.redacted)
+			}
+
+			_, pending, err := clients.ClickHouse.ListPendingIOCs(ctx, "", 1000, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := 0
+			if tt.pending {
+				want = len(stored)
+			}
+			if pending != uint64(want) {
+				t.Errorf("%d of %d IOCs pending review, want %d", pending, len(stored), want)
+			}
+
+			// Infected files are kept only in quarantine, with addresses in
+			// their stored form
+			if !tt.quarantined {
+				if result.MinIOKey != "" {
+					t.Errorf("minio key = %q, want none", result.MinIOKey)
+				}
+				return
+			}
+			if result.MinIOKey != db.QuarantineKey(result.ContentHash) {
+				t.Fatalf("minio key = %q, want the quarantine key", result.MinIOKey)
+			}
+			obj, _, _, err := clients.MinIO.OpenObject(ctx, result.MinIOKey)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer obj.Close()
+			content, _ := io.ReadAll(obj)
+			if holds := strings.Contains(string(content), email); holds == tt.redacted {
+				t.Errorf("quarantined copy holds the address = %v, want %v", holds, !tt.redacted)
+			}
+			if !strings.Contains(string(content), "203.0.113.77") {
+				t.Error("quarantined copy lacks the report text")
+			}
+		})
+	}
+}
+
+func TestProcessFileMisc(t *testing.T) {
+	i, clients := newTestIngestor(t, nil)
+	path := filepath.Join(t.TempDir(), "notes.txt")
+	if err := os.WriteFile(path, []byte("Nothing to report this week.\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	result := i.processFile(crawlJob(t, path))
+	if result.Status != models.ScanStatusMisc || result.IOCCount != 0 {
+		t.Fatalf("status = %s with %d IOCs, want misc", result.Status, result.IOCCount)
+	}
+	if result.MinIOKey != db.ContentKey(result.ContentHash) {
+		t.Errorf("minio key = %q, want the content-addressed key", result.MinIOKey)
+	}
+	if exists, _ := clients.MinIO.ObjectExists(context.Background(), result.MinIOKey); !exists {
+		t.Errorf("%s was not uploaded", result.MinIOKey)
+	}
+}
@@ -0,0 +1,421 @@
+package ingestor
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"tip-server/internal/events"
+	"tip-server/internal/extractor"
+	"tip-server/internal/logparse"
+	"tip-server/internal/models"
+)
+
+// syslogSourcePrefix marks IOCs stored from streamed logs rather than files
+const syslogSourcePrefix = "syslog:"
+
+// logLine is a single message received by the syslog listener
+type logLine struct {
+	transport string // tcp or udp
+	remote    string // Sender IP
+	text      string
+}
+
+// Listen runs the TCP/UDP syslog listeners until ctx is cancelled. Messages
+// are parsed (syslog, CEF, LEEF), scanned for indicators, and matched against
+// the IOC store in batches; hits are published as log_hit match events.
+func (i *Ingestor) Listen(ctx context.Context) error {
+	cfg := i.cfg.Syslog
+	if cfg.TCPAddr == "" && cfg.UDPAddr == "" {
+		return errors.New("listener mode requires SYSLOG_TCP_ADDR or SYSLOG_UDP_ADDR")
+	}
+
+	lines := make(chan logLine, cfg.BatchSize*4)
+	var servers sync.WaitGroup
+
+	if cfg.UDPAddr != "" {
+		pc, err := net.ListenPacket("udp", cfg.UDPAddr)
+		if err != nil {
+			return fmt.Errorf("failed to listen on udp %s: %w", cfg.UDPAddr, err)
+		}
+		go func() { <-ctx.Done(); pc.Close() }()
+
+		servers.Add(1)
+		go i.serveUDP(pc, lines, &servers)
+		log.Info().Str("addr", cfg.UDPAddr).Msg("Listening for syslog over UDP")
+	}
+
+	if cfg.TCPAddr != "" {
+		ln, err := net.Listen("tcp", cfg.TCPAddr)
+		if err != nil {
+			return fmt.Errorf("failed to listen on tcp %s: %w", cfg.TCPAddr, err)
+		}
+		go func() { <-ctx.Done(); ln.Close() }()
+
+		servers.Add(1)
+		go i.serveTCP(ctx, ln, lines, &servers)
+		log.Info().Str("addr", cfg.TCPAddr).Msg("Listening for syslog over TCP")
+	}
+
+	processed := make(chan struct{})
+	go func() {
+		defer close(processed)
+		i.processLogLines(lines)
+	}()
+
+	if interval := i.cfg.Extractor.ListRefreshInterval; interval > 0 {
+		go i.refreshLists(ctx, interval)
+	}
+
+	servers.Wait()
+	close(lines)
+	<-processed
+
+	log.Info().Msg("Syslog listener stopped")
+	return nil
+}
+
+// serveUDP reads one message per datagram
+func (i *Ingestor) serveUDP(pc net.PacketConn, lines chan<- logLine, wg *sync.WaitGroup) {
+	defer wg.Done()
+
+	buf := make([]byte, i.cfg.Syslog.MaxMessageSize)
+	for {
+		n, addr, err := pc.ReadFrom(buf)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			log.Warn().Err(err).Msg("UDP syslog read failed")
+			continue
+		}
+
+		remote := addr.String()
+		if host, _, err := net.SplitHostPort(remote); err == nil {
+			remote = host
+		}
+
+		lines <- logLine{transport: "udp", remote: remote, text: string(bytes.TrimRight(buf[:n], "\r\n\x00"))}
+	}
+}
+
+// serveTCP accepts connections until the listener is closed
+func (i *Ingestor) serveTCP(ctx context.Context, ln net.Listener, lines chan<- logLine, wg *sync.WaitGroup) {
+	defer wg.Done()
+
+	var conns sync.WaitGroup
+	defer conns.Wait()
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			log.Warn().Err(err).Msg("TCP syslog accept failed")
+			continue
+		}
+
+		conns.Add(1)
+		go func() {
+			defer conns.Done()
+			i.serveTCPConn(ctx, conn, lines)
+		}()
+	}
+}
+
+// serveTCPConn reads framed messages from one connection
+func (i *Ingestor) serveTCPConn(ctx context.Context, conn net.Conn, lines chan<- logLine) {
+	defer conn.Close()
+
+	// Unblock the scanner on shutdown
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-done:
+		}
+	}()
+
+	remote, _, err := net.SplitHostPort(conn.RemoteAddr().String())
+	if err != nil {
+		remote = conn.RemoteAddr().String()
+	}
+
+	scanner := bufio.NewScanner(conn)
+	scanner.Buffer(make([]byte, 0, 4096), i.cfg.Syslog.MaxMessageSize)
+	scanner.Split(splitSyslogFrames)
+
+	for scanner.Scan() {
+		if text := scanner.Text(); text != "" {
+			lines <- logLine{transport: "tcp", remote: remote, text: text}
+		}
+	}
+
+	if err := scanner.Err(); err != nil && ctx.Err() == nil && !errors.Is(err, net.ErrClosed) {
+		log.Warn().Err(err).Str("remote", remote).Msg("Closing syslog connection")
+	}
+}
+
+// splitSyslogFrames splits a TCP syslog stream using octet-counting framing
+// ("<len> <msg>", RFC 6587) when present and newline framing otherwise
+func splitSyslogFrames(data []byte, atEOF bool) (int, []byte, error) {
+	if atEOF && len(data) == 0 {
+		return 0, nil, nil
+	}
+
+	if n, start := octetCount(data); start > 0 {
+		if len(data) >= start+n {
+			return start + n, bytes.TrimRight(data[start:start+n], "\r\n"), nil
+		}
+		if atEOF {
+			return len(data), data[start:], nil
+		}
+		return 0, nil, nil
+	}
+
+	if idx := bytes.IndexByte(data, '\n'); idx >= 0 {
+		return idx + 1, bytes.TrimRight(data[:idx], "\r"), nil
+	}
+	if atEOF {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
+
+// octetCount parses an RFC 6587 length prefix. It returns the message length
+// and the offset of the message, or start 0 when data is not octet-counted.
+func octetCount(data []byte) (int, int) {
+	sp := bytes.IndexByte(data, ' ')
+	if sp < 1 || sp > 7 || len(data) < sp+2 || data[sp+1] != '<' {
+		return 0, 0
+	}
+	n, err := strconv.Atoi(string(data[:sp]))
+	if err != nil || n <= 0 {
+		return 0, 0
+	}
+	return n, sp + 1
+}
+
+// processLogLines batches received messages and matches them until lines is closed
+func (i *Ingestor) processLogLines(lines <-chan logLine) {
+	cfg := i.cfg.Syslog
+	batch := make([]logLine, 0, cfg.BatchSize)
+
+	ticker := time.NewTicker(cfg.FlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case line, ok := <-lines:
+			if !ok {
+				i.matchLogBatch(batch)
+				return
+			}
+			batch = append(batch, line)
+			if len(batch) >= cfg.BatchSize {
+				i.matchLogBatch(batch)
+				batch = batch[:0]
+			}
+
+		case <-ticker.C:
+			if len(batch) > 0 {
+				i.matchLogBatch(batch)
+				batch = batch[:0]
+			}
+		}
+	}
+}
+
+// matchLogBatch extracts indicators from a batch of messages, looks them up,
+// and publishes hits. Uses its own context so the final batch is still
+// matched during shutdown.
+func (i *Ingestor) matchLogBatch(batch []logLine) {
+	if len(batch) == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	// value -> reporting host; per-host IOC sets for optional storage
+	observedBy := make(map[string]string)
+	byHost := make(map[string]map[models.IOCType][]string)
+
+	for _, line := range batch {
+		rec := logparse.Parse(line.text)
+		i.metrics.LogMessages.WithLabelValues(line.transport, rec.Format).Inc()
+
+		var iocs map[models.IOCType][]string
+		var err error
+		if rec.Indicators != nil {
+			iocs, _ = i.extractor.ScanFields(rec.Indicators)
+		} else {
+			iocs, _, err = i.extractor.ScanWithReport([]byte(rec.Text()))
+		}
+		if err != nil || extractor.CountIOCs(iocs) == 0 {
+			continue
+		}
+
+		host := rec.Hostname
+		if host == "" {
+			host = line.remote
+		}
+		if byHost[host] == nil {
+			byHost[host] = make(map[models.IOCType][]string)
+		}
+
+		for iocType, values := range iocs {
+			for _, v := range values {
+				if _, seen := observedBy[v]; !seen {
+					observedBy[v] = host
+					byHost[host][iocType] = append(byHost[host][iocType], v)
+				}
+			}
+		}
+	}
+
+	if len(observedBy) == 0 {
+		return
+	}
+
+	// Addresses are matched in their stored, redacted form
+	values := make([]string, 0, len(observedBy))
+	hosts := make(map[string]string, len(observedBy))
+	for v, host := range observedBy {
+		v = i.redactor.Value(v)
+		values = append(values, v)
+		hosts[v] = host
+	}
+
+	// Match before storing, otherwise every stored value would match itself
+	i.publishLogHits(ctx, values, hosts)
+
+	if i.cfg.Syslog.StoreIOCs {
+		for host, iocs := range byHost {
+			i.storeLogIOCs(ctx, host, iocs)
+		}
+	}
+}
+
+// publishLogHits looks values up (Bloom filter, then ClickHouse) and
+// publishes a log_hit event for every known IOC
+func (i *Ingestor) publishLogHits(ctx context.Context, values []string, observedBy map[string]string) {
+	exists, err := i.redis.BFMExists(ctx, values)
+	if err != nil {
+		log.Warn().Err(err).Msg("Bloom filter check failed, querying ClickHouse directly")
+		exists = make([]bool, len(values))
+		for idx := range exists {
+			exists[idx] = true
+		}
+	}
+
+	var candidates []string
+	for idx, v := range values {
+		if exists[idx] {
+			candidates = append(candidates, v)
+		}
+	}
+	if len(candidates) == 0 {
+		return
+	}
+
+	found, err := i.ch.QueryIOCs(ctx, candidates)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to match log indicators")
+		return
+	}
+
+	seen := make(map[string]bool, len(found))
+	matches := make([]models.MatchEvent, 0, len(found))
+	for _, ioc := range found {
+		if seen[ioc.Value] {
+			continue
+		}
+		seen[ioc.Value] = true
+
+		event := models.NewMatchEvent(models.MatchKindLogHit, ioc)
+		event.ObservedBy = observedBy[ioc.Value]
+		matches = append(matches, event)
+		i.metrics.LogMatches.WithLabelValues(string(ioc.Type)).Inc()
+
+		log.Info().
+			Str("ioc", ioc.Value).
+			Str("type", string(ioc.Type)).
+			Str("observed_by", event.ObservedBy).
+			Msg("Known IOC seen in log stream")
+	}
+
+	if err := i.redis.PublishMatchEvents(ctx, matches); err != nil {
+		log.Debug().Err(err).Msg("Failed to publish match events")
+	}
+	if err := i.bus.Publish(ctx, events.MatchMessages(matches)...); err != nil {
+		log.Warn().Err(err).Msg("Failed to publish match events to event bus")
+	}
+}
+
+// storeLogIOCs stores indicators seen in a host's logs and announces new ones
+func (i *Ingestor) storeLogIOCs(ctx context.Context, host string, iocs map[models.IOCType][]string) {
+	iocList := extractor.FlattenIOCs(iocs, syslogSourcePrefix+host)
+	now := time.Now()
+	for idx := range iocList {
+		iocList[idx].FirstSeen = now
+		iocList[idx].LastSeen = now
+		iocList[idx].Confidence = 50
+		iocList[idx].MalwareFamily = "Unknown"
+	}
+	i.extractor.TagPopularity(iocList)
+	extractor.TagHomographs(iocList)
+	i.holdForReview(iocList)
+	i.redactor.IOCs(iocList)
+
+	values := make([]string, len(iocList))
+	for idx, ioc := range iocList {
+		values[idx] = ioc.Value
+	}
+	newValues := make(map[string]bool)
+	added, err := i.redis.BFMAddNew(ctx, values)
+	if err != nil {
+		log.Warn().Err(err).Msg("Failed to add IOCs to Bloom filter")
+	}
+	for idx, isNew := range added {
+		if isNew {
+			newValues[values[idx]] = true
+		}
+	}
+
+	if err := i.ch.BatchInsertIOCs(ctx, iocList); err != nil {
+		log.Error().Err(err).Str("host", host).Msg("Failed to insert log IOCs")
+		return
+	}
+
+	i.publishNewIOCs(iocList, newValues)
+}
+
+// refreshLists re-reads the extraction allow/deny and popularity lists
+// every interval, as the listener runs indefinitely rather than in passes
+func (i *Ingestor) refreshLists(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := i.extractor.ReloadLists(); err != nil {
+				log.Warn().Err(err).Msg("Failed to reload extraction lists, keeping previous ones")
+			}
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package ingestor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/rs/zerolog/log"
+)
+
+// StartMetricsServer serves Prometheus metrics and a liveness probe.
+// The returned server is shut down by the caller on exit.
+func (i *Ingestor) StartMetricsServer() *http.Server {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	mux.HandleFunc("/healthz", i.healthzHandler)
+
+	srv := &http.Server{
+		Addr:              fmt.Sprintf(":%d", i.cfg.Metrics.IngestorPort),
+		Handler:           mux,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+
+	go func() {
+		log.Info().Str("addr", srv.Addr).Msg("Starting metrics server")
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Error().Err(err).Msg("Metrics server failed")
+		}
+	}()
+
+	return srv
+}
+
+// StopMetricsServer gracefully stops the metrics server
+func StopMetricsServer(srv *http.Server) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := srv.Shutdown(ctx); err != nil {
+		log.Warn().Err(err).Msg("Error stopping metrics server")
+	}
+}
+
+// healthzHandler reports liveness and current run progress
+func (i *Ingestor) healthzHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":          "healthy",
+		"timestamp":       time.Now().UTC().Format(time.RFC3339),
+		"uptime":          time.Since(i.stats.StartTime).String(),
+		"files_processed": atomic.LoadInt64(&i.stats.FilesProcessed),
+		"files_skipped":   atomic.LoadInt64(&i.stats.FilesSkipped),
+		"files_failed":    atomic.LoadInt64(&i.stats.FilesFailed),
+		"iocs_extracted":  atomic.LoadInt64(&i.stats.IOCsExtracted),
+	})
+}
@@ -0,0 +1,238 @@
+package ingestor
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"tip-server/internal/binfile"
+	"tip-server/internal/config"
+	"tip-server/internal/extractor"
+	"tip-server/internal/feeds"
+	"tip-server/internal/htmldoc"
+	"tip-server/internal/language"
+	"tip-server/internal/logparse"
+	"tip-server/internal/mailparse"
+	"tip-server/internal/models"
+	"tip-server/internal/pcap"
+	"tip-server/internal/pdfdoc"
+)
+
+// extraction is what a handler produces for one file
+type extraction struct {
+	iocs   map[models.IOCType][]string
+	report extractor.ScanReport
+
+	// attrs carries per-indicator confidence, family, tags and first-seen
+	// time, keyed by lowercased value
+	attrs map[string]feeds.Indicator
+
+	fileType   string // Recorded in the file registry; "" for plain text
+	language   string // Detected from extracted text; "" to detect from raw content
+	quarantine bool   // Store the file as a malware sample
+}
+
+// handler extracts IOCs from one kind of file. detect recognises the file
+// by extension, sniffed content type or magic bytes and returns its format,
+// or "" to pass it to the next handler.
+type handler struct {
+	name    string
+	textual bool // Language is detected on the raw content
+	detect  func(path string, content []byte) string
+	extract func(i *Ingestor, p *profile, path, format string, content []byte) (*extraction, error)
+}
+
+// pipeline lists the extraction handlers in the order they are tried.
+// Content-sniffed formats come first so that, for example, a STIX bundle
+// saved as .txt is still parsed as a feed; text accepts anything left.
+var pipeline = []handler{
+	{name: config.HandlerBinary, detect: detectBinary, extract: (*Ingestor).scanExecutable},
+	{name: config.HandlerFeeds, textual: true, detect: detectFeed, extract: (*Ingestor).scanFeed},
+	{name: config.HandlerEmail, textual: true, detect: mailparse.Detect, extract: (*Ingestor).scanEmail},
+	{name: config.HandlerPCAP, detect: detectCapture, extract: (*Ingestor).scanCapture},
+	{name: config.HandlerLogs, textual: true, detect: detectLog, extract: (*Ingestor).scanLog},
+	{name: config.HandlerPDF, detect: detectPDF, extract: (*Ingestor).scanPDF},
+	{name: config.HandlerHTML, detect: detectHTML, extract: (*Ingestor).scanHTML},
+	{name: config.HandlerText, textual: true, detect: detectText, extract: (*Ingestor).scanText},
+}
+
+// extract runs the first handler enabled in the file's profile that
+// recognises the file. With every matching handler disabled the file yields
+// no IOCs.
+func (i *Ingestor) extract(p *profile, path string, content []byte) (*extraction, error) {
+	for _, h := range pipeline {
+		if !p.worker.HandlerEnabled(h.name) {
+			continue
+		}
+		format := h.detect(path, content)
+		if format == "" {
+			continue
+		}
+
+		i.metrics.FilesByHandler.WithLabelValues(h.name, format).Inc()
+		ext, err := h.extract(i, p, path, format, content)
+		if err != nil {
+			return nil, err
+		}
+		if h.textual && ext.language == "" {
+			ext.language = i.detectLanguage(content)
+		}
+		return ext, nil
+	}
+	return &extraction{}, nil
+}
+
+// errFileTimeout reports an extraction abandoned after FILE_TIMEOUT
+var errFileTimeout = errors.New("extraction exceeded FILE_TIMEOUT")
+
+// extractWithTimeout runs extract, giving up after the profile's
+// FILE_TIMEOUT. Handlers cannot be interrupted, so an abandoned extraction
+// runs on in the background and its result is discarded while the worker
+// moves on to the next file.
+func (i *Ingestor) extractWithTimeout(p *profile, path string, content []byte) (*extraction, error) {
+	if p.worker.FileTimeout <= 0 {
+		return i.extract(p, path, content)
+	}
+
+	type outcome struct {
+		ext *extraction
+		err error
+	}
+	done := make(chan outcome, 1)
+	go func() {
+		ext, err := i.extract(p, path, content)
+		done <- outcome{ext, err}
+	}()
+
+	timer := time.NewTimer(p.worker.FileTimeout)
+	defer timer.Stop()
+	select {
+	case o := <-done:
+		return o.ext, o.err
+	case <-timer.C:
+		i.metrics.OrphanExtracts.Inc()
+		go func() {
+			<-done
+			i.metrics.OrphanExtracts.Dec()
+		}()
+		return nil, fmt.Errorf("%w (%s)", errFileTimeout, p.worker.FileTimeout)
+	}
+}
+
+// detectLanguage returns the language of text, if detection is enabled
+func (i *Ingestor) detectLanguage(text []byte) string {
+	if !i.cfg.Worker.DetectLanguage {
+		return ""
+	}
+	return language.Detect(text)
+}
+
+// normalize prepares text for regex extraction, if enabled
+func (i *Ingestor) normalize(text []byte) []byte {
+	if !i.cfg.Worker.NormalizeText {
+		return text
+	}
+	return language.Normalize(text)
+}
+
+func detectBinary(_ string, content []byte) string {
+	return binfile.Detect(content)
+}
+
+func detectFeed(_ string, content []byte) string {
+	return feeds.Detect(content)
+}
+
+func detectCapture(_ string, content []byte) string {
+	return pcap.Detect(content)
+}
+
+func detectLog(_ string, content []byte) string {
+	return logparse.DetectJSONLog(content)
+}
+
+func detectPDF(path string, content []byte) string {
+	if pdfdoc.Detect(path, content) {
+		return config.HandlerPDF
+	}
+	return ""
+}
+
+func detectHTML(path string, content []byte) string {
+	if htmldoc.Detect(path, content) {
+		return config.HandlerHTML
+	}
+	return ""
+}
+
+func detectText(string, []byte) string {
+	return config.HandlerText
+}
+
+// scanFeed reads OpenIOC and STIX documents indicator by indicator,
+// keeping the source's attributes
+func (i *Ingestor) scanFeed(p *profile, path, _ string, content []byte) (*extraction, error) {
+	indicators, format, err := feeds.Parse(content)
+	if err != nil {
+		return nil, err
+	}
+	log.Debug().Str("file", path).Str("format", format).Int("indicators", len(indicators)).Msg("Parsing threat intel document")
+
+	fields := make(map[models.IOCType][]string)
+	attrs := make(map[string]feeds.Indicator, len(indicators))
+	for _, ind := range indicators {
+		// Feeds such as SSLBL tag certificate fingerprints rather than typing them
+		t := models.CertificateHashType(ind.Type, ind.Tags)
+		fields[t] = append(fields[t], ind.Value)
+		attrs[strings.ToLower(ind.Value)] = ind
+	}
+	iocs, report := p.extractor.ScanFields(fields)
+	return &extraction{iocs: iocs, report: report, attrs: attrs, fileType: format}, nil
+}
+
+// scanLog reads Suricata EVE / Zeek JSON logs from their typed fields
+func (i *Ingestor) scanLog(p *profile, path, _ string, content []byte) (*extraction, error) {
+	fields, format, ok := logparse.ExtractJSONLog(content)
+	if !ok {
+		// Detected from the first record but no record parsed; scan as text
+		return i.scanText(p, path, config.HandlerText, content)
+	}
+	log.Debug().Str("file", path).Str("format", format).Msg("Extracting structured sensor log")
+	iocs, report := p.extractor.ScanFields(fields)
+	return &extraction{iocs: iocs, report: report, fileType: format}, nil
+}
+
+// scanPDF scans the text and link targets of a PDF
+func (i *Ingestor) scanPDF(p *profile, _, format string, content []byte) (*extraction, error) {
+	doc := pdfdoc.Parse(content)
+	return i.scanDocument(p, format, []byte(doc.Text), doc.Content())
+}
+
+// scanHTML scans the visible text, links and script sources of a page
+// rather than its markup
+func (i *Ingestor) scanHTML(p *profile, _, format string, content []byte) (*extraction, error) {
+	doc := htmldoc.Parse(content)
+	return i.scanDocument(p, format, []byte(doc.Text), doc.Content())
+}
+
+// scanDocument regex-scans content extracted from a document format,
+// detecting the language on its text alone
+func (i *Ingestor) scanDocument(p *profile, format string, text, content []byte) (*extraction, error) {
+	iocs, report, err := p.extractor.ScanWithReport(i.normalize(content))
+	if err != nil {
+		return nil, err
+	}
+	return &extraction{iocs: iocs, report: report, fileType: format, language: i.detectLanguage(text)}, nil
+}
+
+// scanText regex-scans the whole file
+func (i *Ingestor) scanText(p *profile, _, _ string, content []byte) (*extraction, error) {
+	iocs, report, err := p.extractor.ScanWithReport(i.normalize(content))
+	if err != nil {
+		return nil, err
+	}
+	return &extraction{iocs: iocs, report: report}, nil
+}
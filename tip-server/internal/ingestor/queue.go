@@ -0,0 +1,142 @@
+package ingestor
+
+import (
+	"container/heap"
+	"context"
+	"errors"
+	"sync"
+
+	"tip-server/internal/models"
+)
+
+// jobQueue is a bounded priority queue of file jobs. Workers take the job
+// with the highest priority first and, among equals, the one the queue order
+// prefers. Crawls block while the queue is full; jobs with a priority above
+// the crawl's are always accepted, so submissions never wait behind a
+// backfill.
+type jobQueue struct {
+	mu       sync.Mutex
+	cond     *sync.Cond
+	items    jobHeap
+	capacity int
+	seq      uint64
+	closed   bool
+}
+
+// queuedJob is a job and the order it was pushed in
+type queuedJob struct {
+	job models.FileJob
+	seq uint64
+}
+
+// jobHeap orders queued jobs by priority, then by the configured order
+type jobHeap struct {
+	jobs []queuedJob
+	less func(a, b models.FileJob) bool
+}
+
+func (h jobHeap) Len() int { return len(h.jobs) }
+
+func (h jobHeap) Less(a, b int) bool {
+	x, y := h.jobs[a], h.jobs[b]
+	switch {
+	case x.job.Priority != y.job.Priority:
+		return x.job.Priority > y.job.Priority
+	case h.less != nil && h.less(x.job, y.job):
+		return true
+	case h.less != nil && h.less(y.job, x.job):
+		return false
+	}
+	return x.seq < y.seq
+}
+
+func (h jobHeap) Swap(a, b int) { h.jobs[a], h.jobs[b] = h.jobs[b], h.jobs[a] }
+
+func (h *jobHeap) Push(x any) { h.jobs = append(h.jobs, x.(queuedJob)) }
+
+func (h *jobHeap) Pop() any {
+	last := h.jobs[len(h.jobs)-1]
+	h.jobs = h.jobs[:len(h.jobs)-1]
+	return last
+}
+
+// queueOrders are the QUEUE_ORDER tie-breaks between jobs of equal priority;
+// "fifo" keeps crawl order
+var queueOrders = map[string]func(a, b models.FileJob) bool{
+	"size":   func(a, b models.FileJob) bool { return a.FileSize < b.FileSize },
+	"recent": func(a, b models.FileJob) bool { return a.LastModified.After(b.LastModified) },
+	"fifo":   nil,
+}
+
+// errQueueClosed reports a job pushed after its pass stopped taking jobs
+var errQueueClosed = errors.New("job queue closed")
+
+// newJobQueue creates a queue holding up to capacity crawled jobs
+func newJobQueue(capacity int, order string) *jobQueue {
+	q := &jobQueue{
+		items:    jobHeap{less: queueOrders[order]},
+		capacity: capacity,
+	}
+	q.cond = sync.NewCond(&q.mu)
+	return q
+}
+
+// Push adds a job, waiting while the queue is full unless the job outranks
+// crawled files. It fails when ctx is cancelled or the queue is closed.
+func (q *jobQueue) Push(ctx context.Context, job models.FileJob) error {
+	stop := context.AfterFunc(ctx, func() {
+		q.mu.Lock()
+		q.cond.Broadcast()
+		q.mu.Unlock()
+	})
+	defer stop()
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for !q.closed && ctx.Err() == nil && job.Priority <= models.JobPriorityCrawl && q.items.Len() >= q.capacity {
+		q.cond.Wait()
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if q.closed {
+		return errQueueClosed
+	}
+
+	q.seq++
+	heap.Push(&q.items, queuedJob{job: job, seq: q.seq})
+	q.cond.Broadcast()
+	return nil
+}
+
+// Pop takes the most urgent job, waiting for one. It reports false once the
+// queue is closed and empty.
+func (q *jobQueue) Pop() (models.FileJob, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for q.items.Len() == 0 && !q.closed {
+		q.cond.Wait()
+	}
+	if q.items.Len() == 0 {
+		return models.FileJob{}, false
+	}
+
+	item := heap.Pop(&q.items).(queuedJob)
+	q.cond.Broadcast()
+	return item.job, true
+}
+
+// Close stops the queue accepting jobs; those queued are still handed out
+func (q *jobQueue) Close() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.closed = true
+	q.cond.Broadcast()
+}
+
+// Len returns the number of queued jobs
+func (q *jobQueue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.items.Len()
+}
@@ -0,0 +1,83 @@
+package ingestor
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"tip-server/internal/models"
+)
+
+// reconcileDeletions tombstones registry entries under root whose source
+// files no longer exist, optionally deprecating the IOCs extracted from them
+func (i *Ingestor) reconcileDeletions(ctx context.Context, root string) error {
+	// An unmounted or missing data root would otherwise tombstone everything
+	if info, err := os.Stat(root); err != nil || !info.IsDir() {
+		log.Warn().Err(err).Str("path", root).Msg("Data path unavailable, skipping deletion reconciliation")
+		return nil
+	}
+
+	files, err := i.ch.ListActiveFiles(ctx, root)
+	if err != nil {
+		return err
+	}
+
+	var deleted []string
+	now := time.Now()
+
+	for fileID, filePath := range files {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		if _, err := os.Lstat(filePath); !errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+
+		meta, err := i.ch.GetFileMetadata(ctx, fileID)
+		if err != nil {
+			log.Warn().Err(err).Str("file", filePath).Msg("Failed to load registry entry for tombstone")
+			continue
+		}
+
+		meta.ScanStatus = models.ScanStatusDeleted
+		meta.DeletedAt = &now
+		if err := i.ch.UpsertFileMetadata(ctx, meta); err != nil {
+			log.Warn().Err(err).Str("file", filePath).Msg("Failed to tombstone registry entry")
+			continue
+		}
+
+		deleted = append(deleted, fileID)
+	}
+
+	var deprecated []string
+	if i.cfg.Worker.DeprecateDeletedIOCs && len(deleted) > 0 {
+		if deprecated, err = i.ch.DeprecateIOCsBySource(ctx, deleted); err != nil {
+			return err
+		}
+	}
+
+	// Deprecated values are dropped from the lookup cache, which would
+	// otherwise keep serving them, and left for the Bloom rebuild, which
+	// drops those with no active rows left
+	if len(deprecated) > 0 {
+		if err := i.redis.ScheduleBloomRemoval(ctx, deprecated...); err != nil {
+			log.Warn().Err(err).Msg("Failed to schedule Bloom filter maintenance")
+		}
+		if err := i.redis.InvalidateCachedIOCs(ctx, deprecated...); err != nil {
+			log.Warn().Err(err).Msg("Failed to invalidate lookup cache for deprecated IOCs")
+		}
+	}
+
+	log.Info().
+		Int("checked", len(files)).
+		Int("deleted", len(deleted)).
+		Int("iocs_deprecated", len(deprecated)).
+		Msg("Deletion reconciliation complete")
+
+	return nil
+}
@@ -0,0 +1,64 @@
+package ingestor
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"tip-server/internal/models"
+)
+
+func TestReconcileDeletions(t *testing.T) {
+	tests := []struct {
+		name      string
+		deprecate string // DEPRECATE_DELETED_IOCS
+		cached    bool   // Still in the lookup cache afterwards
+	}{
+		{"tombstone only", "false", true},
+		{"deprecate", "true", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			i, clients := newTestIngestor(t, map[string]string{"DEPRECATE_DELETED_IOCS": tt.deprecate})
+			ctx := context.Background()
+			path := reportFile(t)
+
+			result := i.processFile(crawlJob(t, path))
+			if result.Status != models.ScanStatusInfected {
+				t.Fatalf("status = %s (%v), want infected", result.Status, result.Error)
+			}
+			ioc := storedIOCs(t, clients, result.FileID)["203.0.113.77"]
+			if err := clients.Redis.CacheIOCs(ctx, map[string]models.IOC{ioc.Value: ioc}, time.Hour); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := os.Remove(path); err != nil {
+				t.Fatal(err)
+			}
+			if err := i.reconcileDeletions(ctx, filepath.Dir(path)); err != nil {
+				t.Fatal(err)
+			}
+
+			meta, err := clients.ClickHouse.GetFileMetadata(ctx, result.FileID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if meta.ScanStatus != models.ScanStatusDeleted {
+				t.Errorf("registry status = %s, want deleted", meta.ScanStatus)
+			}
+			cached, err := clients.Redis.GetCachedIOCs(ctx, []string{ioc.Value})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, ok := cached[ioc.Value]; ok != tt.cached {
+				t.Errorf("cached = %v, want %v", ok, tt.cached)
+			}
+			pending, _ := clients.Redis.PendingBloomRemovals(ctx)
+			if (pending > 0) == tt.cached {
+				t.Errorf("pending Bloom removals = %d", pending)
+			}
+		})
+	}
+}
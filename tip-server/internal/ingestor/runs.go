@@ -0,0 +1,182 @@
+package ingestor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sync"
+
+	"github.com/rs/zerolog/log"
+
+	"tip-server/internal/config"
+	"tip-server/internal/db"
+	"tip-server/internal/models"
+)
+
+// runQueueSize bounds the on-demand runs waiting to be served; further
+// requests are dropped until the ingestor catches up
+const runQueueSize = 16
+
+// listenForRuns relays on-demand runs published by the API until ctx is
+// cancelled
+func (i *Ingestor) listenForRuns(ctx context.Context) {
+	sub := i.redis.Subscribe(ctx, db.IngestRunsChannel)
+	defer sub.Close()
+
+	payloads := sub.Payloads()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case payload, ok := <-payloads:
+			if !ok {
+				return
+			}
+			var run models.IngestRun
+			if err := json.Unmarshal([]byte(payload), &run); err != nil {
+				log.Warn().Err(err).Msg("Malformed ingestion run request")
+				continue
+			}
+			select {
+			case i.runs <- run:
+				log.Info().Str("run", run.ID).Str("path", run.Path).Str("feed", run.Feed).Int("files", len(run.Paths)).Msg("Ingestion run requested")
+			default:
+				log.Warn().Str("run", run.ID).Msg("Too many ingestion runs pending, dropping request")
+			}
+		}
+	}
+}
+
+// serveRuns crawls the on-demand runs requested while a pass is crawling,
+// until stop is closed. Their files are left out of the pass's checkpoint.
+func (i *Ingestor) serveRuns(ctx context.Context, policies *config.IngestPolicies, stop <-chan struct{}, wg *sync.WaitGroup) {
+	defer wg.Done()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ctx.Done():
+			return
+		case run := <-i.runs:
+			dirs, err := resolveRun(run, policies, i.reloader.Current().DataRoots)
+			if err != nil {
+				log.Warn().Err(err).Str("run", run.ID).Msg("Ignoring on-demand ingestion run")
+				continue
+			}
+			i.crawlRun(ctx, run, dirs, policies)
+		}
+	}
+}
+
+// runOnDemand serves an on-demand run requested between passes. Only the
+// run's directories or files are crawled, and the pass checkpoint is left
+// alone.
+func (i *Ingestor) runOnDemand(ctx context.Context, run models.IngestRun, policies *config.IngestPolicies) {
+	dirs, err := resolveRun(run, policies, i.reloader.Current().DataRoots)
+	if err != nil {
+		log.Warn().Err(err).Str("run", run.ID).Msg("Ignoring on-demand ingestion run")
+		return
+	}
+
+	log.Info().Str("run", run.ID).Msg("Starting on-demand ingestion run")
+	i.process(ctx, nil, policies, func() {
+		i.crawlRun(ctx, run, dirs, policies)
+	})
+	log.Info().Str("run", run.ID).Msg("On-demand ingestion run complete")
+}
+
+// crawlRun enqueues the files of an on-demand run ahead of any pass, then
+// reconciles deletions in each of its directories
+func (i *Ingestor) crawlRun(ctx context.Context, run models.IngestRun, dirs []config.IngestPolicy, policies *config.IngestPolicies) {
+	for _, dir := range dirs {
+		i.setDirectory(dir)
+		if err := i.crawl(ctx, dir, policies.Directories, models.JobPrioritySubmitted, nil); err != nil {
+			log.Error().Err(err).Str("run", run.ID).Str("directory", dir.Path).Msg("Crawl error")
+			continue
+		}
+		if i.cfg.Worker.DetectDeletions && ctx.Err() == nil {
+			if err := i.reconcileDeletions(ctx, dir.Path); err != nil {
+				log.Error().Err(err).Str("directory", dir.Path).Msg("Deletion reconciliation failed")
+			}
+		}
+	}
+}
+
+// resolveRun returns the directories an on-demand run crawls: every
+// directory attributed to its feed, or its path or paths with the policy of
+// the directory containing each
+func resolveRun(run models.IngestRun, policies *config.IngestPolicies, roots []config.DataRoot) ([]config.IngestPolicy, error) {
+	if len(run.Paths) > 0 {
+		var dirs []config.IngestPolicy
+		for _, path := range run.Paths {
+			dir, err := resolvePath(path, policies, roots)
+			if err != nil {
+				log.Warn().Err(err).Str("run", run.ID).Msg("Skipping file of on-demand ingestion run")
+				continue
+			}
+			dirs = append(dirs, dir)
+		}
+		if len(dirs) == 0 {
+			return nil, fmt.Errorf("none of the %d files is inside an ingestion directory", len(run.Paths))
+		}
+		return dirs, nil
+	}
+
+	if run.Feed != "" {
+		var dirs []config.IngestPolicy
+		for _, dir := range policies.Directories {
+			if dir.Source == run.Feed {
+				dirs = append(dirs, dir)
+			}
+		}
+		if len(dirs) == 0 {
+			return nil, fmt.Errorf("no directory is attributed to feed %q", run.Feed)
+		}
+		return dirs, nil
+	}
+
+	dir, err := resolvePath(run.Path, policies, roots)
+	if err != nil {
+		return nil, err
+	}
+	return []config.IngestPolicy{dir}, nil
+}
+
+// resolvePath returns the policy of the directory containing path, applied
+// to path itself
+func resolvePath(path string, policies *config.IngestPolicies, roots []config.DataRoot) (config.IngestPolicy, error) {
+	if !filepath.IsAbs(path) && len(roots) > 0 {
+		path = filepath.Join(roots[0].Path, path)
+	}
+	path = filepath.Clean(path)
+
+	var owner *config.IngestPolicy
+	for idx, dir := range policies.Directories {
+		if within(path, dir.Path) && (owner == nil || len(dir.Path) > len(owner.Path)) {
+			owner = &policies.Directories[idx]
+		}
+	}
+	if owner == nil {
+		return config.IngestPolicy{}, fmt.Errorf("%s is not inside an ingestion directory", path)
+	}
+	dir := *owner
+	dir.Path = path
+	return dir, nil
+}
+
+// directory returns the policy of a directory crawled in the current pass
+func (i *Ingestor) directory(path string) config.IngestPolicy {
+	i.dirMu.RLock()
+	defer i.dirMu.RUnlock()
+	return i.directories[path]
+}
+
+// setDirectory records the policy files crawled from a directory are
+// processed with
+func (i *Ingestor) setDirectory(dir config.IngestPolicy) {
+	i.dirMu.Lock()
+	defer i.dirMu.Unlock()
+	i.directories[dir.Path] = dir
+}
@@ -0,0 +1,64 @@
+package ingestor
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	mathrand "math/rand/v2"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"tip-server/internal/extractor"
+	"tip-server/internal/models"
+)
+
+// sampleExtractions keeps EXTRACT_SAMPLE_RATE percent of the IOCs stored
+// from a file, with the text around their first occurrence in content (the
+// stored copy), for analysts to label via /samples
+func (i *Ingestor) sampleExtractions(iocList []models.IOC, content []byte) {
+	rate := i.reloader.Current().Extractor.SampleRate
+	if rate <= 0 {
+		return
+	}
+
+	var folded []byte
+	now := time.Now().UTC()
+	var samples []models.ExtractionSample
+	for _, ioc := range iocList {
+		if mathrand.Float64()*100 >= rate {
+			continue
+		}
+		if folded == nil {
+			folded = extractor.FoldASCII(content)
+		}
+
+		sample := models.ExtractionSample{
+			ID:               newSampleID(),
+			Value:            ioc.Value,
+			Type:             ioc.Type,
+			SourceFileID:     ioc.SourceFileID,
+			ExtractorVersion: extractor.Version(),
+			Offset:           -1,
+			SampledAt:        now,
+		}
+		if at := extractor.Locate(folded, ioc.Value); at >= 0 {
+			sample.Offset = int64(at)
+			sample.Snippet = extractor.Snippet(content, at, at+len(ioc.Value))
+		}
+		samples = append(samples, sample)
+	}
+	if len(samples) == 0 {
+		return
+	}
+
+	if err := i.ch.InsertExtractionSamples(i.ctx, samples); err != nil {
+		log.Warn().Err(err).Int("samples", len(samples)).Msg("Failed to store extraction samples")
+	}
+}
+
+// newSampleID returns a random extraction sample ID
+func newSampleID() string {
+	buf := make([]byte, 8)
+	rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
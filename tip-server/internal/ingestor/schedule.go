@@ -0,0 +1,170 @@
+package ingestor
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"tip-server/internal/config"
+	"tip-server/internal/extractor"
+	"tip-server/internal/models"
+)
+
+// sourceTagPrefix marks the tag naming the data source a file was staged in
+const sourceTagPrefix = "source:"
+
+// profile is the extraction settings applied to the files of the directories
+// naming it. The "" profile holds the global settings.
+type profile struct {
+	worker    config.WorkerConfig
+	extractor *extractor.Extractor
+}
+
+// buildProfiles creates an extractor per extraction profile from the live
+// configuration. A profile whose extractor cannot be built falls back to the
+// global settings.
+func buildProfiles(cfg *config.Config, profiles map[string]config.ExtractionProfile) (map[string]*profile, error) {
+	global, err := extractor.NewExtractorFromConfig(cfg.Extractor)
+	if err != nil {
+		return nil, err
+	}
+	built := map[string]*profile{"": {worker: cfg.Worker, extractor: global}}
+
+	for name, overrides := range profiles {
+		applied := overrides.Apply(*cfg)
+		extract, err := extractor.NewExtractorFromConfig(applied.Extractor)
+		if err != nil {
+			log.Warn().Err(err).Str("profile", name).Msg("Failed to build extraction profile, using global settings")
+			built[name] = built[""]
+			continue
+		}
+		built[name] = &profile{worker: applied.Worker, extractor: extract}
+	}
+	return built, nil
+}
+
+// profile returns the named extraction profile of the current pass, or the
+// global settings when the profile no longer exists
+func (i *Ingestor) profile(name string) *profile {
+	if p, ok := i.profiles[name]; ok {
+		return p
+	}
+	return i.profiles[""]
+}
+
+// loadPolicies re-reads the directory policies, keeping the previous ones
+// when the policy file no longer loads
+func (i *Ingestor) loadPolicies() *config.IngestPolicies {
+	policies, err := config.LoadIngestPolicies(i.reloader.Current())
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to load ingestion policies, keeping previous ones")
+		return i.policies
+	}
+	i.policies = policies
+	return policies
+}
+
+// Watch crawls each policy directory whenever its rescan interval (by
+// default WATCH_INTERVAL) has elapsed, until ctx is cancelled. Directories
+// without an interval are crawled once. Between passes, on-demand runs are
+// served on their own, crawling only the directories or files they name.
+func (i *Ingestor) Watch(ctx context.Context) error {
+	// Next crawl per directory; the zero time means never again
+	nextScan := make(map[string]time.Time)
+
+	i.runs = make(chan models.IngestRun, runQueueSize)
+	go i.listenForRuns(ctx)
+
+	for {
+		policies := i.loadPolicies()
+
+		now := time.Now()
+		var due []config.IngestPolicy
+		for _, dir := range policies.Directories {
+			if next, seen := nextScan[dir.Path]; !seen || (!next.IsZero() && !next.After(now)) {
+				due = append(due, dir)
+			}
+		}
+
+		if len(due) > 0 {
+			if err := i.Run(ctx, due, policies); err != nil {
+				return err
+			}
+			if ctx.Err() != nil {
+				return nil
+			}
+
+			finished := time.Now()
+			watchInterval := i.reloader.Current().Worker.WatchInterval
+			for _, dir := range due {
+				nextScan[dir.Path] = time.Time{}
+				if interval := cmp.Or(dir.RescanInterval, watchInterval); interval > 0 {
+					nextScan[dir.Path] = finished.Add(interval)
+				}
+			}
+		}
+
+		var wake time.Time
+		for _, dir := range policies.Directories {
+			if next := nextScan[dir.Path]; !next.IsZero() && (wake.IsZero() || next.Before(wake)) {
+				wake = next
+			}
+		}
+		// With no pass scheduled, only on-demand runs wake the loop
+		var nextPass <-chan time.Time
+		if wake.IsZero() {
+			log.Info().Msg("No ingestion pass scheduled, waiting for on-demand runs")
+		} else {
+			log.Info().Time("next_pass", wake).Msg("Waiting for next ingestion pass")
+			nextPass = time.After(time.Until(wake))
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-nextPass:
+		case run := <-i.runs:
+			i.runOnDemand(ctx, run, policies)
+		}
+	}
+}
+
+// RunOnce crawls every policy directory once and returns; on-demand runs are
+// not served
+func (i *Ingestor) RunOnce(ctx context.Context) error {
+	policies := i.loadPolicies()
+	return i.Run(ctx, policies.Directories, policies)
+}
+
+// applyDirectoryAttributes attributes IOCs to the source of the directory
+// their file was staged in: its source tag and default tags are added, and
+// its malware family is used where neither the file nor a detection gave one
+func applyDirectoryAttributes(iocList []models.IOC, dir config.IngestPolicy) {
+	tags := slices.Clone(dir.Tags)
+	if dir.Source != "" {
+		tags = append(tags, sourceTagPrefix+dir.Source)
+	}
+	for idx := range iocList {
+		ioc := &iocList[idx]
+		if dir.MalwareFamily != "" && (ioc.MalwareFamily == "" || ioc.MalwareFamily == "Unknown") {
+			ioc.MalwareFamily = dir.MalwareFamily
+		}
+		for _, tag := range tags {
+			if !slices.Contains(ioc.Tags, tag) {
+				ioc.Tags = append(slices.Clip(ioc.Tags), tag)
+			}
+		}
+	}
+}
+
+// byPriority orders directories highest priority first, keeping the policy
+// file order among equals
+func byPriority(dirs []config.IngestPolicy) []config.IngestPolicy {
+	sorted := slices.Clone(dirs)
+	slices.SortStableFunc(sorted, func(a, b config.IngestPolicy) int {
+		return cmp.Compare(b.Priority, a.Priority)
+	})
+	return sorted
+}
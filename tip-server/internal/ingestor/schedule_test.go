@@ -0,0 +1,80 @@
+package ingestor
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"tip-server/internal/models"
+)
+
+// TestWatchServesRunsAfterSinglePass watches without WATCH_INTERVAL: after
+// the one pass, an on-demand run must still be served, crawling only its
+// own directory and leaving the pass checkpoint alone
+func TestWatchServesRunsAfterSinglePass(t *testing.T) {
+	feeds, drops := t.TempDir(), t.TempDir()
+	writeNotes := func(dir, prefix string, count int) {
+		for n := 0; n < count; n++ {
+			path := filepath.Join(dir, fmt.Sprintf("%s-%d.txt", prefix, n))
+			if err := os.WriteFile(path, []byte(fmt.Sprintf("Notes %s %d, nothing to report.\n", prefix, n)), 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	writeNotes(feeds, "pass", 3)
+	writeNotes(drops, "pass", 3)
+
+	i, clients := newRunIngestor(t, map[string]string{"DATA_PATH": feeds + "," + drops})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	watchErr := make(chan error, 1)
+	go func() { watchErr <- i.Watch(ctx) }()
+
+	waitFor := func(what string, done func() bool) {
+		t.Helper()
+		for deadline := time.Now().Add(5 * time.Second); !done(); time.Sleep(10 * time.Millisecond) {
+			if time.Now().After(deadline) {
+				t.Fatalf("timed out waiting for %s", what)
+			}
+		}
+	}
+	var passed models.IngestCheckpoint
+	waitFor("the pass", func() bool {
+		return clients.Redis.GetJSON(ctx, checkpointKey, &passed) == nil && passed.Completed
+	})
+	if processed := atomic.LoadInt64(&i.stats.FilesProcessed); processed != 6 {
+		t.Fatalf("pass processed %d files, want 6", processed)
+	}
+
+	// New files land in both directories; the run only names one
+	writeNotes(feeds, "later", 2)
+	writeNotes(drops, "later", 2)
+	waitFor("the ingestor to listen for runs", func() bool {
+		listeners, err := clients.Redis.RequestIngestRun(ctx, models.IngestRun{ID: "drop", Path: drops})
+		return err == nil && listeners > 0
+	})
+	waitFor("the run", func() bool {
+		return atomic.LoadInt64(&i.stats.FilesProcessed) >= 8
+	})
+
+	cancel()
+	if err := <-watchErr; err != nil {
+		t.Fatal(err)
+	}
+
+	if processed := atomic.LoadInt64(&i.stats.FilesProcessed); processed != 8 {
+		t.Errorf("processed %d files, want 8: the pass and the run's directory only", processed)
+	}
+	var after models.IngestCheckpoint
+	if err := clients.Redis.GetJSON(context.Background(), checkpointKey, &after); err != nil {
+		t.Fatal(err)
+	}
+	if !after.StartedAt.Equal(passed.StartedAt) || after.LastPath != passed.LastPath || !after.Completed {
+		t.Errorf("checkpoint = %+v, want the pass's %+v", after, passed)
+	}
+}
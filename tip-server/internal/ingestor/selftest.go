@@ -0,0 +1,234 @@
+package ingestor
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"text/tabwriter"
+
+	"tip-server/internal/config"
+	"tip-server/internal/extractor"
+	"tip-server/internal/metrics"
+	"tip-server/internal/models"
+)
+
+// CorpusExpectations are the IOCs a golden corpus must yield: by file path
+// relative to the corpus (slash-separated), the canonical values of each
+// type. A file expected to yield nothing is listed with an empty object.
+type CorpusExpectations map[string]map[models.IOCType][]string
+
+// CorpusReport is the outcome of CheckCorpus
+type CorpusReport struct {
+	Dir      string
+	Files    int
+	Failures []CorpusFailure // By path
+	Types    map[models.IOCType]*CorpusTypeStats
+}
+
+// CorpusFailure is a corpus file whose IOCs differ from the expected ones
+type CorpusFailure struct {
+	Path       string
+	Error      string                      // Extraction failed, or the file is missing or unlisted
+	Missing    map[models.IOCType][]string // Expected but not extracted
+	Unexpected map[models.IOCType][]string // Extracted but not expected
+}
+
+// CorpusTypeStats counts the values of one IOC type over the corpus
+type CorpusTypeStats struct {
+	Expected   int
+	Extracted  int
+	Missing    int
+	Unexpected int
+}
+
+// Passed reports whether every file yielded exactly the expected IOCs
+func (r *CorpusReport) Passed() bool {
+	return len(r.Failures) == 0
+}
+
+// CheckCorpus extracts IOCs from every file under dir the way an ingestion
+// pass would with the global settings, without storing anything, and
+// compares them with the expectations in expectedFile. It is meant to run
+// before deploying pattern or list changes; a failing report lists every
+// value gained or lost.
+func CheckCorpus(cfg *config.Config, dir, expectedFile string) (*CorpusReport, error) {
+	raw, err := os.ReadFile(expectedFile)
+	if err != nil {
+		return nil, err
+	}
+	var expected CorpusExpectations
+	if err := json.Unmarshal(raw, &expected); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", expectedFile, err)
+	}
+	for rel, types := range expected {
+		for t := range types {
+			if !slices.Contains(models.AllIOCTypes(), t) {
+				return nil, fmt.Errorf("%s: %s: unknown IOC type %q", expectedFile, rel, t)
+			}
+		}
+	}
+
+	extract, err := extractor.NewExtractorFromConfig(cfg.Extractor)
+	if err != nil {
+		return nil, err
+	}
+	i := &Ingestor{cfg: cfg, metrics: metrics.GetMetrics()}
+	p := &profile{worker: cfg.Worker, extractor: extract}
+
+	// The expectations may be kept alongside the corpus
+	skip, _ := filepath.Abs(expectedFile)
+
+	report := &CorpusReport{Dir: dir, Types: make(map[models.IOCType]*CorpusTypeStats)}
+	seen := make(map[string]bool)
+	err = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil || rel == "." {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if crawlExcluded(cfg.Worker, rel, d.Name()) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		if abs, _ := filepath.Abs(path); abs == skip {
+			return nil
+		}
+
+		report.Files++
+		seen[rel] = true
+		want, listed := expected[rel]
+		if !listed {
+			report.Failures = append(report.Failures, CorpusFailure{Path: rel, Error: "not listed in the expectations"})
+			return nil
+		}
+
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		ext, err := i.extractWithTimeout(p, path, content)
+		if err != nil {
+			report.Failures = append(report.Failures, CorpusFailure{Path: rel, Error: err.Error()})
+			return nil
+		}
+		if f, ok := report.compare(rel, want, ext.iocs); !ok {
+			report.Failures = append(report.Failures, f)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for rel := range expected {
+		if !seen[rel] {
+			report.Failures = append(report.Failures, CorpusFailure{Path: rel, Error: "not found in the corpus"})
+		}
+	}
+	slices.SortFunc(report.Failures, func(a, b CorpusFailure) int {
+		return cmp.Compare(a.Path, b.Path)
+	})
+	return report, nil
+}
+
+// compare diffs the IOCs extracted from a file with the expected ones,
+// counting both toward the per-type totals
+func (r *CorpusReport) compare(rel string, want, got map[models.IOCType][]string) (CorpusFailure, bool) {
+	f := CorpusFailure{
+		Path:       rel,
+		Missing:    make(map[models.IOCType][]string),
+		Unexpected: make(map[models.IOCType][]string),
+	}
+
+	types := make(map[models.IOCType]bool)
+	for t := range want {
+		types[t] = true
+	}
+	for t := range got {
+		types[t] = true
+	}
+	for t := range types {
+		stats := r.Types[t]
+		if stats == nil {
+			stats = &CorpusTypeStats{}
+			r.Types[t] = stats
+		}
+		stats.Expected += len(want[t])
+		stats.Extracted += len(got[t])
+
+		if missing := difference(want[t], got[t]); len(missing) > 0 {
+			f.Missing[t] = missing
+			stats.Missing += len(missing)
+		}
+		if unexpected := difference(got[t], want[t]); len(unexpected) > 0 {
+			f.Unexpected[t] = unexpected
+			stats.Unexpected += len(unexpected)
+		}
+	}
+	return f, len(f.Missing) == 0 && len(f.Unexpected) == 0
+}
+
+// difference returns the values of a not in b, sorted
+func difference(a, b []string) []string {
+	in := make(map[string]bool, len(b))
+	for _, v := range b {
+		in[v] = true
+	}
+	var out []string
+	for _, v := range a {
+		if !in[v] {
+			in[v] = true
+			out = append(out, v)
+		}
+	}
+	slices.Sort(out)
+	return out
+}
+
+// Write prints the report: each failing file with the values it lost (-)
+// or gained (+), then the totals per IOC type
+func (r *CorpusReport) Write(w io.Writer) {
+	fmt.Fprintf(w, "Corpus:  %s\n", r.Dir)
+	fmt.Fprintf(w, "Files:   %d\n", r.Files)
+	fmt.Fprintf(w, "Failed:  %d\n", len(r.Failures))
+
+	for _, f := range r.Failures {
+		fmt.Fprintf(w, "\nFAIL %s\n", f.Path)
+		if f.Error != "" {
+			fmt.Fprintf(w, "  %s\n", f.Error)
+		}
+		for _, t := range slices.Sorted(maps.Keys(f.Missing)) {
+			for _, v := range f.Missing[t] {
+				fmt.Fprintf(w, "  - %-10s %s\n", t, v)
+			}
+		}
+		for _, t := range slices.Sorted(maps.Keys(f.Unexpected)) {
+			for _, v := range f.Unexpected[t] {
+				fmt.Fprintf(w, "  + %-10s %s\n", t, v)
+			}
+		}
+	}
+
+	fmt.Fprintln(w)
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "TYPE\tEXPECTED\tEXTRACTED\tMISSING\tUNEXPECTED")
+	for _, t := range slices.Sorted(maps.Keys(r.Types)) {
+		s := r.Types[t]
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%d\n", t, s.Expected, s.Extracted, s.Missing, s.Unexpected)
+	}
+	tw.Flush()
+}
@@ -0,0 +1,155 @@
+package ingestor
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"tip-server/internal/config"
+	"tip-server/internal/models"
+)
+
+func TestCheckCorpus(t *testing.T) {
+	t.Setenv("CONFIG_FILE", filepath.Join(t.TempDir(), ".env"))
+	t.Setenv("API_KEY", "test-api-key")
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		// edit changes testdata/expected.json, which matches the corpus
+		edit      func(CorpusExpectations)
+		wantErr   bool
+		failures  []CorpusFailure
+		domain    CorpusTypeStats
+		ipv4      CorpusTypeStats
+		reportOut []string
+	}{
+		{
+			name:   "pass",
+			edit:   func(CorpusExpectations) {},
+			domain: CorpusTypeStats{Expected: 1, Extracted: 1},
+			ipv4:   CorpusTypeStats{Expected: 1, Extracted: 1},
+		},
+		{
+			name: "value lost",
+			edit: func(e CorpusExpectations) {
+				e["report.txt"][models.IOCTypeDomain] = append(e["report.txt"][models.IOCTypeDomain], "evil.example")
+			},
+			failures: []CorpusFailure{{
+				Path:       "report.txt",
+				Missing:    map[models.IOCType][]string{models.IOCTypeDomain: {"evil.example"}},
+				Unexpected: map[models.IOCType][]string{},
+			}},
+			domain:    CorpusTypeStats{Expected: 2, Extracted: 1, Missing: 1},
+			ipv4:      CorpusTypeStats{Expected: 1, Extracted: 1},
+			reportOut: []string{"FAIL report.txt", "- domain     evil.example"},
+		},
+		{
+			name: "value gained",
+			edit: func(e CorpusExpectations) {
+				delete(e["report.txt"], models.IOCTypeIPv4)
+			},
+			failures: []CorpusFailure{{
+				Path:       "report.txt",
+				Missing:    map[models.IOCType][]string{},
+				Unexpected: map[models.IOCType][]string{models.IOCTypeIPv4: {"203.0.113.77"}},
+			}},
+			domain:    CorpusTypeStats{Expected: 1, Extracted: 1},
+			ipv4:      CorpusTypeStats{Extracted: 1, Unexpected: 1},
+			reportOut: []string{"FAIL report.txt", "+ ipv4       203.0.113.77"},
+		},
+		{
+			name: "file missing and unlisted",
+			edit: func(e CorpusExpectations) {
+				delete(e, "notes.txt")
+				e["removed.txt"] = map[models.IOCType][]string{}
+			},
+			failures: []CorpusFailure{
+				{Path: "notes.txt", Error: "not listed in the expectations"},
+				{Path: "removed.txt", Error: "not found in the corpus"},
+			},
+			domain:    CorpusTypeStats{Expected: 1, Extracted: 1},
+			ipv4:      CorpusTypeStats{Expected: 1, Extracted: 1},
+			reportOut: []string{"Failed:  2", "FAIL notes.txt", "FAIL removed.txt"},
+		},
+		{
+			name: "unknown type",
+			edit: func(e CorpusExpectations) {
+				e["report.txt"]["hostname"] = []string{"update-checker-cdn.net"}
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expectedFile := writeExpectations(t, tt.edit)
+
+			report, err := CheckCorpus(cfg, filepath.Join("testdata", "corpus"), expectedFile)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("CheckCorpus succeeded, want an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if report.Files != 2 {
+				t.Errorf("files = %d, want 2", report.Files)
+			}
+			if report.Passed() != (len(tt.failures) == 0) {
+				t.Errorf("passed = %v with failures %+v", report.Passed(), report.Failures)
+			}
+			if !reflect.DeepEqual(report.Failures, tt.failures) {
+				t.Errorf("failures = %+v, want %+v", report.Failures, tt.failures)
+			}
+			for iocType, want := range map[models.IOCType]CorpusTypeStats{
+				models.IOCTypeDomain: tt.domain,
+				models.IOCTypeIPv4:   tt.ipv4,
+			} {
+				if got := report.Types[iocType]; got == nil || *got != want {
+					t.Errorf("%s stats = %+v, want %+v", iocType, got, want)
+				}
+			}
+
+			var out strings.Builder
+			report.Write(&out)
+			for _, line := range tt.reportOut {
+				if !strings.Contains(out.String(), line) {
+					t.Errorf("report lacks %q:\n%s", line, out.String())
+				}
+			}
+		})
+	}
+}
+
+// writeExpectations writes testdata/expected.json, changed by edit, to a
+// temporary file and returns its path
+func writeExpectations(t *testing.T, edit func(CorpusExpectations)) string {
+	t.Helper()
+	raw, err := os.ReadFile(filepath.Join("testdata", "expected.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var expected CorpusExpectations
+	if err := json.Unmarshal(raw, &expected); err != nil {
+		t.Fatal(err)
+	}
+	edit(expected)
+
+	if raw, err = json.Marshal(expected); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "expected.json")
+	if err := os.WriteFile(path, raw, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
@@ -0,0 +1,86 @@
+package ingestor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/rs/zerolog/log"
+
+	"tip-server/internal/embed"
+	"tip-server/internal/models"
+)
+
+// indexFile adds a processed file to the similarity index. Failures are
+// logged and counted but never fail the file.
+func (i *Ingestor) indexFile(meta *models.FileMetadata, content []byte) {
+	if i.index == nil {
+		return
+	}
+
+	err := i.index.IndexFile(i.ctx, meta, content)
+	switch {
+	case err == nil:
+		i.metrics.FilesEmbedded.WithLabelValues("indexed").Inc()
+	case errors.Is(err, embed.ErrNotText), errors.Is(err, embed.ErrNoContent):
+		i.metrics.FilesEmbedded.WithLabelValues("skipped").Inc()
+	default:
+		i.metrics.FilesEmbedded.WithLabelValues("failed").Inc()
+		log.Warn().Err(err).Str("file", meta.FilePath).Msg("Failed to index file content")
+	}
+}
+
+// IndexStoredFiles embeds every misc file already stored in MinIO, for
+// deployments that enable similarity search after files were ingested
+func (i *Ingestor) IndexStoredFiles(ctx context.Context) error {
+	if i.index == nil {
+		return fmt.Errorf("similarity index is not available (is QDRANT_ENABLED set?)")
+	}
+
+	files, err := i.ch.ListStoredMiscFiles(ctx)
+	if err != nil {
+		return err
+	}
+
+	indexed, skipped, failed := 0, 0, 0
+	for idx := range files {
+		if ctx.Err() != nil {
+			break
+		}
+		meta := &files[idx]
+
+		content, err := i.readStored(ctx, meta.MinIOKey)
+		if err == nil {
+			err = i.index.IndexFile(ctx, meta, content)
+		}
+		switch {
+		case err == nil:
+			indexed++
+		case errors.Is(err, embed.ErrNotText), errors.Is(err, embed.ErrNoContent):
+			skipped++
+		default:
+			failed++
+			log.Warn().Err(err).Str("file", meta.FilePath).Msg("Failed to index stored file")
+		}
+	}
+
+	log.Info().
+		Int("files", len(files)).
+		Int("indexed", indexed).
+		Int("skipped", skipped).
+		Int("failed", failed).
+		Msg("Vector indexing finished")
+	return ctx.Err()
+}
+
+// readStored reads the prefix of a stored object that the embedder will use
+func (i *Ingestor) readStored(ctx context.Context, minioKey string) ([]byte, error) {
+	reader, _, _, err := i.minio.OpenObject(ctx, minioKey)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	return io.ReadAll(io.LimitReader(reader, int64(i.cfg.Embedding.MaxBytes)))
+}
@@ -0,0 +1,136 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"tip-server/internal/db"
+)
+
+// bloomRebuildBatchSize is the number of values added per BF.MADD call
+const bloomRebuildBatchSize = 10000
+
+// bloomCatchUpMargin covers clock skew between hosts when re-adding values
+// ingested while a rebuild was running
+const bloomCatchUpMargin = time.Minute
+
+// NewBloomRebuild returns a job that rebuilds the Bloom filter from active
+// ClickHouse IOCs whenever removals have been scheduled
+func NewBloomRebuild(ch db.IOCStore, redis db.Cache) JobFunc {
+	return func(ctx context.Context) error {
+		pending, err := redis.PendingBloomRemovals(ctx)
+		if err != nil {
+			return err
+		}
+		if pending == 0 {
+			return nil
+		}
+
+		started := time.Now()
+		added := 0
+
+		err = redis.RebuildBloomFilter(ctx, func(add func([]string) error) error {
+			return ch.StreamActiveIOCValues(ctx, time.Time{}, bloomRebuildBatchSize, func(values []string) error {
+				added += len(values)
+				return add(values)
+			})
+		})
+		if err != nil {
+			return err
+		}
+
+		// The ingestor keeps writing to the old filter until the swap; re-add
+		// anything that landed in ClickHouse while the rebuild was running
+		err = ch.StreamActiveIOCValues(ctx, started.Add(-bloomCatchUpMargin), bloomRebuildBatchSize, func(values []string) error {
+			return redis.BFMAdd(ctx, values)
+		})
+		if err != nil {
+			return err
+		}
+
+		log.Info().
+			Int64("removals", pending).
+			Int("values_added", added).
+			Dur("duration", time.Since(started)).
+			Msg("Bloom filter rebuilt")
+
+		return nil
+	}
+}
+
+// NewBloomSnapshot returns a job that dumps the Bloom filter to MinIO so an
+// emptied Redis can be refilled from it instead of rebuilt
+func NewBloomSnapshot(redis db.Cache, minio db.ObjectStore, filterName string) JobFunc {
+	return func(ctx context.Context) error {
+		// An empty filter is one waiting to be restored or rebuilt; saving
+		// it would replace the snapshot that could refill it
+		info, err := redis.BFInfo(ctx)
+		if err != nil {
+			return err
+		}
+		if info.ItemsInserted == 0 {
+			return nil
+		}
+
+		started := time.Now()
+		snap, err := db.SaveBloomSnapshot(ctx, redis, minio, db.BloomSnapshotKey(filterName))
+		if err != nil {
+			return err
+		}
+
+		log.Info().
+			Int64("items", snap.Items).
+			Int("chunks", snap.Chunks).
+			Int64("bytes", snap.Bytes).
+			Dur("duration", time.Since(started)).
+			Msg("Bloom filter snapshot saved")
+
+		return nil
+	}
+}
+
+// NewBloomSnapshotRestore returns a job that loads the latest snapshot when
+// the Bloom filter is empty, as after a Redis restart without persistence,
+// then re-adds the values ClickHouse stored after the snapshot was taken
+// (all of them when there is no snapshot)
+func NewBloomSnapshotRestore(ch db.IOCStore, redis db.Cache, minio db.ObjectStore, filterName string) JobFunc {
+	return func(ctx context.Context) error {
+		info, err := redis.BFInfo(ctx)
+		if err != nil {
+			return err
+		}
+		if info.ItemsInserted > 0 {
+			return nil
+		}
+
+		started := time.Now()
+		snap, err := db.RestoreBloomSnapshot(ctx, redis, minio, db.BloomSnapshotKey(filterName))
+		if errors.Is(err, db.ErrNoBloomSnapshot) {
+			// Nothing to load; refill from ClickHouse the slow way
+			log.Warn().Msg("Bloom filter is empty and no snapshot is stored, refilling from ClickHouse")
+		} else if err != nil {
+			return err
+		}
+
+		added := 0
+		err = ch.StreamActiveIOCValues(ctx, snap.TakenAt.Add(-bloomCatchUpMargin), bloomRebuildBatchSize, func(values []string) error {
+			added += len(values)
+			return redis.BFMAdd(ctx, values)
+		})
+		if err != nil {
+			return err
+		}
+
+		log.Info().
+			Int64("items", snap.Items).
+			Time("taken_at", snap.TakenAt).
+			Int("values_added", added).
+			Dur("duration", time.Since(started)).
+			Msg("Bloom filter restored from snapshot")
+
+		return nil
+	}
+}
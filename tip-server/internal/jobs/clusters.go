@@ -0,0 +1,178 @@
+package jobs
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"tip-server/internal/config"
+	"tip-server/internal/db"
+	"tip-server/internal/embed"
+	"tip-server/internal/models"
+)
+
+// NewVectorClustering returns a job that groups indexed files by content
+// similarity. Each file is linked to its nearest neighbours scoring at least
+// cfg.Threshold and clusters are the connected components of those links.
+// Indicators extracted from member files are attached to each cluster and
+// the report is stored in Redis for GET /clusters.
+func NewVectorClustering(index *embed.Index, ch db.IOCStore, redis db.Cache, cfg config.ClusterConfig) JobFunc {
+	return func(ctx context.Context) error {
+		files, err := index.Files(ctx, cfg.MaxPoints)
+		if err != nil {
+			return err
+		}
+
+		pos := make(map[uint64]int, len(files))
+		for i, f := range files {
+			pos[f.PointID] = i
+		}
+
+		sets := newDisjointSet(len(files))
+		links := make([]int, len(files))
+		for i, f := range files {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+
+			// One extra result: a file is always its own nearest neighbour
+			ids, err := index.Neighbors(ctx, f.Vector, cfg.Neighbors+1, float32(cfg.Threshold))
+			if err != nil {
+				return err
+			}
+			for _, id := range ids {
+				if j, ok := pos[id]; ok && j != i {
+					sets.union(i, j)
+					links[i]++
+				}
+			}
+		}
+
+		groups := make(map[int][]int)
+		for i := range files {
+			root := sets.find(i)
+			groups[root] = append(groups[root], i)
+		}
+
+		report := models.ClusterReport{
+			GeneratedAt: time.Now().UTC(),
+			Points:      len(files),
+			Threshold:   float32(cfg.Threshold),
+			Clusters:    []models.Cluster{},
+		}
+		for _, members := range groups {
+			if len(members) < cfg.MinSize {
+				continue
+			}
+			cluster, err := buildCluster(ctx, ch, files, links, members, cfg)
+			if err != nil {
+				return err
+			}
+			report.Clusters = append(report.Clusters, cluster)
+		}
+
+		sort.Slice(report.Clusters, func(a, b int) bool {
+			if report.Clusters[a].Size != report.Clusters[b].Size {
+				return report.Clusters[a].Size > report.Clusters[b].Size
+			}
+			return report.Clusters[a].Representative.FilePath < report.Clusters[b].Representative.FilePath
+		})
+		for i := range report.Clusters {
+			report.Clusters[i].ID = i + 1
+		}
+
+		if err := redis.SetJSON(ctx, db.ClusterReportKey, report, 0); err != nil {
+			return err
+		}
+
+		log.Info().
+			Int("files", len(files)).
+			Int("clusters", len(report.Clusters)).
+			Msg("Clustered file vectors")
+		return nil
+	}
+}
+
+// buildCluster describes one connected component: members ordered by how
+// many links they have, the best-connected one as representative, and the
+// indicators extracted from infected members
+func buildCluster(ctx context.Context, ch db.IOCStore, files []embed.IndexedFile, links []int, members []int, cfg config.ClusterConfig) (models.Cluster, error) {
+	sort.Slice(members, func(a, b int) bool {
+		ma, mb := members[a], members[b]
+		if links[ma] != links[mb] {
+			return links[ma] > links[mb]
+		}
+		return files[ma].File.FilePath < files[mb].File.FilePath
+	})
+
+	cluster := models.Cluster{Size: len(members)}
+	var infected []string
+	for n, idx := range members {
+		member := files[idx].File
+		member.Links = links[idx]
+		if n < cfg.MaxMembers {
+			cluster.Members = append(cluster.Members, member)
+		}
+		if member.ScanStatus == models.ScanStatusInfected {
+			infected = append(infected, member.FileID)
+		}
+	}
+	cluster.Representative = cluster.Members[0]
+
+	if len(infected) == 0 {
+		return cluster, nil
+	}
+
+	indicators, err := ch.GetIndicatorsBySourceFiles(ctx, infected, cfg.MaxIndicators)
+	if err != nil {
+		return cluster, err
+	}
+	cluster.Indicators = indicators
+
+	// Families ordered by how many member files their indicators appear in
+	weight := make(map[string]int)
+	for _, ind := range indicators {
+		if ind.MalwareFamily != "" {
+			weight[ind.MalwareFamily] += ind.Files
+		}
+	}
+	for family := range weight {
+		cluster.MalwareFamilies = append(cluster.MalwareFamilies, family)
+	}
+	sort.Slice(cluster.MalwareFamilies, func(a, b int) bool {
+		fa, fb := cluster.MalwareFamilies[a], cluster.MalwareFamilies[b]
+		if weight[fa] != weight[fb] {
+			return weight[fa] > weight[fb]
+		}
+		return fa < fb
+	})
+
+	return cluster, nil
+}
+
+// disjointSet is a union-find over indexes with path halving
+type disjointSet []int
+
+func newDisjointSet(n int) disjointSet {
+	s := make(disjointSet, n)
+	for i := range s {
+		s[i] = i
+	}
+	return s
+}
+
+func (s disjointSet) find(i int) int {
+	for s[i] != i {
+		s[i] = s[s[i]]
+		i = s[i]
+	}
+	return i
+}
+
+func (s disjointSet) union(a, b int) {
+	if ra, rb := s.find(a), s.find(b); ra != rb {
+		s[rb] = ra
+	}
+}
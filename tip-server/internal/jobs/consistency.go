@@ -0,0 +1,242 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"tip-server/internal/config"
+	"tip-server/internal/db"
+	"tip-server/internal/metrics"
+	"tip-server/internal/models"
+)
+
+// consistencyMaxBloomMissing stops the Bloom filter walk once this many
+// distinct values are missing; the filter needs a rebuild either way
+const consistencyMaxBloomMissing = 1000000
+
+// consistencyRecheckLimit bounds the missing values checked again at the
+// end of the walk, which drops values whose Bloom filter addition was still
+// in flight when they were first checked
+const consistencyRecheckLimit = 100000
+
+// NewConsistencyCheck returns a job that cross-checks the stores a failed
+// write leaves out of step: active IOC values missing from the Bloom filter,
+// MinIO objects no registry entry references and registry keys with no
+// object, and IOCs whose source file is not registered or registered files
+// whose IOCs are gone. Entries younger than cfg.GracePeriod may be
+// mid-ingestion and are not reported. Nothing is repaired; the report is
+// stored in Redis for GET /admin/consistency and its counts exported as
+// metrics.
+func NewConsistencyCheck(ch db.IOCStore, redis db.Cache, minio db.ObjectStore, cfg config.ConsistencyConfig) JobFunc {
+	return func(ctx context.Context) error {
+		m := metrics.GetMetrics()
+		started := time.Now()
+		cutoff := started.Add(-cfg.GracePeriod)
+
+		bloom := checkBloom(ctx, ch, redis, cfg.SampleSize)
+		orphanObjects, missingObjects := checkObjects(ctx, ch, minio, cutoff, cfg.SampleSize)
+		orphanIOCs := checkIDs(models.ConsistencyOrphanIOCs, cfg.SampleSize, func(limit int) ([]string, uint64, error) {
+			return ch.ListOrphanedIOCSources(ctx, cutoff, limit)
+		})
+		missingIOCs := checkIDs(models.ConsistencyMissingIOCs, cfg.SampleSize, func(limit int) ([]string, uint64, error) {
+			return ch.ListFilesMissingIOCs(ctx, cutoff, limit)
+		})
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		report := models.ConsistencyReport{
+			GeneratedAt: started.UTC(),
+			Checks:      []models.ConsistencyCheck{bloom, orphanObjects, missingObjects, orphanIOCs, missingIOCs},
+		}
+		failed := 0
+		for _, check := range report.Checks {
+			if check.Error != "" {
+				failed++
+				log.Warn().Str("check", check.Name).Str("error", check.Error).Msg("Consistency check incomplete")
+			}
+			report.Issues += check.Count
+			// A check that failed outright keeps its last gauge value
+			if check.Error == "" || check.Count > 0 {
+				m.ConsistencyIssues.WithLabelValues(check.Name).Set(float64(check.Count))
+			}
+		}
+
+		pending, err := redis.PendingBloomRemovals(ctx)
+		if err != nil {
+			log.Warn().Err(err).Msg("Failed to count pending Bloom filter removals")
+		}
+		report.BloomPendingRemovals = pending
+		report.DurationSeconds = time.Since(started).Seconds()
+
+		if err := redis.SetJSON(ctx, db.ConsistencyReportKey, report, 0); err != nil {
+			return err
+		}
+		m.ConsistencyLastRun.SetToCurrentTime()
+
+		event := log.Info()
+		if report.Issues > 0 || failed > 0 {
+			event = log.Warn()
+		}
+		event.
+			Uint64("bloom_missing", bloom.Count).
+			Uint64("orphan_objects", orphanObjects.Count).
+			Uint64("missing_objects", missingObjects.Count).
+			Uint64("orphan_iocs", orphanIOCs.Count).
+			Uint64("missing_iocs", missingIOCs.Count).
+			Int("incomplete", failed).
+			Dur("duration", time.Since(started)).
+			Msg("Consistency check complete")
+
+		return nil
+	}
+}
+
+// errBloomWalkStopped ends the Bloom filter walk once
+// consistencyMaxBloomMissing values are missing
+var errBloomWalkStopped = fmt.Errorf("stopped after %d missing values; rebuild the Bloom filter", consistencyMaxBloomMissing)
+
+// checkBloom walks the active IOC values and counts the distinct ones the
+// Bloom filter does not hold. /check answers "not found" for those without
+// asking ClickHouse.
+func checkBloom(ctx context.Context, ch db.IOCStore, redis db.Cache, sampleSize int) models.ConsistencyCheck {
+	check := models.ConsistencyCheck{Name: models.ConsistencyBloomMissing}
+
+	missing := make(map[string]struct{})
+	err := ch.StreamActiveIOCValues(ctx, time.Time{}, bloomRebuildBatchSize, func(values []string) error {
+		found, err := redis.BFMExists(ctx, values)
+		if err != nil {
+			return err
+		}
+		for idx, ok := range found {
+			if !ok {
+				missing[values[idx]] = struct{}{}
+			}
+		}
+		if len(missing) >= consistencyMaxBloomMissing {
+			return errBloomWalkStopped
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errBloomWalkStopped) {
+		check.Error = err.Error()
+		return check
+	}
+
+	values := slices.Sorted(maps.Keys(missing))
+	if err == nil && len(values) <= consistencyRecheckLimit {
+		if values, err = notInBloom(ctx, redis, values); err != nil {
+			check.Error = err.Error()
+			return check
+		}
+	}
+	if err != nil {
+		check.Error = err.Error()
+	}
+
+	check.Count = uint64(len(values))
+	check.Samples = values[:min(len(values), sampleSize)]
+	return check
+}
+
+// notInBloom returns the values the Bloom filter does not hold, in order
+func notInBloom(ctx context.Context, redis db.Cache, values []string) ([]string, error) {
+	var missing []string
+	for batch := range slices.Chunk(values, bloomRebuildBatchSize) {
+		found, err := redis.BFMExists(ctx, batch)
+		if err != nil {
+			return nil, err
+		}
+		for idx, ok := range found {
+			if !ok {
+				missing = append(missing, batch[idx])
+			}
+		}
+	}
+	return missing, nil
+}
+
+// checkObjects lists MinIO once, counting objects older than the cutoff no
+// registry entry references (snapshots aside), and registry keys with no
+// object in either bucket
+func checkObjects(ctx context.Context, ch db.IOCStore, minio db.ObjectStore, cutoff time.Time, sampleSize int) (orphans, missing models.ConsistencyCheck) {
+	orphans = models.ConsistencyCheck{Name: models.ConsistencyOrphanObjects}
+	missing = models.ConsistencyCheck{Name: models.ConsistencyMissingObjects}
+	fail := func(err error) (models.ConsistencyCheck, models.ConsistencyCheck) {
+		orphans.Error, missing.Error = err.Error(), err.Error()
+		orphans.Count, orphans.Samples = 0, nil
+		missing.Count, missing.Samples = 0, nil
+		return orphans, missing
+	}
+
+	referenced, err := ch.GetReferencedMinIOKeys(ctx)
+	if err != nil {
+		return fail(err)
+	}
+
+	listed := make(map[string]bool)
+	for obj := range minio.ListObjects(ctx, "") {
+		if obj.Err != nil {
+			return fail(obj.Err)
+		}
+		listed[obj.Key] = true
+		if _, ok := referenced[obj.Key]; ok {
+			continue
+		}
+		if strings.HasPrefix(obj.Key, db.ExportPrefix) || strings.HasPrefix(obj.Key, db.BloomSnapshotPrefix) {
+			continue
+		}
+		if obj.LastModified.After(cutoff) {
+			continue
+		}
+		addSample(&orphans, obj.Key, sampleSize)
+	}
+
+	// Quarantined samples may live in their own bucket, which the listing
+	// does not cover
+	keys := make([]string, 0, len(referenced))
+	for key := range referenced {
+		if !listed[key] {
+			keys = append(keys, key)
+		}
+	}
+	slices.Sort(keys)
+	for _, key := range keys {
+		exists, err := minio.ObjectExists(ctx, key)
+		if err != nil {
+			return fail(err)
+		}
+		if !exists {
+			addSample(&missing, key, sampleSize)
+		}
+	}
+
+	return orphans, missing
+}
+
+// checkIDs runs a registry check that counts and samples IDs
+func checkIDs(name string, sampleSize int, list func(limit int) ([]string, uint64, error)) models.ConsistencyCheck {
+	check := models.ConsistencyCheck{Name: name}
+	ids, total, err := list(sampleSize)
+	if err != nil {
+		check.Error = err.Error()
+		return check
+	}
+	check.Count, check.Samples = total, ids
+	return check
+}
+
+// addSample counts an entry and keeps it while there is room for samples
+func addSample(check *models.ConsistencyCheck, entry string, sampleSize int) {
+	check.Count++
+	if len(check.Samples) < sampleSize {
+		check.Samples = append(check.Samples, entry)
+	}
+}
@@ -0,0 +1,163 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"tip-server/internal/config"
+	"tip-server/internal/db"
+	"tip-server/internal/models"
+	"tip-server/internal/netutil"
+)
+
+// dnsSourcePrefix marks IP IOCs recorded from resolving a domain IOC
+const dnsSourcePrefix = "dns:"
+
+// NewDNSResolution returns a job that resolves domain IOCs not resolved
+// within cfg.MaxAge. Public A/AAAA answers are stored as related IP IOCs
+// (source "dns:<domain>", half the domain's confidence); every domain's
+// status (resolved, nxdomain, sinkholed, error) is recorded.
+func NewDNSResolution(ch db.IOCStore, redis db.Cache, cfg config.DNSConfig) JobFunc {
+	resolver := netutil.NewResolver(cfg.Server)
+	sinkholes := netutil.ParseNetworks(cfg.Sinkholes)
+
+	return func(ctx context.Context) error {
+		domains, err := ch.ListDomainsDueForResolution(ctx, time.Now().Add(-cfg.MaxAge), cfg.BatchSize)
+		if err != nil {
+			return err
+		}
+		if len(domains) == 0 {
+			return nil
+		}
+
+		var (
+			mu          sync.Mutex
+			wg          sync.WaitGroup
+			resolutions = make([]models.DomainResolution, 0, len(domains))
+			related     []models.IOC
+			counts      = make(map[string]int)
+		)
+
+		sem := make(chan struct{}, cfg.Concurrency)
+		for _, domain := range domains {
+			if ctx.Err() != nil {
+				break
+			}
+
+			sem <- struct{}{}
+			wg.Add(1)
+			go func(domain models.IOC) {
+				defer func() { <-sem; wg.Done() }()
+
+				lookupCtx, cancel := context.WithTimeout(ctx, cfg.Timeout)
+				defer cancel()
+
+				res, ips := resolveDomain(lookupCtx, resolver, domain.Value, sinkholes)
+
+				mu.Lock()
+				defer mu.Unlock()
+				resolutions = append(resolutions, res)
+				related = append(related, relatedIPs(domain, ips, res.ResolvedAt)...)
+				counts[res.Status]++
+			}(domain)
+		}
+		wg.Wait()
+
+		if len(related) > 0 {
+			values := make([]string, len(related))
+			for idx, ioc := range related {
+				values[idx] = ioc.Value
+			}
+			if err := redis.BFMAdd(ctx, values); err != nil {
+				log.Warn().Err(err).Msg("Failed to add resolved IPs to Bloom filter")
+			}
+			if err := ch.BatchInsertIOCs(ctx, related); err != nil {
+				return err
+			}
+		}
+
+		if err := ch.InsertDomainResolutions(ctx, resolutions); err != nil {
+			return err
+		}
+
+		log.Info().
+			Int("domains", len(resolutions)).
+			Int("resolved", counts[models.DNSStatusResolved]).
+			Int("nxdomain", counts[models.DNSStatusNXDomain]).
+			Int("sinkholed", counts[models.DNSStatusSinkholed]).
+			Int("errors", counts[models.DNSStatusError]).
+			Int("related_ips", len(related)).
+			Msg("Domain resolution complete")
+
+		return nil
+	}
+}
+
+// resolveDomain looks up A/AAAA records and classifies the domain. The
+// returned IPs are the public addresses worth recording as related IOCs.
+func resolveDomain(ctx context.Context, resolver *net.Resolver, domain string, sinkholes []*net.IPNet) (models.DomainResolution, []net.IP) {
+	res := models.DomainResolution{
+		Domain:     domain,
+		ResolvedAt: time.Now().UTC(),
+	}
+
+	addrs, err := resolver.LookupIPAddr(ctx, domain)
+	if err != nil {
+		var dnsErr *net.DNSError
+		if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+			res.Status = models.DNSStatusNXDomain
+		} else {
+			res.Status = models.DNSStatusError
+		}
+		return res, nil
+	}
+
+	res.Status = models.DNSStatusResolved
+	var public []net.IP
+	for _, a := range addrs {
+		res.Addresses = append(res.Addresses, a.IP.String())
+
+		if netutil.InNetworks(a.IP, sinkholes) {
+			res.Status = models.DNSStatusSinkholed
+			continue
+		}
+		if !netutil.IsPublic(a.IP) {
+			continue
+		}
+		public = append(public, a.IP)
+	}
+
+	// A sinkholed domain's other answers are the sinkhole operator's, not the actor's
+	if res.Status == models.DNSStatusSinkholed {
+		return res, nil
+	}
+	return res, public
+}
+
+// relatedIPs builds IP IOCs for a domain's resolved addresses
+func relatedIPs(domain models.IOC, ips []net.IP, seen time.Time) []models.IOC {
+	iocs := make([]models.IOC, 0, len(ips))
+	for _, ip := range ips {
+		iocType := models.IOCTypeIPv6
+		if ip.To4() != nil {
+			iocType = models.IOCTypeIPv4
+		}
+
+		iocs = append(iocs, models.IOC{
+			Value:         ip.String(),
+			Type:          iocType,
+			SourceFileID:  dnsSourcePrefix + domain.Value,
+			MalwareFamily: domain.MalwareFamily,
+			Confidence:    max(domain.Confidence/2, 1),
+			FirstSeen:     seen,
+			LastSeen:      seen,
+			Tags:          []string{"resolved-from:" + domain.Value},
+		})
+	}
+	return iocs
+}
@@ -0,0 +1,142 @@
+package jobs
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"tip-server/internal/db"
+	"tip-server/internal/signing"
+)
+
+// ErrExportRunning is returned when a snapshot is requested while another
+// one is still being written
+var ErrExportRunning = errors.New("an export is already running")
+
+// ParquetExport writes Parquet snapshots of the active IOC store to MinIO
+// under db.ExportPrefix, for offline analytics and as a portable backup.
+// With a signer each snapshot is stored with a detached signature.
+type ParquetExport struct {
+	ch        db.IOCStore
+	minio     db.ObjectStore
+	retention int
+	signer    *signing.Signer // nil unless FEED_SIGNING_KEY_FILE is set
+
+	running atomic.Bool
+}
+
+// NewParquetExport creates an exporter keeping the newest retention
+// snapshots (0 = keep all)
+func NewParquetExport(ch db.IOCStore, minio db.ObjectStore, retention int, signer *signing.Signer) *ParquetExport {
+	return &ParquetExport{ch: ch, minio: minio, retention: retention, signer: signer}
+}
+
+// Running reports whether a snapshot is being written
+func (e *ParquetExport) Running() bool {
+	return e.running.Load()
+}
+
+// Job adapts Run to the scheduler
+func (e *ParquetExport) Job() JobFunc {
+	return func(ctx context.Context) error {
+		_, err := e.Run(ctx)
+		return err
+	}
+}
+
+// Run writes one snapshot, prunes snapshots beyond the retention count and
+// returns the new object key
+func (e *ParquetExport) Run(ctx context.Context) (string, error) {
+	if !e.running.CompareAndSwap(false, true) {
+		return "", ErrExportRunning
+	}
+	defer e.running.Store(false)
+
+	start := time.Now()
+	key := db.ExportPrefix + start.UTC().Format("20060102T150405Z") + ".parquet"
+
+	body, err := e.ch.ExportIOCsParquet(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer body.Close()
+
+	// Snapshots are signed by digest, computed while they upload
+	var digest *signing.Digest
+	var content io.Reader = body
+	if e.signer.Enabled() {
+		digest = signing.NewDigest()
+		content = io.TeeReader(body, digest)
+	}
+
+	info, err := e.minio.UploadStream(ctx, key, content, "application/vnd.apache.parquet")
+	if err != nil {
+		return "", err
+	}
+	if digest != nil {
+		suffix, sig := e.signer.SignObject(key, digest)
+		if _, err := e.minio.UploadStream(ctx, key+suffix, bytes.NewReader(sig), "text/plain"); err != nil {
+			return "", fmt.Errorf("failed to store snapshot signature: %w", err)
+		}
+	}
+
+	log.Info().
+		Str("object", key).
+		Int64("bytes", info.Size).
+		Dur("duration", time.Since(start)).
+		Msg("Exported IOC snapshot")
+
+	if err := e.prune(ctx); err != nil {
+		log.Warn().Err(err).Msg("Failed to prune old IOC snapshots")
+	}
+	return key, nil
+}
+
+// prune deletes all but the newest retention snapshots. Keys embed their
+// UTC timestamp, so lexical order is chronological.
+func (e *ParquetExport) prune(ctx context.Context) error {
+	if e.retention <= 0 {
+		return nil
+	}
+
+	var keys, signatures []string
+	for obj := range e.minio.ListObjects(ctx, db.ExportPrefix) {
+		if obj.Err != nil {
+			return obj.Err
+		}
+		switch {
+		case strings.HasSuffix(obj.Key, ".parquet"):
+			keys = append(keys, obj.Key)
+		case strings.HasSuffix(obj.Key, signing.SuffixJWS), strings.HasSuffix(obj.Key, signing.SuffixMinisign):
+			signatures = append(signatures, obj.Key)
+		}
+	}
+	if len(keys) <= e.retention {
+		return nil
+	}
+
+	slices.Sort(keys)
+	for _, key := range keys[:len(keys)-e.retention] {
+		if err := e.minio.DeleteObject(ctx, key); err != nil {
+			return err
+		}
+		// Signatures go with their snapshot
+		for _, sig := range signatures {
+			if strings.HasPrefix(sig, key+".") {
+				if err := e.minio.DeleteObject(ctx, sig); err != nil {
+					return err
+				}
+			}
+		}
+		log.Debug().Str("object", key).Msg("Pruned IOC snapshot")
+	}
+	return nil
+}
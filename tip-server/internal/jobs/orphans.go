@@ -0,0 +1,63 @@
+package jobs
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"tip-server/internal/db"
+)
+
+// NewOrphanCleanup returns a job that removes MinIO objects no longer referenced
+// by any file_registry entry. Objects younger than gracePeriod are kept so an
+// upload whose registry row has not been written yet is never removed.
+func NewOrphanCleanup(ch db.IOCStore, minio db.ObjectStore, gracePeriod time.Duration) JobFunc {
+	return func(ctx context.Context) error {
+		referenced, err := ch.GetReferencedMinIOKeys(ctx)
+		if err != nil {
+			return err
+		}
+
+		// An empty registry almost always means a failed or partial read;
+		// never treat that as "everything is orphaned".
+		if len(referenced) == 0 {
+			log.Warn().Msg("No referenced MinIO keys found, skipping orphan cleanup")
+			return nil
+		}
+
+		cutoff := time.Now().Add(-gracePeriod)
+		removed := 0
+
+		for obj := range minio.ListObjects(ctx, "") {
+			if obj.Err != nil {
+				return obj.Err
+			}
+			if _, ok := referenced[obj.Key]; ok {
+				continue
+			}
+			// Snapshots are not registry content; ParquetExport prunes them
+			// and each Bloom filter snapshot is replaced in place
+			if strings.HasPrefix(obj.Key, db.ExportPrefix) || strings.HasPrefix(obj.Key, db.BloomSnapshotPrefix) {
+				continue
+			}
+			if obj.LastModified.After(cutoff) {
+				continue
+			}
+
+			if err := minio.DeleteObject(ctx, obj.Key); err != nil {
+				log.Warn().Err(err).Str("object", obj.Key).Msg("Failed to remove orphaned object")
+				continue
+			}
+			removed++
+		}
+
+		log.Info().
+			Int("referenced", len(referenced)).
+			Int("removed", removed).
+			Msg("Orphaned object cleanup complete")
+
+		return nil
+	}
+}
@@ -0,0 +1,90 @@
+package jobs
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// JobFunc is a unit of periodic background work
+type JobFunc func(ctx context.Context) error
+
+// Scheduler runs registered jobs at fixed intervals
+type Scheduler struct {
+	jobs []job
+	wg   sync.WaitGroup
+}
+
+type job struct {
+	name     string
+	interval time.Duration
+	fn       JobFunc
+}
+
+// NewScheduler creates an empty scheduler
+func NewScheduler() *Scheduler {
+	return &Scheduler{}
+}
+
+// Register adds a job that runs every interval. Jobs with a non-positive
+// interval are treated as disabled and ignored.
+func (s *Scheduler) Register(name string, interval time.Duration, fn JobFunc) {
+	if interval <= 0 {
+		log.Debug().Str("job", name).Msg("Job disabled (no interval configured)")
+		return
+	}
+	s.jobs = append(s.jobs, job{name: name, interval: interval, fn: fn})
+}
+
+// Start launches all registered jobs. Each job runs until ctx is cancelled.
+func (s *Scheduler) Start(ctx context.Context) {
+	for _, j := range s.jobs {
+		s.wg.Add(1)
+		go s.loop(ctx, j)
+	}
+}
+
+// Go runs a long-lived job (such as a queue consumer) once in the
+// background. The job must return when ctx is cancelled.
+func (s *Scheduler) Go(ctx context.Context, name string, fn JobFunc) {
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+
+		log.Info().Str("job", name).Msg("Started background worker")
+		if err := fn(ctx); err != nil {
+			log.Error().Err(err).Str("job", name).Msg("Background worker failed")
+		}
+	}()
+}
+
+// Wait blocks until all job loops have exited
+func (s *Scheduler) Wait() {
+	s.wg.Wait()
+}
+
+// loop runs a single job on its ticker
+func (s *Scheduler) loop(ctx context.Context, j job) {
+	defer s.wg.Done()
+
+	log.Info().Str("job", j.name).Dur("interval", j.interval).Msg("Scheduled background job")
+
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			start := time.Now()
+			if err := j.fn(ctx); err != nil {
+				log.Error().Err(err).Str("job", j.name).Msg("Background job failed")
+				continue
+			}
+			log.Debug().Str("job", j.name).Dur("duration", time.Since(start)).Msg("Background job completed")
+		}
+	}
+}
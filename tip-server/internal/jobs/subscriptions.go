@@ -0,0 +1,371 @@
+package jobs
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"tip-server/internal/config"
+	"tip-server/internal/db"
+	"tip-server/internal/events"
+	"tip-server/internal/feeds"
+	"tip-server/internal/models"
+	"tip-server/internal/netutil"
+	"tip-server/internal/signing"
+)
+
+const (
+	// subscriptionRefresh is how often registered subscriptions are reloaded
+	subscriptionRefresh = 10 * time.Second
+
+	// subscriptionStatusTTL lets the statistics of a subscription deleted
+	// mid-delivery expire
+	subscriptionStatusTTL = 30 * 24 * time.Hour
+
+	// taxiiMediaType is the TAXII 2.1 content type
+	taxiiMediaType = "application/taxii+json;version=2.1"
+)
+
+// errPrivateAddress is returned when a delivery URL resolves to a private,
+// loopback or otherwise non-public address
+var errPrivateAddress = errors.New("delivery URL resolves to a non-public address")
+
+// CheckDeliveryURL validates a webhook or TAXII collection URL. Unless
+// allowPrivate is set, hosts that are non-public IP addresses are refused
+// here, and names resolving to one are refused when delivering.
+func CheckDeliveryURL(raw string, allowPrivate bool) error {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errors.New("url must be an http(s) URL")
+	}
+	if u.User != nil {
+		return errors.New("url must not carry credentials; use authorization")
+	}
+	if allowPrivate {
+		return nil
+	}
+	host := u.Hostname()
+	if strings.EqualFold(host, "localhost") {
+		return errPrivateAddress
+	}
+	if ip := net.ParseIP(host); ip != nil && !netutil.IsPublic(ip) {
+		return errPrivateAddress
+	}
+	return nil
+}
+
+// NewSubscriptionDelivery returns a worker fanning newly ingested IOCs out to
+// consumer subscriptions. It follows the match events ingestion publishes,
+// batches the IOCs each subscription's filter passes for FlushInterval (or
+// until BatchSize) and delivers each batch by webhook, to a Kafka topic or
+// to a TAXII 2.1 collection. Delivery is at most once: a failed batch is
+// counted in the subscription's status and dropped. Run it in one process
+// per deployment.
+func NewSubscriptionDelivery(redis db.Cache, bus events.Publisher, cfg config.SubscriptionConfig, signer *signing.Signer) JobFunc {
+	return func(ctx context.Context) error {
+		sub := redis.Subscribe(ctx, db.MatchEventsChannel)
+		defer sub.Close()
+
+		d := &subscriptionDispatcher{
+			redis:   redis,
+			bus:     bus,
+			cfg:     cfg,
+			signer:  signer,
+			client:  newDeliveryClient(cfg),
+			pending: make(map[string][]models.MatchEvent),
+			status:  make(map[string]*models.SubscriptionStatus),
+		}
+		d.reload(ctx)
+
+		flush := time.NewTicker(cfg.FlushInterval)
+		defer flush.Stop()
+		refresh := time.NewTicker(subscriptionRefresh)
+		defer refresh.Stop()
+
+		payloads := sub.Payloads()
+		for {
+			select {
+			case <-ctx.Done():
+				// Deliver what was already batched before exiting
+				shutdown, cancel := context.WithTimeout(context.Background(), cfg.Timeout)
+				d.flush(shutdown)
+				cancel()
+				return nil
+			case payload, ok := <-payloads:
+				if !ok {
+					return errors.New("match event subscription closed")
+				}
+				d.add(ctx, payload)
+			case <-flush.C:
+				d.flush(ctx)
+			case <-refresh.C:
+				d.reload(ctx)
+			}
+		}
+	}
+}
+
+// newDeliveryClient returns the HTTP client for webhook and TAXII delivery.
+// Unless private URLs are allowed, connections to non-public addresses are
+// refused after resolution, so DNS cannot point a delivery inside the
+// network.
+func newDeliveryClient(cfg config.SubscriptionConfig) *http.Client {
+	dialer := &net.Dialer{Timeout: cfg.Timeout}
+	if !cfg.AllowPrivate {
+		dialer.Control = func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !netutil.IsPublic(ip) {
+				return errPrivateAddress
+			}
+			return nil
+		}
+	}
+	return &http.Client{
+		Timeout:   cfg.Timeout,
+		Transport: &http.Transport{DialContext: dialer.DialContext, TLSHandshakeTimeout: cfg.Timeout},
+	}
+}
+
+// subscriptionDispatcher holds the batches of one delivery worker. Only the
+// worker's goroutine touches subs and pending; status is shared with the
+// deliveries send runs in parallel.
+type subscriptionDispatcher struct {
+	redis  db.Cache
+	bus    events.Publisher
+	cfg    config.SubscriptionConfig
+	signer *signing.Signer
+	client *http.Client
+
+	subs    []models.Subscription
+	pending map[string][]models.MatchEvent // By subscription ID
+	sending sync.WaitGroup                 // Deliveries in progress
+
+	mu     sync.Mutex
+	status map[string]*models.SubscriptionStatus
+}
+
+// reload picks up registered and deleted subscriptions, dropping the
+// batches of deleted ones
+func (d *subscriptionDispatcher) reload(ctx context.Context) {
+	subs, err := d.redis.ListSubscriptions(ctx)
+	if err != nil {
+		log.Warn().Err(err).Msg("Failed to load subscriptions")
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	active := make(map[string]bool, len(subs))
+	for _, sub := range subs {
+		active[sub.ID] = true
+		if _, ok := d.status[sub.ID]; !ok {
+			// Statistics survive restarts of the worker
+			status := &models.SubscriptionStatus{}
+			if err := d.redis.GetJSON(ctx, db.SubscriptionStatusKey(sub.ID), status); err != nil {
+				status = &models.SubscriptionStatus{}
+			}
+			d.status[sub.ID] = status
+		}
+	}
+	for id := range d.status {
+		if !active[id] {
+			delete(d.status, id)
+			delete(d.pending, id)
+		}
+	}
+	d.subs = subs
+}
+
+// add queues an ingested IOC for every subscription whose filter it passes,
+// sending batches that reach BatchSize without waiting for the flush
+func (d *subscriptionDispatcher) add(ctx context.Context, payload string) {
+	var event models.MatchEvent
+	if err := json.Unmarshal([]byte(payload), &event); err != nil || event.Kind != models.MatchKindIngested {
+		return
+	}
+	for _, sub := range d.subs {
+		if !sub.Filter.Matches(event) {
+			continue
+		}
+		d.pending[sub.ID] = append(d.pending[sub.ID], event)
+		if len(d.pending[sub.ID]) >= d.cfg.BatchSize {
+			d.send(ctx, sub, d.pending[sub.ID])
+			delete(d.pending, sub.ID)
+		}
+	}
+}
+
+// flush delivers every pending batch, in parallel, and waits for them and
+// for the full batches add sent
+func (d *subscriptionDispatcher) flush(ctx context.Context) {
+	for _, sub := range d.subs {
+		batch := d.pending[sub.ID]
+		if len(batch) == 0 {
+			continue
+		}
+		delete(d.pending, sub.ID)
+		d.send(ctx, sub, batch)
+	}
+	d.sending.Wait()
+}
+
+// send delivers a batch on its own goroutine, so a slow consumer does not
+// hold up the worker's receive loop
+func (d *subscriptionDispatcher) send(ctx context.Context, sub models.Subscription, batch []models.MatchEvent) {
+	d.sending.Add(1)
+	go func() {
+		defer d.sending.Done()
+		d.deliver(ctx, sub, batch)
+	}()
+}
+
+// deliver sends one batch and records the outcome in the subscription's
+// status
+func (d *subscriptionDispatcher) deliver(ctx context.Context, sub models.Subscription, batch []models.MatchEvent) {
+	ctx, cancel := context.WithTimeout(ctx, d.cfg.Timeout)
+	defer cancel()
+
+	var err error
+	switch sub.Delivery.Method {
+	case models.DeliveryWebhook:
+		err = d.deliverWebhook(ctx, sub, batch)
+	case models.DeliveryKafka:
+		err = d.deliverKafka(ctx, sub, batch)
+	case models.DeliveryTAXII:
+		err = d.deliverTAXII(ctx, sub, batch)
+	default:
+		err = fmt.Errorf("unknown delivery method %q", sub.Delivery.Method)
+	}
+
+	now := time.Now().UTC()
+	d.mu.Lock()
+	status, ok := d.status[sub.ID]
+	if !ok {
+		d.mu.Unlock()
+		return // Deleted meanwhile
+	}
+	if err != nil {
+		status.Failed += uint64(len(batch))
+		status.LastError = err.Error()
+		status.LastErrorAt = &now
+	} else {
+		status.Delivered += uint64(len(batch))
+		status.LastDelivery = &now
+	}
+	snapshot := *status
+	d.mu.Unlock()
+
+	if err != nil {
+		log.Warn().Err(err).Str("subscription", sub.ID).Int("iocs", len(batch)).Msg("Subscription delivery failed")
+	}
+	if err := d.redis.SetJSON(ctx, db.SubscriptionStatusKey(sub.ID), snapshot, subscriptionStatusTTL); err != nil {
+		log.Debug().Err(err).Str("subscription", sub.ID).Msg("Failed to store subscription status")
+	}
+}
+
+// webhookBatch is the body of a webhook delivery
+type webhookBatch struct {
+	Subscription string              `json:"subscription"`
+	IOCs         []models.MatchEvent `json:"iocs"`
+	Timestamp    time.Time           `json:"timestamp"`
+}
+
+// deliverWebhook POSTs the batch as JSON. X-TIP-Signature carries the
+// HMAC-SHA256 of the body under the subscription's secret, and X-Signature
+// the feed signature when feeds are signed.
+func (d *subscriptionDispatcher) deliverWebhook(ctx context.Context, sub models.Subscription, batch []models.MatchEvent) error {
+	body, err := json.Marshal(webhookBatch{Subscription: sub.ID, IOCs: batch, Timestamp: time.Now().UTC()})
+	if err != nil {
+		return err
+	}
+
+	mac := hmac.New(sha256.New, []byte(sub.Delivery.Secret))
+	mac.Write(body)
+	headers := map[string]string{
+		"Content-Type":       "application/json",
+		"X-TIP-Subscription": sub.ID,
+		"X-TIP-Signature":    "sha256=" + hex.EncodeToString(mac.Sum(nil)),
+	}
+	if d.signer.Enabled() {
+		headers[signing.Header] = d.signer.Sign(body)
+	}
+	return d.post(ctx, sub, sub.Delivery.URL, body, headers)
+}
+
+// deliverKafka publishes the batch to the subscription's topic as match
+// events, keyed by IOC value
+func (d *subscriptionDispatcher) deliverKafka(ctx context.Context, sub models.Subscription, batch []models.MatchEvent) error {
+	topics, ok := d.bus.(events.TopicPublisher)
+	if !ok {
+		return errors.New("the event bus is not Kafka")
+	}
+	return topics.PublishTopic(ctx, sub.Delivery.Topic, events.MatchMessages(batch)...)
+}
+
+// deliverTAXII adds the batch to a TAXII 2.1 collection as STIX indicators
+func (d *subscriptionDispatcher) deliverTAXII(ctx context.Context, sub models.Subscription, batch []models.MatchEvent) error {
+	objects := make([]feeds.STIX2Indicator, 0, len(batch))
+	for _, event := range batch {
+		if ind, ok := feeds.NewSTIX2Indicator(event); ok {
+			objects = append(objects, ind)
+		}
+	}
+	if len(objects) == 0 {
+		return nil
+	}
+
+	body, err := json.Marshal(map[string]any{"objects": objects})
+	if err != nil {
+		return err
+	}
+	headers := map[string]string{"Content-Type": taxiiMediaType, "Accept": taxiiMediaType}
+	if d.signer.Enabled() {
+		headers[signing.Header] = d.signer.Sign(body)
+	}
+	return d.post(ctx, sub, strings.TrimSuffix(sub.Delivery.URL, "/")+"/objects/", body, headers)
+}
+
+// post sends a delivery request, expecting a 2xx answer
+func (d *subscriptionDispatcher) post(ctx context.Context, sub models.Subscription, target string, body []byte, headers map[string]string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	if sub.Delivery.Authorization != "" {
+		req.Header.Set("Authorization", sub.Delivery.Authorization)
+	}
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 256))
+		return fmt.Errorf("%s answered %s: %s", sub.Delivery.Method, resp.Status, msg)
+	}
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+	return nil
+}
@@ -0,0 +1,74 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"tip-server/internal/config"
+	"tip-server/internal/memstore"
+	"tip-server/internal/models"
+)
+
+func TestSubscriptionFullBatchDoesNotBlock(t *testing.T) {
+	// The webhook holds every delivery until released
+	release := make(chan struct{})
+	received := make(chan string, 4)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- r.Header.Get("X-TIP-Subscription")
+		<-release
+	}))
+	defer srv.Close()
+
+	cfg := config.SubscriptionConfig{BatchSize: 1, Timeout: 5 * time.Second, AllowPrivate: true}
+	d := &subscriptionDispatcher{
+		redis:   memstore.NewCache(),
+		cfg:     cfg,
+		client:  newDeliveryClient(cfg),
+		pending: make(map[string][]models.MatchEvent),
+		status:  make(map[string]*models.SubscriptionStatus),
+	}
+	for _, id := range []string{"a", "b"} {
+		d.subs = append(d.subs, models.Subscription{
+			ID:       id,
+			Delivery: models.SubscriptionDelivery{Method: models.DeliveryWebhook, URL: srv.URL},
+		})
+		d.status[id] = &models.SubscriptionStatus{}
+	}
+
+	payload, _ := json.Marshal(models.MatchEvent{Kind: models.MatchKindIngested, Value: "evil.example", Type: models.IOCTypeDomain})
+	added := make(chan struct{})
+	go func() {
+		d.add(context.Background(), string(payload))
+		close(added)
+	}()
+
+	// Both full batches are in flight at once, and add has returned
+	for range 2 {
+		select {
+		case <-received:
+		case <-time.After(5 * time.Second):
+			t.Fatal("full batches were not delivered in parallel")
+		}
+	}
+	select {
+	case <-added:
+	case <-time.After(5 * time.Second):
+		t.Fatal("add waited for the delivery")
+	}
+	if len(d.pending) != 0 {
+		t.Errorf("pending = %v, want the full batches handed off", d.pending)
+	}
+
+	// flush waits for the deliveries add started
+	close(release)
+	d.flush(context.Background())
+	for _, id := range []string{"a", "b"} {
+		if got := d.status[id].Delivered; got != 1 {
+			t.Errorf("subscription %s delivered = %d, want 1", id, got)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package jobs
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"tip-server/internal/config"
+	"tip-server/internal/db"
+	"tip-server/internal/metrics"
+	"tip-server/internal/models"
+	"tip-server/internal/redact"
+	"tip-server/internal/signing"
+)
+
+// NewReplicaSync returns a job that pulls IOCs ingested on a primary
+// deployment since the stored cursor (GET /sync/iocs) and applies them
+// locally: rows go into ClickHouse and values into the Bloom filter. The
+// cursor only advances after a page is applied, and rows are keyed by
+// (type, value, source file), so a retried page is harmless. Email addresses
+// a primary stored verbatim are redacted when redactor is set. With
+// verifyKey, pages not signed by the primary's key are rejected.
+func NewReplicaSync(ch db.IOCStore, redis db.Cache, cfg config.SyncConfig, redactor *redact.Redactor, verifyKey ed25519.PublicKey) JobFunc {
+	client := &http.Client{Timeout: cfg.Timeout}
+	m := metrics.GetMetrics()
+
+	return func(ctx context.Context) error {
+		cursor, err := redis.GetSyncCursor(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to read sync cursor: %w", err)
+		}
+
+		applied := 0
+		for {
+			page, err := fetchSyncPage(ctx, client, cfg, verifyKey, cursor)
+			if err != nil {
+				return err
+			}
+
+			if len(page.IOCs) > 0 {
+				if err := applySyncPage(ctx, ch, redis, redactor, page.IOCs); err != nil {
+					return err
+				}
+				applied += len(page.IOCs)
+				m.ReplicaSyncIOCs.Add(float64(len(page.IOCs)))
+				m.ReplicaSyncLag.Set(time.Since(page.IOCs[len(page.IOCs)-1].IngestedAt).Seconds())
+			}
+
+			if page.Cursor != "" && page.Cursor != cursor {
+				if err := redis.SetSyncCursor(ctx, page.Cursor); err != nil {
+					return fmt.Errorf("failed to store sync cursor: %w", err)
+				}
+				cursor = page.Cursor
+			}
+
+			if !page.More || ctx.Err() != nil {
+				break
+			}
+		}
+
+		if applied > 0 {
+			log.Info().
+				Int("iocs", applied).
+				Str("primary", cfg.PrimaryURL).
+				Msg("Replica sync applied IOCs from primary")
+		}
+		return nil
+	}
+}
+
+// fetchSyncPage requests the page after cursor from the primary
+func fetchSyncPage(ctx context.Context, client *http.Client, cfg config.SyncConfig, verifyKey ed25519.PublicKey, cursor string) (*models.SyncResponse, error) {
+	params := url.Values{}
+	params.Set("limit", strconv.Itoa(cfg.BatchSize))
+	if cursor != "" {
+		params.Set("cursor", cursor)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, cfg.PrimaryURL+"/sync/iocs?"+params.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-API-Key", cfg.APIKey)
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach primary: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("primary returned %s: %s", resp.Status, msg)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read sync page: %w", err)
+	}
+	if verifyKey != nil {
+		if err := signing.Verify(verifyKey, body, resp.Header.Get(signing.Header)); err != nil {
+			return nil, fmt.Errorf("sync page failed verification: %w", err)
+		}
+	}
+
+	var page models.SyncResponse
+	if err := json.Unmarshal(body, &page); err != nil {
+		return nil, fmt.Errorf("failed to decode sync page: %w", err)
+	}
+	return &page, nil
+}
+
+// applySyncPage stores replicated rows and makes them visible to /check
+func applySyncPage(ctx context.Context, ch db.IOCStore, redis db.Cache, redactor *redact.Redactor, rows []models.SyncIOC) error {
+	iocs := make([]models.IOC, len(rows))
+	for i, row := range rows {
+		iocs[i] = row.IOC
+	}
+	redactor.IOCs(iocs)
+
+	values := make([]string, len(iocs))
+	for i, ioc := range iocs {
+		values[i] = ioc.Value
+	}
+
+	if err := ch.BatchInsertIOCs(ctx, iocs); err != nil {
+		return fmt.Errorf("failed to store synced IOCs: %w", err)
+	}
+	if err := redis.BFMAdd(ctx, values); err != nil {
+		return fmt.Errorf("failed to add synced IOCs to Bloom filter: %w", err)
+	}
+	if err := redis.InvalidateCachedIOCs(ctx, values...); err != nil {
+		log.Warn().Err(err).Msg("Failed to invalidate lookup cache for synced IOCs")
+	}
+	return nil
+}
@@ -0,0 +1,243 @@
+package jobs
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"tip-server/internal/config"
+	"tip-server/internal/db"
+	"tip-server/internal/metrics"
+	"tip-server/internal/models"
+)
+
+const (
+	// volumeWebhookTimeout bounds an alert delivery
+	volumeWebhookTimeout = 10 * time.Second
+
+	// volumeAlertListed is how many anomalies an alert's text names
+	volumeAlertListed = 5
+)
+
+// NewVolumeCheck returns a job comparing the IOCs each feed ingested of each
+// type over the last cfg.Window (whole hours, up to the current one) with
+// the median of the cfg.BaselineWindows windows before it. A feed falling
+// to cfg.DropFactor of its baseline is reported as a drop, which is what a
+// broken feed looks like; one exceeding cfg.SpikeFactor times its baseline,
+// or times cfg.MinCount when that is larger, as a spike, which is what a
+// parser extracting false positives looks like. Baselines under
+// cfg.MinCount are too small to report drops on. Nothing is reported until
+// the counts cover the baseline.
+//
+// Anomalies are exported as tip_ingestion_volume_anomaly and stored for
+// GET /admin/volume. With cfg.WebhookURL set, anomalies are POSTed there
+// when they start and when they resolve, not on every check.
+func NewVolumeCheck(redis db.Cache, cfg config.VolumeConfig) JobFunc {
+	client := &http.Client{Timeout: volumeWebhookTimeout}
+
+	return func(ctx context.Context) error {
+		m := metrics.GetMetrics()
+		now := time.Now().UTC()
+		end := now.Truncate(time.Hour)
+		perWindow := int(cfg.Window / time.Hour)
+
+		// Newest hour first, so hour i falls in window i/perWindow
+		hours := make([]time.Time, perWindow*(cfg.BaselineWindows+1))
+		for i := range hours {
+			hours[i] = end.Add(-time.Duration(i+1) * time.Hour)
+		}
+		counts, since, err := redis.GetIngestionVolume(ctx, hours)
+		if err != nil {
+			return fmt.Errorf("failed to load ingestion volume: %w", err)
+		}
+
+		series := make(map[models.VolumeKey][]int64)
+		for i, hour := range counts {
+			for k, n := range hour {
+				if series[k] == nil {
+					series[k] = make([]int64, cfg.BaselineWindows+1)
+				}
+				series[k][i/perWindow] += n
+			}
+		}
+
+		report := models.VolumeReport{
+			GeneratedAt:   now,
+			WindowStart:   end.Add(-cfg.Window),
+			WindowEnd:     end,
+			BaselineStart: end.Add(-time.Duration(cfg.BaselineWindows+1) * cfg.Window),
+			Series:        len(series),
+			Anomalies:     []models.VolumeAnomaly{},
+		}
+		report.WarmingUp = since.IsZero() || since.After(report.BaselineStart)
+		if !report.WarmingUp {
+			for k, windows := range series {
+				if anomaly, ok := judgeVolume(k, windows, cfg); ok {
+					report.Anomalies = append(report.Anomalies, anomaly)
+				}
+			}
+		}
+		slices.SortFunc(report.Anomalies, compareVolumeAnomalies)
+
+		// A missing or unreadable previous report means every anomaly is new
+		var previous models.VolumeReport
+		_ = redis.GetJSON(ctx, db.VolumeReportKey, &previous)
+		started, resolved := diffVolumeAnomalies(previous.Anomalies, report.Anomalies, now)
+
+		for _, a := range resolved {
+			m.VolumeAnomaly.DeleteLabelValues(a.Feed, string(a.Type), a.Kind)
+			log.Info().Str("feed", a.Feed).Str("type", string(a.Type)).Str("kind", a.Kind).Msg("Ingestion volume back to baseline")
+		}
+		for _, a := range report.Anomalies {
+			m.VolumeAnomaly.WithLabelValues(a.Feed, string(a.Type), a.Kind).Set(1)
+		}
+		for _, a := range started {
+			log.Warn().
+				Str("feed", a.Feed).
+				Str("type", string(a.Type)).
+				Str("kind", a.Kind).
+				Int64("count", a.Count).
+				Float64("baseline", a.Baseline).
+				Msg("Ingestion volume anomaly")
+		}
+
+		if err := redis.SetJSON(ctx, db.VolumeReportKey, report, 0); err != nil {
+			return err
+		}
+		m.VolumeLastRun.SetToCurrentTime()
+
+		if cfg.WebhookURL == "" || (len(started) == 0 && len(resolved) == 0) {
+			return nil
+		}
+		if err := sendVolumeAlert(ctx, client, cfg.WebhookURL, started, resolved); err != nil {
+			return fmt.Errorf("failed to send ingestion volume alert: %w", err)
+		}
+		return nil
+	}
+}
+
+// judgeVolume checks the window under check (windows[0]) against the median
+// of the baseline windows
+func judgeVolume(k models.VolumeKey, windows []int64, cfg config.VolumeConfig) (models.VolumeAnomaly, bool) {
+	count := windows[0]
+	baseline := median(windows[1:])
+	anomaly := models.VolumeAnomaly{VolumeKey: k, Count: count, Baseline: baseline}
+
+	switch {
+	case baseline >= float64(cfg.MinCount) && float64(count) <= cfg.DropFactor*baseline:
+		anomaly.Kind = models.VolumeDrop
+	case float64(count) >= cfg.SpikeFactor*max(baseline, float64(cfg.MinCount)):
+		anomaly.Kind = models.VolumeSpike
+	default:
+		return anomaly, false
+	}
+	return anomaly, true
+}
+
+// median returns the median of counts
+func median(counts []int64) float64 {
+	sorted := slices.Sorted(slices.Values(counts))
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 1 {
+		return float64(sorted[mid])
+	}
+	return float64(sorted[mid-1]+sorted[mid]) / 2
+}
+
+// diffVolumeAnomalies carries the start time of ongoing anomalies over from
+// the previous check, and returns the anomalies that started and resolved
+// since
+func diffVolumeAnomalies(previous, current []models.VolumeAnomaly, now time.Time) (started, resolved []models.VolumeAnomaly) {
+	for i := range current {
+		a := &current[i]
+		idx := slices.IndexFunc(previous, func(p models.VolumeAnomaly) bool {
+			return p.VolumeKey == a.VolumeKey && p.Kind == a.Kind
+		})
+		if idx >= 0 {
+			a.Since = previous[idx].Since
+			continue
+		}
+		a.Since = now
+		started = append(started, *a)
+	}
+	for _, p := range previous {
+		if !slices.ContainsFunc(current, func(a models.VolumeAnomaly) bool {
+			return p.VolumeKey == a.VolumeKey && p.Kind == a.Kind
+		}) {
+			resolved = append(resolved, p)
+		}
+	}
+	return started, resolved
+}
+
+func compareVolumeAnomalies(a, b models.VolumeAnomaly) int {
+	return cmp.Or(
+		cmp.Compare(a.Feed, b.Feed),
+		cmp.Compare(a.Type, b.Type),
+		cmp.Compare(a.Kind, b.Kind),
+	)
+}
+
+// volumeAlert is the body of an ingestion volume alert. Text summarizes it
+// for chat webhooks (Slack, Mattermost) that display only that field.
+type volumeAlert struct {
+	Text      string                 `json:"text"`
+	Anomalies []models.VolumeAnomaly `json:"anomalies"` // Started with this check
+	Resolved  []models.VolumeAnomaly `json:"resolved"`  // Back to baseline
+}
+
+// sendVolumeAlert POSTs started and resolved anomalies to the alert webhook
+func sendVolumeAlert(ctx context.Context, client *http.Client, target string, started, resolved []models.VolumeAnomaly) error {
+	alert := volumeAlert{
+		Text:      volumeAlertText(started, resolved),
+		Anomalies: append([]models.VolumeAnomaly{}, started...),
+		Resolved:  append([]models.VolumeAnomaly{}, resolved...),
+	}
+	body, err := json.Marshal(alert)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 256))
+		return fmt.Errorf("webhook answered %s: %s", resp.Status, msg)
+	}
+	return nil
+}
+
+// volumeAlertText summarizes an alert in one line
+func volumeAlertText(started, resolved []models.VolumeAnomaly) string {
+	var parts []string
+	for i, a := range started {
+		if i == volumeAlertListed {
+			parts = append(parts, fmt.Sprintf("%d more", len(started)-i))
+			break
+		}
+		parts = append(parts, fmt.Sprintf("%s of %s from %s: %d vs baseline %g", a.Kind, a.Type, a.Feed, a.Count, a.Baseline))
+	}
+	if len(resolved) > 0 {
+		parts = append(parts, fmt.Sprintf("%d back to baseline", len(resolved)))
+	}
+	return "Ingestion volume: " + strings.Join(parts, "; ")
+}
@@ -0,0 +1,163 @@
+// Package language detects the language of ingested documents and
+// normalizes non-Latin text so indicators embedded in it can be extracted.
+package language
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	// sampleSize is the number of bytes of a document examined
+	sampleSize = 64 * 1024
+
+	// minLetters is the fewest letters needed for a verdict
+	minLetters = 20
+
+	// minStopwords is the fewest stopword hits needed to name a language
+	// written in Latin or Cyrillic script
+	minStopwords = 3
+)
+
+// scripts maps Unicode scripts that identify a language on their own
+var scripts = []struct {
+	table *unicode.RangeTable
+	code  string
+}{
+	{unicode.Hangul, "ko"},
+	{unicode.Arabic, "ar"},
+	{unicode.Hebrew, "he"},
+	{unicode.Greek, "el"},
+	{unicode.Thai, "th"},
+	{unicode.Devanagari, "hi"},
+	{unicode.Georgian, "ka"},
+	{unicode.Armenian, "hy"},
+}
+
+// stopwords are frequent function words used to tell apart languages that
+// share a script
+var stopwords = map[string][]string{
+	"en": {"the", "and", "of", "to", "in", "is", "that", "for", "with", "this", "was", "are", "be", "by", "from"},
+	"de": {"der", "die", "und", "das", "ist", "nicht", "mit", "von", "den", "ein", "eine", "auf", "sich", "auch", "wird"},
+	"fr": {"le", "la", "les", "et", "des", "est", "une", "dans", "que", "pour", "qui", "pas", "sur", "avec", "sont"},
+	"es": {"el", "los", "las", "del", "que", "por", "una", "con", "para", "es", "como", "pero", "sus", "fue", "este"},
+	"it": {"il", "di", "che", "della", "per", "una", "sono", "non", "gli", "nel", "alla", "anche", "questo", "delle", "è"},
+	"pt": {"os", "do", "da", "que", "não", "uma", "para", "com", "dos", "das", "por", "mais", "como", "foi", "são"},
+	"nl": {"de", "het", "een", "van", "en", "niet", "op", "dat", "voor", "zijn", "met", "ook", "maar", "wordt", "deze"},
+	"ru": {"и", "в", "не", "на", "что", "с", "по", "это", "как", "для", "из", "от", "или", "был", "также"},
+	"uk": {"і", "в", "не", "на", "що", "з", "та", "це", "як", "для", "від", "або", "був", "також", "які"},
+}
+
+// stopwordIndex maps each stopword to the languages using it
+var stopwordIndex = func() map[string][]string {
+	index := make(map[string][]string)
+	for code, words := range stopwords {
+		for _, w := range words {
+			index[w] = append(index[w], code)
+		}
+	}
+	return index
+}()
+
+// scriptLanguages lists the stopword languages written in each script
+var scriptLanguages = map[*unicode.RangeTable][]string{
+	unicode.Latin:    {"en", "de", "fr", "es", "it", "pt", "nl"},
+	unicode.Cyrillic: {"ru", "uk"},
+}
+
+// Detect returns the ISO 639-1 code of the document's dominant language, or
+// "" when the content is binary, too short or ambiguous
+func Detect(content []byte) string {
+	if len(content) > sampleSize {
+		content = content[:sampleSize]
+	}
+
+	counts := make(map[*unicode.RangeTable]int)
+	var letters, kana, invalid, runes int
+	for rest := content; len(rest) > 0; runes++ {
+		r, size := utf8.DecodeRune(rest)
+		rest = rest[size:]
+		if r == utf8.RuneError && size == 1 {
+			invalid++
+			continue
+		}
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		letters++
+		switch {
+		case unicode.Is(unicode.Latin, r):
+			counts[unicode.Latin]++
+		case unicode.Is(unicode.Cyrillic, r):
+			counts[unicode.Cyrillic]++
+		case unicode.Is(unicode.Han, r):
+			counts[unicode.Han]++
+		case unicode.Is(unicode.Hiragana, r), unicode.Is(unicode.Katakana, r):
+			kana++
+		default:
+			for _, s := range scripts {
+				if unicode.Is(s.table, r) {
+					counts[s.table]++
+					break
+				}
+			}
+		}
+	}
+	if letters < minLetters || invalid*10 > runes {
+		return ""
+	}
+
+	// Japanese mixes kanji with kana; Chinese has no kana
+	if kana > 0 && kana*10 >= letters {
+		return "ja"
+	}
+
+	var dominant *unicode.RangeTable
+	for table, n := range counts {
+		if dominant == nil || n > counts[dominant] {
+			dominant = table
+		}
+	}
+	if counts[dominant]*2 < letters {
+		return ""
+	}
+
+	if dominant == unicode.Han {
+		return "zh"
+	}
+	for _, s := range scripts {
+		if s.table == dominant {
+			return s.code
+		}
+	}
+
+	return byStopwords(string(content), scriptLanguages[dominant])
+}
+
+// byStopwords picks the candidate language whose stopwords occur most often
+func byStopwords(text string, candidates []string) string {
+	hits := make(map[string]int)
+	for _, word := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r)
+	}) {
+		for _, code := range stopwordIndex[word] {
+			hits[code]++
+		}
+	}
+
+	best, second := "", 0
+	for _, code := range candidates {
+		switch n := hits[code]; {
+		case best == "" || n > hits[best]:
+			second = hits[best]
+			best = code
+		case n > second:
+			second = n
+		}
+	}
+	if hits[best] < minStopwords || hits[best] == second {
+		return ""
+	}
+	return best
+}
@@ -0,0 +1,33 @@
+package language
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"english", "The loader was delivered by email and is used for the initial access to the network.", "en"},
+		{"german", "Der Angreifer hat die Daten nicht verschlüsselt, und das ist auch eine gute Nachricht.", "de"},
+		{"russian", "Вредоносная программа была обнаружена на сервере, и это также было в отчёте для клиента.", "ru"},
+		{"greek", "Το κακόβουλο λογισμικό εντοπίστηκε στον διακομιστή της εταιρείας χθες το βράδυ.", "el"},
+		{"arabic", "تم اكتشاف البرنامج الضار على الخادم الرئيسي للشركة أمس في المساء", "ar"},
+		{"japanese", "マルウェアはサーバー上で検出され、報告書に記載されました。攻撃者は不明です。", "ja"},
+		{"chinese", "恶意软件在公司的主服务器上被发现，攻击者的身份目前仍然不明确。", "zh"},
+		{"too short", "Hello there", ""},
+		{"latin without stopwords", strings.Repeat("xkcd qwerty zzyzx ", 5), ""},
+		{"binary", "\x00\xff\xfe\x89PNG\r\n\x1a\n" + strings.Repeat("\xff\xd8", 40) + "abcdefghijklmnopqrstuvwxyz", ""},
+		{"no dominant script", "абвгдежзий abcdefghij αβγδεζηθικ", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Detect([]byte(tt.content)); got != tt.want {
+				t.Errorf("Detect() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,157 @@
+package language
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/net/idna"
+	"golang.org/x/net/publicsuffix"
+	"golang.org/x/text/unicode/norm"
+)
+
+// ideographicStops are full stops CJK writers use in place of "." in
+// hostnames; NFKC leaves them untouched
+var ideographicStops = strings.NewReplacer("。", ".", "︒", ".")
+
+// confusables maps Cyrillic and Greek letters to the Latin letters they are
+// indistinguishable from
+var confusables = map[rune]rune{
+	// Cyrillic
+	'а': 'a', 'в': 'b', 'е': 'e', 'к': 'k', 'м': 'm', 'н': 'h', 'о': 'o', 'р': 'p',
+	'с': 'c', 'т': 't', 'у': 'y', 'х': 'x', 'і': 'i', 'ј': 'j', 'ѕ': 's', 'ԁ': 'd',
+	'ԛ': 'q', 'ԝ': 'w', 'ү': 'y', 'һ': 'h',
+	// Greek
+	'α': 'a', 'ε': 'e', 'ι': 'i', 'κ': 'k', 'ν': 'v', 'ο': 'o', 'ρ': 'p', 'τ': 't',
+	'υ': 'u', 'χ': 'x',
+}
+
+// idnCandidate matches dotted runs containing letters of any script
+var idnCandidate = regexp.MustCompile(`[\p{L}\p{N}-]+(?:\.[\p{L}\p{N}-]+)+`)
+
+// Normalize rewrites text so the ASCII-oriented extractor can find the
+// indicators inside it:
+//   - invisible characters padding indicators are removed (see StripInvisible)
+//   - NFKC folds fullwidth letters, digits and punctuation (ｈｔｔｐ：／／) to ASCII
+//   - ideographic full stops (。) become "."
+//   - internationalized domain names under a real TLD are converted to punycode
+//
+// Cyrillic/Greek lookalikes are left as written, so a homograph such as
+// аpple.com (Cyrillic а) keeps its own identity; FoldConfusables gives the
+// Latin form it imitates. ASCII-only text is returned unchanged.
+func Normalize(content []byte) []byte {
+	if isASCII(content) {
+		return content
+	}
+
+	text := norm.NFKC.String(StripInvisible(string(content)))
+	text = ideographicStops.Replace(text)
+	text = idnCandidate.ReplaceAllStringFunc(text, toPunycode)
+
+	return []byte(text)
+}
+
+// StripInvisible removes characters that render as nothing, which
+// adversaries insert into indicators to defeat exact matching: zero-width
+// spaces and joiners, bidirectional overrides such as RLO, byte order marks,
+// soft hyphens (all Unicode format characters) and variation selectors.
+// ASCII-only text is returned unchanged.
+func StripInvisible(text string) string {
+	if isASCII([]byte(text)) {
+		return text
+	}
+	return strings.Map(func(r rune) rune {
+		if unicode.In(r, unicode.Cf, unicode.Variation_Selector) {
+			return -1
+		}
+		return r
+	}, text)
+}
+
+// FoldConfusables replaces lookalike letters in words that mix Latin with
+// Cyrillic or Greek, when every non-Latin letter in the word has a Latin
+// twin. Words written wholly in Cyrillic or Greek are left alone. The result
+// is only a secondary key for spotting homographs; values are stored and
+// looked up as written.
+func FoldConfusables(text string) string {
+	if isASCII([]byte(text)) {
+		return text
+	}
+
+	var b strings.Builder
+	b.Grow(len(text))
+
+	start := -1
+	flush := func(end int) {
+		if start >= 0 {
+			b.WriteString(transliterateWord(text[start:end]))
+			start = -1
+		}
+	}
+	for i, r := range text {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		flush(i)
+		b.WriteRune(r)
+	}
+	flush(len(text))
+
+	return b.String()
+}
+
+// transliterateWord maps a mixed-script word to Latin, or returns it as is
+func transliterateWord(word string) string {
+	latin, lookalike := false, false
+	for _, r := range word {
+		switch {
+		case r < utf8.RuneSelf:
+			latin = latin || unicode.IsLetter(r)
+		case confusables[r] != 0:
+			lookalike = true
+		case unicode.IsLetter(r):
+			return word
+		}
+	}
+	if !latin || !lookalike {
+		return word
+	}
+
+	return strings.Map(func(r rune) rune {
+		if l, ok := confusables[r]; ok {
+			return l
+		}
+		return r
+	}, word)
+}
+
+// toPunycode converts an internationalized domain name to its ASCII form if
+// it ends in an ICANN top-level domain; anything else is returned as is
+func toPunycode(candidate string) string {
+	if isASCII([]byte(candidate)) {
+		return candidate
+	}
+
+	ascii, err := idna.Lookup.ToASCII(strings.ToLower(candidate))
+	if err != nil {
+		return candidate
+	}
+	if _, icann := publicsuffix.PublicSuffix(ascii); !icann {
+		return candidate
+	}
+	return ascii
+}
+
+// isASCII reports whether b contains only 7-bit characters
+func isASCII(b []byte) bool {
+	for _, c := range b {
+		if c >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,51 @@
+package language
+
+import "testing"
+
+func TestFoldConfusables(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"homograph domain", "аpple.com", "apple.com"},        // Cyrillic а
+		{"greek lookalike", "gοogle.com", "google.com"},       // Greek ο
+		{"mixed-script word", "pаypаl login", "paypal login"}, // Cyrillic а twice
+		{"pure cyrillic word", "пример.рф", "пример.рф"},      // Wholly Cyrillic, though р and е have twins
+		{"pure greek word", "κόσμος", "κόσμος"},               // Left as written
+		{"mixed without a twin", "appleж.com", "appleж.com"},  // ж has no Latin twin
+		{"ascii", "apple.com", "apple.com"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := FoldConfusables(tt.in); got != tt.want {
+				t.Errorf("FoldConfusables(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"zero-width space", "evil\u200b.example", "evil.example"},
+		{"right-to-left override", "invoice\u202egpj.exe", "invoicegpj.exe"},
+		{"byte order mark and soft hyphen", "\ufeffev\u00adil.example", "evil.example"},
+		{"fullwidth url", "ｈｔｔｐ：／／evil．example", "http://evil.example"},
+		{"ideographic stop", "evil。example.com", "evil.example.com"},
+		{"idn under a real tld", "visit bücher.de today", "visit xn--bcher-kva.de today"},
+		{"homograph kept as written", "аpple.com", "xn--pple-43d.com"},
+		{"idn under no tld", "bücher.invalidtld", "bücher.invalidtld"},
+		{"ascii", "plain.example", "plain.example"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := string(Normalize([]byte(tt.in))); got != tt.want {
+				t.Errorf("Normalize(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,165 @@
+package logparse
+
+import (
+	"strings"
+)
+
+// cefHeaderFields names the seven pipe-delimited CEF header fields after the version
+var cefHeaderFields = []string{"deviceVendor", "deviceProduct", "deviceVersion", "signatureId", "name", "severity"}
+
+// leefHeaderFields names the LEEF header fields after the version
+var leefHeaderFields = []string{"vendor", "product", "version", "eventId"}
+
+// parseCEF parses "CEF:Version|Vendor|Product|Version|SignatureID|Name|Severity|Extension"
+func parseCEF(msg string) (map[string]string, bool) {
+	parts := splitEscaped(strings.TrimPrefix(msg, "CEF:"), '|', 8)
+	if len(parts) < 8 {
+		return nil, false
+	}
+
+	fields := make(map[string]string, len(cefHeaderFields)+8)
+	for i, name := range cefHeaderFields {
+		fields[name] = unescapeCEF(parts[i+1])
+	}
+	for k, v := range parseCEFExtension(parts[7]) {
+		fields[k] = v
+	}
+	return fields, true
+}
+
+// parseCEFExtension parses space-separated key=value pairs. Values may
+// contain unescaped spaces; a value runs until the next " key=" token.
+func parseCEFExtension(ext string) map[string]string {
+	fields := make(map[string]string)
+
+	var key string
+	start := 0
+	for i := 0; i < len(ext); i++ {
+		if ext[i] != '=' || (i > 0 && ext[i-1] == '\\') {
+			continue
+		}
+
+		// Key is the token between the previous space and '='; an '=' inside
+		// a value (e.g. a URL query string) has no valid key before it
+		keyStart := strings.LastIndexByte(ext[:i], ' ') + 1
+		if !isCEFKey(ext[keyStart:i]) {
+			continue
+		}
+		if key != "" {
+			fields[key] = unescapeCEF(strings.TrimSpace(ext[start:keyStart]))
+		}
+		key = ext[keyStart:i]
+		start = i + 1
+	}
+	if key != "" {
+		fields[key] = unescapeCEF(strings.TrimSpace(ext[start:]))
+	}
+
+	return fields
+}
+
+// isCEFKey reports whether s is a valid extension key (letters, digits, underscore)
+func isCEFKey(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, c := range s {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_') {
+			return false
+		}
+	}
+	return true
+}
+
+// parseLEEF parses "LEEF:Version|Vendor|Product|Version|EventID|[Delimiter|]Attributes".
+// LEEF 1.0 attributes are tab-separated; 2.0 may declare its own delimiter.
+func parseLEEF(msg string) (map[string]string, bool) {
+	parts := splitEscaped(strings.TrimPrefix(msg, "LEEF:"), '|', 7)
+	if len(parts) < 6 {
+		return nil, false
+	}
+
+	fields := make(map[string]string, len(leefHeaderFields)+8)
+	for i, name := range leefHeaderFields {
+		fields[name] = parts[i+1]
+	}
+
+	delim := "\t"
+	attrs := parts[5]
+	if strings.HasPrefix(parts[0], "2") && len(parts) == 7 {
+		delim = leefDelimiter(parts[5])
+		attrs = parts[6]
+	} else if len(parts) == 7 {
+		attrs = parts[5] + "|" + parts[6]
+	}
+
+	for _, pair := range strings.Split(attrs, delim) {
+		if k, v, ok := strings.Cut(pair, "="); ok && k != "" {
+			fields[strings.TrimSpace(k)] = v
+		}
+	}
+	return fields, true
+}
+
+// leefDelimiter decodes a LEEF 2.0 delimiter: a literal character or a hex code like "x09"
+func leefDelimiter(s string) string {
+	if len(s) > 1 && (s[0] == 'x' || s[0] == 'X') {
+		var b byte
+		for _, c := range s[1:] {
+			switch {
+			case c >= '0' && c <= '9':
+				b = b<<4 | byte(c-'0')
+			case c >= 'a' && c <= 'f':
+				b = b<<4 | byte(c-'a'+10)
+			case c >= 'A' && c <= 'F':
+				b = b<<4 | byte(c-'A'+10)
+			default:
+				return "\t"
+			}
+		}
+		return string(b)
+	}
+	if s == "" {
+		return "\t"
+	}
+	return s
+}
+
+// splitEscaped splits s on sep into at most n parts, ignoring backslash-escaped separators
+func splitEscaped(s string, sep byte, n int) []string {
+	var parts []string
+	start := 0
+	for i := 0; i < len(s) && len(parts) < n-1; i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case sep:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+// unescapeCEF removes CEF escaping (\| \\ \= \n \r)
+func unescapeCEF(s string) string {
+	if !strings.Contains(s, "\\") {
+		return s
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i+1 == len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+		i++
+		switch s[i] {
+		case 'n', 'r':
+			b.WriteByte(' ')
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String()
+}
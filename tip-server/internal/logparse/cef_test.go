@@ -0,0 +1,91 @@
+package logparse
+
+import (
+	"maps"
+	"strings"
+	"testing"
+)
+
+func TestParseStructured(t *testing.T) {
+	tests := []struct {
+		name   string
+		line   string
+		format string
+		fields map[string]string
+	}{
+		{
+			name:   "cef",
+			line:   `<13>Jun  1 00:00:00 ids01 CEF:0|Security|IDS|1.0|100|Beacon \| C2|10|src=10.0.0.5 dst=203.0.113.77 msg=seen twice`,
+			format: FormatCEF,
+			fields: map[string]string{
+				"deviceVendor": "Security", "deviceProduct": "IDS", "deviceVersion": "1.0",
+				"signatureId": "100", "name": "Beacon | C2", "severity": "10",
+				"src": "10.0.0.5", "dst": "203.0.113.77", "msg": "seen twice",
+			},
+		},
+		{
+			name:   "cef escapes and equals in values",
+			line:   `CEF:0|V|P|1|2|N|3|request=http://evil.example/a?b=c&d=e msg=a\=b\nc\\d cs1=`,
+			format: FormatCEF,
+			fields: map[string]string{
+				"deviceVendor": "V", "deviceProduct": "P", "deviceVersion": "1",
+				"signatureId": "2", "name": "N", "severity": "3",
+				"request": "http://evil.example/a?b=c&d=e", "msg": `a=b c\d`, "cs1": "",
+			},
+		},
+		{
+			name:   "cef without an extension",
+			line:   "CEF:0|V|P|1|2|N|3|",
+			format: FormatCEF,
+			fields: map[string]string{
+				"deviceVendor": "V", "deviceProduct": "P", "deviceVersion": "1",
+				"signatureId": "2", "name": "N", "severity": "3",
+			},
+		},
+		{
+			name:   "leef 1.0",
+			line:   "<13>Jun  1 00:00:00 fw01 LEEF:1.0|Vendor|Firewall|2.1|deny|src=10.0.0.5\tdst=203.0.113.77\turl=http://evil.example/a|b",
+			format: FormatLEEF,
+			fields: map[string]string{
+				"vendor": "Vendor", "product": "Firewall", "version": "2.1", "eventId": "deny",
+				"src": "10.0.0.5", "dst": "203.0.113.77", "url": "http://evil.example/a|b",
+			},
+		},
+		{
+			name:   "leef 2.0 literal delimiter",
+			line:   "LEEF:2.0|Vendor|Firewall|2.1|deny|^|src=10.0.0.5^dst=203.0.113.77",
+			format: FormatLEEF,
+			fields: map[string]string{
+				"vendor": "Vendor", "product": "Firewall", "version": "2.1", "eventId": "deny",
+				"src": "10.0.0.5", "dst": "203.0.113.77",
+			},
+		},
+		{
+			name:   "leef 2.0 hex delimiter",
+			line:   "LEEF:2.0|Vendor|Firewall|2.1|deny|x5E|src=10.0.0.5^ dst =203.0.113.77^=ignored",
+			format: FormatLEEF,
+			fields: map[string]string{
+				"vendor": "Vendor", "product": "Firewall", "version": "2.1", "eventId": "deny",
+				"src": "10.0.0.5", "dst": "203.0.113.77",
+			},
+		},
+		{name: "cef short header", line: "CEF:0|V|P|1|2", format: FormatPlain},
+		{name: "leef short header", line: "LEEF:1.0|V|P", format: FormatPlain},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := Parse(tt.line)
+			if rec.Format != tt.format {
+				t.Fatalf("format = %q, want %q", rec.Format, tt.format)
+			}
+			if !maps.Equal(rec.Fields, tt.fields) {
+				t.Errorf("fields = %q, want %q", rec.Fields, tt.fields)
+			}
+			for _, v := range tt.fields {
+				if !strings.Contains(rec.Text(), v+"\n") {
+					t.Errorf("text lacks %q", v)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,286 @@
+package logparse
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"net"
+	"strings"
+
+	"tip-server/internal/models"
+)
+
+// JSON sensor log formats recognised by ExtractJSONLog
+const (
+	FormatSuricata = "suricata"
+	FormatZeek     = "zeek"
+)
+
+// maxJSONLogLine bounds a single EVE/Zeek record
+const maxJSONLogLine = 1024 * 1024
+
+// DetectJSONLog reports whether content is newline-delimited Suricata EVE or
+// Zeek JSON by inspecting its first record. Returns "" for anything else.
+func DetectJSONLog(content []byte) string {
+	line := content
+	if i := bytes.IndexByte(content, '\n'); i >= 0 {
+		line = content[:i]
+	}
+	line = bytes.TrimSpace(line)
+	if len(line) == 0 || line[0] != '{' {
+		return ""
+	}
+
+	var record map[string]any
+	if err := json.Unmarshal(line, &record); err != nil {
+		return ""
+	}
+	return jsonLogFormat(record)
+}
+
+// ExtractJSONLog returns indicator candidates from the typed fields of
+// Suricata EVE or Zeek JSON records (dns.rrname, http.hostname, tls.ja3, ...)
+// instead of regex-scanning whole lines. ok is false when content is not in
+// one of those formats. Candidates still need validation by the extractor.
+func ExtractJSONLog(content []byte) (map[models.IOCType][]string, string, bool) {
+	format := DetectJSONLog(content)
+	if format == "" {
+		return nil, "", false
+	}
+
+	c := candidates{}
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	scanner.Buffer(make([]byte, 0, 64*1024), maxJSONLogLine)
+
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 || line[0] != '{' {
+			continue
+		}
+
+		var record map[string]any
+		if err := json.Unmarshal(line, &record); err != nil {
+			continue
+		}
+
+		switch format {
+		case FormatSuricata:
+			c.suricata(record)
+		case FormatZeek:
+			c.zeek(record)
+		}
+	}
+
+	return c, format, true
+}
+
+// jsonLogFormat identifies a decoded record
+func jsonLogFormat(record map[string]any) string {
+	if _, ok := record["event_type"]; ok {
+		if _, ok := record["timestamp"]; ok {
+			return FormatSuricata
+		}
+	}
+	if _, ok := record["ts"]; ok {
+		if _, ok := record["uid"]; ok {
+			return FormatZeek
+		}
+		if _, ok := record["id.orig_h"]; ok {
+			return FormatZeek
+		}
+	}
+	return ""
+}
+
+// candidates accumulates typed values
+type candidates map[models.IOCType][]string
+
+func (c candidates) add(t models.IOCType, v string) {
+	if v = strings.TrimSpace(v); v != "" {
+		c[t] = append(c[t], v)
+	}
+}
+
+// addCertFingerprint adds a certificate fingerprint typed by its length
+func (c candidates) addCertFingerprint(v string) {
+	switch len(strings.ReplaceAll(strings.TrimSpace(v), ":", "")) {
+	case 40:
+		c.add(models.IOCTypeCertSHA1, v)
+	case 64:
+		c.add(models.IOCTypeCertSHA256, v)
+	}
+}
+
+// addHost adds a value that may be an IP address or a hostname
+func (c candidates) addHost(v string) {
+	v = strings.TrimSpace(v)
+	if host, _, err := net.SplitHostPort(v); err == nil {
+		v = host
+	}
+	v = strings.Trim(v, "[]")
+
+	if ip := net.ParseIP(v); ip != nil {
+		if ip.To4() != nil {
+			c.add(models.IOCTypeIPv4, v)
+		} else {
+			c.add(models.IOCTypeIPv6, v)
+		}
+		return
+	}
+	c.add(models.IOCTypeDomain, v)
+}
+
+// addURL adds an absolute URL built from a host and request target
+func (c candidates) addURL(host, target, scheme string) {
+	if target == "" {
+		return
+	}
+	if strings.Contains(target, "://") {
+		c.add(models.IOCTypeURL, target)
+		return
+	}
+	if host == "" || !strings.HasPrefix(target, "/") {
+		return
+	}
+	c.add(models.IOCTypeURL, scheme+"://"+host+target)
+}
+
+// addEmail adds an address, stripping angle brackets
+func (c candidates) addEmail(v string) {
+	c.add(models.IOCTypeEmail, strings.Trim(strings.TrimSpace(v), "<>"))
+}
+
+// suricata extracts fields from one EVE record
+func (c candidates) suricata(r map[string]any) {
+	c.addHost(str(r, "src_ip"))
+	c.addHost(str(r, "dest_ip"))
+
+	if dns, ok := r["dns"].(map[string]any); ok {
+		c.addHost(str(dns, "rrname"))
+		for _, q := range list(dns, "queries") {
+			c.addHost(str(q, "rrname"))
+		}
+		for _, a := range list(dns, "answers") {
+			c.addHost(str(a, "rrname"))
+			c.addHost(str(a, "rdata"))
+		}
+		if grouped, ok := dns["grouped"].(map[string]any); ok {
+			for _, rrtype := range []string{"A", "AAAA", "CNAME"} {
+				for _, v := range strs(grouped, rrtype) {
+					c.addHost(v)
+				}
+			}
+		}
+	}
+
+	if http, ok := r["http"].(map[string]any); ok {
+		host := str(http, "hostname")
+		c.addHost(host)
+		c.addURL(host, str(http, "url"), "http")
+		c.add(models.IOCTypeUserAgent, str(http, "http_user_agent"))
+	}
+
+	if tls, ok := r["tls"].(map[string]any); ok {
+		c.addHost(str(tls, "sni"))
+		for _, key := range []string{"ja3", "ja3s"} {
+			if fp, ok := tls[key].(map[string]any); ok {
+				c.add(models.IOCTypeJA3, str(fp, "hash"))
+			}
+		}
+		// Server certificate SHA-1, as colon-separated byte pairs
+		c.add(models.IOCTypeCertSHA1, str(tls, "fingerprint"))
+		c.add(models.IOCTypeCertSerial, str(tls, "serial"))
+	}
+
+	if fileinfo, ok := r["fileinfo"].(map[string]any); ok {
+		c.add(models.IOCTypeMD5, str(fileinfo, "md5"))
+		c.add(models.IOCTypeSHA1, str(fileinfo, "sha1"))
+		c.add(models.IOCTypeSHA256, str(fileinfo, "sha256"))
+	}
+
+	if smtp, ok := r["smtp"].(map[string]any); ok {
+		c.addEmail(str(smtp, "mail_from"))
+		for _, v := range strs(smtp, "rcpt_to") {
+			c.addEmail(v)
+		}
+	}
+	if email, ok := r["email"].(map[string]any); ok {
+		c.addEmail(str(email, "from"))
+		for _, v := range strs(email, "to") {
+			c.addEmail(v)
+		}
+	}
+}
+
+// zeek extracts fields from one Zeek JSON record (conn, dns, http, ssl, files, smtp logs)
+func (c candidates) zeek(r map[string]any) {
+	c.addHost(str(r, "id.orig_h"))
+	c.addHost(str(r, "id.resp_h"))
+
+	// dns.log
+	c.addHost(str(r, "query"))
+	for _, v := range strs(r, "answers") {
+		c.addHost(v)
+	}
+
+	// http.log
+	host := str(r, "host")
+	c.addHost(host)
+	c.addURL(host, str(r, "uri"), "http")
+	c.add(models.IOCTypeUserAgent, str(r, "user_agent"))
+
+	// ssl.log (ja3 package adds ja3/ja3s)
+	c.addHost(str(r, "server_name"))
+	c.add(models.IOCTypeJA3, str(r, "ja3"))
+	c.add(models.IOCTypeJA3, str(r, "ja3s"))
+
+	// x509.log (fingerprint is SHA-256 by default, SHA-1 on older Zeek)
+	c.addCertFingerprint(str(r, "fingerprint"))
+	c.add(models.IOCTypeCertSerial, str(r, "certificate.serial"))
+
+	// files.log
+	c.add(models.IOCTypeMD5, str(r, "md5"))
+	c.add(models.IOCTypeSHA1, str(r, "sha1"))
+	c.add(models.IOCTypeSHA256, str(r, "sha256"))
+
+	// smtp.log
+	c.addEmail(str(r, "mailfrom"))
+	for _, v := range strs(r, "rcptto") {
+		c.addEmail(v)
+	}
+}
+
+// str returns a string field, or "" if missing or not a string
+func str(m map[string]any, key string) string {
+	s, _ := m[key].(string)
+	return s
+}
+
+// strs returns a string or array-of-strings field as a slice
+func strs(m map[string]any, key string) []string {
+	switch v := m[key].(type) {
+	case string:
+		return []string{v}
+	case []any:
+		out := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	}
+	return nil
+}
+
+// list returns an array-of-objects field
+func list(m map[string]any, key string) []map[string]any {
+	items, _ := m[key].([]any)
+	out := make([]map[string]any, 0, len(items))
+	for _, item := range items {
+		if obj, ok := item.(map[string]any); ok {
+			out = append(out, obj)
+		}
+	}
+	return out
+}
@@ -0,0 +1,115 @@
+package logparse
+
+import (
+	"maps"
+	"slices"
+	"testing"
+
+	"tip-server/internal/models"
+)
+
+func TestExtractJSONLog(t *testing.T) {
+	const sha256 = "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"
+
+	tests := []struct {
+		name   string
+		log    string
+		format string
+		want   map[models.IOCType][]string
+	}{
+		{
+			name: "suricata dns",
+			log: `{"timestamp":"2024-06-01T00:00:00Z","event_type":"dns","src_ip":"10.0.0.5","dest_ip":"192.0.2.53",` +
+				`"dns":{"queries":[{"rrname":"update-checker-cdn.net"}],"answers":[{"rrname":"update-checker-cdn.net","rdata":"203.0.113.77"}],` +
+				`"grouped":{"AAAA":["2001:db8::1"],"CNAME":"cdn.example"}}}`,
+			format: FormatSuricata,
+			want: map[models.IOCType][]string{
+				models.IOCTypeIPv4:   {"10.0.0.5", "192.0.2.53", "203.0.113.77"},
+				models.IOCTypeIPv6:   {"2001:db8::1"},
+				models.IOCTypeDomain: {"update-checker-cdn.net", "update-checker-cdn.net", "cdn.example"},
+			},
+		},
+		{
+			name: "suricata http and tls",
+			log: `{"timestamp":"2024-06-01T00:00:00Z","event_type":"http","http":{"hostname":"update-checker-cdn.net:8080","url":"/gate.php?id=7","http_user_agent":"FixtureBot/1.0"}}` + "\n" +
+				"\n" +
+				"not json\n" +
+				`{"timestamp":"2024-06-01T00:00:01Z","event_type":"tls","tls":{"sni":"c2.example.org","ja3":{"hash":"e7d705a3286e19ea42f587b344ee6865"},` +
+				`"fingerprint":"aa:bb:cc","serial":"0A:1B"}}`,
+			format: FormatSuricata,
+			want: map[models.IOCType][]string{
+				models.IOCTypeDomain:     {"update-checker-cdn.net", "c2.example.org"},
+				models.IOCTypeURL:        {"http://update-checker-cdn.net:8080/gate.php?id=7"},
+				models.IOCTypeUserAgent:  {"FixtureBot/1.0"},
+				models.IOCTypeJA3:        {"e7d705a3286e19ea42f587b344ee6865"},
+				models.IOCTypeCertSHA1:   {"aa:bb:cc"},
+				models.IOCTypeCertSerial: {"0A:1B"},
+			},
+		},
+		{
+			name: "suricata files and mail",
+			log: `{"timestamp":"2024-06-01T00:00:00Z","event_type":"smtp","fileinfo":{"sha256":"` + sha256 + `"},` +
+				`"smtp":{"mail_from":"<invoices@payments-portal-secure.com>","rcpt_to":["<a@corp.example>"]},"email":{"from":"billing@payments-portal-secure.com","to":"b@corp.example"}}`,
+			format: FormatSuricata,
+			want: map[models.IOCType][]string{
+				models.IOCTypeSHA256: {sha256},
+				models.IOCTypeEmail:  {"invoices@payments-portal-secure.com", "a@corp.example", "billing@payments-portal-secure.com", "b@corp.example"},
+			},
+		},
+		{
+			name: "zeek dns and http",
+			log: `{"ts":1717200000.5,"uid":"C1","id.orig_h":"10.0.0.5","id.resp_h":"[2001:db8::1]","query":"update-checker-cdn.net","answers":["203.0.113.77"]}` + "\n" +
+				`{"ts":1717200001.0,"uid":"C2","host":"update-checker-cdn.net","uri":"http://other.example/x","user_agent":"FixtureBot/1.0"}`,
+			format: FormatZeek,
+			want: map[models.IOCType][]string{
+				models.IOCTypeIPv4:      {"10.0.0.5", "203.0.113.77"},
+				models.IOCTypeIPv6:      {"2001:db8::1"},
+				models.IOCTypeDomain:    {"update-checker-cdn.net", "update-checker-cdn.net"},
+				models.IOCTypeURL:       {"http://other.example/x"},
+				models.IOCTypeUserAgent: {"FixtureBot/1.0"},
+			},
+		},
+		{
+			name: "zeek ssl, x509, files and smtp",
+			log: `{"ts":1,"uid":"C3","server_name":"c2.example.org","ja3":"e7d705a3286e19ea42f587b344ee6865"}` + "\n" +
+				`{"ts":2,"id.orig_h":"10.0.0.6","fingerprint":"` + sha256 + `","certificate.serial":"0A1B"}` + "\n" +
+				`{"ts":3,"uid":"C4","fingerprint":"a94a8fe5ccb19ba61c4c0873d391e987982fbbd3","md5":"098f6bcd4621d373cade4e832627b4f6","mailfrom":"x@evil.example","rcptto":["a@corp.example"]}`,
+			format: FormatZeek,
+			want: map[models.IOCType][]string{
+				models.IOCTypeDomain:     {"c2.example.org"},
+				models.IOCTypeIPv4:       {"10.0.0.6"},
+				models.IOCTypeJA3:        {"e7d705a3286e19ea42f587b344ee6865"},
+				models.IOCTypeCertSHA256: {sha256},
+				models.IOCTypeCertSHA1:   {"a94a8fe5ccb19ba61c4c0873d391e987982fbbd3"},
+				models.IOCTypeCertSerial: {"0A1B"},
+				models.IOCTypeMD5:        {"098f6bcd4621d373cade4e832627b4f6"},
+				models.IOCTypeEmail:      {"x@evil.example", "a@corp.example"},
+			},
+		},
+		{name: "other json", log: `{"time":"2024-06-01","msg":"evil.example"}`},
+		{name: "first line not json", log: "evil.example\n" + `{"timestamp":"x","event_type":"dns"}`},
+		{name: "truncated record", log: `{"timestamp":"2024-06-01T00:00:00Z","event_type":"dns"`},
+		{name: "empty", log: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, format, ok := ExtractJSONLog([]byte(tt.log))
+			if format != tt.format || ok != (tt.format != "") {
+				t.Fatalf("format = %q, ok = %v; want %q", format, ok, tt.format)
+			}
+			if !maps.EqualFunc(got, tt.want, slices.Equal) {
+				t.Errorf("candidates = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseJSONLog(t *testing.T) {
+	rec := Parse(`<14>Jun  1 00:00:00 sensor suricata: {"timestamp":"2024-06-01T00:00:00Z","event_type":"tls","tls":{"sni":"c2.example.org"}}`)
+	if rec.Format != FormatSuricata || rec.AppName != "suricata" {
+		t.Fatalf("format = %q, app = %q; want suricata", rec.Format, rec.AppName)
+	}
+	if got := rec.Indicators[models.IOCTypeDomain]; !slices.Equal(got, []string{"c2.example.org"}) {
+		t.Errorf("domains = %q", got)
+	}
+}
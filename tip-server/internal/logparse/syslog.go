@@ -0,0 +1,172 @@
+// Package logparse parses streamed log formats (syslog, CEF, LEEF, Suricata
+// EVE and Zeek JSON) into
+// the fields that carry indicators, so extraction can skip framing noise.
+package logparse
+
+import (
+	"strconv"
+	"strings"
+
+	"tip-server/internal/models"
+)
+
+// Formats reported by Parse
+const (
+	FormatPlain = "plain"
+	FormatCEF   = "cef"
+	FormatLEEF  = "leef"
+)
+
+// Record is a parsed log message
+type Record struct {
+	Priority int    // Syslog PRI value (-1 when absent)
+	Hostname string // Reporting host from the syslog header
+	AppName  string // Syslog tag / APP-NAME
+	Message  string // Message body after the syslog header
+	Format   string // plain, cef, leef, suricata or zeek
+
+	// Fields holds the CEF/LEEF header and extension fields. Empty for plain messages.
+	Fields map[string]string
+
+	// Indicators holds typed candidates from Suricata EVE / Zeek JSON bodies
+	Indicators map[models.IOCType][]string
+}
+
+// Parse parses a single syslog line (RFC 5424 or RFC 3164) and, when the
+// body is CEF or LEEF, its structured fields. Lines without a syslog header
+// are treated as a bare message.
+func Parse(line string) Record {
+	rec := parseSyslog(strings.TrimRight(line, "\r\n"))
+
+	if i := strings.Index(rec.Message, "CEF:"); i >= 0 {
+		if fields, ok := parseCEF(rec.Message[i:]); ok {
+			rec.Format = FormatCEF
+			rec.Fields = fields
+			return rec
+		}
+	}
+	if i := strings.Index(rec.Message, "LEEF:"); i >= 0 {
+		if fields, ok := parseLEEF(rec.Message[i:]); ok {
+			rec.Format = FormatLEEF
+			rec.Fields = fields
+			return rec
+		}
+	}
+
+	if body := strings.TrimSpace(rec.Message); strings.HasPrefix(body, "{") {
+		if indicators, format, ok := ExtractJSONLog([]byte(body)); ok {
+			rec.Format = format
+			rec.Indicators = indicators
+			return rec
+		}
+	}
+
+	rec.Format = FormatPlain
+	return rec
+}
+
+// Text returns the content that should be scanned for indicators: the
+// values of structured fields, one per line, or the raw message body.
+func (r Record) Text() string {
+	if len(r.Fields) == 0 {
+		return r.Message
+	}
+
+	var b strings.Builder
+	for _, v := range r.Fields {
+		b.WriteString(v)
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// parseSyslog strips the syslog header, if any
+func parseSyslog(line string) Record {
+	rec := Record{Priority: -1, Message: line}
+
+	if !strings.HasPrefix(line, "<") {
+		return rec
+	}
+	end := strings.IndexByte(line, '>')
+	if end < 2 || end > 4 {
+		return rec
+	}
+	pri, err := strconv.Atoi(line[1:end])
+	if err != nil {
+		return rec
+	}
+	rec.Priority = pri
+	rest := line[end+1:]
+
+	// RFC 5424: VERSION SP TIMESTAMP SP HOSTNAME SP APP-NAME SP PROCID SP MSGID SP SD [SP MSG]
+	if len(rest) > 1 && rest[0] >= '1' && rest[0] <= '9' && rest[1] == ' ' {
+		parts := strings.SplitN(rest[2:], " ", 6)
+		if len(parts) == 6 {
+			rec.Hostname = nilValue(parts[1])
+			rec.AppName = nilValue(parts[2])
+			rec.Message = skipStructuredData(parts[5])
+			return rec
+		}
+	}
+
+	// RFC 3164: Mmm dd hh:mm:ss HOSTNAME TAG: MSG
+	if len(rest) > 16 && rest[3] == ' ' && rest[6] == ' ' && rest[15] == ' ' {
+		fields := strings.SplitN(rest[16:], " ", 2)
+		rec.Hostname = fields[0]
+		if len(fields) == 2 {
+			msg := fields[1]
+			if colon := strings.Index(msg, ": "); colon > 0 && !strings.ContainsAny(msg[:colon], " ") {
+				rec.AppName = msg[:colon]
+				msg = msg[colon+2:]
+			}
+			rec.Message = msg
+		} else {
+			rec.Message = ""
+		}
+		return rec
+	}
+
+	rec.Message = rest
+	return rec
+}
+
+// nilValue maps the RFC 5424 NILVALUE "-" to an empty string
+func nilValue(s string) string {
+	if s == "-" {
+		return ""
+	}
+	return s
+}
+
+// skipStructuredData removes RFC 5424 STRUCTURED-DATA ("-" or [..][..]) from
+// the front of s and returns the message that follows
+func skipStructuredData(s string) string {
+	if strings.HasPrefix(s, "-") {
+		return strings.TrimPrefix(strings.TrimPrefix(s, "-"), " ")
+	}
+
+	for strings.HasPrefix(s, "[") {
+		escaped, quoted := false, false
+		closed := -1
+		for i := 1; i < len(s); i++ {
+			switch {
+			case escaped:
+				escaped = false
+			case s[i] == '\\':
+				escaped = true
+			case s[i] == '"':
+				quoted = !quoted
+			case s[i] == ']' && !quoted:
+				closed = i
+			}
+			if closed >= 0 {
+				break
+			}
+		}
+		if closed < 0 {
+			return s
+		}
+		s = s[closed+1:]
+	}
+	return strings.TrimPrefix(s, " ")
+}
@@ -0,0 +1,83 @@
+package logparse
+
+import "testing"
+
+func TestParseSyslog(t *testing.T) {
+	tests := []struct {
+		name     string
+		line     string
+		priority int
+		hostname string
+		appName  string
+		message  string
+	}{
+		{
+			name:     "rfc 5424",
+			line:     "<34>1 2024-06-01T00:00:00Z fw01 sshd 123 ID47 - Failed password from 203.0.113.77\r\n",
+			priority: 34, hostname: "fw01", appName: "sshd",
+			message: "Failed password from 203.0.113.77",
+		},
+		{
+			name:     "rfc 5424 structured data",
+			line:     `<165>1 2024-06-01T00:00:00Z - proxy - - [meta a="1"][origin ip="10.0.0.5" note="x]y \"q\""] blocked evil.example`,
+			priority: 165, appName: "proxy",
+			message: "blocked evil.example",
+		},
+		{
+			name:     "rfc 5424 without a message",
+			line:     "<34>1 2024-06-01T00:00:00Z fw01 sshd 123 ID47 -",
+			priority: 34, hostname: "fw01", appName: "sshd",
+		},
+		{
+			name:     "rfc 3164",
+			line:     "<13>Jun  1 00:00:00 fw01 sshd[123]: Failed password from 203.0.113.77",
+			priority: 13, hostname: "fw01", appName: "sshd[123]",
+			message: "Failed password from 203.0.113.77",
+		},
+		{
+			name:     "rfc 3164 without a tag",
+			line:     "<13>Jun  1 00:00:00 fw01 connection from 203.0.113.77: refused",
+			priority: 13, hostname: "fw01",
+			message: "connection from 203.0.113.77: refused",
+		},
+		{
+			name:     "priority only",
+			line:     "<13>beacon to evil.example",
+			priority: 13,
+			message:  "beacon to evil.example",
+		},
+		{
+			name:     "no header",
+			line:     "beacon to evil.example",
+			priority: -1,
+			message:  "beacon to evil.example",
+		},
+		{
+			name:     "bad priority",
+			line:     "<ab>beacon",
+			priority: -1,
+			message:  "<ab>beacon",
+		},
+		{
+			name:     "priority too long",
+			line:     "<12345>beacon",
+			priority: -1,
+			message:  "<12345>beacon",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := Parse(tt.line)
+			if rec.Priority != tt.priority || rec.Hostname != tt.hostname || rec.AppName != tt.appName {
+				t.Errorf("header = %d %q %q, want %d %q %q",
+					rec.Priority, rec.Hostname, rec.AppName, tt.priority, tt.hostname, tt.appName)
+			}
+			if rec.Message != tt.message {
+				t.Errorf("message = %q, want %q", rec.Message, tt.message)
+			}
+			if rec.Format != FormatPlain || rec.Text() != tt.message {
+				t.Errorf("format = %q, text = %q; want plain message", rec.Format, rec.Text())
+			}
+		})
+	}
+}
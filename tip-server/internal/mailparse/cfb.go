@@ -0,0 +1,246 @@
+package mailparse
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"unicode/utf16"
+)
+
+// Compound File Binary (OLE2) structures, as used by Outlook .msg files.
+// Only what is needed to read streams is implemented: the FAT, mini FAT and
+// directory tree.
+
+// cfbMagic starts every compound file
+var cfbMagic = []byte{0xD0, 0xCF, 0x11, 0xE0, 0xA1, 0xB1, 0x1A, 0xE1}
+
+const (
+	cfbEndOfChain = 0xFFFFFFFE
+	cfbNoStream   = 0xFFFFFFFF
+	cfbDirSize    = 128
+
+	cfbTypeStorage = 1
+	cfbTypeStream  = 2
+	cfbTypeRoot    = 5
+)
+
+var errCorrupt = errors.New("corrupt compound file")
+
+// cfbEntry is a directory entry
+type cfbEntry struct {
+	name               string
+	kind               byte
+	left, right, child uint32
+	start              uint32
+	size               uint64
+}
+
+// compoundFile is a parsed compound file
+type compoundFile struct {
+	data       []byte
+	sectorSize int
+	miniSize   int
+	miniCutoff uint64
+	fat        []uint32
+	miniFAT    []uint32
+	entries    []cfbEntry
+	miniStream []byte
+}
+
+// openCompoundFile parses the header, allocation tables and directory
+func openCompoundFile(data []byte) (*compoundFile, error) {
+	if len(data) < 512 || !bytes.Equal(data[:8], cfbMagic) {
+		return nil, errors.New("not a compound file")
+	}
+
+	le := binary.LittleEndian
+	sectorShift := le.Uint16(data[0x1E:])
+	miniShift := le.Uint16(data[0x20:])
+	if sectorShift != 9 && sectorShift != 12 || miniShift != 6 {
+		return nil, errCorrupt
+	}
+
+	cf := &compoundFile{
+		data:       data,
+		sectorSize: 1 << sectorShift,
+		miniSize:   1 << miniShift,
+		miniCutoff: uint64(le.Uint32(data[0x38:])),
+	}
+
+	// The FAT sectors are listed in the header DIFAT, continued in a chain
+	// of DIFAT sectors
+	var fatSectors []uint32
+	for i := 0; i < 109; i++ {
+		if id := le.Uint32(data[0x4C+i*4:]); id < cfbEndOfChain {
+			fatSectors = append(fatSectors, id)
+		}
+	}
+	difat := le.Uint32(data[0x44:])
+	perSector := cf.sectorSize/4 - 1
+	for n := 0; difat < cfbEndOfChain; n++ {
+		sector, err := cf.sector(difat)
+		if err != nil || n > len(data)/cf.sectorSize {
+			return nil, errCorrupt
+		}
+		for i := 0; i < perSector; i++ {
+			if id := le.Uint32(sector[i*4:]); id < cfbEndOfChain {
+				fatSectors = append(fatSectors, id)
+			}
+		}
+		difat = le.Uint32(sector[perSector*4:])
+	}
+	// A FAT listing more sectors than the file holds repeats some, which
+	// would only inflate the table
+	if len(fatSectors) > len(data)/cf.sectorSize {
+		return nil, errCorrupt
+	}
+
+	for _, id := range fatSectors {
+		sector, err := cf.sector(id)
+		if err != nil {
+			return nil, err
+		}
+		for i := 0; i < cf.sectorSize; i += 4 {
+			cf.fat = append(cf.fat, le.Uint32(sector[i:]))
+		}
+	}
+
+	if miniFATStart := le.Uint32(data[0x3C:]); miniFATStart < cfbEndOfChain {
+		raw, err := cf.chain(miniFATStart, cf.fat, cf.sectorSize, cf.sector)
+		if err != nil {
+			return nil, err
+		}
+		for i := 0; i+4 <= len(raw); i += 4 {
+			cf.miniFAT = append(cf.miniFAT, le.Uint32(raw[i:]))
+		}
+	}
+
+	dir, err := cf.chain(le.Uint32(data[0x30:]), cf.fat, cf.sectorSize, cf.sector)
+	if err != nil {
+		return nil, err
+	}
+	for off := 0; off+cfbDirSize <= len(dir); off += cfbDirSize {
+		e := dir[off : off+cfbDirSize]
+		nameLen := int(le.Uint16(e[0x40:]))
+		if nameLen > 64 {
+			nameLen = 64
+		}
+		units := make([]uint16, 0, nameLen/2)
+		for i := 0; i+1 < nameLen; i += 2 {
+			if u := le.Uint16(e[i:]); u != 0 {
+				units = append(units, u)
+			}
+		}
+		cf.entries = append(cf.entries, cfbEntry{
+			name:  string(utf16.Decode(units)),
+			kind:  e[0x42],
+			left:  le.Uint32(e[0x44:]),
+			right: le.Uint32(e[0x48:]),
+			child: le.Uint32(e[0x4C:]),
+			start: le.Uint32(e[0x74:]),
+			size:  le.Uint64(e[0x78:]) & 0xFFFFFFFF, // v3 files leave the high half undefined
+		})
+	}
+	if len(cf.entries) == 0 || cf.entries[0].kind != cfbTypeRoot {
+		return nil, errCorrupt
+	}
+
+	root := cf.entries[0]
+	if root.start < cfbEndOfChain {
+		cf.miniStream, err = cf.chain(root.start, cf.fat, cf.sectorSize, cf.sector)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return cf, nil
+}
+
+// sector returns a regular sector by ID
+func (cf *compoundFile) sector(id uint32) ([]byte, error) {
+	off := (int(id) + 1) * cf.sectorSize
+	if id >= cfbEndOfChain || off+cf.sectorSize > len(cf.data) {
+		return nil, errCorrupt
+	}
+	return cf.data[off : off+cf.sectorSize], nil
+}
+
+// miniSector returns a mini stream sector by ID
+func (cf *compoundFile) miniSector(id uint32) ([]byte, error) {
+	off := int(id) * cf.miniSize
+	if id >= cfbEndOfChain || off+cf.miniSize > len(cf.miniStream) {
+		return nil, errCorrupt
+	}
+	return cf.miniStream[off : off+cf.miniSize], nil
+}
+
+// chain concatenates the sectors of an allocation chain
+func (cf *compoundFile) chain(start uint32, table []uint32, size int, read func(uint32) ([]byte, error)) ([]byte, error) {
+	var out []byte
+	for id, n := start, 0; id != cfbEndOfChain; n++ {
+		if int(id) >= len(table) || n > len(table) {
+			return nil, errCorrupt
+		}
+		sector, err := read(id)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, sector...)
+		id = table[id]
+	}
+	return out, nil
+}
+
+// read returns the content of a stream entry
+func (cf *compoundFile) read(e cfbEntry) ([]byte, error) {
+	if e.kind != cfbTypeStream {
+		return nil, fmt.Errorf("%s is not a stream", e.name)
+	}
+	if e.size == 0 {
+		return nil, nil
+	}
+
+	var (
+		raw []byte
+		err error
+	)
+	if e.size < cf.miniCutoff {
+		raw, err = cf.chain(e.start, cf.miniFAT, cf.miniSize, cf.miniSector)
+	} else {
+		raw, err = cf.chain(e.start, cf.fat, cf.sectorSize, cf.sector)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if uint64(len(raw)) < e.size {
+		return nil, errCorrupt
+	}
+	return raw[:e.size], nil
+}
+
+// children returns the IDs of the entries directly inside a storage, keyed
+// by name
+func (cf *compoundFile) children(storage uint32) map[string]uint32 {
+	out := make(map[string]uint32)
+	if int(storage) >= len(cf.entries) {
+		return out
+	}
+
+	// Siblings form a binary tree; walk it iteratively with a visited set so
+	// cyclic (malicious) trees terminate
+	seen := make(map[uint32]bool)
+	stack := []uint32{cf.entries[storage].child}
+	for len(stack) > 0 {
+		id := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if id == cfbNoStream || int(id) >= len(cf.entries) || seen[id] {
+			continue
+		}
+		seen[id] = true
+		e := cf.entries[id]
+		out[e.name] = id
+		stack = append(stack, e.left, e.right)
+	}
+	return out
+}
@@ -0,0 +1,257 @@
+// Package mailparse reads RFC 5322 (.eml) and Outlook (.msg) messages into
+// the parts that carry indicators: sender headers, the Received chain,
+// bodies and attachments.
+package mailparse
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"tip-server/internal/htmldoc"
+)
+
+// Formats recognised by Detect
+const (
+	FormatEML = "eml"
+	FormatMSG = "msg"
+)
+
+// Header names that identify the sender of a message
+const (
+	HeaderFrom       = "From"
+	HeaderSender     = "Sender"
+	HeaderReplyTo    = "Reply-To"
+	HeaderReturnPath = "Return-Path"
+)
+
+// SenderHeaders lists the address headers reported in Message.Addresses
+var SenderHeaders = []string{HeaderFrom, HeaderSender, HeaderReplyTo, HeaderReturnPath}
+
+// maxDepth bounds MIME nesting
+const maxDepth = 10
+
+// Message is the indicator-bearing content of an email
+type Message struct {
+	Subject     string
+	Addresses   map[string][]string // Sender header name -> addresses
+	Received    []string            // "from" clause of each Received header, newest first
+	Bodies      []string            // Plain text bodies; HTML bodies reduced to text and links
+	Attachments []Attachment
+}
+
+// Attachment describes an attached file by its hashes
+type Attachment struct {
+	Name   string
+	Size   int
+	MD5    string
+	SHA1   string
+	SHA256 string
+}
+
+// headerLine matches a header field at the start of a line
+var headerLine = regexp.MustCompile(`(?im)^(received|return-path|delivered-to|message-id|mime-version|x-mailer|date|from|to|subject):`)
+
+// Detect returns FormatEML or FormatMSG if the file is an email, or ""
+func Detect(path string, content []byte) string {
+	ext := strings.ToLower(filepath.Ext(path))
+	switch {
+	case bytes.HasPrefix(content, cfbMagic):
+		if ext == ".msg" {
+			return FormatMSG
+		}
+	case ext == ".eml":
+		return FormatEML
+	default:
+		// Unnamed exports: at least three standard headers in the first block
+		head := content[:min(len(content), 4096)]
+		if end := bytes.Index(head, []byte("\n\n")); end > 0 {
+			head = head[:end]
+		}
+		if len(headerLine.FindAll(head, -1)) >= 3 && headerLine.Match(head[:min(len(head), 64)]) {
+			return FormatEML
+		}
+	}
+	return ""
+}
+
+// Parse reads a message in the given format
+func Parse(format string, content []byte) (*Message, error) {
+	if format == FormatMSG {
+		return parseMSG(content)
+	}
+	return parseEML(content)
+}
+
+// parseEML reads an RFC 5322 message
+func parseEML(content []byte) (*Message, error) {
+	m, err := mail.ReadMessage(bufio.NewReader(bytes.NewReader(content)))
+	if err != nil {
+		return nil, err
+	}
+
+	msg := &Message{}
+	msg.readHeader(m.Header)
+	msg.readPart(m.Header.Get("Content-Type"), m.Header.Get("Content-Transfer-Encoding"), "", m.Body, 0)
+	return msg, nil
+}
+
+// readHeader collects subject, sender addresses and the Received chain
+func (msg *Message) readHeader(h mail.Header) {
+	dec := new(mime.WordDecoder)
+	if subject, err := dec.DecodeHeader(h.Get("Subject")); err == nil {
+		msg.Subject = subject
+	}
+
+	msg.Addresses = make(map[string][]string)
+	for _, name := range SenderHeaders {
+		for _, raw := range h[name] {
+			msg.Addresses[name] = append(msg.Addresses[name], parseAddresses(raw)...)
+		}
+	}
+
+	for _, raw := range h["Received"] {
+		if from := receivedFrom(raw); from != "" {
+			msg.Received = append(msg.Received, from)
+		}
+	}
+}
+
+// readPart walks a MIME entity, collecting bodies and attachments
+func (msg *Message) readPart(contentType, encoding, disposition string, body io.Reader, depth int) {
+	if depth > maxDepth {
+		return
+	}
+
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType = "text/plain"
+	}
+	disp, dispParams, _ := mime.ParseMediaType(disposition)
+	name := dispParams["filename"]
+	if name == "" {
+		name = params["name"]
+	}
+
+	if strings.HasPrefix(mediaType, "multipart/") {
+		mr := multipart.NewReader(body, params["boundary"])
+		for {
+			part, err := mr.NextRawPart()
+			if err != nil {
+				return
+			}
+			msg.readPart(part.Header.Get("Content-Type"), part.Header.Get("Content-Transfer-Encoding"),
+				part.Header.Get("Content-Disposition"), part, depth+1)
+		}
+	}
+
+	data, err := io.ReadAll(decodeTransfer(encoding, body))
+	if err != nil && len(data) == 0 {
+		return
+	}
+
+	switch {
+	case disp == "attachment" || name != "" || mediaType == "message/rfc822":
+		msg.Attachments = append(msg.Attachments, newAttachment(name, data))
+	case mediaType == "text/html":
+		msg.Bodies = append(msg.Bodies, string(htmldoc.Parse(data).Content()))
+	case strings.HasPrefix(mediaType, "text/"):
+		msg.Bodies = append(msg.Bodies, string(data))
+	default:
+		msg.Attachments = append(msg.Attachments, newAttachment(name, data))
+	}
+}
+
+// decodeTransfer undoes a Content-Transfer-Encoding
+func decodeTransfer(encoding string, r io.Reader) io.Reader {
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "base64":
+		return base64.NewDecoder(base64.StdEncoding, &base64Cleaner{r: r})
+	case "quoted-printable":
+		return quotedprintable.NewReader(r)
+	}
+	return r
+}
+
+// base64Cleaner strips the line breaks and whitespace base64 bodies are
+// wrapped with
+type base64Cleaner struct {
+	r io.Reader
+}
+
+func (c *base64Cleaner) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	kept := 0
+	for _, b := range p[:n] {
+		if b != '\r' && b != '\n' && b != ' ' && b != '\t' {
+			p[kept] = b
+			kept++
+		}
+	}
+	if kept == 0 && err == nil {
+		return c.Read(p)
+	}
+	return kept, err
+}
+
+// newAttachment hashes attachment content
+func newAttachment(name string, data []byte) Attachment {
+	md := md5.Sum(data)
+	s1 := sha1.Sum(data)
+	s256 := sha256.Sum256(data)
+	return Attachment{
+		Name:   name,
+		Size:   len(data),
+		MD5:    hex.EncodeToString(md[:]),
+		SHA1:   hex.EncodeToString(s1[:]),
+		SHA256: hex.EncodeToString(s256[:]),
+	}
+}
+
+// parseAddresses returns the addresses in a header value, falling back to
+// the raw value when it is not a valid address list
+func parseAddresses(raw string) []string {
+	list, err := mail.ParseAddressList(raw)
+	if err != nil {
+		raw = strings.Trim(strings.TrimSpace(raw), "<>")
+		if raw == "" {
+			return nil
+		}
+		return []string{raw}
+	}
+	out := make([]string, len(list))
+	for i, a := range list {
+		out[i] = a.Address
+	}
+	return out
+}
+
+// receivedFrom returns the "from" clause of a Received header, which names
+// the relay that handed the message over, e.g.
+// "from mail.example.org (mail.example.org [192.0.2.1])"
+func receivedFrom(raw string) string {
+	raw = strings.Join(strings.Fields(raw), " ")
+	lower := strings.ToLower(raw)
+	if !strings.HasPrefix(lower, "from ") {
+		return ""
+	}
+	end := len(raw)
+	for _, stop := range []string{" by ", ";"} {
+		if i := strings.Index(lower, stop); i > 0 && i < end {
+			end = i
+		}
+	}
+	return raw[:end]
+}
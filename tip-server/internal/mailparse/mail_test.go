@@ -0,0 +1,158 @@
+package mailparse
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// attachmentSHA256 is the hash of invoice.exe, attached to both samples
+var attachmentSHA256 = func() string {
+	sum := sha256.Sum256([]byte("MZ fake invoice payload\n"))
+	return hex.EncodeToString(sum[:])
+}()
+
+func readFixture(t *testing.T, name string) []byte {
+	t.Helper()
+	content, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return content
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		file      string
+		format    string
+		addresses map[string][]string
+		received  []string
+		bodies    int
+	}{
+		{
+			file:   "phish.eml",
+			format: FormatEML,
+			addresses: map[string][]string{
+				HeaderFrom:       {"invoices@payments-portal-secure.com"},
+				HeaderReplyTo:    {"collect@update-checker-cdn.net"},
+				HeaderReturnPath: {"bounce@payments-portal-secure.com"},
+			},
+			received: []string{
+				"from mail.payments-portal-secure.com (mail.payments-portal-secure.com [203.0.113.77])",
+				"from [10.0.0.5] (unknown [198.51.100.23])",
+			},
+			bodies: 2, // Plain text and HTML
+		},
+		{
+			file:      "phish.msg",
+			format:    FormatMSG,
+			addresses: map[string][]string{HeaderFrom: {"invoices@payments-portal-secure.com"}},
+			bodies:    1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			content := readFixture(t, tt.file)
+			if format := Detect(tt.file, content); format != tt.format {
+				t.Fatalf("Detect() = %q, want %q", format, tt.format)
+			}
+			msg, err := Parse(tt.format, content)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if msg.Subject != "Overdue invoice" {
+				t.Errorf("subject = %q", msg.Subject)
+			}
+			for _, name := range SenderHeaders {
+				if !slices.Equal(msg.Addresses[name], tt.addresses[name]) {
+					t.Errorf("%s = %v, want %v", name, msg.Addresses[name], tt.addresses[name])
+				}
+			}
+			if !slices.Equal(msg.Received, tt.received) {
+				t.Errorf("received = %q, want %q", msg.Received, tt.received)
+			}
+			if len(msg.Bodies) != tt.bodies {
+				t.Fatalf("%d bodies, want %d", len(msg.Bodies), tt.bodies)
+			}
+			for _, body := range msg.Bodies {
+				if !strings.Contains(body, "http://update-checker-cdn.net/pay?id=7") {
+					t.Errorf("body lacks the payment link: %q", body)
+				}
+			}
+			if len(msg.Attachments) != 1 {
+				t.Fatalf("attachments = %+v, want invoice.exe", msg.Attachments)
+			}
+			if a := msg.Attachments[0]; a.Name != "invoice.exe" || a.SHA256 != attachmentSHA256 {
+				t.Errorf("attachment = %+v, want invoice.exe with SHA256 %s", a, attachmentSHA256)
+			}
+		})
+	}
+}
+
+func TestParseMalformed(t *testing.T) {
+	msgFile := readFixture(t, "phish.msg")
+	corrupt := func(off int, b ...byte) []byte {
+		c := slices.Clone(msgFile)
+		copy(c[off:], b)
+		return c
+	}
+
+	tests := []struct {
+		name    string
+		format  string
+		content []byte
+		err     string
+	}{
+		{"eml without a header", FormatEML, []byte("no header here"), "malformed"},
+		{"empty eml", FormatEML, nil, "EOF"},
+		{"msg header only", FormatMSG, msgFile[:512], "corrupt"},
+		{"msg not a compound file", FormatMSG, readFixture(t, "phish.eml"), "not a compound file"},
+		{"msg short", FormatMSG, msgFile[:100], "not a compound file"},
+		{"msg bad sector size", FormatMSG, corrupt(0x1E, 15, 0), "corrupt"},
+		{"msg directory past the end", FormatMSG, corrupt(0x30, 0xff, 0xff, 0, 0), "corrupt"},
+		{"msg fat sector repeated", FormatMSG, corrupt(0x50, bytes.Repeat([]byte{0, 0, 0, 0}, 10)...), "corrupt"},
+		{"msg fat cycle", FormatMSG, corrupt(512+4, 1, 0, 0, 0), "corrupt"}, // Directory chain points at itself
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg, err := Parse(tt.format, tt.content)
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("Parse() = %+v, %v; want error %q", msg, err, tt.err)
+			}
+		})
+	}
+}
+
+// TestParseDamaged parses every prefix of the samples and every single-byte
+// corruption of them; none may panic
+func TestParseDamaged(t *testing.T) {
+	for _, tt := range []struct {
+		file   string
+		format string
+	}{
+		{"phish.eml", FormatEML},
+		{"phish.msg", FormatMSG},
+	} {
+		t.Run(tt.file, func(t *testing.T) {
+			content := readFixture(t, tt.file)
+			for n := range content {
+				Parse(tt.format, content[:n])
+			}
+			damaged := slices.Clone(content)
+			for idx := range damaged {
+				for _, b := range []byte{0x00, 0x7f, 0xff} {
+					orig := damaged[idx]
+					damaged[idx] = b
+					Parse(tt.format, damaged)
+					damaged[idx] = orig
+				}
+			}
+		})
+	}
+}
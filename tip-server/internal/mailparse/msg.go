@@ -0,0 +1,134 @@
+package mailparse
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"net/mail"
+	"strings"
+	"unicode/utf16"
+
+	"tip-server/internal/htmldoc"
+)
+
+// MAPI property tags read from .msg files
+const (
+	propSubject        = "0037"
+	propTransportHdrs  = "007D"
+	propSenderEmail    = "0C1F"
+	propSentReprEmail  = "0065"
+	propBody           = "1000"
+	propHTMLBody       = "1013"
+	propAttachData     = "3701"
+	propAttachFilename = "3704"
+	propAttachLongName = "3707"
+)
+
+// MAPI property value types
+const (
+	typeUnicode = "001F"
+	typeString8 = "001E"
+	typeBinary  = "0102"
+)
+
+const attachStoragePrefix = "__attach_version1.0_"
+
+// parseMSG reads an Outlook message stored as a compound file. Sender
+// headers and the Received chain come from the original transport headers
+// when Outlook kept them.
+func parseMSG(content []byte) (*Message, error) {
+	cf, err := openCompoundFile(content)
+	if err != nil {
+		return nil, err
+	}
+
+	root := cf.children(0)
+	msg := &Message{Addresses: make(map[string][]string)}
+
+	if hdrs := stringProp(cf, root, propTransportHdrs); hdrs != "" {
+		if m, err := mail.ReadMessage(bufio.NewReader(strings.NewReader(strings.TrimRight(hdrs, "\r\n") + "\r\n\r\n"))); err == nil {
+			msg.readHeader(m.Header)
+		}
+	}
+	if msg.Subject == "" {
+		msg.Subject = stringProp(cf, root, propSubject)
+	}
+	if len(msg.Addresses[HeaderFrom]) == 0 {
+		for _, prop := range []string{propSenderEmail, propSentReprEmail} {
+			// Exchange-internal senders are X.500 paths, not addresses
+			if addr := stringProp(cf, root, prop); strings.Contains(addr, "@") {
+				msg.Addresses[HeaderFrom] = append(msg.Addresses[HeaderFrom], addr)
+			}
+		}
+	}
+
+	if body := stringProp(cf, root, propBody); body != "" {
+		msg.Bodies = append(msg.Bodies, body)
+	}
+	if html := binaryProp(cf, root, propHTMLBody); len(html) > 0 {
+		msg.Bodies = append(msg.Bodies, string(htmldoc.Parse(html).Content()))
+	}
+
+	for name, id := range root {
+		if !strings.HasPrefix(name, attachStoragePrefix) || cf.entries[id].kind != cfbTypeStorage {
+			continue
+		}
+		attach := cf.children(id)
+		data := binaryProp(cf, attach, propAttachData)
+		if data == nil {
+			// Embedded messages are storages rather than data streams
+			continue
+		}
+		filename := stringProp(cf, attach, propAttachLongName)
+		if filename == "" {
+			filename = stringProp(cf, attach, propAttachFilename)
+		}
+		msg.Attachments = append(msg.Attachments, newAttachment(filename, data))
+	}
+
+	return msg, nil
+}
+
+// streamName is the name of the stream holding a property value
+func streamName(prop, kind string) string {
+	return "__substg1.0_" + prop + kind
+}
+
+// stringProp reads a Unicode or 8-bit string property
+func stringProp(cf *compoundFile, entries map[string]uint32, prop string) string {
+	if id, ok := entries[streamName(prop, typeUnicode)]; ok {
+		data, err := cf.read(cf.entries[id])
+		if err != nil {
+			return ""
+		}
+		units := make([]uint16, len(data)/2)
+		for i := range units {
+			units[i] = binary.LittleEndian.Uint16(data[i*2:])
+		}
+		return strings.TrimRight(string(utf16.Decode(units)), "\x00")
+	}
+	if id, ok := entries[streamName(prop, typeString8)]; ok {
+		data, err := cf.read(cf.entries[id])
+		if err != nil {
+			return ""
+		}
+		return string(bytes.TrimRight(data, "\x00"))
+	}
+	return ""
+}
+
+// binaryProp reads a binary property, or nil when absent
+func binaryProp(cf *compoundFile, entries map[string]uint32, prop string) []byte {
+	id, ok := entries[streamName(prop, typeBinary)]
+	if !ok {
+		return nil
+	}
+	data, err := cf.read(cf.entries[id])
+	if err != nil {
+		return nil
+	}
+	if data == nil {
+		data = []byte{}
+	}
+	return data
+}
@@ -0,0 +1,656 @@
+package memstore
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"tip-server/internal/db"
+	"tip-server/internal/models"
+)
+
+// Cache is an in-memory db.Cache. Its "Bloom filter" is an exact set, so
+// it never reports false positives.
+type Cache struct {
+	mu       sync.Mutex
+	bloom    map[string]struct{}
+	removals map[string]struct{} // Values awaiting a Bloom rebuild
+	keys     map[string]cacheEntry
+	subs     map[string][]*subscription // By channel
+}
+
+// cacheEntry is a stored value and its expiry (zero = none)
+type cacheEntry struct {
+	value   []byte
+	expires time.Time
+}
+
+// NewCache returns an empty cache
+func NewCache() *Cache {
+	return &Cache{
+		bloom:    make(map[string]struct{}),
+		removals: make(map[string]struct{}),
+		keys:     make(map[string]cacheEntry),
+		subs:     make(map[string][]*subscription),
+	}
+}
+
+var _ db.Cache = (*Cache)(nil)
+
+// Close does nothing
+func (c *Cache) Close() error { return nil }
+
+// Ping always succeeds
+func (c *Cache) Ping(ctx context.Context) error { return nil }
+
+// ========== Bloom Filter ==========
+
+// BFMAdd adds items to the filter
+func (c *Cache) BFMAdd(ctx context.Context, items []string) error {
+	_, err := c.BFMAddNew(ctx, items)
+	return err
+}
+
+// BFMAddNew adds items to the filter and reports which were new. Like
+// db.RedisClient, it drops the items from the negative lookup cache.
+func (c *Cache) BFMAddNew(ctx context.Context, items []string) ([]bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	added := make([]bool, len(items))
+	for i, item := range items {
+		delete(c.keys, db.MissCacheKey(item))
+		if _, ok := c.bloom[item]; !ok {
+			c.bloom[item] = struct{}{}
+			added[i] = true
+		}
+	}
+	return added, nil
+}
+
+// BFMExists reports, per item, whether it was added
+func (c *Cache) BFMExists(ctx context.Context, items []string) ([]bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	exists := make([]bool, len(items))
+	for i, item := range items {
+		_, exists[i] = c.bloom[item]
+	}
+	return exists, nil
+}
+
+// BFInfo reports the number of items in the filter
+func (c *Cache) BFInfo(ctx context.Context) (redis.BFInfo, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	n := int64(len(c.bloom))
+	return redis.BFInfo{Capacity: n, ItemsInserted: n, Filters: 1}, nil
+}
+
+// ScheduleBloomRemoval queues values for removal at the next rebuild
+func (c *Cache) ScheduleBloomRemoval(ctx context.Context, values ...string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, v := range values {
+		c.removals[v] = struct{}{}
+	}
+	return nil
+}
+
+// PendingBloomRemovals returns how many values await a rebuild
+func (c *Cache) PendingBloomRemovals(ctx context.Context) (int64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return int64(len(c.removals)), nil
+}
+
+// RebuildBloomFilter replaces the filter with the items fill adds.
+// Removals scheduled while fill runs are kept for the next rebuild.
+func (c *Cache) RebuildBloomFilter(ctx context.Context, fill func(add func([]string) error) error) error {
+	c.mu.Lock()
+	satisfied := c.removals
+	c.removals = make(map[string]struct{})
+	c.mu.Unlock()
+
+	rebuilt := make(map[string]struct{})
+	var fillMu sync.Mutex
+	err := fill(func(items []string) error {
+		fillMu.Lock()
+		defer fillMu.Unlock()
+		for _, item := range items {
+			rebuilt[item] = struct{}{}
+		}
+		return nil
+	})
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err != nil {
+		// Return the snapshot to the pending set so it is retried
+		for v := range satisfied {
+			c.removals[v] = struct{}{}
+		}
+		return fmt.Errorf("failed to fill rebuild filter: %w", err)
+	}
+	c.bloom = rebuilt
+	return nil
+}
+
+// DumpBloomFilter writes the filter's values, newline-separated, as a
+// single chunk
+func (c *Cache) DumpBloomFilter(ctx context.Context, write func(iter int64, data []byte) error) error {
+	c.mu.Lock()
+	values := make([]string, 0, len(c.bloom))
+	for v := range c.bloom {
+		values = append(values, v)
+	}
+	c.mu.Unlock()
+
+	slices.Sort(values)
+	return write(1, []byte(strings.Join(values, "\n")))
+}
+
+// RestoreBloomFilter replaces the filter with one written by
+// DumpBloomFilter
+func (c *Cache) RestoreBloomFilter(ctx context.Context, read func(load func(iter int64, data []byte) error) error) error {
+	restored := make(map[string]struct{})
+	err := read(func(iter int64, data []byte) error {
+		for _, v := range strings.Split(string(data), "\n") {
+			if v != "" {
+				restored[v] = struct{}{}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to load filter snapshot: %w", err)
+	}
+
+	c.mu.Lock()
+	c.bloom = restored
+	c.mu.Unlock()
+	return nil
+}
+
+// ========== Key-Value ==========
+
+// get returns a live key's value. Callers hold c.mu.
+func (c *Cache) get(key string) ([]byte, bool) {
+	entry, ok := c.keys[key]
+	if !ok {
+		return nil, false
+	}
+	if !entry.expires.IsZero() && !time.Now().Before(entry.expires) {
+		delete(c.keys, key)
+		return nil, false
+	}
+	return entry.value, true
+}
+
+// set stores a value with expiration (0 = no expiry). Callers hold c.mu.
+func (c *Cache) set(key string, value []byte, expiration time.Duration) {
+	entry := cacheEntry{value: value}
+	if expiration > 0 {
+		entry.expires = time.Now().Add(expiration)
+	}
+	c.keys[key] = entry
+}
+
+// GetSyncCursor returns the replica's sync cursor, or "" before the first sync
+func (c *Cache) GetSyncCursor(ctx context.Context) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	cursor, _ := c.get(db.SyncCursorKey)
+	return string(cursor), nil
+}
+
+// SetSyncCursor records how far the replica has synced
+func (c *Cache) SetSyncCursor(ctx context.Context, cursor string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.set(db.SyncCursorKey, []byte(cursor), 0)
+	return nil
+}
+
+// SetJSON marshals v and stores it under key with expiration (0 = no expiry)
+func (c *Cache) SetJSON(ctx context.Context, key string, v interface{}, expiration time.Duration) error {
+	payload, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to marshal value: %w", err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.set(key, payload, expiration)
+	return nil
+}
+
+// GetJSON loads key and unmarshals it into v. Returns redis.Nil if the key
+// is missing.
+func (c *Cache) GetJSON(ctx context.Context, key string, v interface{}) error {
+	c.mu.Lock()
+	payload, ok := c.get(key)
+	c.mu.Unlock()
+
+	if !ok {
+		return redis.Nil
+	}
+	return json.Unmarshal(payload, v)
+}
+
+// GetCachedIOCs returns the cached IOCs among values
+func (c *Cache) GetCachedIOCs(ctx context.Context, values []string) (map[string]models.IOC, error) {
+	cached := make(map[string]models.IOC)
+	for _, v := range values {
+		var ioc models.IOC
+		if err := c.GetJSON(ctx, db.LookupCacheKey(v), &ioc); err == nil {
+			cached[v] = ioc
+		}
+	}
+	return cached, nil
+}
+
+// CacheIOCs stores IOCs keyed by value for ttl
+func (c *Cache) CacheIOCs(ctx context.Context, iocs map[string]models.IOC, ttl time.Duration) error {
+	for value, ioc := range iocs {
+		if err := c.SetJSON(ctx, db.LookupCacheKey(value), ioc, ttl); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// InvalidateCachedIOCs drops values from the lookup cache
+func (c *Cache) InvalidateCachedIOCs(ctx context.Context, values ...string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, v := range values {
+		delete(c.keys, db.LookupCacheKey(v))
+	}
+	return nil
+}
+
+// FlushCachedIOCs empties the lookup cache
+func (c *Cache) FlushCachedIOCs(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for key := range c.keys {
+		if strings.HasPrefix(key, db.LookupCacheKey("")) {
+			delete(c.keys, key)
+		}
+	}
+	return nil
+}
+
+// ProbeLookups reads the filter and, when asked, the lookup and negative
+// caches for values
+func (c *Cache) ProbeLookups(ctx context.Context, values []string, cached, misses bool) db.LookupProbe {
+	var probe db.LookupProbe
+	if len(values) == 0 {
+		return probe
+	}
+
+	probe.Bloom, probe.BloomErr = c.BFMExists(ctx, values)
+	if cached {
+		probe.Cached, probe.CacheErr = c.GetCachedIOCs(ctx, values)
+	}
+	if misses {
+		c.mu.Lock()
+		probe.Misses = make(map[string]bool)
+		for _, v := range values {
+			if _, ok := c.get(db.MissCacheKey(v)); ok {
+				probe.Misses[v] = true
+			}
+		}
+		c.mu.Unlock()
+	}
+	return probe
+}
+
+// StoreLookups writes matches to the lookup cache for hitTTL and values not
+// found to the negative cache for missTTL. A zero TTL skips that cache.
+func (c *Cache) StoreLookups(ctx context.Context, found map[string]models.IOC, hitTTL time.Duration, absent []string, missTTL time.Duration) error {
+	if hitTTL > 0 {
+		if err := c.CacheIOCs(ctx, found, hitTTL); err != nil {
+			return err
+		}
+	}
+	if missTTL > 0 {
+		c.mu.Lock()
+		for _, v := range absent {
+			c.set(db.MissCacheKey(v), []byte("1"), missTTL)
+		}
+		c.mu.Unlock()
+	}
+	return nil
+}
+
+// ========== Pub/Sub ==========
+
+// subscriptionBuffer is how many payloads a subscriber may fall behind
+// before further ones are dropped
+const subscriptionBuffer = 100
+
+// subscription is an in-memory db.Subscription
+type subscription struct {
+	cache    *Cache
+	channels []string
+	payloads chan string
+	once     sync.Once
+}
+
+// Payloads returns the channel delivering message payloads
+func (s *subscription) Payloads() <-chan string {
+	return s.payloads
+}
+
+// Close unsubscribes, closing the payload channel
+func (s *subscription) Close() error {
+	s.once.Do(func() {
+		c := s.cache
+		c.mu.Lock()
+		defer c.mu.Unlock()
+
+		for _, ch := range s.channels {
+			for i, sub := range c.subs[ch] {
+				if sub == s {
+					c.subs[ch] = append(c.subs[ch][:i], c.subs[ch][i+1:]...)
+					break
+				}
+			}
+		}
+		close(s.payloads)
+	})
+	return nil
+}
+
+// Subscribe subscribes to one or more channels
+func (c *Cache) Subscribe(ctx context.Context, channels ...string) db.Subscription {
+	sub := &subscription{cache: c, channels: channels, payloads: make(chan string, subscriptionBuffer)}
+
+	c.mu.Lock()
+	for _, ch := range channels {
+		c.subs[ch] = append(c.subs[ch], sub)
+	}
+	c.mu.Unlock()
+
+	return sub
+}
+
+// publish delivers a payload to a channel's subscribers and returns how many
+// there were
+func (c *Cache) publish(channel string, payload []byte) int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, sub := range c.subs[channel] {
+		select {
+		case sub.payloads <- string(payload):
+		default:
+		}
+	}
+	return int64(len(c.subs[channel]))
+}
+
+// RequestIngestRun publishes an on-demand crawl, returning how many
+// ingestors received it
+func (c *Cache) RequestIngestRun(ctx context.Context, run models.IngestRun) (int64, error) {
+	payload, err := json.Marshal(run)
+	if err != nil {
+		return 0, fmt.Errorf("failed to marshal ingest run: %w", err)
+	}
+	return c.publish(db.IngestRunsChannel, payload), nil
+}
+
+// PublishMatchEvents publishes match events
+func (c *Cache) PublishMatchEvents(ctx context.Context, events []models.MatchEvent) error {
+	for _, event := range events {
+		if err := c.PublishJSON(ctx, db.MatchEventsChannel, event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// PublishJSON marshals v and publishes it on a channel
+func (c *Cache) PublishJSON(ctx context.Context, channel string, v interface{}) error {
+	payload, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %w", err)
+	}
+	c.publish(channel, payload)
+	return nil
+}
+
+// ========== Rate Limits and IP Blocklist ==========
+
+// incrementWindow increments a fixed-window counter and reports whether it
+// exceeds limit
+func (c *Cache) incrementWindow(key string, limit int, window time.Duration) (int64, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	value, ok := c.get(key)
+	if !ok {
+		c.set(key, []byte("1"), window)
+		return 1, 1 > limit, nil
+	}
+
+	// The window keeps the expiry set by its first increment
+	count, _ := strconv.ParseInt(string(value), 10, 64)
+	count++
+	entry := c.keys[key]
+	entry.value = strconv.AppendInt(nil, count, 10)
+	c.keys[key] = entry
+	return count, count > int64(limit), nil
+}
+
+// IncrementRateLimit counts a request against an API key's limit
+func (c *Cache) IncrementRateLimit(ctx context.Context, apiKeyHash string, limit int, window time.Duration) (int64, bool, error) {
+	return c.incrementWindow(db.RateLimitKey(apiKeyHash), limit, window)
+}
+
+// IncrementIPRateLimit counts a request against a client IP's limit
+func (c *Cache) IncrementIPRateLimit(ctx context.Context, ip string, limit int, window time.Duration) (int64, bool, error) {
+	return c.incrementWindow(db.IPRateLimitKey(ip), limit, window)
+}
+
+// RecordAuthFailure counts a failed authentication from ip
+func (c *Cache) RecordAuthFailure(ctx context.Context, ip string, limit int, window time.Duration) (int64, bool, error) {
+	return c.incrementWindow(db.AuthFailureKey(ip), limit, window)
+}
+
+// IncrementEnrichmentRateLimit counts a lookup against a provider's quota
+func (c *Cache) IncrementEnrichmentRateLimit(ctx context.Context, provider string, limit int, window time.Duration) (int64, bool, error) {
+	return c.incrementWindow("rate_limit:enrich:"+provider, limit, window)
+}
+
+// GetRateLimitRemaining returns remaining requests for an API key
+func (c *Cache) GetRateLimitRemaining(ctx context.Context, apiKeyHash string, limit int) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	value, ok := c.get(db.RateLimitKey(apiKeyHash))
+	if !ok {
+		return limit, nil
+	}
+	current, _ := strconv.Atoi(string(value))
+	return max(limit-current, 0), nil
+}
+
+// BlockIP adds an IP to the blocklist. A zero ttl blocks until removed.
+func (c *Cache) BlockIP(ctx context.Context, entry models.IPBlock, ttl time.Duration) error {
+	return c.SetJSON(ctx, db.IPBlockKey(entry.IP), entry, ttl)
+}
+
+// UnblockIP removes an IP from the blocklist and reports whether it was listed
+func (c *Cache) UnblockIP(ctx context.Context, ip string) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	_, listed := c.get(db.IPBlockKey(ip))
+	delete(c.keys, db.IPBlockKey(ip))
+	delete(c.keys, db.AuthFailureKey(ip))
+	return listed, nil
+}
+
+// IsIPBlocked checks whether an IP is currently blocklisted
+func (c *Cache) IsIPBlocked(ctx context.Context, ip string) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	_, ok := c.get(db.IPBlockKey(ip))
+	return ok, nil
+}
+
+// ListBlockedIPs returns all current blocklist entries
+func (c *Cache) ListBlockedIPs(ctx context.Context) ([]models.IPBlock, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	prefix := db.IPBlockKey("")
+	var entries []models.IPBlock
+	for key := range c.keys {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		payload, ok := c.get(key)
+		if !ok {
+			continue
+		}
+		var entry models.IPBlock
+		if err := json.Unmarshal(payload, &entry); err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// volumeSinceKey mirrors the key db.RedisClient records the start of
+// counting under
+const volumeSinceKey = "tip:volume_since"
+
+// RecordIngestionVolume adds IOC counts to the hour at, keeping the hour for
+// retention
+func (c *Cache) RecordIngestionVolume(ctx context.Context, at time.Time, counts map[models.VolumeKey]int64, retention time.Duration) error {
+	if len(counts) == 0 {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := db.VolumeHourKey(at)
+	hour := make(map[string]int64)
+	if payload, ok := c.get(key); ok {
+		if err := json.Unmarshal(payload, &hour); err != nil {
+			return err
+		}
+	}
+	for k, n := range counts {
+		hour[db.VolumeField(k)] += n
+	}
+	payload, err := json.Marshal(hour)
+	if err != nil {
+		return err
+	}
+	c.set(key, payload, retention)
+
+	since, ok := c.get(volumeSinceKey)
+	if !ok {
+		since = []byte(strconv.FormatInt(at.Unix(), 10))
+	}
+	c.set(volumeSinceKey, since, retention)
+	return nil
+}
+
+// GetIngestionVolume returns the IOC counts of each hour, and since when
+// counts have been recorded (zero if they are not)
+func (c *Cache) GetIngestionVolume(ctx context.Context, hours []time.Time) ([]map[models.VolumeKey]int64, time.Time, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	counts := make([]map[models.VolumeKey]int64, len(hours))
+	for i, h := range hours {
+		counts[i] = make(map[models.VolumeKey]int64)
+		payload, ok := c.get(db.VolumeHourKey(h))
+		if !ok {
+			continue
+		}
+		var hour map[string]int64
+		if err := json.Unmarshal(payload, &hour); err != nil {
+			return nil, time.Time{}, err
+		}
+		for field, n := range hour {
+			if k, ok := db.ParseVolumeField(field); ok {
+				counts[i][k] = n
+			}
+		}
+	}
+
+	var started time.Time
+	if payload, ok := c.get(volumeSinceKey); ok {
+		if unix, err := strconv.ParseInt(string(payload), 10, 64); err == nil {
+			started = time.Unix(unix, 0)
+		}
+	}
+	return counts, started, nil
+}
+
+// SaveSubscription stores a subscription
+func (c *Cache) SaveSubscription(ctx context.Context, sub models.Subscription) error {
+	return c.SetJSON(ctx, db.SubscriptionKey(sub.ID), sub, 0)
+}
+
+// DeleteSubscription removes a subscription and its statistics, and reports
+// whether it existed
+func (c *Cache) DeleteSubscription(ctx context.Context, id string) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	_, ok := c.get(db.SubscriptionKey(id))
+	delete(c.keys, db.SubscriptionKey(id))
+	delete(c.keys, db.SubscriptionStatusKey(id))
+	return ok, nil
+}
+
+// ListSubscriptions returns all subscriptions
+func (c *Cache) ListSubscriptions(ctx context.Context) ([]models.Subscription, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	prefix := db.SubscriptionKey("")
+	var subs []models.Subscription
+	for key := range c.keys {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		payload, ok := c.get(key)
+		if !ok {
+			continue
+		}
+		var sub models.Subscription
+		if err := json.Unmarshal(payload, &sub); err != nil {
+			return nil, err
+		}
+		subs = append(subs, sub)
+	}
+	return subs, nil
+}
@@ -0,0 +1,1265 @@
+package memstore
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"maps"
+	"math"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"tip-server/internal/db"
+	"tip-server/internal/dga"
+	"tip-server/internal/models"
+)
+
+// IOCStore is an in-memory db.IOCStore. Like the ClickHouse tables it
+// keeps every inserted row and aggregates per value on read.
+type IOCStore struct {
+	mu          sync.RWMutex
+	iocs        []iocRow
+	files       map[string]models.FileMetadata   // By file ID, latest version
+	history     map[string][]models.FileMetadata // By file ID, every version oldest first
+	resolutions map[string]models.DomainResolution
+	whois       []models.WhoisRelationship
+	samples     map[string]models.ExtractionSample // By sample ID, latest version
+	audit       []models.AuditEntry
+	queries     []models.QueryLogEntry
+}
+
+// iocRow is one stored sighting of an IOC
+type iocRow struct {
+	models.IOC
+	deprecated bool
+	ingestedAt time.Time
+}
+
+// NewIOCStore returns an empty store
+func NewIOCStore() *IOCStore {
+	return &IOCStore{
+		files:       make(map[string]models.FileMetadata),
+		history:     make(map[string][]models.FileMetadata),
+		resolutions: make(map[string]models.DomainResolution),
+		samples:     make(map[string]models.ExtractionSample),
+	}
+}
+
+var _ db.IOCStore = (*IOCStore)(nil)
+
+// Close does nothing
+func (s *IOCStore) Close() error { return nil }
+
+// Ping always succeeds
+func (s *IOCStore) Ping(ctx context.Context) error { return nil }
+
+// AuditEntries returns the recorded administrative actions
+func (s *IOCStore) AuditEntries() []models.AuditEntry {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return slices.Clone(s.audit)
+}
+
+// QueryLog returns the recorded lookups
+func (s *IOCStore) QueryLog() []models.QueryLogEntry {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return slices.Clone(s.queries)
+}
+
+// ========== File Registry ==========
+
+// GetFileMetadata returns the latest registry entry of a file, or
+// sql.ErrNoRows
+func (s *IOCStore) GetFileMetadata(ctx context.Context, fileID string) (*models.FileMetadata, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	meta, ok := s.files[fileID]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	return &meta, nil
+}
+
+// GetFileMetadataByPath returns the most recently updated entry recorded
+// under a path
+func (s *IOCStore) GetFileMetadataByPath(ctx context.Context, filePath string) (*models.FileMetadata, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var latest *models.FileMetadata
+	for _, meta := range s.files {
+		if meta.FilePath == filePath && (latest == nil || meta.UpdatedAt.After(latest.UpdatedAt)) {
+			latest = &meta
+		}
+	}
+	if latest == nil {
+		return nil, sql.ErrNoRows
+	}
+	return latest, nil
+}
+
+// UpsertFileMetadata replaces a file's registry entry
+func (s *IOCStore) UpsertFileMetadata(ctx context.Context, meta *models.FileMetadata) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry := *meta
+	entry.UpdatedAt = time.Now()
+	s.files[meta.FileID] = entry
+	s.history[meta.FileID] = append(s.history[meta.FileID], entry)
+	return nil
+}
+
+// GetFileHistory returns up to limit versions of a registry entry, newest
+// first
+func (s *IOCStore) GetFileHistory(ctx context.Context, fileID string, limit int) ([]models.FileMetadata, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	versions := slices.Clone(s.history[fileID])
+	slices.Reverse(versions)
+	return truncate(versions, limit), nil
+}
+
+// ListActiveFiles returns file_id -> file_path for non-deleted entries whose
+// path starts with pathPrefix
+func (s *IOCStore) ListActiveFiles(ctx context.Context, pathPrefix string) (map[string]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	files := make(map[string]string)
+	for id, meta := range s.files {
+		if meta.ScanStatus != models.ScanStatusDeleted && strings.HasPrefix(meta.FilePath, pathPrefix) {
+			files[id] = meta.FilePath
+		}
+	}
+	return files, nil
+}
+
+// ListStoredMiscFiles returns misc entries whose content was stored
+func (s *IOCStore) ListStoredMiscFiles(ctx context.Context) ([]models.FileMetadata, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var files []models.FileMetadata
+	for _, meta := range s.files {
+		if meta.ScanStatus == models.ScanStatusMisc && meta.MinIOKey != "" {
+			files = append(files, meta)
+		}
+	}
+	slices.SortFunc(files, func(a, b models.FileMetadata) int { return cmp.Compare(a.FilePath, b.FilePath) })
+	return files, nil
+}
+
+// ListFilesByStatus returns up to limit entries with the given scan status,
+// least recently processed first
+func (s *IOCStore) ListFilesByStatus(ctx context.Context, status models.ScanStatus, limit int) ([]models.FileMetadata, error) {
+	s.mu.RLock()
+	var files []models.FileMetadata
+	for _, meta := range s.files {
+		if meta.ScanStatus == status {
+			files = append(files, meta)
+		}
+	}
+	s.mu.RUnlock()
+
+	slices.SortFunc(files, func(a, b models.FileMetadata) int {
+		return cmp.Or(a.ProcessedAt.Compare(b.ProcessedAt), cmp.Compare(a.FilePath, b.FilePath))
+	})
+	return truncate(files, limit), nil
+}
+
+// ListDuplicateFiles reports content registered under more than one active
+// file ID, the content with the most registrations first
+func (s *IOCStore) ListDuplicateFiles(ctx context.Context, limit, offset int) (*models.DuplicateReport, error) {
+	s.mu.RLock()
+	byHash := make(map[string][]models.DuplicateFile)
+	for _, meta := range s.files {
+		if meta.ScanStatus == models.ScanStatusDeleted || meta.ContentHash == "" {
+			continue
+		}
+		byHash[meta.ContentHash] = append(byHash[meta.ContentHash], models.DuplicateFile{
+			FileID:      meta.FileID,
+			FilePath:    meta.FilePath,
+			ScanStatus:  meta.ScanStatus,
+			IOCCount:    meta.IOCCount,
+			ProcessedAt: meta.ProcessedAt,
+		})
+	}
+	s.mu.RUnlock()
+
+	report := &models.DuplicateReport{Groups: []models.DuplicateFiles{}}
+	var groups []models.DuplicateFiles
+	for hash, files := range byHash {
+		if len(files) < 2 {
+			continue
+		}
+		slices.SortFunc(files, func(a, b models.DuplicateFile) int { return b.ProcessedAt.Compare(a.ProcessedAt) })
+		groups = append(groups, models.DuplicateFiles{ContentHash: hash, Files: files})
+		report.TotalGroups++
+		report.RedundantFiles += uint64(len(files) - 1)
+	}
+	slices.SortFunc(groups, func(a, b models.DuplicateFiles) int {
+		return cmp.Or(cmp.Compare(len(b.Files), len(a.Files)), cmp.Compare(a.ContentHash, b.ContentHash))
+	})
+	if offset < len(groups) {
+		report.Groups = truncate(groups[offset:], limit)
+	}
+	return report, nil
+}
+
+// GetReferencedMinIOKeys returns the object keys registry entries point at
+func (s *IOCStore) GetReferencedMinIOKeys(ctx context.Context) (map[string]struct{}, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	keys := make(map[string]struct{})
+	for _, meta := range s.files {
+		if meta.MinIOKey != "" {
+			keys[meta.MinIOKey] = struct{}{}
+		}
+	}
+	return keys, nil
+}
+
+// ListFilesMissingIOCs returns up to limit IDs of infected files processed
+// before the cutoff with no IOC rows, and how many there are
+func (s *IOCStore) ListFilesMissingIOCs(ctx context.Context, before time.Time, limit int) ([]string, uint64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	sources := make(map[string]bool)
+	for _, row := range s.iocs {
+		sources[row.SourceFileID] = true
+	}
+	var ids []string
+	for id, meta := range s.files {
+		if meta.ScanStatus == models.ScanStatusInfected && meta.IOCCount > 0 &&
+			meta.ProcessedAt.Before(before) && !sources[id] {
+			ids = append(ids, id)
+		}
+	}
+	slices.Sort(ids)
+	return truncate(ids, limit), uint64(len(ids)), nil
+}
+
+// PurgeFiles removes registry entries and their recorded history
+func (s *IOCStore) PurgeFiles(ctx context.Context, fileIDs []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, id := range fileIDs {
+		delete(s.files, id)
+		delete(s.history, id)
+	}
+	return nil
+}
+
+// CountMinIOKeyReferences returns how many registry entries other than
+// excludeFileIDs point at a MinIO key
+func (s *IOCStore) CountMinIOKeyReferences(ctx context.Context, minioKey string, excludeFileIDs []string) (uint64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var n uint64
+	for id, meta := range s.files {
+		if meta.MinIOKey == minioKey && !slices.Contains(excludeFileIDs, id) {
+			n++
+		}
+	}
+	return n, nil
+}
+
+// ========== IOCs ==========
+
+// BatchInsertIOCs stores a batch of IOC rows, scoring domains like the
+// ClickHouse client
+func (s *IOCStore) BatchInsertIOCs(ctx context.Context, iocs []models.IOC) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for _, ioc := range iocs {
+		if ioc.Type == models.IOCTypeDomain && ioc.DGAScore == 0 {
+			ioc.DGAScore = dga.Score(ioc.Value)
+		}
+		ioc.Tags = slices.Clone(ioc.Tags)
+		s.iocs = append(s.iocs, iocRow{IOC: ioc, ingestedAt: now})
+	}
+	return nil
+}
+
+// QueryIOCs returns one aggregated row per known value
+func (s *IOCStore) QueryIOCs(ctx context.Context, iocValues []string) ([]models.IOC, error) {
+	results := make([]models.IOC, 0, len(iocValues))
+	err := s.StreamIOCs(ctx, iocValues, func(ioc models.IOC) error {
+		results = append(results, ioc)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// StreamIOCs calls fn with one aggregated row per known value
+func (s *IOCStore) StreamIOCs(ctx context.Context, iocValues []string, fn func(models.IOC) error) error {
+	wanted := make(map[string]bool, len(iocValues))
+	for _, v := range iocValues {
+		wanted[v] = true
+	}
+
+	s.mu.RLock()
+	groups := s.group(func(row iocRow) bool { return wanted[row.Value] }, false)
+	s.mu.RUnlock()
+
+	for _, rows := range groups {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := fn(aggregate(rows)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ListActiveRanges returns every active CIDR indicator, aggregated per block
+func (s *IOCStore) ListActiveRanges(ctx context.Context) ([]models.IOC, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var ranges []models.IOC
+	for _, rows := range s.group(func(row iocRow) bool { return row.Type == models.IOCTypeCIDR && !row.Pending }, false) {
+		ranges = append(ranges, aggregate(rows))
+	}
+	return ranges, nil
+}
+
+// SearchIOCs returns active indicators matching q exactly, as a prefix or as
+// a substring, aggregated per value and type
+func (s *IOCStore) SearchIOCs(ctx context.Context, mode, q string, iocType models.IOCType, minDGAScore uint8, limit int) ([]models.IOC, error) {
+	var match func(string) bool
+	switch {
+	case q == "":
+		match = func(string) bool { return true }
+	case mode == models.SearchModeExact:
+		match = func(v string) bool { return v == q }
+	case mode == models.SearchModePrefix:
+		match = func(v string) bool { return strings.HasPrefix(v, q) }
+	case mode == models.SearchModeSubstring:
+		match = func(v string) bool { return strings.Contains(v, q) }
+	default:
+		return nil, fmt.Errorf("unknown search mode %q", mode)
+	}
+
+	s.mu.RLock()
+	results := s.search(func(row iocRow) bool {
+		return match(row.Value) && (iocType == "" || row.Type == iocType) && row.DGAScore >= minDGAScore
+	})
+	s.mu.RUnlock()
+
+	if q == "" {
+		slices.SortStableFunc(results, func(a, b models.IOC) int { return cmp.Compare(b.DGAScore, a.DGAScore) })
+	}
+	return truncate(results, limit), nil
+}
+
+// MatchIOCs returns active indicators of one type whose value matches an RE2
+// regular expression. Every stored row counts against maxRows (0 =
+// unlimited), as ClickHouse reads the whole table.
+func (s *IOCStore) MatchIOCs(ctx context.Context, pattern string, iocType models.IOCType, minDGAScore uint8, limit, maxRows int) ([]models.IOC, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search IOCs: %w", err)
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if maxRows > 0 && len(s.iocs) > maxRows {
+		return nil, db.ErrScanLimit
+	}
+	results := s.search(func(row iocRow) bool {
+		return row.Type == iocType && row.DGAScore >= minDGAScore && re.MatchString(row.Value)
+	})
+	return truncate(results, limit), nil
+}
+
+// GetIndicatorsBySourceFiles returns the active IOCs extracted from the
+// given files, most widespread first
+func (s *IOCStore) GetIndicatorsBySourceFiles(ctx context.Context, fileIDs []string, limit int) ([]models.ClusterIndicator, error) {
+	if len(fileIDs) == 0 {
+		return nil, nil
+	}
+	ids := make(map[string]bool, len(fileIDs))
+	for _, id := range fileIDs {
+		ids[id] = true
+	}
+
+	type key struct {
+		value   string
+		iocType models.IOCType
+	}
+
+	s.mu.RLock()
+	files := make(map[key]map[string]bool)
+	family := make(map[key]string)
+	for _, row := range s.iocs {
+		if row.deprecated || !ids[row.SourceFileID] {
+			continue
+		}
+		k := key{row.Value, row.Type}
+		if files[k] == nil {
+			files[k] = make(map[string]bool)
+		}
+		files[k][row.SourceFileID] = true
+		if family[k] == "" && row.MalwareFamily != "" && row.MalwareFamily != "Unknown" {
+			family[k] = row.MalwareFamily
+		}
+	}
+	s.mu.RUnlock()
+
+	results := make([]models.ClusterIndicator, 0, len(files))
+	for k, f := range files {
+		results = append(results, models.ClusterIndicator{
+			Value: k.value, Type: k.iocType, Files: len(f), MalwareFamily: family[k],
+		})
+	}
+	slices.SortFunc(results, func(a, b models.ClusterIndicator) int {
+		return cmp.Or(cmp.Compare(b.Files, a.Files), cmp.Compare(a.Value, b.Value))
+	})
+	return truncate(results, limit), nil
+}
+
+// DeprecateIOC marks every active row of a value as deprecated and returns
+// how many there were
+func (s *IOCStore) DeprecateIOC(ctx context.Context, value string) (uint64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var n uint64
+	for i := range s.iocs {
+		if s.iocs[i].Value == value && !s.iocs[i].deprecated {
+			s.iocs[i].deprecated = true
+			n++
+		}
+	}
+	return n, nil
+}
+
+// ListPendingIOCs returns the values awaiting review, aggregated per value,
+// longest waiting first, and how many there are in all
+func (s *IOCStore) ListPendingIOCs(ctx context.Context, iocType models.IOCType, limit, offset int) ([]models.IOC, uint64, error) {
+	s.mu.RLock()
+	var pending []models.IOC
+	for _, rows := range s.group(func(row iocRow) bool { return row.Pending && (iocType == "" || row.Type == iocType) }, false) {
+		ioc := aggregate(rows)
+		for _, row := range rows {
+			ioc.Confidence = max(ioc.Confidence, row.Confidence)
+		}
+		pending = append(pending, ioc)
+	}
+	s.mu.RUnlock()
+
+	slices.SortFunc(pending, func(a, b models.IOC) int {
+		return cmp.Or(a.FirstSeen.Compare(b.FirstSeen), cmp.Compare(a.Value, b.Value))
+	})
+	total := uint64(len(pending))
+	if offset >= len(pending) {
+		return []models.IOC{}, total, nil
+	}
+	return truncate(pending[offset:], limit), total, nil
+}
+
+// ListIOCsBySourceFile returns the active IOCs extracted from one file,
+// aggregated per value and type and ordered by type then value
+func (s *IOCStore) ListIOCsBySourceFile(ctx context.Context, fileID string, limit, offset int) ([]models.IOC, uint64, error) {
+	s.mu.RLock()
+	var iocs []models.IOC
+	for _, rows := range s.group(func(row iocRow) bool { return row.SourceFileID == fileID }, true) {
+		ioc := aggregate(rows)
+		for _, row := range rows {
+			ioc.Confidence = max(ioc.Confidence, row.Confidence)
+		}
+		iocs = append(iocs, ioc)
+	}
+	s.mu.RUnlock()
+
+	slices.SortFunc(iocs, func(a, b models.IOC) int {
+		return cmp.Or(cmp.Compare(a.Type, b.Type), cmp.Compare(a.Value, b.Value))
+	})
+	total := uint64(len(iocs))
+	if offset >= len(iocs) {
+		return []models.IOC{}, total, nil
+	}
+	return truncate(iocs[offset:], limit), total, nil
+}
+
+// ListOrphanedIOCSources returns up to limit file IDs active IOCs ingested
+// before the cutoff were extracted from that have no active registry entry,
+// and how many there are. Sources not shaped like a file ID are left out.
+func (s *IOCStore) ListOrphanedIOCSources(ctx context.Context, before time.Time, limit int) ([]string, uint64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	orphaned := make(map[string]bool)
+	for _, row := range s.iocs {
+		if row.deprecated || !row.ingestedAt.Before(before) || !isFileID(row.SourceFileID) {
+			continue
+		}
+		if meta, ok := s.files[row.SourceFileID]; !ok || meta.ScanStatus == models.ScanStatusDeleted {
+			orphaned[row.SourceFileID] = true
+		}
+	}
+	ids := slices.Sorted(maps.Keys(orphaned))
+	return truncate(ids, limit), uint64(len(ids)), nil
+}
+
+// isFileID reports whether a source is shaped like a file ID: 64 lowercase
+// hex digits
+func isFileID(source string) bool {
+	if len(source) != 64 {
+		return false
+	}
+	for i := 0; i < len(source); i++ {
+		if c := source[i]; (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// ReviewIOCs approves or rejects the pending rows of values and returns how
+// many rows were affected
+func (s *IOCStore) ReviewIOCs(ctx context.Context, values []string, approve bool) (uint64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var n uint64
+	for i := range s.iocs {
+		row := &s.iocs[i]
+		if !row.Pending || row.deprecated || !slices.Contains(values, row.Value) {
+			continue
+		}
+		if approve {
+			row.Pending = false
+		} else {
+			row.deprecated = true
+		}
+		n++
+	}
+	return n, nil
+}
+
+// CountIOCs returns how many active rows filter selects
+func (s *IOCStore) CountIOCs(ctx context.Context, filter models.IOCFilter) (uint64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var n uint64
+	for _, row := range s.iocs {
+		if row.matches(filter) {
+			n++
+		}
+	}
+	return n, nil
+}
+
+// BulkUpdateIOCs applies update to every active row filter selects and
+// returns how many there were
+func (s *IOCStore) BulkUpdateIOCs(ctx context.Context, filter models.IOCFilter, update models.IOCUpdate) (uint64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var n uint64
+	for i := range s.iocs {
+		row := &s.iocs[i]
+		if !row.matches(filter) {
+			continue
+		}
+		n++
+
+		tags := slices.DeleteFunc(slices.Clone(row.Tags), func(t string) bool {
+			return slices.Contains(update.RemoveTags, t)
+		})
+		for _, t := range update.AddTags {
+			if !slices.Contains(tags, t) {
+				tags = append(tags, t)
+			}
+		}
+		row.Tags = tags
+		if update.Confidence != nil {
+			row.Confidence = *update.Confidence
+		}
+		if update.MalwareFamily != "" {
+			row.MalwareFamily = update.MalwareFamily
+		}
+	}
+	return n, nil
+}
+
+// ListIOCFilterValues returns up to limit distinct values among the active
+// rows filter selects
+func (s *IOCStore) ListIOCFilterValues(ctx context.Context, filter models.IOCFilter, limit int) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var values []string
+	for _, row := range s.iocs {
+		if len(values) == limit {
+			break
+		}
+		if row.matches(filter) && !slices.Contains(values, row.Value) {
+			values = append(values, row.Value)
+		}
+	}
+	return values, nil
+}
+
+// matches reports whether an active row is selected by a bulk filter
+func (r iocRow) matches(f models.IOCFilter) bool {
+	switch {
+	case r.deprecated, r.SourceFileID == db.SelfTestSourceID:
+		return false
+	case len(f.Tags) > 0 && !slices.ContainsFunc(f.Tags, func(t string) bool { return slices.Contains(r.Tags, t) }):
+		return false
+	case len(f.SourceFileIDs) > 0 && !slices.Contains(f.SourceFileIDs, r.SourceFileID):
+		return false
+	case f.Type != "" && r.Type != f.Type:
+		return false
+	case f.Since != nil && r.LastSeen.Before(*f.Since):
+		return false
+	case f.Until != nil && !r.LastSeen.Before(*f.Until):
+		return false
+	}
+	return true
+}
+
+// DeprecateIOCsBySource marks all rows extracted from the given files as
+// deprecated and returns the distinct values that had active rows among them
+func (s *IOCStore) DeprecateIOCsBySource(ctx context.Context, fileIDs []string) ([]string, error) {
+	return s.DeleteIOCs(ctx, nil, fileIDs, false)
+}
+
+// DeleteIOCs deprecates, or removes when purge is set, the rows holding
+// values or extracted from fileIDs, and returns the distinct values that had
+// rows: active rows when deprecating, any when purging
+func (s *IOCStore) DeleteIOCs(ctx context.Context, values, fileIDs []string, purge bool) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	match := func(row iocRow) bool {
+		return (slices.Contains(values, row.Value) || slices.Contains(fileIDs, row.SourceFileID)) &&
+			(purge || !row.deprecated)
+	}
+	affected := make(map[string]bool)
+	for i := range s.iocs {
+		if match(s.iocs[i]) {
+			affected[s.iocs[i].Value] = true
+			s.iocs[i].deprecated = true
+		}
+	}
+	if purge {
+		s.iocs = slices.DeleteFunc(s.iocs, match)
+	}
+	return slices.Sorted(maps.Keys(affected)), nil
+}
+
+// GetSealedValues returns the sealed original stored with each active
+// redacted value that has one
+func (s *IOCStore) GetSealedValues(ctx context.Context, values []string) (map[string]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := make(map[string]string)
+	for _, row := range s.iocs {
+		if row.Sealed != "" && !row.deprecated && slices.Contains(values, row.Value) {
+			result[row.Value] = row.Sealed
+		}
+	}
+	return result, nil
+}
+
+// GetIOCSources returns the sources of each value, most recently seen
+// first, at most limit per value, and how many each has in all
+func (s *IOCStore) GetIOCSources(ctx context.Context, values []string, limit int) (map[string][]models.IOCSource, map[string]int, error) {
+	sources := make(map[string][]models.IOCSource)
+	totals := make(map[string]int)
+	if limit <= 0 {
+		return sources, totals, nil
+	}
+
+	s.mu.RLock()
+	type key struct{ value, source string }
+	merged := make(map[key]*models.IOCSource)
+	for _, row := range s.iocs {
+		if row.deprecated || !slices.Contains(values, row.Value) {
+			continue
+		}
+		k := key{row.Value, row.SourceFileID}
+		src, ok := merged[k]
+		if !ok {
+			src = &models.IOCSource{SourceFileID: row.SourceFileID, FirstSeen: row.FirstSeen}
+			merged[k] = src
+		}
+		if row.FirstSeen.Before(src.FirstSeen) {
+			src.FirstSeen = row.FirstSeen
+		}
+		if !row.LastSeen.Before(src.LastSeen) {
+			src.LastSeen = row.LastSeen
+			src.MalwareFamily = row.MalwareFamily
+			src.Confidence = row.Confidence
+			src.Feed = ""
+			for _, tag := range row.Tags {
+				if feed, ok := strings.CutPrefix(tag, "source:"); ok {
+					src.Feed = feed
+					break
+				}
+			}
+		}
+	}
+	s.mu.RUnlock()
+
+	for k, src := range merged {
+		sources[k.value] = append(sources[k.value], *src)
+		totals[k.value]++
+	}
+	for value, list := range sources {
+		slices.SortFunc(list, func(a, b models.IOCSource) int {
+			if c := b.LastSeen.Compare(a.LastSeen); c != 0 {
+				return c
+			}
+			return cmp.Compare(a.SourceFileID, b.SourceFileID)
+		})
+		if len(list) > limit {
+			sources[value] = list[:limit]
+		}
+	}
+	return sources, totals, nil
+}
+
+// DeleteSelfTestIOCs removes all synthetic self-test rows
+func (s *IOCStore) DeleteSelfTestIOCs(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.iocs = slices.DeleteFunc(s.iocs, func(row iocRow) bool { return row.SourceFileID == db.SelfTestSourceID })
+	return nil
+}
+
+// StreamActiveIOCValues calls fn with batches of active values last seen at
+// or after since
+func (s *IOCStore) StreamActiveIOCValues(ctx context.Context, since time.Time, batchSize int, fn func([]string) error) error {
+	s.mu.RLock()
+	var values []string
+	for _, row := range s.iocs {
+		if !row.deprecated && !row.LastSeen.Before(since) {
+			values = append(values, row.Value)
+		}
+	}
+	s.mu.RUnlock()
+
+	for start := 0; start < len(values); start += batchSize {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := fn(values[start:min(start+batchSize, len(values))]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetIOCsAfter returns up to limit active rows ingested after cursor, in
+// (ingested_at, ioc_value, source_file_id) order. Unlike ClickHouse, rows are
+// visible as soon as they are inserted.
+func (s *IOCStore) GetIOCsAfter(ctx context.Context, cursor models.SyncCursor, limit int) ([]models.SyncIOC, error) {
+	s.mu.RLock()
+	var rows []models.SyncIOC
+	for _, row := range s.iocs {
+		if row.deprecated || row.SourceFileID == db.SelfTestSourceID {
+			continue
+		}
+		rows = append(rows, models.SyncIOC{IOC: row.IOC, IngestedAt: row.ingestedAt})
+	}
+	s.mu.RUnlock()
+
+	order := func(a models.SyncIOC, t time.Time, value, source string) int {
+		return cmp.Or(a.IngestedAt.Compare(t), cmp.Compare(a.Value, value), cmp.Compare(a.SourceFileID, source))
+	}
+	slices.SortFunc(rows, func(a, b models.SyncIOC) int { return order(a, b.IngestedAt, b.Value, b.SourceFileID) })
+
+	after := slices.IndexFunc(rows, func(row models.SyncIOC) bool {
+		return order(row, cursor.IngestedAt, cursor.Value, cursor.SourceFileID) > 0
+	})
+	if after < 0 {
+		return []models.SyncIOC{}, nil
+	}
+	return truncate(rows[after:], limit), nil
+}
+
+// ExportIOCsParquet is not supported
+func (s *IOCStore) ExportIOCsParquet(ctx context.Context) (io.ReadCloser, error) {
+	return nil, ErrUnsupported
+}
+
+// group collects the rows accepted by keep per value, and per type when
+// byType is set, in first-inserted order. Deprecated rows are skipped.
+// Callers hold s.mu.
+func (s *IOCStore) group(keep func(iocRow) bool, byType bool) [][]iocRow {
+	index := make(map[string]int)
+	var groups [][]iocRow
+	for _, row := range s.iocs {
+		if row.deprecated || !keep(row) {
+			continue
+		}
+		key := row.Value
+		if byType {
+			key += "\x00" + string(row.Type)
+		}
+		i, ok := index[key]
+		if !ok {
+			i = len(groups)
+			index[key] = i
+			groups = append(groups, nil)
+		}
+		groups[i] = append(groups[i], row)
+	}
+	return groups
+}
+
+// search aggregates the rows accepted by keep per value and type, ordered by
+// value and type. Callers hold s.mu.
+func (s *IOCStore) search(keep func(iocRow) bool) []models.IOC {
+	var results []models.IOC
+	for _, rows := range s.group(keep, true) {
+		results = append(results, aggregate(rows))
+	}
+	slices.SortFunc(results, func(a, b models.IOC) int {
+		return cmp.Or(cmp.Compare(a.Value, b.Value), cmp.Compare(a.Type, b.Type))
+	})
+	return results
+}
+
+// aggregate merges the sightings of one value like StreamIOCs in ClickHouse:
+// attributes of the newest sighting, first_seen/last_seen spanning all of
+// them, summed hit counts and merged tags. It is pending only while every
+// sighting is.
+func aggregate(rows []iocRow) models.IOC {
+	newest := rows[0]
+	for _, row := range rows[1:] {
+		if row.LastSeen.After(newest.LastSeen) {
+			newest = row
+		}
+	}
+
+	ioc := newest.IOC
+	ioc.Tags = nil
+	var hits uint64
+	for _, row := range rows {
+		ioc.Pending = ioc.Pending && row.Pending
+		if row.FirstSeen.Before(ioc.FirstSeen) {
+			ioc.FirstSeen = row.FirstSeen
+		}
+		ioc.DGAScore = max(ioc.DGAScore, row.DGAScore)
+		hits += uint64(row.HitCount)
+		for _, tag := range row.Tags {
+			if !slices.Contains(ioc.Tags, tag) {
+				ioc.Tags = append(ioc.Tags, tag)
+			}
+		}
+	}
+	ioc.HitCount = uint32(min(hits, math.MaxUint32))
+	return ioc
+}
+
+// truncate returns at most limit items (all when limit <= 0)
+func truncate[T any](items []T, limit int) []T {
+	if limit > 0 && len(items) > limit {
+		return items[:limit]
+	}
+	return items
+}
+
+// ========== Domain Resolution and WHOIS ==========
+
+// ListDomainsDueForResolution returns active domains not resolved since
+// olderThan
+func (s *IOCStore) ListDomainsDueForResolution(ctx context.Context, olderThan time.Time, limit int) ([]models.IOC, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var domains []models.IOC
+	for _, rows := range s.group(func(row iocRow) bool { return row.Type == models.IOCTypeDomain }, false) {
+		if r, ok := s.resolutions[rows[0].Value]; ok && !r.ResolvedAt.Before(olderThan) {
+			continue
+		}
+		ioc := models.IOC{Value: rows[0].Value, Type: models.IOCTypeDomain, MalwareFamily: rows[0].MalwareFamily}
+		for _, row := range rows {
+			ioc.Confidence = max(ioc.Confidence, row.Confidence)
+		}
+		domains = append(domains, ioc)
+	}
+	return truncate(domains, limit), nil
+}
+
+// InsertDomainResolutions records resolution results
+func (s *IOCStore) InsertDomainResolutions(ctx context.Context, resolutions []models.DomainResolution) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, r := range resolutions {
+		if prev, ok := s.resolutions[r.Domain]; !ok || !r.ResolvedAt.Before(prev.ResolvedAt) {
+			s.resolutions[r.Domain] = r
+		}
+	}
+	return nil
+}
+
+// GetDomainResolutions returns the latest resolution of each known domain
+func (s *IOCStore) GetDomainResolutions(ctx context.Context, domains []string) (map[string]models.DomainResolution, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := make(map[string]models.DomainResolution)
+	for _, d := range domains {
+		if r, ok := s.resolutions[d]; ok {
+			result[d] = r
+		}
+	}
+	return result, nil
+}
+
+// InsertWhoisRelationships records registration data of looked-up domains
+func (s *IOCStore) InsertWhoisRelationships(ctx context.Context, rels []models.WhoisRelationship) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.whois = append(s.whois, rels...)
+	return nil
+}
+
+// GetWhoisRelatedDomains returns the domains seen with a registrar,
+// nameserver or registrant email, most recently observed first
+func (s *IOCStore) GetWhoisRelatedDomains(ctx context.Context, relation, value string, limit int) ([]models.WhoisRelationship, error) {
+	s.mu.RLock()
+	latest := make(map[string]time.Time)
+	for _, r := range s.whois {
+		if r.Relation == relation && r.Value == value && r.ObservedAt.After(latest[r.Domain]) {
+			latest[r.Domain] = r.ObservedAt
+		}
+	}
+	s.mu.RUnlock()
+
+	var rels []models.WhoisRelationship
+	for domain, observed := range latest {
+		rels = append(rels, models.WhoisRelationship{Domain: domain, Relation: relation, Value: value, ObservedAt: observed})
+	}
+	slices.SortFunc(rels, func(a, b models.WhoisRelationship) int {
+		return cmp.Or(b.ObservedAt.Compare(a.ObservedAt), cmp.Compare(a.Domain, b.Domain))
+	})
+	return truncate(rels, limit), nil
+}
+
+// ========== Extraction Samples ==========
+
+// InsertExtractionSamples stores sampled extractions, replacing earlier
+// versions of relabeled ones
+func (s *IOCStore) InsertExtractionSamples(ctx context.Context, samples []models.ExtractionSample) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, sample := range samples {
+		s.samples[sample.ID] = sample
+	}
+	return nil
+}
+
+// ListExtractionSamples returns a page of samples, oldest first, and how
+// many there are in all
+func (s *IOCStore) ListExtractionSamples(ctx context.Context, iocType models.IOCType, status string, limit, offset int) ([]models.ExtractionSample, uint64, error) {
+	s.mu.RLock()
+	var samples []models.ExtractionSample
+	for _, sample := range s.samples {
+		if iocType != "" && sample.Type != iocType {
+			continue
+		}
+		if status != "" && (sample.Label != "") != (status == models.SampleStatusLabeled) {
+			continue
+		}
+		samples = append(samples, sample)
+	}
+	s.mu.RUnlock()
+
+	slices.SortFunc(samples, func(a, b models.ExtractionSample) int {
+		return cmp.Or(a.SampledAt.Compare(b.SampledAt), cmp.Compare(a.ID, b.ID))
+	})
+	total := uint64(len(samples))
+	if offset >= len(samples) {
+		return []models.ExtractionSample{}, total, nil
+	}
+	return truncate(samples[offset:], limit), total, nil
+}
+
+// GetExtractionSamples returns the samples with the given IDs, by ID
+func (s *IOCStore) GetExtractionSamples(ctx context.Context, ids []string) (map[string]models.ExtractionSample, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := make(map[string]models.ExtractionSample)
+	for _, id := range ids {
+		if sample, ok := s.samples[id]; ok {
+			result[id] = sample
+		}
+	}
+	return result, nil
+}
+
+// GetSamplePrecision estimates the precision of each IOC type per day or
+// week sampled or per extractor version
+func (s *IOCStore) GetSamplePrecision(ctx context.Context, groupBy string, iocType models.IOCType, since time.Time) ([]models.PrecisionEstimate, error) {
+	if !slices.Contains([]string{models.PrecisionByDay, models.PrecisionByWeek, models.PrecisionByVersion}, groupBy) {
+		return nil, fmt.Errorf("unknown precision grouping %q", groupBy)
+	}
+
+	type key struct {
+		t     models.IOCType
+		group string
+	}
+	counts := make(map[key][2]uint64)
+
+	s.mu.RLock()
+	for _, sample := range s.samples {
+		if sample.Label == "" || sample.SampledAt.Before(since) || (iocType != "" && sample.Type != iocType) {
+			continue
+		}
+		k := key{t: sample.Type}
+		day := sample.SampledAt.UTC().Truncate(24 * time.Hour)
+		switch groupBy {
+		case models.PrecisionByDay:
+			k.group = day.Format(time.DateOnly)
+		case models.PrecisionByWeek:
+			k.group = day.AddDate(0, 0, -(int(day.Weekday())+6)%7).Format(time.DateOnly)
+		case models.PrecisionByVersion:
+			k.group = sample.ExtractorVersion
+		}
+		c := counts[k]
+		switch sample.Label {
+		case models.LabelTruePositive:
+			c[0]++
+		case models.LabelFalsePositive:
+			c[1]++
+		}
+		counts[k] = c
+	}
+	s.mu.RUnlock()
+
+	estimates := []models.PrecisionEstimate{}
+	for k, c := range counts {
+		estimates = append(estimates, models.NewPrecisionEstimate(k.t, k.group, c[0], c[1]))
+	}
+	slices.SortFunc(estimates, func(a, b models.PrecisionEstimate) int {
+		return cmp.Or(cmp.Compare(a.Type, b.Type), cmp.Compare(a.Group, b.Group))
+	})
+	return estimates, nil
+}
+
+// ========== Logs and Statistics ==========
+
+// InsertAuditEntry records an administrative action
+func (s *IOCStore) InsertAuditEntry(ctx context.Context, entry models.AuditEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.audit = append(s.audit, entry)
+	return nil
+}
+
+// InsertQueryLog records a lookup request
+func (s *IOCStore) InsertQueryLog(ctx context.Context, entry models.QueryLogEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.queries = append(s.queries, entry)
+	return nil
+}
+
+// GetTopLookups ranks the given dimension over lookups made since since
+func (s *IOCStore) GetTopLookups(ctx context.Context, dimension string, since time.Time, limit int) ([]models.TopEntry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	counts := make(map[string]*models.TopEntry)
+	count := func(key string) *models.TopEntry {
+		if counts[key] == nil {
+			counts[key] = &models.TopEntry{Key: key}
+		}
+		return counts[key]
+	}
+
+	for _, q := range s.queries {
+		if q.Timestamp.Before(since) {
+			continue
+		}
+		switch dimension {
+		case models.TopDimensionQueried:
+			for _, v := range q.IOCsQueried {
+				e := count(v)
+				e.Count++
+				if slices.Contains(q.IOCsFound, v) {
+					e.Found++
+				}
+			}
+		case models.TopDimensionMalwareFamily:
+			for _, rows := range s.group(func(row iocRow) bool { return slices.Contains(q.IOCsFound, row.Value) }, false) {
+				count(aggregate(rows).MalwareFamily).Count++
+			}
+		case models.TopDimensionSourceFile:
+			credited := make(map[[2]string]bool)
+			for _, row := range s.iocs {
+				k := [2]string{row.Value, row.SourceFileID}
+				if !row.deprecated && !credited[k] && slices.Contains(q.IOCsFound, row.Value) {
+					credited[k] = true
+					e := count(row.SourceFileID)
+					e.Count++
+					e.FilePath = s.files[row.SourceFileID].FilePath
+				}
+			}
+		default:
+			return nil, fmt.Errorf("unknown top dimension %q", dimension)
+		}
+	}
+
+	entries := []models.TopEntry{}
+	for _, e := range counts {
+		entries = append(entries, *e)
+	}
+	slices.SortFunc(entries, func(a, b models.TopEntry) int {
+		return cmp.Or(cmp.Compare(b.Count, a.Count), cmp.Compare(a.Key, b.Key))
+	})
+	return truncate(entries, limit), nil
+}
+
+// GetIOCStats counts stored rows by type
+func (s *IOCStore) GetIOCStats(ctx context.Context) (map[models.IOCType]int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	stats := make(map[models.IOCType]int64)
+	for _, row := range s.iocs {
+		stats[row.Type]++
+	}
+	return stats, nil
+}
+
+// GetFileStats counts registry entries by status
+func (s *IOCStore) GetFileStats(ctx context.Context) (map[models.ScanStatus]int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	stats := make(map[models.ScanStatus]int64)
+	for _, meta := range s.files {
+		stats[meta.ScanStatus]++
+	}
+	return stats, nil
+}
+
+// GetFreshnessStats returns the age distribution of active IOCs by type, the
+// number past their validity window, and the staleness of up to feedLimit
+// feed documents of the given formats
+func (s *IOCStore) GetFreshnessStats(ctx context.Context, feedFormats []string, feedLimit int) (*models.FreshnessStats, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	now := time.Now()
+	labels := db.AgeBucketLabels()
+	stats := &models.FreshnessStats{
+		Buckets:      labels,
+		FirstSeenAge: make(map[models.IOCType][]int64),
+		LastSeenAge:  make(map[models.IOCType][]int64),
+		Expired:      make(map[models.IOCType]int64),
+		Feeds:        []models.FeedFreshness{},
+	}
+
+	// Buckets as in ClickHouse: under a day, then powers of two of days
+	bucket := func(t time.Time) int {
+		days := int(now.Sub(t).Hours() / 24)
+		if days < 1 {
+			return 0
+		}
+		return min(int(math.Log2(float64(days)))+1, len(labels)-1)
+	}
+	record := func(hist map[models.IOCType][]int64, t models.IOCType, at time.Time) {
+		if hist[t] == nil {
+			hist[t] = make([]int64, len(labels))
+		}
+		hist[t][bucket(at)]++
+	}
+
+	type feedIOCs struct {
+		newest  *time.Time
+		expired uint64
+	}
+	bySource := make(map[string]*feedIOCs)
+
+	for _, row := range s.iocs {
+		if row.deprecated {
+			continue
+		}
+		record(stats.FirstSeenAge, row.Type, row.FirstSeen)
+		record(stats.LastSeenAge, row.Type, row.LastSeen)
+
+		expired := row.ValidUntil != nil && row.ValidUntil.Before(now)
+		if expired {
+			stats.Expired[row.Type]++
+		}
+
+		f := bySource[row.SourceFileID]
+		if f == nil {
+			f = &feedIOCs{}
+			bySource[row.SourceFileID] = f
+		}
+		if f.newest == nil || row.FirstSeen.After(*f.newest) {
+			firstSeen := row.FirstSeen
+			f.newest = &firstSeen
+		}
+		if expired {
+			f.expired++
+		}
+	}
+
+	if len(feedFormats) == 0 || feedLimit <= 0 {
+		return stats, nil
+	}
+
+	for _, meta := range s.files {
+		if !slices.Contains(feedFormats, meta.FileType) || meta.ScanStatus == models.ScanStatusDeleted {
+			continue
+		}
+		feed := models.FeedFreshness{
+			FileID:       meta.FileID,
+			FilePath:     meta.FilePath,
+			Format:       meta.FileType,
+			LastModified: meta.LastModified,
+			StaleDays:    int64(now.Sub(meta.LastModified).Hours() / 24),
+			IOCCount:     meta.IOCCount,
+		}
+		if f := bySource[meta.FileID]; f != nil {
+			feed.NewestIndicator = f.newest
+			feed.Expired = f.expired
+		}
+		stats.Feeds = append(stats.Feeds, feed)
+	}
+	slices.SortFunc(stats.Feeds, func(a, b models.FeedFreshness) int { return a.LastModified.Compare(b.LastModified) })
+	stats.Feeds = truncate(stats.Feeds, feedLimit)
+
+	return stats, nil
+}
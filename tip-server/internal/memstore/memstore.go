@@ -0,0 +1,25 @@
+// Package memstore provides in-memory implementations of the storage
+// interfaces of package db, so the API server, the ingestor and background
+// jobs can run without ClickHouse, Redis or MinIO. They keep everything in
+// process memory and are meant for tests and local experiments, not for
+// production volumes.
+package memstore
+
+import (
+	"errors"
+
+	"tip-server/internal/db"
+)
+
+// ErrUnsupported is returned by operations that need the real backend
+var ErrUnsupported = errors.New("not supported by the in-memory store")
+
+// NewClients returns empty in-memory backends. Qdrant is left unset, so
+// similarity search and vector clustering are disabled.
+func NewClients() *db.Clients {
+	return &db.Clients{
+		ClickHouse: NewIOCStore(),
+		Redis:      NewCache(),
+		MinIO:      NewObjectStore(),
+	}
+}
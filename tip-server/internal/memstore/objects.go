@@ -0,0 +1,150 @@
+package memstore
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+
+	"tip-server/internal/db"
+)
+
+// ObjectStore is an in-memory db.ObjectStore. Content is stored
+// uncompressed; quarantined samples share the one bucket.
+type ObjectStore struct {
+	mu      sync.RWMutex
+	objects map[string]storedObject
+}
+
+// storedObject is an object's content and metadata
+type storedObject struct {
+	content []byte
+	info    minio.ObjectInfo
+}
+
+// NewObjectStore returns an empty object store
+func NewObjectStore() *ObjectStore {
+	return &ObjectStore{objects: make(map[string]storedObject)}
+}
+
+var _ db.ObjectStore = (*ObjectStore)(nil)
+
+// Ping always succeeds
+func (o *ObjectStore) Ping(ctx context.Context) error { return nil }
+
+// put stores content under objectName
+func (o *ObjectStore) put(objectName string, content []byte, contentType string, meta map[string]string) *minio.UploadInfo {
+	sum := md5.Sum(content)
+	etag := hex.EncodeToString(sum[:])
+	now := time.Now()
+
+	header := http.Header{}
+	header.Set("Content-Type", contentType)
+
+	o.mu.Lock()
+	o.objects[objectName] = storedObject{
+		content: content,
+		info: minio.ObjectInfo{
+			Key:          objectName,
+			Size:         int64(len(content)),
+			ETag:         etag,
+			ContentType:  contentType,
+			LastModified: now,
+			Metadata:     header,
+			UserMetadata: meta,
+		},
+	}
+	o.mu.Unlock()
+
+	return &minio.UploadInfo{Key: objectName, ETag: etag, Size: int64(len(content)), LastModified: now}
+}
+
+// UploadBytes stores byte content with the SHA-256 of the content in its
+// user metadata
+func (o *ObjectStore) UploadBytes(ctx context.Context, objectName string, content []byte, contentType string) (*minio.UploadInfo, error) {
+	meta := map[string]string{db.MetaContentSHA256: db.ContentHash(content)}
+	return o.put(objectName, bytes.Clone(content), contentType, meta), nil
+}
+
+// UploadQuarantined stores a malware sample marked with db.MetaQuarantine
+func (o *ObjectStore) UploadQuarantined(ctx context.Context, objectName string, content []byte) (*minio.UploadInfo, error) {
+	meta := map[string]string{
+		db.MetaQuarantine:    "true",
+		db.MetaContentSHA256: db.ContentHash(content),
+	}
+	return o.put(objectName, bytes.Clone(content), "application/octet-stream", meta), nil
+}
+
+// UploadStream stores the content of a reader
+func (o *ObjectStore) UploadStream(ctx context.Context, objectName string, reader io.Reader, contentType string) (*minio.UploadInfo, error) {
+	content, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to upload stream: %w", err)
+	}
+	return o.put(objectName, content, contentType, nil), nil
+}
+
+// OpenObject opens an object for reading
+func (o *ObjectStore) OpenObject(ctx context.Context, objectName string) (io.ReadCloser, minio.ObjectInfo, int64, error) {
+	o.mu.RLock()
+	obj, ok := o.objects[objectName]
+	o.mu.RUnlock()
+
+	if !ok {
+		return nil, minio.ObjectInfo{}, 0, fmt.Errorf("failed to stat object: %w", minio.ErrorResponse{
+			StatusCode: http.StatusNotFound,
+			Code:       "NoSuchKey",
+			Key:        objectName,
+			Message:    "The specified key does not exist.",
+		})
+	}
+	return io.NopCloser(bytes.NewReader(obj.content)), obj.info, obj.info.Size, nil
+}
+
+// ObjectExists checks if an object exists
+func (o *ObjectStore) ObjectExists(ctx context.Context, objectName string) (bool, error) {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+
+	_, ok := o.objects[objectName]
+	return ok, nil
+}
+
+// DeleteObject deletes an object; deleting a missing one is not an error
+func (o *ObjectStore) DeleteObject(ctx context.Context, objectName string) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	delete(o.objects, objectName)
+	return nil
+}
+
+// ListObjects lists objects with a prefix in key order
+func (o *ObjectStore) ListObjects(ctx context.Context, prefix string) <-chan minio.ObjectInfo {
+	o.mu.RLock()
+	var infos []minio.ObjectInfo
+	for key, obj := range o.objects {
+		if strings.HasPrefix(key, prefix) {
+			infos = append(infos, obj.info)
+		}
+	}
+	o.mu.RUnlock()
+
+	slices.SortFunc(infos, func(a, b minio.ObjectInfo) int { return strings.Compare(a.Key, b.Key) })
+
+	ch := make(chan minio.ObjectInfo, len(infos))
+	for _, info := range infos {
+		ch <- info
+	}
+	close(ch)
+	return ch
+}
@@ -1,6 +1,8 @@
 package metrics
 
 import (
+	"strconv"
+
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
 )
@@ -12,20 +14,59 @@ type Metrics struct {
 	FilesSkipped     prometheus.Counter
 	FilesFailed      prometheus.Counter
 	IOCsExtracted    *prometheus.CounterVec
+	IOCsDropped      *prometheus.CounterVec
+	FilesByHandler   *prometheus.CounterVec
+	ClamAVScans      *prometheus.CounterVec
 	BytesProcessed   prometheus.Counter
 	ProcessingTime   *prometheus.HistogramVec
 	ActiveWorkers    prometheus.Gauge
+	OrphanExtracts   prometheus.Gauge
 	BatchInsertTime  prometheus.Histogram
 	BatchInsertSize  prometheus.Histogram
+	BloomAddTime     prometheus.Histogram
+	BloomAddSize     prometheus.Histogram
+	BloomAddFailures prometheus.Counter
+
+	// Log listener metrics
+	LogMessages *prometheus.CounterVec
+	LogMatches  *prometheus.CounterVec
+
+	// Enrichment metrics
+	EnrichmentLookups *prometheus.CounterVec
+	EnrichmentLatency *prometheus.HistogramVec
+
+	// Similarity index metrics
+	FilesEmbedded *prometheus.CounterVec
 
 	// API metrics
 	APIRequests      *prometheus.CounterVec
 	APILatency       *prometheus.HistogramVec
 	BloomFilterHits  prometheus.Counter
 	BloomFilterMisses prometheus.Counter
+	BloomFalsePositives prometheus.Counter
+	CheckOutcomes     *prometheus.CounterVec
+	CheckDegraded     prometheus.Counter
+	CheckCoalesced    prometheus.Counter
 	ClickHouseQueries *prometheus.CounterVec
 	ClickHouseLatency prometheus.Histogram
 
+	// Self-test metrics
+	SelfTestPassed   prometheus.Gauge
+	SelfTestLastRun  prometheus.Gauge
+	SelfTestFailures *prometheus.CounterVec
+
+	// Replica sync metrics
+	ReplicaSyncIOCs prometheus.Counter
+	ReplicaSyncLag  prometheus.Gauge
+
+	// Consistency check metrics
+	ConsistencyIssues  *prometheus.GaugeVec
+	ConsistencyLastRun prometheus.Gauge
+
+	// Ingestion volume metrics
+	VolumeAnomaly *prometheus.GaugeVec
+	VolumeLastRun prometheus.Gauge
+
 	// System metrics
 	DBConnections    *prometheus.GaugeVec
 	BloomFilterSize  prometheus.Gauge
@@ -41,7 +82,7 @@ func NewMetrics() *Metrics {
 				Name: "tip_files_processed_total",
 				Help: "Total number of files processed by status",
 			},
-			[]string{"status"}, // infected, clean, misc, failed
+			[]string{"status"}, // infected, clean, misc, failed, oversized, timeout
 		),
 
 		FilesSkipped: promauto.NewCounter(
@@ -66,6 +107,14 @@ func NewMetrics() *Metrics {
 			[]string{"type"}, // ipv4, ipv6, md5, sha1, sha256, domain, url, email
 		),
 
+		IOCsDropped: promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "tip_iocs_dropped_total",
+				Help: "Total number of extracted values discarded by sanity limits or the allowlist",
+			},
+			[]string{"type", "reason"}, // reason: oversized, cap, allowlisted
+		),
+
 		BytesProcessed: promauto.NewCounter(
 			prometheus.CounterOpts{
 				Name: "tip_bytes_processed_total",
@@ -89,6 +138,13 @@ func NewMetrics() *Metrics {
 			},
 		),
 
+		OrphanExtracts: promauto.NewGauge(
+			prometheus.GaugeOpts{
+				Name: "tip_orphan_extractions",
+				Help: "Number of extractions past FILE_TIMEOUT still running in the background",
+			},
+		),
+
 		BatchInsertTime: promauto.NewHistogram(
 			prometheus.HistogramOpts{
 				Name:    "tip_batch_insert_seconds",
@@ -105,11 +161,91 @@ func NewMetrics() *Metrics {
 			},
 		),
 
+		BloomAddTime: promauto.NewHistogram(
+			prometheus.HistogramOpts{
+				Name:    "tip_bloom_add_seconds",
+				Help:    "Time spent adding a batch of IOCs to the Bloom filter, retries included",
+				Buckets: []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 1},
+			},
+		),
+
+		BloomAddSize: promauto.NewHistogram(
+			prometheus.HistogramOpts{
+				Name:    "tip_bloom_add_size",
+				Help:    "Number of values in each Bloom filter batch add",
+				Buckets: []float64{10, 100, 500, 1000, 2500, 5000, 10000, 25000},
+			},
+		),
+
+		BloomAddFailures: promauto.NewCounter(
+			prometheus.CounterOpts{
+				Name: "tip_bloom_add_failures_total",
+				Help: "Total number of values not added to the Bloom filter after all retries",
+			},
+		),
+
+		FilesByHandler: promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "tip_files_by_handler_total",
+				Help: "Total number of files processed by extraction handler and format",
+			},
+			[]string{"handler", "format"},
+		),
+
+		ClamAVScans: promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "tip_clamav_scans_total",
+				Help: "Total number of files submitted to clamd by result (clean, detected, error, skipped)",
+			},
+			[]string{"result"},
+		),
+
+		LogMessages: promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "tip_log_messages_total",
+				Help: "Total number of streamed log messages received by transport and format",
+			},
+			[]string{"transport", "format"}, // tcp, udp / plain, cef, leef, suricata, zeek
+		),
+
+		LogMatches: promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "tip_log_matches_total",
+				Help: "Total number of known IOCs seen in streamed logs by type",
+			},
+			[]string{"type"},
+		),
+
+		EnrichmentLookups: promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "tip_enrichment_lookups_total",
+				Help: "Total number of reputation enrichment lookups by provider and result",
+			},
+			[]string{"provider", "result"}, // provider: virustotal, abuseipdb, whois; result: cached, fetched, unknown, throttled, error
+		),
+
+		EnrichmentLatency: promauto.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "tip_enrichment_lookup_seconds",
+				Help:    "Latency of live (uncached) enrichment lookups by provider",
+				Buckets: []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10},
+			},
+			[]string{"provider"},
+		),
+
+		FilesEmbedded: promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "tip_files_embedded_total",
+				Help: "Total number of misc files submitted to the similarity index by result",
+			},
+			[]string{"result"}, // indexed, skipped, failed
+		),
+
 		// ========== API Metrics ==========
 		APIRequests: promauto.NewCounterVec(
 			prometheus.CounterOpts{
 				Name: "tip_api_requests_total",
-				Help: "Total number of API requests by endpoint and status",
+				Help: "Total number of API requests by endpoint, method and HTTP status code",
 			},
 			[]string{"endpoint", "method", "status"},
 		),
@@ -137,6 +273,35 @@ func NewMetrics() *Metrics {
 			},
 		),
 
+		BloomFalsePositives: promauto.NewCounter(
+			prometheus.CounterOpts{
+				Name: "tip_bloom_filter_false_positives_total",
+				Help: "Total number of Bloom filter hits not found in ClickHouse",
+			},
+		),
+
+		CheckOutcomes: promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "tip_check_outcomes_total",
+				Help: "Total number of /check lookups by IOC type and outcome",
+			},
+			[]string{"type", "outcome"}, // outcome: found, not_found; type is "unknown" for unrecognised values
+		),
+
+		CheckCoalesced: promauto.NewCounter(
+			prometheus.CounterOpts{
+				Name: "tip_check_coalesced_total",
+				Help: "Total number of /check values answered by another request's in-flight ClickHouse lookup",
+			},
+		),
+
+		CheckDegraded: promauto.NewCounter(
+			prometheus.CounterOpts{
+				Name: "tip_check_degraded_total",
+				Help: "Total number of /check responses served with bloom-only verdicts because ClickHouse failed",
+			},
+		),
+
 		ClickHouseQueries: promauto.NewCounterVec(
 			prometheus.CounterOpts{
 				Name: "tip_clickhouse_queries_total",
@@ -153,6 +318,76 @@ func NewMetrics() *Metrics {
 			},
 		),
 
+		// ========== Self-test Metrics ==========
+		SelfTestPassed: promauto.NewGauge(
+			prometheus.GaugeOpts{
+				Name: "tip_selftest_passed",
+				Help: "Whether the last synthetic IOC round-trip passed (1) or failed (0)",
+			},
+		),
+
+		SelfTestLastRun: promauto.NewGauge(
+			prometheus.GaugeOpts{
+				Name: "tip_selftest_last_run_timestamp_seconds",
+				Help: "Unix time of the last synthetic IOC round-trip",
+			},
+		),
+
+		SelfTestFailures: promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "tip_selftest_failures_total",
+				Help: "Total number of failed synthetic IOC round-trips by failing step",
+			},
+			[]string{"step"}, // insert, bloom, clickhouse, check, cleanup
+		),
+
+		// ========== Replica Sync Metrics ==========
+		ReplicaSyncIOCs: promauto.NewCounter(
+			prometheus.CounterOpts{
+				Name: "tip_replica_sync_iocs_total",
+				Help: "Total number of IOC rows pulled from the primary and applied",
+			},
+		),
+
+		ReplicaSyncLag: promauto.NewGauge(
+			prometheus.GaugeOpts{
+				Name: "tip_replica_sync_lag_seconds",
+				Help: "Age of the newest IOC row applied by the last replica sync",
+			},
+		),
+
+		// ========== Consistency Check Metrics ==========
+		ConsistencyIssues: promauto.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "tip_consistency_issues",
+				Help: "Orphaned or missing entries found by the last cross-store consistency check",
+			},
+			[]string{"check"}, // bloom_missing, orphan_objects, missing_objects, orphan_iocs, missing_iocs
+		),
+
+		ConsistencyLastRun: promauto.NewGauge(
+			prometheus.GaugeOpts{
+				Name: "tip_consistency_last_run_timestamp_seconds",
+				Help: "Unix time of the last completed cross-store consistency check",
+			},
+		),
+
+		// ========== Ingestion Volume Metrics ==========
+		VolumeAnomaly: promauto.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "tip_ingestion_volume_anomaly",
+				Help: "1 while a feed's ingestion volume of an IOC type departs from its baseline",
+			},
+			[]string{"feed", "type", "kind"}, // kind: drop or spike
+		),
+
+		VolumeLastRun: promauto.NewGauge(
+			prometheus.GaugeOpts{
+				Name: "tip_ingestion_volume_last_run_timestamp_seconds",
+				Help: "Unix time of the last completed ingestion volume check",
+			},
+		),
+
 		// ========== System Metrics ==========
 		DBConnections: promauto.NewGaugeVec(
 			prometheus.GaugeOpts{
@@ -204,13 +439,14 @@ func (m *Metrics) RecordIOCsExtracted(iocType string, count int) {
 	m.IOCsExtracted.WithLabelValues(iocType).Add(float64(count))
 }
 
-// RecordAPIRequest records an API request
+// RecordIOCsDropped records values discarded by extraction limits
+func (m *Metrics) RecordIOCsDropped(iocType, reason string, count int) {
+	m.IOCsDropped.WithLabelValues(iocType, reason).Add(float64(count))
+}
+
+// RecordAPIRequest records an API request with its HTTP status code
 func (m *Metrics) RecordAPIRequest(endpoint, method string, statusCode int, durationSeconds float64) {
-	status := "success"
-	if statusCode >= 400 {
-		status = "error"
-	}
-	m.APIRequests.WithLabelValues(endpoint, method, status).Inc()
+	m.APIRequests.WithLabelValues(endpoint, method, strconv.Itoa(statusCode)).Inc()
 	m.APILatency.WithLabelValues(endpoint, method).Observe(durationSeconds)
 }
 
@@ -223,6 +459,21 @@ func (m *Metrics) RecordBloomFilterCheck(hit bool) {
 	}
 }
 
+// RecordCheckOutcome records whether a looked-up value was found
+func (m *Metrics) RecordCheckOutcome(iocType string, found bool) {
+	outcome := "not_found"
+	if found {
+		outcome = "found"
+	}
+	m.CheckOutcomes.WithLabelValues(iocType, outcome).Inc()
+}
+
+// RecordBloomAdd records a Bloom filter batch add
+func (m *Metrics) RecordBloomAdd(size int, durationSeconds float64) {
+	m.BloomAddSize.Observe(float64(size))
+	m.BloomAddTime.Observe(durationSeconds)
+}
+
 // RecordBatchInsert records a batch insert operation
 func (m *Metrics) RecordBatchInsert(size int, durationSeconds float64) {
 	m.BatchInsertSize.Observe(float64(size))
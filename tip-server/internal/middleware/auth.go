@@ -4,6 +4,7 @@ import (
 	"context"
 	"crypto/sha256"
 	"encoding/hex"
+	"strconv"
 	"strings"
 	"time"
 
@@ -16,11 +17,16 @@ import (
 
 // AuthConfig holds authentication middleware configuration
 type AuthConfig struct {
-	APIKey       string           // Static API key (for simple auth)
-	Redis        *db.RedisClient  // Redis client for rate limiting
-	RateLimit    int              // Requests per minute
-	RateWindow   time.Duration    // Rate limit window
-	SkipPaths    []string         // Paths to skip authentication
+	APIKey      string        // Static API key (for simple auth)
+	AdminAPIKey string        // Static admin API key (empty = no admin access)
+	Redis       db.Cache      // Redis client for rate limiting
+	RateLimit   int           // Requests per minute
+	RateWindow  time.Duration // Rate limit window
+	SkipPaths   []string      // Paths to skip authentication
+
+	// RateLimitFunc, when set, is consulted on every request so the limit
+	// can change at runtime (config reload). Overrides RateLimit.
+	RateLimitFunc func() int
 }
 
 // NewAuthMiddleware creates a new authentication middleware
@@ -56,32 +62,35 @@ func NewAuthMiddleware(cfg AuthConfig) fiber.Handler {
 		}
 
 		if apiKey == "" {
-			return c.Status(fiber.StatusUnauthorized).JSON(models.ErrorResponse{
-				Error: "Missing API key",
-				Code:  fiber.StatusUnauthorized,
-			})
+			return Problem(c, fiber.StatusUnauthorized, models.ErrCodeMissingAPIKey,
+				"Missing API key", "")
 		}
 
 		// Validate API key
-		if cfg.APIKey != "" && apiKey != cfg.APIKey {
+		role := RoleUser
+		if cfg.AdminAPIKey != "" && apiKey == cfg.AdminAPIKey {
+			role = RoleAdmin
+		} else if cfg.APIKey != "" && apiKey != cfg.APIKey {
 			log.Warn().
 				Str("ip", c.IP()).
 				Str("path", path).
 				Msg("Invalid API key attempt")
 
-			return c.Status(fiber.StatusUnauthorized).JSON(models.ErrorResponse{
-				Error: "Invalid API key",
-				Code:  fiber.StatusUnauthorized,
-			})
+			return Problem(c, fiber.StatusUnauthorized, models.ErrCodeInvalidAPIKey,
+				"Invalid API key", "")
 		}
 
 		// Rate limiting
-		if cfg.Redis != nil && cfg.RateLimit > 0 {
+		limit := cfg.RateLimit
+		if cfg.RateLimitFunc != nil {
+			limit = cfg.RateLimitFunc()
+		}
+		if cfg.Redis != nil && limit > 0 {
 			keyHash := hashAPIKey(apiKey)
 			count, exceeded, err := cfg.Redis.IncrementRateLimit(
 				context.Background(),
 				keyHash,
-				cfg.RateLimit,
+				limit,
 				cfg.RateWindow,
 			)
 
@@ -89,25 +98,46 @@ func NewAuthMiddleware(cfg AuthConfig) fiber.Handler {
 				log.Error().Err(err).Msg("Rate limit check failed")
 				// Continue without rate limiting on error
 			} else if exceeded {
-				remaining, _ := cfg.Redis.GetRateLimitRemaining(context.Background(), keyHash, cfg.RateLimit)
+				remaining, _ := cfg.Redis.GetRateLimitRemaining(context.Background(), keyHash, limit)
 
-				c.Set("X-RateLimit-Limit", string(rune(cfg.RateLimit)))
-				c.Set("X-RateLimit-Remaining", string(rune(remaining)))
+				c.Set("X-RateLimit-Limit", strconv.Itoa(limit))
+				c.Set("X-RateLimit-Remaining", strconv.Itoa(remaining))
 
-				return c.Status(fiber.StatusTooManyRequests).JSON(models.ErrorResponse{
-					Error:   "Rate limit exceeded",
-					Code:    fiber.StatusTooManyRequests,
-					Details: "Please slow down your requests",
-				})
+				return Problem(c, fiber.StatusTooManyRequests, models.ErrCodeRateLimitExceeded,
+					"Rate limit exceeded", "Please slow down your requests")
 			} else {
-				c.Set("X-RateLimit-Limit", string(rune(cfg.RateLimit)))
-				c.Set("X-RateLimit-Remaining", string(rune(cfg.RateLimit-int(count))))
+				c.Set("X-RateLimit-Limit", strconv.Itoa(limit))
+				c.Set("X-RateLimit-Remaining", strconv.Itoa(limit-int(count)))
 			}
 		}
 
-		// Store API key hash in context for logging
+		// Store API key hash and role in context for logging and authorization
 		c.Locals("api_key_hash", hashAPIKey(apiKey))
+		c.Locals("role", role)
+
+		return c.Next()
+	}
+}
+
+// Roles assigned by the authentication middleware
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
+// RequireAdmin rejects requests that were not authenticated with the admin key.
+// Must run after the authentication middleware.
+func RequireAdmin() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if role, _ := c.Locals("role").(string); role != RoleAdmin {
+			log.Warn().
+				Str("ip", c.IP()).
+				Str("path", c.Path()).
+				Msg("Admin endpoint access denied")
 
+			return Problem(c, fiber.StatusForbidden, models.ErrCodeAdminRequired,
+				"Admin privileges required", "")
+		}
 		return c.Next()
 	}
 }
@@ -161,10 +191,8 @@ func RecoverMiddleware() fiber.Handler {
 					Str("path", c.Path()).
 					Msg("Recovered from panic")
 
-				c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
-					Error: "Internal server error",
-					Code:  fiber.StatusInternalServerError,
-				})
+				Problem(c, fiber.StatusInternalServerError, models.ErrCodeInternal,
+					"Internal server error", "")
 			}
 		}()
 
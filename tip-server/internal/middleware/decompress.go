@@ -0,0 +1,70 @@
+package middleware
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/klauspost/compress/zstd"
+
+	"tip-server/internal/models"
+)
+
+// DecompressBody decodes gzip or zstd request bodies (Content-Encoding) in
+// place, rejecting bodies that inflate beyond maxSize bytes. The header is
+// removed afterwards so Fiber does not decode the body again without a limit.
+func DecompressBody(maxSize int) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		encoding := strings.ToLower(strings.TrimSpace(c.Get(fiber.HeaderContentEncoding)))
+		if encoding == "" || encoding == "identity" {
+			return c.Next()
+		}
+
+		raw := c.Request().Body()
+		var (
+			r   io.Reader
+			err error
+		)
+		switch encoding {
+		case "gzip", "x-gzip":
+			r, err = gzip.NewReader(bytes.NewReader(raw))
+		case "zstd":
+			// Bound the window a frame header may ask for, not only the
+			// output, so a crafted frame cannot force a large allocation
+			var dec *zstd.Decoder
+			dec, err = zstd.NewReader(bytes.NewReader(raw),
+				zstd.WithDecoderConcurrency(1),
+				zstd.WithDecoderMaxMemory(uint64(max(maxSize, 1))),
+				zstd.WithDecoderMaxWindow(uint64(max(maxSize, zstd.MinWindowSize))),
+			)
+			if err == nil {
+				defer dec.Close()
+				r = dec
+			}
+		default:
+			return Problem(c, fiber.StatusUnsupportedMediaType, models.ErrCodeUnsupportedMediaType,
+				"Unsupported content encoding", "Request bodies may be gzip or zstd encoded")
+		}
+		if err != nil {
+			return Problem(c, fiber.StatusBadRequest, models.ErrCodeInvalidRequest,
+				"Invalid compressed body", err.Error())
+		}
+
+		body, err := io.ReadAll(io.LimitReader(r, int64(maxSize)+1))
+		if err != nil {
+			return Problem(c, fiber.StatusBadRequest, models.ErrCodeInvalidRequest,
+				"Invalid compressed body", err.Error())
+		}
+		if len(body) > maxSize {
+			return Problem(c, fiber.StatusRequestEntityTooLarge, models.ErrCodeBodyTooLarge,
+				"Decompressed body too large", fmt.Sprintf("Maximum %d bytes after decompression", maxSize))
+		}
+
+		c.Request().Header.Del(fiber.HeaderContentEncoding)
+		c.Request().SetBodyRaw(body)
+		return c.Next()
+	}
+}
@@ -0,0 +1,79 @@
+package middleware
+
+import (
+	"bytes"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/klauspost/compress/zstd"
+)
+
+func zstdBody(t *testing.T, payload []byte, opts ...zstd.EOption) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	enc, err := zstd.NewWriter(&buf, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := enc.Write(payload); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// rawZstdFrame builds a zstd frame holding payload in one raw block, with a
+// window of 1<<windowLog bytes declared in its header and no content size
+func rawZstdFrame(windowLog byte, payload []byte) []byte {
+	frame := []byte{0x28, 0xb5, 0x2f, 0xfd, 0x00, (windowLog - 10) << 3}
+	header := uint32(len(payload))<<3 | 1 // Raw block, last
+	frame = append(frame, byte(header), byte(header>>8), byte(header>>16))
+	return append(frame, payload...)
+}
+
+func TestDecompressBodyZstd(t *testing.T) {
+	const maxSize = 64 << 10
+
+	app := fiber.New()
+	app.Use(DecompressBody(maxSize))
+	app.Post("/", func(c *fiber.Ctx) error {
+		return c.Send(c.Body())
+	})
+
+	small := []byte(`{"iocs":["evil.example"]}`)
+	large := bytes.Repeat([]byte("a"), maxSize+1)
+
+	tests := []struct {
+		name   string
+		body   []byte
+		status int
+	}{
+		{"within limit", zstdBody(t, small), fiber.StatusOK},
+		{"inflates beyond limit", zstdBody(t, large, zstd.WithWindowSize(maxSize)), fiber.StatusRequestEntityTooLarge},
+		// A frame declaring an 8 MiB window is refused from its header,
+		// before the decoder allocates the window
+		{"window beyond limit", rawZstdFrame(23, small), fiber.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/", bytes.NewReader(tt.body))
+			req.Header.Set("Content-Encoding", "zstd")
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, _ := io.ReadAll(resp.Body)
+			if resp.StatusCode != tt.status {
+				t.Fatalf("status = %d, want %d (%s)", resp.StatusCode, tt.status, got)
+			}
+			if tt.status == fiber.StatusOK && !strings.Contains(string(got), "evil.example") {
+				t.Errorf("body = %s, want the decoded payload", got)
+			}
+		})
+	}
+}
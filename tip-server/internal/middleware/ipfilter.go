@@ -0,0 +1,101 @@
+package middleware
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/rs/zerolog/log"
+
+	"tip-server/internal/db"
+	"tip-server/internal/models"
+)
+
+// IPFilterConfig holds per-IP throttling and blocklist configuration
+type IPFilterConfig struct {
+	Redis db.Cache
+
+	// RateLimitFunc returns the requests per window allowed per client IP
+	// (0 = no per-IP throttling). Consulted per request so it can be reloaded.
+	RateLimitFunc func() int
+	RateWindow    time.Duration
+
+	// Auto-blocking of IPs that repeatedly fail authentication
+	AuthFailureLimit  int           // Failures per window before blocking (0 = disabled)
+	AuthFailureWindow time.Duration // Window over which failures are counted
+	BlockDuration     time.Duration // How long automatic blocks last
+}
+
+// NewIPFilterMiddleware rejects blocklisted IPs, throttles per source IP and
+// blocks IPs that keep failing authentication. It runs before authentication
+// so unauthenticated scanners are limited too. Redis errors fail open.
+func NewIPFilterMiddleware(cfg IPFilterConfig) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		ip := c.IP()
+		ctx := context.Background()
+
+		blocked, err := cfg.Redis.IsIPBlocked(ctx, ip)
+		if err != nil {
+			log.Error().Err(err).Msg("IP blocklist check failed")
+		} else if blocked {
+			return Problem(c, fiber.StatusForbidden, models.ErrCodeIPBlocked,
+				"Forbidden", "")
+		}
+
+		if limit := cfg.RateLimitFunc(); limit > 0 {
+			_, exceeded, err := cfg.Redis.IncrementIPRateLimit(ctx, ip, limit, cfg.RateWindow)
+			if err != nil {
+				log.Error().Err(err).Msg("IP rate limit check failed")
+			} else if exceeded {
+				c.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(cfg.RateWindow.Seconds())))
+				return Problem(c, fiber.StatusTooManyRequests, models.ErrCodeRateLimitExceeded,
+					"Rate limit exceeded", "Too many requests from this address")
+			}
+		}
+
+		err = c.Next()
+
+		if cfg.AuthFailureLimit > 0 && c.Response().StatusCode() == fiber.StatusUnauthorized {
+			recordAuthFailure(ctx, cfg, ip)
+		}
+
+		return err
+	}
+}
+
+// recordAuthFailure counts a failed authentication and blocks the IP once
+// the limit is exceeded
+func recordAuthFailure(ctx context.Context, cfg IPFilterConfig, ip string) {
+	count, exceeded, err := cfg.Redis.RecordAuthFailure(ctx, ip, cfg.AuthFailureLimit, cfg.AuthFailureWindow)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to record authentication failure")
+		return
+	}
+	if !exceeded {
+		return
+	}
+
+	now := time.Now().UTC()
+	entry := models.IPBlock{
+		IP:        ip,
+		Reason:    "too many authentication failures",
+		Source:    models.IPBlockSourceAuto,
+		BlockedAt: now,
+	}
+	if cfg.BlockDuration > 0 {
+		expires := now.Add(cfg.BlockDuration)
+		entry.ExpiresAt = &expires
+	}
+
+	if err := cfg.Redis.BlockIP(ctx, entry, cfg.BlockDuration); err != nil {
+		log.Error().Err(err).Str("ip", ip).Msg("Failed to block IP")
+		return
+	}
+
+	log.Warn().
+		Str("ip", ip).
+		Int64("failures", count).
+		Dur("duration", cfg.BlockDuration).
+		Msg("IP blocked after repeated authentication failures")
+}
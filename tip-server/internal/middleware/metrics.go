@@ -0,0 +1,42 @@
+package middleware
+
+import (
+	"errors"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"tip-server/internal/metrics"
+)
+
+// MetricsMiddleware records request count and latency for every route,
+// including error responses. Register it first so it observes the final
+// status code written by the recover middleware and error handler.
+func MetricsMiddleware(m *metrics.Metrics) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		start := time.Now()
+
+		err := c.Next()
+
+		status := c.Response().StatusCode()
+		if err != nil {
+			// The app error handler runs after the middleware chain unwinds,
+			// so derive the status it will write
+			status = fiber.StatusInternalServerError
+			var fe *fiber.Error
+			if errors.As(err, &fe) {
+				status = fe.Code
+			}
+		}
+
+		// Use the route template to keep label cardinality bounded
+		endpoint := "unmatched"
+		if route := c.Route(); route != nil && route.Path != "" && status != fiber.StatusNotFound {
+			endpoint = route.Path
+		}
+
+		m.RecordAPIRequest(endpoint, c.Method(), status, time.Since(start).Seconds())
+
+		return err
+	}
+}
@@ -0,0 +1,17 @@
+package middleware
+
+import (
+	"github.com/gofiber/fiber/v2"
+
+	"tip-server/internal/models"
+)
+
+// Problem sends an RFC 7807 problem details response
+func Problem(c *fiber.Ctx, status int, code, title, detail string) error {
+	return SendProblem(c, models.NewProblem(status, code, title, detail))
+}
+
+// SendProblem sends a prepared problem details response
+func SendProblem(c *fiber.Ctx, p models.Problem) error {
+	return c.Status(p.Status).JSON(p, models.ProblemContentType)
+}
@@ -0,0 +1,89 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/gofiber/fiber/v2"
+
+	"tip-server/internal/models"
+)
+
+// Errors returned by ValidateIndicator
+var (
+	ErrIndicatorEmpty    = errors.New("indicator is empty")
+	ErrIndicatorTooLong  = errors.New("indicator exceeds maximum length")
+	ErrIndicatorEncoding = errors.New("indicator is not valid UTF-8")
+	ErrIndicatorControl  = errors.New("indicator contains control characters")
+)
+
+// RequireJSON rejects request bodies on write methods that are not declared
+// as JSON, so form or multipart payloads never reach the strict decoder.
+func RequireJSON() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		switch c.Method() {
+		case fiber.MethodPost, fiber.MethodPut, fiber.MethodPatch:
+		default:
+			return c.Next()
+		}
+
+		if len(c.Body()) > 0 && !strings.HasPrefix(c.Get(fiber.HeaderContentType), fiber.MIMEApplicationJSON) {
+			return Problem(c, fiber.StatusUnsupportedMediaType, models.ErrCodeUnsupportedMediaType,
+				"Unsupported content type", "Request bodies must be application/json")
+		}
+		return c.Next()
+	}
+}
+
+// ParseJSONStrict decodes the request body into out, rejecting unknown
+// fields and trailing data after the top-level value.
+func ParseJSONStrict(c *fiber.Ctx, out any) error {
+	dec := json.NewDecoder(bytes.NewReader(c.Body()))
+	dec.DisallowUnknownFields()
+
+	if err := dec.Decode(out); err != nil {
+		return err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return errors.New("unexpected data after JSON body")
+	}
+	return nil
+}
+
+// ValidateIndicator checks a submitted IOC value before it is looked up,
+// stored or logged. Control characters are rejected outright rather than
+// stripped so a crafted value cannot forge log lines or smuggle CR/LF into
+// downstream systems.
+func ValidateIndicator(value string, maxLen int) error {
+	if strings.TrimSpace(value) == "" {
+		return ErrIndicatorEmpty
+	}
+	if maxLen > 0 && len(value) > maxLen {
+		return ErrIndicatorTooLong
+	}
+	if !utf8.ValidString(value) {
+		return ErrIndicatorEncoding
+	}
+	for _, r := range value {
+		if unicode.IsControl(r) {
+			return ErrIndicatorControl
+		}
+	}
+	return nil
+}
+
+// ValidateIndicators validates every value and reports the first failure with its index
+func ValidateIndicators(values []string, maxLen int) error {
+	for i, v := range values {
+		if err := ValidateIndicator(v, maxLen); err != nil {
+			return fmt.Errorf("iocs[%d]: %w", i, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,20 @@
+package middleware
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// HSTSMiddleware sets Strict-Transport-Security on every response so
+// browsers refuse to downgrade to plain HTTP. Only register it when the
+// server terminates TLS itself.
+func HSTSMiddleware(maxAge time.Duration) fiber.Handler {
+	header := fmt.Sprintf("max-age=%d; includeSubDomains", int64(maxAge.Seconds()))
+
+	return func(c *fiber.Ctx) error {
+		c.Set("Strict-Transport-Security", header)
+		return c.Next()
+	}
+}
@@ -0,0 +1,31 @@
+package middleware
+
+import (
+	"slices"
+
+	"github.com/gofiber/fiber/v2"
+
+	"tip-server/internal/signing"
+)
+
+// SignResponses signs successful responses of the given paths, sending the
+// detached signature in the X-Signature header. Registered ahead of
+// TimeFormat it runs after the rewrite, so the signature covers the body as
+// sent. A nil signer signs nothing.
+func SignResponses(signer *signing.Signer, paths ...string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if !signer.Enabled() || !slices.Contains(paths, c.Path()) {
+			return c.Next()
+		}
+		if err := c.Next(); err != nil {
+			return err
+		}
+
+		resp := c.Response()
+		if resp.StatusCode() != fiber.StatusOK || resp.IsBodyStream() {
+			return nil
+		}
+		c.Set(signing.Header, signer.Sign(resp.Body()))
+		return nil
+	}
+}
@@ -0,0 +1,151 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/rs/zerolog/log"
+
+	"tip-server/internal/models"
+)
+
+// timestampForm matches the RFC 3339 timestamps encoding/json writes for
+// time.Time; other strings are left alone
+var timestampForm = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}(\.\d+)?(Z|[+-]\d{2}:\d{2})$`)
+
+// TimeFormat renders the timestamps of JSON responses in the format a
+// request asks for (?time_format=rfc3339|both), defaulting to def. With
+// "both" every timestamp is rewritten in UTC and each object field holding
+// one gains a "<field>_ms" sibling with its epoch milliseconds, so clients
+// need not parse timestamps at all. Event streams and other content types
+// pass through.
+func TimeFormat(def string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		format := def
+		if raw := c.Query("time_format"); raw != "" {
+			format = strings.ToLower(raw)
+		}
+		switch format {
+		case models.TimeFormatRFC3339:
+			return c.Next()
+		case models.TimeFormatBoth:
+		default:
+			return Problem(c, fiber.StatusBadRequest, models.ErrCodeInvalidParameter,
+				"Invalid query parameter", "time_format must be rfc3339 or both")
+		}
+
+		if err := c.Next(); err != nil {
+			return err
+		}
+
+		resp := c.Response()
+		if !strings.HasPrefix(string(resp.Header.ContentType()), fiber.MIMEApplicationJSON) || resp.IsBodyStream() {
+			return nil
+		}
+		body, err := addEpochTimes(resp.Body())
+		if err != nil {
+			log.Debug().Err(err).Str("path", c.Path()).Msg("Failed to rewrite response timestamps")
+			return nil
+		}
+		resp.SetBodyRaw(body)
+		return nil
+	}
+}
+
+// addEpochTimes rewrites a JSON document as TimeFormat describes, keeping
+// the order of object fields
+func addEpochTimes(body []byte) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+
+	var out bytes.Buffer
+	out.Grow(len(body) + len(body)/4)
+	if err := rewriteValue(dec, &out, ""); err != nil {
+		return nil, err
+	}
+	if dec.More() {
+		return nil, errors.New("trailing data after JSON document")
+	}
+	return out.Bytes(), nil
+}
+
+// rewriteValue copies the next value from dec to out. key names the object
+// field holding the value, "" inside arrays and at the top level.
+func rewriteValue(dec *json.Decoder, out *bytes.Buffer, key string) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+
+	switch t := tok.(type) {
+	case json.Delim:
+		switch t {
+		case '{':
+			out.WriteByte('{')
+			for first := true; dec.More(); first = false {
+				if !first {
+					out.WriteByte(',')
+				}
+				field, err := dec.Token()
+				if err != nil {
+					return err
+				}
+				name, _ := field.(string)
+				writeJSONString(out, name)
+				out.WriteByte(':')
+				if err := rewriteValue(dec, out, name); err != nil {
+					return err
+				}
+			}
+			out.WriteByte('}')
+		case '[':
+			out.WriteByte('[')
+			for first := true; dec.More(); first = false {
+				if !first {
+					out.WriteByte(',')
+				}
+				if err := rewriteValue(dec, out, ""); err != nil {
+					return err
+				}
+			}
+			out.WriteByte(']')
+		}
+		// Closing delimiter
+		_, err := dec.Token()
+		return err
+
+	case string:
+		if timestampForm.MatchString(t) {
+			if ts, err := time.Parse(time.RFC3339Nano, t); err == nil {
+				writeJSONString(out, ts.UTC().Format(time.RFC3339Nano))
+				if key != "" {
+					out.WriteByte(',')
+					writeJSONString(out, key+"_ms")
+					out.WriteByte(':')
+					out.WriteString(strconv.FormatInt(ts.UnixMilli(), 10))
+				}
+				return nil
+			}
+		}
+		writeJSONString(out, t)
+	case json.Number:
+		out.WriteString(t.String())
+	case bool:
+		out.WriteString(strconv.FormatBool(t))
+	case nil:
+		out.WriteString("null")
+	}
+	return nil
+}
+
+// writeJSONString writes s as a JSON string, escaped as encoding/json does
+func writeJSONString(out *bytes.Buffer, s string) {
+	b, _ := json.Marshal(s)
+	out.Write(b)
+}
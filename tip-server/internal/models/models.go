@@ -1,6 +1,14 @@
 package models
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -16,6 +24,22 @@ const (
 	IOCTypeSHA1   IOCType = "sha1"
 	IOCTypeSHA256 IOCType = "sha256"
 	IOCTypeEmail  IOCType = "email"
+	IOCTypeJA3    IOCType = "ja3" // TLS client/server fingerprint (MD5 form)
+
+	IOCTypeImphash IOCType = "imphash" // PE import table hash (MD5 form)
+
+	// X.509 certificate fingerprints and serial numbers, for cert-pinned C2
+	IOCTypeCertSHA1   IOCType = "cert_sha1"   // SHA-1 form
+	IOCTypeCertSHA256 IOCType = "cert_sha256" // SHA-256 form
+	IOCTypeCertSerial IOCType = "cert_serial" // Lowercase hex, no separators
+
+	// Host and network behaviour seen in sandbox reports
+	IOCTypeMutex     IOCType = "mutex"      // Named mutex, including any Global\ or Local\ prefix
+	IOCTypeUserAgent IOCType = "user_agent" // HTTP User-Agent header value
+
+	// Network ranges, e.g. bulletproof hosting; /check matches addresses inside CIDR blocks
+	IOCTypeASN  IOCType = "asn"  // "AS" followed by the decimal number
+	IOCTypeCIDR IOCType = "cidr" // Masked network in CIDR notation
 )
 
 // AllIOCTypes returns all supported IOC types
@@ -29,7 +53,61 @@ func AllIOCTypes() []IOCType {
 		IOCTypeSHA1,
 		IOCTypeSHA256,
 		IOCTypeEmail,
+		IOCTypeJA3,
+		IOCTypeImphash,
+		IOCTypeCertSHA1,
+		IOCTypeCertSHA256,
+		IOCTypeCertSerial,
+		IOCTypeMutex,
+		IOCTypeUserAgent,
+		IOCTypeASN,
+		IOCTypeCIDR,
+	}
+}
+
+// HashForm returns the file hash type whose hex digests values of t look
+// like, or "" when t is not hash-shaped. Extraction alone classifies such
+// values as that file hash type.
+func HashForm(t IOCType) IOCType {
+	switch t {
+	case IOCTypeJA3, IOCTypeImphash:
+		return IOCTypeMD5
+	case IOCTypeCertSHA1:
+		return IOCTypeSHA1
+	case IOCTypeCertSHA256:
+		return IOCTypeSHA256
 	}
+	return ""
+}
+
+// certificateTags mark a feed or submission's hashes as certificate
+// fingerprints rather than file hashes
+var certificateTags = map[string]bool{
+	"cert":        true,
+	"certificate": true,
+	"ssl":         true,
+	"ssl-cert":    true,
+	"sslbl":       true,
+	"tls-cert":    true,
+	"x509":        true,
+}
+
+// CertificateHashType returns the certificate fingerprint type for a SHA-1
+// or SHA-256 value when any of its tags marks it as a certificate, and t
+// unchanged otherwise
+func CertificateHashType(t IOCType, tags []string) IOCType {
+	if t != IOCTypeSHA1 && t != IOCTypeSHA256 {
+		return t
+	}
+	for _, tag := range tags {
+		if certificateTags[strings.ToLower(strings.TrimSpace(tag))] {
+			if t == IOCTypeSHA1 {
+				return IOCTypeCertSHA1
+			}
+			return IOCTypeCertSHA256
+		}
+	}
+	return t
 }
 
 // ScanStatus represents the processing status of a file
@@ -41,20 +119,29 @@ const (
 	ScanStatusInfected ScanStatus = "infected"
 	ScanStatusMisc     ScanStatus = "misc"
 	ScanStatusFailed   ScanStatus = "failed"
+	ScanStatusDeleted  ScanStatus = "deleted" // Source file no longer exists
+
+	// Stopped by the ingestor's per-file safeguards
+	ScanStatusOversized ScanStatus = "oversized" // Larger than MAX_FILE_SIZE_MB; not read
+	ScanStatusTimeout   ScanStatus = "timeout"   // Extraction exceeded FILE_TIMEOUT; nothing stored
 )
 
 // IOC represents an Indicator of Compromise
 type IOC struct {
-	Value         string    `json:"value" ch:"ioc_value"`
-	Type          IOCType   `json:"type" ch:"ioc_type"`
-	SourceFileID  string    `json:"source_file_id" ch:"source_file_id"`
-	MalwareFamily string    `json:"malware_family,omitempty" ch:"malware_family"`
-	Confidence    uint8     `json:"confidence" ch:"confidence"`
-	FirstSeen     time.Time `json:"first_seen" ch:"first_seen"`
-	LastSeen      time.Time `json:"last_seen" ch:"last_seen"`
-	HitCount      uint32    `json:"hit_count" ch:"hit_count"`
-	VectorID      *uint64   `json:"vector_id,omitempty" ch:"vector_id"` // Phase 2: Qdrant integration
-	Tags          []string  `json:"tags,omitempty" ch:"tags"`
+	Value         string     `json:"value" ch:"ioc_value"`
+	Type          IOCType    `json:"type" ch:"ioc_type"`
+	SourceFileID  string     `json:"source_file_id" ch:"source_file_id"`
+	MalwareFamily string     `json:"malware_family,omitempty" ch:"malware_family"`
+	Confidence    uint8      `json:"confidence" ch:"confidence"`
+	DGAScore      uint8      `json:"dga_score,omitempty" ch:"dga_score"` // Likelihood (0-100) a domain was algorithmically generated
+	FirstSeen     time.Time  `json:"first_seen" ch:"first_seen"`
+	LastSeen      time.Time  `json:"last_seen" ch:"last_seen"`
+	ValidUntil    *time.Time `json:"valid_until,omitempty" ch:"valid_until"` // End of the source's validity window, if it stated one
+	HitCount      uint32     `json:"hit_count" ch:"hit_count"`
+	VectorID      *uint64    `json:"vector_id,omitempty" ch:"vector_id"` // Phase 2: Qdrant integration
+	Tags          []string   `json:"tags,omitempty" ch:"tags"`
+	Pending       bool       `json:"pending,omitempty" ch:"pending"` // Awaiting analyst review; left out of /check by default
+	Sealed        string     `json:"-" ch:"sealed_value"`            // Encrypted original of a redacted email address (see package redact)
 }
 
 // FileMetadata represents information about a processed file
@@ -62,6 +149,9 @@ type FileMetadata struct {
 	FileID       string     `json:"file_id" ch:"file_id"`
 	FilePath     string     `json:"file_path" ch:"file_path"`
 	FileSize     uint64     `json:"file_size" ch:"file_size"`
+	ContentHash  string     `json:"content_hash,omitempty" ch:"content_hash"`
+	Language     string     `json:"language,omitempty" ch:"language"`   // ISO 639-1, "" if undetected
+	FileType     string     `json:"file_type,omitempty" ch:"file_type"` // Detected format, e.g. "eml", "pcapng", "pe32+"; "" for plain text
 	LastModified time.Time  `json:"last_modified" ch:"last_modified"`
 	ScanStatus   ScanStatus `json:"scan_status" ch:"scan_status"`
 	IOCCount     uint32     `json:"ioc_count" ch:"ioc_count"`
@@ -69,8 +159,17 @@ type FileMetadata struct {
 	ErrorMessage string     `json:"error_message,omitempty" ch:"error_message"`
 	ProcessedAt  time.Time  `json:"processed_at" ch:"processed_at"`
 	UpdatedAt    time.Time  `json:"updated_at" ch:"updated_at"`
+	DeletedAt    *time.Time `json:"deleted_at,omitempty" ch:"deleted_at"`
 }
 
+// File identity strategies (FILE_IDENTITY): what a file_id is derived from
+const (
+	FileIdentityPath        = "path"         // Path alone; copies and renames are new files
+	FileIdentityContent     = "content"      // Content hash; copies share one identity
+	FileIdentityPathContent = "path_content" // Path and content hash; each version of a file is its own
+	FileIdentityInode       = "inode"        // Device and inode; renames within a filesystem keep their identity
+)
+
 // APIKey represents an API key for authentication
 type APIKey struct {
 	KeyHash     string    `json:"key_hash" ch:"key_hash"`
@@ -82,6 +181,111 @@ type APIKey struct {
 	LastUsed    time.Time `json:"last_used" ch:"last_used"`
 }
 
+// AuditEntry records an administrative change to the IOC corpus
+type AuditEntry struct {
+	Timestamp    time.Time `json:"timestamp" ch:"timestamp"`
+	Action       string    `json:"action" ch:"action"`
+	IOCValue     string    `json:"ioc_value" ch:"ioc_value"`
+	Actor        string    `json:"actor" ch:"actor"`
+	Reason       string    `json:"reason,omitempty" ch:"reason"`
+	ClientIP     string    `json:"client_ip,omitempty" ch:"client_ip"`
+	RowsAffected uint64    `json:"rows_affected" ch:"rows_affected"`
+}
+
+// Audit actions
+const (
+	AuditActionDeprecate          = "deprecate"
+	AuditActionQuarantineDownload = "quarantine_download" // IOCValue is the sample's SHA256
+	AuditActionExport             = "export"              // IOCValue is empty
+	AuditActionImport             = "import"              // IOCValue is the import source
+	AuditActionIngestRun          = "ingest_run"          // IOCValue is the requested path or feed
+	AuditActionBulkUpdate         = "bulk_update"         // IOCValue is the JSON filter
+	AuditActionReviewApprove      = "review_approve"      // IOCValue is the JSON list of values
+	AuditActionReviewReject       = "review_reject"       // IOCValue is the JSON list of values
+	AuditActionReprocess          = "reprocess"           // IOCValue is the JSON list of file IDs
+	AuditActionDelete             = "delete"              // IOCValue is the JSON file IDs and values
+	AuditActionPurge              = "purge"               // IOCValue is the JSON file IDs and values
+	AuditActionReveal             = "reveal"              // IOCValue is the JSON list of redacted values
+	AuditActionSubscribe          = "subscribe"           // IOCValue is the subscription ID
+	AuditActionUnsubscribe        = "unsubscribe"         // IOCValue is the subscription ID
+	AuditActionLabelSamples       = "label_samples"       // IOCValue is the JSON list of labels
+)
+
+// QueryLogEntry records one lookup request in the query log
+type QueryLogEntry struct {
+	Timestamp      time.Time `ch:"timestamp"`
+	APIKeyHash     string    `ch:"api_key_hash"`
+	Endpoint       string    `ch:"endpoint"`
+	IOCsQueried    []string  `ch:"iocs_queried"`
+	IOCsFound      []string  `ch:"iocs_found"`
+	ResponseTimeMs uint32    `ch:"response_time_ms"`
+	ClientIP       string    `ch:"client_ip"`
+}
+
+// IPBlock is a blocklisted client IP
+type IPBlock struct {
+	IP        string     `json:"ip"`
+	Reason    string     `json:"reason,omitempty"`
+	Source    string     `json:"source"` // "manual" or "auto"
+	BlockedAt time.Time  `json:"blocked_at"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// DomainResolution is the latest DNS resolution of a domain IOC
+type DomainResolution struct {
+	Domain     string    `json:"domain" ch:"domain"`
+	Status     string    `json:"status" ch:"status"`
+	Addresses  []string  `json:"addresses,omitempty" ch:"addresses"`
+	ResolvedAt time.Time `json:"resolved_at" ch:"resolved_at"`
+}
+
+// WhoisRelationship links a domain to a registrar, nameserver or
+// registrant email taken from its registration data
+type WhoisRelationship struct {
+	Domain     string    `json:"domain" ch:"domain"`
+	Relation   string    `json:"relation" ch:"relation"`
+	Value      string    `json:"value" ch:"value"`
+	ObservedAt time.Time `json:"observed_at" ch:"observed_at"`
+}
+
+// WHOIS relationship kinds
+const (
+	WhoisRelationRegistrar       = "registrar"
+	WhoisRelationNameserver      = "nameserver"
+	WhoisRelationRegistrantEmail = "registrant_email"
+)
+
+// WhoisRelatedDomain is a domain sharing registration data with the queried value
+type WhoisRelatedDomain struct {
+	Domain        string    `json:"domain"`
+	ObservedAt    time.Time `json:"observed_at"`
+	Found         bool      `json:"found"` // The domain is a known IOC
+	MalwareFamily string    `json:"malware_family,omitempty"`
+	Confidence    uint8     `json:"confidence,omitempty"`
+}
+
+// WhoisRelatedResponse lists the domains registered with a registrar,
+// nameserver or registrant email
+type WhoisRelatedResponse struct {
+	Relation string               `json:"relation"`
+	Value    string               `json:"value"`
+	Domains  []WhoisRelatedDomain `json:"domains"`
+}
+
+// Domain resolution statuses
+const (
+	DNSStatusResolved  = "resolved"
+	DNSStatusNXDomain  = "nxdomain"
+	DNSStatusSinkholed = "sinkholed" // Resolves into a configured sinkhole range
+	DNSStatusError     = "error"
+)
+
+// IP block sources
+const (
+	IPBlockSourceManual = "manual"
+	IPBlockSourceAuto   = "auto"
+)
+
 // ========== API Request/Response Models ==========
 
 // CheckRequest represents a request to check IOCs
@@ -89,24 +293,283 @@ type CheckRequest struct {
 	IOCs []string `json:"iocs" validate:"required,min=1,max=1000"`
 }
 
+// IOCSubmission is a batch of IOCs pushed by an external producer
+type IOCSubmission struct {
+	Source       string         `json:"source"`                  // Producer name, recorded as the IOC source
+	TypeMismatch string         `json:"type_mismatch,omitempty"` // reject or correct (default SUBMISSION_TYPE_MISMATCH)
+	IOCs         []SubmittedIOC `json:"iocs"`
+}
+
+// SubmittedIOC is a single externally submitted indicator. Type is detected
+// from the value when omitted.
+type SubmittedIOC struct {
+	Value         string   `json:"value"`
+	Type          IOCType  `json:"type,omitempty"`
+	MalwareFamily string   `json:"malware_family,omitempty"`
+	Confidence    uint8    `json:"confidence,omitempty"`
+	Tags          []string `json:"tags,omitempty"`
+}
+
+// What happens to a submitted value that is a valid IOC of a type other
+// than the one declared
+const (
+	TypeMismatchReject  = "reject"  // Reject the value
+	TypeMismatchCorrect = "correct" // Store it under the detected type
+)
+
+// Submission issue codes
+const (
+	SubmissionIssueInvalidValue   = "invalid_value"    // Empty, too long, not UTF-8 or contains control characters
+	SubmissionIssueUnknownType    = "unknown_type"     // Declared type is not one the server supports
+	SubmissionIssueUnrecognized   = "unrecognized"     // Not an IOC of any type
+	SubmissionIssueInvalidForType = "invalid_for_type" // Not a valid value of the declared type, nor of another
+	SubmissionIssueTypeMismatch   = "type_mismatch"    // A valid IOC, but of another type than declared
+)
+
+// SubmissionIssue reports a submitted value that was rejected, or stored
+// under another type than declared
+type SubmissionIssue struct {
+	Index        int     `json:"index"` // Position in the submitted list
+	Value        string  `json:"value"` // Truncated when long
+	DeclaredType IOCType `json:"declared_type,omitempty"`
+	DetectedType IOCType `json:"detected_type,omitempty"` // What the value is, when it is an IOC
+	Code         string  `json:"code"`
+	Message      string  `json:"message"`
+	Corrected    bool    `json:"corrected,omitempty"` // Stored as DetectedType rather than rejected
+}
+
+// BlockIPRequest adds an IP to the blocklist
+type BlockIPRequest struct {
+	IP       string `json:"ip"`
+	Reason   string `json:"reason,omitempty"`
+	Duration string `json:"duration,omitempty"` // Go duration, e.g. "24h" (empty = until removed)
+}
+
 // CheckResponse represents the response from IOC check
 type CheckResponse struct {
-	Results   []IOCResult `json:"results"`
-	Total     int         `json:"total"`
-	Found     int         `json:"found"`
-	NotFound  int         `json:"not_found"`
-	QueryTime string      `json:"query_time"`
+	Results      []IOCResult `json:"results"`
+	Total        int         `json:"total"`
+	Found        int         `json:"found"`
+	NotFound     int         `json:"not_found"`
+	Cached       int         `json:"cached,omitempty"`        // Matches served from the lookup cache
+	CachedMisses int         `json:"cached_misses,omitempty"` // Misses served from the negative cache
+	QueryTime    string      `json:"query_time"`
+	Stages       CheckStages `json:"stages"`
+
+	// Degraded is set when ClickHouse could not answer for some values; those
+	// the Bloom filter passed are counted in Probable rather than NotFound
+	Degraded bool `json:"degraded,omitempty"`
+	Probable int  `json:"probable,omitempty"`
+
+	// Known values not seen within since/until, counted in NotFound
+	OutsideWindow int `json:"outside_window,omitempty"`
+}
+
+// CheckStages reports the time spent in each /check lookup stage. The Bloom
+// filter, lookup cache and negative cache are read in one pipelined Redis
+// round trip, timed as Redis.
+type CheckStages struct {
+	Redis      string `json:"redis"`
+	Bloom      string `json:"bloom"` // Same as Redis; kept for clients reading it
+	ClickHouse string `json:"clickhouse"`
+	Enrich     string `json:"enrich,omitempty"`
 }
 
 // IOCResult represents a single IOC lookup result
 type IOCResult struct {
-	IOC           string  `json:"ioc"`
+	IOC           string  `json:"ioc"`             // Canonical form, as stored and looked up
+	Input         string  `json:"input,omitempty"` // Value as submitted, when it differs from ioc
 	Found         bool    `json:"found"`
+	Probable      bool    `json:"probable,omitempty"` // Bloom filter match ClickHouse could not confirm (degraded responses)
+	Pending       bool    `json:"pending,omitempty"`  // Awaiting analyst review (include_pending=true)
 	Type          IOCType `json:"type,omitempty"`
 	SourceFileID  string  `json:"source_file_id,omitempty"`
 	MalwareFamily string  `json:"malware_family,omitempty"`
 	Confidence    uint8   `json:"confidence,omitempty"`
+	DGAScore      uint8   `json:"dga_score,omitempty"` // Likelihood (0-100) a matched domain was algorithmically generated
 	FirstSeen     string  `json:"first_seen,omitempty"`
+	LastSeen      string  `json:"last_seen,omitempty"`      // v1 only
+	MatchedRange  string  `json:"matched_range,omitempty"`  // Stored CIDR block containing the address
+	List          string  `json:"list,omitempty"`           // "allow" or "deny" when an operator list decided the verdict
+	OutsideWindow bool    `json:"outside_window,omitempty"` // Known, but not seen within since/until; not a match
+
+	// Aggregated attributes of a match, returned by /v1/check only
+	Tags     []string `json:"tags,omitempty"`      // Merged over the sightings aggregated for the match
+	HitCount uint32   `json:"hit_count,omitempty"` // Times the value was seen, summed over those sightings
+	VectorID *uint64  `json:"vector_id,omitempty"` // Qdrant point of the value, for similarity search
+
+	Sources     []IOCSource `json:"sources,omitempty"`      // Every file or feed that yielded the IOC, most recently seen first
+	SourceCount int         `json:"source_count,omitempty"` // Sources in total; more than len(sources) when capped by max_sources
+
+	PopularityRank int    `json:"popularity_rank,omitempty"` // Tranco rank of the matched domain or its parent
+	Warning        string `json:"warning,omitempty"`         // Why a match needs analyst judgement
+
+	Reputation []Reputation `json:"reputation,omitempty"` // External provider verdicts
+	DNSStatus  string       `json:"dns_status,omitempty"` // Latest resolution status (domains only)
+
+	RegisteredAt    string `json:"registered_at,omitempty"`    // Domain registration date (WHOIS/RDAP)
+	NewlyRegistered bool   `json:"newly_registered,omitempty"` // Registered within NRD_DAYS
+}
+
+// IOCSource is one origin of a matched IOC: a source file hash or a
+// prefixed origin such as "feed", "import:<name>" or "syslog:<host>"
+type IOCSource struct {
+	SourceFileID  string    `json:"source_file_id"`
+	Feed          string    `json:"feed,omitempty"` // DATA_PATH source the file was crawled from
+	MalwareFamily string    `json:"malware_family,omitempty"`
+	Confidence    uint8     `json:"confidence,omitempty"`
+	FirstSeen     time.Time `json:"first_seen"`
+	LastSeen      time.Time `json:"last_seen"`
+}
+
+// Reputation is an external provider's verdict on an IOC
+type Reputation struct {
+	Provider  string    `json:"provider"`
+	Score     uint8     `json:"score"`               // 0-100, higher is more malicious
+	Malicious int       `json:"malicious,omitempty"` // Engines flagging the value (VirusTotal)
+	Engines   int       `json:"engines,omitempty"`   // Engines that scanned the value (VirusTotal)
+	Reports   int       `json:"reports,omitempty"`   // Abuse reports (AbuseIPDB)
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+// Search modes for GET /search
+const (
+	SearchModeExact     = "exact"
+	SearchModePrefix    = "prefix"
+	SearchModeSubstring = "substring"
+	SearchModeRegex     = "regex" // POST /search/regex
+)
+
+// SearchResponse lists stored indicators matching a partial value
+type SearchResponse struct {
+	Query       string  `json:"query"`
+	Mode        string  `json:"mode"`
+	Type        IOCType `json:"type,omitempty"`
+	MinDGAScore uint8   `json:"min_dga_score,omitempty"`
+	Results   []IOC   `json:"results"`
+	Truncated bool    `json:"truncated"` // More indicators match than limit
+	QueryTime string  `json:"query_time"`
+}
+
+// RegexSearchRequest asks for stored indicators of one type whose value
+// matches a regular expression
+type RegexSearchRequest struct {
+	Pattern string  `json:"pattern"` // RE2 syntax
+	Type    IOCType `json:"type"`
+	Limit   int     `json:"limit,omitempty"`
+	Async   bool    `json:"async,omitempty"` // Run as a background job straight away
+
+	MinDGAScore uint8 `json:"min_dga_score,omitempty"` // Only domains scoring at least this (0-100)
+}
+
+// Regex search job states
+const (
+	RegexJobRunning = "running"
+	RegexJobDone    = "done"
+	RegexJobFailed  = "failed"
+)
+
+// RegexSearchJob is a regex search running in the background, polled at
+// GET /search/regex/:id
+type RegexSearchJob struct {
+	ID          string     `json:"id"`
+	Status      string     `json:"status"`
+	Pattern     string     `json:"pattern"`
+	Type        IOCType    `json:"type"`
+	Limit       int        `json:"limit"`
+	MinDGAScore uint8      `json:"min_dga_score,omitempty"`
+	Results     []IOC      `json:"results,omitempty"`
+	Truncated   bool       `json:"truncated,omitempty"`
+	Error       string     `json:"error,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+}
+
+// TyposquatRequest asks for lookalike permutations of a brand domain
+type TyposquatRequest struct {
+	Domain     string   `json:"domain"`
+	Kinds      []string `json:"kinds,omitempty"`       // Permutation kinds (empty = all)
+	ResolveDNS bool     `json:"resolve_dns,omitempty"` // Also report permutations that resolve
+}
+
+// TyposquatResponse lists permutations that are known IOCs or resolve
+type TyposquatResponse struct {
+	Domain    string           `json:"domain"`
+	Generated int              `json:"generated"`
+	Resolved  int              `json:"resolved,omitempty"` // Permutations looked up in DNS
+	Matches   []TyposquatMatch `json:"matches"`
+	QueryTime string           `json:"query_time"`
+}
+
+// TyposquatMatch is a permutation that exists as an indicator or in DNS
+type TyposquatMatch struct {
+	Domain        string   `json:"domain"`
+	Kind          string   `json:"kind"`
+	Found         bool     `json:"found"` // Present in the IOC corpus
+	MalwareFamily string   `json:"malware_family,omitempty"`
+	Confidence    uint8    `json:"confidence,omitempty"`
+	SourceFileID  string   `json:"source_file_id,omitempty"`
+	Resolves      bool     `json:"resolves,omitempty"`
+	Addresses     []string `json:"addresses,omitempty"`
+}
+
+// FuzzySearchRequest asks for indexed files whose content resembles text
+type FuzzySearchRequest struct {
+	Text     string  `json:"text"`
+	Limit    int     `json:"limit,omitempty"`     // Maximum matches (default 10)
+	MinScore float32 `json:"min_score,omitempty"` // Minimum cosine similarity (0-1)
+}
+
+// FuzzySearchResponse lists the most similar stored files
+type FuzzySearchResponse struct {
+	Matches   []SimilarFile `json:"matches"`
+	QueryTime string        `json:"query_time"`
+}
+
+// SimilarFile is an indexed file returned by similarity search
+type SimilarFile struct {
+	FileID      string     `json:"file_id"`
+	FilePath    string     `json:"file_path"`
+	Score       float32    `json:"score"`
+	ScanStatus  ScanStatus `json:"scan_status,omitempty"`
+	ContentHash string     `json:"content_hash,omitempty"`
+	MinIOKey    string     `json:"minio_key,omitempty"`
+	Snippet     string     `json:"snippet,omitempty"`
+}
+
+// ClusterReport is the latest result of clustering file vectors
+type ClusterReport struct {
+	GeneratedAt time.Time `json:"generated_at"`
+	Points      int       `json:"points"`    // Vectors considered
+	Threshold   float32   `json:"threshold"` // Minimum similarity linking two files
+	Clusters    []Cluster `json:"clusters"`
+}
+
+// Cluster is a group of files with similar content and the indicators
+// extracted from them
+type Cluster struct {
+	ID              int                `json:"id"`
+	Size            int                `json:"size"`
+	Representative  ClusterMember      `json:"representative"`
+	Members         []ClusterMember    `json:"members"` // Most connected first, capped
+	Indicators      []ClusterIndicator `json:"indicators,omitempty"`
+	MalwareFamilies []string           `json:"malware_families,omitempty"`
+}
+
+// ClusterMember is a file in a cluster
+type ClusterMember struct {
+	FileID     string     `json:"file_id"`
+	FilePath   string     `json:"file_path"`
+	ScanStatus ScanStatus `json:"scan_status,omitempty"`
+	Links      int        `json:"links"` // Similar files within the cluster
+}
+
+// ClusterIndicator is an IOC extracted from one or more files in a cluster
+type ClusterIndicator struct {
+	Value         string  `json:"value"`
+	Type          IOCType `json:"type"`
+	Files         int     `json:"files"` // Cluster members containing it
+	MalwareFamily string  `json:"malware_family,omitempty"`
 }
 
 // ContextResponse represents file context response
@@ -124,24 +587,386 @@ type HealthResponse struct {
 	Status     string            `json:"status"`
 	Timestamp  string            `json:"timestamp"`
 	Components map[string]string `json:"components"`
+	LatencyMs  map[string]int64  `json:"latency_ms,omitempty"`
+}
+
+// CapabilitiesResponse lists the optional subsystems enabled in this
+// deployment, so clients can adapt instead of probing for errors
+type CapabilitiesResponse struct {
+	Features            map[string]bool `json:"features"`
+	EnrichmentProviders []string        `json:"enrichment_providers"`
+	Bloom               BloomCapability `json:"bloom"`
+	Endpoints           map[string]bool `json:"endpoints"` // Optional endpoints, keyed "METHOD /path"
+	Limits              RequestLimits   `json:"limits"`
+}
+
+// BloomCapability describes the Bloom filter in front of the IOC store
+type BloomCapability struct {
+	Type      string  `json:"type"`
+	ErrorRate float64 `json:"error_rate"`
+	Capacity  int64   `json:"capacity"`
+}
+
+// RequestLimits are the input limits enforced on API requests
+type RequestLimits struct {
+	MaxIOCsPerCheck int `json:"max_iocs_per_check"`
+	MaxIOCLength    int `json:"max_ioc_length"`
+	MaxBodyBytes    int `json:"max_body_bytes"`
+}
+
+// Problem is an RFC 7807 problem details body, served as
+// application/problem+json. Clients branch on Code rather than Title.
+type Problem struct {
+	Type   string `json:"type"` // "urn:tip:problem:<code>"
+	Title  string `json:"title"`
+	Status int    `json:"status"`
+	Detail string `json:"detail,omitempty"`
+	Code   string `json:"code"`
 }
 
-// ErrorResponse represents an error response
-type ErrorResponse struct {
-	Error   string `json:"error"`
-	Code    int    `json:"code"`
-	Details string `json:"details,omitempty"`
+// ProblemContentType is the media type of Problem responses
+const ProblemContentType = "application/problem+json"
+
+// Machine-readable error codes carried in Problem.Code
+const (
+	ErrCodeInvalidRequest       = "invalid_request"   // Malformed body or unsupported value
+	ErrCodeInvalidParameter     = "invalid_parameter" // Bad path or query parameter
+	ErrCodeInvalidIOC           = "invalid_ioc"
+	ErrCodeNoIOCs               = "no_iocs"
+	ErrCodeIOCLimitExceeded     = "ioc_limit_exceeded"
+	ErrCodeBodyTooLarge         = "body_too_large"
+	ErrCodeUnsupportedMediaType = "unsupported_media_type"
+	ErrCodeMethodNotAllowed     = "method_not_allowed"
+	ErrCodeMissingAPIKey        = "missing_api_key"
+	ErrCodeInvalidAPIKey        = "invalid_api_key"
+	ErrCodeRateLimitExceeded    = "rate_limit_exceeded"
+	ErrCodeIPBlocked            = "ip_blocked"
+	ErrCodeAdminRequired        = "admin_required"
+	ErrCodeConfirmationRequired = "confirmation_required"
+	ErrCodeNotFound             = "not_found"
+	ErrCodeConflict             = "conflict"            // Operation already in progress
+	ErrCodeScanLimitExceeded    = "scan_limit_exceeded" // Query would read more rows than allowed
+	ErrCodeStorageMiss          = "storage_miss"        // Registry entry exists but its stored content does not
+	ErrCodeStorageUnavailable   = "storage_unavailable" // ClickHouse, Redis or MinIO request failed
+	ErrCodeBloomUnavailable     = "bloom_unavailable"
+	ErrCodeFeatureUnavailable   = "feature_unavailable" // Optional component disabled or unreachable
+	ErrCodeInternal             = "internal_error"
+)
+
+// NewProblem builds a Problem for an error code
+func NewProblem(status int, code, title, detail string) Problem {
+	return Problem{
+		Type:   "urn:tip:problem:" + code,
+		Title:  title,
+		Status: status,
+		Detail: detail,
+		Code:   code,
+	}
 }
 
 // ========== Ingestor Models ==========
 
+// IngestRunRequest asks watch-mode ingestors to crawl now; exactly one of
+// Path and Feed is set
+type IngestRunRequest struct {
+	Path string `json:"path,omitempty"` // Absolute, or relative to the first DATA_PATH root
+	Feed string `json:"feed,omitempty"` // Source name; every directory attributed to it is crawled
+}
+
+// IngestRun is an on-demand crawl relayed from the API to the ingestors
+type IngestRun struct {
+	ID          string    `json:"id"`
+	Path        string    `json:"path,omitempty"`
+	Feed        string    `json:"feed,omitempty"`
+	Paths       []string  `json:"paths,omitempty"` // Individual files to process again
+	RequestedAt time.Time `json:"requested_at"`
+}
+
+// ReprocessRequest selects registered files to ingest again, by ID or by
+// scan status; exactly one of FileIDs and Status is set
+type ReprocessRequest struct {
+	FileIDs []string   `json:"file_ids,omitempty"`
+	Status  ScanStatus `json:"status,omitempty"` // failed, timeout or oversized
+	Reason  string     `json:"reason,omitempty"` // Recorded in the audit log
+}
+
+// ReprocessResponse reports the files reset for reprocessing
+type ReprocessResponse struct {
+	Files     []FileMetadata `json:"files"`               // As registered before the reset
+	NotFound  []string       `json:"not_found,omitempty"` // Requested IDs with no registry entry, or deleted
+	More      bool           `json:"more,omitempty"`      // The status matched more files than one request resets
+	RunID     string         `json:"run_id,omitempty"`
+	Ingestors int64          `json:"ingestors"` // Watch-mode ingestors notified; with none, the next crawl picks the files up
+	Timestamp time.Time      `json:"timestamp"`
+}
+
+// DeleteRequest is the body of POST /admin/delete
+type DeleteRequest struct {
+	FileIDs []string `json:"file_ids,omitempty"` // Registry entries to delete, with the IOCs extracted from them
+	IOCs    []string `json:"iocs,omitempty"`     // IOC values to delete from every source
+	Purge   bool     `json:"purge,omitempty"`    // Remove the rows rather than mark them deleted
+	Objects string   `json:"objects,omitempty"`  // What to do with stored content; MINIO_DELETE_POLICY by default
+	Reason  string   `json:"reason,omitempty"`   // Recorded in the audit log
+}
+
+// Delete policies for the stored content of deleted files
+const (
+	DeletePolicyRetain = "retain" // Keep the object; /context can still serve a soft-deleted file
+	DeletePolicyDelete = "delete" // Remove the object unless another registry entry references it
+)
+
+// DeleteResponse reports what POST /admin/delete removed
+type DeleteResponse struct {
+	Files           []string  `json:"files"`               // File IDs deleted
+	IOCValues       int       `json:"ioc_values"`          // Distinct values whose rows were deleted, from the request or the files
+	NotFound        []string  `json:"not_found,omitempty"` // Requested IDs and values with nothing to delete
+	Purged          bool      `json:"purged"`
+	ObjectsDeleted  []string  `json:"objects_deleted,omitempty"`
+	ObjectsRetained []string  `json:"objects_retained,omitempty"` // Kept by policy or still referenced by another file
+	Timestamp       time.Time `json:"timestamp"`
+}
+
+// RevealRequest is the body of POST /admin/reveal
+type RevealRequest struct {
+	Values []string `json:"values"`           // Redacted email values, as stored
+	Reason string   `json:"reason,omitempty"` // Recorded in the audit log
+}
+
+// RevealResponse maps redacted email values to the addresses they stand for
+type RevealResponse struct {
+	Revealed  map[string]string `json:"revealed"`
+	NotFound  []string          `json:"not_found,omitempty"` // Values with no sealed original, e.g. synced from a primary
+	Timestamp time.Time         `json:"timestamp"`
+}
+
+// DuplicateFile is one registration of content registered more than once
+type DuplicateFile struct {
+	FileID      string     `json:"file_id"`
+	FilePath    string     `json:"file_path"`
+	ScanStatus  ScanStatus `json:"scan_status"`
+	IOCCount    uint32     `json:"ioc_count"`
+	ProcessedAt time.Time  `json:"processed_at"`
+}
+
+// DuplicateFiles is content registered under more than one active file ID:
+// copies, or renamed files whose old registration was never tombstoned
+type DuplicateFiles struct {
+	ContentHash string          `json:"content_hash"`
+	Files       []DuplicateFile `json:"files"` // Most recently processed first
+}
+
+// DuplicateReport is a page of GET /admin/files/duplicates
+type DuplicateReport struct {
+	Groups         []DuplicateFiles `json:"groups"` // Most registrations first
+	TotalGroups    uint64           `json:"total_groups"`
+	RedundantFiles uint64           `json:"redundant_files"` // Registrations beyond the first of every group
+	Limit          int              `json:"limit"`
+	Offset         int              `json:"offset"`
+}
+
+// FileVersion is one recorded state of a registry entry
+type FileVersion struct {
+	FileMetadata
+	PreviousStatus ScanStatus `json:"previous_status,omitempty"` // Set when this version changed the scan status
+}
+
+// FileHistory is the response of GET /files/:file_id/history
+type FileHistory struct {
+	FileID   string        `json:"file_id"`
+	Current  FileMetadata  `json:"current"`
+	Versions []FileVersion `json:"versions"`       // Newest first
+	More     bool          `json:"more,omitempty"` // Older versions exist beyond the limit
+	Limit    int           `json:"limit"`
+}
+
+// ConsistencyReport is the latest cross-check of the IOC store, the Bloom
+// filter, MinIO and the file registry
+type ConsistencyReport struct {
+	GeneratedAt          time.Time          `json:"generated_at"`
+	DurationSeconds      float64            `json:"duration_seconds"`
+	Checks               []ConsistencyCheck `json:"checks"`
+	Issues               uint64             `json:"issues"`                 // Sum of the checks' counts
+	BloomPendingRemovals int64              `json:"bloom_pending_removals"` // Values awaiting the next Bloom filter rebuild
+}
+
+// ConsistencyCheck is the outcome of one cross-store check
+type ConsistencyCheck struct {
+	Name    string   `json:"name"`
+	Count   uint64   `json:"count"`             // Orphaned or missing entries found
+	Samples []string `json:"samples,omitempty"` // Up to CONSISTENCY_SAMPLE_SIZE of them
+	Error   string   `json:"error,omitempty"`   // Set when the check could not complete
+}
+
+// Consistency checks
+const (
+	ConsistencyBloomMissing   = "bloom_missing"   // Active IOC values the Bloom filter does not hold
+	ConsistencyOrphanObjects  = "orphan_objects"  // MinIO objects no registry entry references
+	ConsistencyMissingObjects = "missing_objects" // Registry MinIO keys with no object
+	ConsistencyOrphanIOCs     = "orphan_iocs"     // File IDs of active IOCs with no active registry entry
+	ConsistencyMissingIOCs    = "missing_iocs"    // Infected registry entries with no IOC rows
+)
+
+// VolumeKey identifies an ingestion volume series: the IOCs of one type
+// from one feed
+type VolumeKey struct {
+	Feed string  `json:"feed"` // DATA_PATH source name or directory, or submission:<source>
+	Type IOCType `json:"type"`
+}
+
+// VolumeCounts counts IOCs by type toward feed, or toward their source file
+// ID when feed is "" (submissions and imports)
+func VolumeCounts(feed string, iocs []IOC) map[VolumeKey]int64 {
+	counts := make(map[VolumeKey]int64)
+	for _, ioc := range iocs {
+		k := VolumeKey{Feed: feed, Type: ioc.Type}
+		if feed == "" {
+			k.Feed = ioc.SourceFileID
+		}
+		counts[k]++
+	}
+	return counts
+}
+
+// VolumeReport is the latest check of ingestion volume against its baseline
+type VolumeReport struct {
+	GeneratedAt   time.Time       `json:"generated_at"`
+	WindowStart   time.Time       `json:"window_start"`   // Window under check
+	WindowEnd     time.Time       `json:"window_end"`
+	BaselineStart time.Time       `json:"baseline_start"` // Start of the windows the baseline is taken from
+	Series        int             `json:"series"`         // Feed and type pairs checked
+	WarmingUp     bool            `json:"warming_up"`     // Counts do not cover the baseline yet; nothing is reported
+	Anomalies     []VolumeAnomaly `json:"anomalies"`
+}
+
+// VolumeAnomaly is a feed and IOC type whose volume departs from its
+// baseline
+type VolumeAnomaly struct {
+	VolumeKey
+	Kind     string    `json:"kind"`     // "drop" or "spike"
+	Count    int64     `json:"count"`    // IOCs ingested in the window
+	Baseline float64   `json:"baseline"` // Median of the preceding windows
+	Since    time.Time `json:"since"`    // First check that reported it
+}
+
+// Volume anomaly kinds
+const (
+	VolumeDrop  = "drop"  // Typically a broken feed
+	VolumeSpike = "spike" // Typically a parser false-positive explosion
+)
+
+// ExtractionSample is an extracted IOC kept with the text around it for an
+// analyst to label, so the precision of each pattern can be estimated
+type ExtractionSample struct {
+	ID               string     `json:"id"`
+	Value            string     `json:"value"`
+	Type             IOCType    `json:"type"`
+	SourceFileID     string     `json:"source_file_id"`
+	ExtractorVersion string     `json:"extractor_version"` // Build that extracted it
+	Offset           int64      `json:"offset"`            // Byte offset of the first occurrence in the stored content, or -1
+	Snippet          string     `json:"snippet"`           // Text surrounding the first occurrence; empty when not found
+	SampledAt        time.Time  `json:"sampled_at"`
+	Label            string     `json:"label,omitempty"` // Empty until labeled
+	LabeledBy        string     `json:"labeled_by,omitempty"`
+	LabeledAt        *time.Time `json:"labeled_at,omitempty"`
+}
+
+// Extraction sample labels
+const (
+	LabelTruePositive  = "true_positive"
+	LabelFalsePositive = "false_positive"
+)
+
+// Extraction sample statuses, for GET /samples
+const (
+	SampleStatusUnlabeled = "unlabeled"
+	SampleStatusLabeled   = "labeled"
+)
+
+// SamplesResponse is a page of GET /samples
+type SamplesResponse struct {
+	Samples []ExtractionSample `json:"samples"` // Oldest first
+	Total   uint64             `json:"total"`
+	Limit   int                `json:"limit"`
+	Offset  int                `json:"offset"`
+}
+
+// SampleLabel labels one extraction sample
+type SampleLabel struct {
+	ID    string `json:"id"`
+	Label string `json:"label"` // "true_positive" or "false_positive"
+}
+
+// LabelRequest labels extraction samples; a labeled sample may be relabeled
+type LabelRequest struct {
+	Labels []SampleLabel `json:"labels"`
+}
+
+// LabelResponse reports a labeling
+type LabelResponse struct {
+	Labeled   int       `json:"labeled"`
+	Unknown   []string  `json:"unknown"` // IDs with no sample
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// PrecisionEstimate is the share of labeled samples of an IOC type that
+// were true positives, over one period or extractor version
+type PrecisionEstimate struct {
+	Type           IOCType `json:"type"`
+	Group          string  `json:"group"` // Day or week start (YYYY-MM-DD), or extractor version
+	Labeled        uint64  `json:"labeled"`
+	TruePositives  uint64  `json:"true_positives"`
+	FalsePositives uint64  `json:"false_positives"`
+	Precision      float64 `json:"precision"`
+	Lower          float64 `json:"lower"` // 95% Wilson score interval
+	Upper          float64 `json:"upper"`
+}
+
+// NewPrecisionEstimate estimates precision from labeled counts, with a
+// Wilson score interval so small samples show how little they say
+func NewPrecisionEstimate(iocType IOCType, group string, tp, fp uint64) PrecisionEstimate {
+	e := PrecisionEstimate{Type: iocType, Group: group, Labeled: tp + fp, TruePositives: tp, FalsePositives: fp}
+	if e.Labeled == 0 {
+		return e
+	}
+	const z = 1.96
+	n := float64(e.Labeled)
+	p := float64(tp) / n
+	center := (p + z*z/(2*n)) / (1 + z*z/n)
+	margin := z / (1 + z*z/n) * math.Sqrt(p*(1-p)/n+z*z/(4*n*n))
+	e.Precision = p
+	e.Lower = max(0, center-margin)
+	e.Upper = min(1, center+margin)
+	return e
+}
+
+// Precision estimate groupings, for GET /samples/precision
+const (
+	PrecisionByDay     = "day"
+	PrecisionByWeek    = "week"    // Weeks start on Monday
+	PrecisionByVersion = "version" // Extractor version
+)
+
+// PrecisionResponse is the body of GET /samples/precision
+type PrecisionResponse struct {
+	GroupBy   string              `json:"group_by"` // "day", "week" or "version"
+	Estimates []PrecisionEstimate `json:"estimates"`
+}
+
 // FileJob represents a file to be processed by the worker pool
 type FileJob struct {
 	FilePath     string
 	FileSize     int64
 	LastModified time.Time
+	Directory    string // Policy directory the file was found under
+	Priority     int    // Higher is processed first (see JobPriorityCrawl)
 }
 
+// Job priorities; analyst submissions are processed ahead of crawled files
+const (
+	JobPriorityCrawl     = 0
+	JobPrioritySubmitted = 10
+)
+
 // ProcessResult represents the result of processing a file
 type ProcessResult struct {
 	FileID     string
@@ -151,6 +976,141 @@ type ProcessResult struct {
 	IOCs       map[IOCType][]string
 	Error      error
 	Duration   time.Duration
+	Skipped    bool // Unchanged since the last scan
+	Dropped    int  // Valid IOCs discarded by the per-file/per-type caps
+	ContentHash string
+	MinIOKey    string
+	Language    string
+	FileType    string
+	Signature   string // Antivirus detection name, "" when clean or not scanned
+}
+
+// IngestionEvent is published for every file the ingestor processes
+type IngestionEvent struct {
+	FileID     string          `json:"file_id"`
+	FilePath   string          `json:"file_path"`
+	Status     ScanStatus      `json:"status"`
+	IOCCount   int             `json:"ioc_count"`
+	IOCsByType map[IOCType]int `json:"iocs_by_type,omitempty"`
+	Language   string          `json:"language,omitempty"`
+	FileType   string          `json:"file_type,omitempty"`
+	Signature  string          `json:"signature,omitempty"`
+	Error      string          `json:"error,omitempty"`
+	DurationMs int64           `json:"duration_ms"`
+	Timestamp  time.Time       `json:"timestamp"`
+}
+
+// MatchEvent is published when a new IOC is ingested or a /check lookup hits
+type MatchEvent struct {
+	Kind          string    `json:"kind"`
+	Value         string    `json:"value"`
+	Type          IOCType   `json:"type"`
+	Tags          []string  `json:"tags,omitempty"`
+	MalwareFamily string    `json:"malware_family,omitempty"`
+	Confidence    uint8     `json:"confidence"`
+	SourceFileID  string    `json:"source_file_id,omitempty"`
+	ObservedBy    string    `json:"observed_by,omitempty"` // Log source that reported the value (log_hit)
+	Timestamp     time.Time `json:"timestamp"`
+}
+
+// Match event kinds
+const (
+	MatchKindIngested = "ioc_ingested"
+	MatchKindCheckHit = "check_hit"
+	MatchKindLogHit   = "log_hit"
+)
+
+// NewMatchEvent builds a match event of the given kind for an IOC
+func NewMatchEvent(kind string, ioc IOC) MatchEvent {
+	return MatchEvent{
+		Kind:          kind,
+		Value:         ioc.Value,
+		Type:          ioc.Type,
+		Tags:          ioc.Tags,
+		MalwareFamily: ioc.MalwareFamily,
+		Confidence:    ioc.Confidence,
+		SourceFileID:  ioc.SourceFileID,
+		Timestamp:     time.Now().UTC(),
+	}
+}
+
+// Subscription is a consumer's standing request for newly ingested IOCs
+// matching a filter, fanned out to its delivery target as they arrive
+// (POST /subscriptions)
+type Subscription struct {
+	ID        string               `json:"id"`
+	Name      string               `json:"name,omitempty"`
+	Owner     string               `json:"owner"` // Hash of the API key that registered it
+	Filter    SubscriptionFilter   `json:"filter"`
+	Delivery  SubscriptionDelivery `json:"delivery"`
+	CreatedAt time.Time            `json:"created_at"`
+
+	Status *SubscriptionStatus `json:"status,omitempty"` // Delivery statistics, on reads
+}
+
+// SubscriptionRequest registers a subscription
+type SubscriptionRequest struct {
+	Name     string               `json:"name,omitempty"`
+	Filter   SubscriptionFilter   `json:"filter"`
+	Delivery SubscriptionDelivery `json:"delivery"` // Secret is generated for webhooks
+}
+
+// SubscriptionFilter selects the IOCs a subscription receives. Empty fields
+// match everything.
+type SubscriptionFilter struct {
+	Types         []IOCType `json:"types,omitempty"`
+	Tags          []string  `json:"tags,omitempty"` // IOCs carrying any of them
+	MinConfidence uint8     `json:"min_confidence,omitempty"`
+}
+
+// Matches reports whether an ingested IOC passes the filter
+func (f SubscriptionFilter) Matches(event MatchEvent) bool {
+	if len(f.Types) > 0 && !slices.Contains(f.Types, event.Type) {
+		return false
+	}
+	if len(f.Tags) > 0 && !slices.ContainsFunc(event.Tags, func(t string) bool { return slices.Contains(f.Tags, t) }) {
+		return false
+	}
+	return event.Confidence >= f.MinConfidence
+}
+
+// SubscriptionDelivery says where a subscription's IOCs go. Secrets are
+// stored with it but never returned, except the webhook secret at creation.
+type SubscriptionDelivery struct {
+	Method        string `json:"method"`                  // "webhook", "kafka" or "taxii"
+	URL           string `json:"url,omitempty"`           // Webhook endpoint, or TAXII 2.1 collection URL
+	Topic         string `json:"topic,omitempty"`         // Kafka topic
+	Authorization string `json:"authorization,omitempty"` // Authorization header sent with webhook and TAXII requests
+	Secret        string `json:"secret,omitempty"`        // Key of the webhook's X-TIP-Signature HMAC
+}
+
+// Subscription delivery methods
+const (
+	DeliveryWebhook = "webhook" // POST of a JSON batch of match events
+	DeliveryKafka   = "kafka"   // Match events on a Kafka topic
+	DeliveryTAXII   = "taxii"   // STIX 2.1 indicators added to a TAXII 2.1 collection
+)
+
+// SubscriptionStatus records how deliveries to a subscription went
+type SubscriptionStatus struct {
+	Delivered    uint64     `json:"delivered"` // IOCs delivered
+	Failed       uint64     `json:"failed"`    // IOCs whose delivery failed and was dropped
+	LastDelivery *time.Time `json:"last_delivery,omitempty"`
+	LastError    string     `json:"last_error,omitempty"`
+	LastErrorAt  *time.Time `json:"last_error_at,omitempty"`
+}
+
+// IngestCheckpoint records the outcome of the last ingestion run
+type IngestCheckpoint struct {
+	StartedAt      time.Time `json:"started_at"`
+	FinishedAt     time.Time `json:"finished_at"`
+	Completed      bool      `json:"completed"` // False if the run was interrupted
+	LastPath       string    `json:"last_path,omitempty"`
+	FilesProcessed int64     `json:"files_processed"`
+	FilesSkipped   int64     `json:"files_skipped"`
+	FilesFailed    int64     `json:"files_failed"`
+	FilesAbandoned int64     `json:"files_abandoned"`
+	IOCsExtracted  int64     `json:"iocs_extracted"`
 }
 
 // BatchInsert represents a batch of IOCs to insert
@@ -181,3 +1141,281 @@ type APIStats struct {
 	ClickHouseHits  int64 `json:"clickhouse_hits"`
 	AverageLatency  int64 `json:"average_latency_ms"`
 }
+
+// FreshnessStats describes how current the IOC corpus is
+type FreshnessStats struct {
+	Buckets      []string            `json:"buckets"`        // Age bucket labels, doubling in width
+	FirstSeenAge map[IOCType][]int64 `json:"first_seen_age"` // Counts per bucket, by type
+	LastSeenAge  map[IOCType][]int64 `json:"last_seen_age"`
+	Expired      map[IOCType]int64   `json:"expired"` // Active IOCs past their valid_until
+	Feeds        []FeedFreshness     `json:"feeds"`   // Stalest first
+}
+
+// FeedFreshness describes how current one ingested feed document is
+type FeedFreshness struct {
+	FileID          string     `json:"file_id"`
+	FilePath        string     `json:"file_path"`
+	Format          string     `json:"format"`
+	LastModified    time.Time  `json:"last_modified"`
+	StaleDays       int64      `json:"stale_days"` // Since the feed file last changed
+	IOCCount        uint32     `json:"ioc_count"`
+	NewestIndicator *time.Time `json:"newest_indicator,omitempty"` // Latest first_seen among its IOCs
+	Expired         uint64     `json:"expired"`
+}
+
+// Dimensions of GET /stats/top
+const (
+	TopDimensionQueried       = "queried"        // Most looked-up indicator values
+	TopDimensionMalwareFamily = "malware_family" // Families of the indicators matched most often
+	TopDimensionSourceFile    = "source_file"    // Source files whose indicators matched most often
+)
+
+// TopEntry is one row of a top-N ranking
+type TopEntry struct {
+	Key      string `json:"key"`
+	Count    uint64 `json:"count"`               // Lookups, or matches for malware_family and source_file
+	Found    uint64 `json:"found,omitempty"`     // queried: lookups that matched
+	FilePath string `json:"file_path,omitempty"` // source_file: path of the file
+}
+
+// TopResponse is the response for GET /stats/top
+type TopResponse struct {
+	Dimension string     `json:"dimension"`
+	Since     time.Time  `json:"since"`
+	Entries   []TopEntry `json:"entries"`
+	QueryTime string     `json:"query_time"`
+}
+
+// Merge policies for POST /admin/import, applied when an imported value is
+// already known locally
+const (
+	ImportPolicyKeepHigherConfidence = "keep_higher_confidence" // Import only if more confident than the local value
+	ImportPolicyTagUnion             = "tag_union"              // Always import, merging local tags and keeping the higher confidence
+)
+
+// Import formats accepted by POST /admin/import
+const (
+	ImportFormatTIP   = "tip"   // Pages of GET /sync/iocs
+	ImportFormatSTIX2 = "stix2" // STIX 2.x bundle
+)
+
+// ImportResponse summarises an import
+type ImportResponse struct {
+	Format    string `json:"format"`
+	Policy    string `json:"policy"`
+	Source    string `json:"source"`
+	Received  int    `json:"received"`  // Indicators in the document
+	Imported  int    `json:"imported"`  // Distinct values stored
+	Skipped   int    `json:"skipped"`   // Values the local corpus already knew with higher confidence
+	Rejected  int    `json:"rejected"`  // Invalid values
+	Corrected int    `json:"corrected"` // Values stored under their detected type
+
+	Issues        []SubmissionIssue `json:"issues,omitempty"`         // Rejected and corrected rows, in document order
+	IssuesOmitted int               `json:"issues_omitted,omitempty"` // Issues beyond the reported limit
+}
+
+// IOCFilter selects active IOC rows for a bulk operation. Conditions are
+// ANDed; lists match any of their entries.
+type IOCFilter struct {
+	Tags          []string   `json:"tags,omitempty"`            // Rows carrying any of these tags
+	SourceFileIDs []string   `json:"source_file_ids,omitempty"` // Rows extracted from these files or submitted by these sources
+	Type          IOCType    `json:"type,omitempty"`
+	Since         *time.Time `json:"since,omitempty"` // Last seen at or after
+	Until         *time.Time `json:"until,omitempty"` // Last seen before
+}
+
+// IsEmpty reports whether the filter selects every row
+func (f IOCFilter) IsEmpty() bool {
+	return len(f.Tags) == 0 && len(f.SourceFileIDs) == 0 && f.Type == "" && f.Since == nil && f.Until == nil
+}
+
+// UnmarshalJSON reads since and until in any form ParseTime accepts,
+// relative times counting from now, and rejects unknown fields
+func (f *IOCFilter) UnmarshalJSON(data []byte) error {
+	type plain IOCFilter
+	var raw struct {
+		*plain
+		Since json.RawMessage `json:"since,omitempty"`
+		Until json.RawMessage `json:"until,omitempty"`
+	}
+	raw.plain = (*plain)(f)
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&raw); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	var err error
+	if f.Since, err = decodeTime(raw.Since, now); err != nil {
+		return fmt.Errorf("since: %w", err)
+	}
+	if f.Until, err = decodeTime(raw.Until, now); err != nil {
+		return fmt.Errorf("until: %w", err)
+	}
+	return nil
+}
+
+// Response time formats (API_TIME_FORMAT, ?time_format=)
+const (
+	TimeFormatRFC3339 = "rfc3339" // Timestamps as stored
+	TimeFormatBoth    = "both"    // RFC 3339 in UTC plus epoch milliseconds in "<field>_ms"
+)
+
+// epochMillisThreshold separates epoch seconds from milliseconds: 1e11
+// seconds is past the year 5000, 1e11 milliseconds early 1973
+const epochMillisThreshold = 100_000_000_000
+
+// ParseTime reads a time filter in any form the API accepts: RFC 3339, Unix
+// epoch seconds or milliseconds, or an offset from now such as -24h or -7d
+// ("now" alone is now). The result is in UTC.
+func ParseTime(s string, now time.Time) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	switch {
+	case s == "":
+		return time.Time{}, errors.New("empty time")
+	case s == "now":
+		return now.UTC(), nil
+	case s[0] == '-' || s[0] == '+':
+		d, err := parseOffset(s)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid relative time %q, e.g. -24h or -7d", s)
+		}
+		return now.Add(d).UTC(), nil
+	}
+
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+		if n >= epochMillisThreshold {
+			return time.UnixMilli(n).UTC(), nil
+		}
+		return time.Unix(n, 0).UTC(), nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %q: use RFC 3339, epoch seconds or milliseconds, or an offset such as -24h", s)
+	}
+	return t.UTC(), nil
+}
+
+// parseOffset reads a signed duration, allowing whole days ("-7d")
+func parseOffset(s string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, err
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	return time.ParseDuration(s)
+}
+
+// decodeTime reads a JSON time filter, a string or an epoch number, with
+// ParseTime; null or absent is nil
+func decodeTime(raw json.RawMessage, now time.Time) (*time.Time, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, nil
+	}
+	s := string(raw)
+	if raw[0] == '"' {
+		if err := json.Unmarshal(raw, &s); err != nil {
+			return nil, err
+		}
+	}
+	t, err := ParseTime(s, now)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// IOCUpdate is the curation a bulk update applies to each selected row
+type IOCUpdate struct {
+	AddTags       []string `json:"add_tags,omitempty"`
+	RemoveTags    []string `json:"remove_tags,omitempty"` // Applied before AddTags
+	Confidence    *uint8   `json:"confidence,omitempty"`
+	MalwareFamily string   `json:"malware_family,omitempty"`
+}
+
+// IsEmpty reports whether the update changes nothing
+func (u IOCUpdate) IsEmpty() bool {
+	return len(u.AddTags) == 0 && len(u.RemoveTags) == 0 && u.Confidence == nil && u.MalwareFamily == ""
+}
+
+// BulkUpdateRequest is the body of POST /iocs/bulk-update
+type BulkUpdateRequest struct {
+	Filter IOCFilter `json:"filter"`
+	IOCUpdate
+	Reason string `json:"reason,omitempty"` // Recorded in the audit log
+	DryRun bool   `json:"dry_run,omitempty"` // Count the selected rows without updating them
+}
+
+// BulkUpdateResponse reports a bulk update
+type BulkUpdateResponse struct {
+	Matched   uint64    `json:"matched"` // Active rows the filter selected
+	DryRun    bool      `json:"dry_run"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// ReviewQueueResponse is a page of GET /review
+type ReviewQueueResponse struct {
+	IOCs   []IOC  `json:"iocs"` // Longest waiting first
+	Total  uint64 `json:"total"`
+	Limit  int    `json:"limit"`
+	Offset int    `json:"offset"`
+}
+
+// ReviewRequest approves or rejects pending IOCs
+type ReviewRequest struct {
+	Values []string `json:"values"`
+	Reason string   `json:"reason,omitempty"` // Recorded in the audit log
+}
+
+// ReviewResponse reports a review decision
+type ReviewResponse struct {
+	Action       string    `json:"action"` // "approve" or "reject"
+	Values       []string  `json:"values"` // Canonical forms
+	RowsAffected uint64    `json:"rows_affected"`
+	Timestamp    time.Time `json:"timestamp"`
+}
+
+// FileIOC is an indicator extracted from a file, located in the file's stored
+// content when it could be found there
+type FileIOC struct {
+	IOC
+	Offset  *int   `json:"offset,omitempty"`  // Byte offset of the first occurrence
+	Snippet string `json:"snippet,omitempty"` // Text surrounding the first occurrence
+}
+
+// FileIOCsResponse is a page of GET /context/:file_id/iocs
+type FileIOCsResponse struct {
+	FileID   string    `json:"file_id"`
+	FilePath string    `json:"file_path"`
+	IOCs     []FileIOC `json:"iocs"` // By type, then value
+	Total    uint64    `json:"total"`
+	Limit    int       `json:"limit"`
+	Offset   int       `json:"offset"`
+	Located  bool      `json:"located"` // Whether stored content was searched for offsets and snippets
+}
+
+// SyncIOC is an IOC row as replicated between deployments
+type SyncIOC struct {
+	IOC
+	IngestedAt time.Time `json:"ingested_at"`
+}
+
+// SyncCursor is the position of a replica in the primary's IOC store: the
+// last row it applied, in (ingested_at, ioc_value, source_file_id) order
+type SyncCursor struct {
+	IngestedAt   time.Time `json:"t"`
+	Value        string    `json:"v"`
+	SourceFileID string    `json:"s"`
+}
+
+// SyncResponse is a page of GET /sync/iocs. Cursor is passed back to fetch
+// the next page; More is false once the replica has caught up.
+type SyncResponse struct {
+	IOCs   []SyncIOC `json:"iocs"`
+	Cursor string    `json:"cursor"`
+	More   bool      `json:"more"`
+}
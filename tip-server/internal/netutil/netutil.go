@@ -0,0 +1,117 @@
+// Package netutil holds IP classification and DNS resolver helpers shared by
+// the extractor, background jobs and API handlers.
+package netutil
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"slices"
+	"strings"
+)
+
+// IsPublic reports whether ip is globally routable: not private, loopback,
+// link-local, unspecified or multicast
+func IsPublic(ip net.IP) bool {
+	return !(ip.IsPrivate() || ip.IsLoopback() || ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() || ip.IsUnspecified() || ip.IsMulticast())
+}
+
+// NewResolver returns the system resolver, or one that sends every query to
+// server (host:port)
+func NewResolver(server string) *net.Resolver {
+	if server == "" {
+		return net.DefaultResolver
+	}
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, network, server)
+		},
+	}
+}
+
+// ParseNetworks parses CIDRs and bare IPs (as single-host networks),
+// skipping invalid entries
+func ParseNetworks(entries []string) []*net.IPNet {
+	var nets []*net.IPNet
+	for _, e := range entries {
+		e = strings.TrimSpace(e)
+		if _, n, err := net.ParseCIDR(e); err == nil {
+			nets = append(nets, n)
+			continue
+		}
+		if ip := net.ParseIP(e); ip != nil {
+			bits := 128
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 32
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+		}
+	}
+	return nets
+}
+
+// InNetworks reports whether ip falls in any of nets
+func InNetworks(ip net.IP, nets []*net.IPNet) bool {
+	for _, n := range nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// PrefixTable maps IP prefixes to values and finds the most specific prefix
+// containing an address. It is built once and then only read, so it is safe
+// for concurrent lookups.
+type PrefixTable[V any] struct {
+	byLen map[int]map[netip.Prefix]V
+	lens  []int // Prefix lengths present, longest first
+}
+
+// NewPrefixTable returns an empty table
+func NewPrefixTable[V any]() *PrefixTable[V] {
+	return &PrefixTable[V]{byLen: make(map[int]map[netip.Prefix]V)}
+}
+
+// Insert adds a prefix, replacing any value stored for the same network
+func (t *PrefixTable[V]) Insert(p netip.Prefix, v V) {
+	p = p.Masked()
+	bits := p.Bits()
+	if t.byLen[bits] == nil {
+		t.byLen[bits] = make(map[netip.Prefix]V)
+		t.lens = append(t.lens, bits)
+		slices.SortFunc(t.lens, func(a, b int) int { return b - a })
+	}
+	t.byLen[bits][p] = v
+}
+
+// Lookup returns the longest stored prefix containing addr
+func (t *PrefixTable[V]) Lookup(addr netip.Addr) (netip.Prefix, V, bool) {
+	addr = addr.Unmap()
+	for _, bits := range t.lens {
+		if bits > addr.BitLen() {
+			continue
+		}
+		p, err := addr.Prefix(bits)
+		if err != nil {
+			continue
+		}
+		if v, ok := t.byLen[bits][p]; ok {
+			return p, v, true
+		}
+	}
+	var zero V
+	return netip.Prefix{}, zero, false
+}
+
+// Len returns the number of stored prefixes
+func (t *PrefixTable[V]) Len() int {
+	n := 0
+	for _, m := range t.byLen {
+		n += len(m)
+	}
+	return n
+}
@@ -0,0 +1,185 @@
+// Package normalize canonicalizes indicator values, so one indicator written
+// several ways is stored and looked up under a single form. The extractor
+// applies it to the values it stores and /check to the values it is asked
+// about.
+package normalize
+
+import (
+	"net"
+	"net/netip"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/idna"
+
+	"tip-server/internal/language"
+)
+
+var (
+	// defangs are the bracketed forms reports use to keep indicators
+	// from being clickable
+	defangs = strings.NewReplacer(
+		"[.]", ".", "(.)", ".", "{.}", ".", "[dot]", ".", "(dot)", ".",
+		"[@]", "@", "[at]", "@", "(at)", "@",
+		"[:]", ":", "[://]", "://",
+	)
+
+	// defangedScheme matches hxxp:// and hxxps:// in any case
+	defangedScheme = regexp.MustCompile(`(?i)^h(?:xx|tt)p(s?)://`)
+
+	// asnForm matches an AS number with or without an AS/ASN prefix
+	asnForm = regexp.MustCompile(`(?i)^asn?[ :]*([0-9]{1,10})$`)
+)
+
+// defaultPorts are ports implied by a URL scheme
+var defaultPorts = map[string]string{
+	"http":  "80",
+	"https": "443",
+	"ftp":   "21",
+}
+
+// Value canonicalizes a single indicator of any type, detected from its
+// form. Values that are not addresses, blocks, URLs, hashes, emails,
+// hostnames or AS numbers are only sanitized and trimmed.
+func Value(s string) string {
+	s = Refang(Sanitize(s))
+
+	switch {
+	case s == "":
+		return s
+	case strings.Contains(s, "://"):
+		return URL(s)
+	case strings.Contains(s, "/"):
+		return CIDR(s)
+	case strings.Contains(s, "@"):
+		return Email(s)
+	}
+
+	if ip, ok := IP(s); ok {
+		return ip
+	}
+	if m := asnForm.FindStringSubmatch(s); m != nil {
+		if n, err := strconv.ParseUint(m[1], 10, 32); err == nil {
+			return "AS" + strconv.FormatUint(n, 10)
+		}
+	}
+	if isHex(s) {
+		return strings.ToLower(s)
+	}
+	if strings.Contains(s, ".") {
+		return Domain(s)
+	}
+	return s
+}
+
+// Sanitize strips invisible characters and folds fullwidth forms, so a value
+// padded or disguised to evade exact matching compares equal to the plain
+// one. Cyrillic or Greek lookalikes are kept: a homograph is a different
+// indicator from the domain it imitates, stored in punycode.
+func Sanitize(s string) string {
+	return string(language.Normalize([]byte(s)))
+}
+
+// Refang trims s and undoes common defanging: hxxp schemes and bracketed
+// dots, at-signs and colons
+func Refang(s string) string {
+	s = defangs.Replace(strings.TrimSpace(s))
+	return defangedScheme.ReplaceAllString(s, "http$1://")
+}
+
+// IP returns an address in canonical form: surrounding brackets and zones
+// dropped, IPv4-mapped IPv6 as IPv4 and IPv6 compressed per RFC 5952
+func IP(s string) (string, bool) {
+	s = strings.TrimSuffix(strings.TrimPrefix(s, "["), "]")
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return s, false
+	}
+	return addr.WithZone("").Unmap().String(), true
+}
+
+// CIDR returns a block masked to its network address, or s unchanged when it
+// is not a block
+func CIDR(s string) string {
+	p, err := netip.ParsePrefix(s)
+	if err != nil {
+		return s
+	}
+	return p.Masked().String()
+}
+
+// Domain returns a hostname lowercased, without a trailing dot and with
+// internationalized labels in punycode. Values that are not hostnames, such
+// as mutex names, are returned unchanged.
+func Domain(s string) string {
+	lower := strings.TrimSuffix(strings.ToLower(s), ".")
+	if lower == "" {
+		return s
+	}
+	if strings.Trim(lower, "abcdefghijklmnopqrstuvwxyz0123456789.-_") == "" {
+		return lower
+	}
+	if ascii, err := idna.Lookup.ToASCII(lower); err == nil {
+		return ascii
+	}
+	return s
+}
+
+// Email returns an address lowercased with its domain canonicalized
+func Email(s string) string {
+	local, domain, ok := strings.Cut(strings.ToLower(s), "@")
+	if !ok || local == "" || strings.ContainsAny(s, " \t") {
+		return s
+	}
+	return local + "@" + Domain(domain)
+}
+
+// URL returns a URL with its scheme and host canonicalized, the scheme's
+// default port and any fragment removed. Paths and queries are kept as
+// written, since servers may treat them case-sensitively.
+func URL(s string) string {
+	u, err := url.Parse(s)
+	if err != nil || u.Host == "" {
+		return s
+	}
+
+	u.Scheme = strings.ToLower(u.Scheme)
+	host, port := u.Hostname(), u.Port()
+	if ip, ok := IP(host); ok {
+		host = ip
+	} else {
+		host = Domain(host)
+	}
+	if port == defaultPorts[u.Scheme] {
+		port = ""
+	}
+
+	switch {
+	case port != "":
+		u.Host = net.JoinHostPort(host, port)
+	case strings.Contains(host, ":"):
+		u.Host = "[" + host + "]"
+	default:
+		u.Host = host
+	}
+	u.Fragment, u.RawFragment = "", ""
+	return u.String()
+}
+
+// isHex reports whether s is a hex string of a common digest length
+func isHex(s string) bool {
+	switch len(s) {
+	case 32, 40, 64, 128:
+	default:
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i] | 0x20
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
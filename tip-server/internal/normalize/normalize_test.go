@@ -0,0 +1,65 @@
+package normalize
+
+import "testing"
+
+func TestValue(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"domain case and trailing dot", "Evil.EXAMPLE.com.", "evil.example.com"},
+		{"defanged domain", "evil[.]example(.)com", "evil.example.com"},
+		{"idn domain", "bücher.de", "xn--bcher-kva.de"},
+		{"not a hostname", "Global\\MutexName", "Global\\MutexName"},
+		{"url default port and fragment", "HTTP://Evil.Example:80/Gate.php?id=1#top", "http://evil.example/Gate.php?id=1"},
+		{"url other port", "https://evil.example:8443/", "https://evil.example:8443/"},
+		{"defanged url", "hxxps://evil[.]example/a", "https://evil.example/a"},
+		{"url ipv6 host", "http://[2001:DB8:0:0::1]:80/x", "http://[2001:db8::1]/x"},
+		{"ipv4", " 203.0.113.77 ", "203.0.113.77"},
+		{"ipv6 compression", "2001:0db8:0000:0000:0000:0000:0000:0001", "2001:db8::1"},
+		{"bracketed ipv6 with zone", "[fe80::1%eth0]", "fe80::1"},
+		{"ipv4-mapped ipv6", "::ffff:203.0.113.77", "203.0.113.77"},
+		{"cidr masked", "203.0.113.77/24", "203.0.113.0/24"},
+		{"email", "Invoices[@]Payments-Portal.COM", "invoices@payments-portal.com"},
+		{"hash", "D41D8CD98F00B204E9800998ECF8427E", "d41d8cd98f00b204e9800998ecf8427e"},
+		{"asn", "asn 64496", "AS64496"},
+		{"empty", "   ", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Value(tt.in); got != tt.want {
+				t.Errorf("Value(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSanitize(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"zero-width joiner", "evil\u200d.example", "evil.example"},
+		{"right-to-left override", "http://evil.example/\u202efdp.exe", "http://evil.example/fdp.exe"},
+		{"fullwidth", "ｅｖｉｌ．ｅｘａｍｐｌｅ", "evil.example"},
+		{"homograph domain", "аpple.com", "xn--pple-43d.com"}, // Cyrillic а
+		{"pure cyrillic domain", "пример.рф", "xn--e1afmkfd.xn--p1ai"},
+		{"mixed-script word", "pаypаl", "pаypаl"}, // Not a hostname; kept as written
+		{"pure greek word", "κόσμος", "κόσμος"},
+		{"ascii", "evil.example", "evil.example"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Sanitize(tt.in); got != tt.want {
+				t.Errorf("Sanitize(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+
+	// A homograph must not collide with the domain it imitates
+	if Value("аpple.com") == Value("apple.com") {
+		t.Error("homograph canonicalized to the domain it imitates")
+	}
+}
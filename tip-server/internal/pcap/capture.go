@@ -0,0 +1,399 @@
+package pcap
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net/netip"
+	"time"
+)
+
+// Kinds of application-layer observation
+const (
+	KindDNSQuery = "dns-query"
+	KindHTTPHost = "http-host"
+	KindHTTPURL  = "http-url"
+	KindTLSSNI   = "tls-sni"
+)
+
+// Limits that keep a large capture from growing the summary without bound
+const (
+	maxFlows        = 100000
+	maxObservations = 100000
+)
+
+// Link-layer header types (https://www.tcpdump.org/linktypes.html)
+const (
+	linkNull     = 0
+	linkEthernet = 1
+	linkRaw      = 101
+	linkRawAlt   = 12 // DLT_RAW on OpenBSD
+	linkLoop     = 108
+	linkLinuxSLL = 113
+	linkIPv4     = 228
+	linkIPv6     = 229
+	linkLinuxSL2 = 276
+)
+
+// EtherTypes and IP protocol numbers
+const (
+	etherIPv4   = 0x0800
+	etherIPv6   = 0x86dd
+	etherVLAN   = 0x8100
+	etherQinQ   = 0x88a8
+	protoTCP    = 6
+	protoUDP    = 17
+	protoICMP   = 1
+	protoICMPv6 = 58
+)
+
+// Flow is a bidirectional conversation between two endpoints. Src is the
+// endpoint that sent the first packet seen.
+type Flow struct {
+	Protocol string
+	Src      netip.AddrPort
+	Dst      netip.AddrPort
+	Packets  int
+	Bytes    int
+	First    time.Time
+	Last     time.Time
+}
+
+// String renders the flow as "tcp 10.0.0.5:51234 > 93.184.216.34:443"
+func (f Flow) String() string {
+	if f.Protocol != "tcp" && f.Protocol != "udp" {
+		return fmt.Sprintf("%s %s > %s", f.Protocol, f.Src.Addr(), f.Dst.Addr())
+	}
+	return fmt.Sprintf("%s %s > %s", f.Protocol, f.Src, f.Dst)
+}
+
+// Observation is a hostname or URL seen in application-layer traffic
+type Observation struct {
+	Kind  string
+	Value string
+	Flow  int // Index into Capture.Flows, -1 once maxFlows is reached
+	Time  time.Time
+}
+
+// Capture is the indicator-bearing summary of a capture file
+type Capture struct {
+	Flows        []Flow
+	Observations []Observation
+	Packets      int
+	Undecoded    int // Frames with an unsupported link or network layer
+}
+
+// flowKey identifies a conversation regardless of direction
+type flowKey struct {
+	proto  uint8
+	lo, hi netip.AddrPort
+}
+
+// observationKey deduplicates repeated queries on the same flow
+type observationKey struct {
+	kind, value string
+	flow        int
+}
+
+// builder accumulates a Capture
+type builder struct {
+	capture *Capture
+	flows   map[flowKey]int
+	seen    map[observationKey]bool
+}
+
+// Parse reads a capture file in the given format
+func Parse(format string, content []byte) (*Capture, error) {
+	b := &builder{
+		capture: &Capture{},
+		flows:   make(map[flowKey]int),
+		seen:    make(map[observationKey]bool),
+	}
+	err := readPackets(format, content, b.packet)
+	if err != nil && len(b.capture.Flows) == 0 {
+		return nil, err
+	}
+	// A capture cut short by a full disk or killed tcpdump is still worth
+	// reading up to the truncation point
+	return b.capture, nil
+}
+
+// packet decodes one frame down to the transport layer
+func (b *builder) packet(p packet) {
+	b.capture.Packets++
+
+	network, data, ok := linkPayload(p.linkType, p.data)
+	if !ok {
+		b.capture.Undecoded++
+		return
+	}
+
+	var (
+		src, dst netip.Addr
+		proto    uint8
+	)
+	switch network {
+	case etherIPv4:
+		src, dst, proto, data, ok = ipv4Payload(data)
+	case etherIPv6:
+		src, dst, proto, data, ok = ipv6Payload(data)
+	default:
+		ok = false
+	}
+	if !ok {
+		b.capture.Undecoded++
+		return
+	}
+
+	var srcPort, dstPort uint16
+	switch proto {
+	case protoTCP:
+		if len(data) < 20 {
+			return
+		}
+		srcPort, dstPort = binary.BigEndian.Uint16(data), binary.BigEndian.Uint16(data[2:])
+		offset := int(data[12]>>4) * 4
+		if offset < 20 || offset > len(data) {
+			return
+		}
+		data = data[offset:]
+	case protoUDP:
+		if len(data) < 8 {
+			return
+		}
+		srcPort, dstPort = binary.BigEndian.Uint16(data), binary.BigEndian.Uint16(data[2:])
+		data = data[8:]
+	default:
+		data = nil
+	}
+
+	flow := b.flow(proto, netip.AddrPortFrom(src, srcPort), netip.AddrPortFrom(dst, dstPort), p.time, len(p.data))
+	if len(data) == 0 {
+		return
+	}
+
+	switch {
+	case proto == protoUDP && (srcPort == 53 || dstPort == 53):
+		for _, name := range dnsQuestions(data) {
+			b.observe(KindDNSQuery, name, flow, p.time)
+		}
+	case proto == protoTCP && (srcPort == 53 || dstPort == 53):
+		// DNS over TCP carries a two-byte length prefix
+		if len(data) > 2 {
+			for _, name := range dnsQuestions(data[2:]) {
+				b.observe(KindDNSQuery, name, flow, p.time)
+			}
+		}
+	case proto == protoTCP:
+		if host, url, ok := httpRequest(data); ok {
+			b.observe(KindHTTPHost, host, flow, p.time)
+			if url != "" {
+				b.observe(KindHTTPURL, url, flow, p.time)
+			}
+		} else if sni, ok := tlsServerName(data); ok {
+			b.observe(KindTLSSNI, sni, flow, p.time)
+		}
+	}
+}
+
+// flow records a packet against its conversation and returns the flow index
+func (b *builder) flow(proto uint8, src, dst netip.AddrPort, t time.Time, length int) int {
+	key := flowKey{proto: proto, lo: src, hi: dst}
+	if key.hi.Compare(key.lo) < 0 {
+		key.lo, key.hi = key.hi, key.lo
+	}
+
+	idx, ok := b.flows[key]
+	if !ok {
+		if len(b.capture.Flows) >= maxFlows {
+			return -1
+		}
+		idx = len(b.capture.Flows)
+		b.flows[key] = idx
+		b.capture.Flows = append(b.capture.Flows, Flow{
+			Protocol: protocolName(proto),
+			Src:      src,
+			Dst:      dst,
+			First:    t,
+		})
+	}
+
+	f := &b.capture.Flows[idx]
+	f.Packets++
+	f.Bytes += length
+	if t.After(f.Last) {
+		f.Last = t
+	}
+	if f.First.IsZero() || (!t.IsZero() && t.Before(f.First)) {
+		f.First = t
+	}
+	return idx
+}
+
+// observe records an application-layer value once per flow
+func (b *builder) observe(kind, value string, flow int, t time.Time) {
+	key := observationKey{kind: kind, value: value, flow: flow}
+	if value == "" || b.seen[key] || len(b.capture.Observations) >= maxObservations {
+		return
+	}
+	b.seen[key] = true
+	b.capture.Observations = append(b.capture.Observations, Observation{
+		Kind:  kind,
+		Value: value,
+		Flow:  flow,
+		Time:  t,
+	})
+}
+
+// protocolName names an IP protocol number
+func protocolName(proto uint8) string {
+	switch proto {
+	case protoTCP:
+		return "tcp"
+	case protoUDP:
+		return "udp"
+	case protoICMP:
+		return "icmp"
+	case protoICMPv6:
+		return "icmpv6"
+	default:
+		return fmt.Sprintf("ip-%d", proto)
+	}
+}
+
+// linkPayload strips the link-layer header and returns the EtherType of
+// the network-layer payload
+func linkPayload(linkType uint32, data []byte) (uint16, []byte, bool) {
+	switch linkType {
+	case linkEthernet:
+		if len(data) < 14 {
+			return 0, nil, false
+		}
+		etherType := binary.BigEndian.Uint16(data[12:])
+		data = data[14:]
+		for etherType == etherVLAN || etherType == etherQinQ {
+			if len(data) < 4 {
+				return 0, nil, false
+			}
+			etherType = binary.BigEndian.Uint16(data[2:])
+			data = data[4:]
+		}
+		return etherType, data, true
+
+	case linkRaw, linkRawAlt:
+		if len(data) == 0 {
+			return 0, nil, false
+		}
+		switch data[0] >> 4 {
+		case 4:
+			return etherIPv4, data, true
+		case 6:
+			return etherIPv6, data, true
+		}
+		return 0, nil, false
+
+	case linkIPv4:
+		return etherIPv4, data, true
+
+	case linkIPv6:
+		return etherIPv6, data, true
+
+	case linkNull, linkLoop:
+		// 4-byte address family, in the capturing host's byte order for
+		// NULL and network order for LOOP; the low byte identifies it
+		// either way
+		if len(data) < 4 {
+			return 0, nil, false
+		}
+		family := data[0] | data[3]
+		switch family {
+		case 2:
+			return etherIPv4, data[4:], true
+		case 10, 24, 28, 30:
+			return etherIPv6, data[4:], true
+		}
+		return 0, nil, false
+
+	case linkLinuxSLL:
+		if len(data) < 16 {
+			return 0, nil, false
+		}
+		return binary.BigEndian.Uint16(data[14:]), data[16:], true
+
+	case linkLinuxSL2:
+		if len(data) < 20 {
+			return 0, nil, false
+		}
+		return binary.BigEndian.Uint16(data), data[20:], true
+	}
+	return 0, nil, false
+}
+
+// ipv4Payload returns the addresses, protocol and payload of an IPv4
+// packet. Non-initial fragments are skipped since they carry no transport
+// header.
+func ipv4Payload(data []byte) (src, dst netip.Addr, proto uint8, payload []byte, ok bool) {
+	if len(data) < 20 || data[0]>>4 != 4 {
+		return
+	}
+	ihl := int(data[0]&0x0f) * 4
+	total := int(binary.BigEndian.Uint16(data[2:]))
+	if ihl < 20 || ihl > len(data) {
+		return
+	}
+	if total >= ihl && total < len(data) {
+		// Trim Ethernet padding
+		data = data[:total]
+	}
+	if binary.BigEndian.Uint16(data[6:])&0x1fff != 0 {
+		return
+	}
+	src = netip.AddrFrom4([4]byte(data[12:16]))
+	dst = netip.AddrFrom4([4]byte(data[16:20]))
+	return src, dst, data[9], data[ihl:], true
+}
+
+// ipv6Payload returns the addresses, upper-layer protocol and payload of
+// an IPv6 packet, walking past extension headers
+func ipv6Payload(data []byte) (src, dst netip.Addr, proto uint8, payload []byte, ok bool) {
+	if len(data) < 40 || data[0]>>4 != 6 {
+		return
+	}
+	length := int(binary.BigEndian.Uint16(data[4:]))
+	next := data[6]
+	src = netip.AddrFrom16([16]byte(data[8:24]))
+	dst = netip.AddrFrom16([16]byte(data[24:40]))
+	data = data[40:]
+	if length > 0 && length < len(data) {
+		data = data[:length]
+	}
+
+	for {
+		switch next {
+		case 0, 43, 60: // Hop-by-hop, routing, destination options
+			if len(data) < 8 {
+				return
+			}
+			n := (int(data[1]) + 1) * 8
+			if n > len(data) {
+				return
+			}
+			next, data = data[0], data[n:]
+		case 44: // Fragment
+			if len(data) < 8 || binary.BigEndian.Uint16(data[2:])&0xfff8 != 0 {
+				return
+			}
+			next, data = data[0], data[8:]
+		case 51: // Authentication header
+			if len(data) < 8 {
+				return
+			}
+			n := (int(data[1]) + 2) * 4
+			if n > len(data) {
+				return
+			}
+			next, data = data[0], data[n:]
+		default:
+			return src, dst, next, data, true
+		}
+	}
+}
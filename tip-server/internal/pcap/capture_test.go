@@ -0,0 +1,147 @@
+package pcap
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// wantObservations are the values of testdata/traffic.pcap{,ng}: a DNS
+// query, an HTTP request and a TLS ClientHello from 10.0.0.5
+var wantObservations = []Observation{
+	{Kind: KindDNSQuery, Value: "update-checker-cdn.net", Flow: 0},
+	{Kind: KindHTTPHost, Value: "update-checker-cdn.net", Flow: 1},
+	{Kind: KindHTTPURL, Value: "http://update-checker-cdn.net/gate.php?id=7", Flow: 1},
+	{Kind: KindTLSSNI, Value: "c2.example.org", Flow: 2},
+}
+
+func readFixture(t *testing.T, name string) []byte {
+	t.Helper()
+	content, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return content
+}
+
+func TestParse(t *testing.T) {
+	for _, tt := range []struct {
+		file   string
+		format string
+	}{
+		{"traffic.pcap", FormatPCAP},
+		{"traffic.pcapng", FormatPCAPNG},
+	} {
+		t.Run(tt.file, func(t *testing.T) {
+			content := readFixture(t, tt.file)
+			if format := Detect(content); format != tt.format {
+				t.Fatalf("Detect() = %q, want %q", format, tt.format)
+			}
+			capture, err := Parse(tt.format, content)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if capture.Packets != 3 || capture.Undecoded != 0 {
+				t.Errorf("packets = %d, undecoded = %d; want 3, 0", capture.Packets, capture.Undecoded)
+			}
+			var flows []string
+			for _, f := range capture.Flows {
+				flows = append(flows, f.String())
+			}
+			want := []string{
+				"udp 10.0.0.5:51000 > 192.0.2.53:53",
+				"tcp 10.0.0.5:51001 > 203.0.113.77:80",
+				"tcp 10.0.0.5:51002 > 203.0.113.77:443",
+			}
+			if !slices.Equal(flows, want) {
+				t.Errorf("flows = %v, want %v", flows, want)
+			}
+
+			first := time.Unix(1717200000, 500000000).UTC()
+			if got := capture.Flows[0].First; !got.Equal(first) {
+				t.Errorf("first packet at %v, want %v", got, first)
+			}
+			if len(capture.Observations) != len(wantObservations) {
+				t.Fatalf("observations = %+v, want %+v", capture.Observations, wantObservations)
+			}
+			for idx, o := range capture.Observations {
+				w := wantObservations[idx]
+				if o.Kind != w.Kind || o.Value != w.Value || o.Flow != w.Flow {
+					t.Errorf("observation %d = %+v, want %+v", idx, o, w)
+				}
+			}
+		})
+	}
+}
+
+func TestParseMalformed(t *testing.T) {
+	pcapFile := readFixture(t, "traffic.pcap")
+	pcapng := readFixture(t, "traffic.pcapng")
+
+	tests := []struct {
+		name    string
+		format  string
+		content []byte
+		packets int    // Read before the damage
+		err     string // Returned when nothing could be read
+	}{
+		{"pcap header only", FormatPCAP, pcapFile[:24], 0, ""},
+		{"pcap cut in a header", FormatPCAP, pcapFile[:20], 0, "truncated"},
+		{"pcap cut in the first record", FormatPCAP, pcapFile[:30], 0, "truncated"},
+		{"pcap cut in the last packet", FormatPCAP, pcapFile[:len(pcapFile)-10], 2, ""},
+		{"pcap bad magic", FormatPCAP, append([]byte{0, 0, 0, 0}, pcapFile[4:]...), 0, "not a pcap file"},
+		{"pcapng cut in the section header", FormatPCAPNG, pcapng[:16], 0, "truncated"},
+		{"pcapng cut in the last block", FormatPCAPNG, pcapng[:len(pcapng)-8], 2, ""},
+		{"pcapng zero block length", FormatPCAPNG, append(slices.Clone(pcapng[:4]), make([]byte, 24)...), 0, "truncated"},
+		{"unknown format", "erf", pcapFile, 0, "unsupported"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			capture, err := Parse(tt.format, tt.content)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Errorf("Parse() = %+v, %v; want error %q", capture, err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if capture.Packets != tt.packets {
+				t.Errorf("packets = %d, want %d", capture.Packets, tt.packets)
+			}
+		})
+	}
+}
+
+// TestParseDamaged parses every prefix of the fixtures and every single-byte
+// corruption of them; none may panic
+func TestParseDamaged(t *testing.T) {
+	for _, tt := range []struct {
+		file   string
+		format string
+	}{
+		{"traffic.pcap", FormatPCAP},
+		{"traffic.pcapng", FormatPCAPNG},
+	} {
+		t.Run(tt.file, func(t *testing.T) {
+			content := readFixture(t, tt.file)
+			for n := range content {
+				Parse(tt.format, content[:n])
+			}
+			damaged := slices.Clone(content)
+			for idx := range damaged {
+				for _, b := range []byte{0x00, 0x7f, 0xff} {
+					orig := damaged[idx]
+					damaged[idx] = b
+					Parse(tt.format, damaged)
+					damaged[idx] = orig
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,207 @@
+package pcap
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"strings"
+)
+
+// maxQuestions bounds the questions read from one DNS message
+const maxQuestions = 16
+
+// dnsQuestions returns the query names of a DNS message
+func dnsQuestions(msg []byte) []string {
+	if len(msg) < 12 {
+		return nil
+	}
+	count := int(binary.BigEndian.Uint16(msg[4:]))
+	if count > maxQuestions {
+		count = maxQuestions
+	}
+
+	var names []string
+	off := 12
+	for range count {
+		name, next, ok := dnsName(msg, off)
+		if !ok || next+4 > len(msg) {
+			break
+		}
+		if name != "" {
+			names = append(names, name)
+		}
+		off = next + 4 // QTYPE, QCLASS
+	}
+	return names
+}
+
+// dnsName decodes a possibly compressed domain name at off and returns the
+// offset just past it
+func dnsName(msg []byte, off int) (string, int, bool) {
+	var (
+		labels []string
+		end    = -1
+		jumps  = 0
+	)
+	for {
+		if off >= len(msg) {
+			return "", 0, false
+		}
+		n := int(msg[off])
+		switch {
+		case n == 0:
+			if end < 0 {
+				end = off + 1
+			}
+			return strings.ToLower(strings.Join(labels, ".")), end, true
+		case n&0xc0 == 0xc0:
+			if off+1 >= len(msg) || jumps > 10 {
+				return "", 0, false
+			}
+			if end < 0 {
+				end = off + 2
+			}
+			off = int(binary.BigEndian.Uint16(msg[off:]) & 0x3fff)
+			jumps++
+		case n&0xc0 != 0:
+			return "", 0, false
+		default:
+			if off+1+n > len(msg) {
+				return "", 0, false
+			}
+			labels = append(labels, string(msg[off+1:off+1+n]))
+			off += 1 + n
+		}
+	}
+}
+
+// httpMethods are the request methods recognised at the start of a segment
+var httpMethods = []string{"GET ", "POST ", "HEAD ", "PUT ", "DELETE ", "OPTIONS ", "PATCH ", "CONNECT "}
+
+// httpRequest parses the request line and Host header of an HTTP/1.x
+// request and returns the host and the absolute request URL
+func httpRequest(data []byte) (host, url string, ok bool) {
+	isRequest := false
+	for _, m := range httpMethods {
+		if bytes.HasPrefix(data, []byte(m)) {
+			isRequest = true
+			break
+		}
+	}
+	if !isRequest {
+		return "", "", false
+	}
+	if end := bytes.Index(data, []byte("\r\n\r\n")); end >= 0 {
+		data = data[:end]
+	}
+
+	lines := strings.Split(string(data), "\r\n")
+	parts := strings.Fields(lines[0])
+	if len(parts) != 3 || !strings.HasPrefix(parts[2], "HTTP/") {
+		return "", "", false
+	}
+	method, target := parts[0], parts[1]
+
+	for _, line := range lines[1:] {
+		name, value, found := strings.Cut(line, ":")
+		if found && strings.EqualFold(strings.TrimSpace(name), "Host") {
+			host = strings.TrimSpace(value)
+			break
+		}
+	}
+
+	switch {
+	case method == "CONNECT":
+		// Proxy tunnel; the target is host:port
+		if host == "" {
+			host = target
+		}
+	case strings.HasPrefix(target, "http://"), strings.HasPrefix(target, "https://"):
+		url = target
+	case strings.HasPrefix(target, "/") && host != "":
+		url = "http://" + host + target
+	}
+
+	host = stripPort(host)
+	if host == "" {
+		return "", "", false
+	}
+	return strings.ToLower(host), url, true
+}
+
+// stripPort removes a port and IPv6 brackets from a Host value
+func stripPort(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		return h
+	}
+	return strings.Trim(host, "[]")
+}
+
+// TLS record and handshake constants
+const (
+	tlsRecordHandshake    = 0x16
+	tlsClientHello        = 0x01
+	tlsExtServerName      = 0x0000
+	tlsServerNameHostName = 0x00
+)
+
+// tlsServerName returns the SNI of a TLS ClientHello at the start of data
+func tlsServerName(data []byte) (string, bool) {
+	if len(data) < 9 || data[0] != tlsRecordHandshake || data[1] != 3 || data[5] != tlsClientHello {
+		return "", false
+	}
+	// Record header (5), handshake header (4)
+	hello := data[9:]
+	if n := int(data[6])<<16 | int(data[7])<<8 | int(data[8]); n < len(hello) {
+		hello = hello[:n]
+	}
+
+	// Version (2), random (32)
+	off := 34
+	if off >= len(hello) {
+		return "", false
+	}
+	off += 1 + int(hello[off]) // Session ID
+	if off+2 > len(hello) {
+		return "", false
+	}
+	off += 2 + int(binary.BigEndian.Uint16(hello[off:])) // Cipher suites
+	if off >= len(hello) {
+		return "", false
+	}
+	off += 1 + int(hello[off]) // Compression methods
+	if off+2 > len(hello) {
+		return "", false
+	}
+	exts := hello[off+2:]
+	if n := int(binary.BigEndian.Uint16(hello[off:])); n < len(exts) {
+		exts = exts[:n]
+	}
+
+	for len(exts) >= 4 {
+		extType := binary.BigEndian.Uint16(exts)
+		n := int(binary.BigEndian.Uint16(exts[2:]))
+		if 4+n > len(exts) {
+			return "", false
+		}
+		body := exts[4 : 4+n]
+		exts = exts[4+n:]
+		if extType != tlsExtServerName || len(body) < 2 {
+			continue
+		}
+
+		list := body[2:]
+		for len(list) >= 3 {
+			nameType := list[0]
+			l := int(binary.BigEndian.Uint16(list[1:]))
+			if 3+l > len(list) {
+				break
+			}
+			if nameType == tlsServerNameHostName {
+				return strings.ToLower(string(list[3 : 3+l])), true
+			}
+			list = list[3+l:]
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,276 @@
+// Package pcap reads libpcap and pcapng captures and summarises the traffic
+// into the parts that carry indicators: DNS query names, HTTP hosts and
+// request URLs, TLS server names, and the IP conversations they travelled
+// over.
+package pcap
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/bits"
+	"time"
+)
+
+// Formats recognised by Detect
+const (
+	FormatPCAP   = "pcap"
+	FormatPCAPNG = "pcapng"
+)
+
+// ErrTruncated is returned when a capture ends inside a header or block
+var ErrTruncated = errors.New("truncated capture")
+
+// libpcap file magic numbers, as read in little-endian order
+const (
+	pcapMagicMicro        = 0xa1b2c3d4
+	pcapMagicNano         = 0xa1b23c4d
+	pcapMagicMicroSwapped = 0xd4c3b2a1
+	pcapMagicNanoSwapped  = 0x4d3cb2a1
+
+	pcapHeaderSize = 24
+	pcapRecordSize = 16
+)
+
+// pcapng block types
+const (
+	blockSectionHeader   = 0x0a0d0d0a
+	blockInterface       = 0x00000001
+	blockPacketObsolete  = 0x00000002
+	blockSimplePacket    = 0x00000003
+	blockEnhancedPacket  = 0x00000006
+	byteOrderMagic       = 0x1a2b3c4d
+	optionEnd            = 0
+	optionTimeResolution = 9
+)
+
+// packet is one captured frame
+type packet struct {
+	linkType uint32
+	time     time.Time
+	data     []byte
+}
+
+// Detect returns the capture format of content, or "" if it is not a
+// capture file
+func Detect(content []byte) string {
+	if len(content) < 12 {
+		return ""
+	}
+	if binary.LittleEndian.Uint32(content) == blockSectionHeader {
+		if m := binary.LittleEndian.Uint32(content[8:]); m == byteOrderMagic || m == bits.ReverseBytes32(byteOrderMagic) {
+			return FormatPCAPNG
+		}
+		return ""
+	}
+	switch binary.LittleEndian.Uint32(content) {
+	case pcapMagicMicro, pcapMagicNano, pcapMagicMicroSwapped, pcapMagicNanoSwapped:
+		return FormatPCAP
+	}
+	return ""
+}
+
+// readPackets calls fn for each packet in the capture
+func readPackets(format string, content []byte, fn func(packet)) error {
+	switch format {
+	case FormatPCAP:
+		return readPCAP(content, fn)
+	case FormatPCAPNG:
+		return readPCAPNG(content, fn)
+	default:
+		return fmt.Errorf("unsupported capture format %q", format)
+	}
+}
+
+// readPCAP walks a libpcap file
+func readPCAP(content []byte, fn func(packet)) error {
+	if len(content) < pcapHeaderSize {
+		return ErrTruncated
+	}
+
+	var order binary.ByteOrder = binary.LittleEndian
+	nano := false
+	switch binary.LittleEndian.Uint32(content) {
+	case pcapMagicMicro:
+	case pcapMagicNano:
+		nano = true
+	case pcapMagicMicroSwapped:
+		order = binary.BigEndian
+	case pcapMagicNanoSwapped:
+		order, nano = binary.BigEndian, true
+	default:
+		return errors.New("not a pcap file")
+	}
+	// The upper bits of the link type field carry FCS information
+	linkType := order.Uint32(content[20:]) & 0xffff
+
+	for off := pcapHeaderSize; off < len(content); {
+		if len(content)-off < pcapRecordSize {
+			return ErrTruncated
+		}
+		sec := order.Uint32(content[off:])
+		frac := order.Uint32(content[off+4:])
+		capLen := int(order.Uint32(content[off+8:]))
+		off += pcapRecordSize
+		if capLen < 0 || capLen > len(content)-off {
+			return ErrTruncated
+		}
+
+		nsec := int64(frac)
+		if !nano {
+			nsec *= int64(time.Microsecond)
+		}
+		fn(packet{
+			linkType: linkType,
+			time:     time.Unix(int64(sec), nsec).UTC(),
+			data:     content[off : off+capLen],
+		})
+		off += capLen
+	}
+	return nil
+}
+
+// iface is the per-interface state of a pcapng section
+type iface struct {
+	linkType uint32
+	tsresol  byte // if_tsresol option; 6 (microseconds) by default
+}
+
+// readPCAPNG walks the blocks of a pcapng file. Each section carries its
+// own byte order and interface list.
+func readPCAPNG(content []byte, fn func(packet)) error {
+	var (
+		order  binary.ByteOrder = binary.LittleEndian
+		ifaces []iface
+	)
+
+	for off := 0; off < len(content); {
+		if len(content)-off < 12 {
+			return ErrTruncated
+		}
+		blockType := binary.LittleEndian.Uint32(content[off:])
+		if blockType == blockSectionHeader {
+			// Byte order is only known once the section header is read
+			if binary.LittleEndian.Uint32(content[off+8:]) == byteOrderMagic {
+				order = binary.LittleEndian
+			} else {
+				order = binary.BigEndian
+			}
+			ifaces = ifaces[:0]
+		} else {
+			blockType = order.Uint32(content[off:])
+		}
+
+		blockLen := int(order.Uint32(content[off+4:]))
+		if blockLen < 12 || blockLen%4 != 0 || blockLen > len(content)-off {
+			return ErrTruncated
+		}
+		body := content[off+8 : off+blockLen-4]
+		off += blockLen
+
+		switch blockType {
+		case blockInterface:
+			if len(body) < 8 {
+				return ErrTruncated
+			}
+			ifc := iface{linkType: uint32(order.Uint16(body)), tsresol: 6}
+			if r, ok := findOption(order, body[8:], optionTimeResolution); ok && len(r) == 1 {
+				ifc.tsresol = r[0]
+			}
+			ifaces = append(ifaces, ifc)
+
+		case blockEnhancedPacket:
+			if len(body) < 20 {
+				return ErrTruncated
+			}
+			id := order.Uint32(body)
+			capLen := int(order.Uint32(body[12:]))
+			if int(id) >= len(ifaces) || capLen < 0 || capLen > len(body)-20 {
+				continue
+			}
+			ts := uint64(order.Uint32(body[4:]))<<32 | uint64(order.Uint32(body[8:]))
+			fn(packet{
+				linkType: ifaces[id].linkType,
+				time:     timestamp(ts, ifaces[id].tsresol),
+				data:     body[20 : 20+capLen],
+			})
+
+		case blockPacketObsolete:
+			if len(body) < 20 {
+				return ErrTruncated
+			}
+			id := order.Uint16(body)
+			capLen := int(order.Uint32(body[12:]))
+			if int(id) >= len(ifaces) || capLen < 0 || capLen > len(body)-20 {
+				continue
+			}
+			ts := uint64(order.Uint32(body[4:]))<<32 | uint64(order.Uint32(body[8:]))
+			fn(packet{
+				linkType: ifaces[id].linkType,
+				time:     timestamp(ts, ifaces[id].tsresol),
+				data:     body[20 : 20+capLen],
+			})
+
+		case blockSimplePacket:
+			// Simple packets have no timestamp and belong to interface 0
+			if len(body) < 4 || len(ifaces) == 0 {
+				continue
+			}
+			origLen := int(order.Uint32(body))
+			data := body[4:]
+			if origLen >= 0 && origLen < len(data) {
+				data = data[:origLen]
+			}
+			fn(packet{linkType: ifaces[0].linkType, data: data})
+		}
+	}
+	return nil
+}
+
+// findOption returns the value of the first option with the given code
+func findOption(order binary.ByteOrder, opts []byte, code uint16) ([]byte, bool) {
+	for len(opts) >= 4 {
+		c := order.Uint16(opts)
+		n := int(order.Uint16(opts[2:]))
+		if c == optionEnd || 4+n > len(opts) {
+			break
+		}
+		if c == code {
+			return opts[4 : 4+n], true
+		}
+		next := 4 + (n+3)&^3
+		if next > len(opts) {
+			break
+		}
+		opts = opts[next:]
+	}
+	return nil, false
+}
+
+// timestamp converts a pcapng timestamp in units of if_tsresol to a time.
+// The high bit of tsresol selects a power of two rather than ten.
+func timestamp(ts uint64, tsresol byte) time.Time {
+	if tsresol&0x80 != 0 {
+		shift := uint(tsresol & 0x7f)
+		if shift >= 64 {
+			return time.Time{}
+		}
+		sec := ts >> shift
+		frac := ts & (1<<shift - 1)
+		// frac < 2^shift, so frac*1e9 >> shift fits in 64 bits
+		hi, lo := bits.Mul64(frac, uint64(time.Second))
+		nsec := hi<<(64-shift) | lo>>shift
+		return time.Unix(int64(sec), int64(nsec)).UTC()
+	}
+
+	// Finer than nanoseconds; scale down
+	for ; tsresol > 9; tsresol-- {
+		ts /= 10
+	}
+	unit := uint64(1)
+	for i := tsresol; i < 9; i++ {
+		unit *= 10
+	}
+	perSec := uint64(time.Second) / unit
+	return time.Unix(int64(ts/perSec), int64(ts%perSec*unit)).UTC()
+}
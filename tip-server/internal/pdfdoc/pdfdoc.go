@@ -0,0 +1,293 @@
+// Package pdfdoc pulls the scannable parts out of a PDF: link targets from
+// URI actions and the text shown by content streams. Only Flate-compressed
+// and unfiltered streams are read, and text is recovered from literal and
+// hex strings as written; fonts whose glyph codes need a ToUnicode map to
+// read are not decoded.
+package pdfdoc
+
+import (
+	"bytes"
+	"compress/zlib"
+	"io"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode/utf16"
+)
+
+// Document is the scannable content of a PDF
+type Document struct {
+	Text  string   // Text shown on pages, one text object per line
+	Links []string // URI action targets
+}
+
+// Limits on decompression, so a crafted file cannot exhaust memory
+const (
+	maxStreamSize  = 16 << 20
+	maxDecodedSize = 64 << 20
+	sniffSize      = 1024
+)
+
+var (
+	streamStart = regexp.MustCompile(`stream\r?\n`)
+	uriKey      = regexp.MustCompile(`/URI\s*`)
+)
+
+// Detect reports whether a file is a PDF, by extension or header
+func Detect(path string, content []byte) bool {
+	if strings.EqualFold(filepath.Ext(path), ".pdf") {
+		return true
+	}
+	return bytes.Contains(content[:min(len(content), sniffSize)], []byte("%PDF-"))
+}
+
+// Parse extracts links and text from a PDF. Malformed streams are skipped
+// rather than failing the whole document.
+func Parse(content []byte) *Document {
+	doc := &Document{}
+	seen := make(map[string]bool)
+	var text strings.Builder
+
+	// Object dictionaries outside streams can hold URI actions too
+	doc.addLinks(content, seen)
+
+	decoded := 0
+	for _, loc := range streamStart.FindAllIndex(content, -1) {
+		if decoded >= maxDecodedSize {
+			break
+		}
+		start := loc[1]
+		end := bytes.Index(content[start:], []byte("endstream"))
+		if end < 0 {
+			continue
+		}
+		data := content[start : start+end]
+		dict := content[max(0, loc[0]-sniffSize):loc[0]]
+		if i := bytes.LastIndex(dict, []byte("obj")); i >= 0 {
+			dict = dict[i:]
+		}
+
+		switch {
+		case bytes.Contains(dict, []byte("/FlateDecode")):
+			data = inflate(data)
+		case bytes.Contains(dict, []byte("/Filter")):
+			// Images and other encodings carry no text
+			continue
+		}
+		decoded += len(data)
+
+		doc.addLinks(data, seen)
+		if bytes.Contains(data, []byte("BT")) {
+			showText(data, &text)
+		}
+	}
+
+	doc.Text = text.String()
+	return doc
+}
+
+// Content returns the text followed by the links, newline separated
+func (d *Document) Content() []byte {
+	var b strings.Builder
+	b.WriteString(d.Text)
+	for _, l := range d.Links {
+		b.WriteByte('\n')
+		b.WriteString(l)
+	}
+	return []byte(b.String())
+}
+
+// addLinks records the targets of /URI entries in data
+func (d *Document) addLinks(data []byte, seen map[string]bool) {
+	for _, loc := range uriKey.FindAllIndex(data, -1) {
+		rest := data[loc[1]:]
+		var uri string
+		switch {
+		case len(rest) > 0 && rest[0] == '(':
+			uri, _ = literalString(rest)
+		case len(rest) > 0 && rest[0] == '<':
+			uri, _ = hexString(rest)
+		}
+		uri = strings.TrimSpace(uri)
+		if uri != "" && !seen[uri] {
+			seen[uri] = true
+			d.Links = append(d.Links, uri)
+		}
+	}
+}
+
+// inflate decompresses a Flate stream, keeping whatever decodes before an
+// error
+func inflate(data []byte) []byte {
+	r, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil
+	}
+	defer r.Close()
+	out, _ := io.ReadAll(io.LimitReader(r, maxStreamSize))
+	return out
+}
+
+// showText appends the strings drawn by a content stream, starting a new
+// line at each text positioning operator
+func showText(data []byte, out *strings.Builder) {
+	line := false
+	newline := func() {
+		if line {
+			out.WriteByte('\n')
+			line = false
+		}
+	}
+
+	for i := 0; i < len(data); {
+		switch c := data[i]; {
+		case c == '(':
+			s, n := literalString(data[i:])
+			out.WriteString(s)
+			line = line || s != ""
+			i += n
+		case c == '<' && i+1 < len(data) && data[i+1] != '<':
+			s, n := hexString(data[i:])
+			out.WriteString(s)
+			line = line || s != ""
+			i += n
+		case c == '-' || (c >= '0' && c <= '9') || c == '.':
+			// A large negative kerning adjustment inside TJ is a word gap
+			j := i + 1
+			for j < len(data) && (data[j] == '.' || (data[j] >= '0' && data[j] <= '9')) {
+				j++
+			}
+			if c == '-' && j-i > 3 && line {
+				out.WriteByte(' ')
+			}
+			i = j
+		case c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || c == '\'' || c == '"' || c == '*':
+			j := i + 1
+			for j < len(data) && (data[j] >= 'A' && data[j] <= 'Z' || data[j] >= 'a' && data[j] <= 'z' || data[j] == '*') {
+				j++
+			}
+			switch string(data[i:j]) {
+			case "Td", "TD", "T*", "Tm", "ET", "'", "\"":
+				newline()
+			}
+			i = j
+		default:
+			i++
+		}
+	}
+	newline()
+}
+
+// literalString decodes a (...) string at the start of data and returns it
+// with the number of bytes consumed
+func literalString(data []byte) (string, int) {
+	var b []byte
+	depth := 0
+	i := 0
+	for ; i < len(data); i++ {
+		c := data[i]
+		switch c {
+		case '(':
+			depth++
+			if depth == 1 {
+				continue
+			}
+		case ')':
+			depth--
+			if depth == 0 {
+				return decodeText(b), i + 1
+			}
+		case '\\':
+			i++
+			if i >= len(data) {
+				break
+			}
+			switch e := data[i]; e {
+			case 'n':
+				c = '\n'
+			case 'r':
+				c = '\r'
+			case 't':
+				c = '\t'
+			case 'b':
+				c = '\b'
+			case 'f':
+				c = '\f'
+			case '\r', '\n':
+				// Line continuation
+				if e == '\r' && i+1 < len(data) && data[i+1] == '\n' {
+					i++
+				}
+				continue
+			default:
+				if e >= '0' && e <= '7' {
+					v := 0
+					for k := 0; k < 3 && i < len(data) && data[i] >= '0' && data[i] <= '7'; k++ {
+						v = v*8 + int(data[i]-'0')
+						i++
+					}
+					i--
+					c = byte(v)
+				} else {
+					c = e
+				}
+			}
+		}
+		b = append(b, c)
+	}
+	return decodeText(b), i
+}
+
+// hexString decodes a <...> string at the start of data and returns it
+// with the number of bytes consumed
+func hexString(data []byte) (string, int) {
+	end := bytes.IndexByte(data, '>')
+	if end < 0 {
+		return "", len(data)
+	}
+	var b []byte
+	var hi byte
+	odd := false
+	for _, c := range data[1:end] {
+		var v byte
+		switch {
+		case c >= '0' && c <= '9':
+			v = c - '0'
+		case c >= 'a' && c <= 'f':
+			v = c - 'a' + 10
+		case c >= 'A' && c <= 'F':
+			v = c - 'A' + 10
+		default:
+			continue
+		}
+		if odd {
+			b = append(b, hi<<4|v)
+		} else {
+			hi = v
+		}
+		odd = !odd
+	}
+	if odd {
+		b = append(b, hi<<4)
+	}
+	return decodeText(b), end + 1
+}
+
+// decodeText interprets a PDF string: UTF-16BE with a byte order mark, or
+// single-byte text from which only printable characters are kept
+func decodeText(b []byte) string {
+	if len(b) >= 2 && b[0] == 0xfe && b[1] == 0xff {
+		u := make([]uint16, 0, (len(b)-2)/2)
+		for i := 2; i+1 < len(b); i += 2 {
+			u = append(u, uint16(b[i])<<8|uint16(b[i+1]))
+		}
+		return string(utf16.Decode(u))
+	}
+	out := make([]byte, 0, len(b))
+	for _, c := range b {
+		if c == '\t' || c == '\n' || (c >= 0x20 && c < 0x7f) {
+			out = append(out, c)
+		}
+	}
+	return string(out)
+}
@@ -0,0 +1,171 @@
+// Package redact keeps email addresses out of the stores (EMAIL_REDACTION).
+// Addresses are replaced by a keyed token before they are stored, or by a
+// masked form carrying a shorter token in partial mode, so lookups still
+// match exactly while the address itself is never kept verbatim. The
+// original is sealed next to the token for admins to reveal.
+package redact
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"tip-server/internal/config"
+	"tip-server/internal/models"
+	"tip-server/internal/normalize"
+)
+
+// Redaction modes (EMAIL_REDACTION)
+const (
+	ModeOff     = "off"
+	ModeHash    = "hash"    // "email:" and a 32-digit token
+	ModePartial = "partial" // Masked address and a 16-digit token, e.g. "j***@example.com#9f86d081884c7d65"
+)
+
+const (
+	hashPrefix       = "email:"
+	hashTokenLength  = 32
+	partialTagLength = 16
+)
+
+var (
+	// addressPattern finds addresses in text, as the extractor does
+	addressPattern = regexp.MustCompile(`(?i)\b[a-z0-9._%+-]+@[a-z0-9.-]+\.[a-z]{2,}\b`)
+
+	// addressForm matches a value that is an address on its own. Redacted
+	// values never match, so redacting twice changes nothing.
+	addressForm = regexp.MustCompile(`(?i)^[a-z0-9._%+-]+@[a-z0-9.-]+\.[a-z]{2,}$`)
+)
+
+// Redactor turns addresses into their stored form. A nil Redactor (mode off)
+// leaves every value as it is.
+type Redactor struct {
+	mode     string
+	tokenKey []byte
+	aead     cipher.AEAD
+}
+
+// New returns the redactor for cfg, or nil when redaction is off
+func New(cfg config.RedactionConfig) *Redactor {
+	if cfg.Mode == "" || cfg.Mode == ModeOff {
+		return nil
+	}
+
+	// Tokens and seals use separate keys derived from the one secret
+	block, err := aes.NewCipher(deriveKey(cfg.Key, "seal"))
+	if err != nil {
+		panic(err) // A 32-byte key is always valid
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		panic(err) // Standard nonce and tag sizes
+	}
+	return &Redactor{mode: cfg.Mode, tokenKey: deriveKey(cfg.Key, "token"), aead: aead}
+}
+
+func deriveKey(secret, purpose string) []byte {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(purpose))
+	return mac.Sum(nil)
+}
+
+// Enabled reports whether addresses are redacted
+func (r *Redactor) Enabled() bool {
+	return r != nil
+}
+
+// Value returns the stored form of a normalized indicator: addresses are
+// redacted and anything else is returned unchanged
+func (r *Redactor) Value(value string) string {
+	if r == nil || !addressForm.MatchString(value) {
+		return value
+	}
+	return r.address(value)
+}
+
+// IOCs redacts the email IOCs of a batch in place before it is stored,
+// sealing each original address
+func (r *Redactor) IOCs(iocs []models.IOC) {
+	if r == nil {
+		return
+	}
+	for idx := range iocs {
+		ioc := &iocs[idx]
+		if ioc.Type != models.IOCTypeEmail || !addressForm.MatchString(ioc.Value) {
+			continue
+		}
+		token := r.address(ioc.Value)
+		ioc.Sealed = r.seal(ioc.Value, token)
+		ioc.Value = token
+	}
+}
+
+// Text replaces the addresses in text content by their stored form. Content
+// that is not valid UTF-8 (binaries, archives) is returned unchanged.
+func (r *Redactor) Text(content []byte) []byte {
+	if r == nil || !utf8.Valid(content) || !addressPattern.Match(content) {
+		return content
+	}
+	return addressPattern.ReplaceAllFunc(content, func(m []byte) []byte {
+		return []byte(r.address(normalize.Email(string(m))))
+	})
+}
+
+// Open returns the address a redacted value stands for, from the sealed
+// original stored with it
+func (r *Redactor) Open(value, sealed string) (string, error) {
+	if r == nil {
+		return "", errors.New("email redaction is off")
+	}
+	raw, err := base64.StdEncoding.DecodeString(sealed)
+	if err != nil {
+		return "", err
+	}
+	n := r.aead.NonceSize()
+	if len(raw) < n {
+		return "", errors.New("sealed value is truncated")
+	}
+	plain, err := r.aead.Open(nil, raw[:n], raw[n:], []byte(value))
+	if err != nil {
+		return "", errors.New("sealed value does not match (was EMAIL_REDACTION_KEY changed?)")
+	}
+	return string(plain), nil
+}
+
+// address returns the stored form of an address
+func (r *Redactor) address(addr string) string {
+	mac := hmac.New(sha256.New, r.tokenKey)
+	mac.Write([]byte(addr))
+	sum := hex.EncodeToString(mac.Sum(nil))
+
+	if r.mode == ModePartial {
+		return Mask(addr) + "#" + sum[:partialTagLength]
+	}
+	return hashPrefix + sum[:hashTokenLength]
+}
+
+// seal encrypts an address, bound to its token so a sealed original cannot
+// be moved to another value
+func (r *Redactor) seal(addr, token string) string {
+	nonce := make([]byte, r.aead.NonceSize())
+	rand.Read(nonce)
+	return base64.StdEncoding.EncodeToString(r.aead.Seal(nonce, nonce, []byte(addr), []byte(token)))
+}
+
+// Mask keeps the first character of an address's local part and its domain
+func Mask(addr string) string {
+	local, domain, ok := strings.Cut(addr, "@")
+	if !ok || local == "" {
+		return addr
+	}
+	first, _ := utf8.DecodeRuneInString(local)
+	return string(first) + "***@" + domain
+}
@@ -0,0 +1,129 @@
+package redact
+
+import (
+	"bytes"
+	"encoding/base64"
+	"regexp"
+	"testing"
+
+	"tip-server/internal/config"
+	"tip-server/internal/models"
+)
+
+const address = "invoices@payments-portal-secure.com"
+
+func TestValue(t *testing.T) {
+	tests := []struct {
+		mode  string
+		value string
+		want  string // Pattern of the stored form
+	}{
+		{ModeHash, address, `^email:[0-9a-f]{32}$`},
+		{ModePartial, address, `^i\*\*\*@payments-portal-secure\.com#[0-9a-f]{16}$`},
+		{ModeOff, address, `^` + regexp.QuoteMeta(address) + `$`},
+		{ModeHash, "update-checker-cdn.net", `^update-checker-cdn\.net$`},
+		{ModeHash, "http://evil.example/?to=" + address, `^http://evil\.example/\?to=`},
+		{ModeHash, "email:0123456789abcdef0123456789abcdef", `^email:0123456789abcdef0123456789abcdef$`},
+		{ModePartial, "i***@payments-portal-secure.com#0123456789abcdef", `^i\*\*\*@payments-portal-secure\.com#0123456789abcdef$`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.mode+" "+tt.value, func(t *testing.T) {
+			r := New(config.RedactionConfig{Mode: tt.mode, Key: "secret"})
+			got := r.Value(tt.value)
+			if !regexp.MustCompile(tt.want).MatchString(got) {
+				t.Errorf("Value(%q) = %q, want %s", tt.value, got, tt.want)
+			}
+			if r.Value(got) != got {
+				t.Errorf("Value() is not idempotent on %q", got)
+			}
+			if r.Value(tt.value) != got {
+				t.Errorf("Value(%q) is not deterministic", tt.value)
+			}
+		})
+	}
+
+	// Tokens depend on the key
+	a := New(config.RedactionConfig{Mode: ModeHash, Key: "one"})
+	b := New(config.RedactionConfig{Mode: ModeHash, Key: "two"})
+	if a.Value(address) == b.Value(address) {
+		t.Error("tokens do not depend on EMAIL_REDACTION_KEY")
+	}
+}
+
+func TestSealOpen(t *testing.T) {
+	for _, mode := range []string{ModeHash, ModePartial} {
+		t.Run(mode, func(t *testing.T) {
+			r := New(config.RedactionConfig{Mode: mode, Key: "secret"})
+			iocs := []models.IOC{
+				{Type: models.IOCTypeEmail, Value: address},
+				{Type: models.IOCTypeEmail, Value: "collect@update-checker-cdn.net"},
+				{Type: models.IOCTypeDomain, Value: "update-checker-cdn.net"},
+			}
+			r.IOCs(iocs)
+
+			if iocs[0].Value != r.Value(address) || iocs[0].Sealed == "" {
+				t.Fatalf("redacted IOC = %+v", iocs[0])
+			}
+			if iocs[2].Value != "update-checker-cdn.net" || iocs[2].Sealed != "" {
+				t.Errorf("domain IOC changed: %+v", iocs[2])
+			}
+			got, err := r.Open(iocs[0].Value, iocs[0].Sealed)
+			if err != nil || got != address {
+				t.Fatalf("Open() = %q, %v; want %q", got, err, address)
+			}
+
+			raw, _ := base64.StdEncoding.DecodeString(iocs[0].Sealed)
+			raw[len(raw)-1] ^= 1
+			other := New(config.RedactionConfig{Mode: mode, Key: "rotated"})
+			tests := []struct {
+				name   string
+				r      *Redactor
+				value  string
+				sealed string
+			}{
+				{"moved to another value", r, iocs[1].Value, iocs[0].Sealed},
+				{"flipped bit", r, iocs[0].Value, base64.StdEncoding.EncodeToString(raw)},
+				{"truncated", r, iocs[0].Value, base64.StdEncoding.EncodeToString(raw[:4])},
+				{"not base64", r, iocs[0].Value, "!!"},
+				{"other key", other, iocs[0].Value, iocs[0].Sealed},
+				{"redaction off", nil, iocs[0].Value, iocs[0].Sealed},
+			}
+			for _, tt := range tests {
+				if got, err := tt.r.Open(tt.value, tt.sealed); err == nil {
+					t.Errorf("%s: Open() = %q, want an error", tt.name, got)
+				}
+			}
+		})
+	}
+}
+
+func TestText(t *testing.T) {
+	r := New(config.RedactionConfig{Mode: ModeHash, Key: "secret"})
+
+	text := []byte("From: Invoices@Payments-Portal-Secure.COM\nLink: http://update-checker-cdn.net/\n")
+	want := "From: " + r.Value(address) + "\nLink: http://update-checker-cdn.net/\n"
+	if got := r.Text(text); string(got) != want {
+		t.Errorf("Text() = %q, want %q", got, want)
+	}
+
+	binary := append([]byte{0xff, 0xfe}, address...)
+	if got := r.Text(binary); !bytes.Equal(got, binary) {
+		t.Errorf("Text() changed binary content: %q", got)
+	}
+	if got := (*Redactor)(nil).Text(text); !bytes.Equal(got, text) {
+		t.Errorf("Text() with redaction off = %q", got)
+	}
+}
+
+func TestMask(t *testing.T) {
+	for in, want := range map[string]string{
+		address:          "i***@payments-portal-secure.com",
+		"ü@example.com":  "ü***@example.com",
+		"@example.com":   "@example.com",
+		"not an address": "not an address",
+	} {
+		if got := Mask(in); got != want {
+			t.Errorf("Mask(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,287 @@
+// Package signing signs export feeds (FEED_SIGNING_KEY_FILE) so consumers
+// can verify their integrity and origin when intel is relayed through
+// intermediaries. Feeds are signed with Ed25519, either as detached JWS
+// (RFC 7515, alg EdDSA) or in minisign's format.
+package signing
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"hash"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/blake2b"
+
+	"tip-server/internal/config"
+)
+
+// Signature formats (FEED_SIGNING_FORMAT)
+const (
+	FormatJWS      = "jws"
+	FormatMinisign = "minisign"
+)
+
+// Header carries the signature of a signed HTTP response
+const Header = "X-Signature"
+
+// Signature object suffixes, appended to the key of the object they sign
+const (
+	SuffixJWS      = ".jws"
+	SuffixMinisign = ".minisig"
+)
+
+const (
+	keyIDLength = 8 // Bytes, as minisign's key number
+
+	minisignAlgPlain  = "Ed" // Signs the message itself
+	minisignAlgHashed = "ED" // Signs its BLAKE2b-512 digest
+)
+
+// Signer signs feeds with one Ed25519 key. A nil Signer (no key configured)
+// signs nothing.
+type Signer struct {
+	format string
+	key    ed25519.PrivateKey
+	keyID  [keyIDLength]byte
+}
+
+// New loads the signing key of cfg, or returns nil when none is configured
+func New(cfg config.SigningConfig) (*Signer, error) {
+	if cfg.KeyFile == "" {
+		return nil, nil
+	}
+	raw, err := os.ReadFile(cfg.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read feed signing key: %w", err)
+	}
+	block, _ := pem.Decode(raw)
+	if block == nil {
+		return nil, errors.New("feed signing key is not PEM encoded")
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse feed signing key: %w", err)
+	}
+	key, ok := parsed.(ed25519.PrivateKey)
+	if !ok {
+		return nil, errors.New("feed signing key is not an Ed25519 key")
+	}
+
+	s := &Signer{format: cfg.Format, key: key}
+	s.keyID = KeyID(key.Public().(ed25519.PublicKey))
+	return s, nil
+}
+
+// KeyID identifies a public key: the first bytes of its SHA-256, which also
+// serve as its minisign key number
+func KeyID(pub ed25519.PublicKey) [keyIDLength]byte {
+	var id [keyIDLength]byte
+	sum := sha256.Sum256(pub)
+	copy(id[:], sum[:])
+	return id
+}
+
+// Enabled reports whether feeds are signed
+func (s *Signer) Enabled() bool {
+	return s != nil
+}
+
+// Format returns the signature format, "jws" or "minisign"
+func (s *Signer) Format() string {
+	return s.format
+}
+
+// PublicKey describes the key feeds are verified with, in the forms
+// consumers use: raw, as a JWK and as a minisign public key
+func (s *Signer) PublicKey() PublicKey {
+	pub := s.key.Public().(ed25519.PublicKey)
+	return PublicKey{
+		Format:    s.format,
+		Algorithm: "EdDSA",
+		KeyID:     hex.EncodeToString(s.keyID[:]),
+		PublicKey: base64.StdEncoding.EncodeToString(pub),
+		JWK: map[string]string{
+			"kty": "OKP",
+			"crv": "Ed25519",
+			"x":   base64.RawURLEncoding.EncodeToString(pub),
+			"kid": hex.EncodeToString(s.keyID[:]),
+			"use": "sig",
+		},
+		Minisign: base64.StdEncoding.EncodeToString(append(append([]byte(minisignAlgPlain), s.keyID[:]...), pub...)),
+	}
+}
+
+// PublicKey is the public half of the signing key (GET /signing/key)
+type PublicKey struct {
+	Format    string            `json:"format"`
+	Algorithm string            `json:"algorithm"`
+	KeyID     string            `json:"key_id"`
+	PublicKey string            `json:"public_key"` // Base64 Ed25519 public key
+	JWK       map[string]string `json:"jwk"`
+	Minisign  string            `json:"minisign"` // For minisign -P
+}
+
+// Sign returns the detached signature of an HTTP payload, as sent in Header:
+// a compact JWS with the payload left out ("<header>..<signature>"), or a
+// minisign signature file in base64
+func (s *Signer) Sign(payload []byte) string {
+	if s.format == FormatMinisign {
+		sig := s.minisign(minisignAlgPlain, payload, fmt.Sprintf("timestamp:%d", time.Now().Unix()))
+		return base64.StdEncoding.EncodeToString(sig)
+	}
+	protected := s.jwsHeader()
+	return protected + ".." + s.jwsSignature(protected, base64.RawURLEncoding.EncodeToString(payload))
+}
+
+// Digest hashes an object while it is written, for SignObject
+type Digest struct {
+	sha256  hash.Hash
+	blake2b hash.Hash
+	size    int64
+}
+
+// NewDigest returns an empty digest
+func NewDigest() *Digest {
+	b, _ := blake2b.New512(nil) // Only fails for oversized keys
+	return &Digest{sha256: sha256.New(), blake2b: b}
+}
+
+// Write adds p to the digest
+func (d *Digest) Write(p []byte) (int, error) {
+	d.sha256.Write(p)
+	d.blake2b.Write(p)
+	d.size += int64(len(p))
+	return len(p), nil
+}
+
+// SignObject returns the signature of a stored object, to be stored under
+// the object's key plus the returned suffix. Objects can be too large to
+// hold in memory, so they are signed by digest: minisign signs the BLAKE2b
+// digest as "minisign -H" does, and JWS signs a manifest naming the object
+// with its size and SHA-256.
+func (s *Signer) SignObject(key string, d *Digest) (suffix string, sig []byte) {
+	now := time.Now().UTC()
+	if s.format == FormatMinisign {
+		comment := fmt.Sprintf("timestamp:%d\tfile:%s\thashed", now.Unix(), path.Base(key))
+		return SuffixMinisign, s.minisign(minisignAlgHashed, d.blake2b.Sum(nil), comment)
+	}
+
+	manifest, _ := json.Marshal(Manifest{
+		Object:    key,
+		Size:      d.size,
+		SHA256:    hex.EncodeToString(d.sha256.Sum(nil)),
+		CreatedAt: now,
+	})
+	protected := s.jwsHeader()
+	payload := base64.RawURLEncoding.EncodeToString(manifest)
+	return SuffixJWS, []byte(protected + "." + payload + "." + s.jwsSignature(protected, payload))
+}
+
+// Manifest is the payload of the JWS stored with a signed object
+type Manifest struct {
+	Object    string    `json:"object"`
+	Size      int64     `json:"size"`
+	SHA256    string    `json:"sha256"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (s *Signer) jwsHeader() string {
+	header, _ := json.Marshal(map[string]string{"alg": "EdDSA", "kid": hex.EncodeToString(s.keyID[:])})
+	return base64.RawURLEncoding.EncodeToString(header)
+}
+
+func (s *Signer) jwsSignature(protected, payload string) string {
+	return base64.RawURLEncoding.EncodeToString(ed25519.Sign(s.key, []byte(protected+"."+payload)))
+}
+
+// minisign returns a minisign signature file over message with the given
+// trusted comment
+func (s *Signer) minisign(alg string, message []byte, trusted string) []byte {
+	sig := ed25519.Sign(s.key, message)
+	global := ed25519.Sign(s.key, append(append([]byte{}, sig...), trusted...))
+
+	blob := append(append([]byte(alg), s.keyID[:]...), sig...)
+	var b strings.Builder
+	fmt.Fprintf(&b, "untrusted comment: signature from tip-server key %X\n", binary.LittleEndian.Uint64(s.keyID[:]))
+	b.WriteString(base64.StdEncoding.EncodeToString(blob) + "\n")
+	b.WriteString("trusted comment: " + trusted + "\n")
+	b.WriteString(base64.StdEncoding.EncodeToString(global) + "\n")
+	return []byte(b.String())
+}
+
+// ParsePublicKey parses a public key as GET /signing/key lists it: the
+// base64 Ed25519 key or its minisign form
+func ParsePublicKey(s string) (ed25519.PublicKey, error) {
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(s))
+	if err != nil {
+		return nil, fmt.Errorf("public key is not base64: %w", err)
+	}
+	switch len(raw) {
+	case ed25519.PublicKeySize:
+		return ed25519.PublicKey(raw), nil
+	case len(minisignAlgPlain) + keyIDLength + ed25519.PublicKeySize:
+		return ed25519.PublicKey(raw[len(minisignAlgPlain)+keyIDLength:]), nil
+	}
+	return nil, errors.New("public key is neither an Ed25519 nor a minisign key")
+}
+
+// Verify checks the signature Sign returned for payload, in either format
+func Verify(pub ed25519.PublicKey, payload []byte, signature string) error {
+	if signature == "" {
+		return errors.New("payload is not signed")
+	}
+	if protected, sig, ok := strings.Cut(signature, ".."); ok {
+		raw, err := base64.RawURLEncoding.DecodeString(sig)
+		if err != nil {
+			return fmt.Errorf("malformed JWS signature: %w", err)
+		}
+		if !ed25519.Verify(pub, []byte(protected+"."+base64.RawURLEncoding.EncodeToString(payload)), raw) {
+			return errors.New("JWS signature does not match")
+		}
+		return nil
+	}
+
+	file, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return fmt.Errorf("malformed minisign signature: %w", err)
+	}
+	lines := strings.Split(string(file), "\n")
+	if len(lines) < 4 || !strings.HasPrefix(lines[2], "trusted comment: ") {
+		return errors.New("malformed minisign signature")
+	}
+	blob, err := base64.StdEncoding.DecodeString(lines[1])
+	if err != nil || len(blob) != len(minisignAlgPlain)+keyIDLength+ed25519.SignatureSize {
+		return errors.New("malformed minisign signature")
+	}
+	global, err := base64.StdEncoding.DecodeString(lines[3])
+	if err != nil {
+		return errors.New("malformed minisign signature")
+	}
+
+	message := payload
+	switch string(blob[:2]) {
+	case minisignAlgPlain:
+	case minisignAlgHashed:
+		sum := blake2b.Sum512(payload)
+		message = sum[:]
+	default:
+		return errors.New("unsupported minisign signature algorithm")
+	}
+	sig := blob[len(minisignAlgPlain)+keyIDLength:]
+	trusted := strings.TrimPrefix(lines[2], "trusted comment: ")
+	if !ed25519.Verify(pub, message, sig) || !ed25519.Verify(pub, append(append([]byte{}, sig...), trusted...), global) {
+		return errors.New("minisign signature does not match")
+	}
+	return nil
+}
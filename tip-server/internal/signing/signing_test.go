@@ -0,0 +1,199 @@
+package signing
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"tip-server/internal/config"
+)
+
+var feed = []byte(`{"iocs":[{"value":"update-checker-cdn.net","type":"domain"}]}`)
+
+// writeKey stores key as a PKCS #8 PEM file and returns its path
+func writeKey(t *testing.T, key any) string {
+	t.Helper()
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "signing.pem")
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// newSigner returns a signer over a fresh key, and its public key
+func newSigner(t *testing.T, format string) (*Signer, ed25519.PublicKey) {
+	t.Helper()
+	pub, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := New(config.SigningConfig{KeyFile: writeKey(t, key), Format: format})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s, pub
+}
+
+func TestNew(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	notPEM := filepath.Join(dir, "key.txt")
+	garbled := filepath.Join(dir, "garbled.pem")
+	for path, content := range map[string][]byte{
+		notPEM:  []byte("not a key"),
+		garbled: pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: []byte("junk")}),
+	} {
+		if err := os.WriteFile(path, content, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		keyFile string
+		err     string
+	}{
+		{"missing file", filepath.Join(t.TempDir(), "none.pem"), "failed to read"},
+		{"not pem", notPEM, "not PEM encoded"},
+		{"not pkcs8", garbled, "failed to parse"},
+		{"not ed25519", writeKey(t, ecKey), "not an Ed25519 key"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := New(config.SigningConfig{KeyFile: tt.keyFile, Format: FormatJWS})
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("New() = %v, %v; want error %q", s, err, tt.err)
+			}
+		})
+	}
+
+	s, err := New(config.SigningConfig{})
+	if err != nil || s.Enabled() {
+		t.Errorf("New() without a key = %v, %v; want a disabled signer", s, err)
+	}
+}
+
+func TestSignVerify(t *testing.T) {
+	for _, format := range []string{FormatJWS, FormatMinisign} {
+		t.Run(format, func(t *testing.T) {
+			s, pub := newSigner(t, format)
+			other, _ := newSigner(t, format)
+			sig := s.Sign(feed)
+
+			// Consumers configure the key in either of the listed forms
+			listed := s.PublicKey()
+			for _, encoded := range []string{listed.PublicKey, listed.Minisign} {
+				parsed, err := ParsePublicKey(encoded)
+				if err != nil || !parsed.Equal(pub) {
+					t.Fatalf("ParsePublicKey(%q) = %v, %v", encoded, parsed, err)
+				}
+			}
+			if err := Verify(pub, feed, sig); err != nil {
+				t.Fatalf("Verify() = %v", err)
+			}
+
+			tampered := []byte(strings.Replace(string(feed), "update-checker-cdn.net", "update-checker-cdn.org", 1))
+			otherSig := other.Sign(feed)
+			tests := []struct {
+				name      string
+				payload   []byte
+				signature string
+			}{
+				{"tampered payload", tampered, sig},
+				{"signature by another key", feed, otherSig},
+				{"flipped signature bit", feed, flipSignatureBit(t, sig)},
+				{"unsigned", feed, ""},
+				{"garbage", feed, "!!"},
+			}
+			for _, tt := range tests {
+				if err := Verify(pub, tt.payload, tt.signature); err == nil {
+					t.Errorf("%s: Verify() accepted the signature", tt.name)
+				}
+			}
+		})
+	}
+}
+
+// flipSignatureBit returns sig with one bit of its Ed25519 signature flipped
+func flipSignatureBit(t *testing.T, sig string) string {
+	t.Helper()
+	if protected, s, ok := strings.Cut(sig, ".."); ok {
+		raw, _ := base64.RawURLEncoding.DecodeString(s)
+		raw[0] ^= 1
+		return protected + ".." + base64.RawURLEncoding.EncodeToString(raw)
+	}
+	file, err := base64.StdEncoding.DecodeString(sig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(string(file), "\n")
+	blob, _ := base64.StdEncoding.DecodeString(lines[1])
+	blob[len(blob)-1] ^= 1
+	lines[1] = base64.StdEncoding.EncodeToString(blob)
+	return base64.StdEncoding.EncodeToString([]byte(strings.Join(lines, "\n")))
+}
+
+func TestSignObject(t *testing.T) {
+	t.Run(FormatMinisign, func(t *testing.T) {
+		s, pub := newSigner(t, FormatMinisign)
+		d := NewDigest()
+		d.Write(feed[:10])
+		d.Write(feed[10:])
+
+		suffix, sig := s.SignObject("exports/2024/feed.json", d)
+		if suffix != SuffixMinisign || !strings.Contains(string(sig), "\tfile:feed.json\thashed\n") {
+			t.Fatalf("SignObject() = %q, %q", suffix, sig)
+		}
+		encoded := base64.StdEncoding.EncodeToString(sig)
+		if err := Verify(pub, feed, encoded); err != nil {
+			t.Errorf("Verify() = %v", err)
+		}
+		if err := Verify(pub, feed[1:], encoded); err == nil {
+			t.Error("Verify() accepted a truncated object")
+		}
+	})
+
+	t.Run(FormatJWS, func(t *testing.T) {
+		s, pub := newSigner(t, FormatJWS)
+		d := NewDigest()
+		d.Write(feed)
+
+		suffix, sig := s.SignObject("exports/2024/feed.json", d)
+		parts := strings.Split(string(sig), ".")
+		if suffix != SuffixJWS || len(parts) != 3 {
+			t.Fatalf("SignObject() = %q, %q", suffix, sig)
+		}
+		raw, _ := base64.RawURLEncoding.DecodeString(parts[2])
+		if !ed25519.Verify(pub, []byte(parts[0]+"."+parts[1]), raw) {
+			t.Error("JWS signature does not verify")
+		}
+
+		payload, _ := base64.RawURLEncoding.DecodeString(parts[1])
+		var m Manifest
+		if err := json.Unmarshal(payload, &m); err != nil {
+			t.Fatal(err)
+		}
+		sum := sha256.Sum256(feed)
+		if m.Object != "exports/2024/feed.json" || m.Size != int64(len(feed)) || m.SHA256 != hex.EncodeToString(sum[:]) {
+			t.Errorf("manifest = %+v", m)
+		}
+	})
+}
@@ -0,0 +1,293 @@
+// Package typosquat generates lookalike permutations of a domain for brand
+// protection: typos, bit flips, homoglyphs and TLD swaps.
+package typosquat
+
+import (
+	"errors"
+	"net/netip"
+	"regexp"
+	"sort"
+	"strings"
+
+	"golang.org/x/net/idna"
+	"golang.org/x/net/publicsuffix"
+)
+
+// Permutation kinds
+const (
+	KindAddition      = "addition"
+	KindBitsquatting  = "bitsquatting"
+	KindHomoglyph     = "homoglyph"
+	KindHyphenation   = "hyphenation"
+	KindOmission      = "omission"
+	KindRepetition    = "repetition"
+	KindReplacement   = "replacement"
+	KindSubdomain     = "subdomain"
+	KindTLDSwap       = "tld-swap"
+	KindTransposition = "transposition"
+	KindVowelSwap     = "vowel-swap"
+)
+
+// AllKinds returns every permutation kind
+func AllKinds() []string {
+	return []string{
+		KindAddition, KindBitsquatting, KindHomoglyph, KindHyphenation,
+		KindOmission, KindRepetition, KindReplacement, KindSubdomain,
+		KindTLDSwap, KindTransposition, KindVowelSwap,
+	}
+}
+
+// Permutation is a generated lookalike domain
+type Permutation struct {
+	Domain string `json:"domain"` // ASCII (punycode for IDN homoglyphs)
+	Kind   string `json:"kind"`
+}
+
+// ErrInvalidDomain is returned for inputs without a registrable name
+var ErrInvalidDomain = errors.New("not a registrable domain")
+
+// swapTLDs are suffixes commonly abused for lookalike registrations
+var swapTLDs = []string{
+	"com", "net", "org", "info", "biz", "co", "io", "us", "uk", "co.uk", "de", "ru", "cn",
+	"xyz", "top", "online", "site", "club", "shop", "app", "live", "icu", "vip", "cc", "me",
+}
+
+// asciiGlyphs are ASCII sequences that read like a character
+var asciiGlyphs = map[rune][]string{
+	'a': {"4"}, 'b': {"d", "lb"}, 'd': {"b", "cl"}, 'e': {"3"}, 'g': {"q", "9"},
+	'i': {"1", "l"}, 'l': {"1", "i"}, 'm': {"rn", "nn"}, 'n': {"m", "r"},
+	'o': {"0"}, 'q': {"g"}, 's': {"5"}, 'u': {"v"}, 'v': {"u"}, 'w': {"vv"}, 'z': {"2"},
+	'0': {"o"}, '1': {"l", "i"}, '5': {"s"},
+}
+
+// unicodeGlyphs are visually identical non-Latin characters (IDN homographs)
+var unicodeGlyphs = map[rune][]rune{
+	'a': {'а', 'ɑ'}, 'c': {'с', 'ϲ'}, 'd': {'ԁ'}, 'e': {'е'}, 'h': {'һ'},
+	'i': {'і'}, 'j': {'ј'}, 'k': {'κ'}, 'l': {'ӏ'}, 'n': {'ո'}, 'o': {'о', 'ο'},
+	'p': {'р'}, 'q': {'ԛ'}, 's': {'ѕ'}, 'u': {'υ'}, 'v': {'ν'}, 'w': {'ԝ'},
+	'x': {'х'}, 'y': {'у'},
+}
+
+// qwertyAdjacent lists neighbouring keys on a QWERTY keyboard
+var qwertyAdjacent = map[rune]string{
+	'1': "2q", '2': "3wq1", '3': "4ew2", '4': "5re3", '5': "6tr4", '6': "7yt5", '7': "8uy6", '8': "9iu7", '9': "0oi8", '0': "po9",
+	'q': "12wa", 'w': "3esaq2", 'e': "4rdsw3", 'r': "5tfde4", 't': "6ygfr5", 'y': "7uhgt6", 'u': "8ijhy7", 'i': "9okju8", 'o': "0plki9", 'p': "lo0",
+	'a': "qwsz", 's': "edxzaw", 'd': "rfcxse", 'f': "tgvcdr", 'g': "yhbvft", 'h': "ujnbgy", 'j': "ikmnhu", 'k': "olmji", 'l': "kop",
+	'z': "asx", 'x': "zsdc", 'c': "xdfv", 'v': "cfgb", 'b': "vghn", 'n': "bhjm", 'm': "njk",
+}
+
+const vowels = "aeiou"
+
+var labelPattern = regexp.MustCompile(`^[a-z0-9](?:[a-z0-9-]{0,61}[a-z0-9])?$`)
+
+// Generate returns permutations of domain for the requested kinds (all kinds
+// when none are given), deduplicated and excluding the original, up to limit
+// (0 = no limit). The registrable name is permuted; subdomains are dropped.
+func Generate(domain string, kinds []string, limit int) ([]Permutation, error) {
+	domain = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(domain)), ".")
+	if _, err := netip.ParseAddr(domain); err == nil {
+		return nil, ErrInvalidDomain // Public suffix rules would split it like a name
+	}
+	registrable, err := publicsuffix.EffectiveTLDPlusOne(domain)
+	if err != nil {
+		return nil, ErrInvalidDomain
+	}
+	suffix, _ := publicsuffix.PublicSuffix(registrable)
+	name := strings.TrimSuffix(registrable, "."+suffix)
+	if !labelPattern.MatchString(name) {
+		return nil, ErrInvalidDomain
+	}
+
+	enabled := make(map[string]bool)
+	for _, k := range kinds {
+		enabled[k] = true
+	}
+	if len(kinds) == 0 {
+		for _, k := range AllKinds() {
+			enabled[k] = true
+		}
+	}
+
+	g := generator{
+		original: registrable,
+		seen:     map[string]bool{registrable: true},
+	}
+
+	labels := map[string]func(string) []string{
+		KindAddition:      addition,
+		KindBitsquatting:  bitsquatting,
+		KindHyphenation:   hyphenation,
+		KindOmission:      omission,
+		KindRepetition:    repetition,
+		KindReplacement:   replacement,
+		KindTransposition: transposition,
+		KindVowelSwap:     vowelSwap,
+		KindHomoglyph:     asciiHomoglyphs,
+	}
+	for _, kind := range AllKinds() {
+		if fn, ok := labels[kind]; ok && enabled[kind] {
+			for _, label := range fn(name) {
+				g.add(label+"."+suffix, kind)
+			}
+		}
+	}
+
+	if enabled[KindHomoglyph] {
+		for _, d := range idnHomoglyphs(name) {
+			g.add(d+"."+suffix, KindHomoglyph)
+		}
+	}
+	if enabled[KindSubdomain] {
+		for i := 1; i < len(name); i++ {
+			if name[i-1] != '-' && name[i] != '-' {
+				g.add(name[:i]+"."+name[i:]+"."+suffix, KindSubdomain)
+			}
+		}
+	}
+	if enabled[KindTLDSwap] {
+		for _, tld := range swapTLDs {
+			g.add(name+"."+tld, KindTLDSwap)
+		}
+	}
+
+	sort.SliceStable(g.out, func(i, j int) bool { return g.out[i].Kind < g.out[j].Kind })
+	if limit > 0 && len(g.out) > limit {
+		g.out = g.out[:limit]
+	}
+	return g.out, nil
+}
+
+// generator collects valid, unique permutations
+type generator struct {
+	original string
+	seen     map[string]bool
+	out      []Permutation
+}
+
+func (g *generator) add(domain, kind string) {
+	if g.seen[domain] {
+		return
+	}
+	for _, label := range strings.Split(domain, ".") {
+		if !labelPattern.MatchString(label) {
+			return
+		}
+	}
+	g.seen[domain] = true
+	g.out = append(g.out, Permutation{Domain: domain, Kind: kind})
+}
+
+func addition(name string) []string {
+	var out []string
+	for c := 'a'; c <= 'z'; c++ {
+		out = append(out, name+string(c))
+	}
+	for c := '0'; c <= '9'; c++ {
+		out = append(out, name+string(c))
+	}
+	return out
+}
+
+// bitsquatting flips each bit of each character, keeping valid hostname characters
+func bitsquatting(name string) []string {
+	var out []string
+	for i := 0; i < len(name); i++ {
+		for bit := 0; bit < 8; bit++ {
+			c := name[i] ^ (1 << bit)
+			if (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') || c == '-' {
+				out = append(out, name[:i]+string(c)+name[i+1:])
+			}
+		}
+	}
+	return out
+}
+
+func hyphenation(name string) []string {
+	var out []string
+	for i := 1; i < len(name); i++ {
+		out = append(out, name[:i]+"-"+name[i:])
+	}
+	return out
+}
+
+func omission(name string) []string {
+	var out []string
+	for i := 0; i < len(name); i++ {
+		out = append(out, name[:i]+name[i+1:])
+	}
+	return out
+}
+
+func repetition(name string) []string {
+	var out []string
+	for i := 0; i < len(name); i++ {
+		out = append(out, name[:i+1]+name[i:])
+	}
+	return out
+}
+
+// replacement substitutes each character with its keyboard neighbours
+func replacement(name string) []string {
+	var out []string
+	for i, c := range name {
+		for _, r := range qwertyAdjacent[c] {
+			out = append(out, name[:i]+string(r)+name[i+1:])
+		}
+	}
+	return out
+}
+
+func transposition(name string) []string {
+	var out []string
+	for i := 0; i+1 < len(name); i++ {
+		if name[i] != name[i+1] {
+			out = append(out, name[:i]+string(name[i+1])+string(name[i])+name[i+2:])
+		}
+	}
+	return out
+}
+
+func vowelSwap(name string) []string {
+	var out []string
+	for i, c := range name {
+		if !strings.ContainsRune(vowels, c) {
+			continue
+		}
+		for _, v := range vowels {
+			if v != c {
+				out = append(out, name[:i]+string(v)+name[i+1:])
+			}
+		}
+	}
+	return out
+}
+
+// asciiHomoglyphs substitutes single characters with ASCII lookalikes
+func asciiHomoglyphs(name string) []string {
+	var out []string
+	for i, c := range name {
+		for _, g := range asciiGlyphs[c] {
+			out = append(out, name[:i]+g+name[i+1:])
+		}
+	}
+	return out
+}
+
+// idnHomoglyphs substitutes single characters with Unicode lookalikes and
+// returns the punycode (xn--) form registrars and DNS use
+func idnHomoglyphs(name string) []string {
+	var out []string
+	runes := []rune(name)
+	for i, c := range runes {
+		for _, g := range unicodeGlyphs[c] {
+			variant := make([]rune, len(runes))
+			copy(variant, runes)
+			variant[i] = g
+			ascii, err := idna.ToASCII(string(variant))
+			if err == nil {
+				out = append(out, ascii)
+			}
+		}
+	}
+	return out
+}
@@ -0,0 +1,98 @@
+package typosquat
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestGenerate(t *testing.T) {
+	perms, err := Generate(" Login.PayPal.com. ", nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kinds := make(map[string]string, len(perms))
+	for _, p := range perms {
+		if _, dup := kinds[p.Domain]; dup {
+			t.Errorf("%s generated twice", p.Domain)
+		}
+		kinds[p.Domain] = p.Kind
+	}
+
+	positives := []struct {
+		domain string
+		kind   string
+	}{
+		{"paypalx.com", KindAddition},
+		{"qaypal.com", KindBitsquatting},
+		{"paypa1.com", KindHomoglyph},
+		{"xn--pypal-4ve.com", KindHomoglyph}, // Cyrillic а
+		{"pay-pal.com", KindHyphenation},
+		{"paypl.com", KindOmission},
+		{"payypal.com", KindRepetition},
+		{"paypak.com", KindReplacement},
+		{"pay.pal.com", KindSubdomain},
+		{"paypal.co.uk", KindTLDSwap},
+		{"paypal.net", KindTLDSwap},
+		{"pyapal.com", KindTransposition},
+		{"paypol.com", KindVowelSwap},
+	}
+	for _, tt := range positives {
+		if got := kinds[tt.domain]; got != tt.kind {
+			t.Errorf("%s: kind = %q, want %q", tt.domain, got, tt.kind)
+		}
+	}
+
+	negatives := []string{
+		"paypal.com",       // The original
+		"login.paypal.com", // Subdomains are dropped
+		"paypal-.com",
+		"-paypal.com",
+		"pay-.pal.com",
+		"rnypal.com", // Not a lookalike of any single character
+	}
+	for _, domain := range negatives {
+		if kind, ok := kinds[domain]; ok {
+			t.Errorf("%s generated as %s", domain, kind)
+		}
+	}
+}
+
+func TestGenerateOptions(t *testing.T) {
+	tests := []struct {
+		name   string
+		domain string
+		kinds  []string
+		limit  int
+		count  int
+	}{
+		{name: "tld swaps", domain: "example.com", kinds: []string{KindTLDSwap}, count: len(swapTLDs) - 1},
+		{name: "omissions", domain: "abc.org", kinds: []string{KindOmission}, count: 3},
+		{name: "omissions of repeated letters", domain: "aab.org", kinds: []string{KindOmission}, count: 2},
+		{name: "limit", domain: "example.com", limit: 10, count: 10},
+		{name: "unknown kind", domain: "example.com", kinds: []string{"typo"}, count: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			perms, err := Generate(tt.domain, tt.kinds, tt.limit)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(perms) != tt.count {
+				t.Errorf("%d permutations, want %d: %v", len(perms), tt.count, perms)
+			}
+			for _, p := range perms {
+				if len(tt.kinds) == 1 && p.Kind != tt.kinds[0] {
+					t.Errorf("%s is of kind %s", p.Domain, p.Kind)
+				}
+			}
+		})
+	}
+}
+
+func TestGenerateInvalid(t *testing.T) {
+	for _, domain := range []string{"", "com", "co.uk", "exa_mple.com", "-example.com", "203.0.113.77"} {
+		if perms, err := Generate(domain, nil, 0); !errors.Is(err, ErrInvalidDomain) {
+			t.Errorf("Generate(%q) = %d permutations, %v; want ErrInvalidDomain", domain, len(perms), err)
+		}
+	}
+}